@@ -56,6 +56,10 @@ pub enum SendMessageType {
     ApplicationLog = 17,
     SyslogDetail = 18,
     SkyWalking = 19,
+    OpenTelemetryLog = 20,
+    OpenTelemetryMetrics = 21,
+    Zipkin = 22,
+    Jaeger = 23,
 }
 
 impl fmt::Display for SendMessageType {
@@ -80,6 +84,10 @@ impl fmt::Display for SendMessageType {
             Self::ApplicationLog => write!(f, "application_log"),
             Self::SyslogDetail => write!(f, "syslog_detail"),
             Self::SkyWalking => write!(f, "skywalking"),
+            Self::OpenTelemetryLog => write!(f, "open_telemetry_log"),
+            Self::OpenTelemetryMetrics => write!(f, "open_telemetry_metrics"),
+            Self::Zipkin => write!(f, "zipkin"),
+            Self::Jaeger => write!(f, "jaeger"),
         }
     }
 }
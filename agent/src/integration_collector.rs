@@ -126,13 +126,14 @@ pub struct PrometheusExtra {
     metrics: Vec<u8>,
     extra_label_names: Vec<String>,
     extra_label_values: Vec<String>,
+    remote_write_version: u32,
 }
 
 impl Debug for PrometheusExtra {
     fn fmt(&self, f: &mut Formatter<'_>) -> fmt::Result {
         f.write_fmt(format_args!(
-            "PrometheusExtra {{ metrics's len: {}, extra_label_names: {:?}, extra_label_values: {:?}",
-            self.metrics.len(), self.extra_label_names, self.extra_label_values
+            "PrometheusExtra {{ metrics's len: {}, extra_label_names: {:?}, extra_label_values: {:?}, remote_write_version: {}",
+            self.metrics.len(), self.extra_label_names, self.extra_label_values, self.remote_write_version
         ))
     }
 }
@@ -146,6 +147,7 @@ impl Sendable for BoxedPrometheusExtra {
             metrics: self.0.metrics,
             extra_label_names: self.0.extra_label_names,
             extra_label_values: self.0.extra_label_values,
+            remote_write_version: self.0.remote_write_version,
         };
         let _ = pb_prometheus_metric.encode(buf)?;
         Ok(pb_prometheus_metric.encoded_len())
@@ -186,6 +188,42 @@ impl Sendable for Profile {
     }
 }
 
+const PROMETHEUS_REMOTE_WRITE_V1: u32 = 1;
+const PROMETHEUS_REMOTE_WRITE_V2: u32 = 2;
+
+// Negotiate the remote write protocol by the proto parameter of Content-Type, e.g.:
+// application/x-protobuf;proto=io.prometheus.write.v2.Request
+// Returns None if the proto message is not supported.
+fn prometheus_remote_write_version(headers: &HeaderMap) -> Option<u32> {
+    let Some(content_type) = headers.get(CONTENT_TYPE).and_then(|v| v.to_str().ok()) else {
+        return Some(PROMETHEUS_REMOTE_WRITE_V1);
+    };
+    let mut parts = content_type.split(';');
+    if !parts
+        .next()
+        .unwrap_or_default()
+        .trim()
+        .eq_ignore_ascii_case("application/x-protobuf")
+    {
+        // keep compatible with the senders which do not set Content-Type correctly
+        return Some(PROMETHEUS_REMOTE_WRITE_V1);
+    }
+    for param in parts {
+        let Some((key, value)) = param.split_once('=') else {
+            continue;
+        };
+        if !key.trim().eq_ignore_ascii_case("proto") {
+            continue;
+        }
+        return match value.trim().trim_matches('"') {
+            "prometheus.WriteRequest" => Some(PROMETHEUS_REMOTE_WRITE_V1),
+            "io.prometheus.write.v2.Request" => Some(PROMETHEUS_REMOTE_WRITE_V2),
+            _ => None,
+        };
+    }
+    Some(PROMETHEUS_REMOTE_WRITE_V1)
+}
+
 fn decode_metric(mut whole_body: impl Buf, headers: &HeaderMap) -> Result<Vec<u8>, GenericError> {
     let metric = if headers
         .get(CONTENT_ENCODING)
@@ -242,6 +280,74 @@ impl Sendable for ApplicationLog {
     }
 }
 
+/// OpenTelemetry LogsData encoded in Protobuf, zlib-compressed if `compressed` is enabled
+/// ingester detects the compression by the first byte: 0x78 for zlib, 0x0a for the field 'resource_logs' of LogsData
+#[derive(Debug, PartialEq)]
+pub struct OpenTelemetryLog(Vec<u8>);
+
+impl Sendable for OpenTelemetryLog {
+    fn encode(mut self, buf: &mut Vec<u8>) -> Result<usize, prost::EncodeError> {
+        let length = self.0.len();
+        buf.append(&mut self.0);
+        Ok(length)
+    }
+
+    fn message_type(&self) -> SendMessageType {
+        SendMessageType::OpenTelemetryLog
+    }
+}
+
+/// OpenTelemetry MetricsData encoded in Protobuf, zlib-compressed if `compressed` is enabled
+/// ingester detects the compression by the first byte: 0x78 for zlib, 0x0a for the field 'resource_metrics' of MetricsData
+#[derive(Debug, PartialEq)]
+pub struct OpenTelemetryMetrics(Vec<u8>);
+
+impl Sendable for OpenTelemetryMetrics {
+    fn encode(mut self, buf: &mut Vec<u8>) -> Result<usize, prost::EncodeError> {
+        let length = self.0.len();
+        buf.append(&mut self.0);
+        Ok(length)
+    }
+
+    fn message_type(&self) -> SendMessageType {
+        SendMessageType::OpenTelemetryMetrics
+    }
+}
+
+/// Zipkin v2 spans, encoded in JSON or Protobuf
+/// ingester detects the encoding by the first byte, refer to: https://github.com/openzipkin/zipkin-api
+#[derive(Debug, PartialEq)]
+pub struct ZipkinSpans(Vec<u8>);
+
+impl Sendable for ZipkinSpans {
+    fn encode(mut self, buf: &mut Vec<u8>) -> Result<usize, prost::EncodeError> {
+        let length = self.0.len();
+        buf.append(&mut self.0);
+        Ok(length)
+    }
+
+    fn message_type(&self) -> SendMessageType {
+        SendMessageType::Zipkin
+    }
+}
+
+/// Jaeger Batch, encoded in Thrift (binary or compact protocol) or Protobuf
+/// ingester detects the encoding by the first byte, refer to: https://github.com/jaegertracing/jaeger-idl
+#[derive(Debug, PartialEq)]
+pub struct JaegerBatch(Vec<u8>);
+
+impl Sendable for JaegerBatch {
+    fn encode(mut self, buf: &mut Vec<u8>) -> Result<usize, prost::EncodeError> {
+        let length = self.0.len();
+        buf.append(&mut self.0);
+        Ok(length)
+    }
+
+    fn message_type(&self) -> SendMessageType {
+        SendMessageType::Jaeger
+    }
+}
+
 fn decode_otel_trace_data(
     peer_addr: SocketAddr,
     data: Vec<u8>,
@@ -599,6 +705,10 @@ async fn handler(
     profile_sender: DebugSender<Profile>,
     application_log_sender: DebugSender<ApplicationLog>,
     skywalking_sender: DebugSender<SkyWalkingExtra>,
+    otel_metrics_sender: DebugSender<OpenTelemetryMetrics>,
+    otel_log_sender: DebugSender<OpenTelemetryLog>,
+    jaeger_sender: DebugSender<JaegerBatch>,
+    zipkin_sender: DebugSender<ZipkinSpans>,
     exception_handler: ExceptionHandler,
     compressed: bool,
     profile_compressed: bool,
@@ -684,6 +794,12 @@ async fn handler(
                 return Ok(Response::builder().body(Body::empty()).unwrap());
             }
             let headers = req.headers();
+            let Some(remote_write_version) = prometheus_remote_write_version(headers) else {
+                return Ok(Response::builder()
+                    .status(StatusCode::UNSUPPORTED_MEDIA_TYPE)
+                    .body(Body::empty())
+                    .unwrap());
+            };
             let labels = &prometheus_extra_config.extra_labels;
             let labels_limit = prometheus_extra_config.label_length;
             let values_limit = prometheus_extra_config.value_length;
@@ -727,6 +843,7 @@ async fn handler(
                 metrics: metric,
                 extra_label_names,
                 extra_label_values,
+                remote_write_version,
             };
             if let Err(e) =
                 prometheus_sender.send(BoxedPrometheusExtra(Box::new(prometheus_with_extra)))
@@ -829,6 +946,104 @@ async fn handler(
 
             Ok(Response::builder().body(Body::empty()).unwrap())
         }
+        // OpenTelemetry log integration, the path is the same as the OTLP/HTTP receiver
+        (&Method::POST, "/api/v1/otel/logs" | "/v1/logs") => {
+            if external_log_integration_disabled {
+                return Ok(Response::builder().body(Body::empty()).unwrap());
+            }
+            let (part, body) = req.into_parts();
+            let whole_body = match aggregate_with_catch_exception(body, &exception_handler).await {
+                Ok(b) => b,
+                Err(e) => {
+                    return Ok(e);
+                }
+            };
+            let mut logs_data = decode_metric(whole_body, &part.headers)?;
+            if compressed {
+                counter
+                    .uncompressed
+                    .fetch_add(logs_data.len() as u64, Ordering::Relaxed);
+                logs_data = compress_data(logs_data)?;
+                counter
+                    .compressed
+                    .fetch_add(logs_data.len() as u64, Ordering::Relaxed);
+            }
+            if let Err(e) = otel_log_sender.send(OpenTelemetryLog(logs_data)) {
+                warn!("otel_log_sender failed to send data, because {:?}", e);
+            }
+
+            Ok(Response::builder().body(Body::empty()).unwrap())
+        }
+        // OpenTelemetry metrics integration, the path is the same as the OTLP/HTTP receiver
+        (&Method::POST, "/api/v1/otel/metrics" | "/v1/metrics") => {
+            if external_metric_integration_disabled {
+                return Ok(Response::builder().body(Body::empty()).unwrap());
+            }
+            let (part, body) = req.into_parts();
+            let whole_body = match aggregate_with_catch_exception(body, &exception_handler).await {
+                Ok(b) => b,
+                Err(e) => {
+                    return Ok(e);
+                }
+            };
+            let mut metrics_data = decode_metric(whole_body, &part.headers)?;
+            if compressed {
+                counter
+                    .uncompressed
+                    .fetch_add(metrics_data.len() as u64, Ordering::Relaxed);
+                metrics_data = compress_data(metrics_data)?;
+                counter
+                    .compressed
+                    .fetch_add(metrics_data.len() as u64, Ordering::Relaxed);
+            }
+            if let Err(e) = otel_metrics_sender.send(OpenTelemetryMetrics(metrics_data)) {
+                warn!("otel_metrics_sender failed to send data, because {:?}", e);
+            }
+
+            Ok(Response::builder().body(Body::empty()).unwrap())
+        }
+        // Zipkin v2 trace integration, the path is the same as the Zipkin collector
+        (&Method::POST, "/api/v2/spans") => {
+            if external_trace_integration_disabled {
+                return Ok(Response::builder().body(Body::empty()).unwrap());
+            }
+            let (part, body) = req.into_parts();
+            let whole_body = match aggregate_with_catch_exception(body, &exception_handler).await {
+                Ok(b) => b,
+                Err(e) => {
+                    return Ok(e);
+                }
+            };
+            let spans = decode_metric(whole_body, &part.headers)?;
+            if let Err(e) = zipkin_sender.send(ZipkinSpans(spans)) {
+                warn!("zipkin_sender failed to send data, because {:?}", e);
+            }
+            Ok(Response::builder()
+                .status(StatusCode::ACCEPTED)
+                .body(Body::empty())
+                .unwrap())
+        }
+        // Jaeger trace integration, the path is the same as the HTTP endpoint of the Jaeger collector
+        (&Method::POST, "/api/traces") => {
+            if external_trace_integration_disabled {
+                return Ok(Response::builder().body(Body::empty()).unwrap());
+            }
+            let (part, body) = req.into_parts();
+            let whole_body = match aggregate_with_catch_exception(body, &exception_handler).await {
+                Ok(b) => b,
+                Err(e) => {
+                    return Ok(e);
+                }
+            };
+            let batch = decode_metric(whole_body, &part.headers)?;
+            if let Err(e) = jaeger_sender.send(JaegerBatch(batch)) {
+                warn!("jaeger_sender failed to send data, because {:?}", e);
+            }
+            Ok(Response::builder()
+                .status(StatusCode::ACCEPTED)
+                .body(Body::empty())
+                .unwrap())
+        }
         (
             &Method::POST,
             "/v3/segments" | "/skywalking.v3.TraceSegmentReportService/collectInSync",
@@ -963,6 +1178,10 @@ pub struct MetricServer {
     profile_sender: DebugSender<Profile>,
     application_log_sender: DebugSender<ApplicationLog>,
     skywalking_sender: DebugSender<SkyWalkingExtra>,
+    otel_metrics_sender: DebugSender<OpenTelemetryMetrics>,
+    otel_log_sender: DebugSender<OpenTelemetryLog>,
+    jaeger_sender: DebugSender<JaegerBatch>,
+    zipkin_sender: DebugSender<ZipkinSpans>,
     port: Arc<AtomicU16>,
     exception_handler: ExceptionHandler,
     server_shutdown_tx: Mutex<Option<mpsc::Sender<()>>>,
@@ -991,6 +1210,10 @@ impl MetricServer {
         profile_sender: DebugSender<Profile>,
         application_log_sender: DebugSender<ApplicationLog>,
         skywalking_sender: DebugSender<SkyWalkingExtra>,
+        otel_metrics_sender: DebugSender<OpenTelemetryMetrics>,
+        otel_log_sender: DebugSender<OpenTelemetryLog>,
+        jaeger_sender: DebugSender<JaegerBatch>,
+        zipkin_sender: DebugSender<ZipkinSpans>,
         port: u16,
         exception_handler: ExceptionHandler,
         compressed: bool,
@@ -1020,6 +1243,10 @@ impl MetricServer {
                 profile_sender,
                 application_log_sender,
                 skywalking_sender,
+                otel_metrics_sender,
+                otel_log_sender,
+                jaeger_sender,
+                zipkin_sender,
                 port: Arc::new(AtomicU16::new(port)),
                 exception_handler,
                 server_shutdown_tx: Default::default(),
@@ -1070,6 +1297,10 @@ impl MetricServer {
         let profile_sender = self.profile_sender.clone();
         let application_log_sender = self.application_log_sender.clone();
         let skywalking_sender = self.skywalking_sender.clone();
+        let otel_metrics_sender = self.otel_metrics_sender.clone();
+        let otel_log_sender = self.otel_log_sender.clone();
+        let jaeger_sender = self.jaeger_sender.clone();
+        let zipkin_sender = self.zipkin_sender.clone();
         let port = self.port.clone();
         let monitor_port = Arc::new(AtomicU16::new(port.load(Ordering::Acquire)));
         let (mon_tx, mon_rx) = oneshot::channel();
@@ -1143,6 +1374,10 @@ impl MetricServer {
                     let profile_sender = profile_sender.clone();
                     let application_log_sender = application_log_sender.clone();
                     let skywalking_sender = skywalking_sender.clone();
+                    let otel_metrics_sender = otel_metrics_sender.clone();
+                    let otel_log_sender = otel_log_sender.clone();
+                    let jaeger_sender = jaeger_sender.clone();
+                    let zipkin_sender = zipkin_sender.clone();
                     let exception_handler_inner = exception_handler.clone();
                     let counter = counter.clone();
                     let compressed = compressed.clone();
@@ -1161,6 +1396,10 @@ impl MetricServer {
                         let profile_sender = profile_sender.clone();
                         let application_log_sender = application_log_sender.clone();
                         let skywalking_sender = skywalking_sender.clone();
+                        let otel_metrics_sender = otel_metrics_sender.clone();
+                        let otel_log_sender = otel_log_sender.clone();
+                        let jaeger_sender = jaeger_sender.clone();
+                        let zipkin_sender = zipkin_sender.clone();
                         let exception_handler = exception_handler_inner.clone();
                         let peer_addr = conn.remote_addr();
                         let counter = counter.clone();
@@ -1185,6 +1424,10 @@ impl MetricServer {
                                     profile_sender.clone(),
                                     application_log_sender.clone(),
                                     skywalking_sender.clone(),
+                                    otel_metrics_sender.clone(),
+                                    otel_log_sender.clone(),
+                                    jaeger_sender.clone(),
+                                    zipkin_sender.clone(),
                                     exception_handler.clone(),
                                     compressed.load(Ordering::Relaxed),
                                     profile_compressed.load(Ordering::Relaxed),
@@ -72,8 +72,9 @@ use crate::{
     },
     handler::{NpbBuilder, PacketHandlerBuilder},
     integration_collector::{
-        ApplicationLog, BoxedPrometheusExtra, MetricServer, OpenTelemetry, OpenTelemetryCompressed,
-        Profile, TelegrafMetric,
+        ApplicationLog, BoxedPrometheusExtra, JaegerBatch, MetricServer, OpenTelemetry,
+        OpenTelemetryCompressed, OpenTelemetryLog, OpenTelemetryMetrics, Profile, TelegrafMetric,
+        ZipkinSpans,
     },
     metric::document::BoxedDocument,
     monitor::Monitor,
@@ -1568,6 +1569,10 @@ pub struct AgentComponents {
     pub proc_event_uniform_sender: UniformSenderThread<BoxedProcEvents>,
     pub application_log_uniform_sender: UniformSenderThread<ApplicationLog>,
     pub skywalking_uniform_sender: UniformSenderThread<SkyWalkingExtra>,
+    pub otel_metrics_uniform_sender: UniformSenderThread<OpenTelemetryMetrics>,
+    pub otel_log_uniform_sender: UniformSenderThread<OpenTelemetryLog>,
+    pub jaeger_uniform_sender: UniformSenderThread<JaegerBatch>,
+    pub zipkin_uniform_sender: UniformSenderThread<ZipkinSpans>,
     pub exception_handler: ExceptionHandler,
     pub proto_log_sender: DebugSender<BoxAppProtoLogsData>,
     pub pcap_batch_sender: DebugSender<BoxedPcapBatch>,
@@ -2467,6 +2472,110 @@ impl AgentComponents {
             None,
         );
 
+        let otel_metrics_queue_name = "1-otel-metrics-to-sender";
+        let (otel_metrics_sender, otel_metrics_receiver, counter) = queue::bounded_with_debug(
+            user_config
+                .processors
+                .flow_log
+                .tunning
+                .flow_aggregator_queue_size,
+            otel_metrics_queue_name,
+            &queue_debugger,
+        );
+        stats_collector.register_countable(
+            &QueueStats {
+                module: otel_metrics_queue_name,
+                ..Default::default()
+            },
+            Countable::Owned(Box::new(counter)),
+        );
+        let otel_metrics_uniform_sender = UniformSenderThread::new(
+            otel_metrics_queue_name,
+            Arc::new(otel_metrics_receiver),
+            config_handler.sender(),
+            stats_collector.clone(),
+            exception_handler.clone(),
+            None,
+        );
+
+        let otel_log_queue_name = "1-otel-log-to-sender";
+        let (otel_log_sender, otel_log_receiver, counter) = queue::bounded_with_debug(
+            user_config
+                .processors
+                .flow_log
+                .tunning
+                .flow_aggregator_queue_size,
+            otel_log_queue_name,
+            &queue_debugger,
+        );
+        stats_collector.register_countable(
+            &QueueStats {
+                module: otel_log_queue_name,
+                ..Default::default()
+            },
+            Countable::Owned(Box::new(counter)),
+        );
+        let otel_log_uniform_sender = UniformSenderThread::new(
+            otel_log_queue_name,
+            Arc::new(otel_log_receiver),
+            config_handler.sender(),
+            stats_collector.clone(),
+            exception_handler.clone(),
+            None,
+        );
+
+        let jaeger_queue_name = "1-jaeger-to-sender";
+        let (jaeger_sender, jaeger_receiver, counter) = queue::bounded_with_debug(
+            user_config
+                .processors
+                .flow_log
+                .tunning
+                .flow_aggregator_queue_size,
+            jaeger_queue_name,
+            &queue_debugger,
+        );
+        stats_collector.register_countable(
+            &QueueStats {
+                module: jaeger_queue_name,
+                ..Default::default()
+            },
+            Countable::Owned(Box::new(counter)),
+        );
+        let jaeger_uniform_sender = UniformSenderThread::new(
+            jaeger_queue_name,
+            Arc::new(jaeger_receiver),
+            config_handler.sender(),
+            stats_collector.clone(),
+            exception_handler.clone(),
+            None,
+        );
+
+        let zipkin_queue_name = "1-zipkin-to-sender";
+        let (zipkin_sender, zipkin_receiver, counter) = queue::bounded_with_debug(
+            user_config
+                .processors
+                .flow_log
+                .tunning
+                .flow_aggregator_queue_size,
+            zipkin_queue_name,
+            &queue_debugger,
+        );
+        stats_collector.register_countable(
+            &QueueStats {
+                module: zipkin_queue_name,
+                ..Default::default()
+            },
+            Countable::Owned(Box::new(counter)),
+        );
+        let zipkin_uniform_sender = UniformSenderThread::new(
+            zipkin_queue_name,
+            Arc::new(zipkin_receiver),
+            config_handler.sender(),
+            stats_collector.clone(),
+            exception_handler.clone(),
+            None,
+        );
+
         let ebpf_dispatcher_id = dispatcher_components.len();
         #[cfg(any(target_os = "linux", target_os = "android"))]
         let mut ebpf_dispatcher_component = None;
@@ -2710,6 +2819,10 @@ impl AgentComponents {
             profile_sender,
             application_log_sender,
             skywalking_sender,
+            otel_metrics_sender,
+            otel_log_sender,
+            jaeger_sender,
+            zipkin_sender,
             candidate_config.metric_server.port,
             exception_handler.clone(),
             candidate_config.metric_server.compressed,
@@ -2795,6 +2908,10 @@ impl AgentComponents {
             proc_event_uniform_sender,
             application_log_uniform_sender,
             skywalking_uniform_sender,
+            otel_metrics_uniform_sender,
+            otel_log_uniform_sender,
+            jaeger_uniform_sender,
+            zipkin_uniform_sender,
             capture_mode: candidate_config.capture_mode,
             packet_sequence_uniform_output, // Enterprise Edition Feature: packet-sequence
             packet_sequence_uniform_sender, // Enterprise Edition Feature: packet-sequence
@@ -2884,6 +3001,10 @@ impl AgentComponents {
             self.proc_event_uniform_sender.start();
             self.application_log_uniform_sender.start();
             self.skywalking_uniform_sender.start();
+            self.otel_metrics_uniform_sender.start();
+            self.otel_log_uniform_sender.start();
+            self.jaeger_uniform_sender.start();
+            self.zipkin_uniform_sender.start();
             if self.config.metric_server.enabled {
                 self.metrics_server_component.start();
             }
@@ -2959,6 +3080,18 @@ impl AgentComponents {
         if let Some(h) = self.skywalking_uniform_sender.notify_stop() {
             join_handles.push(h);
         }
+        if let Some(h) = self.otel_metrics_uniform_sender.notify_stop() {
+            join_handles.push(h);
+        }
+        if let Some(h) = self.otel_log_uniform_sender.notify_stop() {
+            join_handles.push(h);
+        }
+        if let Some(h) = self.jaeger_uniform_sender.notify_stop() {
+            join_handles.push(h);
+        }
+        if let Some(h) = self.zipkin_uniform_sender.notify_stop() {
+            join_handles.push(h);
+        }
         // Enterprise Edition Feature: packet-sequence
         if let Some(h) = self.packet_sequence_uniform_sender.notify_stop() {
             join_handles.push(h);
@@ -64,6 +64,7 @@ const (
 	DOMAIN_TYPE_SUGON             DomainType = 29 // sugon
 	DOMAIN_TYPE_VOLCENGINE        DomainType = 30 // volcengine
 	DOMAIN_TYPE_H3C               DomainType = 31 // h3c
+	DOMAIN_TYPE_GCP               DomainType = 32 // gcp
 )
 
 var DomainTypes []DomainType = []DomainType{
@@ -91,6 +92,7 @@ var DomainTypes []DomainType = []DomainType{
 	DOMAIN_TYPE_SUGON,
 	DOMAIN_TYPE_VOLCENGINE,
 	DOMAIN_TYPE_H3C,
+	DOMAIN_TYPE_GCP,
 }
 
 func GetDomainTypeByName(domainTypeName string) DomainType {
@@ -313,6 +313,10 @@ func exampleDomainConfig(cmd *cobra.Command, args []string) {
 		fmt.Printf(string(example.YamlDomainFileReader))
 	case common.DOMAIN_TYPE_VOLCENGINE:
 		fmt.Printf(string(example.YamlDomainVolcengine))
+	case common.DOMAIN_TYPE_AZURE:
+		fmt.Printf(string(example.YamlDomainAzure))
+	case common.DOMAIN_TYPE_GCP:
+		fmt.Printf(string(example.YamlDomainGCP))
 	default:
 		err := fmt.Sprintf("domain_type %s not supported\n", args[0])
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"fmt"
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.azure")
+
+const (
+	API_VERSION_RESOURCE = "2022-12-01"
+	API_VERSION_NETWORK  = "2023-09-01"
+	API_VERSION_COMPUTE  = "2023-09-01"
+)
+
+type Azure struct {
+	orgID          int
+	teamID         int
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	token          *Token
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewAzure(orgID int, domain mysqlmodel.Domain, globalCloudCfg config.CloudConfig) (*Azure, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(orgID, domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Azure{
+		orgID:  orgID,
+		teamID: domain.TeamID,
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}, nil
+}
+
+func (a *Azure) ClearDebugLog() {
+	a.debugger.Clear()
+}
+
+// CheckAuth 获取 token 并读取订阅信息，校验凭据以及其对订阅的访问权限
+func (a *Azure) CheckAuth() error {
+	_, err := a.get(fmt.Sprintf("%s/subscriptions/%s?api-version=%s", a.config.ManagementURL, a.config.SubscriptionID, API_VERSION_RESOURCE))
+	return err
+}
+
+func (a *Azure) GetCloudData() (model.Resource, error) {
+	a.cloudStatsd = statsd.NewCloudStatsd()
+	a.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+
+	regions, err := a.getRegions()
+	if err != nil {
+		return resource, err
+	}
+
+	vpcs, networks, subnets, peerConnections, err := a.getVPCs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VPCs = vpcs
+	resource.Networks = networks
+	resource.Subnets = subnets
+	resource.PeerConnections = peerConnections
+
+	if err := a.getPublicIPs(); err != nil {
+		return resource, err
+	}
+	vinterfaces, ips, floatingIPs, err := a.getVInterfaces()
+	if err != nil {
+		return resource, err
+	}
+	resource.VInterfaces = append(resource.VInterfaces, vinterfaces...)
+	resource.IPs = append(resource.IPs, ips...)
+	resource.FloatingIPs = floatingIPs
+
+	vms, err := a.getVMs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VMs = vms
+
+	lbs, lbListeners, lbTargetServers, err := a.getLBs()
+	if err != nil {
+		return resource, err
+	}
+	resource.LBs = lbs
+	resource.LBListeners = lbListeners
+	resource.LBTargetServers = lbTargetServers
+
+	natGateways, natVInterfaces, natIPs, natVMConnections, err := a.getNATGateways()
+	if err != nil {
+		return resource, err
+	}
+	resource.NATGateways = natGateways
+	resource.NATVMConnections = natVMConnections
+	resource.VInterfaces = append(resource.VInterfaces, natVInterfaces...)
+	resource.IPs = append(resource.IPs, natIPs...)
+
+	log.Debugf("region resource num info: %v", a.toolDataSet.regionLcuuidToResourceNum, logger.NewORGPrefix(a.orgID))
+	log.Debugf("az resource num info: %v", a.toolDataSet.azLcuuidToResourceNum, logger.NewORGPrefix(a.orgID))
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, a.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(a.getAZs(), a.toolDataSet.azLcuuidToResourceNum)
+
+	a.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(a)
+
+	a.debugger.Refresh()
+	return resource, nil
+}
+
+func (a *Azure) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": a.name,
+		"domain":      a.lcuuid,
+		"platform":    common.AZURE_EN,
+	}
+
+	return statsd.StatsdStatter{
+		OrgID:      a.orgID,
+		TeamID:     a.teamID,
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(a.cloudStatsd),
+	}
+}
+
+// subscriptionPath 返回订阅中某类资源的列表接口路径
+func (a *Azure) subscriptionPath(resourceType, apiVersion string) string {
+	return fmt.Sprintf("/subscriptions/%s/providers/%s?api-version=%s", a.config.SubscriptionID, resourceType, apiVersion)
+}
+
+// Azure 的资源 ID 大小写不敏感，统一转换为小写后使用
+func (a *Azure) getLcuuid(id string) string {
+	return common.GetUUIDByOrgID(a.orgID, strings.ToLower(id))
+}
+
+// parentID 返回子资源的父资源 ID，例如网卡 IP 配置所属的网卡、子网所属的虚拟网络
+func parentID(id string) string {
+	parts := strings.Split(strings.ToLower(id), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.Join(parts[:len(parts)-2], "/")
+}
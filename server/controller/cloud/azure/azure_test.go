@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+)
+
+// the responses of the azure apis, the key is the request path and the page
+var testResponseFiles = map[string]string{
+	"/tenant-1/oauth2/v2.0/token":                                        "token.json",
+	"/subscriptions/sub-1":                                               "subscription.json",
+	"/subscriptions/sub-1/locations":                                     "locations.json",
+	"/subscriptions/sub-1/locations?page=2":                              "locations_2.json",
+	"/subscriptions/sub-1/providers/Microsoft.Network/virtualNetworks":   "virtual_networks.json",
+	"/subscriptions/sub-1/providers/Microsoft.Network/publicIPAddresses": "public_ip_addresses.json",
+	"/subscriptions/sub-1/providers/Microsoft.Network/networkInterfaces": "network_interfaces.json",
+	"/subscriptions/sub-1/providers/Microsoft.Compute/virtualMachines":   "virtual_machines.json",
+	"/subscriptions/sub-1/providers/Microsoft.Network/loadBalancers":     "load_balancers.json",
+	"/subscriptions/sub-1/providers/Microsoft.Network/natGateways":       "nat_gateways.json",
+}
+
+func newTestServer() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if r.FormValue("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		} else if r.Header.Get("Authorization") != "Bearer test-token" || r.URL.Query().Get("api-version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		key := r.URL.Path
+		if page := r.URL.Query().Get("page"); page != "" {
+			key += "?page=" + page
+		}
+		file, ok := testResponseFiles[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile("testfiles/" + file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(string(data), "{{endpoint}}", server.URL)))
+	}))
+	return server
+}
+
+func newTestAzure(url, secret string) *Azure {
+	return &Azure{
+		orgID:          mysqlcommon.DEFAULT_ORG_ID,
+		lcuuidGenerate: "test_azure",
+		name:           "test_azure",
+		httpTimeout:    30,
+		config: &Config{
+			TenantID:       "tenant-1",
+			ClientID:       "client-1",
+			ClientSecret:   secret,
+			SubscriptionID: "sub-1",
+			LoginURL:       url,
+			ManagementURL:  url,
+			IncludeRegions: map[string]bool{"eastus": false, "westus": false},
+		},
+		debugger: cloudcommon.NewDebugger("test_azure"),
+	}
+}
+
+func TestAzure(t *testing.T) {
+	Convey("TestAzure", t, func() {
+		server := newTestServer()
+		defer server.Close()
+
+		Convey("check auth with wrong secret should fail", func() {
+			So(newTestAzure(server.URL, "wrong").CheckAuth(), ShouldNotBeNil)
+			So(newTestAzure(server.URL, "secret").CheckAuth(), ShouldBeNil)
+		})
+
+		azure := newTestAzure(server.URL, "secret")
+		data, err := azure.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("azureResource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 2)
+			So(len(data.AZs), ShouldEqual, 3)
+			So(len(data.VPCs), ShouldEqual, 2)
+			So(len(data.Networks), ShouldEqual, 3)
+			So(len(data.Subnets), ShouldEqual, 3)
+			So(len(data.PeerConnections), ShouldEqual, 1)
+			So(len(data.VMs), ShouldEqual, 3)
+			So(len(data.VInterfaces), ShouldEqual, 5)
+			So(len(data.IPs), ShouldEqual, 5)
+			So(len(data.FloatingIPs), ShouldEqual, 1)
+			So(len(data.LBs), ShouldEqual, 1)
+			So(len(data.LBListeners), ShouldEqual, 1)
+			So(len(data.LBTargetServers), ShouldEqual, 2)
+			So(len(data.NATGateways), ShouldEqual, 1)
+			So(len(data.NATVMConnections), ShouldEqual, 1)
+		})
+
+		Convey("vm should use the ip of its primary nic", func() {
+			So(data.VMs[0].IP, ShouldEqual, "10.0.1.4")
+			So(data.VMs[0].Hostname, ShouldEqual, "vm1")
+			So(data.VMs[0].State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(data.VMs[0].CloudTags, ShouldResemble, map[string]string{"env": "prod"})
+			So(data.VMs[1].State, ShouldEqual, common.VM_STATE_STOPPED)
+			So(data.VInterfaces[0].Mac, ShouldEqual, "00:0d:3a:00:00:01")
+		})
+
+		Convey("public lb should be in the vpc of its backends", func() {
+			So(data.LBs[0].Model, ShouldEqual, cloudcommon.LB_MODEL_EXTERNAL)
+			So(data.LBs[0].VIP, ShouldEqual, "20.0.0.2")
+			So(data.LBs[0].VPCLcuuid, ShouldEqual, data.VMs[0].VPCLcuuid)
+			So(data.LBTargetServers[1].Type, ShouldEqual, common.LB_SERVER_TYPE_VM)
+			So(data.LBTargetServers[1].VMLcuuid, ShouldEqual, data.VMs[1].Lcuuid)
+			So(data.NATVMConnections[0].VMLcuuid, ShouldEqual, data.VMs[1].Lcuuid)
+		})
+	})
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	DEFAULT_LOGIN_URL      = "https://login.microsoftonline.com"
+	DEFAULT_MANAGEMENT_URL = "https://management.azure.com"
+)
+
+type Config struct {
+	RegionLcuuid   string
+	TenantID       string
+	ClientID       string
+	ClientSecret   string
+	SubscriptionID string
+	LoginURL       string // 微软 Entra ID 地址，Azure 中国等主权云需要修改
+	ManagementURL  string // Azure Resource Manager 地址，Azure 中国等主权云需要修改
+	IncludeRegions map[string]bool
+	ExcludeRegions map[string]bool
+}
+
+func (c *Config) LoadFromString(orgID int, sConf string) (err error) {
+	jConf, err := simplejson.NewJson([]byte(sConf))
+	if err != nil {
+		log.Errorf("convert config string: %s to json failed: %v", sConf, err, logger.NewORGPrefix(orgID))
+		return
+	}
+	c.TenantID, err = jConf.Get("tenant_id").String()
+	if err != nil {
+		log.Error("tenant_id must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.ClientID, err = jConf.Get("client_id").String()
+	if err != nil {
+		log.Error("client_id must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	secret, err := jConf.Get("client_secret").String()
+	if err != nil {
+		log.Error("client_secret must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	decryptSecret, err := common.DecryptSecretKey(secret)
+	if err != nil {
+		log.Error("decrypt client_secret failed", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.ClientSecret = decryptSecret
+	c.SubscriptionID, err = jConf.Get("subscription_id").String()
+	if err != nil {
+		log.Error("subscription_id must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.LoginURL = strings.TrimSuffix(jConf.Get("login_url").MustString(), "/")
+	if c.LoginURL == "" {
+		c.LoginURL = DEFAULT_LOGIN_URL
+	}
+	c.ManagementURL = strings.TrimSuffix(jConf.Get("management_url").MustString(), "/")
+	if c.ManagementURL == "" {
+		c.ManagementURL = DEFAULT_MANAGEMENT_URL
+	}
+	c.RegionLcuuid = jConf.Get("region_uuid").MustString()
+	c.IncludeRegions = cloudcommon.UniqRegions(jConf.Get("include_regions").MustString())
+	c.ExcludeRegions = cloudcommon.UniqRegions(jConf.Get("exclude_regions").MustString())
+	return
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+type Token struct {
+	token     string
+	expiresAt time.Time
+}
+
+// 离失效时间小于5m，则认为token已过期
+func (t *Token) isExpired() bool {
+	return time.Until(t.expiresAt) < 5*time.Minute
+}
+
+func newErr(url, msg string) error {
+	return errors.New(fmt.Sprintf("request url: %s, %s", url, msg))
+}
+
+// getToken 使用 client credentials 方式从 Entra ID 获取访问 Azure Resource Manager 的 token
+func (a *Azure) getToken() (string, error) {
+	if a.token != nil && !a.token.isExpired() {
+		return a.token.token, nil
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", a.config.LoginURL, a.config.TenantID)
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.config.ClientID},
+		"client_secret": {a.config.ClientSecret},
+		"scope":         {a.config.ManagementURL + "/.default"},
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", newErr(tokenURL, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := a.do(req)
+	if err != nil {
+		return "", err
+	}
+	token := resp.Get("access_token").MustString()
+	if token == "" {
+		return "", newErr(tokenURL, "access_token not found in response")
+	}
+	a.token = &Token{
+		token:     token,
+		expiresAt: time.Now().Add(time.Duration(resp.Get("expires_in").MustInt()) * time.Second),
+	}
+	return token, nil
+}
+
+func (a *Azure) get(url string) (*simplejson.Json, error) {
+	token, err := a.getToken()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return a.do(req)
+}
+
+// getRawData 获取订阅中的资源列表，列表数据在 value 中，存在 nextLink 时继续获取下一页
+func (a *Azure) getRawData(path, resultKey string) ([]*simplejson.Json, error) {
+	statsdAPIStartTime := time.Now()
+	url := a.config.ManagementURL + path
+	var jsonList []*simplejson.Json
+	for url != "" {
+		resp, err := a.get(url)
+		if err != nil {
+			return nil, err
+		}
+		jData := resp.Get("value")
+		for i := range jData.MustArray() {
+			jsonList = append(jsonList, jData.GetIndex(i))
+		}
+		url = resp.Get("nextLink").MustString()
+	}
+	a.cloudStatsd.RefreshAPIMoniter(resultKey, len(jsonList), statsdAPIStartTime)
+
+	a.debugger.WriteJson(resultKey, a.config.ManagementURL+path, jsonList)
+	return jsonList, nil
+}
+
+func (a *Azure) do(req *http.Request) (*simplejson.Json, error) {
+	url := req.URL.String()
+	log.Debugf("url: %s", url, logger.NewORGPrefix(a.orgID))
+	req.Header.Set("Accept", "application/json")
+
+	client := cloudcommon.GetUnverifyHTTPClient(time.Second * time.Duration(a.httpTimeout))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("failed: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("read failed: %s", err.Error()))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErr(url, fmt.Sprintf("failed: status code (%d), body (%s)", resp.StatusCode, bytes.TrimSpace(respBody)))
+	}
+	jsonResp, err := simplejson.NewJson(respBody)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("JSONiz failed: %s", err.Error()))
+	}
+	return jsonResp, nil
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getLBs 获取负载均衡器，负载均衡规则对应监听器，规则后端池中的网卡 IP 配置对应后端主机
+func (a *Azure) getLBs() ([]model.LB, []model.LBListener, []model.LBTargetServer, error) {
+	jLBs, err := a.getRawData(a.subscriptionPath("Microsoft.Network/loadBalancers", API_VERSION_NETWORK), "loadBalancers")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var lbs []model.LB
+	var lbListeners []model.LBListener
+	var lbTargetServers []model.LBTargetServer
+	for _, jLB := range jLBs {
+		id := strings.ToLower(jLB.Get("id").MustString())
+		if !cloudcommon.CheckJsonAttributes(jLB, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude lb: %s, missing attr", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jLB.Get("location").MustString())
+		if !ok {
+			log.Infof("exclude lb: %s, region not included", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		jProperties := jLB.Get("properties")
+
+		// 内网负载均衡器的 VPC 为前端 IP 所在的虚拟网络，公网负载均衡器的 VPC 为后端虚拟机所在的虚拟网络
+		lbModel := cloudcommon.LB_MODEL_INTERNAL
+		var vpcLcuuid string
+		var vips []string
+		frontendIDToIP := make(map[string]string)
+		jFrontends := jProperties.Get("frontendIPConfigurations")
+		for i := range jFrontends.MustArray() {
+			jFrontend := jFrontends.GetIndex(i)
+			jFrontendProperties := jFrontend.Get("properties")
+			ip := jFrontendProperties.Get("privateIPAddress").MustString()
+			if publicIP, ok := a.toolDataSet.publicIPIDToIP[strings.ToLower(jFrontendProperties.Get("publicIPAddress").Get("id").MustString())]; ok {
+				ip = publicIP
+				lbModel = cloudcommon.LB_MODEL_EXTERNAL
+			}
+			if network, ok := a.toolDataSet.subnetIDToNetwork[strings.ToLower(jFrontendProperties.Get("subnet").Get("id").MustString())]; ok && vpcLcuuid == "" {
+				vpcLcuuid = network.VPCLcuuid
+			}
+			if ip == "" {
+				continue
+			}
+			frontendIDToIP[strings.ToLower(jFrontend.Get("id").MustString())] = ip
+			vips = append(vips, ip)
+		}
+		poolIDToIPConfigIDs := make(map[string][]string)
+		jPools := jProperties.Get("backendAddressPools")
+		for i := range jPools.MustArray() {
+			jPool := jPools.GetIndex(i)
+			poolID := strings.ToLower(jPool.Get("id").MustString())
+			jIPConfigs := jPool.Get("properties").Get("backendIPConfigurations")
+			for j := range jIPConfigs.MustArray() {
+				ipConfigID := strings.ToLower(jIPConfigs.GetIndex(j).Get("id").MustString())
+				poolIDToIPConfigIDs[poolID] = append(poolIDToIPConfigIDs[poolID], ipConfigID)
+				if vpcLcuuid == "" {
+					vpcLcuuid = a.toolDataSet.vmIDToVPCLcuuid[a.toolDataSet.nicIDToVMID[parentID(ipConfigID)]]
+				}
+			}
+		}
+		if vpcLcuuid == "" {
+			log.Infof("exclude lb: %s, vpc not found", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+
+		lb := model.LB{
+			Lcuuid:       a.getLcuuid(id),
+			Name:         jLB.Get("name").MustString(),
+			Label:        id,
+			Model:        lbModel,
+			VIP:          strings.Join(vips, ","),
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		}
+		lbs = append(lbs, lb)
+		a.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+		jRules := jProperties.Get("loadBalancingRules")
+		for i := range jRules.MustArray() {
+			jRule := jRules.GetIndex(i)
+			ruleID := strings.ToLower(jRule.Get("id").MustString())
+			jRuleProperties := jRule.Get("properties")
+			protocol := strings.ToUpper(jRuleProperties.Get("protocol").MustString())
+			listener := model.LBListener{
+				Lcuuid:   a.getLcuuid(ruleID),
+				LBLcuuid: lb.Lcuuid,
+				Name:     jRule.Get("name").MustString(),
+				Label:    ruleID,
+				IPs:      frontendIDToIP[strings.ToLower(jRuleProperties.Get("frontendIPConfiguration").Get("id").MustString())],
+				Protocol: protocol,
+				Port:     jRuleProperties.Get("frontendPort").MustInt(),
+			}
+			lbListeners = append(lbListeners, listener)
+
+			for _, ipConfigID := range poolIDToIPConfigIDs[strings.ToLower(jRuleProperties.Get("backendAddressPool").Get("id").MustString())] {
+				ip, ok := a.toolDataSet.ipConfigIDToIP[ipConfigID]
+				if !ok {
+					log.Infof("exclude lb_target_server: %s, ip not found", ipConfigID, logger.NewORGPrefix(a.orgID))
+					continue
+				}
+				targetServer := model.LBTargetServer{
+					Lcuuid:           common.GetUUIDByOrgID(a.orgID, listener.Lcuuid+ipConfigID),
+					LBLcuuid:         lb.Lcuuid,
+					LBListenerLcuuid: listener.Lcuuid,
+					Type:             common.LB_SERVER_TYPE_IP,
+					IP:               ip,
+					Protocol:         protocol,
+					Port:             jRuleProperties.Get("backendPort").MustInt(),
+					VPCLcuuid:        vpcLcuuid,
+				}
+				if vmID, ok := a.toolDataSet.nicIDToVMID[parentID(ipConfigID)]; ok {
+					targetServer.Type = common.LB_SERVER_TYPE_VM
+					targetServer.VMLcuuid = a.getLcuuid(vmID)
+				}
+				lbTargetServers = append(lbTargetServers, targetServer)
+			}
+		}
+	}
+	return lbs, lbListeners, lbTargetServers, nil
+}
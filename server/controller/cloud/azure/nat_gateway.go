@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"sort"
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getNATGateways 获取 NAT 网关，NAT 网关关联子网中的虚拟机通过该网关访问公网
+func (a *Azure) getNATGateways() ([]model.NATGateway, []model.VInterface, []model.IP, []model.NATVMConnection, error) {
+	jNATGateways, err := a.getRawData(a.subscriptionPath("Microsoft.Network/natGateways", API_VERSION_NETWORK), "natGateways")
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var vmIDs []string
+	for vmID := range a.toolDataSet.vmIDToSubnetIDs {
+		vmIDs = append(vmIDs, vmID)
+	}
+	sort.Strings(vmIDs)
+
+	var natGateways []model.NATGateway
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var natVMConnections []model.NATVMConnection
+	for _, jNATGateway := range jNATGateways {
+		id := strings.ToLower(jNATGateway.Get("id").MustString())
+		if !cloudcommon.CheckJsonAttributes(jNATGateway, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude nat_gateway: %s, missing attr", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jNATGateway.Get("location").MustString())
+		if !ok {
+			log.Infof("exclude nat_gateway: %s, region not included", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		jProperties := jNATGateway.Get("properties")
+		var vpcLcuuid string
+		subnetIDs := make(map[string]bool)
+		jSubnets := jProperties.Get("subnets")
+		for i := range jSubnets.MustArray() {
+			subnetID := strings.ToLower(jSubnets.GetIndex(i).Get("id").MustString())
+			if network, ok := a.toolDataSet.subnetIDToNetwork[subnetID]; ok {
+				vpcLcuuid = network.VPCLcuuid
+				subnetIDs[subnetID] = true
+			}
+		}
+		if vpcLcuuid == "" {
+			log.Infof("exclude nat_gateway: %s, not associated with any subnet", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		var floatingIPs []string
+		jPublicIPs := jProperties.Get("publicIpAddresses")
+		for i := range jPublicIPs.MustArray() {
+			if ip, ok := a.toolDataSet.publicIPIDToIP[strings.ToLower(jPublicIPs.GetIndex(i).Get("id").MustString())]; ok {
+				floatingIPs = append(floatingIPs, ip)
+			}
+		}
+
+		natGateway := model.NATGateway{
+			Lcuuid:       a.getLcuuid(id),
+			Name:         jNATGateway.Get("name").MustString(),
+			Label:        id,
+			FloatingIPs:  strings.Join(floatingIPs, ","),
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		}
+		natGateways = append(natGateways, natGateway)
+		a.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+		vinterfaceLcuuid := common.GetUUIDByOrgID(a.orgID, natGateway.Lcuuid)
+		vinterfaces = append(vinterfaces, model.VInterface{
+			Lcuuid:        vinterfaceLcuuid,
+			Type:          common.VIF_TYPE_WAN,
+			Mac:           common.VIF_DEFAULT_MAC,
+			DeviceLcuuid:  natGateway.Lcuuid,
+			DeviceType:    common.VIF_DEVICE_TYPE_NAT_GATEWAY,
+			NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+			VPCLcuuid:     vpcLcuuid,
+			RegionLcuuid:  regionLcuuid,
+		})
+		for _, ip := range floatingIPs {
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GetUUIDByOrgID(a.orgID, vinterfaceLcuuid+ip),
+				VInterfaceLcuuid: vinterfaceLcuuid,
+				IP:               ip,
+				RegionLcuuid:     regionLcuuid,
+			})
+		}
+
+		for _, vmID := range vmIDs {
+			for _, subnetID := range a.toolDataSet.vmIDToSubnetIDs[vmID] {
+				if !subnetIDs[subnetID] {
+					continue
+				}
+				vmLcuuid := a.getLcuuid(vmID)
+				natVMConnections = append(natVMConnections, model.NATVMConnection{
+					Lcuuid:           common.GetUUIDByOrgID(a.orgID, natGateway.Lcuuid+vmLcuuid),
+					NATGatewayLcuuid: natGateway.Lcuuid,
+					VMLcuuid:         vmLcuuid,
+				})
+				break
+			}
+		}
+	}
+	return natGateways, vinterfaces, ips, natVMConnections, nil
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getRegions 订阅中的每个位置（location）对应一个区域
+func (a *Azure) getRegions() ([]model.Region, error) {
+	jLocations, err := a.getRawData(fmt.Sprintf("/subscriptions/%s/locations?api-version=%s", a.config.SubscriptionID, API_VERSION_RESOURCE), "locations")
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []model.Region
+	for _, jLocation := range jLocations {
+		if !cloudcommon.CheckJsonAttributes(jLocation, []string{"name"}) {
+			continue
+		}
+		name := jLocation.Get("name").MustString()
+		// 区域白名单，如果当前区域不在白名单中，则跳过
+		if _, ok := a.config.IncludeRegions[name]; !ok {
+			log.Infof("region (%s) not in include_regions", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		// 区域黑名单，如果当前区域在黑名单中，则跳过
+		if _, ok := a.config.ExcludeRegions[name]; ok {
+			log.Infof("region (%s) in exclude_regions", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		if a.config.RegionLcuuid != "" {
+			a.toolDataSet.locationToRegionLcuuid[name] = a.config.RegionLcuuid
+			continue
+		}
+		displayName := jLocation.Get("displayName").MustString()
+		if displayName == "" {
+			displayName = name
+		}
+		region := model.Region{
+			Lcuuid: common.GenerateUUIDByOrgID(a.orgID, name+"_"+a.lcuuidGenerate),
+			Label:  name,
+			Name:   displayName,
+		}
+		regions = append(regions, region)
+		a.toolDataSet.locationToRegionLcuuid[name] = region.Lcuuid
+	}
+	return regions, nil
+}
+
+// getRegionLcuuid 返回资源所在位置对应的区域，位置不在 include_regions 中时返回 false
+func (a *Azure) getRegionLcuuid(location string) (string, bool) {
+	location = strings.ToLower(strings.ReplaceAll(location, " ", ""))
+	lcuuid, ok := a.toolDataSet.locationToRegionLcuuid[location]
+	return lcuuid, ok
+}
+
+// getAZLcuuid 可用区为资源所在位置中的 zone，未指定 zone 的资源属于以位置命名的可用区
+func (a *Azure) getAZLcuuid(location, regionLcuuid string, jZones *simplejson.Json) string {
+	name := strings.ToLower(location)
+	if zone := jZones.GetIndex(0).MustString(); zone != "" {
+		name += "-" + zone
+	}
+	lcuuid := common.GenerateUUIDByOrgID(a.orgID, name+"_"+a.lcuuidGenerate)
+	if _, ok := a.toolDataSet.azs[lcuuid]; !ok {
+		a.toolDataSet.azs[lcuuid] = model.AZ{
+			Lcuuid:       lcuuid,
+			Label:        name,
+			Name:         name,
+			RegionLcuuid: regionLcuuid,
+		}
+	}
+	return lcuuid
+}
+
+func (a *Azure) getAZs() []model.AZ {
+	var azs []model.AZ
+	for _, az := range a.toolDataSet.azs {
+		azs = append(azs, az)
+	}
+	sort.Slice(azs, func(i, j int) bool { return azs[i].Lcuuid < azs[j].Lcuuid })
+	return azs
+}
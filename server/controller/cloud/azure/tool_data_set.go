@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+)
+
+type ToolDataSet struct {
+	locationToRegionLcuuid    map[string]string
+	azs                       map[string]model.AZ
+	vnetIDToVPC               map[string]model.VPC
+	subnetIDToNetwork         map[string]model.Network
+	publicIPIDToIP            map[string]string
+	ipConfigIDToIP            map[string]string
+	nicIDToVMID               map[string]string
+	vmIDToVPCLcuuid           map[string]string
+	vmIDToIP                  map[string]string
+	vmIDToSubnetIDs           map[string][]string
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		locationToRegionLcuuid:    make(map[string]string),
+		azs:                       make(map[string]model.AZ),
+		vnetIDToVPC:               make(map[string]model.VPC),
+		subnetIDToNetwork:         make(map[string]model.Network),
+		publicIPIDToIP:            make(map[string]string),
+		ipConfigIDToIP:            make(map[string]string),
+		nicIDToVMID:               make(map[string]string),
+		vmIDToVPCLcuuid:           make(map[string]string),
+		vmIDToIP:                  make(map[string]string),
+		vmIDToSubnetIDs:           make(map[string][]string),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
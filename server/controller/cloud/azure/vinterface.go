@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getPublicIPs 获取公网 IP 地址，动态分配且未绑定的公网 IP 没有地址，忽略
+func (a *Azure) getPublicIPs() error {
+	jPublicIPs, err := a.getRawData(a.subscriptionPath("Microsoft.Network/publicIPAddresses", API_VERSION_NETWORK), "publicIPAddresses")
+	if err != nil {
+		return err
+	}
+	for _, jPublicIP := range jPublicIPs {
+		id := strings.ToLower(jPublicIP.Get("id").MustString())
+		ip := jPublicIP.Get("properties").Get("ipAddress").MustString()
+		if id == "" || ip == "" {
+			continue
+		}
+		a.toolDataSet.publicIPIDToIP[id] = ip
+	}
+	return nil
+}
+
+// getVInterfaces 获取挂载到虚拟机的网卡，网卡 IP 配置中的私网 IP 属于子网，公网 IP 生成 WAN 网卡和浮动 IP
+func (a *Azure) getVInterfaces() ([]model.VInterface, []model.IP, []model.FloatingIP, error) {
+	jNICs, err := a.getRawData(a.subscriptionPath("Microsoft.Network/networkInterfaces", API_VERSION_NETWORK), "networkInterfaces")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var floatingIPs []model.FloatingIP
+	for _, jNIC := range jNICs {
+		id := strings.ToLower(jNIC.Get("id").MustString())
+		if !cloudcommon.CheckJsonAttributes(jNIC, []string{"id", "location", "properties"}) {
+			log.Infof("exclude vinterface: %s, missing attr", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		jProperties := jNIC.Get("properties")
+		vmID := strings.ToLower(jProperties.Get("virtualMachine").Get("id").MustString())
+		mac := strings.ToLower(strings.ReplaceAll(jProperties.Get("macAddress").MustString(), "-", ":"))
+		if vmID == "" || mac == "" {
+			log.Debugf("exclude vinterface: %s, not attached to vm", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jNIC.Get("location").MustString())
+		if !ok {
+			log.Infof("exclude vinterface: %s, region not included", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+
+		vmLcuuid := a.getLcuuid(vmID)
+		var vinterface, wanVInterface *model.VInterface
+		jIPConfigs := jProperties.Get("ipConfigurations")
+		for i := range jIPConfigs.MustArray() {
+			jIPConfig := jIPConfigs.GetIndex(i)
+			ipConfigID := strings.ToLower(jIPConfig.Get("id").MustString())
+			jIPProperties := jIPConfig.Get("properties")
+			privateIP := jIPProperties.Get("privateIPAddress").MustString()
+			subnetID := strings.ToLower(jIPProperties.Get("subnet").Get("id").MustString())
+			network, ok := a.toolDataSet.subnetIDToNetwork[subnetID]
+			if !ok || privateIP == "" {
+				log.Infof("exclude ip configuration: %s, subnet not found", ipConfigID, logger.NewORGPrefix(a.orgID))
+				continue
+			}
+			if vinterface == nil {
+				vinterface = &model.VInterface{
+					Lcuuid:        a.getLcuuid(id),
+					Name:          jNIC.Get("name").MustString(),
+					Type:          common.VIF_TYPE_LAN,
+					Mac:           mac,
+					DeviceLcuuid:  vmLcuuid,
+					DeviceType:    common.VIF_DEVICE_TYPE_VM,
+					NetworkLcuuid: network.Lcuuid,
+					VPCLcuuid:     network.VPCLcuuid,
+					RegionLcuuid:  regionLcuuid,
+				}
+				a.toolDataSet.nicIDToVMID[id] = vmID
+				if _, ok := a.toolDataSet.vmIDToVPCLcuuid[vmID]; !ok {
+					a.toolDataSet.vmIDToVPCLcuuid[vmID] = network.VPCLcuuid
+				}
+			}
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GetUUIDByOrgID(a.orgID, vinterface.Lcuuid+privateIP),
+				VInterfaceLcuuid: vinterface.Lcuuid,
+				IP:               privateIP,
+				SubnetLcuuid:     common.GetUUIDByOrgID(a.orgID, network.Lcuuid),
+				RegionLcuuid:     regionLcuuid,
+			})
+			a.toolDataSet.ipConfigIDToIP[ipConfigID] = privateIP
+			a.toolDataSet.vmIDToSubnetIDs[vmID] = append(a.toolDataSet.vmIDToSubnetIDs[vmID], subnetID)
+			// 虚拟机的 IP 使用主网卡的主 IP 配置
+			if _, ok := a.toolDataSet.vmIDToIP[vmID]; !ok || (jIPProperties.Get("primary").MustBool() && jProperties.Get("primary").MustBool()) {
+				a.toolDataSet.vmIDToIP[vmID] = privateIP
+			}
+
+			publicIP, ok := a.toolDataSet.publicIPIDToIP[strings.ToLower(jIPProperties.Get("publicIPAddress").Get("id").MustString())]
+			if !ok {
+				continue
+			}
+			if wanVInterface == nil {
+				wanVInterface = &model.VInterface{
+					Lcuuid:        common.GetUUIDByOrgID(a.orgID, vinterface.Lcuuid),
+					Type:          common.VIF_TYPE_WAN,
+					Mac:           cloudcommon.GenerateWANVInterfaceMac(mac),
+					DeviceLcuuid:  vmLcuuid,
+					DeviceType:    common.VIF_DEVICE_TYPE_VM,
+					NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+					VPCLcuuid:     network.VPCLcuuid,
+					RegionLcuuid:  regionLcuuid,
+				}
+			}
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GetUUIDByOrgID(a.orgID, wanVInterface.Lcuuid+publicIP),
+				VInterfaceLcuuid: wanVInterface.Lcuuid,
+				IP:               publicIP,
+				RegionLcuuid:     regionLcuuid,
+			})
+			floatingIPs = append(floatingIPs, model.FloatingIP{
+				Lcuuid:        common.GetUUIDByOrgID(a.orgID, vinterface.Lcuuid+publicIP),
+				IP:            publicIP,
+				VMLcuuid:      vmLcuuid,
+				NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+				VPCLcuuid:     network.VPCLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			})
+		}
+		if vinterface != nil {
+			vinterfaces = append(vinterfaces, *vinterface)
+		}
+		if wanVInterface != nil {
+			vinterfaces = append(vinterfaces, *wanVInterface)
+		}
+	}
+	return vinterfaces, ips, floatingIPs, nil
+}
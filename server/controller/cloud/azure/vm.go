@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var STATE_CONVERTION = map[string]int{
+	"PowerState/running":      common.VM_STATE_RUNNING,
+	"PowerState/stopped":      common.VM_STATE_STOPPED,
+	"PowerState/deallocated":  common.VM_STATE_STOPPED,
+	"PowerState/deallocating": common.VM_STATE_STOPPED,
+}
+
+// getVMs 获取虚拟机及其电源状态（statusOnly=true 时返回 instanceView），虚拟机的 VPC 为其网卡所在的虚拟网络
+func (a *Azure) getVMs() ([]model.VM, error) {
+	jVMs, err := a.getRawData(a.subscriptionPath("Microsoft.Compute/virtualMachines", API_VERSION_COMPUTE)+"&statusOnly=true", "virtualMachines")
+	if err != nil {
+		return nil, err
+	}
+
+	var vms []model.VM
+	for _, jVM := range jVMs {
+		id := strings.ToLower(jVM.Get("id").MustString())
+		if !cloudcommon.CheckJsonAttributes(jVM, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude vm: %s, missing attr", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		location := jVM.Get("location").MustString()
+		regionLcuuid, ok := a.getRegionLcuuid(location)
+		if !ok {
+			log.Infof("exclude vm: %s, region not included", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		vpcLcuuid, ok := a.toolDataSet.vmIDToVPCLcuuid[id]
+		if !ok {
+			log.Infof("exclude vm: %s, vpc not found", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+
+		jProperties := jVM.Get("properties")
+		state := common.VM_STATE_EXCEPTION
+		jStatuses := jProperties.Get("instanceView").Get("statuses")
+		for i := range jStatuses.MustArray() {
+			if s, ok := STATE_CONVERTION[jStatuses.GetIndex(i).Get("code").MustString()]; ok {
+				state = s
+				break
+			}
+		}
+		createdAt, _ := time.Parse(time.RFC3339Nano, jProperties.Get("timeCreated").MustString())
+		cloudTags := make(map[string]string)
+		for key, value := range jVM.Get("tags").MustMap() {
+			cloudTags[key] = fmt.Sprint(value)
+		}
+		azLcuuid := a.getAZLcuuid(location, regionLcuuid, jVM.Get("zones"))
+		vms = append(vms, model.VM{
+			Lcuuid:       a.getLcuuid(id),
+			Name:         jVM.Get("name").MustString(),
+			Label:        id,
+			IP:           a.toolDataSet.vmIDToIP[id],
+			Hostname:     jProperties.Get("osProfile").Get("computerName").MustString(),
+			HType:        common.VM_HTYPE_VM_C,
+			State:        state,
+			CreatedAt:    createdAt,
+			VPCLcuuid:    vpcLcuuid,
+			AZLcuuid:     azLcuuid,
+			RegionLcuuid: regionLcuuid,
+			CloudTags:    cloudTags,
+		})
+		a.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+		a.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+	return vms, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const PEERING_STATE_CONNECTED = "Connected"
+
+// getVPCs 虚拟网络对应 VPC，虚拟网络中的每个子网对应一个网络和一个子网，虚拟网络对等连接对应对等连接
+func (a *Azure) getVPCs() ([]model.VPC, []model.Network, []model.Subnet, []model.PeerConnection, error) {
+	jVNets, err := a.getRawData(a.subscriptionPath("Microsoft.Network/virtualNetworks", API_VERSION_NETWORK), "virtualNetworks")
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var vpcs []model.VPC
+	var networks []model.Network
+	var subnets []model.Subnet
+	var includedVNets []*simplejson.Json
+	for _, jVNet := range jVNets {
+		id := strings.ToLower(jVNet.Get("id").MustString())
+		if !cloudcommon.CheckJsonAttributes(jVNet, []string{"id", "name", "location"}) {
+			log.Infof("exclude vpc: %s, missing attr", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jVNet.Get("location").MustString())
+		if !ok {
+			log.Infof("exclude vpc: %s, region not included", id, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		jProperties := jVNet.Get("properties")
+		vpc := model.VPC{
+			Lcuuid:       a.getLcuuid(id),
+			Name:         jVNet.Get("name").MustString(),
+			Label:        id,
+			CIDR:         jProperties.Get("addressSpace").Get("addressPrefixes").GetIndex(0).MustString(),
+			RegionLcuuid: regionLcuuid,
+		}
+		vpcs = append(vpcs, vpc)
+		includedVNets = append(includedVNets, jVNet)
+		a.toolDataSet.vnetIDToVPC[id] = vpc
+
+		jSubnets := jProperties.Get("subnets")
+		for i := range jSubnets.MustArray() {
+			jSubnet := jSubnets.GetIndex(i)
+			subnetID := strings.ToLower(jSubnet.Get("id").MustString())
+			cidr := jSubnet.Get("properties").Get("addressPrefix").MustString()
+			if cidr == "" {
+				cidr = jSubnet.Get("properties").Get("addressPrefixes").GetIndex(0).MustString()
+			}
+			if subnetID == "" || cidr == "" {
+				log.Infof("exclude subnet: %s, missing attr", subnetID, logger.NewORGPrefix(a.orgID))
+				continue
+			}
+			network := model.Network{
+				Lcuuid:       a.getLcuuid(subnetID),
+				Name:         jSubnet.Get("name").MustString(),
+				Label:        subnetID,
+				NetType:      common.NETWORK_TYPE_LAN,
+				VPCLcuuid:    vpc.Lcuuid,
+				RegionLcuuid: regionLcuuid,
+			}
+			networks = append(networks, network)
+			subnets = append(subnets, model.Subnet{
+				Lcuuid:        common.GetUUIDByOrgID(a.orgID, network.Lcuuid),
+				Name:          network.Name,
+				Label:         subnetID,
+				CIDR:          cidr,
+				NetworkLcuuid: network.Lcuuid,
+				VPCLcuuid:     vpc.Lcuuid,
+			})
+			a.toolDataSet.subnetIDToNetwork[subnetID] = network
+		}
+	}
+	return vpcs, networks, subnets, a.getPeerConnections(includedVNets), nil
+}
+
+// getPeerConnections 对等连接在两端的虚拟网络中各有一条记录，按两端虚拟网络去重，对端不在同步范围内时忽略
+func (a *Azure) getPeerConnections(jVNets []*simplejson.Json) []model.PeerConnection {
+	var peerConnections []model.PeerConnection
+	keys := make(map[string]bool)
+	for _, jVNet := range jVNets {
+		localVPC := a.toolDataSet.vnetIDToVPC[strings.ToLower(jVNet.Get("id").MustString())]
+		jPeerings := jVNet.Get("properties").Get("virtualNetworkPeerings")
+		for i := range jPeerings.MustArray() {
+			jPeering := jPeerings.GetIndex(i)
+			id := strings.ToLower(jPeering.Get("id").MustString())
+			jProperties := jPeering.Get("properties")
+			if jProperties.Get("peeringState").MustString() != PEERING_STATE_CONNECTED {
+				log.Infof("exclude peer connection: %s, not connected", id, logger.NewORGPrefix(a.orgID))
+				continue
+			}
+			remoteVPC, ok := a.toolDataSet.vnetIDToVPC[strings.ToLower(jProperties.Get("remoteVirtualNetwork").Get("id").MustString())]
+			if !ok {
+				log.Infof("exclude peer connection: %s, remote vpc not found", id, logger.NewORGPrefix(a.orgID))
+				continue
+			}
+			pair := []string{localVPC.Lcuuid, remoteVPC.Lcuuid}
+			sort.Strings(pair)
+			key := strings.Join(pair, "_")
+			if keys[key] {
+				continue
+			}
+			keys[key] = true
+			peerConnections = append(peerConnections, model.PeerConnection{
+				Lcuuid:             common.GetUUIDByOrgID(a.orgID, key),
+				Name:               jPeering.Get("name").MustString(),
+				Label:              id,
+				LocalVPCLcuuid:     localVPC.Lcuuid,
+				RemoteVPCLcuuid:    remoteVPC.Lcuuid,
+				LocalRegionLcuuid:  localVPC.RegionLcuuid,
+				RemoteRegionLcuuid: remoteVPC.RegionLcuuid,
+			})
+		}
+	}
+	return peerConnections
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	DEFAULT_COMPUTE_URL = "https://compute.googleapis.com/compute/v1"
+	DEFAULT_TOKEN_URL   = "https://oauth2.googleapis.com/token"
+)
+
+type Config struct {
+	RegionLcuuid   string
+	ProjectID      string
+	ClientEmail    string
+	PrivateKey     string // PEM 格式的服务账号私钥
+	TokenURL       string
+	ComputeURL     string
+	IncludeRegions map[string]bool
+	ExcludeRegions map[string]bool
+}
+
+// LoadFromString service_account_key 为服务账号的 JSON 密钥文件内容，project_id 未指定时使用密钥所属的项目
+func (c *Config) LoadFromString(orgID int, sConf string) (err error) {
+	jConf, err := simplejson.NewJson([]byte(sConf))
+	if err != nil {
+		log.Errorf("convert config string: %s to json failed: %v", sConf, err, logger.NewORGPrefix(orgID))
+		return
+	}
+	key, err := jConf.Get("service_account_key").String()
+	if err != nil {
+		log.Error("service_account_key must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	decryptKey, err := common.DecryptSecretKey(key)
+	if err != nil {
+		log.Error("decrypt service_account_key failed", logger.NewORGPrefix(orgID))
+		return
+	}
+	jKey, err := simplejson.NewJson([]byte(decryptKey))
+	if err != nil {
+		log.Errorf("service_account_key is not a valid json key file: %v", err, logger.NewORGPrefix(orgID))
+		return
+	}
+	c.ClientEmail, err = jKey.Get("client_email").String()
+	if err != nil {
+		log.Error("client_email not found in service_account_key", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.PrivateKey, err = jKey.Get("private_key").String()
+	if err != nil {
+		log.Error("private_key not found in service_account_key", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.TokenURL = jKey.Get("token_uri").MustString()
+	if c.TokenURL == "" {
+		c.TokenURL = DEFAULT_TOKEN_URL
+	}
+	c.ProjectID = jConf.Get("project_id").MustString()
+	if c.ProjectID == "" {
+		c.ProjectID, err = jKey.Get("project_id").String()
+		if err != nil {
+			log.Error("project_id must be specified", logger.NewORGPrefix(orgID))
+			return
+		}
+	}
+	c.ComputeURL = strings.TrimSuffix(jConf.Get("compute_url").MustString(), "/")
+	if c.ComputeURL == "" {
+		c.ComputeURL = DEFAULT_COMPUTE_URL
+	}
+	c.RegionLcuuid = jConf.Get("region_uuid").MustString()
+	c.IncludeRegions = cloudcommon.UniqRegions(jConf.Get("include_regions").MustString())
+	c.ExcludeRegions = cloudcommon.UniqRegions(jConf.Get("exclude_regions").MustString())
+	return
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	TOKEN_SCOPE    = "https://www.googleapis.com/auth/compute.readonly"
+	TOKEN_LIFETIME = time.Hour
+	PAGE_SIZE      = 500
+)
+
+type Token struct {
+	token     string
+	expiresAt time.Time
+}
+
+// 离失效时间小于5m，则认为token已过期
+func (t *Token) isExpired() bool {
+	return time.Until(t.expiresAt) < 5*time.Minute
+}
+
+func newErr(url, msg string) error {
+	return errors.New(fmt.Sprintf("request url: %s, %s", url, msg))
+}
+
+// getToken 使用服务账号私钥签名的 JWT 换取 OAuth 2.0 访问 token
+func (g *GCP) getToken() (string, error) {
+	if g.token != nil && !g.token.isExpired() {
+		return g.token.token, nil
+	}
+	assertion, err := g.signJWT(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequest("POST", g.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", newErr(g.config.TokenURL, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := g.do(req)
+	if err != nil {
+		return "", err
+	}
+	token := resp.Get("access_token").MustString()
+	if token == "" {
+		return "", newErr(g.config.TokenURL, "access_token not found in response")
+	}
+	g.token = &Token{
+		token:     token,
+		expiresAt: time.Now().Add(time.Duration(resp.Get("expires_in").MustInt()) * time.Second),
+	}
+	return token, nil
+}
+
+func (g *GCP) signJWT(now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(g.config.PrivateKey))
+	if block == nil {
+		return "", errors.New("private_key of service_account_key is not in pem format")
+	}
+	var privateKey *rsa.PrivateKey
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("private_key of service_account_key is not a rsa key")
+		}
+		privateKey = rsaKey
+	} else if privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("parse private_key of service_account_key failed: %s", err.Error())
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   g.config.ClientEmail,
+		"scope": TOKEN_SCOPE,
+		"aud":   g.config.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(TOKEN_LIFETIME).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hashed := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("sign jwt failed: %s", err.Error())
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (g *GCP) get(url string) (*simplejson.Json, error) {
+	token, err := g.getToken()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return g.do(req)
+}
+
+// getRawData 获取项目中的资源列表，使用 pageToken 分页；aggregated 接口的 items 为作用域（区域或可用区）到资源列表的映射
+func (g *GCP) getRawData(path, resultKey string, aggregated bool) ([]*simplejson.Json, error) {
+	statsdAPIStartTime := time.Now()
+	baseURL := fmt.Sprintf("%s/projects/%s/%s?maxResults=%d", g.config.ComputeURL, g.config.ProjectID, path, PAGE_SIZE)
+	var jsonList []*simplejson.Json
+	var pageToken string
+	for {
+		pageURL := baseURL
+		if pageToken != "" {
+			pageURL += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		resp, err := g.get(pageURL)
+		if err != nil {
+			return nil, err
+		}
+		if aggregated {
+			jScopes := resp.Get("items")
+			var scopes []string
+			for scope := range jScopes.MustMap() {
+				scopes = append(scopes, scope)
+			}
+			sort.Strings(scopes)
+			for _, scope := range scopes {
+				jItems := jScopes.Get(scope).Get(resultKey)
+				for i := range jItems.MustArray() {
+					jsonList = append(jsonList, jItems.GetIndex(i))
+				}
+			}
+		} else {
+			jItems := resp.Get("items")
+			for i := range jItems.MustArray() {
+				jsonList = append(jsonList, jItems.GetIndex(i))
+			}
+		}
+		pageToken = resp.Get("nextPageToken").MustString()
+		if pageToken == "" {
+			break
+		}
+	}
+	g.cloudStatsd.RefreshAPIMoniter(resultKey, len(jsonList), statsdAPIStartTime)
+
+	g.debugger.WriteJson(resultKey, baseURL, jsonList)
+	return jsonList, nil
+}
+
+func (g *GCP) do(req *http.Request) (*simplejson.Json, error) {
+	url := req.URL.String()
+	log.Debugf("url: %s", url, logger.NewORGPrefix(g.orgID))
+	req.Header.Set("Accept", "application/json")
+
+	client := cloudcommon.GetUnverifyHTTPClient(time.Second * time.Duration(g.httpTimeout))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("failed: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("read failed: %s", err.Error()))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newErr(url, fmt.Sprintf("failed: status code (%d), body (%s)", resp.StatusCode, bytes.TrimSpace(respBody)))
+	}
+	jsonResp, err := simplejson.NewJson(respBody)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("JSONiz failed: %s", err.Error()))
+	}
+	return jsonResp, nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"fmt"
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.gcp")
+
+type GCP struct {
+	orgID          int
+	teamID         int
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	token          *Token
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewGCP(orgID int, domain mysqlmodel.Domain, globalCloudCfg config.CloudConfig) (*GCP, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(orgID, domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &GCP{
+		orgID:  orgID,
+		teamID: domain.TeamID,
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}, nil
+}
+
+func (g *GCP) ClearDebugLog() {
+	g.debugger.Clear()
+}
+
+// CheckAuth 获取 token 并读取项目信息，校验服务账号以及其对项目的访问权限
+func (g *GCP) CheckAuth() error {
+	_, err := g.get(fmt.Sprintf("%s/projects/%s", g.config.ComputeURL, g.config.ProjectID))
+	return err
+}
+
+func (g *GCP) GetCloudData() (model.Resource, error) {
+	g.cloudStatsd = statsd.NewCloudStatsd()
+	g.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+
+	regions, err := g.getRegions()
+	if err != nil {
+		return resource, err
+	}
+	azs, err := g.getAZs()
+	if err != nil {
+		return resource, err
+	}
+
+	networks, subnets, err := g.getNetworks()
+	if err != nil {
+		return resource, err
+	}
+	resource.Networks = networks
+	resource.Subnets = subnets
+
+	vms, vinterfaces, ips, floatingIPs, err := g.getVMs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VMs = vms
+	resource.VInterfaces = append(resource.VInterfaces, vinterfaces...)
+	resource.IPs = append(resource.IPs, ips...)
+	resource.FloatingIPs = floatingIPs
+
+	lbs, lbListeners, lbTargetServers, err := g.getLBs()
+	if err != nil {
+		return resource, err
+	}
+	resource.LBs = lbs
+	resource.LBListeners = lbListeners
+	resource.LBTargetServers = lbTargetServers
+
+	natGateways, natVInterfaces, natIPs, natVMConnections, err := g.getNATGateways()
+	if err != nil {
+		return resource, err
+	}
+	resource.NATGateways = natGateways
+	resource.NATVMConnections = natVMConnections
+	resource.VInterfaces = append(resource.VInterfaces, natVInterfaces...)
+	resource.IPs = append(resource.IPs, natIPs...)
+
+	resource.VPCs = g.getVPCs()
+	resource.PeerConnections = g.getPeerConnections()
+
+	log.Debugf("region resource num info: %v", g.toolDataSet.regionLcuuidToResourceNum, logger.NewORGPrefix(g.orgID))
+	log.Debugf("az resource num info: %v", g.toolDataSet.azLcuuidToResourceNum, logger.NewORGPrefix(g.orgID))
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, g.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(azs, g.toolDataSet.azLcuuidToResourceNum)
+
+	g.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(g)
+
+	g.debugger.Refresh()
+	return resource, nil
+}
+
+func (g *GCP) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": g.name,
+		"domain":      g.lcuuid,
+		"platform":    common.GCP_EN,
+	}
+
+	return statsd.StatsdStatter{
+		OrgID:      g.orgID,
+		TeamID:     g.teamID,
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(g.cloudStatsd),
+	}
+}
+
+// resourcePath 将资源链接（selfLink）转换为以 projects/ 开头的路径，不同版本、域名的链接指向同一资源时路径相同
+func resourcePath(link string) string {
+	if i := strings.Index(link, "projects/"); i >= 0 {
+		return link[i:]
+	}
+	return link
+}
+
+// lastSegment 返回资源链接的最后一段，即资源名称
+func lastSegment(link string) string {
+	return link[strings.LastIndex(link, "/")+1:]
+}
+
+func (g *GCP) getLcuuid(link string) string {
+	return common.GetUUIDByOrgID(g.orgID, resourcePath(link))
+}
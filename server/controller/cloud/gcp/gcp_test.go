@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+)
+
+// the responses of the compute apis, the key is the request path and the page token
+var testResponseFiles = map[string]string{
+	"/token":                              "token.json",
+	"/compute/v1/projects/proj-1":         "project.json",
+	"/compute/v1/projects/proj-1/regions": "regions.json",
+	"/compute/v1/projects/proj-1/regions?pageToken=page-2":   "regions_2.json",
+	"/compute/v1/projects/proj-1/zones":                      "zones.json",
+	"/compute/v1/projects/proj-1/global/networks":            "networks.json",
+	"/compute/v1/projects/proj-1/aggregated/subnetworks":     "subnetworks.json",
+	"/compute/v1/projects/proj-1/aggregated/instances":       "instances.json",
+	"/compute/v1/projects/proj-1/aggregated/targetPools":     "target_pools.json",
+	"/compute/v1/projects/proj-1/aggregated/forwardingRules": "forwarding_rules.json",
+	"/compute/v1/projects/proj-1/aggregated/addresses":       "addresses.json",
+	"/compute/v1/projects/proj-1/aggregated/routers":         "routers.json",
+}
+
+// verifyAssertion checks the RS256 signature of the jwt assertion with the public key of the service account
+func verifyAssertion(publicKey *rsa.PublicKey, assertion string) bool {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signature) == nil
+}
+
+func newTestServer(publicKey *rsa.PublicKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if !verifyAssertion(publicKey, r.FormValue("assertion")) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		} else if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		key := r.URL.Path
+		if pageToken := r.URL.Query().Get("pageToken"); pageToken != "" {
+			key += "?pageToken=" + pageToken
+		}
+		file, ok := testResponseFiles[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile("testfiles/" + file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func newTestGCP(url string, privateKey *rsa.PrivateKey) *GCP {
+	keyBytes, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+	return &GCP{
+		orgID:          mysqlcommon.DEFAULT_ORG_ID,
+		lcuuidGenerate: "test_gcp",
+		name:           "test_gcp",
+		httpTimeout:    30,
+		config: &Config{
+			ProjectID:      "proj-1",
+			ClientEmail:    "deepflow@proj-1.iam.gserviceaccount.com",
+			PrivateKey:     string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})),
+			TokenURL:       url + "/token",
+			ComputeURL:     url + "/compute/v1",
+			IncludeRegions: map[string]bool{"us-central1": false, "europe-west1": false},
+		},
+		debugger: cloudcommon.NewDebugger("test_gcp"),
+	}
+}
+
+func TestGCP(t *testing.T) {
+	Convey("TestGCP", t, func() {
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		server := newTestServer(&privateKey.PublicKey)
+		defer server.Close()
+
+		Convey("check auth with wrong private key should fail", func() {
+			wrongKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			So(err, ShouldBeNil)
+			So(newTestGCP(server.URL, wrongKey).CheckAuth(), ShouldNotBeNil)
+			So(newTestGCP(server.URL, privateKey).CheckAuth(), ShouldBeNil)
+		})
+
+		gcp := newTestGCP(server.URL, privateKey)
+		data, err := gcp.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("gcpResource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 2)
+			So(len(data.AZs), ShouldEqual, 3)
+			So(len(data.VPCs), ShouldEqual, 3)
+			So(len(data.Networks), ShouldEqual, 3)
+			So(len(data.Subnets), ShouldEqual, 3)
+			So(len(data.PeerConnections), ShouldEqual, 1)
+			So(len(data.VMs), ShouldEqual, 3)
+			So(len(data.VInterfaces), ShouldEqual, 6)
+			So(len(data.IPs), ShouldEqual, 6)
+			So(len(data.FloatingIPs), ShouldEqual, 1)
+			So(len(data.LBs), ShouldEqual, 1)
+			So(len(data.LBListeners), ShouldEqual, 1)
+			So(len(data.LBTargetServers), ShouldEqual, 2)
+			So(len(data.NATGateways), ShouldEqual, 1)
+			So(len(data.NATVMConnections), ShouldEqual, 2)
+		})
+
+		Convey("vpc network should be split by region", func() {
+			So(data.VMs[0].Name, ShouldEqual, "vm3")
+			So(data.VMs[1].Name, ShouldEqual, "vm1")
+			So(data.VMs[0].VPCLcuuid, ShouldNotEqual, data.VMs[1].VPCLcuuid)
+			So(data.VMs[1].VPCLcuuid, ShouldEqual, data.VMs[2].VPCLcuuid)
+			So(data.Subnets[0].GatewayIP, ShouldEqual, "10.132.0.1")
+		})
+
+		Convey("vm should use the ip of its nic0", func() {
+			So(data.VMs[1].IP, ShouldEqual, "10.128.0.2")
+			So(data.VMs[1].Hostname, ShouldEqual, "vm1")
+			So(data.VMs[1].State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(data.VMs[1].CloudTags, ShouldResemble, map[string]string{"env": "prod"})
+			So(data.VMs[2].Hostname, ShouldEqual, "vm2.example.internal")
+			So(data.VMs[2].State, ShouldEqual, common.VM_STATE_STOPPED)
+			So(data.VInterfaces[1].Mac, ShouldEqual, "42:01:0a:80:00:02")
+			So(data.FloatingIPs[0].IP, ShouldEqual, "34.1.1.1")
+		})
+
+		Convey("external lb should be in the vpc of its target pool", func() {
+			So(data.LBs[0].Model, ShouldEqual, cloudcommon.LB_MODEL_EXTERNAL)
+			So(data.LBs[0].VIP, ShouldEqual, "35.2.2.2")
+			So(data.LBs[0].VPCLcuuid, ShouldEqual, data.VMs[1].VPCLcuuid)
+			So(data.LBListeners[0].Port, ShouldEqual, 80)
+			So(data.LBTargetServers[1].VMLcuuid, ShouldEqual, data.VMs[2].Lcuuid)
+			So(data.NATGateways[0].FloatingIPs, ShouldEqual, "34.3.3.3")
+		})
+	})
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"strconv"
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getLBs 区域级转发规则（forwarding rule）对应负载均衡器及其监听器，转发目标为目标池（target pool）时，
+// 目标池中的虚拟机为后端主机；全局转发规则没有所属区域，忽略
+func (g *GCP) getLBs() ([]model.LB, []model.LBListener, []model.LBTargetServer, error) {
+	jTargetPools, err := g.getRawData("aggregated/targetPools", "targetPools", true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	targetPoolPathToInstancePaths := make(map[string][]string)
+	for _, jTargetPool := range jTargetPools {
+		path := resourcePath(jTargetPool.Get("selfLink").MustString())
+		jInstances := jTargetPool.Get("instances")
+		for i := range jInstances.MustArray() {
+			targetPoolPathToInstancePaths[path] = append(targetPoolPathToInstancePaths[path], resourcePath(jInstances.GetIndex(i).MustString()))
+		}
+	}
+
+	jForwardingRules, err := g.getRawData("aggregated/forwardingRules", "forwardingRules", true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var lbs []model.LB
+	var lbListeners []model.LBListener
+	var lbTargetServers []model.LBTargetServer
+	for _, jRule := range jForwardingRules {
+		path := resourcePath(jRule.Get("selfLink").MustString())
+		if !cloudcommon.CheckJsonAttributes(jRule, []string{"name", "selfLink", "region", "IPAddress"}) {
+			log.Infof("exclude lb: %s, missing attr", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		regionName := lastSegment(jRule.Get("region").MustString())
+		regionLcuuid, ok := g.toolDataSet.regionNameToLcuuid[regionName]
+		if !ok {
+			log.Infof("exclude lb: %s, region not included", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+
+		// 内部转发规则的 VPC 为其所在 VPC 网络对应的 VPC，外部转发规则的 VPC 为后端虚拟机所在的 VPC
+		var targetVMs []model.VM
+		for _, instancePath := range targetPoolPathToInstancePaths[resourcePath(jRule.Get("target").MustString())] {
+			if vm, ok := g.toolDataSet.instancePathToVM[instancePath]; ok {
+				targetVMs = append(targetVMs, vm)
+			}
+		}
+		lbModel := cloudcommon.LB_MODEL_INTERNAL
+		var vpcLcuuid string
+		if strings.HasPrefix(jRule.Get("loadBalancingScheme").MustString(), "EXTERNAL") {
+			lbModel = cloudcommon.LB_MODEL_EXTERNAL
+		}
+		if networkPath := resourcePath(jRule.Get("network").MustString()); networkPath != "" {
+			vpcLcuuid = g.toolDataSet.vpcs[networkPath+"_"+regionName].Lcuuid
+		}
+		if vpcLcuuid == "" && len(targetVMs) > 0 {
+			vpcLcuuid = targetVMs[0].VPCLcuuid
+		}
+		if vpcLcuuid == "" {
+			log.Infof("exclude lb: %s, vpc not found", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+
+		vip := jRule.Get("IPAddress").MustString()
+		lb := model.LB{
+			Lcuuid:       g.getLcuuid(path),
+			Name:         jRule.Get("name").MustString(),
+			Label:        path,
+			Model:        lbModel,
+			VIP:          vip,
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		}
+		lbs = append(lbs, lb)
+		g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+		protocol := strings.ToUpper(jRule.Get("IPProtocol").MustString())
+		port := getPort(jRule.Get("portRange").MustString())
+		if port == 0 {
+			port = getPort(jRule.Get("ports").GetIndex(0).MustString())
+		}
+		listener := model.LBListener{
+			Lcuuid:   common.GetUUIDByOrgID(g.orgID, lb.Lcuuid),
+			LBLcuuid: lb.Lcuuid,
+			Name:     lb.Name,
+			Label:    path,
+			IPs:      vip,
+			Protocol: protocol,
+			Port:     port,
+		}
+		lbListeners = append(lbListeners, listener)
+
+		for _, vm := range targetVMs {
+			lbTargetServers = append(lbTargetServers, model.LBTargetServer{
+				Lcuuid:           common.GetUUIDByOrgID(g.orgID, listener.Lcuuid+vm.Lcuuid),
+				LBLcuuid:         lb.Lcuuid,
+				LBListenerLcuuid: listener.Lcuuid,
+				Type:             common.LB_SERVER_TYPE_VM,
+				VMLcuuid:         vm.Lcuuid,
+				IP:               vm.IP,
+				Protocol:         protocol,
+				Port:             port,
+				VPCLcuuid:        vm.VPCLcuuid,
+			})
+		}
+	}
+	return lbs, lbListeners, lbTargetServers, nil
+}
+
+// getPort 返回端口范围（例如 80-80）的起始端口，未指定端口时返回 0
+func getPort(portRange string) int {
+	port, _ := strconv.Atoi(strings.SplitN(portRange, "-", 2)[0])
+	return port
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"sort"
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const NAT_ALL_SUBNETWORKS_PREFIX = "ALL_SUBNETWORKS"
+
+// getNATGateways Cloud Router 中的每个 Cloud NAT 对应一个 NAT 网关，NAT 覆盖子网中的虚拟机通过该网关访问公网；
+// 自动分配的 NAT IP 不在地址列表中，无法获取
+func (g *GCP) getNATGateways() ([]model.NATGateway, []model.VInterface, []model.IP, []model.NATVMConnection, error) {
+	jAddresses, err := g.getRawData("aggregated/addresses", "addresses", true)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	for _, jAddress := range jAddresses {
+		path := resourcePath(jAddress.Get("selfLink").MustString())
+		if ip := jAddress.Get("address").MustString(); path != "" && ip != "" {
+			g.toolDataSet.addressPathToIP[path] = ip
+		}
+	}
+
+	jRouters, err := g.getRawData("aggregated/routers", "routers", true)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var vmLcuuids []string
+	for vmLcuuid := range g.toolDataSet.vmLcuuidToSubnetPaths {
+		vmLcuuids = append(vmLcuuids, vmLcuuid)
+	}
+	sort.Strings(vmLcuuids)
+
+	var natGateways []model.NATGateway
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var natVMConnections []model.NATVMConnection
+	for _, jRouter := range jRouters {
+		path := resourcePath(jRouter.Get("selfLink").MustString())
+		if !cloudcommon.CheckJsonAttributes(jRouter, []string{"name", "selfLink", "region", "network"}) {
+			log.Infof("exclude nat_gateway: %s, missing attr", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		regionName := lastSegment(jRouter.Get("region").MustString())
+		regionLcuuid, ok := g.toolDataSet.regionNameToLcuuid[regionName]
+		if !ok {
+			log.Infof("exclude nat_gateway: %s, region not included", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		networkPath := resourcePath(jRouter.Get("network").MustString())
+		networkRegion := networkPath + "_" + regionName
+		if _, ok := g.toolDataSet.vpcs[networkRegion]; !ok {
+			log.Infof("exclude nat_gateway: %s, vpc not found", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		vpcLcuuid := g.getVPCLcuuid(networkPath, regionName)
+
+		jNATs := jRouter.Get("nats")
+		for i := range jNATs.MustArray() {
+			jNAT := jNATs.GetIndex(i)
+			name := jNAT.Get("name").MustString()
+			var floatingIPs []string
+			jNATIPs := jNAT.Get("natIps")
+			for j := range jNATIPs.MustArray() {
+				if ip, ok := g.toolDataSet.addressPathToIP[resourcePath(jNATIPs.GetIndex(j).MustString())]; ok {
+					floatingIPs = append(floatingIPs, ip)
+				}
+			}
+			subnetPaths := make(map[string]bool)
+			if strings.HasPrefix(jNAT.Get("sourceSubnetworkIpRangesToNat").MustString(), NAT_ALL_SUBNETWORKS_PREFIX) {
+				for _, subnetPath := range g.toolDataSet.networkRegionToSubnets[networkRegion] {
+					subnetPaths[subnetPath] = true
+				}
+			} else {
+				jSubnetworks := jNAT.Get("subnetworks")
+				for j := range jSubnetworks.MustArray() {
+					subnetPaths[resourcePath(jSubnetworks.GetIndex(j).Get("name").MustString())] = true
+				}
+			}
+
+			natGateway := model.NATGateway{
+				Lcuuid:       common.GetUUIDByOrgID(g.orgID, path+"/nats/"+name),
+				Name:         name,
+				Label:        path + "/nats/" + name,
+				FloatingIPs:  strings.Join(floatingIPs, ","),
+				VPCLcuuid:    vpcLcuuid,
+				RegionLcuuid: regionLcuuid,
+			}
+			natGateways = append(natGateways, natGateway)
+			g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+			vinterfaceLcuuid := common.GetUUIDByOrgID(g.orgID, natGateway.Lcuuid)
+			vinterfaces = append(vinterfaces, model.VInterface{
+				Lcuuid:        vinterfaceLcuuid,
+				Type:          common.VIF_TYPE_WAN,
+				Mac:           common.VIF_DEFAULT_MAC,
+				DeviceLcuuid:  natGateway.Lcuuid,
+				DeviceType:    common.VIF_DEVICE_TYPE_NAT_GATEWAY,
+				NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+				VPCLcuuid:     vpcLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			})
+			for _, ip := range floatingIPs {
+				ips = append(ips, model.IP{
+					Lcuuid:           common.GetUUIDByOrgID(g.orgID, vinterfaceLcuuid+ip),
+					VInterfaceLcuuid: vinterfaceLcuuid,
+					IP:               ip,
+					RegionLcuuid:     regionLcuuid,
+				})
+			}
+
+			for _, vmLcuuid := range vmLcuuids {
+				for _, subnetPath := range g.toolDataSet.vmLcuuidToSubnetPaths[vmLcuuid] {
+					if !subnetPaths[subnetPath] {
+						continue
+					}
+					natVMConnections = append(natVMConnections, model.NATVMConnection{
+						Lcuuid:           common.GetUUIDByOrgID(g.orgID, natGateway.Lcuuid+vmLcuuid),
+						NATGatewayLcuuid: natGateway.Lcuuid,
+						VMLcuuid:         vmLcuuid,
+					})
+					break
+				}
+			}
+		}
+	}
+	return natGateways, vinterfaces, ips, natVMConnections, nil
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getRegions 获取项目可用的区域
+func (g *GCP) getRegions() ([]model.Region, error) {
+	jRegions, err := g.getRawData("regions", "regions", false)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []model.Region
+	for _, jRegion := range jRegions {
+		if !cloudcommon.CheckJsonAttributes(jRegion, []string{"name"}) {
+			continue
+		}
+		name := jRegion.Get("name").MustString()
+		// 区域白名单，如果当前区域不在白名单中，则跳过
+		if _, ok := g.config.IncludeRegions[name]; !ok {
+			log.Infof("region (%s) not in include_regions", name, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		// 区域黑名单，如果当前区域在黑名单中，则跳过
+		if _, ok := g.config.ExcludeRegions[name]; ok {
+			log.Infof("region (%s) in exclude_regions", name, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		if g.config.RegionLcuuid != "" {
+			g.toolDataSet.regionNameToLcuuid[name] = g.config.RegionLcuuid
+			continue
+		}
+		region := model.Region{
+			Lcuuid: common.GenerateUUIDByOrgID(g.orgID, name+"_"+g.lcuuidGenerate),
+			Label:  name,
+			Name:   name,
+		}
+		regions = append(regions, region)
+		g.toolDataSet.regionNameToLcuuid[name] = region.Lcuuid
+	}
+	return regions, nil
+}
+
+// getAZs 可用区（zone）对应可用区，只保留属于同步区域的可用区
+func (g *GCP) getAZs() ([]model.AZ, error) {
+	jZones, err := g.getRawData("zones", "zones", false)
+	if err != nil {
+		return nil, err
+	}
+
+	var azs []model.AZ
+	for _, jZone := range jZones {
+		if !cloudcommon.CheckJsonAttributes(jZone, []string{"name", "region"}) {
+			continue
+		}
+		name := jZone.Get("name").MustString()
+		regionName := lastSegment(jZone.Get("region").MustString())
+		regionLcuuid, ok := g.toolDataSet.regionNameToLcuuid[regionName]
+		if !ok {
+			log.Debugf("exclude az: %s, region not included", name, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		az := model.AZ{
+			Lcuuid:       common.GenerateUUIDByOrgID(g.orgID, name+"_"+g.lcuuidGenerate),
+			Label:        name,
+			Name:         name,
+			RegionLcuuid: regionLcuuid,
+		}
+		azs = append(azs, az)
+		g.toolDataSet.zoneNameToAZLcuuid[name] = az.Lcuuid
+		g.toolDataSet.zoneNameToRegionName[name] = regionName
+	}
+	return azs, nil
+}
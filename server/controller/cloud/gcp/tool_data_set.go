@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+)
+
+type ToolDataSet struct {
+	regionNameToLcuuid        map[string]string
+	zoneNameToAZLcuuid        map[string]string
+	zoneNameToRegionName      map[string]string
+	networkPathToName         map[string]string
+	networkPathToPeerPaths    map[string][]string
+	vpcs                      map[string]model.VPC
+	subnetPathToNetwork       map[string]model.Network
+	networkRegionToSubnets    map[string][]string
+	instancePathToVM          map[string]model.VM
+	vmLcuuidToSubnetPaths     map[string][]string
+	addressPathToIP           map[string]string
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		regionNameToLcuuid:        make(map[string]string),
+		zoneNameToAZLcuuid:        make(map[string]string),
+		zoneNameToRegionName:      make(map[string]string),
+		networkPathToName:         make(map[string]string),
+		networkPathToPeerPaths:    make(map[string][]string),
+		vpcs:                      make(map[string]model.VPC),
+		subnetPathToNetwork:       make(map[string]model.Network),
+		networkRegionToSubnets:    make(map[string][]string),
+		instancePathToVM:          make(map[string]model.VM),
+		vmLcuuidToSubnetPaths:     make(map[string][]string),
+		addressPathToIP:           make(map[string]string),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var STATE_CONVERTION = map[string]int{
+	"RUNNING":    common.VM_STATE_RUNNING,
+	"STOPPING":   common.VM_STATE_STOPPED,
+	"TERMINATED": common.VM_STATE_STOPPED,
+	"SUSPENDING": common.VM_STATE_STOPPED,
+	"SUSPENDED":  common.VM_STATE_STOPPED,
+}
+
+// getVMs 获取虚拟机及其网卡，虚拟机的 VPC 为主网卡（nic0）所在 VPC 网络在虚拟机区域中对应的 VPC，
+// 网卡的外部 IP（accessConfigs 中的 natIP）生成 WAN 网卡和浮动 IP
+func (g *GCP) getVMs() ([]model.VM, []model.VInterface, []model.IP, []model.FloatingIP, error) {
+	jInstances, err := g.getRawData("aggregated/instances", "instances", true)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var vms []model.VM
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	var floatingIPs []model.FloatingIP
+	for _, jInstance := range jInstances {
+		path := resourcePath(jInstance.Get("selfLink").MustString())
+		if !cloudcommon.CheckJsonAttributes(jInstance, []string{"name", "selfLink", "zone", "networkInterfaces"}) {
+			log.Infof("exclude vm: %s, missing attr", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		zoneName := lastSegment(jInstance.Get("zone").MustString())
+		azLcuuid, ok := g.toolDataSet.zoneNameToAZLcuuid[zoneName]
+		if !ok {
+			log.Infof("exclude vm: %s, region not included", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		regionName := g.toolDataSet.zoneNameToRegionName[zoneName]
+		regionLcuuid := g.toolDataSet.regionNameToLcuuid[regionName]
+
+		vmLcuuid := g.getLcuuid(path)
+		var vmIP, vpcLcuuid string
+		var vmVInterfaces []model.VInterface
+		var vmIPs []model.IP
+		var vmFloatingIPs []model.FloatingIP
+		jNICs := jInstance.Get("networkInterfaces")
+		for i := range jNICs.MustArray() {
+			jNIC := jNICs.GetIndex(i)
+			subnetPath := resourcePath(jNIC.Get("subnetwork").MustString())
+			network, ok := g.toolDataSet.subnetPathToNetwork[subnetPath]
+			privateIP := jNIC.Get("networkIP").MustString()
+			if !ok || privateIP == "" {
+				log.Infof("exclude vinterface: %s/%s, subnet not found", path, jNIC.Get("name").MustString(), logger.NewORGPrefix(g.orgID))
+				continue
+			}
+			if vpcLcuuid == "" {
+				vmIP = privateIP
+				vpcLcuuid = network.VPCLcuuid
+			}
+			mac := generateMac(privateIP)
+			vinterface := model.VInterface{
+				Lcuuid:        common.GetUUIDByOrgID(g.orgID, vmLcuuid+jNIC.Get("name").MustString()),
+				Name:          jNIC.Get("name").MustString(),
+				Type:          common.VIF_TYPE_LAN,
+				Mac:           mac,
+				DeviceLcuuid:  vmLcuuid,
+				DeviceType:    common.VIF_DEVICE_TYPE_VM,
+				NetworkLcuuid: network.Lcuuid,
+				VPCLcuuid:     network.VPCLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			}
+			vmVInterfaces = append(vmVInterfaces, vinterface)
+			vmIPs = append(vmIPs, model.IP{
+				Lcuuid:           common.GetUUIDByOrgID(g.orgID, vinterface.Lcuuid+privateIP),
+				VInterfaceLcuuid: vinterface.Lcuuid,
+				IP:               privateIP,
+				SubnetLcuuid:     common.GetUUIDByOrgID(g.orgID, network.Lcuuid),
+				RegionLcuuid:     regionLcuuid,
+			})
+			g.toolDataSet.vmLcuuidToSubnetPaths[vmLcuuid] = append(g.toolDataSet.vmLcuuidToSubnetPaths[vmLcuuid], subnetPath)
+
+			jAccessConfigs := jNIC.Get("accessConfigs")
+			for j := range jAccessConfigs.MustArray() {
+				publicIP := jAccessConfigs.GetIndex(j).Get("natIP").MustString()
+				if publicIP == "" {
+					continue
+				}
+				wanVInterface := model.VInterface{
+					Lcuuid:        common.GetUUIDByOrgID(g.orgID, vinterface.Lcuuid),
+					Type:          common.VIF_TYPE_WAN,
+					Mac:           cloudcommon.GenerateWANVInterfaceMac(mac),
+					DeviceLcuuid:  vmLcuuid,
+					DeviceType:    common.VIF_DEVICE_TYPE_VM,
+					NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+					VPCLcuuid:     network.VPCLcuuid,
+					RegionLcuuid:  regionLcuuid,
+				}
+				vmVInterfaces = append(vmVInterfaces, wanVInterface)
+				vmIPs = append(vmIPs, model.IP{
+					Lcuuid:           common.GetUUIDByOrgID(g.orgID, wanVInterface.Lcuuid+publicIP),
+					VInterfaceLcuuid: wanVInterface.Lcuuid,
+					IP:               publicIP,
+					RegionLcuuid:     regionLcuuid,
+				})
+				vmFloatingIPs = append(vmFloatingIPs, model.FloatingIP{
+					Lcuuid:        common.GetUUIDByOrgID(g.orgID, vinterface.Lcuuid+publicIP),
+					IP:            publicIP,
+					VMLcuuid:      vmLcuuid,
+					NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+					VPCLcuuid:     network.VPCLcuuid,
+					RegionLcuuid:  regionLcuuid,
+				})
+				// 每个网卡最多只有一个外部 IP
+				break
+			}
+		}
+		if vpcLcuuid == "" {
+			log.Infof("exclude vm: %s, vpc not found", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+
+		state, ok := STATE_CONVERTION[jInstance.Get("status").MustString()]
+		if !ok {
+			state = common.VM_STATE_EXCEPTION
+		}
+		createdAt, _ := time.Parse(time.RFC3339Nano, jInstance.Get("creationTimestamp").MustString())
+		cloudTags := make(map[string]string)
+		for key, value := range jInstance.Get("labels").MustMap() {
+			cloudTags[key] = fmt.Sprint(value)
+		}
+		hostname := jInstance.Get("hostname").MustString()
+		if hostname == "" {
+			hostname = jInstance.Get("name").MustString()
+		}
+		vm := model.VM{
+			Lcuuid:       vmLcuuid,
+			Name:         jInstance.Get("name").MustString(),
+			Label:        path,
+			IP:           vmIP,
+			Hostname:     hostname,
+			HType:        common.VM_HTYPE_VM_C,
+			State:        state,
+			CreatedAt:    createdAt,
+			VPCLcuuid:    vpcLcuuid,
+			AZLcuuid:     azLcuuid,
+			RegionLcuuid: regionLcuuid,
+			CloudTags:    cloudTags,
+		}
+		vms = append(vms, vm)
+		vinterfaces = append(vinterfaces, vmVInterfaces...)
+		ips = append(ips, vmIPs...)
+		floatingIPs = append(floatingIPs, vmFloatingIPs...)
+		g.toolDataSet.instancePathToVM[path] = vm
+		g.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+		g.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+	return vms, vinterfaces, ips, floatingIPs, nil
+}
+
+// generateMac 接口不返回网卡的 MAC 地址，GCP 按网卡的主内网 IP 生成 MAC 地址：42:01 加上 IP 的 4 个字节
+func generateMac(ip string) string {
+	ipv4 := net.ParseIP(ip).To4()
+	if ipv4 == nil {
+		return common.VIF_DEFAULT_MAC
+	}
+	return fmt.Sprintf("42:01:%02x:%02x:%02x:%02x", ipv4[0], ipv4[1], ipv4[2], ipv4[3])
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"sort"
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const PEERING_STATE_ACTIVE = "ACTIVE"
+
+// getNetworks VPC 网络是全局资源，每个区域中的子网（subnetwork）对应一个网络和一个子网，
+// VPC 网络在每个有子网的区域对应一个 VPC
+func (g *GCP) getNetworks() ([]model.Network, []model.Subnet, error) {
+	jVPCNetworks, err := g.getRawData("global/networks", "networks", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, jVPCNetwork := range jVPCNetworks {
+		if !cloudcommon.CheckJsonAttributes(jVPCNetwork, []string{"name", "selfLink"}) {
+			continue
+		}
+		path := resourcePath(jVPCNetwork.Get("selfLink").MustString())
+		g.toolDataSet.networkPathToName[path] = jVPCNetwork.Get("name").MustString()
+		jPeerings := jVPCNetwork.Get("peerings")
+		for i := range jPeerings.MustArray() {
+			jPeering := jPeerings.GetIndex(i)
+			if jPeering.Get("state").MustString() != PEERING_STATE_ACTIVE {
+				log.Infof("exclude peer connection: %s, not active", jPeering.Get("name").MustString(), logger.NewORGPrefix(g.orgID))
+				continue
+			}
+			g.toolDataSet.networkPathToPeerPaths[path] = append(g.toolDataSet.networkPathToPeerPaths[path], resourcePath(jPeering.Get("network").MustString()))
+		}
+	}
+
+	jSubnetworks, err := g.getRawData("aggregated/subnetworks", "subnetworks", true)
+	if err != nil {
+		return nil, nil, err
+	}
+	var networks []model.Network
+	var subnets []model.Subnet
+	for _, jSubnetwork := range jSubnetworks {
+		path := resourcePath(jSubnetwork.Get("selfLink").MustString())
+		if !cloudcommon.CheckJsonAttributes(jSubnetwork, []string{"name", "selfLink", "network", "region", "ipCidrRange"}) {
+			log.Infof("exclude subnet: %s, missing attr", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		regionName := lastSegment(jSubnetwork.Get("region").MustString())
+		regionLcuuid, ok := g.toolDataSet.regionNameToLcuuid[regionName]
+		if !ok {
+			log.Infof("exclude subnet: %s, region not included", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		networkPath := resourcePath(jSubnetwork.Get("network").MustString())
+		if _, ok := g.toolDataSet.networkPathToName[networkPath]; !ok {
+			log.Infof("exclude subnet: %s, vpc not found", path, logger.NewORGPrefix(g.orgID))
+			continue
+		}
+		vpcLcuuid := g.getVPCLcuuid(networkPath, regionName)
+		network := model.Network{
+			Lcuuid:       g.getLcuuid(path),
+			Name:         jSubnetwork.Get("name").MustString(),
+			Label:        path,
+			NetType:      common.NETWORK_TYPE_LAN,
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		}
+		networks = append(networks, network)
+		subnets = append(subnets, model.Subnet{
+			Lcuuid:        common.GetUUIDByOrgID(g.orgID, network.Lcuuid),
+			Name:          network.Name,
+			Label:         path,
+			CIDR:          jSubnetwork.Get("ipCidrRange").MustString(),
+			GatewayIP:     jSubnetwork.Get("gatewayAddress").MustString(),
+			NetworkLcuuid: network.Lcuuid,
+			VPCLcuuid:     vpcLcuuid,
+		})
+		g.toolDataSet.subnetPathToNetwork[path] = network
+		key := networkPath + "_" + regionName
+		g.toolDataSet.networkRegionToSubnets[key] = append(g.toolDataSet.networkRegionToSubnets[key], path)
+	}
+	return networks, subnets, nil
+}
+
+// getVPCLcuuid 返回 VPC 网络在区域中对应的 VPC，首次使用时创建
+func (g *GCP) getVPCLcuuid(networkPath, regionName string) string {
+	key := networkPath + "_" + regionName
+	if vpc, ok := g.toolDataSet.vpcs[key]; ok {
+		return vpc.Lcuuid
+	}
+	vpc := model.VPC{
+		Lcuuid:       common.GetUUIDByOrgID(g.orgID, key),
+		Name:         g.toolDataSet.networkPathToName[networkPath],
+		Label:        networkPath,
+		RegionLcuuid: g.toolDataSet.regionNameToLcuuid[regionName],
+	}
+	g.toolDataSet.vpcs[key] = vpc
+	return vpc.Lcuuid
+}
+
+func (g *GCP) getVPCs() []model.VPC {
+	var vpcs []model.VPC
+	for _, vpc := range g.toolDataSet.vpcs {
+		vpcs = append(vpcs, vpc)
+	}
+	sort.Slice(vpcs, func(i, j int) bool { return vpcs[i].Lcuuid < vpcs[j].Lcuuid })
+	return vpcs
+}
+
+// getPeerConnections 对等连接在两端的 VPC 网络中各有一条记录，两端网络在同一区域中的 VPC 之间生成对等连接，按两端 VPC 去重
+func (g *GCP) getPeerConnections() []model.PeerConnection {
+	var keys []string
+	for key := range g.toolDataSet.vpcs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var peerConnections []model.PeerConnection
+	pairs := make(map[string]bool)
+	for _, key := range keys {
+		localVPC := g.toolDataSet.vpcs[key]
+		regionName := key[strings.LastIndex(key, "_")+1:]
+		for _, peerPath := range g.toolDataSet.networkPathToPeerPaths[localVPC.Label] {
+			remoteVPC, ok := g.toolDataSet.vpcs[peerPath+"_"+regionName]
+			if !ok {
+				continue
+			}
+			pair := []string{localVPC.Lcuuid, remoteVPC.Lcuuid}
+			sort.Strings(pair)
+			pairKey := strings.Join(pair, "_")
+			if pairs[pairKey] {
+				continue
+			}
+			pairs[pairKey] = true
+			peerConnections = append(peerConnections, model.PeerConnection{
+				Lcuuid:             common.GetUUIDByOrgID(g.orgID, pairKey),
+				Name:               localVPC.Name + "-" + remoteVPC.Name,
+				Label:              localVPC.Label + "," + remoteVPC.Label,
+				LocalVPCLcuuid:     localVPC.Lcuuid,
+				RemoteVPCLcuuid:    remoteVPC.Lcuuid,
+				LocalRegionLcuuid:  localVPC.RegionLcuuid,
+				RemoteRegionLcuuid: remoteVPC.RegionLcuuid,
+			})
+		}
+	}
+	return peerConnections
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// internal 可用区中是控制节点的服务，不作为资源的可用区
+const INTERNAL_AZ_NAME = "internal"
+
+func (o *OpenStack) getAZs(region string) ([]model.AZ, error) {
+	endpoint, err := o.getRegionEndpoint(region, SERVICE_TYPE_COMPUTE)
+	if err != nil {
+		log.Infof("exclude azs: %s", err.Error(), logger.NewORGPrefix(o.orgID))
+		return nil, nil
+	}
+	jAZs, err := o.getRawData(endpoint+"/os-availability-zone/detail", "availabilityZoneInfo", false)
+	if err != nil {
+		return nil, err
+	}
+
+	var azs []model.AZ
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jAZ := range jAZs {
+		name := jAZ.Get("zoneName").MustString()
+		if !cloudcommon.CheckJsonAttributes(jAZ, []string{"zoneName"}) || name == INTERNAL_AZ_NAME {
+			log.Infof("exclude az: %s", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		lcuuid := common.GenerateUUIDByOrgID(o.orgID, region+"_"+name+"_"+o.lcuuidGenerate)
+		azs = append(azs, model.AZ{
+			Lcuuid:       lcuuid,
+			Label:        name,
+			Name:         name,
+			RegionLcuuid: regionLcuuid,
+		})
+		o.toolDataSet.azNameToLcuuid[o.regionKey(region, name)] = lcuuid
+		for hostName := range jAZ.Get("hosts").MustMap() {
+			o.toolDataSet.hostNameToAZName[o.regionKey(region, hostName)] = name
+		}
+	}
+	return azs, nil
+}
+
+func (o *OpenStack) regionKey(region, name string) string {
+	return strings.Join([]string{region, name}, "/")
+}
+
+func (o *OpenStack) getAZLcuuid(region, azName string) string {
+	return o.toolDataSet.azNameToLcuuid[o.regionKey(region, azName)]
+}
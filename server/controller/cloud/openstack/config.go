@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	DEFAULT_DOMAIN_NAME        = "Default"
+	DEFAULT_ENDPOINT_INTERFACE = "public"
+)
+
+type Config struct {
+	RegionLcuuid      string
+	AuthURL           string // keystone v3 url, e.g. http://keystone:5000/v3
+	UserName          string
+	Password          string
+	UserDomainName    string
+	ProjectName       string
+	ProjectDomainName string
+	EndpointInterface string // public, internal or admin, the interface of the endpoints in the service catalog
+	IncludeRegions    map[string]bool
+	ExcludeRegions    map[string]bool
+}
+
+func (c *Config) LoadFromString(orgID int, sConf string) (err error) {
+	jConf, err := simplejson.NewJson([]byte(sConf))
+	if err != nil {
+		log.Errorf("convert config string: %s to json failed: %v", sConf, err, logger.NewORGPrefix(orgID))
+		return
+	}
+	c.AuthURL, err = jConf.Get("url").String()
+	if err != nil {
+		log.Error("url must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.AuthURL = strings.TrimSuffix(c.AuthURL, "/")
+	if !strings.HasSuffix(c.AuthURL, "/v3") {
+		c.AuthURL += "/v3"
+	}
+	c.UserName, err = jConf.Get("username").String()
+	if err != nil {
+		log.Error("username must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	pswd, err := jConf.Get("password").String()
+	if err != nil {
+		log.Error("password must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	dpswd, err := common.DecryptSecretKey(pswd)
+	if err != nil {
+		log.Error("decrypt password failed", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.Password = dpswd
+	c.ProjectName, err = jConf.Get("project_name").String()
+	if err != nil {
+		log.Error("project_name must be specified", logger.NewORGPrefix(orgID))
+		return
+	}
+	c.UserDomainName = jConf.Get("user_domain_name").MustString()
+	if c.UserDomainName == "" {
+		c.UserDomainName = DEFAULT_DOMAIN_NAME
+	}
+	c.ProjectDomainName = jConf.Get("project_domain_name").MustString()
+	if c.ProjectDomainName == "" {
+		c.ProjectDomainName = DEFAULT_DOMAIN_NAME
+	}
+	c.EndpointInterface = jConf.Get("endpoint_type").MustString()
+	if c.EndpointInterface == "" {
+		c.EndpointInterface = DEFAULT_ENDPOINT_INTERFACE
+	}
+	c.RegionLcuuid = jConf.Get("region_uuid").MustString()
+	c.IncludeRegions = cloudcommon.UniqRegions(jConf.Get("include_regions").MustString())
+	c.ExcludeRegions = cloudcommon.UniqRegions(jConf.Get("exclude_regions").MustString())
+	return
+}
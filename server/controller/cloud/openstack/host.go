@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var HYPERVISOR_TYPE_TO_HTYPE = map[string]int{
+	"qemu":    common.HOST_HTYPE_KVM,
+	"kvm":     common.HOST_HTYPE_KVM,
+	"vmware":  common.HOST_HTYPE_ESXI,
+	"hyperv":  common.HOST_HTYPE_HYPER_V,
+	"hyper-v": common.HOST_HTYPE_HYPER_V,
+}
+
+// getHosts 通过 hypervisor 接口获取宿主机，需要管理员权限，无权限时不同步宿主机
+func (o *OpenStack) getHosts(region string) ([]model.Host, error) {
+	endpoint, err := o.getRegionEndpoint(region, SERVICE_TYPE_COMPUTE)
+	if err != nil {
+		return nil, nil
+	}
+	jHypervisors, err := o.getRawData(endpoint+"/os-hypervisors/detail", "hypervisors", false)
+	if err != nil {
+		log.Warningf("exclude hosts of region (%s): %s", region, err.Error(), logger.NewORGPrefix(o.orgID))
+		return nil, nil
+	}
+
+	var hosts []model.Host
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jHypervisor := range jHypervisors {
+		name := jHypervisor.Get("hypervisor_hostname").MustString()
+		if !cloudcommon.CheckJsonAttributes(jHypervisor, []string{"hypervisor_hostname", "host_ip"}) {
+			log.Infof("exclude host: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		ip := jHypervisor.Get("host_ip").MustString()
+		// 可用区中使用的是计算服务的 host 名称
+		serviceHost := jHypervisor.Get("service").Get("host").MustString()
+		if serviceHost == "" {
+			serviceHost = name
+		}
+		azLcuuid := o.getAZLcuuid(region, o.toolDataSet.hostNameToAZName[o.regionKey(region, serviceHost)])
+		htype, ok := HYPERVISOR_TYPE_TO_HTYPE[strings.ToLower(jHypervisor.Get("hypervisor_type").MustString())]
+		if !ok {
+			htype = common.HOST_HTYPE_KVM
+		}
+		hosts = append(hosts, model.Host{
+			Lcuuid:       common.GenerateUUIDByOrgID(o.orgID, region+"_"+name+"_"+o.lcuuidGenerate),
+			Name:         name,
+			IP:           ip,
+			Hostname:     name,
+			Type:         common.HOST_TYPE_VM,
+			HType:        htype,
+			VCPUNum:      jHypervisor.Get("vcpus").MustInt(),
+			MemTotal:     jHypervisor.Get("memory_mb").MustInt(),
+			AZLcuuid:     azLcuuid,
+			RegionLcuuid: regionLcuuid,
+		})
+		o.toolDataSet.hostNameToIP[o.regionKey(region, name)] = ip
+		o.toolDataSet.hostNameToIP[o.regionKey(region, serviceHost)] = ip
+		o.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+		o.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+	return hosts, nil
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	SERVICE_TYPE_COMPUTE       = "compute"
+	SERVICE_TYPE_NETWORK       = "network"
+	SERVICE_TYPE_LOAD_BALANCER = "load-balancer"
+	SERVICE_TYPE_DATABASE      = "database"
+)
+
+type Token struct {
+	token     string
+	expiresAt time.Time
+	projectID string
+	catalog   map[string]map[string]string // region -> service type -> endpoint url
+}
+
+// 离失效时间小于5m，则认为token已过期
+func (t *Token) isExpired() bool {
+	return time.Until(t.expiresAt) < 5*time.Minute
+}
+
+func (o *OpenStack) getToken() (*Token, error) {
+	if o.token == nil || o.token.isExpired() {
+		token, err := o.createToken()
+		if err != nil {
+			return nil, err
+		}
+		o.token = token
+	}
+	return o.token, nil
+}
+
+// createToken 使用 keystone v3 password 方式获取 project scoped token，并解析其中的 service catalog
+func (o *OpenStack) createToken() (*Token, error) {
+	authBody := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"password"},
+				"password": map[string]interface{}{
+					"user": map[string]interface{}{
+						"domain": map[string]interface{}{
+							"name": o.config.UserDomainName,
+						},
+						"name":     o.config.UserName,
+						"password": o.config.Password,
+					},
+				},
+			},
+			"scope": map[string]interface{}{
+				"project": map[string]interface{}{
+					"domain": map[string]interface{}{
+						"name": o.config.ProjectDomainName,
+					},
+					"name": o.config.ProjectName,
+				},
+			},
+		},
+	}
+	resp, err := cloudcommon.RequestPost(o.config.AuthURL+"/auth/tokens", time.Duration(o.httpTimeout), authBody)
+	if err != nil {
+		return nil, err
+	}
+	token := &Token{
+		token:     resp.Get("X-Subject-Token").MustString(),
+		projectID: resp.Get("token").Get("project").Get("id").MustString(),
+		catalog:   o.formatCatalog(resp.Get("token").Get("catalog")),
+	}
+	if token.token == "" {
+		return nil, errors.New("token not found in the response of keystone")
+	}
+	expiresAt := resp.Get("token").Get("expires_at").MustString()
+	token.expiresAt, err = time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		log.Errorf("parse token expires_at (%s) failed: %s", expiresAt, err.Error(), logger.NewORGPrefix(o.orgID))
+		token.expiresAt = time.Now()
+	}
+	return token, nil
+}
+
+func (o *OpenStack) formatCatalog(jCatalog *simplejson.Json) map[string]map[string]string {
+	catalog := make(map[string]map[string]string)
+	for i := range jCatalog.MustArray() {
+		jService := jCatalog.GetIndex(i)
+		serviceType := jService.Get("type").MustString()
+		jEndpoints := jService.Get("endpoints")
+		for j := range jEndpoints.MustArray() {
+			jEndpoint := jEndpoints.GetIndex(j)
+			if jEndpoint.Get("interface").MustString() != o.config.EndpointInterface {
+				continue
+			}
+			region := jEndpoint.Get("region_id").MustString()
+			if region == "" {
+				region = jEndpoint.Get("region").MustString()
+			}
+			if _, ok := catalog[region]; !ok {
+				catalog[region] = make(map[string]string)
+			}
+			catalog[region][serviceType] = strings.TrimSuffix(jEndpoint.Get("url").MustString(), "/")
+		}
+	}
+	return catalog
+}
+
+// getEndpoint 返回区域中服务的地址，network 和 load-balancer 服务的地址通常不包含版本号，统一补充 /v2.0 和 /v2
+func (o *OpenStack) getEndpoint(region, serviceType string) (string, bool) {
+	endpoint, ok := o.token.catalog[region][serviceType]
+	if !ok || endpoint == "" {
+		return "", false
+	}
+	if serviceType == SERVICE_TYPE_NETWORK && !strings.HasSuffix(endpoint, "/v2.0") {
+		endpoint += "/v2.0"
+	}
+	if serviceType == SERVICE_TYPE_LOAD_BALANCER && !strings.HasSuffix(endpoint, "/v2") && !strings.HasSuffix(endpoint, "/v2.0") {
+		endpoint += "/v2"
+	}
+	return endpoint, true
+}
+
+// getProjectNames 获取项目名称，非管理员用户没有权限时仅使用 token 所属项目的名称
+func (o *OpenStack) getProjectNames() map[string]string {
+	projectIDToName := map[string]string{o.token.projectID: o.config.ProjectName}
+	jProjects, err := o.getRawData(o.config.AuthURL+"/projects", "projects", false)
+	if err != nil {
+		log.Warningf("get projects failed: %s", err.Error(), logger.NewORGPrefix(o.orgID))
+		return projectIDToName
+	}
+	for _, jProject := range jProjects {
+		if !cloudcommon.CheckJsonAttributes(jProject, []string{"id", "name"}) {
+			continue
+		}
+		projectIDToName[jProject.Get("id").MustString()] = jProject.Get("name").MustString()
+	}
+	return projectIDToName
+}
+
+func (o *OpenStack) getRegionEndpoint(region, serviceType string) (string, error) {
+	endpoint, ok := o.getEndpoint(region, serviceType)
+	if !ok {
+		return "", fmt.Errorf("%s endpoint of region (%s) not found", serviceType, region)
+	}
+	return endpoint, nil
+}
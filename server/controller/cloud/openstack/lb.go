@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getLBs 通过 octavia 获取负载均衡器、监听器和后端主机，负载均衡器的 VIP 网卡在 getVInterfaces 中同步
+func (o *OpenStack) getLBs(region string) ([]model.LB, []model.LBListener, []model.LBTargetServer, error) {
+	endpoint, ok := o.getEndpoint(region, SERVICE_TYPE_LOAD_BALANCER)
+	if !ok {
+		log.Infof("exclude lbs, region (%s) has no octavia", region, logger.NewORGPrefix(o.orgID))
+		return nil, nil, nil, nil
+	}
+	jLBs, err := o.getRawData(endpoint+"/lbaas/loadbalancers", "loadbalancers", true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var lbs []model.LB
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jLB := range jLBs {
+		id := jLB.Get("id").MustString()
+		if !cloudcommon.CheckJsonAttributes(jLB, []string{"id", "name", "vip_address"}) {
+			log.Infof("exclude lb: %s, missing attr", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		name := jLB.Get("name").MustString()
+		if name == "" {
+			name = id
+		}
+		lbModel := cloudcommon.LB_MODEL_INTERNAL
+		if network, ok := o.toolDataSet.lcuuidToNetwork[common.IDGenerateUUID(o.orgID, jLB.Get("vip_network_id").MustString())]; ok && network.External {
+			lbModel = cloudcommon.LB_MODEL_EXTERNAL
+		}
+		lb := model.LB{
+			Lcuuid:       common.IDGenerateUUID(o.orgID, id),
+			Name:         name,
+			Label:        id,
+			Model:        lbModel,
+			VIP:          jLB.Get("vip_address").MustString(),
+			VPCLcuuid:    o.getVPCLcuuid(getProjectID(jLB), region),
+			RegionLcuuid: regionLcuuid,
+		}
+		lbs = append(lbs, lb)
+		o.toolDataSet.lbLcuuidToIP[lb.Lcuuid] = lb.VIP
+		o.toolDataSet.lbLcuuidToVPCLcuuid[lb.Lcuuid] = lb.VPCLcuuid
+		o.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+
+	listeners, targetServers, err := o.getLBListenersAndTargetServers(endpoint)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return lbs, listeners, targetServers, nil
+}
+
+func (o *OpenStack) getLBListenersAndTargetServers(endpoint string) ([]model.LBListener, []model.LBTargetServer, error) {
+	jListeners, err := o.getRawData(endpoint+"/lbaas/listeners", "listeners", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var listeners []model.LBListener
+	var targetServers []model.LBTargetServer
+	for _, jListener := range jListeners {
+		id := jListener.Get("id").MustString()
+		if !cloudcommon.CheckJsonAttributes(jListener, []string{"id", "name", "loadbalancers", "protocol", "protocol_port"}) {
+			log.Infof("exclude lb_listener: %s, missing attr", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		var lbLcuuid string
+		jLBs := jListener.Get("loadbalancers")
+		for i := range jLBs.MustArray() {
+			if lbID := jLBs.GetIndex(i).Get("id").MustString(); lbID != "" {
+				lbLcuuid = common.IDGenerateUUID(o.orgID, lbID)
+				break
+			}
+		}
+		if _, ok := o.toolDataSet.lbLcuuidToVPCLcuuid[lbLcuuid]; !ok {
+			log.Infof("exclude lb_listener: %s, missing lb info", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		name := jListener.Get("name").MustString()
+		if name == "" {
+			name = id
+		}
+		protocol := jListener.Get("protocol").MustString()
+		listener := model.LBListener{
+			Lcuuid:   common.IDGenerateUUID(o.orgID, id),
+			LBLcuuid: lbLcuuid,
+			Name:     name,
+			Label:    id,
+			IPs:      o.toolDataSet.lbLcuuidToIP[lbLcuuid],
+			Protocol: protocol,
+			Port:     jListener.Get("protocol_port").MustInt(),
+		}
+		listeners = append(listeners, listener)
+
+		poolID := jListener.Get("default_pool_id").MustString()
+		if poolID == "" {
+			continue
+		}
+		jMembers, err := o.getRawData(fmt.Sprintf("%s/lbaas/pools/%s/members", endpoint, poolID), "members", true)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, jMember := range jMembers {
+			memberID := jMember.Get("id").MustString()
+			if !cloudcommon.CheckJsonAttributes(jMember, []string{"id", "address", "protocol_port"}) {
+				log.Infof("exclude lb_target_server: %s, missing attr", memberID, logger.NewORGPrefix(o.orgID))
+				continue
+			}
+			ip := jMember.Get("address").MustString()
+			targetServer := model.LBTargetServer{
+				Lcuuid:           common.IDGenerateUUID(o.orgID, memberID),
+				LBLcuuid:         lbLcuuid,
+				LBListenerLcuuid: listener.Lcuuid,
+				Type:             common.LB_SERVER_TYPE_IP,
+				IP:               ip,
+				Port:             jMember.Get("protocol_port").MustInt(),
+				Protocol:         protocol,
+				VPCLcuuid:        o.toolDataSet.lbLcuuidToVPCLcuuid[lbLcuuid],
+			}
+			subnetLcuuid := common.IDGenerateUUID(o.orgID, jMember.Get("subnet_id").MustString())
+			if vmLcuuid, ok := o.toolDataSet.keyToVMLcuuid[SubnetIPKey{subnetLcuuid, ip}]; ok {
+				targetServer.Type = common.LB_SERVER_TYPE_VM
+				targetServer.VMLcuuid = vmLcuuid
+			}
+			targetServers = append(targetServers, targetServer)
+		}
+	}
+	return listeners, targetServers, nil
+}
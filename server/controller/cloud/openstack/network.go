@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 旧版本的 neutron 使用 tenant_id 表示资源所属的项目
+func getProjectID(jData *simplejson.Json) string {
+	if projectID := jData.Get("project_id").MustString(); projectID != "" {
+		return projectID
+	}
+	return jData.Get("tenant_id").MustString()
+}
+
+func (o *OpenStack) getNetworks(region string) ([]model.Network, []model.Subnet, error) {
+	endpoint, err := o.getRegionEndpoint(region, SERVICE_TYPE_NETWORK)
+	if err != nil {
+		log.Infof("exclude networks: %s", err.Error(), logger.NewORGPrefix(o.orgID))
+		return nil, nil, nil
+	}
+	jNetworks, err := o.getRawData(endpoint+"/networks", "networks", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var networks []model.Network
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jNetwork := range jNetworks {
+		name := jNetwork.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jNetwork, []string{"id", "name"}) {
+			log.Infof("exclude network: %s, missing attr", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		id := jNetwork.Get("id").MustString()
+		if name == "" {
+			name = id
+		}
+		external := jNetwork.Get("router:external").MustBool()
+		netType := common.NETWORK_TYPE_LAN
+		if external {
+			netType = common.NETWORK_TYPE_WAN
+		}
+		var azLcuuid string
+		if jAZs := jNetwork.Get("availability_zones").MustArray(); len(jAZs) == 1 {
+			azLcuuid = o.getAZLcuuid(region, jNetwork.Get("availability_zones").GetIndex(0).MustString())
+		}
+		network := model.Network{
+			Lcuuid:         common.IDGenerateUUID(o.orgID, id),
+			Name:           name,
+			Label:          id,
+			SegmentationID: jNetwork.Get("provider:segmentation_id").MustInt(),
+			Shared:         jNetwork.Get("shared").MustBool(),
+			External:       external,
+			NetType:        netType,
+			VPCLcuuid:      o.getVPCLcuuid(getProjectID(jNetwork), region),
+			AZLcuuid:       azLcuuid,
+			RegionLcuuid:   regionLcuuid,
+		}
+		networks = append(networks, network)
+		o.toolDataSet.lcuuidToNetwork[network.Lcuuid] = network
+		o.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+
+	subnets, err := o.getSubnets(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return networks, subnets, nil
+}
+
+func (o *OpenStack) getSubnets(endpoint string) ([]model.Subnet, error) {
+	jSubnets, err := o.getRawData(endpoint+"/subnets", "subnets", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var subnets []model.Subnet
+	for _, jSubnet := range jSubnets {
+		id := jSubnet.Get("id").MustString()
+		if !cloudcommon.CheckJsonAttributes(jSubnet, []string{"id", "cidr", "network_id"}) {
+			log.Infof("exclude subnet: %s, missing attr", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		network, ok := o.toolDataSet.lcuuidToNetwork[common.IDGenerateUUID(o.orgID, jSubnet.Get("network_id").MustString())]
+		if !ok {
+			log.Infof("exclude subnet: %s, missing network info", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		name := jSubnet.Get("name").MustString()
+		if name == "" {
+			name = id
+		}
+		subnet := model.Subnet{
+			Lcuuid:        common.IDGenerateUUID(o.orgID, id),
+			Name:          name,
+			Label:         id,
+			CIDR:          jSubnet.Get("cidr").MustString(),
+			GatewayIP:     jSubnet.Get("gateway_ip").MustString(),
+			NetworkLcuuid: network.Lcuuid,
+			VPCLcuuid:     network.VPCLcuuid,
+		}
+		subnets = append(subnets, subnet)
+		o.toolDataSet.networkLcuuidToSubnets[network.Lcuuid] = append(o.toolDataSet.networkLcuuidToSubnets[network.Lcuuid], subnet)
+	}
+	return subnets, nil
+}
+
+func (o *OpenStack) getSubnetLcuuid(networkLcuuid, ip string) string {
+	for _, subnet := range o.toolDataSet.networkLcuuidToSubnets[networkLcuuid] {
+		if cloudcommon.IsIPInCIDR(ip, subnet.CIDR) {
+			return subnet.Lcuuid
+		}
+	}
+	return ""
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.openstack")
+
+const PAGE_LIMIT = 500
+
+type OpenStack struct {
+	orgID          int
+	teamID         int
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	token          *Token
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewOpenStack(orgID int, domain mysqlmodel.Domain, globalCloudCfg config.CloudConfig) (*OpenStack, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(orgID, domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenStack{
+		orgID:  orgID,
+		teamID: domain.TeamID,
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}, nil
+}
+
+func (o *OpenStack) ClearDebugLog() {
+	o.debugger.Clear()
+}
+
+func (o *OpenStack) CheckAuth() error {
+	_, err := o.createToken()
+	return err
+}
+
+func (o *OpenStack) GetCloudData() (model.Resource, error) {
+	o.cloudStatsd = statsd.NewCloudStatsd()
+	o.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+	if _, err := o.getToken(); err != nil {
+		return resource, err
+	}
+	o.toolDataSet.projectIDToName = o.getProjectNames()
+
+	regions := o.getRegions()
+	for _, region := range o.toolDataSet.regionNames {
+		azs, err := o.getAZs(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.AZs = append(resource.AZs, azs...)
+
+		hosts, err := o.getHosts(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.Hosts = append(resource.Hosts, hosts...)
+
+		networks, subnets, err := o.getNetworks(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.Networks = append(resource.Networks, networks...)
+		resource.Subnets = append(resource.Subnets, subnets...)
+
+		vrouters, routingTables, err := o.getVRouters(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.VRouters = append(resource.VRouters, vrouters...)
+		resource.RoutingTables = append(resource.RoutingTables, routingTables...)
+
+		vms, err := o.getVMs(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.VMs = append(resource.VMs, vms...)
+
+		dhcpPorts, vifs, ips, err := o.getVInterfaces(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.DHCPPorts = append(resource.DHCPPorts, dhcpPorts...)
+		resource.VInterfaces = append(resource.VInterfaces, vifs...)
+		resource.IPs = append(resource.IPs, ips...)
+
+		fIPs, err := o.getFloatingIPs(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.FloatingIPs = append(resource.FloatingIPs, fIPs...)
+
+		lbs, listeners, targetServers, err := o.getLBs(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.LBs = append(resource.LBs, lbs...)
+		resource.LBListeners = append(resource.LBListeners, listeners...)
+		resource.LBTargetServers = append(resource.LBTargetServers, targetServers...)
+
+		rdsInstances, err := o.getRDSInstances(region)
+		if err != nil {
+			return resource, err
+		}
+		resource.RDSInstances = append(resource.RDSInstances, rdsInstances...)
+	}
+	resource.VPCs = o.getVPCs()
+
+	log.Debugf("region resource num info: %v", o.toolDataSet.regionLcuuidToResourceNum, logger.NewORGPrefix(o.orgID))
+	log.Debugf("az resource num info: %v", o.toolDataSet.azLcuuidToResourceNum, logger.NewORGPrefix(o.orgID))
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, o.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(resource.AZs, o.toolDataSet.azLcuuidToResourceNum)
+
+	o.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(o)
+
+	o.debugger.Refresh()
+	return resource, nil
+}
+
+func (o *OpenStack) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": o.name,
+		"domain":      o.lcuuid,
+		"platform":    common.OPENSTACK_EN,
+	}
+
+	return statsd.StatsdStatter{
+		OrgID:      o.orgID,
+		TeamID:     o.teamID,
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(o.cloudStatsd),
+	}
+}
+
+// getRawData 获取接口返回数据中 resultKey 对应的列表，分页时使用 limit 和 marker 参数，
+// 未开启分页的接口会忽略 limit 和 marker 返回全部数据，此时根据 id 去重并在没有新数据时结束
+func (o *OpenStack) getRawData(url, resultKey string, paged bool) (jsonList []*simplejson.Json, err error) {
+	statsdAPIStartTime := time.Now()
+	token, err := o.getToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if !paged {
+		resp, err := cloudcommon.RequestGet(url, token.token, time.Duration(o.httpTimeout))
+		if err != nil {
+			return nil, err
+		}
+		jData := resp.Get(resultKey)
+		for i := range jData.MustArray() {
+			jsonList = append(jsonList, jData.GetIndex(i))
+		}
+	} else {
+		sep := "?"
+		if strings.Contains(url, "?") {
+			sep = "&"
+		}
+		ids := make(map[string]bool)
+		var marker string
+		for {
+			pageURL := fmt.Sprintf("%s%slimit=%d", url, sep, PAGE_LIMIT)
+			if marker != "" {
+				pageURL = fmt.Sprintf("%s&marker=%s", pageURL, marker)
+			}
+			resp, err := cloudcommon.RequestGet(pageURL, token.token, time.Duration(o.httpTimeout))
+			if err != nil {
+				return nil, err
+			}
+			jData := resp.Get(resultKey)
+			curCount := len(jData.MustArray())
+			newCount := 0
+			for i := 0; i < curCount; i++ {
+				jItem := jData.GetIndex(i)
+				id := jItem.Get("id").MustString()
+				if ids[id] {
+					continue
+				}
+				ids[id] = true
+				newCount++
+				marker = id
+				jsonList = append(jsonList, jItem)
+			}
+			if curCount < PAGE_LIMIT || newCount == 0 || marker == "" {
+				break
+			}
+		}
+	}
+	o.cloudStatsd.RefreshAPIMoniter(resultKey, len(jsonList), statsdAPIStartTime)
+
+	o.debugger.WriteJson(resultKey, url, jsonList)
+	return
+}
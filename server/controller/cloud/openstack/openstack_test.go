@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+)
+
+// the responses of the openstack apis, the key is the suffix of the request path
+var testResponseFiles = map[string]string{
+	"/v3/auth/tokens": "token.json",
+	"/v3/projects":    "projects.json",
+	"/compute/v2.1/os-availability-zone/detail":    "availability_zones.json",
+	"/compute/v2.1/os-hypervisors/detail":          "hypervisors.json",
+	"/compute/v2.1/servers/detail":                 "servers.json",
+	"/network/v2.0/networks":                       "networks.json",
+	"/network/v2.0/subnets":                        "subnets.json",
+	"/network/v2.0/routers":                        "routers.json",
+	"/network/v2.0/ports":                          "ports.json",
+	"/network/v2.0/floatingips":                    "floatingips.json",
+	"/load-balancer/v2/lbaas/loadbalancers":        "loadbalancers.json",
+	"/load-balancer/v2/lbaas/listeners":            "listeners.json",
+	"/load-balancer/v2/lbaas/pools/pool-1/members": "members.json",
+	"/database/v1.0/p-admin/instances":             "instances.json",
+}
+
+func newTestServer() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := testResponseFiles[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile("testfiles/" + file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set("X-Subject-Token", "test-token")
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(strings.ReplaceAll(string(data), "{{endpoint}}", server.URL)))
+	}))
+	return server
+}
+
+func TestOpenStack(t *testing.T) {
+	Convey("TestOpenStack", t, func() {
+		server := newTestServer()
+		defer server.Close()
+
+		openstack := &OpenStack{
+			orgID:          mysqlcommon.DEFAULT_ORG_ID,
+			lcuuidGenerate: "test_openstack",
+			name:           "test_openstack",
+			httpTimeout:    30,
+			config: &Config{
+				AuthURL:           server.URL + "/v3",
+				UserName:          "admin",
+				Password:          "password",
+				UserDomainName:    DEFAULT_DOMAIN_NAME,
+				ProjectName:       "admin",
+				ProjectDomainName: DEFAULT_DOMAIN_NAME,
+				EndpointInterface: DEFAULT_ENDPOINT_INTERFACE,
+				IncludeRegions:    map[string]bool{"RegionOne": false},
+			},
+			debugger: cloudcommon.NewDebugger("test_openstack"),
+		}
+		data, err := openstack.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("openstackResource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 1)
+			So(len(data.AZs), ShouldEqual, 1)
+			So(len(data.Hosts), ShouldEqual, 2)
+			So(len(data.VPCs), ShouldEqual, 2)
+			So(len(data.Networks), ShouldEqual, 2)
+			So(len(data.Subnets), ShouldEqual, 2)
+			So(len(data.VRouters), ShouldEqual, 1)
+			So(len(data.RoutingTables), ShouldEqual, 1)
+			So(len(data.VMs), ShouldEqual, 2)
+			So(len(data.VInterfaces), ShouldEqual, 6)
+			So(len(data.IPs), ShouldEqual, 6)
+			So(len(data.DHCPPorts), ShouldEqual, 1)
+			So(len(data.FloatingIPs), ShouldEqual, 1)
+			So(len(data.LBs), ShouldEqual, 1)
+			So(len(data.LBListeners), ShouldEqual, 1)
+			So(len(data.LBTargetServers), ShouldEqual, 2)
+			So(len(data.RDSInstances), ShouldEqual, 1)
+		})
+
+		Convey("vm should be launched on the host of the hypervisor", func() {
+			So(data.VMs[0].LaunchServer, ShouldEqual, "10.0.0.11")
+			So(data.VMs[0].State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(data.VMs[0].CloudTags, ShouldResemble, map[string]string{"env": "prod"})
+		})
+
+		Convey("lb target server in the subnet of a vm should be vm type", func() {
+			So(data.LBTargetServers[0].Type, ShouldEqual, common.LB_SERVER_TYPE_VM)
+			So(data.LBTargetServers[0].VMLcuuid, ShouldEqual, data.VMs[0].Lcuuid)
+			So(data.LBTargetServers[1].Type, ShouldEqual, common.LB_SERVER_TYPE_IP)
+		})
+	})
+}
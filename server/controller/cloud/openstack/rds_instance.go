@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var RDS_STATE_CONVERTION = map[string]int{
+	"ACTIVE":  common.RDS_STATE_RUNNING,
+	"RESTART": common.RDS_STATE_RESTORING,
+}
+
+var RDS_TYPE_CONVERTION = map[string]int{
+	"mysql":      common.RDS_TYPE_MYSQL,
+	"percona":    common.RDS_TYPE_MYSQL,
+	"mariadb":    common.RDS_TYPE_MARIADB,
+	"postgresql": common.RDS_TYPE_PSQL,
+}
+
+// getRDSInstances 通过 trove 获取数据库实例，trove 的地址中包含 token 所属的项目
+func (o *OpenStack) getRDSInstances(region string) ([]model.RDSInstance, error) {
+	endpoint, ok := o.getEndpoint(region, SERVICE_TYPE_DATABASE)
+	if !ok {
+		log.Infof("exclude rds instances, region (%s) has no trove", region, logger.NewORGPrefix(o.orgID))
+		return nil, nil
+	}
+	jInstances, err := o.getRawData(endpoint+"/instances", "instances", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var rdsInstances []model.RDSInstance
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jInstance := range jInstances {
+		id := jInstance.Get("id").MustString()
+		if !cloudcommon.CheckJsonAttributes(jInstance, []string{"id", "name", "status", "datastore"}) {
+			log.Infof("exclude rds instance: %s, missing attr", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		rdsModel := common.RDS_MODEL_PRIMARY
+		if _, ok := jInstance.CheckGet("replica_of"); ok {
+			rdsModel = common.RDS_MODEL_READONLY
+		}
+		rdsInstances = append(rdsInstances, model.RDSInstance{
+			Lcuuid:       common.IDGenerateUUID(o.orgID, id),
+			Name:         jInstance.Get("name").MustString(),
+			Label:        id,
+			State:        RDS_STATE_CONVERTION[jInstance.Get("status").MustString()],
+			Type:         RDS_TYPE_CONVERTION[strings.ToLower(jInstance.Get("datastore").Get("type").MustString())],
+			Version:      jInstance.Get("datastore").Get("version").MustString(),
+			Series:       common.RDS_SERIES_BASIC,
+			Model:        rdsModel,
+			VPCLcuuid:    o.getVPCLcuuid(o.token.projectID, region),
+			RegionLcuuid: regionLcuuid,
+		})
+		o.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+	return rdsInstances, nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"sort"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getRegions 从 service catalog 中获取提供 compute 或 network 服务的区域
+func (o *OpenStack) getRegions() []model.Region {
+	var names []string
+	for name, services := range o.token.catalog {
+		_, hasCompute := services[SERVICE_TYPE_COMPUTE]
+		_, hasNetwork := services[SERVICE_TYPE_NETWORK]
+		if hasCompute || hasNetwork {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var regions []model.Region
+	for _, name := range names {
+		// 区域白名单，如果当前区域不在白名单中，则跳过
+		if _, ok := o.config.IncludeRegions[name]; !ok {
+			log.Infof("region (%s) not in include_regions", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		// 区域黑名单，如果当前区域在黑名单中，则跳过
+		if _, ok := o.config.ExcludeRegions[name]; ok {
+			log.Infof("region (%s) in exclude_regions", name, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		region := model.Region{
+			Lcuuid: common.GenerateUUIDByOrgID(o.orgID, name+"_"+o.lcuuidGenerate),
+			Label:  name,
+			Name:   name,
+		}
+		regions = append(regions, region)
+		o.toolDataSet.regionNames = append(o.toolDataSet.regionNames, name)
+		o.toolDataSet.regionNameToLcuuid[name] = region.Lcuuid
+	}
+	return regions
+}
+
+func (o *OpenStack) getRegionLcuuid(region string) string {
+	if o.config.RegionLcuuid != "" {
+		return o.config.RegionLcuuid
+	}
+	return o.toolDataSet.regionNameToLcuuid[region]
+}
+
+// OpenStack 中没有 VPC，每个项目在每个区域中的资源对应一个 VPC
+func (o *OpenStack) getVPCLcuuid(projectID, region string) string {
+	lcuuid := common.GenerateUUIDByOrgID(o.orgID, projectID+"_"+region+"_"+o.lcuuidGenerate)
+	if _, ok := o.toolDataSet.vpcs[lcuuid]; !ok {
+		name, ok := o.toolDataSet.projectIDToName[projectID]
+		if !ok || name == "" {
+			name = projectID
+		}
+		o.toolDataSet.vpcs[lcuuid] = model.VPC{
+			Lcuuid:       lcuuid,
+			Name:         name,
+			Label:        projectID,
+			RegionLcuuid: o.getRegionLcuuid(region),
+		}
+	}
+	return lcuuid
+}
+
+func (o *OpenStack) getVPCs() []model.VPC {
+	var vpcs []model.VPC
+	for _, vpc := range o.toolDataSet.vpcs {
+		vpcs = append(vpcs, vpc)
+	}
+	sort.Slice(vpcs, func(i, j int) bool { return vpcs[i].Lcuuid < vpcs[j].Lcuuid })
+	return vpcs
+}
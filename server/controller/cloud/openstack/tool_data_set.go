@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+)
+
+type ToolDataSet struct {
+	regionNames               []string
+	regionNameToLcuuid        map[string]string
+	projectIDToName           map[string]string
+	vpcs                      map[string]model.VPC
+	azNameToLcuuid            map[string]string
+	hostNameToAZName          map[string]string
+	hostNameToIP              map[string]string
+	lcuuidToNetwork           map[string]model.Network
+	networkLcuuidToSubnets    map[string][]model.Subnet
+	vmLcuuidToVPCLcuuid       map[string]string
+	portIDToVInterface        map[string]model.VInterface
+	keyToVMLcuuid             map[SubnetIPKey]string
+	lbLcuuidToIP              map[string]string
+	lbLcuuidToVPCLcuuid       map[string]string
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+type SubnetIPKey struct {
+	SubnetLcuuid string
+	IP           string
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		regionNameToLcuuid:        make(map[string]string),
+		projectIDToName:           make(map[string]string),
+		vpcs:                      make(map[string]model.VPC),
+		azNameToLcuuid:            make(map[string]string),
+		hostNameToAZName:          make(map[string]string),
+		hostNameToIP:              make(map[string]string),
+		lcuuidToNetwork:           make(map[string]model.Network),
+		networkLcuuidToSubnets:    make(map[string][]model.Subnet),
+		vmLcuuidToVPCLcuuid:       make(map[string]string),
+		portIDToVInterface:        make(map[string]model.VInterface),
+		keyToVMLcuuid:             make(map[SubnetIPKey]string),
+		lbLcuuidToIP:              make(map[string]string),
+		lbLcuuidToVPCLcuuid:       make(map[string]string),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	DEVICE_OWNER_VM_PREFIX          = "compute:"
+	DEVICE_OWNER_DHCP               = "network:dhcp"
+	DEVICE_OWNER_ROUTER_GW          = "network:router_gateway"
+	DEVICE_OWNER_ROUTER_IFACE       = "network:router_interface"
+	DEVICE_OWNER_ROUTER_IFACE_DVR   = "network:router_interface_distributed"
+	DEVICE_OWNER_ROUTER_IFACE_HA    = "network:ha_router_replicated_interface"
+	DEVICE_OWNER_ROUTER_SNAT        = "network:router_centralized_snat"
+	DEVICE_OWNER_OCTAVIA            = "Octavia"
+	DEVICE_OWNER_LOADBALANCERV2     = "neutron:LOADBALANCERV2"
+	OCTAVIA_VIP_PORT_DEVICE_ID_PREF = "lb-"
+)
+
+var ROUTER_DEVICE_OWNERS = []string{
+	DEVICE_OWNER_ROUTER_GW, DEVICE_OWNER_ROUTER_IFACE, DEVICE_OWNER_ROUTER_IFACE_DVR, DEVICE_OWNER_ROUTER_IFACE_HA, DEVICE_OWNER_ROUTER_SNAT,
+}
+
+func (o *OpenStack) getVInterfaces(region string) ([]model.DHCPPort, []model.VInterface, []model.IP, error) {
+	endpoint, err := o.getRegionEndpoint(region, SERVICE_TYPE_NETWORK)
+	if err != nil {
+		return nil, nil, nil, nil
+	}
+	jPorts, err := o.getRawData(endpoint+"/ports", "ports", true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var dhcpPorts []model.DHCPPort
+	var vifs []model.VInterface
+	var ips []model.IP
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jPort := range jPorts {
+		mac := jPort.Get("mac_address").MustString()
+		if !cloudcommon.CheckJsonAttributes(jPort, []string{"id", "mac_address", "network_id", "device_id", "device_owner"}) {
+			log.Infof("exclude vinterface: %s, missing attr", mac, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		id := common.IDGenerateUUID(o.orgID, jPort.Get("id").MustString())
+		network, ok := o.toolDataSet.lcuuidToNetwork[common.IDGenerateUUID(o.orgID, jPort.Get("network_id").MustString())]
+		if !ok {
+			log.Infof("exclude vinterface: %s, missing network info", mac, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		deviceID := jPort.Get("device_id").MustString()
+		deviceOwner := jPort.Get("device_owner").MustString()
+		var deviceType int
+		var deviceLcuuid string
+		switch {
+		case strings.HasPrefix(deviceOwner, DEVICE_OWNER_VM_PREFIX):
+			deviceType = common.VIF_DEVICE_TYPE_VM
+			deviceLcuuid = common.IDGenerateUUID(o.orgID, deviceID)
+		case common.Contains(ROUTER_DEVICE_OWNERS, deviceOwner):
+			deviceType = common.VIF_DEVICE_TYPE_VROUTER
+			deviceLcuuid = common.IDGenerateUUID(o.orgID, deviceID)
+		case deviceOwner == DEVICE_OWNER_OCTAVIA || deviceOwner == DEVICE_OWNER_LOADBALANCERV2:
+			deviceType = common.VIF_DEVICE_TYPE_LB
+			deviceLcuuid = common.IDGenerateUUID(o.orgID, strings.TrimPrefix(deviceID, OCTAVIA_VIP_PORT_DEVICE_ID_PREF))
+		case deviceOwner == DEVICE_OWNER_DHCP:
+			deviceType = common.VIF_DEVICE_TYPE_DHCP_PORT
+			deviceLcuuid = id
+			name := network.Name + "_DHCP"
+			if len(name) > 256 {
+				name = name[:256]
+			}
+			dhcpPorts = append(dhcpPorts, model.DHCPPort{
+				Lcuuid:       id,
+				Name:         name,
+				VPCLcuuid:    network.VPCLcuuid,
+				AZLcuuid:     network.AZLcuuid,
+				RegionLcuuid: regionLcuuid,
+			})
+		default:
+			log.Infof("exclude vinterface: %s, %s", mac, deviceOwner, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+
+		vifType := common.VIF_TYPE_LAN
+		if network.External {
+			vifType = common.VIF_TYPE_WAN
+		}
+		vif := model.VInterface{
+			Lcuuid:        id,
+			Name:          jPort.Get("name").MustString(),
+			Type:          vifType,
+			Mac:           mac,
+			DeviceLcuuid:  deviceLcuuid,
+			DeviceType:    deviceType,
+			NetworkLcuuid: network.Lcuuid,
+			VPCLcuuid:     network.VPCLcuuid,
+			RegionLcuuid:  regionLcuuid,
+		}
+		vifs = append(vifs, vif)
+		o.toolDataSet.portIDToVInterface[jPort.Get("id").MustString()] = vif
+
+		jIPs := jPort.Get("fixed_ips")
+		for i := range jIPs.MustArray() {
+			jIP := jIPs.GetIndex(i)
+			if !cloudcommon.CheckJsonAttributes(jIP, []string{"ip_address"}) {
+				continue
+			}
+			ip := jIP.Get("ip_address").MustString()
+			subnetLcuuid := o.getSubnetLcuuid(network.Lcuuid, ip)
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GenerateUUIDByOrgID(o.orgID, vif.Lcuuid+ip),
+				VInterfaceLcuuid: vif.Lcuuid,
+				IP:               ip,
+				SubnetLcuuid:     subnetLcuuid,
+				RegionLcuuid:     regionLcuuid,
+			})
+			if deviceType == common.VIF_DEVICE_TYPE_VM {
+				o.toolDataSet.keyToVMLcuuid[SubnetIPKey{subnetLcuuid, ip}] = deviceLcuuid
+			}
+		}
+	}
+	return dhcpPorts, vifs, ips, nil
+}
+
+func (o *OpenStack) getFloatingIPs(region string) ([]model.FloatingIP, error) {
+	endpoint, err := o.getRegionEndpoint(region, SERVICE_TYPE_NETWORK)
+	if err != nil {
+		return nil, nil
+	}
+	jFIPs, err := o.getRawData(endpoint+"/floatingips", "floatingips", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var fIPs []model.FloatingIP
+	for _, jFIP := range jFIPs {
+		ip := jFIP.Get("floating_ip_address").MustString()
+		if !cloudcommon.CheckJsonAttributes(jFIP, []string{"id", "floating_ip_address", "floating_network_id", "port_id"}) {
+			log.Infof("exclude floating ip: %s, missing attr", ip, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		// 仅同步绑定了云服务器的浮动IP
+		vif, ok := o.toolDataSet.portIDToVInterface[jFIP.Get("port_id").MustString()]
+		if !ok || vif.DeviceType != common.VIF_DEVICE_TYPE_VM {
+			log.Infof("exclude floating ip: %s, not bound to vm", ip, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		vpcLcuuid, ok := o.toolDataSet.vmLcuuidToVPCLcuuid[vif.DeviceLcuuid]
+		if !ok {
+			vpcLcuuid = vif.VPCLcuuid
+		}
+		fIPs = append(fIPs, model.FloatingIP{
+			Lcuuid:        common.IDGenerateUUID(o.orgID, jFIP.Get("id").MustString()),
+			IP:            ip,
+			VMLcuuid:      vif.DeviceLcuuid,
+			NetworkLcuuid: common.IDGenerateUUID(o.orgID, jFIP.Get("floating_network_id").MustString()),
+			VPCLcuuid:     vpcLcuuid,
+			RegionLcuuid:  vif.RegionLcuuid,
+		})
+	}
+	return fIPs, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+	"time"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var STATE_CONVERTION = map[string]int{
+	"ACTIVE":  common.VM_STATE_RUNNING,
+	"SHUTOFF": common.VM_STATE_STOPPED,
+	"ERROR":   common.VM_STATE_EXCEPTION,
+}
+
+func (o *OpenStack) getVMs(region string) ([]model.VM, error) {
+	endpoint, err := o.getRegionEndpoint(region, SERVICE_TYPE_COMPUTE)
+	if err != nil {
+		log.Infof("exclude vms: %s", err.Error(), logger.NewORGPrefix(o.orgID))
+		return nil, nil
+	}
+	// 非管理员用户没有 all_tenants 权限时，仅获取 token 所属项目的云服务器
+	jVMs, err := o.getRawData(endpoint+"/servers/detail?all_tenants=1", "servers", true)
+	if err != nil {
+		log.Warningf("get servers of all projects failed: %s", err.Error(), logger.NewORGPrefix(o.orgID))
+		jVMs, err = o.getRawData(endpoint+"/servers/detail", "servers", true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var vms []model.VM
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jVM := range jVMs {
+		id := jVM.Get("id").MustString()
+		if !cloudcommon.CheckJsonAttributes(jVM, []string{"id", "name", "status", "tenant_id"}) {
+			log.Infof("exclude vm: %s, missing attr", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		azLcuuid := o.getAZLcuuid(region, jVM.Get("OS-EXT-AZ:availability_zone").MustString())
+		cloudTags := make(map[string]string)
+		for k, v := range jVM.Get("metadata").MustMap() {
+			cloudTags[k] = fmt.Sprint(v)
+		}
+		vm := model.VM{
+			Lcuuid:       common.IDGenerateUUID(o.orgID, id),
+			Name:         jVM.Get("name").MustString(),
+			Label:        id,
+			Hostname:     jVM.Get("OS-EXT-SRV-ATTR:hostname").MustString(),
+			HType:        common.VM_HTYPE_VM_C,
+			State:        STATE_CONVERTION[jVM.Get("status").MustString()],
+			LaunchServer: o.toolDataSet.hostNameToIP[o.regionKey(region, jVM.Get("OS-EXT-SRV-ATTR:host").MustString())],
+			VPCLcuuid:    o.getVPCLcuuid(jVM.Get("tenant_id").MustString(), region),
+			AZLcuuid:     azLcuuid,
+			RegionLcuuid: regionLcuuid,
+			CloudTags:    cloudTags,
+		}
+		if created := jVM.Get("created").MustString(); created != "" {
+			createdAt, err := time.Parse(time.RFC3339, created)
+			if err != nil {
+				log.Errorf("parse created failed: %s", created, logger.NewORGPrefix(o.orgID))
+			} else {
+				vm.CreatedAt = createdAt
+			}
+		}
+		vms = append(vms, vm)
+		o.toolDataSet.vmLcuuidToVPCLcuuid[vm.Lcuuid] = vm.VPCLcuuid
+		o.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+		o.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+	return vms, nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (o *OpenStack) getVRouters(region string) ([]model.VRouter, []model.RoutingTable, error) {
+	endpoint, err := o.getRegionEndpoint(region, SERVICE_TYPE_NETWORK)
+	if err != nil {
+		return nil, nil, nil
+	}
+	jRouters, err := o.getRawData(endpoint+"/routers", "routers", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var vrouters []model.VRouter
+	var routingTables []model.RoutingTable
+	regionLcuuid := o.getRegionLcuuid(region)
+	for _, jRouter := range jRouters {
+		id := jRouter.Get("id").MustString()
+		if !cloudcommon.CheckJsonAttributes(jRouter, []string{"id", "name"}) {
+			log.Infof("exclude vrouter: %s, missing attr", id, logger.NewORGPrefix(o.orgID))
+			continue
+		}
+		name := jRouter.Get("name").MustString()
+		if name == "" {
+			name = id
+		}
+		vrouterLcuuid := common.IDGenerateUUID(o.orgID, id)
+		vrouters = append(vrouters, model.VRouter{
+			Lcuuid:       vrouterLcuuid,
+			Name:         name,
+			Label:        id,
+			VPCLcuuid:    o.getVPCLcuuid(getProjectID(jRouter), region),
+			RegionLcuuid: regionLcuuid,
+		})
+		o.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+		jRoutes := jRouter.Get("routes")
+		for i := range jRoutes.MustArray() {
+			jRoute := jRoutes.GetIndex(i)
+			if !cloudcommon.CheckJsonAttributes(jRoute, []string{"destination", "nexthop"}) {
+				continue
+			}
+			destination := jRoute.Get("destination").MustString()
+			nexthop := jRoute.Get("nexthop").MustString()
+			routingTables = append(routingTables, model.RoutingTable{
+				Lcuuid:        common.GenerateUUIDByOrgID(o.orgID, vrouterLcuuid+destination+nexthop),
+				VRouterLcuuid: vrouterLcuuid,
+				Destination:   destination,
+				NexthopType:   common.ROUTING_TABLE_TYPE_IP,
+				Nexthop:       nexthop,
+			})
+		}
+	}
+	return vrouters, routingTables, nil
+}
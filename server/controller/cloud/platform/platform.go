@@ -22,16 +22,20 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/cloud/aliyun"
 	"github.com/deepflowio/deepflow/server/controller/cloud/aws"
+	"github.com/deepflowio/deepflow/server/controller/cloud/azure"
 	"github.com/deepflowio/deepflow/server/controller/cloud/baidubce"
 	"github.com/deepflowio/deepflow/server/controller/cloud/config"
 	"github.com/deepflowio/deepflow/server/controller/cloud/filereader"
+	"github.com/deepflowio/deepflow/server/controller/cloud/gcp"
 	"github.com/deepflowio/deepflow/server/controller/cloud/genesis"
 	"github.com/deepflowio/deepflow/server/controller/cloud/huawei"
 	"github.com/deepflowio/deepflow/server/controller/cloud/kubernetes"
 	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/cloud/openstack"
 	"github.com/deepflowio/deepflow/server/controller/cloud/qingcloud"
 	"github.com/deepflowio/deepflow/server/controller/cloud/tencent"
 	"github.com/deepflowio/deepflow/server/controller/cloud/volcengine"
+	"github.com/deepflowio/deepflow/server/controller/cloud/vsphere"
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
 	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
@@ -71,6 +75,14 @@ func NewPlatform(domain mysqlmodel.Domain, cfg config.CloudConfig, db *mysql.DB)
 		platform, err = filereader.NewFileReader(db.ORGID, domain)
 	case common.VOLCENGINE:
 		platform, err = volcengine.NewVolcEngine(db.ORGID, domain, cfg)
+	case common.OPENSTACK:
+		platform, err = openstack.NewOpenStack(db.ORGID, domain, cfg)
+	case common.VSPHERE:
+		platform, err = vsphere.NewVSphere(db.ORGID, domain, cfg)
+	case common.AZURE:
+		platform, err = azure.NewAzure(db.ORGID, domain, cfg)
+	case common.GCP:
+		platform, err = gcp.NewGCP(db.ORGID, domain, cfg)
 	// TODO: other platform
 	default:
 		return nil, errors.New(fmt.Sprintf("domain type (%d) not supported", domain.Type))
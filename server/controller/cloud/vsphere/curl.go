@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const SESSION_HEADER = "vmware-api-session-id"
+
+func newErr(url, msg string) error {
+	return errors.New(fmt.Sprintf("request url: %s, %s", url, msg))
+}
+
+// createSession 使用 basic auth 创建 vSphere Automation API 会话，返回的会话 ID 用于后续请求
+func (v *VSphere) createSession() error {
+	url := v.config.URL + "/api/session"
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.SetBasicAuth(v.config.UserName, v.config.Password)
+	respBody, err := v.do(req)
+	if err != nil {
+		return err
+	}
+	var sessionID string
+	if err := json.Unmarshal(respBody, &sessionID); err != nil || sessionID == "" {
+		return newErr(url, fmt.Sprintf("session id not found in response: %s", string(respBody)))
+	}
+	v.sessionID = sessionID
+	return nil
+}
+
+func (v *VSphere) deleteSession() {
+	if v.sessionID == "" {
+		return
+	}
+	url := v.config.URL + "/api/session"
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set(SESSION_HEADER, v.sessionID)
+	if _, err := v.do(req); err != nil {
+		log.Warningf("delete session failed: %s", err.Error(), logger.NewORGPrefix(v.orgID))
+	}
+	v.sessionID = ""
+}
+
+func (v *VSphere) get(path string) (*simplejson.Json, error) {
+	url := v.config.URL + path
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set(SESSION_HEADER, v.sessionID)
+	respBody, err := v.do(req)
+	if err != nil {
+		return nil, err
+	}
+	jsonResp, err := simplejson.NewJson(respBody)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("JSONiz failed: %s", err.Error()))
+	}
+	return jsonResp, nil
+}
+
+// getRawData 获取列表接口返回的数据，vSphere Automation API 的列表接口不分页，直接返回 JSON 数组
+func (v *VSphere) getRawData(path, resultKey string) ([]*simplejson.Json, error) {
+	statsdAPIStartTime := time.Now()
+	jData, err := v.get(path)
+	if err != nil {
+		return nil, err
+	}
+	var jsonList []*simplejson.Json
+	for i := range jData.MustArray() {
+		jsonList = append(jsonList, jData.GetIndex(i))
+	}
+	v.cloudStatsd.RefreshAPIMoniter(resultKey, len(jsonList), statsdAPIStartTime)
+
+	v.debugger.WriteJson(resultKey, v.config.URL+path, jsonList)
+	return jsonList, nil
+}
+
+func (v *VSphere) do(req *http.Request) ([]byte, error) {
+	url := req.URL.String()
+	log.Debugf("url: %s", url, logger.NewORGPrefix(v.orgID))
+	req.Header.Set("Accept", "application/json")
+
+	client := cloudcommon.GetUnverifyHTTPClient(time.Second * time.Duration(v.httpTimeout))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("failed: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newErr(url, fmt.Sprintf("read failed: %s", err.Error()))
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, newErr(url, fmt.Sprintf("failed: status code (%d), body (%s)", resp.StatusCode, bytes.TrimSpace(respBody)))
+	}
+	return respBody, nil
+}
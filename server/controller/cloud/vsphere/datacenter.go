@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"sort"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getDatacenters 每个数据中心对应一个区域和一个可用区，以及标准交换机端口组所在的 VPC
+func (v *VSphere) getDatacenters() ([]model.Region, []model.AZ, error) {
+	jDatacenters, err := v.getRawData("/api/vcenter/datacenter", "datacenters")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var regions []model.Region
+	var azs []model.AZ
+	for _, jDatacenter := range jDatacenters {
+		if !cloudcommon.CheckJsonAttributes(jDatacenter, []string{"datacenter", "name"}) {
+			continue
+		}
+		id := jDatacenter.Get("datacenter").MustString()
+		name := jDatacenter.Get("name").MustString()
+		// 区域白名单，如果当前数据中心不在白名单中，则跳过
+		if _, ok := v.config.IncludeRegions[name]; !ok {
+			log.Infof("datacenter (%s) not in include_regions", name, logger.NewORGPrefix(v.orgID))
+			continue
+		}
+		// 区域黑名单，如果当前数据中心在黑名单中，则跳过
+		if _, ok := v.config.ExcludeRegions[name]; ok {
+			log.Infof("datacenter (%s) in exclude_regions", name, logger.NewORGPrefix(v.orgID))
+			continue
+		}
+
+		dc := &datacenter{
+			id:           id,
+			name:         name,
+			regionLcuuid: v.config.RegionLcuuid,
+			azLcuuid:     common.GenerateUUIDByOrgID(v.orgID, id+"_az_"+v.lcuuidGenerate),
+			vpcLcuuid:    common.GenerateUUIDByOrgID(v.orgID, id+"_vpc_"+v.lcuuidGenerate),
+		}
+		if dc.regionLcuuid == "" {
+			dc.regionLcuuid = common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate)
+			regions = append(regions, model.Region{
+				Lcuuid: dc.regionLcuuid,
+				Label:  id,
+				Name:   name,
+			})
+		}
+		azs = append(azs, model.AZ{
+			Lcuuid:       dc.azLcuuid,
+			Label:        id,
+			Name:         name,
+			RegionLcuuid: dc.regionLcuuid,
+		})
+		v.toolDataSet.datacenters = append(v.toolDataSet.datacenters, dc)
+	}
+	return regions, azs, nil
+}
+
+// getVPCLcuuid 标准交换机和 NSX 的端口组使用数据中心的 VPC，分布式端口组使用其所属分布式交换机的 VPC
+func (v *VSphere) getVPCLcuuid(dc *datacenter, pg *portGroup) string {
+	if pg == nil || pg.dvsUUID == "" {
+		if _, ok := v.toolDataSet.vpcs[dc.vpcLcuuid]; !ok {
+			v.toolDataSet.vpcs[dc.vpcLcuuid] = model.VPC{
+				Lcuuid:       dc.vpcLcuuid,
+				Name:         dc.name,
+				Label:        dc.id,
+				RegionLcuuid: dc.regionLcuuid,
+			}
+		}
+		return dc.vpcLcuuid
+	}
+	lcuuid := common.GenerateUUIDByOrgID(v.orgID, pg.dvsUUID+"_"+v.lcuuidGenerate)
+	if _, ok := v.toolDataSet.vpcs[lcuuid]; !ok {
+		v.toolDataSet.vpcs[lcuuid] = model.VPC{
+			Lcuuid:       lcuuid,
+			Name:         pg.dvsUUID,
+			Label:        pg.dvsUUID,
+			RegionLcuuid: dc.regionLcuuid,
+		}
+	}
+	return lcuuid
+}
+
+func (v *VSphere) getVPCs() []model.VPC {
+	var vpcs []model.VPC
+	for _, vpc := range v.toolDataSet.vpcs {
+		vpcs = append(vpcs, vpc)
+	}
+	sort.Slice(vpcs, func(i, j int) bool { return vpcs[i].Lcuuid < vpcs[j].Lcuuid })
+	return vpcs
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"net"
+	"net/url"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getHosts 获取数据中心中的 ESXi 主机，主机名称不是 IP 时通过 DNS 或文件解析主机 IP
+func (v *VSphere) getHosts(dc *datacenter) ([]model.Host, error) {
+	jHosts, err := v.getRawData("/api/vcenter/host?datacenters="+url.QueryEscape(dc.id), "hosts")
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []model.Host
+	for _, jHost := range jHosts {
+		id := jHost.Get("host").MustString()
+		if !cloudcommon.CheckJsonAttributes(jHost, []string{"host", "name"}) {
+			log.Infof("exclude host: %s, missing attr", id, logger.NewORGPrefix(v.orgID))
+			continue
+		}
+		name := jHost.Get("name").MustString()
+		ip := name
+		if net.ParseIP(name) == nil {
+			ip, err = cloudcommon.GetHostIPByName(name)
+			if err != nil {
+				log.Warningf("exclude host: %s, get ip by name (%s) failed: %s", id, name, err.Error(), logger.NewORGPrefix(v.orgID))
+				continue
+			}
+		}
+		hosts = append(hosts, model.Host{
+			Lcuuid:       common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate),
+			Name:         name,
+			IP:           ip,
+			Hostname:     name,
+			Type:         common.HOST_TYPE_VM,
+			HType:        common.HOST_HTYPE_ESXI,
+			AZLcuuid:     dc.azLcuuid,
+			RegionLcuuid: dc.regionLcuuid,
+		})
+		dc.hostIDs = append(dc.hostIDs, id)
+		v.toolDataSet.hostIDToIP[id] = ip
+		v.toolDataSet.azLcuuidToResourceNum[dc.azLcuuid]++
+		v.toolDataSet.regionLcuuidToResourceNum[dc.regionLcuuid]++
+	}
+	return hosts, nil
+}
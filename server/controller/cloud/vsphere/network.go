@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const (
+	PORT_GROUP_TYPE_STANDARD    = "STANDARD_PORTGROUP"
+	PORT_GROUP_TYPE_DISTRIBUTED = "DISTRIBUTED_PORTGROUP"
+	PORT_GROUP_TYPE_OPAQUE      = "OPAQUE_NETWORK"
+)
+
+// getPortGroups 获取数据中心中的端口组，端口组在获取虚拟机后才转换为网络
+func (v *VSphere) getPortGroups(dc *datacenter) error {
+	jNetworks, err := v.getRawData("/api/vcenter/network?datacenters="+url.QueryEscape(dc.id), "networks")
+	if err != nil {
+		return err
+	}
+	for _, jNetwork := range jNetworks {
+		id := jNetwork.Get("network").MustString()
+		if !cloudcommon.CheckJsonAttributes(jNetwork, []string{"network", "name", "type"}) {
+			log.Infof("exclude network: %s, missing attr", id, logger.NewORGPrefix(v.orgID))
+			continue
+		}
+		pg := &portGroup{
+			id:     id,
+			name:   jNetwork.Get("name").MustString(),
+			pgType: jNetwork.Get("type").MustString(),
+		}
+		dc.portGroups = append(dc.portGroups, pg)
+		v.toolDataSet.networkIDToPortGroup[id] = pg
+	}
+	return nil
+}
+
+// getNetworks 将端口组转换为网络，所属分布式交换机未知（没有虚拟机网卡接入）的分布式端口组会被忽略
+func (v *VSphere) getNetworks(dc *datacenter) []model.Network {
+	var networks []model.Network
+	for _, pg := range dc.portGroups {
+		if pg.pgType == PORT_GROUP_TYPE_DISTRIBUTED && pg.dvsUUID == "" {
+			log.Infof("exclude network: %s, no vinterface connected", pg.id, logger.NewORGPrefix(v.orgID))
+			continue
+		}
+		networks = append(networks, model.Network{
+			Lcuuid:       v.getNetworkLcuuid(pg.id),
+			Name:         pg.name,
+			Label:        pg.id,
+			Shared:       pg.pgType != PORT_GROUP_TYPE_STANDARD,
+			NetType:      common.NETWORK_TYPE_LAN,
+			VPCLcuuid:    v.getVPCLcuuid(dc, pg),
+			AZLcuuid:     dc.azLcuuid,
+			RegionLcuuid: dc.regionLcuuid,
+		})
+	}
+	return networks
+}
+
+func (v *VSphere) getNetworkLcuuid(id string) string {
+	return common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate)
+}
+
+// getSubnetLcuuid vSphere 中没有子网，根据虚拟机 IP 的前缀长度生成网络中的子网
+func (v *VSphere) getSubnetLcuuid(networkLcuuid, vpcLcuuid, ip string, prefixLength int) (string, bool) {
+	_, ipNet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", ip, prefixLength))
+	if err != nil {
+		log.Infof("exclude ip: %s, invalid prefix length (%d)", ip, prefixLength, logger.NewORGPrefix(v.orgID))
+		return "", false
+	}
+	cidr := ipNet.String()
+	lcuuid := common.GenerateUUIDByOrgID(v.orgID, networkLcuuid+"_"+cidr)
+	if _, ok := v.toolDataSet.subnets[lcuuid]; !ok {
+		v.toolDataSet.subnets[lcuuid] = model.Subnet{
+			Lcuuid:        lcuuid,
+			Name:          cidr,
+			Label:         cidr,
+			CIDR:          cidr,
+			NetworkLcuuid: networkLcuuid,
+			VPCLcuuid:     vpcLcuuid,
+		}
+	}
+	return lcuuid, true
+}
+
+func (v *VSphere) getSubnets() []model.Subnet {
+	var subnets []model.Subnet
+	for _, subnet := range v.toolDataSet.subnets {
+		subnets = append(subnets, subnet)
+	}
+	sort.Slice(subnets, func(i, j int) bool { return subnets[i].Lcuuid < subnets[j].Lcuuid })
+	return subnets
+}
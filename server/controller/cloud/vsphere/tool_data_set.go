@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+)
+
+type ToolDataSet struct {
+	datacenters               []*datacenter
+	vpcs                      map[string]model.VPC
+	hostIDToIP                map[string]string
+	networkIDToPortGroup      map[string]*portGroup
+	subnets                   map[string]model.Subnet
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+type datacenter struct {
+	id           string
+	name         string
+	regionLcuuid string
+	azLcuuid     string
+	vpcLcuuid    string // 标准交换机端口组所在的 VPC
+	hostIDs      []string
+	portGroups   []*portGroup
+}
+
+type portGroup struct {
+	id      string
+	name    string
+	pgType  string
+	dvsUUID string // 分布式端口组所属的分布式交换机，从虚拟机网卡的 backing 中获取
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		vpcs:                      make(map[string]model.VPC),
+		hostIDToIP:                make(map[string]string),
+		networkIDToPortGroup:      make(map[string]*portGroup),
+		subnets:                   make(map[string]model.Subnet),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const POWER_STATE_ON = "POWERED_ON"
+
+var STATE_CONVERTION = map[string]int{
+	"POWERED_ON":  common.VM_STATE_RUNNING,
+	"POWERED_OFF": common.VM_STATE_STOPPED,
+	"SUSPENDED":   common.VM_STATE_STOPPED,
+}
+
+type guestIP struct {
+	ip           string
+	prefixLength int
+}
+
+// getVMs 按主机获取虚拟机，网卡和 MAC 从虚拟机详情中获取，IP 从 VMware Tools 上报的客户机网络信息中获取
+func (v *VSphere) getVMs(dc *datacenter) ([]model.VM, []model.VInterface, []model.IP, error) {
+	var vms []model.VM
+	var vinterfaces []model.VInterface
+	var ips []model.IP
+	for _, hostID := range dc.hostIDs {
+		jVMs, err := v.getRawData("/api/vcenter/vm?hosts="+url.QueryEscape(hostID), "vms")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, jVM := range jVMs {
+			id := jVM.Get("vm").MustString()
+			if !cloudcommon.CheckJsonAttributes(jVM, []string{"vm", "name", "power_state"}) {
+				log.Infof("exclude vm: %s, missing attr", id, logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			// 虚拟机可能在获取列表后被删除，获取详情失败时忽略该虚拟机
+			jDetail, err := v.get("/api/vcenter/vm/" + url.PathEscape(id))
+			if err != nil {
+				log.Warningf("exclude vm: %s, get detail failed: %s", id, err.Error(), logger.NewORGPrefix(v.orgID))
+				continue
+			}
+			powerState := jVM.Get("power_state").MustString()
+			state, ok := STATE_CONVERTION[powerState]
+			if !ok {
+				state = common.VM_STATE_EXCEPTION
+			}
+			vm := model.VM{
+				Lcuuid:       common.GenerateUUIDByOrgID(v.orgID, id+"_"+v.lcuuidGenerate),
+				Name:         jVM.Get("name").MustString(),
+				Label:        id,
+				HType:        common.VM_HTYPE_VM_C,
+				State:        state,
+				LaunchServer: v.toolDataSet.hostIDToIP[hostID],
+				AZLcuuid:     dc.azLcuuid,
+				RegionLcuuid: dc.regionLcuuid,
+			}
+			macToGuestIPs := make(map[string][]guestIP)
+			if powerState == POWER_STATE_ON {
+				vm.Hostname, vm.IP = v.getGuestIdentity(id)
+				macToGuestIPs = v.getGuestIPs(id)
+			}
+
+			jNICs := jDetail.Get("nics")
+			var nicKeys []string
+			for key := range jNICs.MustMap() {
+				nicKeys = append(nicKeys, key)
+			}
+			sort.Strings(nicKeys)
+			for _, key := range nicKeys {
+				vinterface, vifIPs, ok := v.getVInterface(dc, vm.Lcuuid, id+"_"+key, jNICs.Get(key), macToGuestIPs)
+				if !ok {
+					continue
+				}
+				if vm.VPCLcuuid == "" {
+					vm.VPCLcuuid = vinterface.VPCLcuuid
+				}
+				vinterfaces = append(vinterfaces, vinterface)
+				ips = append(ips, vifIPs...)
+			}
+			if vm.VPCLcuuid == "" {
+				vm.VPCLcuuid = v.getVPCLcuuid(dc, nil)
+			}
+			vms = append(vms, vm)
+			v.toolDataSet.azLcuuidToResourceNum[dc.azLcuuid]++
+			v.toolDataSet.regionLcuuidToResourceNum[dc.regionLcuuid]++
+		}
+	}
+	return vms, vinterfaces, ips, nil
+}
+
+// getVInterface 根据网卡 backing 中的网络生成虚拟机网卡，分布式端口组的 backing 中包含其所属分布式交换机
+func (v *VSphere) getVInterface(dc *datacenter, vmLcuuid, nicID string, jNIC *simplejson.Json, macToGuestIPs map[string][]guestIP) (model.VInterface, []model.IP, bool) {
+	mac := strings.ToLower(jNIC.Get("mac_address").MustString())
+	if mac == "" {
+		log.Infof("exclude vinterface: %s, missing mac", nicID, logger.NewORGPrefix(v.orgID))
+		return model.VInterface{}, nil, false
+	}
+	jBacking := jNIC.Get("backing")
+	pg, ok := v.toolDataSet.networkIDToPortGroup[jBacking.Get("network").MustString()]
+	if !ok {
+		log.Infof("exclude vinterface: %s, network not found", nicID, logger.NewORGPrefix(v.orgID))
+		return model.VInterface{}, nil, false
+	}
+	if dvsUUID := jBacking.Get("distributed_switch_uuid").MustString(); dvsUUID != "" {
+		pg.dvsUUID = dvsUUID
+	}
+	vinterface := model.VInterface{
+		Lcuuid:        common.GenerateUUIDByOrgID(v.orgID, nicID+"_"+v.lcuuidGenerate),
+		Name:          jNIC.Get("label").MustString(),
+		Type:          common.VIF_TYPE_LAN,
+		Mac:           mac,
+		DeviceLcuuid:  vmLcuuid,
+		DeviceType:    common.VIF_DEVICE_TYPE_VM,
+		NetworkLcuuid: v.getNetworkLcuuid(pg.id),
+		VPCLcuuid:     v.getVPCLcuuid(dc, pg),
+		RegionLcuuid:  dc.regionLcuuid,
+	}
+
+	var ips []model.IP
+	for _, gIP := range macToGuestIPs[mac] {
+		subnetLcuuid, ok := v.getSubnetLcuuid(vinterface.NetworkLcuuid, vinterface.VPCLcuuid, gIP.ip, gIP.prefixLength)
+		if !ok {
+			continue
+		}
+		ips = append(ips, model.IP{
+			Lcuuid:           common.GenerateUUIDByOrgID(v.orgID, vinterface.Lcuuid+"_"+gIP.ip),
+			VInterfaceLcuuid: vinterface.Lcuuid,
+			IP:               gIP.ip,
+			SubnetLcuuid:     subnetLcuuid,
+			RegionLcuuid:     dc.regionLcuuid,
+		})
+	}
+	return vinterface, ips, true
+}
+
+// getGuestIdentity 获取客户机的主机名和主 IP，VMware Tools 未运行时接口返回错误，此时忽略
+func (v *VSphere) getGuestIdentity(vmID string) (string, string) {
+	jIdentity, err := v.get("/api/vcenter/vm/" + url.PathEscape(vmID) + "/guest/identity")
+	if err != nil {
+		log.Debugf("get guest identity of vm (%s) failed: %s", vmID, err.Error(), logger.NewORGPrefix(v.orgID))
+		return "", ""
+	}
+	return jIdentity.Get("host_name").MustString(), jIdentity.Get("ip_address").MustString()
+}
+
+// getGuestIPs 获取客户机网卡的 IP，忽略链路本地地址
+func (v *VSphere) getGuestIPs(vmID string) map[string][]guestIP {
+	macToGuestIPs := make(map[string][]guestIP)
+	jInterfaces, err := v.get("/api/vcenter/vm/" + url.PathEscape(vmID) + "/guest/networking/interfaces")
+	if err != nil {
+		log.Debugf("get guest interfaces of vm (%s) failed: %s", vmID, err.Error(), logger.NewORGPrefix(v.orgID))
+		return macToGuestIPs
+	}
+	for i := range jInterfaces.MustArray() {
+		jInterface := jInterfaces.GetIndex(i)
+		mac := strings.ToLower(jInterface.Get("mac_address").MustString())
+		jIPs := jInterface.Get("ip").Get("ip_addresses")
+		for j := range jIPs.MustArray() {
+			jIP := jIPs.GetIndex(j)
+			ip := jIP.Get("ip_address").MustString()
+			parsedIP := net.ParseIP(ip)
+			if parsedIP == nil || parsedIP.IsLinkLocalUnicast() {
+				continue
+			}
+			macToGuestIPs[mac] = append(macToGuestIPs[mac], guestIP{ip: ip, prefixLength: jIP.Get("prefix_length").MustInt()})
+		}
+	}
+	return macToGuestIPs
+}
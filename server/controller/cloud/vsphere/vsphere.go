@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.vsphere")
+
+type VSphere struct {
+	orgID          int
+	teamID         int
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	sessionID      string
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewVSphere(orgID int, domain mysqlmodel.Domain, globalCloudCfg config.CloudConfig) (*VSphere, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(orgID, domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &VSphere{
+		orgID:  orgID,
+		teamID: domain.TeamID,
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}, nil
+}
+
+func (v *VSphere) ClearDebugLog() {
+	v.debugger.Clear()
+}
+
+func (v *VSphere) CheckAuth() error {
+	if err := v.createSession(); err != nil {
+		return err
+	}
+	v.deleteSession()
+	return nil
+}
+
+func (v *VSphere) GetCloudData() (model.Resource, error) {
+	v.cloudStatsd = statsd.NewCloudStatsd()
+	v.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+	if err := v.createSession(); err != nil {
+		return resource, err
+	}
+	defer v.deleteSession()
+
+	regions, azs, err := v.getDatacenters()
+	if err != nil {
+		return resource, err
+	}
+	for _, dc := range v.toolDataSet.datacenters {
+		hosts, err := v.getHosts(dc)
+		if err != nil {
+			return resource, err
+		}
+		resource.Hosts = append(resource.Hosts, hosts...)
+
+		// 端口组所属的分布式交换机只能从虚拟机网卡的 backing 中获取，因此先获取虚拟机再生成网络
+		if err := v.getPortGroups(dc); err != nil {
+			return resource, err
+		}
+		vms, vinterfaces, ips, err := v.getVMs(dc)
+		if err != nil {
+			return resource, err
+		}
+		resource.VMs = append(resource.VMs, vms...)
+		resource.VInterfaces = append(resource.VInterfaces, vinterfaces...)
+		resource.IPs = append(resource.IPs, ips...)
+		resource.Networks = append(resource.Networks, v.getNetworks(dc)...)
+	}
+	resource.Subnets = v.getSubnets()
+	resource.VPCs = v.getVPCs()
+
+	log.Debugf("region resource num info: %v", v.toolDataSet.regionLcuuidToResourceNum, logger.NewORGPrefix(v.orgID))
+	log.Debugf("az resource num info: %v", v.toolDataSet.azLcuuidToResourceNum, logger.NewORGPrefix(v.orgID))
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, v.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(azs, v.toolDataSet.azLcuuidToResourceNum)
+
+	v.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(v)
+
+	v.debugger.Refresh()
+	return resource, nil
+}
+
+func (v *VSphere) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": v.name,
+		"domain":      v.lcuuid,
+		"platform":    common.VSPHERE_EN,
+	}
+
+	return statsd.StatsdStatter{
+		OrgID:      v.orgID,
+		TeamID:     v.teamID,
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(v.cloudStatsd),
+	}
+}
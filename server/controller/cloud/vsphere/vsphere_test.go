@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vsphere
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+)
+
+const testSessionID = "test-session"
+
+// the responses of the vSphere Automation API, the key is the request uri
+var testResponseFiles = map[string]string{
+	"/api/vcenter/datacenter":                            "datacenters.json",
+	"/api/vcenter/host?datacenters=datacenter-1":         "hosts.json",
+	"/api/vcenter/network?datacenters=datacenter-1":      "networks.json",
+	"/api/vcenter/vm?hosts=host-10":                      "vms_host-10.json",
+	"/api/vcenter/vm?hosts=host-11":                      "vms_host-11.json",
+	"/api/vcenter/vm/vm-100":                             "vm-100.json",
+	"/api/vcenter/vm/vm-101":                             "vm-101.json",
+	"/api/vcenter/vm/vm-102":                             "vm-102.json",
+	"/api/vcenter/vm/vm-100/guest/identity":              "vm-100_identity.json",
+	"/api/vcenter/vm/vm-100/guest/networking/interfaces": "vm-100_interfaces.json",
+	"/api/vcenter/vm/vm-102/guest/networking/interfaces": "vm-102_interfaces.json",
+}
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/session" {
+			switch r.Method {
+			case http.MethodPost:
+				if user, password, ok := r.BasicAuth(); !ok || user != "administrator@vsphere.local" || password != "password" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`"` + testSessionID + `"`))
+			case http.MethodDelete:
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+		if r.Header.Get(SESSION_HEADER) != testSessionID {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		file, ok := testResponseFiles[r.URL.RequestURI()]
+		if !ok {
+			// the guest apis return 503 if the VMware Tools is not running
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, err := os.ReadFile("testfiles/" + file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func newTestVSphere(url, password string) *VSphere {
+	return &VSphere{
+		orgID:          mysqlcommon.DEFAULT_ORG_ID,
+		lcuuidGenerate: "test_vsphere",
+		name:           "test_vsphere",
+		httpTimeout:    30,
+		config: &Config{
+			URL:            url,
+			UserName:       "administrator@vsphere.local",
+			Password:       password,
+			IncludeRegions: map[string]bool{"DC1": false},
+		},
+		debugger: cloudcommon.NewDebugger("test_vsphere"),
+	}
+}
+
+func TestVSphere(t *testing.T) {
+	Convey("TestVSphere", t, func() {
+		server := newTestServer()
+		defer server.Close()
+
+		Convey("check auth with wrong password should fail", func() {
+			So(newTestVSphere(server.URL, "wrong").CheckAuth(), ShouldNotBeNil)
+			So(newTestVSphere(server.URL, "password").CheckAuth(), ShouldBeNil)
+		})
+
+		vsphere := newTestVSphere(server.URL, "password")
+		data, err := vsphere.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("vsphereResource number should be equal", func() {
+			So(len(data.Regions), ShouldEqual, 1)
+			So(len(data.AZs), ShouldEqual, 1)
+			So(len(data.Hosts), ShouldEqual, 2)
+			So(len(data.VMs), ShouldEqual, 3)
+			So(len(data.VPCs), ShouldEqual, 2)
+			So(len(data.Networks), ShouldEqual, 2)
+			So(len(data.Subnets), ShouldEqual, 2)
+			So(len(data.VInterfaces), ShouldEqual, 4)
+			So(len(data.IPs), ShouldEqual, 3)
+		})
+
+		Convey("vm should be in the vpc of the dvswitch of its first nic", func() {
+			vm := data.VMs[0]
+			So(vm.Name, ShouldEqual, "app-1")
+			So(vm.Hostname, ShouldEqual, "app-1.example.com")
+			So(vm.IP, ShouldEqual, "192.168.10.10")
+			So(vm.LaunchServer, ShouldEqual, "10.0.0.11")
+			So(vm.State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(vm.VPCLcuuid, ShouldEqual, data.VInterfaces[0].VPCLcuuid)
+			So(vm.VPCLcuuid, ShouldNotEqual, data.VInterfaces[1].VPCLcuuid)
+			So(data.VInterfaces[0].Mac, ShouldEqual, "00:50:56:aa:00:01")
+			So(data.VMs[1].State, ShouldEqual, common.VM_STATE_STOPPED)
+		})
+	})
+}
@@ -297,6 +297,7 @@ const (
 	SUGON             = 29
 	VOLCENGINE        = 30
 	H3C               = 31
+	GCP               = 32
 
 	OPENSTACK_EN         = "openstack"
 	VSPHERE_EN           = "vsphere"
@@ -328,6 +329,7 @@ const (
 	SUGON_EN             = "sugon"
 	VOLCENGINE_EN        = "volcengine"
 	H3C_EN               = "h3c"
+	GCP_EN               = "gcp"
 
 	TENCENT_CH          = "腾讯云"
 	ALIYUN_CH           = "阿里云"
@@ -341,6 +343,7 @@ const (
 	SUGON_CH            = "曙光云"
 	VOLCENGINE_CH       = "火山云"
 	H3C_CH              = "华三云"
+	GCP_CH              = "谷歌云"
 
 	OPENSTACK_CH   = "OpenStack"
 	VSPHERE_CH     = "vSphere"
@@ -370,6 +373,7 @@ var IconNameToDomainTypes = map[string][]int{
 	KINGSOFT_PRIVATE_CH: {KINGSOFT_PRIVATE},
 	BAIDU_BCE_CH:        {BAIDU_BCE},
 	VOLCENGINE_CH:       {VOLCENGINE},
+	GCP_CH:              {GCP},
 }
 
 const (
@@ -42,6 +42,7 @@ import (
 	"github.com/deepflowio/deepflow/server/controller/monitor"
 	"github.com/deepflowio/deepflow/server/controller/prometheus"
 	"github.com/deepflowio/deepflow/server/controller/recorder"
+	"github.com/deepflowio/deepflow/server/controller/recorder/outbound"
 	"github.com/deepflowio/deepflow/server/controller/report"
 	"github.com/deepflowio/deepflow/server/controller/statsd"
 	"github.com/deepflowio/deepflow/server/controller/tagrecorder"
@@ -136,6 +137,9 @@ func Start(ctx context.Context, configPath, serverLogFile string, shared *server
 		os.Exit(0)
 	}
 
+	router.SetInitStageForHealthChecker("Recorder outbound init")
+	outbound.GetSingleton().Init(ctx, cfg.ManagerCfg.TaskCfg.RecorderCfg.Outbound).Start()
+
 	router.SetInitStageForHealthChecker("Manager init")
 	// 启动resource manager
 	// 每个云平台启动一个cloud和recorder
@@ -55,6 +55,9 @@ func GetAcquireTime() int64 { // 提供给 trisolairs 使用，确保各个 serv
 
 const (
 	ID_ITEM_NUM = 4
+
+	ELECTION_BACKEND_KUBERNETES = "kubernetes"
+	ELECTION_BACKEND_MYSQL      = "mysql"
 )
 
 type LeaderData struct {
@@ -173,6 +176,21 @@ func checkLeaderValid(ctx context.Context, lock *resourcelock.LeaseLock) { // se
 }
 
 func Start(ctx context.Context, cfg *config.ControllerConfig) {
+	switch cfg.ElectionBackend {
+	case ELECTION_BACKEND_MYSQL:
+		store, err := NewMySQLLeaseStore(cfg.MySqlCfg, cfg.ElectionName)
+		if err != nil {
+			log.Errorf("failed to create mysql lease store: %v", err)
+			time.Sleep(1 * time.Second)
+			os.Exit(1)
+		}
+		startLeaseElection(ctx, store)
+	default:
+		startKubernetesElection(ctx, cfg)
+	}
+}
+
+func startKubernetesElection(ctx context.Context, cfg *config.ControllerConfig) {
 	kubeconfig := cfg.Kubeconfig
 	electionName := cfg.ElectionName
 	electionNamespace := common.GetNameSpace()
@@ -213,9 +231,9 @@ func Start(ctx context.Context, cfg *config.ControllerConfig) {
 		// get elected before your background loop finished, violating
 		// the stated goal of the lease.
 		ReleaseOnCancel: true,
-		LeaseDuration:   15 * time.Second,
-		RenewDeadline:   10 * time.Second,
-		RetryPeriod:     2 * time.Second,
+		LeaseDuration:   LEASE_DURATION,
+		RenewDeadline:   RENEW_DEADLINE,
+		RetryPeriod:     RETRY_PERIOD,
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
 				// we're notified when we start - this is where you would
@@ -0,0 +1,371 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/utils"
+)
+
+const (
+	LEASE_DURATION = 15 * time.Second
+	RENEW_DEADLINE = 10 * time.Second
+	RETRY_PERIOD   = 2 * time.Second
+)
+
+// ErrLeaseConflict is returned by a LeaseStore when the lease has been changed by another controller
+var ErrLeaseConflict = errors.New("lease has been changed by another controller")
+
+// LeaseRecord is the lock shared by all controllers through a LeaseStore
+type LeaseRecord struct {
+	HolderIdentity string
+	AcquireTime    time.Time
+	RenewTime      time.Time
+	LeaseDuration  time.Duration
+	// FencingToken increases by one every time the lease changes hands, so the
+	// writes of a deposed leader can be told apart from the ones of the current leader
+	FencingToken uint64
+	// Revision increases by one on every write, it is used for compare-and-swap
+	Revision uint64
+}
+
+// LeaseStore stores the lease of an election outside Kubernetes
+type LeaseStore interface {
+	// Get returns nil if the lease has never been created
+	Get(ctx context.Context) (*LeaseRecord, error)
+	// Create returns ErrLeaseConflict if the lease already exists
+	Create(ctx context.Context, record *LeaseRecord) error
+	// Update returns ErrLeaseConflict if the revision of the lease is no longer old.Revision
+	Update(ctx context.Context, old, record *LeaseRecord) error
+}
+
+type LeaseCallbacks struct {
+	OnStartedLeading func(ctx context.Context)
+	OnStoppedLeading func()
+	// OnNewLeader is called when a live holder of the lease is seen for the
+	// first time or the holder changes
+	OnNewLeader func(record LeaseRecord)
+}
+
+// LeaseElector works like the leader elector of client-go: a controller takes
+// the lease when it is free or has not been renewed for LeaseDuration, and
+// steps down when it fails to renew the lease within RenewDeadline, which is
+// shorter than LeaseDuration, so that it stops leading before anyone else can
+// take over the lease.
+type LeaseElector struct {
+	store         LeaseStore
+	identity      string
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+	callbacks     LeaseCallbacks
+	clock         func() time.Time
+
+	mutex sync.RWMutex
+	// the expiry of a lease held by others is judged by the local time when
+	// its latest revision was observed, so clock skew does not matter
+	observedRecord *LeaseRecord
+	observedTime   time.Time
+	reportedHolder string
+	// fencingToken is not 0 only when this controller is leading and the
+	// lease has been renewed before leadingDeadline
+	fencingToken    uint64
+	leadingDeadline time.Time
+}
+
+func NewLeaseElector(store LeaseStore, identity string, leaseDuration, renewDeadline, retryPeriod time.Duration, callbacks LeaseCallbacks) (*LeaseElector, error) {
+	if leaseDuration <= renewDeadline {
+		return nil, fmt.Errorf("lease duration (%v) must be greater than renew deadline (%v)", leaseDuration, renewDeadline)
+	}
+	if renewDeadline <= retryPeriod {
+		return nil, fmt.Errorf("renew deadline (%v) must be greater than retry period (%v)", renewDeadline, retryPeriod)
+	}
+	if identity == "" {
+		return nil, errors.New("identity is empty")
+	}
+	return &LeaseElector{
+		store:         store,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		renewDeadline: renewDeadline,
+		retryPeriod:   retryPeriod,
+		callbacks:     callbacks,
+		clock:         time.Now,
+	}, nil
+}
+
+// IsLeader returns whether this controller holds a lease renewed within RenewDeadline
+func (e *LeaseElector) IsLeader() bool {
+	return e.GetFencingToken() != 0
+}
+
+// GetFencingToken returns the fencing token of the lease held by this controller, or 0 if it is not the leader
+func (e *LeaseElector) GetFencingToken() uint64 {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.fencingToken == 0 || !e.clock().Before(e.leadingDeadline) {
+		return 0
+	}
+	return e.fencingToken
+}
+
+// GetHolder returns the holder of the lease last observed
+func (e *LeaseElector) GetHolder() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.observedRecord == nil {
+		return ""
+	}
+	return e.observedRecord.HolderIdentity
+}
+
+// Run blocks until the lease is acquired, then renews it until the lease is
+// lost or ctx is done. The lease is released when ctx is done.
+func (e *LeaseElector) Run(ctx context.Context) {
+	defer func() {
+		if e.callbacks.OnStoppedLeading != nil {
+			e.callbacks.OnStoppedLeading()
+		}
+	}()
+	if !e.acquire(ctx) {
+		return
+	}
+	leadingCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if e.callbacks.OnStartedLeading != nil {
+		go e.callbacks.OnStartedLeading(leadingCtx)
+	}
+	e.renew(ctx)
+}
+
+func (e *LeaseElector) acquire(ctx context.Context) bool {
+	for {
+		if e.tryAcquireOrRenew(ctx) {
+			log.Infof("%s acquired the lease", e.identity)
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(e.retryPeriod):
+		}
+	}
+}
+
+func (e *LeaseElector) renew(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-time.After(e.retryPeriod):
+		}
+		if e.tryAcquireOrRenew(ctx) {
+			continue
+		}
+		if !e.IsLeader() {
+			log.Infof("%s failed to renew the lease, holder is %s", e.identity, e.GetHolder())
+			e.stepDown()
+			return
+		}
+	}
+}
+
+func (e *LeaseElector) tryAcquireOrRenew(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, e.renewDeadline)
+	defer cancel()
+
+	now := e.clock()
+	old, err := e.store.Get(ctx)
+	if err != nil {
+		log.Errorf("get lease failed: %v", err)
+		return false
+	}
+	if old == nil {
+		record := &LeaseRecord{
+			HolderIdentity: e.identity,
+			AcquireTime:    now,
+			RenewTime:      now,
+			LeaseDuration:  e.leaseDuration,
+			FencingToken:   1,
+			Revision:       1,
+		}
+		if err := e.store.Create(ctx, record); err != nil {
+			if err != ErrLeaseConflict {
+				log.Errorf("create lease failed: %v", err)
+			}
+			return false
+		}
+		e.observe(record, now, true)
+		return true
+	}
+
+	e.observe(old, now, false)
+	if old.HolderIdentity != e.identity && old.HolderIdentity != "" && now.Before(e.getObservedTime().Add(old.LeaseDuration)) {
+		// another live controller holds the lease
+		e.stepDown()
+		return false
+	}
+
+	record := *old
+	record.RenewTime = now
+	record.LeaseDuration = e.leaseDuration
+	record.Revision = old.Revision + 1
+	if old.HolderIdentity != e.identity {
+		record.HolderIdentity = e.identity
+		record.AcquireTime = now
+		record.FencingToken = old.FencingToken + 1
+	}
+	if err := e.store.Update(ctx, old, &record); err != nil {
+		if err != ErrLeaseConflict {
+			log.Errorf("update lease failed: %v", err)
+		}
+		return false
+	}
+	e.observe(&record, now, true)
+	return true
+}
+
+// release gives up the lease so that other controllers can take it over without waiting for it to expire
+func (e *LeaseElector) release() {
+	defer e.stepDown()
+	e.mutex.RLock()
+	old := e.observedRecord
+	e.mutex.RUnlock()
+	if old == nil || old.HolderIdentity != e.identity {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.retryPeriod)
+	defer cancel()
+	record := *old
+	record.HolderIdentity = ""
+	record.RenewTime = e.clock()
+	record.Revision = old.Revision + 1
+	if err := e.store.Update(ctx, old, &record); err != nil {
+		log.Errorf("release lease failed: %v", err)
+		return
+	}
+	e.mutex.Lock()
+	e.observedRecord = &record
+	e.mutex.Unlock()
+	log.Infof("%s released the lease", e.identity)
+}
+
+func (e *LeaseElector) stepDown() {
+	e.mutex.Lock()
+	e.fencingToken = 0
+	e.mutex.Unlock()
+}
+
+func (e *LeaseElector) getObservedTime() time.Time {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.observedTime
+}
+
+// observe records the lease read from or written to the store. A holder is
+// live if this controller has just written the lease or has seen the lease
+// renewed.
+func (e *LeaseElector) observe(record *LeaseRecord, now time.Time, written bool) {
+	e.mutex.Lock()
+	changed := e.observedRecord == nil || e.observedRecord.Revision != record.Revision
+	live := written || (e.observedRecord != nil && changed)
+	if changed {
+		e.observedRecord = record
+		e.observedTime = now
+	}
+	if written {
+		e.fencingToken = record.FencingToken
+		e.leadingDeadline = now.Add(e.renewDeadline)
+	}
+	report := live && record.HolderIdentity != "" && record.HolderIdentity != e.reportedHolder
+	if report {
+		e.reportedHolder = record.HolderIdentity
+	}
+	e.mutex.Unlock()
+
+	if report && e.callbacks.OnNewLeader != nil {
+		e.callbacks.OnNewLeader(*record)
+	}
+}
+
+var leaseElector atomic.Value // *LeaseElector
+
+// GetFencingToken returns the fencing token of the lease held by this
+// controller, or 0 if it is not the leader. Only the lease backends of the
+// election support fencing tokens.
+func GetFencingToken() uint64 {
+	if e, ok := leaseElector.Load().(*LeaseElector); ok {
+		return e.GetFencingToken()
+	}
+	return 0
+}
+
+func startLeaseElection(ctx context.Context, store LeaseStore) {
+	id := getID()
+	log.Infof("election id is %s", id)
+
+	var le *LeaseElector
+	le, err := NewLeaseElector(store, id, LEASE_DURATION, RENEW_DEADLINE, RETRY_PERIOD, LeaseCallbacks{
+		OnStartedLeading: func(ctx context.Context) {
+			log.Infof("%s is the leader", id)
+			leaderData.SetLeader(id)
+		},
+		OnStoppedLeading: func() {
+			log.Infof("leader lost: %s", id)
+			// the lease may still be recorded as ours after renewing failed,
+			// leave the leader empty until a live leader is seen
+			if holder := le.GetHolder(); holder != id {
+				leaderData.SetLeader(holder)
+			} else {
+				leaderData.SetLeader("")
+			}
+		},
+		OnNewLeader: func(record LeaseRecord) {
+			// as the kubernetes election, the acquire time is set once, when a
+			// live leader is seen for the first time
+			if !leaderData.getValide() {
+				acquireTime = record.AcquireTime.Unix()
+				leaderData.setValide()
+				log.Infof("check leader finish, leader is %s", record.HolderIdentity)
+			} else {
+				log.Infof("new leader elected: %s", record.HolderIdentity)
+			}
+			leaderData.SetLeader(record.HolderIdentity)
+		},
+	})
+	if err != nil {
+		log.Errorf("failed to create election: %v", err)
+		time.Sleep(1 * time.Second)
+		os.Exit(1)
+	}
+	leaseElector.Store(le)
+
+	wg := utils.GetWaitGroupInCtx(ctx)
+	wg.Add(1)
+	defer wg.Done()
+	wait.UntilWithContext(ctx, le.Run, 0)
+}
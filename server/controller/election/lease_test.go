@@ -0,0 +1,256 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryLeaseStore struct {
+	sync.Mutex
+	record *LeaseRecord
+	// afterGet is called after Get to simulate the writes of other controllers
+	afterGet func()
+}
+
+func (s *memoryLeaseStore) Get(ctx context.Context) (*LeaseRecord, error) {
+	s.Lock()
+	var record *LeaseRecord
+	if s.record != nil {
+		copied := *s.record
+		record = &copied
+	}
+	afterGet := s.afterGet
+	s.afterGet = nil
+	s.Unlock()
+	if afterGet != nil {
+		afterGet()
+	}
+	return record, nil
+}
+
+func (s *memoryLeaseStore) Create(ctx context.Context, record *LeaseRecord) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.record != nil {
+		return ErrLeaseConflict
+	}
+	copied := *record
+	s.record = &copied
+	return nil
+}
+
+func (s *memoryLeaseStore) Update(ctx context.Context, old, record *LeaseRecord) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.record == nil || s.record.Revision != old.Revision {
+		return ErrLeaseConflict
+	}
+	copied := *record
+	s.record = &copied
+	return nil
+}
+
+type fakeClock struct {
+	sync.Mutex
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) step(d time.Duration) {
+	c.Lock()
+	c.t = c.t.Add(d)
+	c.Unlock()
+}
+
+func newTestLeaseElector(t *testing.T, store LeaseStore, clock *fakeClock, identity string, leaders *[]string) *LeaseElector {
+	e, err := NewLeaseElector(store, identity, LEASE_DURATION, RENEW_DEADLINE, RETRY_PERIOD, LeaseCallbacks{
+		OnNewLeader: func(record LeaseRecord) {
+			if leaders != nil {
+				*leaders = append(*leaders, record.HolderIdentity)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.clock = clock.now
+	return e
+}
+
+func TestNewLeaseElector(t *testing.T) {
+	store := &memoryLeaseStore{}
+	if _, err := NewLeaseElector(store, "a", RENEW_DEADLINE, RENEW_DEADLINE, RETRY_PERIOD, LeaseCallbacks{}); err == nil {
+		t.Errorf("lease duration equal to renew deadline should be refused")
+	}
+	if _, err := NewLeaseElector(store, "a", LEASE_DURATION, RETRY_PERIOD, RETRY_PERIOD, LeaseCallbacks{}); err == nil {
+		t.Errorf("renew deadline equal to retry period should be refused")
+	}
+	if _, err := NewLeaseElector(store, "", LEASE_DURATION, RENEW_DEADLINE, RETRY_PERIOD, LeaseCallbacks{}); err == nil {
+		t.Errorf("empty identity should be refused")
+	}
+}
+
+func TestLeaseElectorAcquireAndRenew(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryLeaseStore{}
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	var leadersOfA, leadersOfB []string
+	a := newTestLeaseElector(t, store, clock, "a", &leadersOfA)
+	b := newTestLeaseElector(t, store, clock, "b", &leadersOfB)
+
+	if !a.tryAcquireOrRenew(ctx) {
+		t.Fatalf("a failed to acquire a new lease")
+	}
+	if token := a.GetFencingToken(); token != 1 {
+		t.Errorf("got fencing token %d, expected 1", token)
+	}
+	if b.tryAcquireOrRenew(ctx) || b.IsLeader() {
+		t.Errorf("b acquired the lease held by a")
+	}
+	// b has not seen the lease renewed yet, so a is not known as a live leader
+	if len(leadersOfB) != 0 {
+		t.Errorf("got leaders %v of b, expected none", leadersOfB)
+	}
+
+	clock.step(RETRY_PERIOD)
+	if !a.tryAcquireOrRenew(ctx) {
+		t.Fatalf("a failed to renew the lease")
+	}
+	clock.step(RETRY_PERIOD)
+	if b.tryAcquireOrRenew(ctx) {
+		t.Errorf("b acquired the lease renewed by a")
+	}
+	if len(leadersOfA) != 1 || leadersOfA[0] != "a" || len(leadersOfB) != 1 || leadersOfB[0] != "a" {
+		t.Errorf("got leaders %v of a and %v of b, expected [a]", leadersOfA, leadersOfB)
+	}
+	// renewing keeps the acquire time and the fencing token, which the versions of trisolaris depend on
+	if store.record.AcquireTime.Unix() != 1700000000 || store.record.FencingToken != 1 || store.record.Revision != 2 {
+		t.Errorf("got lease %+v after renewing", store.record)
+	}
+}
+
+func TestLeaseElectorFailover(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryLeaseStore{}
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	var leadersOfB []string
+	a := newTestLeaseElector(t, store, clock, "a", nil)
+	b := newTestLeaseElector(t, store, clock, "b", &leadersOfB)
+
+	if !a.tryAcquireOrRenew(ctx) {
+		t.Fatalf("a failed to acquire a new lease")
+	}
+	b.tryAcquireOrRenew(ctx)
+
+	// a hangs, it is no longer the leader after the renew deadline
+	clock.step(RENEW_DEADLINE)
+	if a.IsLeader() {
+		t.Errorf("a is still the leader after the renew deadline")
+	}
+	if b.tryAcquireOrRenew(ctx) {
+		t.Errorf("b acquired the lease before it expired")
+	}
+	clock.step(LEASE_DURATION - RENEW_DEADLINE)
+	if !b.tryAcquireOrRenew(ctx) {
+		t.Fatalf("b failed to acquire the expired lease")
+	}
+	if token := b.GetFencingToken(); token != 2 {
+		t.Errorf("got fencing token %d of b, expected 2", token)
+	}
+	if acquireTime := store.record.AcquireTime; !acquireTime.Equal(clock.now()) {
+		t.Errorf("got acquire time %v, expected %v", acquireTime, clock.now())
+	}
+	if len(leadersOfB) != 1 || leadersOfB[0] != "b" {
+		t.Errorf("got leaders %v of b, expected [b]", leadersOfB)
+	}
+
+	// a recovers, it can not renew the lease held by b
+	if a.tryAcquireOrRenew(ctx) || a.GetFencingToken() != 0 {
+		t.Errorf("a renewed the lease held by b")
+	}
+	if holder := a.GetHolder(); holder != "b" {
+		t.Errorf("got holder %s, expected b", holder)
+	}
+}
+
+func TestLeaseElectorConflict(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryLeaseStore{}
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	a := newTestLeaseElector(t, store, clock, "a", nil)
+	b := newTestLeaseElector(t, store, clock, "b", nil)
+
+	if !a.tryAcquireOrRenew(ctx) {
+		t.Fatalf("a failed to acquire a new lease")
+	}
+	b.tryAcquireOrRenew(ctx)
+	clock.step(LEASE_DURATION)
+
+	// a renews the lease between the read and the write of b
+	store.afterGet = func() {
+		if !a.tryAcquireOrRenew(ctx) {
+			t.Errorf("a failed to renew the lease")
+		}
+	}
+	if b.tryAcquireOrRenew(ctx) || b.IsLeader() {
+		t.Errorf("b overwrote the lease renewed by a")
+	}
+	if store.record.HolderIdentity != "a" || store.record.FencingToken != 1 {
+		t.Errorf("got lease %+v, expected to be held by a", store.record)
+	}
+}
+
+func TestLeaseElectorRelease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := &memoryLeaseStore{}
+	a, err := NewLeaseElector(store, "a", 300*time.Millisecond, 200*time.Millisecond, 20*time.Millisecond, LeaseCallbacks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(stopped)
+	}()
+	for !a.IsLeader() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-stopped
+	if a.IsLeader() || store.record.HolderIdentity != "" {
+		t.Fatalf("a did not release the lease, got %+v", store.record)
+	}
+
+	// the released lease is taken over without waiting for it to expire
+	clock := &fakeClock{t: time.Now()}
+	b := newTestLeaseElector(t, store, clock, "b", nil)
+	if !b.tryAcquireOrRenew(context.Background()) {
+		t.Fatalf("b failed to acquire the released lease")
+	}
+	if token := b.GetFencingToken(); token != 2 {
+		t.Errorf("got fencing token %d of b, expected 2", token)
+	}
+}
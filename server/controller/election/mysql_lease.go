@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+	mysqlcfg "github.com/deepflowio/deepflow/server/controller/db/mysql/config"
+)
+
+const (
+	// the lease is kept out of the deepflow database, because the election
+	// starts before the master controller creates and migrates that database
+	ELECTION_DATABASE_SUFFIX = "_election"
+	ELECTION_LEASE_TABLE     = "election_lease"
+
+	CREATE_TABLE_ELECTION_LEASE = `CREATE TABLE IF NOT EXISTS ` + ELECTION_LEASE_TABLE + ` (
+    name                VARCHAR(256) NOT NULL PRIMARY KEY,
+    holder_identity     VARCHAR(256) NOT NULL DEFAULT '',
+    acquire_time        BIGINT NOT NULL DEFAULT 0 COMMENT 'unit: ms',
+    renew_time          BIGINT NOT NULL DEFAULT 0 COMMENT 'unit: ms',
+    lease_duration      BIGINT NOT NULL DEFAULT 0 COMMENT 'unit: ms',
+    fencing_token       BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    revision            BIGINT UNSIGNED NOT NULL DEFAULT 0
+)ENGINE=innodb DEFAULT CHARSET=utf8 COMMENT='store the leases of controller election';`
+)
+
+type electionLease struct {
+	Name           string `gorm:"column:name;primaryKey"`
+	HolderIdentity string `gorm:"column:holder_identity"`
+	AcquireTime    int64  `gorm:"column:acquire_time"`
+	RenewTime      int64  `gorm:"column:renew_time"`
+	LeaseDuration  int64  `gorm:"column:lease_duration"`
+	FencingToken   uint64 `gorm:"column:fencing_token"`
+	Revision       uint64 `gorm:"column:revision"`
+}
+
+func (electionLease) TableName() string {
+	return ELECTION_LEASE_TABLE
+}
+
+func (l *electionLease) toRecord() *LeaseRecord {
+	return &LeaseRecord{
+		HolderIdentity: l.HolderIdentity,
+		AcquireTime:    time.UnixMilli(l.AcquireTime),
+		RenewTime:      time.UnixMilli(l.RenewTime),
+		LeaseDuration:  time.Duration(l.LeaseDuration) * time.Millisecond,
+		FencingToken:   l.FencingToken,
+		Revision:       l.Revision,
+	}
+}
+
+// MySQLLeaseStore keeps the lease in a row of the controller's MySQL
+type MySQLLeaseStore struct {
+	db   *gorm.DB
+	name string
+}
+
+func NewMySQLLeaseStore(cfg mysqlcfg.MySqlConfig, name string) (*MySQLLeaseStore, error) {
+	connector, err := mysqlcommon.GetConnector(cfg, false, cfg.TimeOut, false)
+	if err != nil {
+		return nil, err
+	}
+	db, err := mysqlcommon.InitSession(cfg, connector)
+	if err != nil {
+		return nil, err
+	}
+	database := cfg.Database + ELECTION_DATABASE_SUFFIX
+	err = db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", database)).Error
+	if sqlDB, e := db.DB(); e == nil {
+		sqlDB.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create database %s failed: %v", database, err)
+	}
+
+	copiedCfg := cfg
+	copiedCfg.Database = database
+	// the election only needs a few connections
+	copiedCfg.MaxOpenConns = 2
+	copiedCfg.MaxIdleConns = 1
+	db, err = mysqlcommon.GetSession(copiedCfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Exec(CREATE_TABLE_ELECTION_LEASE).Error; err != nil {
+		return nil, fmt.Errorf("create table %s failed: %v", ELECTION_LEASE_TABLE, err)
+	}
+	return &MySQLLeaseStore{db: db, name: name}, nil
+}
+
+func (s *MySQLLeaseStore) Get(ctx context.Context) (*LeaseRecord, error) {
+	var leases []electionLease
+	if err := s.db.WithContext(ctx).Where("name = ?", s.name).Limit(1).Find(&leases).Error; err != nil {
+		return nil, err
+	}
+	if len(leases) == 0 {
+		return nil, nil
+	}
+	return leases[0].toRecord(), nil
+}
+
+func (s *MySQLLeaseStore) Create(ctx context.Context, record *LeaseRecord) error {
+	lease := &electionLease{
+		Name:           s.name,
+		HolderIdentity: record.HolderIdentity,
+		AcquireTime:    record.AcquireTime.UnixMilli(),
+		RenewTime:      record.RenewTime.UnixMilli(),
+		LeaseDuration:  record.LeaseDuration.Milliseconds(),
+		FencingToken:   record.FencingToken,
+		Revision:       record.Revision,
+	}
+	result := s.db.WithContext(ctx).Clauses(clause.Insert{Modifier: "IGNORE"}).Create(lease)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseConflict
+	}
+	return nil
+}
+
+func (s *MySQLLeaseStore) Update(ctx context.Context, old, record *LeaseRecord) error {
+	// the revision always changes, so no rows affected means the lease has been changed by others
+	result := s.db.WithContext(ctx).Model(&electionLease{}).
+		Where("name = ? AND revision = ?", s.name, old.Revision).
+		Updates(map[string]interface{}{
+			"holder_identity": record.HolderIdentity,
+			"acquire_time":    record.AcquireTime.UnixMilli(),
+			"renew_time":      record.RenewTime.UnixMilli(),
+			"lease_duration":  record.LeaseDuration.Milliseconds(),
+			"fencing_token":   record.FencingToken,
+			"revision":        record.Revision,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseConflict
+	}
+	return nil
+}
@@ -25,5 +25,6 @@ import (
 func GetRegistrants(cfg *config.ControllerConfig) []registrant.Registrant {
 	return []registrant.Registrant{
 		resource.NewVPC(),
+		resource.NewInventory(cfg),
 	}
 }
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/deepflowio/deepflow/server/controller/config"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	"github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
+)
+
+// the query keys which are not the filters of the resource fields
+var inventoryReservedKeys = map[string]struct{}{
+	"page_index": {},
+	"page_size":  {},
+	"fields":     {},
+	"label":      {},
+}
+
+// Inventory is the read-only API of the resources stored by the recorder, e.g.:
+// GET /v2/pods/?pod_cluster_id=1&label=app:nginx&fields=ID,NAME,LABEL&page_index=1&page_size=100
+type Inventory struct {
+	cfg *config.ControllerConfig
+}
+
+func NewInventory(cfg *config.ControllerConfig) *Inventory {
+	return &Inventory{cfg: cfg}
+}
+
+func (i *Inventory) RegisterTo(e *gin.Engine) {
+	for resourceType := range resource.InventoryResources {
+		e.GET(fmt.Sprintf("/v2/%s/", resourceType), getInventory(i.cfg, resourceType))
+	}
+}
+
+func getInventory(cfg *config.ControllerConfig, resourceType string) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		filter := &resource.InventoryFilter{
+			Conditions: make(map[string][]string),
+			Labels:     make(map[string]string),
+		}
+		var err error
+		if value, ok := c.GetQuery("page_index"); ok {
+			if filter.PageIndex, err = strconv.Atoi(value); err != nil {
+				common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+				return
+			}
+		}
+		if value, ok := c.GetQuery("page_size"); ok {
+			if filter.PageSize, err = strconv.Atoi(value); err != nil {
+				common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+				return
+			}
+		}
+		if value, ok := c.GetQuery("fields"); ok && value != "" {
+			filter.Fields = strings.Split(value, ",")
+		}
+		for _, label := range c.QueryArray("label") {
+			kv := strings.SplitN(label, ":", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, fmt.Sprintf("invalid label (%s), should be key:value", label))
+				return
+			}
+			filter.Labels[kv[0]] = kv[1]
+		}
+		for key, values := range c.Request.URL.Query() {
+			if _, ok := inventoryReservedKeys[key]; !ok {
+				filter.Conditions[key] = values
+			}
+		}
+
+		db, err := common.GetContextOrgDB(c)
+		if err != nil {
+			common.BadRequestResponse(c, httpcommon.GET_ORG_DB_FAIL, err.Error())
+			return
+		}
+		teamIDs, err := httpcommon.GetUnauthorizedTeamIDs(httpcommon.GetUserInfo(c), &cfg.FPermit)
+		if err != nil {
+			common.BadRequestResponse(c, httpcommon.CHECK_SCOPE_TEAMS_FAIL, err.Error())
+			return
+		}
+		for k := range teamIDs {
+			filter.ExcludeTeamIDs = append(filter.ExcludeTeamIDs, k)
+		}
+		data, err := resource.GetInventory(db, resourceType, filter)
+		common.JsonResponse(c, data, err)
+	})
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
+)
+
+const (
+	INVENTORY_DEFAULT_PAGE_SIZE = 100
+	INVENTORY_MAX_PAGE_SIZE     = 10000
+)
+
+// InventoryFilter is the filter of the inventory resources
+type InventoryFilter struct {
+	Conditions     map[string][]string // column name -> values
+	Labels         map[string]string   // label (tag) key -> value
+	Fields         []string            // the json names of the returned fields, all fields are returned if empty
+	PageIndex      int                 // start from 1
+	PageSize       int
+	ExcludeTeamIDs []int
+}
+
+type InventoryPage struct {
+	PageIndex int           `json:"PAGE_INDEX"`
+	PageSize  int           `json:"PAGE_SIZE"`
+	Total     int           `json:"TOTAL"`
+	Items     []interface{} `json:"ITEMS"`
+}
+
+type inventoryResource struct {
+	model        interface{}
+	labelColumn  string // the column of the labels, labels are not supported if it is empty
+	hasSubDomain bool
+	find         func(db *gorm.DB) ([]interface{}, error)
+	labels       func(item interface{}) map[string]string
+}
+
+func newInventoryResource[T any](labelColumn string, hasSubDomain bool, labels func(*T) map[string]string) *inventoryResource {
+	r := &inventoryResource{
+		model:        new(T),
+		labelColumn:  labelColumn,
+		hasSubDomain: hasSubDomain,
+		find: func(db *gorm.DB) ([]interface{}, error) {
+			var items []*T
+			if err := db.Find(&items).Error; err != nil {
+				return nil, err
+			}
+			result := make([]interface{}, len(items))
+			for i := range items {
+				result[i] = items[i]
+			}
+			return result, nil
+		},
+	}
+	if labels != nil {
+		r.labels = func(item interface{}) map[string]string {
+			return labels(item.(*T))
+		}
+	}
+	return r
+}
+
+// InventoryResources are the resources stored by the recorder which could be listed by `/v2/<resource>/`
+var InventoryResources = map[string]*inventoryResource{
+	"hosts": newInventoryResource[mysqlmodel.Host]("", false, nil),
+	"vms": newInventoryResource("cloud_tags", false, func(vm *mysqlmodel.VM) map[string]string {
+		return vm.CloudTags
+	}),
+	"lbs":             newInventoryResource[mysqlmodel.LB]("", false, nil),
+	"rds-instances":   newInventoryResource[mysqlmodel.RDSInstance]("", false, nil),
+	"redis-instances": newInventoryResource[mysqlmodel.RedisInstance]("", false, nil),
+	"pod-clusters":    newInventoryResource[mysqlmodel.PodCluster]("", true, nil),
+	"pod-nodes":       newInventoryResource[mysqlmodel.PodNode]("", true, nil),
+	"pod-namespaces": newInventoryResource("cloud_tags", true, func(ns *mysqlmodel.PodNamespace) map[string]string {
+		return ns.CloudTags
+	}),
+	"pod-services": newInventoryResource("label", true, func(ps *mysqlmodel.PodService) map[string]string {
+		return parseInventoryLabels(ps.Label)
+	}),
+	"pod-groups": newInventoryResource("label", true, func(pg *mysqlmodel.PodGroup) map[string]string {
+		return parseInventoryLabels(pg.Label)
+	}),
+	"pods": newInventoryResource("label", true, func(pod *mysqlmodel.Pod) map[string]string {
+		return parseInventoryLabels(pod.Label)
+	}),
+	"processes": newInventoryResource("os_app_tags", true, func(p *mysqlmodel.Process) map[string]string {
+		return parseInventoryLabels(p.OSAPPTags)
+	}),
+}
+
+var inventorySchemas sync.Map
+
+// GetInventory returns a page of the resources which match the filter, the resources in the domains and sub domains
+// of the excluded teams are not returned
+func GetInventory(orgDB *mysql.DB, resourceType string, filter *InventoryFilter) (*InventoryPage, error) {
+	r, ok := InventoryResources[resourceType]
+	if !ok {
+		return nil, servicecommon.NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("resource type (%s) not found", resourceType))
+	}
+	if filter.PageIndex <= 0 {
+		filter.PageIndex = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = INVENTORY_DEFAULT_PAGE_SIZE
+	} else if filter.PageSize > INVENTORY_MAX_PAGE_SIZE {
+		filter.PageSize = INVENTORY_MAX_PAGE_SIZE
+	}
+	if len(filter.Labels) > 0 && r.labels == nil {
+		return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("resource type (%s) does not support label filters", resourceType))
+	}
+	s, err := schema.Parse(r.model, &inventorySchemas, orgDB.NamingStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	db := orgDB.DB.Model(r.model)
+	for column, values := range filter.Conditions {
+		field := s.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("resource type (%s) has no field (%s)", resourceType, column))
+		}
+		db = db.Where(fmt.Sprintf("%s IN (?)", field.DBName), values)
+	}
+	if len(filter.ExcludeTeamIDs) > 0 {
+		db = db.Where("domain NOT IN (?)", orgDB.DB.Model(&mysqlmodel.Domain{}).Select("lcuuid").Where("team_id IN (?)", filter.ExcludeTeamIDs))
+		if r.hasSubDomain {
+			db = db.Where("sub_domain NOT IN (?)", orgDB.DB.Model(&mysqlmodel.SubDomain{}).Select("lcuuid").Where("team_id IN (?)", filter.ExcludeTeamIDs))
+		}
+	}
+	// narrow the candidates down by the label strings, the labels are matched exactly after querying
+	for key, value := range filter.Labels {
+		db = db.Where(fmt.Sprintf("%s LIKE ?", r.labelColumn), "%"+escapeLike(key)+"%"+escapeLike(value)+"%")
+	}
+	db = db.Order("id").Session(&gorm.Session{})
+
+	page := &InventoryPage{PageIndex: filter.PageIndex, PageSize: filter.PageSize}
+	offset := (filter.PageIndex - 1) * filter.PageSize
+	var items []interface{}
+	if len(filter.Labels) == 0 {
+		var total int64
+		if err := db.Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = int(total)
+		if items, err = r.find(db.Offset(offset).Limit(filter.PageSize)); err != nil {
+			return nil, err
+		}
+	} else {
+		candidates, err := r.find(db)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range candidates {
+			if matchInventoryLabels(r.labels(item), filter.Labels) {
+				items = append(items, item)
+			}
+		}
+		page.Total = len(items)
+		if offset >= len(items) {
+			items = nil
+		} else if offset+filter.PageSize < len(items) {
+			items = items[offset : offset+filter.PageSize]
+		} else {
+			items = items[offset:]
+		}
+	}
+
+	page.Items = make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if len(filter.Fields) == 0 {
+			page.Items = append(page.Items, item)
+			continue
+		}
+		selected, err := selectInventoryFields(item, filter.Fields)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, selected)
+	}
+	return page, nil
+}
+
+// parseInventoryLabels parses the labels in the format of `key1:value1, key2:value2`
+func parseInventoryLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for _, label := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(label), ":", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			continue
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels
+}
+
+func matchInventoryLabels(labels, filter map[string]string) bool {
+	for key, value := range filter {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// selectInventoryFields returns the fields of the item by the json names, the names are case insensitive
+func selectInventoryFields(item interface{}, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]interface{})
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	selected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		field = strings.ToUpper(strings.TrimSpace(field))
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
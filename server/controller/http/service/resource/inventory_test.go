@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+)
+
+func (t *SuiteTest) TestGetInventory() {
+	domain := mysqlmodel.Domain{Base: mysqlmodel.Base{Lcuuid: uuid.NewString()}, TeamID: 1}
+	t.db.Create(&domain)
+	otherDomain := mysqlmodel.Domain{Base: mysqlmodel.Base{Lcuuid: uuid.NewString()}, TeamID: 2}
+	t.db.Create(&otherDomain)
+	clusterID := randID() + 1000
+	for _, pod := range []*mysqlmodel.Pod{
+		{Base: mysqlmodel.Base{Lcuuid: uuid.NewString()}, Name: "a", Label: "app:nginx, tier:web", PodClusterID: clusterID, Domain: domain.Lcuuid},
+		{Base: mysqlmodel.Base{Lcuuid: uuid.NewString()}, Name: "b", Label: "app:nginx-exporter", PodClusterID: clusterID, Domain: domain.Lcuuid},
+		{Base: mysqlmodel.Base{Lcuuid: uuid.NewString()}, Name: "c", Label: "app:nginx", PodClusterID: clusterID, Domain: domain.Lcuuid},
+		{Base: mysqlmodel.Base{Lcuuid: uuid.NewString()}, Name: "d", Label: "app:nginx", PodClusterID: clusterID, Domain: otherDomain.Lcuuid},
+	} {
+		t.db.Create(pod)
+	}
+	db := &mysql.DB{DB: t.db, ORGID: common.DEFAULT_ORG_ID}
+	conditions := map[string][]string{"pod_cluster_id": {strconv.Itoa(clusterID)}}
+
+	page, err := GetInventory(db, "pods", &InventoryFilter{Conditions: conditions, PageSize: 2, PageIndex: 2})
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), 4, page.Total)
+	assert.Equal(t.T(), 2, len(page.Items))
+	assert.Equal(t.T(), "c", page.Items[0].(*mysqlmodel.Pod).Name)
+
+	// the label is matched exactly, the pods of the excluded teams are not returned
+	page, err = GetInventory(db, "pods", &InventoryFilter{
+		Conditions:     conditions,
+		Labels:         map[string]string{"app": "nginx"},
+		Fields:         []string{"name", "LABEL"},
+		ExcludeTeamIDs: []int{2},
+	})
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), 2, page.Total)
+	assert.Equal(t.T(), map[string]interface{}{"NAME": "a", "LABEL": "app:nginx, tier:web"}, page.Items[0])
+
+	_, err = GetInventory(db, "pods", &InventoryFilter{Conditions: map[string][]string{"unknown": {"1"}}})
+	assert.NotNil(t.T(), err)
+	_, err = GetInventory(db, "hosts", &InventoryFilter{Labels: map[string]string{"app": "nginx"}})
+	assert.NotNil(t.T(), err)
+}
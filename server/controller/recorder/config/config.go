@@ -26,6 +26,7 @@ type RecorderConfig struct {
 	ResourceMaxID1               int    `default:"499999" yaml:"resource_max_id_1"`
 
 	LogDebug LogDebugConfig `yaml:"log_debug"`
+	Outbound OutboundConfig `yaml:"outbound"`
 }
 
 func Get() *RecorderConfig {
@@ -41,3 +42,32 @@ type LogDebugConfig struct {
 	DetailEnabled bool     `default:"false" yaml:"detail_enabled"`
 	ResourceTypes []string `default:"" yaml:"resource_type"`
 }
+
+// OutboundConfig is the config of publishing the resource change events to the external systems
+type OutboundConfig struct {
+	Enabled          bool                    `default:"false" yaml:"enabled"`
+	Source           string                  `default:"deepflow-controller" yaml:"source"` // source attribute of the cloud events
+	ResourceTypes    []string                `default:"" yaml:"resource_types"`            // resource types of all the sinks, empty means all
+	QueueSize        int                     `default:"10000" yaml:"queue_size"`           // events are dropped when the queue of a sink is full
+	MaxRetries       int                     `default:"5" yaml:"max_retries"`              // retries of a failed delivery
+	RetryInterval    int                     `default:"1" yaml:"retry_interval"`           // unit: second, doubled after each retry
+	MaxRetryInterval int                     `default:"60" yaml:"max_retry_interval"`      // unit: second
+	Webhooks         []OutboundWebhookConfig `yaml:"webhooks"`
+	Kafkas           []OutboundKafkaConfig   `yaml:"kafkas"`
+}
+
+type OutboundWebhookConfig struct {
+	URL           string            `yaml:"url"`
+	Headers       map[string]string `yaml:"headers"`
+	Timeout       int               `default:"10" yaml:"timeout"` // unit: second
+	ResourceTypes []string          `yaml:"resource_types"`       // empty means the resource types of the outbound config
+}
+
+type OutboundKafkaConfig struct {
+	Brokers       []string `yaml:"brokers"`
+	Topic         string   `yaml:"topic"`
+	SaslEnabled   bool     `default:"false" yaml:"sasl_enabled"`
+	SaslUsername  string   `yaml:"sasl_username"`
+	SaslPassword  string   `yaml:"sasl_password"`
+	ResourceTypes []string `yaml:"resource_types"` // empty means the resource types of the outbound config
+}
@@ -0,0 +1,68 @@
+/**
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbound
+
+import (
+	"sync/atomic"
+)
+
+type Counter struct {
+	Enqueued uint64 `statsd:"enqueued_count"`
+	Dropped  uint64 `statsd:"dropped_count"` // the queue is full
+	Sent     uint64 `statsd:"sent_count"`
+	Retried  uint64 `statsd:"retried_count"`
+	Failed   uint64 `statsd:"failed_count"` // still failed after all retries
+}
+
+// DeliveryCounter is the delivery metrics of a sink
+type DeliveryCounter struct {
+	*Counter
+}
+
+func newDeliveryCounter() *DeliveryCounter {
+	return &DeliveryCounter{Counter: &Counter{}}
+}
+
+func (c *DeliveryCounter) GetCounter() interface{} {
+	counter := &Counter{}
+	counter, c.Counter = c.Counter, counter
+	return counter
+}
+
+func (c *DeliveryCounter) Closed() bool {
+	return false
+}
+
+func (c *DeliveryCounter) addEnqueued() {
+	atomic.AddUint64(&c.Counter.Enqueued, 1)
+}
+
+func (c *DeliveryCounter) addDropped() {
+	atomic.AddUint64(&c.Counter.Dropped, 1)
+}
+
+func (c *DeliveryCounter) addSent() {
+	atomic.AddUint64(&c.Counter.Sent, 1)
+}
+
+func (c *DeliveryCounter) addRetried() {
+	atomic.AddUint64(&c.Counter.Retried, 1)
+}
+
+func (c *DeliveryCounter) addFailed() {
+	atomic.AddUint64(&c.Counter.Failed, 1)
+}
@@ -0,0 +1,168 @@
+/**
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbound
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/schema"
+
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub/message"
+)
+
+const (
+	CLOUD_EVENTS_SPEC_VERSION = "1.0"
+	CLOUD_EVENTS_CONTENT_TYPE = "application/cloudevents+json"
+
+	EVENT_TYPE_PREFIX = "io.deepflow.resource"
+
+	ACTION_ADDED   = "added"
+	ACTION_UPDATED = "updated"
+	ACTION_DELETED = "deleted"
+)
+
+// CloudEvent is a resource change event in the structured mode of CloudEvents 1.0,
+// e.g. the type of a pod added event is io.deepflow.resource.pod.added
+type CloudEvent struct {
+	SpecVersion     string     `json:"specversion"`
+	ID              string     `json:"id"`
+	Source          string     `json:"source"`
+	Type            string     `json:"type"`
+	Subject         string     `json:"subject,omitempty"` // lcuuid of the resource
+	Time            time.Time  `json:"time"`
+	DataContentType string     `json:"datacontenttype"`
+	Data            *EventData `json:"data"`
+
+	resourceType string
+}
+
+type EventData struct {
+	ResourceType string                  `json:"resource_type"`
+	Action       string                  `json:"action"`
+	ORGID        int                     `json:"org_id"`
+	TeamID       int                     `json:"team_id"`
+	DomainID     int                     `json:"domain_id"`
+	SubDomainID  int                     `json:"sub_domain_id"`
+	ID           int                     `json:"id"`
+	Lcuuid       string                  `json:"lcuuid"`
+	SoftDelete   bool                    `json:"soft_delete,omitempty"`
+	Resource     interface{}             `json:"resource,omitempty"` // MySQL model of the added or deleted resource
+	Changes      map[string]*FieldChange `json:"changes,omitempty"`  // changed fields of the updated resource
+}
+
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+func newCloudEvent(source, resourceType, action string, md *message.Metadata) *CloudEvent {
+	return &CloudEvent{
+		SpecVersion:     CLOUD_EVENTS_SPEC_VERSION,
+		ID:              uuid.NewString(),
+		Source:          source,
+		Type:            fmt.Sprintf("%s.%s.%s", EVENT_TYPE_PREFIX, resourceType, action),
+		Time:            time.Now(),
+		DataContentType: "application/json",
+		Data: &EventData{
+			ResourceType: resourceType,
+			Action:       action,
+			ORGID:        md.ORGID,
+			TeamID:       md.TeamID,
+			DomainID:     md.DomainID,
+			SubDomainID:  md.SubDomainID,
+			SoftDelete:   action == ACTION_DELETED && md.SoftDelete,
+		},
+		resourceType: resourceType,
+	}
+}
+
+type idLcuuidGetter interface {
+	GetID() int
+	GetLcuuid() string
+}
+
+// newResourceEvents creates an event for each of the MySQL items in the add or delete message
+func newResourceEvents(source, resourceType, action string, md *message.Metadata, items interface{}) []*CloudEvent {
+	value := reflect.ValueOf(items)
+	if value.Kind() != reflect.Slice {
+		return nil
+	}
+	events := make([]*CloudEvent, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		item := value.Index(i).Interface()
+		event := newCloudEvent(source, resourceType, action, md)
+		event.Data.Resource = item
+		if getter, ok := item.(idLcuuidGetter); ok {
+			event.Data.ID = getter.GetID()
+			event.Data.Lcuuid = getter.GetLcuuid()
+			event.Subject = event.Data.Lcuuid
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// newUpdatedEvent creates an event of the fields update message, only the changed fields are included
+func newUpdatedEvent(source, resourceType string, md *message.Metadata, fields interface{}) *CloudEvent {
+	event := newCloudEvent(source, resourceType, ACTION_UPDATED, md)
+	if getter, ok := fields.(idLcuuidGetter); ok {
+		event.Data.ID = getter.GetID()
+		event.Data.Lcuuid = getter.GetLcuuid()
+		event.Subject = event.Data.Lcuuid
+	}
+	event.Data.Changes = changedFields(fields)
+	return event
+}
+
+type fieldDetail interface {
+	IsDifferent() bool
+}
+
+var namingStrategy = schema.NamingStrategy{}
+
+// changedFields collects the old and new values of the different fieldDetail of the fields update message,
+// the keys are the MySQL column names of the fields, e.g. PodGroupID -> pod_group_id
+func changedFields(fields interface{}) map[string]*FieldChange {
+	changes := make(map[string]*FieldChange)
+	value := reflect.ValueOf(fields)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return changes
+	}
+	value = value.Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if !value.Type().Field(i).IsExported() || !field.CanAddr() {
+			continue
+		}
+		detail, ok := field.Addr().Interface().(fieldDetail)
+		if !ok || !detail.IsDifferent() {
+			continue
+		}
+		change := &FieldChange{}
+		if method := field.Addr().MethodByName("GetOld"); method.IsValid() {
+			change.Old = method.Call(nil)[0].Interface()
+		}
+		if method := field.Addr().MethodByName("GetNew"); method.IsValid() {
+			change.New = method.Call(nil)[0].Interface()
+		}
+		changes[namingStrategy.ColumnName("", value.Type().Field(i).Name)] = change
+	}
+	return changes
+}
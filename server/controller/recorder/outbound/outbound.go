@@ -0,0 +1,254 @@
+/**
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package outbound publishes the resource add/update/delete messages of the recorder pubsub as CloudEvents
+// to the webhooks and the kafka topics configured in recorder.outbound
+package outbound
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub/message"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+)
+
+var log = logger.MustGetLogger("recorder.outbound")
+
+const (
+	DEFAULT_QUEUE_SIZE         = 10000
+	DEFAULT_RETRY_INTERVAL     = 1  // unit: second
+	DEFAULT_MAX_RETRY_INTERVAL = 60 // unit: second
+)
+
+var (
+	outboundOnce sync.Once
+	outbound     *Outbound
+)
+
+type Outbound struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    config.OutboundConfig
+
+	workers []*worker
+}
+
+func GetSingleton() *Outbound {
+	outboundOnce.Do(func() {
+		outbound = &Outbound{}
+	})
+	return outbound
+}
+
+func (o *Outbound) Init(ctx context.Context, cfg config.OutboundConfig) *Outbound {
+	o.ctx, o.cancel = context.WithCancel(ctx)
+	o.cfg = cfg
+	if o.cfg.QueueSize <= 0 {
+		o.cfg.QueueSize = DEFAULT_QUEUE_SIZE
+	}
+	if o.cfg.RetryInterval <= 0 {
+		o.cfg.RetryInterval = DEFAULT_RETRY_INTERVAL
+	}
+	if o.cfg.MaxRetryInterval < o.cfg.RetryInterval {
+		o.cfg.MaxRetryInterval = DEFAULT_MAX_RETRY_INTERVAL
+	}
+	return o
+}
+
+// Start creates the sinks and subscribes the add/update/delete messages of the resources, the sinks which fail to
+// be created are skipped
+func (o *Outbound) Start() {
+	if !o.cfg.Enabled {
+		return
+	}
+	for _, webhookCfg := range o.cfg.Webhooks {
+		o.addWorker(newWebhookSink(webhookCfg), webhookCfg.ResourceTypes)
+	}
+	for _, kafkaCfg := range o.cfg.Kafkas {
+		s, err := newKafkaSink(kafkaCfg)
+		if err != nil {
+			log.Errorf("create kafka sink of topic (%s) failed: %s", kafkaCfg.Topic, err.Error())
+			continue
+		}
+		o.addWorker(s, kafkaCfg.ResourceTypes)
+	}
+	if len(o.workers) == 0 {
+		log.Warning("outbound is enabled without any sink")
+		return
+	}
+
+	for resourceType := range pubsub.GetManager().TypeToPubSub {
+		if resourceType == pubsub.PubSubTypeDomain || !o.accept(o.cfg.ResourceTypes, resourceType) {
+			continue
+		}
+		s := &resourceSubscriber{resourceType: resourceType, outbound: o}
+		pubsub.Subscribe(resourceType, pubsub.TopicResourceBatchAddedMySQL, s)
+		pubsub.Subscribe(resourceType, pubsub.TopicResourceUpdatedFields, s)
+		pubsub.Subscribe(resourceType, pubsub.TopicResourceBatchDeletedMySQL, s)
+	}
+	log.Infof("outbound started with %d sinks", len(o.workers))
+}
+
+func (o *Outbound) Stop() {
+	if o.cancel != nil {
+		o.cancel()
+	}
+}
+
+func (o *Outbound) addWorker(s sink, resourceTypes []string) {
+	w := newWorker(o.ctx, o.cfg, s, resourceTypes)
+	if err := stats.RegisterCountableWithModulePrefix("controller_", "recorder_outbound", w.counter, stats.OptionStatTags{"sink": s.Name()}); err != nil {
+		log.Error(err)
+	}
+	o.workers = append(o.workers, w)
+	go w.run()
+}
+
+func (o *Outbound) accept(resourceTypes []string, resourceType string) bool {
+	if len(resourceTypes) == 0 {
+		return true
+	}
+	for _, t := range resourceTypes {
+		if t == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// publish enqueues the events to the workers without blocking the recorder
+func (o *Outbound) publish(events ...*CloudEvent) {
+	for _, w := range o.workers {
+		if !o.accept(w.resourceTypes, events[0].resourceType) {
+			continue
+		}
+		for _, event := range events {
+			w.enqueue(event)
+		}
+	}
+}
+
+// resourceSubscriber implements the subscriber interfaces in recorder/pubsub/subscriber.go for a resource type
+type resourceSubscriber struct {
+	resourceType string
+	outbound     *Outbound
+}
+
+func (s *resourceSubscriber) OnResourceBatchAdded(md *message.Metadata, msg interface{}) {
+	if events := newResourceEvents(s.outbound.cfg.Source, s.resourceType, ACTION_ADDED, md, msg); len(events) > 0 {
+		s.outbound.publish(events...)
+	}
+}
+
+func (s *resourceSubscriber) OnResourceUpdated(md *message.Metadata, msg interface{}) {
+	event := newUpdatedEvent(s.outbound.cfg.Source, s.resourceType, md, msg)
+	if len(event.Data.Changes) > 0 {
+		s.outbound.publish(event)
+	}
+}
+
+func (s *resourceSubscriber) OnResourceBatchDeleted(md *message.Metadata, msg interface{}) {
+	if events := newResourceEvents(s.outbound.cfg.Source, s.resourceType, ACTION_DELETED, md, msg); len(events) > 0 {
+		s.outbound.publish(events...)
+	}
+}
+
+// worker delivers the events of a sink in order, a failed event is retried with exponential backoff before the
+// next event is delivered
+type worker struct {
+	ctx              context.Context
+	sink             sink
+	resourceTypes    []string
+	queue            chan *CloudEvent
+	maxRetries       int
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+	counter          *DeliveryCounter
+}
+
+func newWorker(ctx context.Context, cfg config.OutboundConfig, s sink, resourceTypes []string) *worker {
+	return &worker{
+		ctx:              ctx,
+		sink:             s,
+		resourceTypes:    resourceTypes,
+		queue:            make(chan *CloudEvent, cfg.QueueSize),
+		maxRetries:       cfg.MaxRetries,
+		retryInterval:    time.Duration(cfg.RetryInterval) * time.Second,
+		maxRetryInterval: time.Duration(cfg.MaxRetryInterval) * time.Second,
+		counter:          newDeliveryCounter(),
+	}
+}
+
+func (w *worker) enqueue(event *CloudEvent) {
+	select {
+	case w.queue <- event:
+		w.counter.addEnqueued()
+	default:
+		w.counter.addDropped()
+		log.Warningf("queue of %s is full, event (%s) dropped", w.sink.Name(), event.Type)
+	}
+}
+
+func (w *worker) run() {
+	defer w.sink.Close()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case event := <-w.queue:
+			w.deliver(event)
+		}
+	}
+}
+
+func (w *worker) deliver(event *CloudEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		w.counter.addFailed()
+		log.Errorf("marshal event (%s) failed: %s", event.Type, err.Error())
+		return
+	}
+	interval := w.retryInterval
+	for retries := 0; ; retries++ {
+		err = w.sink.Send(w.ctx, event.Subject, data)
+		if err == nil {
+			w.counter.addSent()
+			return
+		}
+		if retries >= w.maxRetries {
+			w.counter.addFailed()
+			log.Errorf("deliver event (%s %s) to %s failed after %d retries: %s", event.Type, event.Subject, w.sink.Name(), retries, err.Error())
+			return
+		}
+		w.counter.addRetried()
+		log.Warningf("deliver event (%s %s) to %s failed, retry in %s: %s", event.Type, event.Subject, w.sink.Name(), interval, err.Error())
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > w.maxRetryInterval {
+			interval = w.maxRetryInterval
+		}
+	}
+}
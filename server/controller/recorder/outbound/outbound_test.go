@@ -0,0 +1,84 @@
+/**
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbound
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub/message"
+)
+
+func TestNewUpdatedEvent(t *testing.T) {
+	fields := &message.PodFieldsUpdate{}
+	fields.SetID(1)
+	fields.SetLcuuid("pod-1")
+	fields.PodGroupID.Set(2, 3)
+	fields.Label.SetNew("app:nginx")
+
+	event := newUpdatedEvent("deepflow-controller", "pod", message.NewMetadata(1, message.MetadataDomainID(4)), fields)
+	assert.Equal(t, "io.deepflow.resource.pod.updated", event.Type)
+	assert.Equal(t, "pod-1", event.Subject)
+	assert.Equal(t, 4, event.Data.DomainID)
+	assert.Equal(t, map[string]*FieldChange{
+		"pod_group_id": {Old: 2, New: 3},
+		"label":        {Old: "", New: "app:nginx"},
+	}, event.Data.Changes)
+}
+
+func TestWorkerDeliver(t *testing.T) {
+	var requests int32
+	var received CloudEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, CLOUD_EVENTS_CONTENT_TYPE, r.Header.Get("Content-Type"))
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	cfg := config.OutboundConfig{QueueSize: 1, MaxRetries: 2}
+	w := newWorker(context.Background(), cfg, newWebhookSink(config.OutboundWebhookConfig{URL: server.URL, Headers: map[string]string{"Authorization": "token"}}), nil)
+	w.retryInterval = time.Millisecond
+	w.maxRetryInterval = time.Millisecond
+
+	event := newCloudEvent("deepflow-controller", "vm", ACTION_DELETED, message.NewMetadata(1, message.MetadataSoftDelete(true)))
+	w.enqueue(event)
+	w.enqueue(event)
+	w.deliver(<-w.queue)
+	assert.Equal(t, int32(3), requests)
+	assert.Equal(t, event.ID, received.ID)
+	assert.True(t, received.Data.SoftDelete)
+	assert.Equal(t, &Counter{Enqueued: 1, Dropped: 1, Sent: 1, Retried: 2}, w.counter.GetCounter())
+
+	// fails without retries
+	w.maxRetries = 0
+	server.Close()
+	w.deliver(event)
+	assert.Equal(t, uint64(1), w.counter.GetCounter().(*Counter).Failed)
+}
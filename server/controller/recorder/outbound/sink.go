@@ -0,0 +1,138 @@
+/**
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+)
+
+const DEFAULT_WEBHOOK_TIMEOUT = 10 // unit: second
+
+// sink delivers the encoded events to an external system
+type sink interface {
+	Name() string
+	Send(ctx context.Context, key string, data []byte) error
+	Close()
+}
+
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSink(cfg config.OutboundWebhookConfig) *webhookSink {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_WEBHOOK_TIMEOUT
+	}
+	return &webhookSink{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook:" + s.url
+}
+
+// Send posts the event in the structured mode of the CloudEvents HTTP binding, non 2xx responses are failures
+func (s *webhookSink) Send(ctx context.Context, key string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", CLOUD_EVENTS_CONTENT_TYPE)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook %s responded status code %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() {
+	s.client.CloseIdleConnections()
+}
+
+type kafkaSink struct {
+	brokers  []string
+	topic    string
+	producer sarama.SyncProducer
+}
+
+func newKafkaSink(cfg config.OutboundKafkaConfig) (*kafkaSink, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, fmt.Errorf("brokers and topic of kafka are required")
+	}
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Producer.Retry.Max = 0 // retried by the outbound with backoff
+	saramaConfig.Producer.Return.Successes = true
+
+	saramaConfig.Net.SASL.Enable = cfg.SaslEnabled
+	saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	saramaConfig.Net.SASL.User = cfg.SaslUsername
+	saramaConfig.Net.SASL.Password = cfg.SaslPassword
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{brokers: cfg.Brokers, topic: cfg.Topic, producer: producer}, nil
+}
+
+func (s *kafkaSink) Name() string {
+	return "kafka:" + s.topic
+}
+
+// Send produces the event in the structured mode of the CloudEvents Kafka binding, the key is the resource lcuuid
+// so that the events of a resource are kept in order in a partition
+func (s *kafkaSink) Send(ctx context.Context, key string, data []byte) error {
+	_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(data),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("content-type"), Value: []byte(CLOUD_EVENTS_CONTENT_TYPE)},
+		},
+	})
+	return err
+}
+
+func (s *kafkaSink) Close() {
+	if err := s.producer.Close(); err != nil {
+		log.Errorf("close kafka producer of topic (%s) failed: %s", s.topic, err.Error())
+	}
+}
@@ -20,10 +20,14 @@ import (
 	"strconv"
 	"time"
 
+	logging "github.com/op/go-logging"
+
 	"github.com/deepflowio/deepflow/server/ingester/app_log/config"
 	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
 	"github.com/deepflowio/deepflow/server/ingester/app_log/decoder"
 	dropletqueue "github.com/deepflowio/deepflow/server/ingester/droplet/queue"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
 	"github.com/deepflowio/deepflow/server/ingester/pkg/ckwriter"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
@@ -33,12 +37,23 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/receiver"
 )
 
+var log = logging.MustGetLogger("app_log")
+
+// the export producer groups of the loggers, the decoders of a logger use the producer indexes of its group
+const (
+	EXPORT_GROUP_APPLICATION_LOG = iota
+	EXPORT_GROUP_OPENTELEMETRY_LOG
+	EXPORT_GROUP_SYSLOG
+	EXPORT_GROUP_AGENT_LOG
+)
+
 type ApplicationLogger struct {
 	Config      *config.Config
 	Ckwriter    *ckwriter.CKWriter
 	SysLogger   *Logger
 	AgentLogger *Logger
 	AppLogger   *Logger
+	OTelLogger  *Logger
 }
 
 type Logger struct {
@@ -51,6 +66,7 @@ func NewApplicationLogger(
 	config *config.Config,
 	recv *receiver.Receiver,
 	platformDataManager *grpc.PlatformDataManager,
+	exporters *exporters.Exporters,
 ) (*ApplicationLogger, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_APPLICATION_LOG_QUEUE)
 
@@ -58,15 +74,20 @@ func NewApplicationLogger(
 	if err != nil {
 		return nil, err
 	}
-	sysLogger, err := NewLogger(datatype.MESSAGE_TYPE_SYSLOG, config, manager, recv, platformDataManager, ckwriter)
+	// all loggers write to application_log.log, every logger has its own export producer group
+	sysLogger, err := NewLogger(datatype.MESSAGE_TYPE_SYSLOG, config, manager, recv, platformDataManager, ckwriter, exporters, EXPORT_GROUP_SYSLOG)
+	if err != nil {
+		return nil, err
+	}
+	agentLogger, err := NewLogger(datatype.MESSAGE_TYPE_AGENT_LOG, config, manager, recv, platformDataManager, ckwriter, exporters, EXPORT_GROUP_AGENT_LOG)
 	if err != nil {
 		return nil, err
 	}
-	agentLogger, err := NewLogger(datatype.MESSAGE_TYPE_AGENT_LOG, config, manager, recv, platformDataManager, ckwriter)
+	appLogger, err := NewLogger(datatype.MESSAGE_TYPE_APPLICATION_LOG, config, manager, recv, platformDataManager, ckwriter, exporters, EXPORT_GROUP_APPLICATION_LOG)
 	if err != nil {
 		return nil, err
 	}
-	appLogger, err := NewLogger(datatype.MESSAGE_TYPE_APPLICATION_LOG, config, manager, recv, platformDataManager, ckwriter)
+	otelLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY_LOG, config, manager, recv, platformDataManager, ckwriter, exporters, EXPORT_GROUP_OPENTELEMETRY_LOG)
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +98,7 @@ func NewApplicationLogger(
 		SysLogger:   sysLogger,
 		AgentLogger: agentLogger,
 		AppLogger:   appLogger,
+		OTelLogger:  otelLogger,
 	}, nil
 }
 
@@ -85,12 +107,14 @@ func (l *ApplicationLogger) Start() {
 	l.SysLogger.Start()
 	l.AgentLogger.Start()
 	l.AppLogger.Start()
+	l.OTelLogger.Start()
 }
 
 func (l *ApplicationLogger) Close() error {
 	l.SysLogger.Close()
 	l.AgentLogger.Close()
 	l.AppLogger.Close()
+	l.OTelLogger.Close()
 	l.Ckwriter.Close()
 	return nil
 }
@@ -102,6 +126,8 @@ func NewLogger(
 	recv *receiver.Receiver,
 	platformDataManager *grpc.PlatformDataManager,
 	ckwriter *ckwriter.CKWriter,
+	exporters *exporters.Exporters,
+	exportGroup int,
 ) (*Logger, error) {
 
 	queueCount := config.DecoderQueueCount
@@ -131,6 +157,8 @@ func NewLogger(
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			logWriter,
 			platformDatas[i],
+			exporters,
+			exporterconfig.ProducerIndex(exportGroup, i),
 			config,
 		)
 	}
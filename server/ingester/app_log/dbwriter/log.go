@@ -19,14 +19,21 @@ package dbwriter
 import (
 	"fmt"
 	"net"
+	"reflect"
 	"strings"
 	"sync/atomic"
+	"unsafe"
+
+	"go.opentelemetry.io/collector/pdata/plog"
 
 	basecommon "github.com/deepflowio/deepflow/server/ingester/common"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
 	"github.com/deepflowio/deepflow/server/ingester/flow_tag"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/pool"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
 const (
@@ -65,15 +72,15 @@ type ApplicationLogStore struct {
 	Time      uint32 `json:"time" category:"$tag" sub:"flow_info"` // s
 	Timestamp int64  `json:"timestamp" category:"$tag" sub:"flow_info"`
 	_id       uint64 `json:"_id" category:"$tag" sub:"flow_info"`
-	Type      string
+	Type      string `json:"type" category:"$tag" sub:"log_info"`
 
-	TraceID    string
-	SpanID     string
-	TraceFlags uint32
+	TraceID    string `json:"trace_id" category:"$tag" sub:"log_info"`
+	SpanID     string `json:"span_id" category:"$tag" sub:"log_info"`
+	TraceFlags uint32 `json:"trace_flags" category:"$tag" sub:"log_info"`
 
-	SeverityNumber uint8 // numerical value of the severity(also known as log level id)
+	SeverityNumber uint8 `json:"severity_number" category:"$tag" sub:"log_info"` // numerical value of the severity(also known as log level id)
 
-	Body string
+	Body string `json:"body" category:"$tag" sub:"log_info"`
 
 	AppService string `json:"app_service" category:"$tag" sub:"service_info"` // service name
 
@@ -98,9 +105,9 @@ type ApplicationLogStore struct {
 
 	// Not stored, only determines which database to store in.
 	// When Orgid is 0 or 1, it is stored in database 'event', otherwise stored in '<OrgId>_event'.
-	OrgId  uint16
-	TeamID uint16
-	UserID uint32
+	OrgId  uint16 `json:"org_id" category:"$tag"`
+	TeamID uint16 `json:"team_id" category:"$tag"`
+	UserID uint32 `json:"user_id" category:"$tag"`
 
 	AutoInstanceID   uint32 `json:"auto_instance_id" category:"$tag" sub:"universal_tag"`
 	AutoInstanceType uint8  `json:"auto_instance_type" category:"$tag" sub:"universal_tag" enumfile:"auto_instance_type"`
@@ -174,7 +181,69 @@ func (l *ApplicationLogStore) Release() {
 }
 
 func (l *ApplicationLogStore) DataSource() uint32 {
-	return uint32(config.MAX_DATASOURCE_ID)
+	return uint32(config.APPLICATION_LOG)
+}
+
+func (l *ApplicationLogStore) EncodeTo(protocol config.ExportProtocol, utags *utag.UniversalTagsManager, cfg *config.ExporterCfg) (interface{}, error) {
+	switch protocol {
+	case config.PROTOCOL_KAFKA:
+		tags := l.QueryUniversalTags(utags)
+		k8sLabels := utags.QueryCustomK8sLabels(l.OrgId, l.PodID)
+		return exportercommon.EncodeToJson(l, int(l.DataSource()), cfg, tags, tags, k8sLabels, k8sLabels), nil
+	case config.PROTOCOL_OTLP:
+		severityNumber, severityText := severityToOtlp(l.SeverityNumber)
+		return exportercommon.EncodeLogToOtlp(l, int(l.DataSource()), cfg, l.QueryUniversalTags(utags), &exportercommon.OtlpLog{
+			SeverityNumber:  severityNumber,
+			SeverityText:    severityText,
+			Body:            l.Body,
+			TraceID:         l.TraceID,
+			SpanID:          l.SpanID,
+			TraceFlags:      l.TraceFlags,
+			AttributeNames:  l.AttributeNames,
+			AttributeValues: l.AttributeValues,
+			MetricsNames:    l.MetricsNames,
+			MetricsValues:   l.MetricsValues,
+		}), nil
+	default:
+		return nil, fmt.Errorf("application log unsupport export to %s", protocol)
+	}
+}
+
+// severityToOtlp converts the severity number(SEVERITY_* of app_log/decoder) to the OTLP severity number and text
+func severityToOtlp(severity uint8) (plog.SeverityNumber, string) {
+	switch severity {
+	case 0, 1, 2: // log/syslog.LOG_EMERG, LOG_ALERT, LOG_CRIT
+		return plog.SeverityNumberFatal, "FATAL"
+	case 3:
+		return plog.SeverityNumberError, "ERROR"
+	case 4:
+		return plog.SeverityNumberWarn, "WARN"
+	case 5:
+		return plog.SeverityNumberInfo, "INFO"
+	case 6:
+		return plog.SeverityNumberDebug, "DEBUG"
+	case 7:
+		return plog.SeverityNumberTrace, "TRACE"
+	default:
+		return plog.SeverityNumberUnspecified, ""
+	}
+}
+
+func (l *ApplicationLogStore) QueryUniversalTags(utags *utag.UniversalTagsManager) *utag.UniversalTags {
+	return utags.QueryUniversalTags(l.OrgId,
+		l.RegionID, l.AZID, l.HostID, l.PodNSID, l.PodClusterID, l.SubnetID, l.AgentID,
+		l.L3DeviceType, l.AutoServiceType, l.AutoInstanceType,
+		l.L3DeviceID, l.AutoServiceID, l.AutoInstanceID, l.PodNodeID, l.PodGroupID, l.PodID, uint32(l.L3EpcID), l.GProcessID, l.ServiceID,
+		l.IsIPv4, l.IP4, l.IP6,
+	)
+}
+
+func (l *ApplicationLogStore) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(l)), offset, kind, dataType)
+}
+
+func (l *ApplicationLogStore) TimestampUs() int64 {
+	return l.Timestamp
 }
 
 var LogCounter uint32
@@ -26,10 +26,14 @@ import (
 
 	json "github.com/bytedance/sonic"
 	logging "github.com/op/go-logging"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
 
 	"github.com/deepflowio/deepflow/server/ingester/app_log/config"
 	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
 	ingestercommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/codec"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
@@ -115,6 +119,8 @@ type Decoder struct {
 	platformData      *grpc.PlatformInfoTable
 	inQueue           queue.QueueReader
 	logWriter         *dbwriter.AppLogWriter
+	exporters         *exporters.Exporters
+	exportIndex       int
 	debugEnabled      bool
 	config            *config.Config
 	appLogEntrysCache []AppLogEntry
@@ -130,6 +136,8 @@ func NewDecoder(
 	inQueue queue.QueueReader,
 	logWriter *dbwriter.AppLogWriter,
 	platformData *grpc.PlatformInfoTable,
+	exporters *exporters.Exporters,
+	exportIndex int,
 	config *config.Config,
 ) *Decoder {
 	return &Decoder{
@@ -139,6 +147,8 @@ func NewDecoder(
 		inQueue:           inQueue,
 		debugEnabled:      log.IsEnabledFor(logging.DEBUG),
 		logWriter:         logWriter,
+		exporters:         exporters,
+		exportIndex:       exportIndex,
 		appLogEntrysCache: make([]AppLogEntry, 0),
 		config:            config,
 		counter:           &Counter{},
@@ -158,10 +168,12 @@ func (d *Decoder) Run() {
 		"msg_type": d.msgType.String()})
 	buffer := make([]interface{}, BUFFER_SIZE)
 	decoder := &codec.SimpleDecoder{}
+	pbLogsData := &logsv1.LogsData{}
 	for {
 		n := d.inQueue.Gets(buffer)
 		for i := 0; i < n; i++ {
 			if buffer[i] == nil {
+				d.export(nil)
 				continue
 			}
 			d.counter.InCount++
@@ -177,12 +189,21 @@ func (d *Decoder) Run() {
 				d.handleAppLog(recvBytes.VtapID, decoder)
 			case datatype.MESSAGE_TYPE_SYSLOG, datatype.MESSAGE_TYPE_AGENT_LOG:
 				d.handleAgentLog(recvBytes.VtapID, decoder)
+			case datatype.MESSAGE_TYPE_OPENTELEMETRY_LOG:
+				d.handleOTelLog(recvBytes.VtapID, decoder, pbLogsData)
 			}
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
 	}
 }
 
+func (d *Decoder) export(item exportercommon.ExportItem) {
+	if !d.exporters.IsExportDataSource(uint32(exporterconfig.APPLICATION_LOG)) {
+		return
+	}
+	d.exporters.Put(uint32(exporterconfig.APPLICATION_LOG), d.exportIndex, item)
+}
+
 func (d *Decoder) handleAgentLog(agentId uint16, decoder *codec.SimpleDecoder) {
 	for !decoder.IsEnd() {
 		bytes := decoder.ReadBytes()
@@ -300,6 +321,7 @@ func (d *Decoder) WriteAgentLog(agentId uint16, bs []byte) error {
 	s.AttributeNames = append(s.AttributeNames, "module")
 	s.AttributeValues = append(s.AttributeValues, string(columns[4]))
 
+	d.export(s)
 	d.logWriter.Write(s)
 	return nil
 }
@@ -334,8 +356,6 @@ func (d *Decoder) WriteAppLog(agentId uint16, l *AppLogEntry) error {
 		s.OrgId, s.TeamID = d.orgId, d.teamId
 	}
 
-	s.L3EpcID = d.platformData.QueryVtapEpc0(s.OrgId, agentId)
-
 	if l.Json != nil {
 		switch v := l.Json.(type) {
 		case map[string]interface{}:
@@ -365,11 +385,21 @@ func (d *Decoder) WriteAppLog(agentId uint16, l *AppLogEntry) error {
 		s.AttributeValues = append(s.AttributeValues, strings.Clone(l.Kubernetes.PodIp), strings.Clone(l.Kubernetes.PodName))
 	}
 
-	podName := l.Kubernetes.PodName
 	var ip net.IP
 	if l.Kubernetes.PodIp != "" {
 		ip = net.ParseIP(l.Kubernetes.PodIp)
 	}
+	d.fillPlatformInfo(s, agentId, l.Kubernetes.PodName, ip)
+
+	d.export(s)
+	d.logWriter.Write(s)
+	return nil
+}
+
+// fillPlatformInfo fills the universal tags of the log by the pod name or the IP. If neither can be matched,
+// the platform info of the agent is used.
+func (d *Decoder) fillPlatformInfo(s *dbwriter.ApplicationLogStore, agentId uint16, podName string, ip net.IP) {
+	s.L3EpcID = d.platformData.QueryVtapEpc0(s.OrgId, agentId)
 	if podName != "" {
 		podInfo := d.platformData.QueryPodInfo(s.OrgId, agentId, podName)
 		if podInfo != nil {
@@ -446,9 +476,6 @@ func (d *Decoder) WriteAppLog(agentId uint16, l *AppLogEntry) error {
 
 	s.AutoInstanceID, s.AutoInstanceType = ingestercommon.GetAutoInstance(s.PodID, 0, s.PodNodeID, s.L3DeviceID, uint32(s.SubnetID), uint8(s.L3DeviceType), s.L3EpcID)
 	s.AutoServiceID, s.AutoServiceType = ingestercommon.GetAutoService(s.ServiceID, s.PodGroupID, 0, s.PodNodeID, s.L3DeviceID, uint32(s.SubnetID), uint8(s.L3DeviceType), podGroupType, s.L3EpcID)
-
-	d.logWriter.Write(s)
-	return nil
 }
 
 type AppLogEntry struct {
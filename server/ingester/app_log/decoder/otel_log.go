@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
+	ingestercommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/codec"
+)
+
+// the attribute name of event logs, refer to: https://opentelemetry.io/docs/specs/semconv/general/events/
+const OTEL_EVENT_NAME = "event.name"
+
+// OTelSeverityToSeverity converts the OpenTelemetry severity number to the severity of application log,
+// refer to: https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber
+func OTelSeverityToSeverity(severityNumber logsv1.SeverityNumber, severityText string) uint8 {
+	switch {
+	case severityNumber >= logsv1.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return SEVERITY_FATAL
+	case severityNumber >= logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return SEVERITY_ERROR
+	case severityNumber >= logsv1.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return SEVERITY_WARN
+	case severityNumber >= logsv1.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return SEVERITY_INFO
+	case severityNumber >= logsv1.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return SEVERITY_DEBUG
+	case severityNumber >= logsv1.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return SEVERITY_TRACE
+	default:
+		// the severity number is unspecified, try the severity text
+		return StringToSeverity(severityText)
+	}
+}
+
+// handleOTelLog decodes the OpenTelemetry logs sent by the agent. Each payload of the message is a 4-byte length
+// followed by an OTLP LogsData encoded in Protobuf, which is zlib-compressed if the agent enables compression.
+// A LogsData starts with the tag of field 'resource_logs' (0x0a), while a zlib stream starts with 0x78.
+func (d *Decoder) handleOTelLog(agentId uint16, decoder *codec.SimpleDecoder, pbLogsData *logsv1.LogsData) {
+	var err error
+	for !decoder.IsEnd() {
+		pbLogsData.Reset()
+		bytes := decoder.ReadBytes()
+		if len(bytes) > 0 {
+			if bytes[0] == ZLIB_HEADER {
+				bytes, err = decompressZlib(bytes)
+			}
+			if err == nil {
+				err = proto.Unmarshal(bytes, pbLogsData)
+			}
+		}
+		if decoder.Failed() || err != nil {
+			if d.counter.ErrorCount == 0 {
+				log.Errorf("OpenTelemetry log decode failed, offset=%d len=%d err: %s", decoder.Offset(), len(decoder.Bytes()), err)
+			}
+			d.counter.ErrorCount++
+			return
+		}
+		if d.debugEnabled {
+			log.Debugf("recv agent Id: %d, otel log: %s", agentId, pbLogsData)
+		}
+
+		for _, resourceLogs := range pbLogsData.GetResourceLogs() {
+			resAttributes := resourceLogs.GetResource().GetAttributes()
+			for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+				for _, record := range scopeLogs.GetLogRecords() {
+					d.WriteOTelLog(agentId, resAttributes, record)
+					d.counter.OutCount++
+				}
+			}
+		}
+	}
+}
+
+func (d *Decoder) WriteOTelLog(agentId uint16, resAttributes []*v11.KeyValue, record *logsv1.LogRecord) {
+	s := dbwriter.AcquireApplicationLogStore()
+	podName, ip := fillOTelLogRecord(s, resAttributes, record)
+	s.SetId(s.Time, d.platformData.QueryAnalyzerID())
+	s.AgentID = agentId
+	s.OrgId, s.TeamID = d.orgId, d.teamId
+
+	d.fillPlatformInfo(s, agentId, podName, ip)
+
+	d.export(s)
+	d.logWriter.Write(s)
+}
+
+// fillOTelLogRecord fills the application log with the log record and its resource attributes,
+// and returns the pod name and IP used to look up the platform information.
+func fillOTelLogRecord(s *dbwriter.ApplicationLogStore, resAttributes []*v11.KeyValue, record *logsv1.LogRecord) (string, net.IP) {
+	s.Type = dbwriter.LOG_TYPE_USER
+	s.Body = ingestercommon.OTelAnyValueString(record.GetBody())
+
+	timeUnixNano := record.GetTimeUnixNano()
+	if timeUnixNano == 0 {
+		timeUnixNano = record.GetObservedTimeUnixNano()
+	}
+	if timeUnixNano == 0 {
+		timeUnixNano = uint64(time.Now().UnixNano())
+	}
+	s.Time = uint32(timeUnixNano / uint64(time.Second))
+	s.Timestamp = int64(timeUnixNano / uint64(time.Microsecond))
+
+	if len(record.GetTraceId()) > 0 {
+		s.TraceID = hex.EncodeToString(record.GetTraceId())
+	}
+	if len(record.GetSpanId()) > 0 {
+		s.SpanID = hex.EncodeToString(record.GetSpanId())
+	}
+	s.TraceFlags = record.GetFlags()
+	s.SeverityNumber = OTelSeverityToSeverity(record.GetSeverityNumber(), record.GetSeverityText())
+
+	for _, attr := range record.GetAttributes() {
+		if attr.GetValue() == nil {
+			continue
+		}
+		valueString := ingestercommon.OTelAnyValueString(attr.GetValue())
+		// the body of event logs is optional, use the event name instead
+		if s.Body == "" && attr.GetKey() == OTEL_EVENT_NAME {
+			s.Body = valueString
+		}
+		s.AttributeNames = append(s.AttributeNames, attr.GetKey())
+		s.AttributeValues = append(s.AttributeValues, valueString)
+	}
+
+	var podName string
+	var ip net.IP
+	for _, attr := range resAttributes {
+		if attr.GetValue() == nil {
+			continue
+		}
+		key := attr.GetKey()
+		valueString := ingestercommon.OTelAnyValueString(attr.GetValue())
+		switch key {
+		case "service.name":
+			s.AppService = valueString
+			continue
+		case "k8s.pod.name":
+			podName = valueString
+		case "k8s.pod.ip", "app.host.ip":
+			if ip == nil {
+				ip = net.ParseIP(valueString)
+			}
+		}
+		s.AttributeNames = append(s.AttributeNames, key)
+		s.AttributeValues = append(s.AttributeValues, valueString)
+	}
+	return podName, ip
+}
+
+const ZLIB_HEADER = 0x78
+
+func decompressZlib(in []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"reflect"
+	"testing"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
+)
+
+func TestOTelSeverityToSeverity(t *testing.T) {
+	testCases := []struct {
+		number   logsv1.SeverityNumber
+		text     string
+		expected uint8
+	}{
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_TRACE, "", SEVERITY_TRACE},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_TRACE4, "", SEVERITY_TRACE},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_DEBUG, "", SEVERITY_DEBUG},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_DEBUG4, "", SEVERITY_DEBUG},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_INFO, "", SEVERITY_INFO},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_INFO4, "", SEVERITY_INFO},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_WARN, "", SEVERITY_WARN},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_WARN4, "", SEVERITY_WARN},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR, "", SEVERITY_ERROR},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR4, "", SEVERITY_ERROR},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_FATAL, "", SEVERITY_FATAL},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_FATAL4, "", SEVERITY_FATAL},
+		// the severity number takes precedence over the severity text
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_INFO, "error", SEVERITY_INFO},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "warn", SEVERITY_WARN},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "", SEVERITY_UNKNOWN},
+	}
+	for _, c := range testCases {
+		if got := OTelSeverityToSeverity(c.number, c.text); got != c.expected {
+			t.Errorf("OTelSeverityToSeverity(%s, %q) = %d, expected %d", c.number, c.text, got, c.expected)
+		}
+	}
+}
+
+func stringKeyValue(key, value string) *v11.KeyValue {
+	return &v11.KeyValue{Key: key, Value: &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: value}}}
+}
+
+func TestFillOTelLogRecord(t *testing.T) {
+	record := &logsv1.LogRecord{
+		TimeUnixNano:   1700000000123456789,
+		SeverityNumber: logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR,
+		Body:           &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: "hello"}},
+		Attributes: []*v11.KeyValue{
+			stringKeyValue("service.name", "not-resource"),
+			{Key: "count", Value: &v11.AnyValue{Value: &v11.AnyValue_IntValue{IntValue: 3}}},
+			{Key: "empty"},
+		},
+		Flags:   1,
+		TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		SpanId:  []byte{0xa1, 0xb2, 0xc3, 0xd4, 0xe5, 0xf6, 0x07, 0x08},
+	}
+	resAttributes := []*v11.KeyValue{
+		stringKeyValue("service.name", "svc"),
+		stringKeyValue("k8s.pod.name", "pod-0"),
+		stringKeyValue("k8s.pod.ip", "10.1.2.3"),
+		stringKeyValue("app.host.ip", "192.168.0.1"),
+	}
+
+	s := &dbwriter.ApplicationLogStore{}
+	podName, ip := fillOTelLogRecord(s, resAttributes, record)
+	if podName != "pod-0" || ip.String() != "10.1.2.3" {
+		t.Errorf("got pod %s ip %s, expected pod-0 10.1.2.3", podName, ip)
+	}
+	if s.Time != 1700000000 || s.Timestamp != 1700000000123456 {
+		t.Errorf("got time %d timestamp %d", s.Time, s.Timestamp)
+	}
+	if s.TraceID != "0102030405060708090a0b0c0d0e0f10" || s.SpanID != "a1b2c3d4e5f60708" || s.TraceFlags != 1 {
+		t.Errorf("got trace id %s span id %s flags %d", s.TraceID, s.SpanID, s.TraceFlags)
+	}
+	if s.Body != "hello" || s.SeverityNumber != SEVERITY_ERROR {
+		t.Errorf("got body %s severity %d", s.Body, s.SeverityNumber)
+	}
+	// only the service name of the resource is used as the app service, and it is not kept as an attribute
+	if s.AppService != "svc" {
+		t.Errorf("got app service %s, expected svc", s.AppService)
+	}
+	expectedNames := []string{"service.name", "count", "k8s.pod.name", "k8s.pod.ip", "app.host.ip"}
+	expectedValues := []string{"not-resource", "3", "pod-0", "10.1.2.3", "192.168.0.1"}
+	if !reflect.DeepEqual(s.AttributeNames, expectedNames) || !reflect.DeepEqual(s.AttributeValues, expectedValues) {
+		t.Errorf("got attributes %v=%v, expected %v=%v", s.AttributeNames, s.AttributeValues, expectedNames, expectedValues)
+	}
+}
+
+func TestFillOTelLogRecordEmptyBody(t *testing.T) {
+	testCases := []struct {
+		name     string
+		record   *logsv1.LogRecord
+		expected string
+	}{
+		{"no body", &logsv1.LogRecord{ObservedTimeUnixNano: 1700000000000000000}, ""},
+		{"event", &logsv1.LogRecord{Attributes: []*v11.KeyValue{stringKeyValue(OTEL_EVENT_NAME, "browser.click")}}, "browser.click"},
+		{"body before event name", &logsv1.LogRecord{
+			Body:       &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: "hello"}},
+			Attributes: []*v11.KeyValue{stringKeyValue(OTEL_EVENT_NAME, "browser.click")},
+		}, "hello"},
+	}
+	for _, c := range testCases {
+		s := &dbwriter.ApplicationLogStore{}
+		fillOTelLogRecord(s, nil, c.record)
+		if s.Body != c.expected {
+			t.Errorf("%s: got body %q, expected %q", c.name, s.Body, c.expected)
+		}
+		if s.Time == 0 {
+			t.Errorf("%s: time is not filled", c.name)
+		}
+	}
+}
+
+// the attributes of the record must not be modified when the resource attributes are appended
+func TestFillOTelLogRecordAttributesNotAliased(t *testing.T) {
+	attributes := make([]*v11.KeyValue, 1, 4)
+	attributes[0] = stringKeyValue("a", "1")
+	spare := attributes[:4]
+	record := &logsv1.LogRecord{Attributes: attributes}
+	fillOTelLogRecord(&dbwriter.ApplicationLogStore{}, []*v11.KeyValue{stringKeyValue("b", "2")}, record)
+	if spare[1] != nil {
+		t.Errorf("the spare capacity of the record attributes is modified: %v", spare[1])
+	}
+}
+
+func TestDecompressZlib(t *testing.T) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte("\x0a\x00"))
+	w.Close()
+	if buf.Bytes()[0] != ZLIB_HEADER {
+		t.Fatalf("unexpected zlib header 0x%x", buf.Bytes()[0])
+	}
+	out, err := decompressZlib(buf.Bytes())
+	if err != nil || string(out) != "\x0a\x00" {
+		t.Errorf("got %v %v", out, err)
+	}
+	if _, err := decompressZlib([]byte{ZLIB_HEADER, 0}); err == nil {
+		t.Errorf("expected error for an invalid zlib stream")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// OTelAnyValueString converts the OpenTelemetry attribute value to a string, bytes are encoded in base64,
+// arrays and key-value lists are encoded in JSON.
+func OTelAnyValueString(value *v11.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *v11.AnyValue_StringValue:
+		return v.StringValue
+	case *v11.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *v11.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *v11.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *v11.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *v11.AnyValue_ArrayValue, *v11.AnyValue_KvlistValue:
+		b, err := json.Marshal(otelAnyValueToInterface(value))
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	default:
+		return ""
+	}
+}
+
+func otelAnyValueToInterface(value *v11.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *v11.AnyValue_StringValue:
+		return v.StringValue
+	case *v11.AnyValue_BoolValue:
+		return v.BoolValue
+	case *v11.AnyValue_IntValue:
+		return v.IntValue
+	case *v11.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *v11.AnyValue_BytesValue:
+		return v.BytesValue
+	case *v11.AnyValue_ArrayValue:
+		values := v.ArrayValue.GetValues()
+		array := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			array = append(array, otelAnyValueToInterface(value))
+		}
+		return array
+	case *v11.AnyValue_KvlistValue:
+		kvs := v.KvlistValue.GetValues()
+		kvlist := make(map[string]interface{}, len(kvs))
+		for _, kv := range kvs {
+			kvlist[kv.GetKey()] = otelAnyValueToInterface(kv.GetValue())
+		}
+		return kvlist
+	default:
+		return nil
+	}
+}
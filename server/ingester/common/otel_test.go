@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"testing"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func TestOTelAnyValueString(t *testing.T) {
+	stringValue := func(s string) *v11.AnyValue {
+		return &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: s}}
+	}
+	testCases := []struct {
+		name     string
+		value    *v11.AnyValue
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"unset", &v11.AnyValue{}, ""},
+		{"string", stringValue("a b:c"), "a b:c"},
+		{"empty string", stringValue(""), ""},
+		{"bool", &v11.AnyValue{Value: &v11.AnyValue_BoolValue{BoolValue: false}}, "false"},
+		{"int", &v11.AnyValue{Value: &v11.AnyValue_IntValue{IntValue: -42}}, "-42"},
+		{"double", &v11.AnyValue{Value: &v11.AnyValue_DoubleValue{DoubleValue: 0.25}}, "0.25"},
+		{"bytes", &v11.AnyValue{Value: &v11.AnyValue_BytesValue{BytesValue: []byte{0xde, 0xad, 0xbe, 0xef}}}, "3q2+7w=="},
+		{"array", &v11.AnyValue{Value: &v11.AnyValue_ArrayValue{ArrayValue: &v11.ArrayValue{Values: []*v11.AnyValue{
+			stringValue("x"),
+			{Value: &v11.AnyValue_IntValue{IntValue: 1}},
+		}}}}, `["x",1]`},
+		{"kvlist", &v11.AnyValue{Value: &v11.AnyValue_KvlistValue{KvlistValue: &v11.KeyValueList{Values: []*v11.KeyValue{
+			{Key: "b", Value: &v11.AnyValue{Value: &v11.AnyValue_BoolValue{BoolValue: true}}},
+			{Key: "a", Value: stringValue("y")},
+		}}}}, `{"a":"y","b":true}`},
+	}
+	for _, c := range testCases {
+		if got := OTelAnyValueString(c.value); got != c.expected {
+			t.Errorf("%s: got %q, expected %q", c.name, got, c.expected)
+		}
+	}
+}
@@ -65,6 +65,9 @@ const (
 	EnvRunningMode                  = "DEEPFLOW_SERVER_RUNNING_MODE"
 	RunningModeStandalone           = "STANDALONE"
 	DefaultByconityStoragePolicy    = "cnch_default_s3"
+	DefaultCKWriterSpoolDir         = "/var/lib/deepflow-server/ckwriter-spool"
+	DefaultCKWriterSpoolMaxSize     = 1024 // MB
+	DefaultCKWriterSpoolMaxAge      = 24   // hour
 	// the maximum number of endpoints for a server corresponding to ClickHouse;
 	//   any endpoints beyond this limit will be ignored
 	MaxClickHouseEndpointsPerServer = 128
@@ -131,6 +134,27 @@ type Auth struct {
 	Password string `yaml:"password"`
 }
 
+// CKWriterSpool is the on-disk spool of the ckwriters, the batches failed to be written to ClickHouse are saved
+// to the spool and replayed after ClickHouse recovers
+type CKWriterSpool struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+	MaxSize int    `yaml:"max-size"` // MB, for each table of each organization
+	MaxAge  int    `yaml:"max-age"`  // hour
+}
+
+func (s *CKWriterSpool) Validate() {
+	if s.Dir == "" {
+		s.Dir = DefaultCKWriterSpoolDir
+	}
+	if s.MaxSize <= 0 {
+		s.MaxSize = DefaultCKWriterSpoolMaxSize
+	}
+	if s.MaxAge <= 0 {
+		s.MaxAge = DefaultCKWriterSpoolMaxAge
+	}
+}
+
 type CKWriterConfig struct {
 	QueueCount   int `yaml:"queue-count"`
 	QueueSize    int `yaml:"queue-size"`
@@ -177,6 +201,7 @@ type Config struct {
 	TCPReaderBuffer          int             `yaml:"tcp-reader-buffer"`
 	CKDiskMonitor            CKDiskMonitor   `yaml:"ck-disk-monitor"`
 	ColdStorage              CKDBColdStorage `yaml:"ckdb-cold-storage"`
+	CKWriterSpool            CKWriterSpool   `yaml:"ckwriter-spool"`
 	ckdbColdStorages         map[string]*ckdb.ColdStorage
 	NodeIP                   string `yaml:"node-ip"`
 	GrpcBufferSize           int    `yaml:"grpc-buffer-size"`
@@ -337,6 +362,7 @@ func (c *Config) Validate() error {
 		return nil
 	}
 	c.CKDiskMonitor.Validate()
+	c.CKWriterSpool.Validate()
 
 	if c.CKDB.Type == "" {
 		c.CKDB.Type = ckdb.CKDBTypeClickhouse
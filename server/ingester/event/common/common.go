@@ -65,6 +65,10 @@ func (e EventType) DataSource() uint32 {
 	switch e {
 	case PERF_EVENT:
 		return uint32(exportconfig.PERF_EVENT)
+	case RESOURCE_EVENT, K8S_EVENT:
+		return uint32(exportconfig.EVENT)
+	case ALERT_EVENT:
+		return uint32(exportconfig.ALERT_EVENT)
 	default:
 		return uint32(exportconfig.MAX_DATASOURCE_ID)
 	}
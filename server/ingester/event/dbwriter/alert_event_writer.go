@@ -17,16 +17,24 @@
 package dbwriter
 
 import (
+	"fmt"
+	"reflect"
 	"strconv"
 	"sync/atomic"
+	"time"
+	"unsafe"
 
 	basecommon "github.com/deepflowio/deepflow/server/ingester/common"
 	"github.com/deepflowio/deepflow/server/ingester/event/common"
 	"github.com/deepflowio/deepflow/server/ingester/event/config"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
 	"github.com/deepflowio/deepflow/server/ingester/flow_tag"
 	"github.com/deepflowio/deepflow/server/ingester/pkg/ckwriter"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/pool"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
 var alertEventPool = pool.NewLockFreePool(func() interface{} {
@@ -34,11 +42,13 @@ var alertEventPool = pool.NewLockFreePool(func() interface{} {
 })
 
 func AcquireAlertEventStore() *AlertEventStore {
-	return alertEventPool.Get().(*AlertEventStore)
+	e := alertEventPool.Get().(*AlertEventStore)
+	e.Reset()
+	return e
 }
 
 func ReleaseAlertEventStore(e *AlertEventStore) {
-	if e == nil {
+	if e == nil || e.SubReferenceCount() {
 		return
 	}
 	*e = AlertEventStore{}
@@ -46,26 +56,28 @@ func ReleaseAlertEventStore(e *AlertEventStore) {
 }
 
 type AlertEventStore struct {
-	Time uint32
-	_id  uint64
-
-	PolicyId     uint32
-	PolicyType   uint8
-	AlertPlicy   string
-	MetricValue  float64
-	EventLevel   uint8
-	TargetTags   string
-	TagStrKeys   []string
-	TagStrValues []string
-	TagIntKeys   []string
-	TagIntValues []int64
+	pool.ReferenceCount
+
+	Time uint32 `json:"time" category:"$tag" sub:"flow_info"` // s
+	_id  uint64 `json:"_id" category:"$tag" sub:"flow_info"`
+
+	PolicyId     uint32   `json:"policy_id" category:"$tag" sub:"alert_info"`
+	PolicyType   uint8    `json:"policy_type" category:"$tag" sub:"alert_info"`
+	AlertPlicy   string   `json:"alert_policy" category:"$tag" sub:"alert_info"`
+	MetricValue  float64  `json:"metric_value" category:"$metrics" sub:"metrics_value"`
+	EventLevel   uint8    `json:"event_level" category:"$tag" sub:"alert_info"`
+	TargetTags   string   `json:"target_tags" category:"$tag" sub:"alert_info"`
+	TagStrKeys   []string `json:"tag_string_names" category:"$tag" sub:"native_tag" data_type:"[]string"`
+	TagStrValues []string `json:"tag_string_values" category:"$tag" sub:"native_tag" data_type:"[]string"`
+	TagIntKeys   []string `json:"tag_int_names" category:"$tag" sub:"native_tag" data_type:"[]string"`
+	TagIntValues []int64  `json:"tag_int_values" category:"$tag" sub:"native_tag" data_type:"[]int64"`
 
 	XTargetUid   string
 	XQueryRegion string
 
-	UserId uint32
-	OrgId  uint16
-	TeamID uint16
+	UserId uint32 `json:"user_id" category:"$tag" sub:"alert_info"`
+	OrgId  uint16 `json:"org_id" category:"$tag"`
+	TeamID uint16 `json:"team_id" category:"$tag"`
 }
 
 func (e *AlertEventStore) SetId(time, analyzerID uint32) {
@@ -126,6 +138,53 @@ func (e *AlertEventStore) Release() {
 	ReleaseAlertEventStore(e)
 }
 
+func (e *AlertEventStore) DataSource() uint32 {
+	return uint32(exporterconfig.ALERT_EVENT)
+}
+
+func (e *AlertEventStore) EncodeTo(protocol exporterconfig.ExportProtocol, utags *utag.UniversalTagsManager, cfg *exporterconfig.ExporterCfg) (interface{}, error) {
+	switch protocol {
+	case exporterconfig.PROTOCOL_KAFKA:
+		// alert events have no universal tags
+		tags := &utag.UniversalTags{}
+		return exportercommon.EncodeToJson(e, int(e.DataSource()), cfg, tags, tags, nil, nil), nil
+	case exporterconfig.PROTOCOL_OTLP:
+		names := make([]string, 0, len(e.TagStrKeys)+len(e.TagIntKeys))
+		values := make([]string, 0, len(e.TagStrKeys)+len(e.TagIntKeys))
+		for i, key := range e.TagStrKeys {
+			if i >= len(e.TagStrValues) {
+				break
+			}
+			names = append(names, key)
+			values = append(values, e.TagStrValues[i])
+		}
+		for i, key := range e.TagIntKeys {
+			if i >= len(e.TagIntValues) {
+				break
+			}
+			names = append(names, key)
+			values = append(values, strconv.FormatInt(e.TagIntValues[i], 10))
+		}
+		return exportercommon.EncodeLogToOtlp(e, int(e.DataSource()), cfg, &utag.UniversalTags{}, &exportercommon.OtlpLog{
+			Body:            e.AlertPlicy,
+			AttributeNames:  names,
+			AttributeValues: values,
+			MetricsNames:    []string{"metric_value"},
+			MetricsValues:   []float64{e.MetricValue},
+		}), nil
+	default:
+		return nil, fmt.Errorf("alert event unsupport export to %s", protocol)
+	}
+}
+
+func (e *AlertEventStore) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(e)), offset, kind, dataType)
+}
+
+func (e *AlertEventStore) TimestampUs() int64 {
+	return int64(time.Duration(e.Time) * time.Second / time.Microsecond)
+}
+
 func (e *AlertEventStore) OrgID() uint16 {
 	return e.OrgId
 }
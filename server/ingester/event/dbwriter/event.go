@@ -63,7 +63,7 @@ type EventStore struct {
 
 	SignalSource     uint8  `json:"signal_source" category:"$tag" sub:"capture_info" enumfile:"perf_event_signal_source"` // Resource / File IO
 	EventType        string `json:"event_type" category:"$tag" sub:"event_info" enumfile:"perf_event_type"`
-	EventDescription string
+	EventDescription string `json:"event_desc" category:"$tag" sub:"event_info"`
 	ProcessKName     string `json:"process_kname" category:"$tag" sub:"service_info"` // us
 
 	GProcessID uint32 `json:"gprocess_id" category:"$tag" sub:"universal_tag"`
@@ -179,7 +179,7 @@ func (e *EventStore) DataSource() uint32 {
 	if e.HasMetrics {
 		return uint32(config.PERF_EVENT)
 	}
-	return uint32(config.MAX_DATASOURCE_ID)
+	return uint32(config.EVENT)
 }
 
 func (e *EventStore) EncodeTo(protocol config.ExportProtocol, utags *utag.UniversalTagsManager, cfg *config.ExporterCfg) (interface{}, error) {
@@ -188,6 +188,20 @@ func (e *EventStore) EncodeTo(protocol config.ExportProtocol, utags *utag.Univer
 		tags := e.QueryUniversalTags(utags)
 		k8sLabels := utags.QueryCustomK8sLabels(e.OrgId, e.PodID)
 		return exportercommon.EncodeToJson(e, int(e.DataSource()), cfg, tags, tags, k8sLabels, k8sLabels), nil
+	case config.PROTOCOL_OTLP:
+		l := &exportercommon.OtlpLog{
+			Body:            e.EventDescription,
+			AttributeNames:  e.AttributeNames,
+			AttributeValues: e.AttributeValues,
+		}
+		if l.Body == "" {
+			l.Body = e.EventType
+		}
+		if e.HasMetrics {
+			l.MetricsNames = []string{"bytes", "duration"}
+			l.MetricsValues = []float64{float64(e.Bytes), float64(e.Duration)}
+		}
+		return exportercommon.EncodeLogToOtlp(e, int(e.DataSource()), cfg, e.QueryUniversalTags(utags), l), nil
 	default:
 		return nil, fmt.Errorf("event unsupport export to %s", protocol)
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/event/dbwriter"
 	"github.com/deepflowio/deepflow/server/ingester/event/decoder"
 	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
@@ -54,7 +55,7 @@ type Eventor struct {
 
 func NewEvent(config *config.Config, resourceEventQueue *queue.OverwriteQueue, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager, exporters *exporters.Exporters) (*Event, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_EVENT_QUEUE)
-	resourceEventor, err := NewResouceEventor(resourceEventQueue, config, platformDataManager.GetMasterPlatformInfoTable())
+	resourceEventor, err := NewResouceEventor(resourceEventQueue, config, platformDataManager.GetMasterPlatformInfoTable(), exporters)
 	if err != nil {
 		return nil, err
 	}
@@ -64,12 +65,12 @@ func NewEvent(config *config.Config, resourceEventQueue *queue.OverwriteQueue, r
 		return nil, err
 	}
 
-	alertEventor, err := NewAlertEventor(config, recv, manager, platformDataManager.GetMasterPlatformInfoTable())
+	alertEventor, err := NewAlertEventor(config, recv, manager, platformDataManager.GetMasterPlatformInfoTable(), exporters)
 	if err != nil {
 		return nil, err
 	}
 
-	k8sEventor, err := NewEventor(common.K8S_EVENT, config, recv, manager, platformDataManager, nil)
+	k8sEventor, err := NewEventor(common.K8S_EVENT, config, recv, manager, platformDataManager, exporters)
 	if err != nil {
 		return nil, err
 	}
@@ -83,18 +84,19 @@ func NewEvent(config *config.Config, resourceEventQueue *queue.OverwriteQueue, r
 	}, nil
 }
 
-func NewResouceEventor(eventQueue *queue.OverwriteQueue, config *config.Config, platformTable *grpc.PlatformInfoTable) (*Eventor, error) {
+func NewResouceEventor(eventQueue *queue.OverwriteQueue, config *config.Config, platformTable *grpc.PlatformInfoTable, exporters *exporters.Exporters) (*Eventor, error) {
 	eventWriter, err := dbwriter.NewEventWriter(common.RESOURCE_EVENT, 0, config)
 	if err != nil {
 		return nil, err
 	}
 	d := decoder.NewDecoder(
-		0,
+		// resource events share the export datasource with k8s events whose decoders use the producer group 0
+		exporterconfig.ProducerIndex(1, 0),
 		common.RESOURCE_EVENT,
 		queue.QueueReader(eventQueue),
 		eventWriter,
 		platformTable,
-		nil,
+		exporters,
 		config,
 	)
 	return &Eventor{
@@ -103,7 +105,7 @@ func NewResouceEventor(eventQueue *queue.OverwriteQueue, config *config.Config,
 	}, nil
 }
 
-func NewAlertEventor(config *config.Config, recv *receiver.Receiver, manager *dropletqueue.Manager, platformTable *grpc.PlatformInfoTable) (*Eventor, error) {
+func NewAlertEventor(config *config.Config, recv *receiver.Receiver, manager *dropletqueue.Manager, platformTable *grpc.PlatformInfoTable, exporters *exporters.Exporters) (*Eventor, error) {
 	eventMsg := datatype.MESSAGE_TYPE_ALERT_EVENT
 	decodeQueues := manager.NewQueues(
 		"1-receive-to-decode-"+eventMsg.String(),
@@ -124,7 +126,7 @@ func NewAlertEventor(config *config.Config, recv *receiver.Receiver, manager *dr
 		queue.QueueReader(decodeQueues.FixedMultiQueue[0]),
 		eventWriter,
 		platformTable,
-		nil,
+		exporters,
 		config,
 	)
 	return &Eventor{
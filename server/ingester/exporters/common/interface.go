@@ -124,13 +124,14 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 	}
 
 	isMapItem := config.DataSourceID(dataSourceId).IsMap()
-	var isString, isFloat64, isStringSlice, isFloat64Slice bool
+	var isString, isFloat64, isStringSlice, isFloat64Slice, isInt64Slice bool
 	var keyStr, valueStr string
 	var valueFloat64 float64
 	var stringSlice []string
 	var float64Slice []float64
+	var int64Slice []int64
 	for _, structTags := range exporterCfg.ExportFieldStructTags[dataSourceId] {
-		isString, isFloat64, isStringSlice, isFloat64Slice = false, false, false, false
+		isString, isFloat64, isStringSlice, isFloat64Slice, isInt64Slice = false, false, false, false, false
 		value := item.GetFieldValueByOffsetAndKind(structTags.Offset, structTags.DataKind, structTags.DataType)
 		if utils.IsNil(value) {
 			log.Debugf("%s value is nil", structTags.FieldName)
@@ -150,6 +151,9 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 		} else if v, ok := value.([]float64); ok {
 			isFloat64Slice = true
 			float64Slice = v
+		} else if v, ok := value.([]int64); ok {
+			isInt64Slice = true
+			int64Slice = v
 		} else if v, vStr, ok := utils.ConvertToFloat64(value); ok {
 			isFloat64 = true
 			valueFloat64 = v
@@ -187,7 +191,8 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 		if !exporterCfg.ExportEmptyTag &&
 			(structTags.CategoryBit&config.TAG) != 0 &&
 			((isString && valueStr == "") ||
-				(isStringSlice && len(stringSlice) == 0)) {
+				(isStringSlice && len(stringSlice) == 0) ||
+				(isInt64Slice && len(int64Slice) == 0)) {
 			continue
 		}
 
@@ -226,6 +231,15 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 				sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
 			}
 			sb.WriteString("]")
+		} else if isInt64Slice {
+			sb.WriteString("[")
+			for i, v := range int64Slice {
+				if i != 0 {
+					sb.WriteString(`,`)
+				}
+				sb.WriteString(strconv.FormatInt(v, 10))
+			}
+			sb.WriteString("]")
 		} else if isFloat64 {
 			sb.WriteString(valueStr)
 		} else {
@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"encoding/hex"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+)
+
+const OTLP_SCOPE_NAME = "deepflow"
+
+// OtlpLog is the content of an OTLP log record besides the attributes generated from the tag fields of the item
+type OtlpLog struct {
+	SeverityNumber plog.SeverityNumber
+	SeverityText   string
+	Body           string
+	TraceID        string // hex string
+	SpanID         string // hex string
+	TraceFlags     uint32
+
+	AttributeNames  []string
+	AttributeValues []string
+	MetricsNames    []string
+	MetricsValues   []float64
+}
+
+func putOtlpAttributes(attrs pcommon.Map, names, values []string, cfg *config.ExporterCfg) {
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		if !cfg.ExportEmptyTag && values[i] == "" {
+			continue
+		}
+		attrs.PutStr(name, values[i])
+	}
+}
+
+func putOtlpMetricsAttributes(attrs pcommon.Map, names []string, values []float64, cfg *config.ExporterCfg) {
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		if cfg.ExportEmptyMetricsDisabled && values[i] == 0 {
+			continue
+		}
+		attrs.PutDouble(name, values[i])
+	}
+}
+
+func setOtlpResource(resource pcommon.Resource, dataSourceId int) {
+	resource.Attributes().PutStr("datasource", config.DataSourceID(dataSourceId).String())
+}
+
+// EncodeLogToOtlp generates one OTLP log record for the single side item, the tag fields of the item are put to
+// the attributes of the record in the same way as GetPrometheusLabels.
+func EncodeLogToOtlp(item EncodeItem, dataSourceId int, cfg *config.ExporterCfg, uTags *utag.UniversalTags, l *OtlpLog) plog.ResourceLogsSlice {
+	logsSlice := plog.NewResourceLogsSlice()
+	resLogs := logsSlice.AppendEmpty()
+	setOtlpResource(resLogs.Resource(), dataSourceId)
+	scopeLogs := resLogs.ScopeLogs().AppendEmpty()
+	scopeLogs.Scope().SetName(OTLP_SCOPE_NAME)
+
+	record := scopeLogs.LogRecords().AppendEmpty()
+	timestamp := pcommon.Timestamp(item.TimestampUs() * 1000)
+	record.SetTimestamp(timestamp)
+	record.SetObservedTimestamp(timestamp)
+	record.SetSeverityNumber(l.SeverityNumber)
+	record.SetSeverityText(l.SeverityText)
+	record.Body().SetStr(l.Body)
+	if traceID, err := hex.DecodeString(l.TraceID); err == nil && len(traceID) == 16 {
+		var id pcommon.TraceID
+		copy(id[:], traceID)
+		record.SetTraceID(id)
+	}
+	if spanID, err := hex.DecodeString(l.SpanID); err == nil && len(spanID) == 8 {
+		var id pcommon.SpanID
+		copy(id[:], spanID)
+		record.SetSpanID(id)
+	}
+	record.SetFlags(plog.LogRecordFlags(l.TraceFlags))
+
+	attrs := record.Attributes()
+	rangeTags(item, dataSourceId, cfg, uTags, uTags, func(name, value string) {
+		attrs.PutStr(name, value)
+	})
+	putOtlpAttributes(attrs, l.AttributeNames, l.AttributeValues, cfg)
+	putOtlpMetricsAttributes(attrs, l.MetricsNames, l.MetricsValues, cfg)
+	return logsSlice
+}
+
+// EncodeMetricsToOtlp generates one gauge for every metric in names/values, and all data points share the attributes
+// generated from the tag fields of the single side item and tagNames/tagValues.
+func EncodeMetricsToOtlp(item EncodeItem, dataSourceId int, cfg *config.ExporterCfg, uTags *utag.UniversalTags, tagNames, tagValues []string, metricPrefix string, names []string, values []float64) pmetric.ResourceMetricsSlice {
+	metricsSlice := pmetric.NewResourceMetricsSlice()
+	resMetrics := metricsSlice.AppendEmpty()
+	setOtlpResource(resMetrics.Resource(), dataSourceId)
+	scopeMetrics := resMetrics.ScopeMetrics().AppendEmpty()
+	scopeMetrics.Scope().SetName(OTLP_SCOPE_NAME)
+
+	attrs := pcommon.NewMap()
+	rangeTags(item, dataSourceId, cfg, uTags, uTags, func(name, value string) {
+		attrs.PutStr(name, value)
+	})
+	putOtlpAttributes(attrs, tagNames, tagValues, cfg)
+
+	timestamp := pcommon.Timestamp(item.TimestampUs() * 1000)
+	metrics := scopeMetrics.Metrics()
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		if cfg.ExportEmptyMetricsDisabled && values[i] == 0 {
+			continue
+		}
+		metric := metrics.AppendEmpty()
+		metric.SetName(metricPrefix + name)
+		point := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		point.SetTimestamp(timestamp)
+		point.SetDoubleValue(values[i])
+		attrs.CopyTo(point.Attributes())
+	}
+	return metricsSlice
+}
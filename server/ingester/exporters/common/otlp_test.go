@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+)
+
+func TestEncodeLogToOtlp(t *testing.T) {
+	item := &testItem{Time: 1700000000, PodID: 1, Host: "h1"}
+	var uTags utag.UniversalTags
+	uTags[utag.Pod] = "pod-0"
+	cfg := newTestExporterCfg(t, config.APPLICATION_LOG)
+	l := &OtlpLog{
+		SeverityNumber:  plog.SeverityNumberError,
+		SeverityText:    "error",
+		Body:            "hello",
+		TraceID:         "0102030405060708090a0b0c0d0e0f10",
+		SpanID:          "invalid",
+		AttributeNames:  []string{"a", "empty"},
+		AttributeValues: []string{"1", ""},
+		MetricsNames:    []string{"m"},
+		MetricsValues:   []float64{2.5},
+	}
+
+	logs := EncodeLogToOtlp(item, int(config.APPLICATION_LOG), cfg, &uTags, l)
+	if logs.Len() != 1 {
+		t.Fatalf("got %d resource logs, expected 1", logs.Len())
+	}
+	if v, _ := logs.At(0).Resource().Attributes().Get("datasource"); v.Str() != "application_log.log" {
+		t.Errorf("got datasource %s", v.Str())
+	}
+	record := logs.At(0).ScopeLogs().At(0).LogRecords().At(0)
+	if record.Timestamp() != 1700000000000000000 || record.Body().Str() != "hello" || record.SeverityNumber() != plog.SeverityNumberError {
+		t.Errorf("got timestamp %d body %s severity %s", record.Timestamp(), record.Body().Str(), record.SeverityNumber())
+	}
+	if record.TraceID().String() != l.TraceID || !record.SpanID().IsEmpty() {
+		t.Errorf("got trace id %s span id %s", record.TraceID(), record.SpanID())
+	}
+	attrs := record.Attributes().AsRaw()
+	expected := map[string]interface{}{"pod_id": "pod-0", "pod_id_1": "pod-0", "host": "h1", "a": "1", "m": 2.5}
+	if len(attrs) != len(expected) {
+		t.Errorf("got attributes %v, expected %v", attrs, expected)
+	}
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("got attribute %s=%v, expected %v", k, attrs[k], v)
+		}
+	}
+}
+
+func TestEncodeMetricsToOtlp(t *testing.T) {
+	item := &testItem{Time: 1700000000, Host: "h1"}
+	cfg := newTestExporterCfg(t, config.EXT_METRICS)
+	cfg.ExportEmptyMetricsDisabled = true
+	metrics := EncodeMetricsToOtlp(item, int(config.EXT_METRICS), cfg, &utag.UniversalTags{}, []string{"tag"}, []string{"v"},
+		"influxdb.", []string{"cpu", "zero"}, []float64{1.5, 0})
+
+	ms := metrics.At(0).ScopeMetrics().At(0).Metrics()
+	if ms.Len() != 1 {
+		t.Fatalf("got %d metrics, expected 1", ms.Len())
+	}
+	if ms.At(0).Name() != "influxdb.cpu" {
+		t.Errorf("got metric name %s", ms.At(0).Name())
+	}
+	point := ms.At(0).Gauge().DataPoints().At(0)
+	if point.DoubleValue() != 1.5 || point.Timestamp() != 1700000000000000000 {
+		t.Errorf("got value %v timestamp %d", point.DoubleValue(), point.Timestamp())
+	}
+	attrs := point.Attributes().AsRaw()
+	if len(attrs) != 2 || attrs["host"] != "h1" || attrs["tag"] != "v" {
+		t.Errorf("got attributes %v", attrs)
+	}
+}
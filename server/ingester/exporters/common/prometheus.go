@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+// GetPrometheusLabels returns the tag fields of the item as prometheus labels, uTags1 is only used by the '_1' side
+// tags of the double side items, and single side items could pass the same universal tags for both sides.
+// Array fields(such as 'tag_names'/'tag_values') should be added by the caller.
+func GetPrometheusLabels(item EncodeItem, dataSourceId int, cfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags) []prompb.Label {
+	labels := make([]prompb.Label, 0, 16)
+	labels = append(labels, prompb.Label{
+		Name:  "datasource",
+		Value: config.DataSourceID(dataSourceId).String(),
+	})
+	rangeTags(item, dataSourceId, cfg, uTags0, uTags1, func(name, value string) {
+		labels = append(labels, prompb.Label{
+			Name:  name,
+			Value: value,
+		})
+	})
+	return labels
+}
+
+// rangeTags calls f with the name and the translated value of every exported tag field of the item, array fields are skipped.
+func rangeTags(item EncodeItem, dataSourceId int, cfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, f func(name, value string)) {
+	isMapItem := config.DataSourceID(dataSourceId).IsMap()
+	var name, valueStr string
+	for _, structTags := range cfg.ExportFieldStructTags[dataSourceId] {
+		if structTags.CategoryBit&config.TAG == 0 {
+			continue
+		}
+		//  the time of items is exported as the timestamp, no need to export anymore
+		if structTags.Name == "time" {
+			continue
+		}
+
+		value := item.GetFieldValueByOffsetAndKind(structTags.Offset, structTags.DataKind, structTags.DataType)
+		if utils.IsNil(value) {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			valueStr = v
+		case []string, []float64, []int64:
+			continue
+		default:
+			valueStr = fmt.Sprintf("%v", value)
+		}
+
+		if structTags.ToStringFuncName != "" {
+			ret := structTags.ToStringFunc.Call([]reflect.Value{reflect.ValueOf(value)})
+			valueStr = ret[0].String()
+		} else if structTags.UniversalTagMapID > 0 && !cfg.UniversalTagTranslateToNameDisabled {
+			if strings.HasSuffix(structTags.Name, "_1") {
+				valueStr = uTags1.GetTagValue(structTags.UniversalTagMapID)
+			} else {
+				valueStr = uTags0.GetTagValue(structTags.UniversalTagMapID)
+			}
+		} else if structTags.EnumFile != "" && !cfg.EnumTranslateToNameDisabled {
+			valueStr = structTags.EnumStringMap[valueStr]
+		}
+
+		if !cfg.ExportEmptyTag && valueStr == "" {
+			continue
+		}
+
+		if isMapItem && structTags.MapName != "" {
+			name = structTags.MapName
+		} else {
+			name = structTags.Name
+		}
+		f(name, valueStr)
+	}
+}
+
+// AppendPrometheusLabels appends name/value pairs to labels, names are converted to valid prometheus label names.
+func AppendPrometheusLabels(labels []prompb.Label, names, values []string, cfg *config.ExporterCfg) []prompb.Label {
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		if !cfg.ExportEmptyTag && values[i] == "" {
+			continue
+		}
+		labels = append(labels, prompb.Label{
+			Name:  PrometheusName(name),
+			Value: values[i],
+		})
+	}
+	return labels
+}
+
+// EncodeMetricsToPrometheus generates one TimeSeries for every metric in names/values, and all TimeSeries share the same labels.
+func EncodeMetricsToPrometheus(labels []prompb.Label, metricPrefix string, names []string, values []float64, timestampMs int64, cfg *config.ExporterCfg) []prompb.TimeSeries {
+	timeSeries := make([]prompb.TimeSeries, 0, len(names))
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		if cfg.ExportEmptyMetricsDisabled && values[i] == 0 {
+			continue
+		}
+		ts := prompb.TimeSeries{}
+		ts.Labels = make([]prompb.Label, 0, len(labels)+1)
+		ts.Labels = append(ts.Labels, prompb.Label{
+			Name:  "__name__",
+			Value: PrometheusName(metricPrefix + name),
+		})
+		ts.Labels = append(ts.Labels, labels...)
+		sort.Slice(ts.Labels, func(i, j int) bool {
+			return ts.Labels[i].Name < ts.Labels[j].Name
+		})
+		ts.Samples = []prompb.Sample{{Value: values[i], Timestamp: timestampMs}}
+		timeSeries = append(timeSeries, ts)
+	}
+	return timeSeries
+}
+
+// PrometheusName replaces the characters which are not allowed in prometheus metric/label names with '_'.
+func PrometheusName(name string) string {
+	valid := true
+	for i, c := range name {
+		if !isPrometheusNameChar(c, i == 0) {
+			valid = false
+			break
+		}
+	}
+	if valid {
+		return name
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(name))
+	for i, c := range name {
+		if isPrometheusNameChar(c, i == 0) {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+func isPrometheusNameChar(c rune, isFirst bool) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == ':' || (!isFirst && c >= '0' && c <= '9')
+}
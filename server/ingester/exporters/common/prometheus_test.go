@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+type testItem struct {
+	Time   uint32
+	PodID  uint32
+	PodID1 uint32
+	Host   string
+	Domain string
+	Names  []string
+	Bytes  uint64
+}
+
+func (i *testItem) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(i)), offset, kind, dataType)
+}
+
+func (i *testItem) TimestampUs() int64 {
+	return int64(i.Time) * 1000000
+}
+
+func testStructTags(t *testing.T, fieldName, name, mapName string, categoryBit uint64) config.StructTags {
+	field, ok := reflect.TypeOf(testItem{}).FieldByName(fieldName)
+	if !ok {
+		t.Fatalf("no field %s", fieldName)
+	}
+	tags := config.StructTags{
+		Name:        name,
+		MapName:     mapName,
+		FieldName:   fieldName,
+		Offset:      field.Offset,
+		CategoryBit: categoryBit,
+		DataKind:    field.Type.Kind(),
+	}
+	if field.Type.Kind() == reflect.Slice {
+		tags.DataType = utils.DATATYPE_StringSlice
+	}
+	return tags
+}
+
+func newTestExporterCfg(t *testing.T, dataSourceId config.DataSourceID) *config.ExporterCfg {
+	cfg := &config.ExporterCfg{}
+	podTags := testStructTags(t, "PodID", "pod_id", "pod_id_0", config.UNIVERSAL_TAG)
+	podTags.UniversalTagMapID = utag.Pod
+	pod1Tags := testStructTags(t, "PodID1", "pod_id_1", "", config.UNIVERSAL_TAG)
+	pod1Tags.UniversalTagMapID = utag.Pod
+	cfg.ExportFieldStructTags[dataSourceId] = []config.StructTags{
+		testStructTags(t, "Time", "time", "", config.FLOW_INFO),
+		podTags,
+		pod1Tags,
+		testStructTags(t, "Host", "host", "", config.NATIVE_TAG),
+		testStructTags(t, "Domain", "domain", "", config.NATIVE_TAG),
+		testStructTags(t, "Names", "names", "", config.NATIVE_TAG),
+		testStructTags(t, "Bytes", "bytes", "", config.L3_THROUGHPUT),
+	}
+	return cfg
+}
+
+func TestGetPrometheusLabels(t *testing.T) {
+	item := &testItem{Time: 1700000000, PodID: 1, PodID1: 2, Host: "h1", Names: []string{"a"}, Bytes: 10}
+	var uTags0, uTags1 utag.UniversalTags
+	uTags0[utag.Pod], uTags1[utag.Pod] = "pod-0", "pod-1"
+
+	testCases := []struct {
+		dataSourceId config.DataSourceID
+		expected     []prompb.Label
+	}{
+		{config.NETWORK_MAP_1M, []prompb.Label{
+			{Name: "datasource", Value: "flow_metrics.network_map.1m"},
+			{Name: "pod_id_0", Value: "pod-0"},
+			{Name: "pod_id_1", Value: "pod-1"},
+			{Name: "host", Value: "h1"},
+		}},
+		// the map name is only used by the map datasources
+		{config.NETWORK_1M, []prompb.Label{
+			{Name: "datasource", Value: "flow_metrics.network.1m"},
+			{Name: "pod_id", Value: "pod-0"},
+			{Name: "pod_id_1", Value: "pod-1"},
+			{Name: "host", Value: "h1"},
+		}},
+	}
+	for _, c := range testCases {
+		cfg := newTestExporterCfg(t, c.dataSourceId)
+		if got := GetPrometheusLabels(item, int(c.dataSourceId), cfg, &uTags0, &uTags1); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: got labels %v, expected %v", c.dataSourceId, got, c.expected)
+		}
+	}
+
+	cfg := newTestExporterCfg(t, config.NETWORK_1M)
+	cfg.ExportEmptyTag = true
+	cfg.UniversalTagTranslateToNameDisabled = true
+	expected := []prompb.Label{
+		{Name: "datasource", Value: "flow_metrics.network.1m"},
+		{Name: "pod_id", Value: "1"},
+		{Name: "pod_id_1", Value: "2"},
+		{Name: "host", Value: "h1"},
+		{Name: "domain", Value: ""},
+	}
+	if got := GetPrometheusLabels(item, int(config.NETWORK_1M), cfg, &uTags0, &uTags1); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got labels %v, expected %v", got, expected)
+	}
+}
+
+func TestEncodeMetricsToPrometheus(t *testing.T) {
+	labels := []prompb.Label{{Name: "datasource", Value: "ext_metrics.metrics"}, {Name: "host", Value: "h1"}}
+	names := []string{"cpu.usage", "zero", "no_value"}
+	values := []float64{1.5, 0}
+
+	cfg := &config.ExporterCfg{}
+	timeSeries := EncodeMetricsToPrometheus(labels, "influxdb_", names, values, 1700000000000, cfg)
+	if len(timeSeries) != 2 {
+		t.Fatalf("got %d time series, expected 2", len(timeSeries))
+	}
+	expected := prompb.TimeSeries{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: "influxdb_cpu_usage"},
+			{Name: "datasource", Value: "ext_metrics.metrics"},
+			{Name: "host", Value: "h1"},
+		},
+		Samples: []prompb.Sample{{Value: 1.5, Timestamp: 1700000000000}},
+	}
+	if !reflect.DeepEqual(timeSeries[0], expected) {
+		t.Errorf("got time series %v, expected %v", timeSeries[0], expected)
+	}
+	// the shared labels must not be sorted or modified
+	if labels[0].Name != "datasource" || len(labels) != 2 {
+		t.Errorf("the shared labels are modified: %v", labels)
+	}
+
+	cfg.ExportEmptyMetricsDisabled = true
+	if timeSeries := EncodeMetricsToPrometheus(labels, "influxdb_", names, values, 1700000000000, cfg); len(timeSeries) != 1 {
+		t.Errorf("got %d time series, expected 1 when empty metrics are not exported", len(timeSeries))
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"", ""},
+		{"valid_name:total", "valid_name:total"},
+		{"http.server.duration", "http_server_duration"},
+		{"k8s.pod-name", "k8s_pod_name"},
+		{"0abc", "_abc"},
+		{"a0", "a0"},
+		{"名字", "__"},
+	}
+	for _, c := range testCases {
+		if got := PrometheusName(c.name); got != c.expected {
+			t.Errorf("PrometheusName(%q) = %q, expected %q", c.name, got, c.expected)
+		}
+	}
+}
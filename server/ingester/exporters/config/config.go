@@ -33,6 +33,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/config"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
+	"github.com/deepflowio/deepflow/server/libs/queue"
 	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
@@ -50,6 +51,12 @@ const (
 	CATEGORY_METRICS   = "$metrics"
 
 	TOPIC_PREFIX = "deepflow."
+
+	MAX_EXPORTERS_PER_DATASOURCE = 8
+	// the producers(such as decoders) of a datasource are divided into groups, e.g. the decoders of different
+	// message types, and every group has at most queue.MAX_QUEUE_COUNT producers
+	MAX_PRODUCER_GROUPS_PER_DATASOURCE = 4
+	MAX_PRODUCERS_PER_DATASOURCE       = MAX_PRODUCER_GROUPS_PER_DATASOURCE * queue.MAX_QUEUE_COUNT
 )
 
 var DefaultExportCategory = []string{"$service_info", "$tracing_info", "$network_layer", "$flow_info", "$transport_layer", "$application_layer", "$metrics"}
@@ -70,6 +77,12 @@ const (
 	PERF_EVENT = DataSourceID(flow_metrics.METRICS_TABLE_ID_MAX) + 1 + iota
 	L4_FLOW_LOG
 	L7_FLOW_LOG
+	EVENT
+	ALERT_EVENT
+	APPLICATION_LOG
+	PROFILE
+	EXT_METRICS
+	PROMETHEUS
 
 	MAX_DATASOURCE_ID
 )
@@ -86,6 +99,12 @@ var dataSourceStrings = []string{
 	PERF_EVENT:         "event.perf_event",
 	L4_FLOW_LOG:        "flow_log.l4_flow_log",
 	L7_FLOW_LOG:        "flow_log.l7_flow_log",
+	EVENT:              "event.event",
+	ALERT_EVENT:        "event.alert_event",
+	APPLICATION_LOG:    "application_log.log",
+	PROFILE:            "profile.in_process",
+	EXT_METRICS:        "ext_metrics.metrics",
+	PROMETHEUS:         "prometheus.samples",
 	MAX_DATASOURCE_ID:  "invalid_datasource",
 }
 
@@ -101,9 +120,25 @@ var dataSourceTopicStrings = []string{
 	PERF_EVENT:         TOPIC_PREFIX + dataSourceStrings[PERF_EVENT],
 	L4_FLOW_LOG:        TOPIC_PREFIX + dataSourceStrings[L4_FLOW_LOG],
 	L7_FLOW_LOG:        TOPIC_PREFIX + dataSourceStrings[L7_FLOW_LOG],
+	EVENT:              TOPIC_PREFIX + dataSourceStrings[EVENT],
+	ALERT_EVENT:        TOPIC_PREFIX + dataSourceStrings[ALERT_EVENT],
+	APPLICATION_LOG:    TOPIC_PREFIX + dataSourceStrings[APPLICATION_LOG],
+	PROFILE:            TOPIC_PREFIX + dataSourceStrings[PROFILE],
+	EXT_METRICS:        TOPIC_PREFIX + dataSourceStrings[EXT_METRICS],
+	PROMETHEUS:         TOPIC_PREFIX + dataSourceStrings[PROMETHEUS],
 	MAX_DATASOURCE_ID:  TOPIC_PREFIX + dataSourceStrings[MAX_DATASOURCE_ID],
 }
 
+// ProducerIndex returns the index used to put items by the index-th producer of the groupIndex-th group, every
+// producer of a datasource must have its own index, since the put caches of exporters are not locked.
+func ProducerIndex(groupIndex, index int) int {
+	if groupIndex >= MAX_PRODUCER_GROUPS_PER_DATASOURCE || index >= queue.MAX_QUEUE_COUNT {
+		panic(fmt.Sprintf("producer group %d index %d exceeds the limit, max groups %d, max producers per group %d",
+			groupIndex, index, MAX_PRODUCER_GROUPS_PER_DATASOURCE, queue.MAX_QUEUE_COUNT))
+	}
+	return groupIndex*queue.MAX_QUEUE_COUNT + index
+}
+
 func FlowLogMessageToDataSourceID(messageType datatype.MessageType) uint32 {
 	switch messageType {
 	case datatype.MESSAGE_TYPE_TAGGEDFLOW:
@@ -146,7 +181,8 @@ func (d DataSourceID) TopicString() string {
 
 func (d DataSourceID) IsMap() bool {
 	switch d {
-	case NETWORK_1M, APPLICATION_1M, NETWORK_1S, APPLICATION_1S, PERF_EVENT:
+	case NETWORK_1M, APPLICATION_1M, NETWORK_1S, APPLICATION_1S, PERF_EVENT,
+		EVENT, ALERT_EVENT, APPLICATION_LOG, PROFILE, EXT_METRICS, PROMETHEUS:
 		return false
 	default:
 		return true
@@ -515,6 +551,28 @@ func (p ExportProtocol) String() string {
 	return protocolToStrings[p]
 }
 
+// IsSupportedDataSource returns whether the items of the datasource can be encoded to the protocol,
+// kafka supports all datasources.
+func (p ExportProtocol) IsSupportedDataSource(d DataSourceID) bool {
+	switch p {
+	case PROTOCOL_KAFKA:
+		return d < MAX_DATASOURCE_ID
+	case PROTOCOL_PROMETHEUS:
+		switch d {
+		case NETWORK_1M, NETWORK_MAP_1M, APPLICATION_1M, APPLICATION_MAP_1M,
+			NETWORK_1S, NETWORK_MAP_1S, APPLICATION_1S, APPLICATION_MAP_1S,
+			EXT_METRICS, PROMETHEUS:
+			return true
+		}
+	case PROTOCOL_OTLP:
+		switch d {
+		case L7_FLOW_LOG, PERF_EVENT, EVENT, ALERT_EVENT, APPLICATION_LOG, EXT_METRICS, PROMETHEUS:
+			return true
+		}
+	}
+	return false
+}
+
 func (cfg *ExporterCfg) Validate() error {
 	l := len(cfg.Endpoints)
 	cfg.RandomEndpoints = make([]string, 0, l)
@@ -540,9 +598,16 @@ func (cfg *ExporterCfg) Validate() error {
 		cfg.ExportFields = DefaultExportCategory
 	}
 	cfg.DataSourceBits = StringsToDataSourceBits(cfg.DataSources)
+	cfg.ExportProtocol = stringToExportProtocol(cfg.Protocol)
+	if cfg.Enabled && cfg.ExportProtocol != MAX_PROTOCOL_ID {
+		for _, dataSource := range cfg.DataSources {
+			if d, err := ToDataSourceID(dataSource); err == nil && !cfg.ExportProtocol.IsSupportedDataSource(d) {
+				return fmt.Errorf("export protocol %s unsupport datasource %s", cfg.Protocol, dataSource)
+			}
+		}
+	}
 	cfg.ExportFieldCategoryBits = StringsToCategoryBits(cfg.ExportFields)
 	cfg.ExportFieldNames = cfg.ExportFields
-	cfg.ExportProtocol = stringToExportProtocol(cfg.Protocol)
 	cfg.ExportFieldK8s = GetK8sLabelConfigs(cfg.ExportFields)
 	for i := range cfg.TagFilters {
 		cfg.TagFilters[i].Validate()
@@ -564,10 +629,22 @@ type Config struct {
 }
 
 func (c *Config) Validate() error {
+	var exporterCounts [MAX_DATASOURCE_ID]int
 	for i := range c.Exporters {
 		if err := c.Exporters[i].Validate(); err != nil {
 			return err
 		}
+		if !c.Exporters[i].Enabled {
+			continue
+		}
+		for _, dataSource := range c.Exporters[i].DataSources {
+			if d, err := ToDataSourceID(dataSource); err == nil {
+				exporterCounts[d]++
+				if exporterCounts[d] > MAX_EXPORTERS_PER_DATASOURCE {
+					return fmt.Errorf("datasource %s is exported by more than %d exporters", dataSource, MAX_EXPORTERS_PER_DATASOURCE)
+				}
+			}
+		}
 	}
 	return nil
 }
@@ -603,6 +680,9 @@ const (
 	CAPTURE_INFO
 	EVENT_INFO // perf_event only
 	DATA_LINK_LAYER
+	LOG_INFO     // application_log only
+	PROFILE_INFO // profile only
+	ALERT_INFO   // alert_event only
 
 	// metrics
 	L3_THROUGHPUT // network*/l4_flow_log
@@ -613,10 +693,11 @@ const (
 	THROUGHPUT    // application*/l7_flow_log
 	ERROR         // application*/l7_flow_log
 	DELAY         // all network/application/flow_log
+	METRICS_VALUE // ext_metrics/prometheus/application_log/profile
 
 	K8S_LABEL
-	TAG     = FLOW_INFO | UNIVERSAL_TAG | CUSTOM_TAG | NATIVE_TAG | NETWORK_LAYER | TUNNEL_INFO | TRANSPORT_LAYER | APPLICATION_LAYER | SERVICE_INFO | TRACING_INFO | CAPTURE_INFO | DATA_LINK_LAYER
-	METRICS = L3_THROUGHPUT | L4_THROUGHPUT | TCP_SLOW | TCP_ERROR | APPLICATION | THROUGHPUT | ERROR | DELAY
+	TAG     = FLOW_INFO | UNIVERSAL_TAG | CUSTOM_TAG | NATIVE_TAG | NETWORK_LAYER | TUNNEL_INFO | TRANSPORT_LAYER | APPLICATION_LAYER | SERVICE_INFO | TRACING_INFO | CAPTURE_INFO | DATA_LINK_LAYER | LOG_INFO | PROFILE_INFO | ALERT_INFO
+	METRICS = L3_THROUGHPUT | L4_THROUGHPUT | TCP_SLOW | TCP_ERROR | APPLICATION | THROUGHPUT | ERROR | DELAY | METRICS_VALUE
 )
 
 var categoryStringMap = map[string]uint64{
@@ -633,6 +714,9 @@ var categoryStringMap = map[string]uint64{
 	"capture_info":      CAPTURE_INFO,
 	"event_info":        EVENT_INFO,
 	"data_link_layer":   DATA_LINK_LAYER,
+	"log_info":          LOG_INFO,
+	"profile_info":      PROFILE_INFO,
+	"alert_info":        ALERT_INFO,
 	CATEGORY_K8S_LABEL:  K8S_LABEL,
 
 	CATEGORY_METRICS: METRICS, // contains the following sucategories
@@ -644,6 +728,7 @@ var categoryStringMap = map[string]uint64{
 	"throughput":     THROUGHPUT,
 	"error":          ERROR,
 	"delay":          DELAY,
+	"metrics_value":  METRICS_VALUE,
 }
 
 func StringToCategoryBit(str string) uint64 {
@@ -22,6 +22,8 @@ import (
 	"testing"
 
 	yaml "gopkg.in/yaml.v2"
+
+	"github.com/deepflowio/deepflow/server/libs/queue"
 )
 
 type baseConfig struct {
@@ -49,3 +51,106 @@ func TestConfig(t *testing.T) {
 		t.Logf("yaml unmarshal, got: %s", string(bytes))
 	}
 }
+
+func TestDataSourceID(t *testing.T) {
+	for _, d := range []DataSourceID{APPLICATION_LOG, PROFILE, EXT_METRICS, PROMETHEUS} {
+		got, err := ToDataSourceID(d.String())
+		if err != nil || got != d {
+			t.Errorf("ToDataSourceID(%s) = %d %v, expected %d", d, got, err, d)
+		}
+		if d.IsMap() {
+			t.Errorf("datasource %s should not be map", d)
+		}
+		if d.TopicString() != TOPIC_PREFIX+d.String() {
+			t.Errorf("got topic %s of datasource %s", d.TopicString(), d)
+		}
+	}
+	if _, err := ToDataSourceID("profile"); err == nil {
+		t.Errorf("expected error for an invalid datasource")
+	}
+}
+
+func TestStringsToCategoryBits(t *testing.T) {
+	testCases := []struct {
+		categories []string
+		expected   uint64
+	}{
+		{[]string{"$tag.log_info"}, LOG_INFO},
+		{[]string{"$tag.profile_info"}, PROFILE_INFO},
+		{[]string{"$tag.alert_info"}, ALERT_INFO},
+		{[]string{"$metrics.metrics_value"}, METRICS_VALUE},
+		{[]string{"$tag.log_info", "$metrics.metrics_value", "log_level"}, LOG_INFO | METRICS_VALUE},
+	}
+	for _, c := range testCases {
+		if got := StringsToCategoryBits(c.categories); got != c.expected {
+			t.Errorf("StringsToCategoryBits(%v) = %x, expected %x", c.categories, got, c.expected)
+		}
+	}
+	for _, bit := range []uint64{LOG_INFO, PROFILE_INFO, ALERT_INFO} {
+		if bit&TAG == 0 || bit&METRICS != 0 {
+			t.Errorf("category %x should be a tag category", bit)
+		}
+	}
+	if METRICS_VALUE&METRICS == 0 || METRICS_VALUE&TAG != 0 {
+		t.Errorf("category metrics_value should be a metrics category")
+	}
+}
+
+func TestExporterCfgValidate(t *testing.T) {
+	testCases := []struct {
+		protocol    string
+		dataSources []string
+		enabled     bool
+		err         bool
+	}{
+		{"kafka", []string{"profile.in_process", "flow_log.l4_flow_log"}, true, false},
+		{"prometheus", []string{"flow_metrics.network_map.1m", "ext_metrics.metrics", "prometheus.samples"}, true, false},
+		{"prometheus", []string{"application_log.log"}, true, true},
+		{"opentelemetry", []string{"flow_log.l7_flow_log", "application_log.log", "event.alert_event", "prometheus.samples"}, true, false},
+		{"opentelemetry", []string{"profile.in_process"}, true, true},
+		{"opentelemetry", []string{"flow_log.l4_flow_log"}, true, true},
+		// disabled exporters are not checked
+		{"opentelemetry", []string{"profile.in_process"}, false, false},
+	}
+	for _, c := range testCases {
+		cfg := ExporterCfg{Protocol: c.protocol, DataSources: c.dataSources, Enabled: c.enabled}
+		if err := cfg.Validate(); (err != nil) != c.err {
+			t.Errorf("protocol %s datasources %v: expected error %v, got %v", c.protocol, c.dataSources, c.err, err)
+		}
+	}
+}
+
+func TestConfigValidateExporterCount(t *testing.T) {
+	c := Config{}
+	for i := 0; i < MAX_EXPORTERS_PER_DATASOURCE; i++ {
+		c.Exporters = append(c.Exporters, ExporterCfg{Protocol: "kafka", DataSources: []string{"prometheus.samples"}, Enabled: true})
+	}
+	c.Exporters = append(c.Exporters, ExporterCfg{Protocol: "kafka", DataSources: []string{"prometheus.samples"}})
+	if err := c.Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	c.Exporters = append(c.Exporters, ExporterCfg{Protocol: "kafka", DataSources: []string{"prometheus.samples"}, Enabled: true})
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error when the datasource is exported by more than %d exporters", MAX_EXPORTERS_PER_DATASOURCE)
+	}
+}
+
+func TestProducerIndex(t *testing.T) {
+	seen := make(map[int]bool)
+	for group := 0; group < MAX_PRODUCER_GROUPS_PER_DATASOURCE; group++ {
+		for i := 0; i < queue.MAX_QUEUE_COUNT; i++ {
+			index := ProducerIndex(group, i)
+			if index < 0 || index >= MAX_PRODUCERS_PER_DATASOURCE || seen[index] {
+				t.Fatalf("ProducerIndex(%d, %d) = %d is out of range or duplicated", group, i, index)
+			}
+			seen[index] = true
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic when the group exceeds the limit")
+		}
+	}()
+	ProducerIndex(MAX_PRODUCER_GROUPS_PER_DATASOURCE, 0)
+}
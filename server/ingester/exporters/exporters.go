@@ -29,7 +29,6 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/exporters/otlp_exporter"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/prometheus_exporter"
 	"github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
-	"github.com/deepflowio/deepflow/server/libs/queue"
 	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
@@ -37,7 +36,7 @@ var log = logging.MustGetLogger("exporters")
 
 const (
 	PUT_BATCH_SIZE               = 1024
-	MAX_EXPORTERS_PER_DATASOURCE = 8
+	MAX_EXPORTERS_PER_DATASOURCE = config.MAX_EXPORTERS_PER_DATASOURCE
 )
 
 type Exporter interface {
@@ -72,7 +71,7 @@ func NewExporters(cfg *config.Config) *Exporters {
 	log.Infof("init exporters: %+v", cfg.Exporters)
 
 	translation := enum_translation.NewEnumTranslation()
-	putCaches := make([]ExportersCache, config.MAX_DATASOURCE_ID*queue.MAX_QUEUE_COUNT*MAX_EXPORTERS_PER_DATASOURCE)
+	putCaches := make([]ExportersCache, config.MAX_DATASOURCE_ID*config.MAX_PRODUCERS_PER_DATASOURCE*MAX_EXPORTERS_PER_DATASOURCE)
 	exporters := make([]Exporter, 0)
 	dataSourceExporters := [config.MAX_DATASOURCE_ID][]Exporter{}
 	dataSourceExporterCfgs := [config.MAX_DATASOURCE_ID][]*config.ExporterCfg{}
@@ -318,8 +317,18 @@ func (es *Exporters) IsExportItem(item common.ExportItem, dataSourceId uint32, e
 	return true
 }
 
+// IsExportDataSource returns whether any exporter exports the datasource, producers could check it before building
+// export items. It is safe to call on nil Exporters.
+func (es *Exporters) IsExportDataSource(dataSourceId uint32) bool {
+	if es == nil || dataSourceId >= uint32(config.MAX_DATASOURCE_ID) {
+		return false
+	}
+	return len(es.dataSourceExporters[dataSourceId]) > 0
+}
+
+// decoderId is the producer index returned by config.ProducerIndex
 func (es *Exporters) getPutCache(dataSourceId, decoderId, exporterId int) *ExportersCache {
-	return &es.putCaches[(dataSourceId*queue.MAX_QUEUE_COUNT+decoderId)*MAX_EXPORTERS_PER_DATASOURCE+exporterId]
+	return &es.putCaches[(dataSourceId*config.MAX_PRODUCERS_PER_DATASOURCE+decoderId)*MAX_EXPORTERS_PER_DATASOURCE+exporterId]
 }
 
 func (es *Exporters) Put(dataSourceId uint32, decoderIndex int, item common.ExportItem) {
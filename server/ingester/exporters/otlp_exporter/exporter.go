@@ -24,6 +24,10 @@ import (
 
 	logging "github.com/op/go-logging"
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"golang.org/x/net/context"
@@ -53,6 +57,8 @@ type OtlpExporter struct {
 	dataQueues           queue.FixedMultiQueue
 	queueCount           int
 	grpcExporters        []ptraceotlp.GRPCClient
+	grpcLogExporters     []plogotlp.GRPCClient
+	grpcMetricExporters  []pmetricotlp.GRPCClient
 	grpcConns            []*grpc.ClientConn
 	grpcFailedCounters   []int
 	universalTagsManager *utag.UniversalTagsManager
@@ -95,6 +101,8 @@ func NewOtlpExporter(index int, config *exporters_cfg.ExporterCfg, universalTags
 		grpcConns:            make([]*grpc.ClientConn, config.QueueCount),
 		grpcFailedCounters:   make([]int, config.QueueCount),
 		grpcExporters:        make([]ptraceotlp.GRPCClient, config.QueueCount),
+		grpcLogExporters:     make([]plogotlp.GRPCClient, config.QueueCount),
+		grpcMetricExporters:  make([]pmetricotlp.GRPCClient, config.QueueCount),
 		config:               config,
 		counter:              &Counter{},
 	}
@@ -127,9 +135,41 @@ func (e *OtlpExporter) Close() {
 	log.Infof("otlp exporter %d stopping", e.index)
 }
 
+// otlpBatch holds the items of one signal type(traces/logs/metrics) to be exported
+type otlpBatch struct {
+	count     int
+	newBatch  func()
+	append    func(dst interface{})
+	toRequest func() exportRequest
+	toString  func() string
+}
+
 func (e *OtlpExporter) queueProcess(queueID int) {
-	var batchCount int
-	traces := ptrace.NewTraces()
+	var traces ptrace.Traces
+	var logs plog.Logs
+	var metrics pmetric.Metrics
+	traceBatch := &otlpBatch{
+		newBatch:  func() { traces = ptrace.NewTraces() },
+		append:    func(dst interface{}) { dst.(ptrace.ResourceSpansSlice).MoveAndAppendTo(traces.ResourceSpans()) },
+		toRequest: func() exportRequest { return ptraceotlp.NewExportRequestFromTraces(traces) },
+		toString:  func() string { return tracesToString(traces) },
+	}
+	logBatch := &otlpBatch{
+		newBatch:  func() { logs = plog.NewLogs() },
+		append:    func(dst interface{}) { dst.(plog.ResourceLogsSlice).MoveAndAppendTo(logs.ResourceLogs()) },
+		toRequest: func() exportRequest { return plogotlp.NewExportRequestFromLogs(logs) },
+		toString:  func() string { return fmt.Sprintf("%d log records", logs.LogRecordCount()) },
+	}
+	metricBatch := &otlpBatch{
+		newBatch:  func() { metrics = pmetric.NewMetrics() },
+		append:    func(dst interface{}) { dst.(pmetric.ResourceMetricsSlice).MoveAndAppendTo(metrics.ResourceMetrics()) },
+		toRequest: func() exportRequest { return pmetricotlp.NewExportRequestFromMetrics(metrics) },
+		toString:  func() string { return fmt.Sprintf("%d metric data points", metrics.DataPointCount()) },
+	}
+	batches := []*otlpBatch{traceBatch, logBatch, metricBatch}
+	for _, b := range batches {
+		b.newBatch()
+	}
 	items := make([]interface{}, QUEUE_BATCH_COUNT)
 
 	ctx := context.Background()
@@ -137,24 +177,26 @@ func (e *OtlpExporter) queueProcess(queueID int) {
 		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.config.ExtraHeaders))
 	}
 
-	doExport := func() {
-		if batchCount == 0 {
+	doExport := func(b *otlpBatch) {
+		if b.count == 0 {
 			return
 		}
 
-		if err := e.grpcExport(ctx, queueID, ptraceotlp.NewExportRequestFromTraces(traces)); err == nil {
-			e.counter.SendCounter += int64(batchCount)
+		if err := e.grpcExport(ctx, queueID, b.toRequest()); err == nil {
+			e.counter.SendCounter += int64(b.count)
 		}
-		batchCount = 0
-		log.Debugf(tracesToString(traces))
-		traces = ptrace.NewTraces()
+		b.count = 0
+		log.Debugf(b.toString())
+		b.newBatch()
 	}
 
 	for e.running {
 		n := e.dataQueues.Gets(queue.HashKey(queueID), items)
 		for _, item := range items[:n] {
 			if item == nil {
-				doExport()
+				for _, b := range batches {
+					doExport(b)
+				}
 				continue
 			}
 
@@ -173,19 +215,39 @@ func (e *OtlpExporter) queueProcess(queueID int) {
 				exportItem.Release()
 				continue
 			}
-			rsSlice := dst.(ptrace.ResourceSpansSlice)
-			rsSlice.MoveAndAppendTo(traces.ResourceSpans())
+			var b *otlpBatch
+			switch dst.(type) {
+			case ptrace.ResourceSpansSlice:
+				b = traceBatch
+			case plog.ResourceLogsSlice:
+				b = logBatch
+			case pmetric.ResourceMetricsSlice:
+				b = metricBatch
+			default:
+				if e.counter.DropCounter == 0 {
+					log.Warningf("otlp exporter unsupport encoded type %T", dst)
+				}
+				e.counter.DropCounter++
+				exportItem.Release()
+				continue
+			}
+			b.append(dst)
 
-			batchCount++
-			if batchCount >= e.config.BatchSize {
-				doExport()
+			b.count++
+			if b.count >= e.config.BatchSize {
+				doExport(b)
 			}
 			exportItem.Release()
 		}
 	}
 }
 
-func (e *OtlpExporter) grpcExport(ctx context.Context, queueID int, req ptraceotlp.ExportRequest) error {
+// exportRequest is implemented by ptraceotlp.ExportRequest, plogotlp.ExportRequest and pmetricotlp.ExportRequest
+type exportRequest interface {
+	MarshalJSON() ([]byte, error)
+}
+
+func (e *OtlpExporter) grpcExport(ctx context.Context, queueID int, req exportRequest) error {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Warningf("grpc otlp export error: %s", r)
@@ -206,10 +268,20 @@ func (e *OtlpExporter) grpcExport(ctx context.Context, queueID int, req ptraceot
 			return err
 		}
 	}
-	_, err := e.grpcExporters[queueID].Export(ctx, req)
+	var err error
+	switch r := req.(type) {
+	case ptraceotlp.ExportRequest:
+		_, err = e.grpcExporters[queueID].Export(ctx, r)
+	case plogotlp.ExportRequest:
+		_, err = e.grpcLogExporters[queueID].Export(ctx, r)
+	case pmetricotlp.ExportRequest:
+		_, err = e.grpcMetricExporters[queueID].Export(ctx, r)
+	default:
+		err = fmt.Errorf("unsupport otlp request type %T", req)
+	}
 	if err != nil {
 		if e.counter.DropCounter == 0 {
-			log.Warningf("otlp exporter %d send grpc traces failed. faildCounter=%d, err: %s", e.index, e.grpcFailedCounters[queueID], err)
+			log.Warningf("otlp exporter %d send grpc %T failed. faildCounter=%d, err: %s", e.index, req, e.grpcFailedCounters[queueID], err)
 		}
 		e.counter.DropCounter++
 		e.grpcExporters[queueID] = nil
@@ -249,6 +321,8 @@ func (e *OtlpExporter) newGrpcExporter(queueID int) error {
 
 	e.grpcConns[queueID] = conn
 	e.grpcExporters[queueID] = ptraceotlp.NewGRPCClient(conn)
+	e.grpcLogExporters[queueID] = plogotlp.NewGRPCClient(conn)
+	e.grpcMetricExporters[queueID] = pmetricotlp.NewGRPCClient(conn)
 	return nil
 }
 
@@ -32,6 +32,8 @@ const (
 	DefaultDecoderQueueCount = 2
 	DefaultDecoderQueueSize  = 4096
 	DefaultExtMetricsTTL     = 168 // hour
+
+	DefaultOTelCumulativeCacheSize = 1000000
 )
 
 type Config struct {
@@ -40,6 +42,8 @@ type Config struct {
 	DecoderQueueCount int                   `yaml:"ext-metrics-decoder-queue-count"`
 	DecoderQueueSize  int                   `yaml:"ext-metrics-decoder-queue-size"`
 	TTL               int                   `yaml:"ext-metrics-ttl-hour"`
+
+	OTelCumulativeCacheSize int `yaml:"ext-metrics-otel-cumulative-cache-size"`
 }
 
 type ExtMetricsConfig struct {
@@ -53,6 +57,9 @@ func (c *Config) Validate() error {
 	if c.TTL <= 0 {
 		c.TTL = DefaultExtMetricsTTL
 	}
+	if c.OTelCumulativeCacheSize <= 0 {
+		c.OTelCumulativeCacheSize = DefaultOTelCumulativeCacheSize
+	}
 
 	return nil
 }
@@ -65,6 +72,8 @@ func Load(base *config.Config, path string) *Config {
 			DecoderQueueSize:  DefaultDecoderQueueSize,
 			CKWriterConfig:    config.CKWriterConfig{QueueCount: 1, QueueSize: 100000, BatchSize: 51200, FlushTimeout: 10},
 			TTL:               DefaultExtMetricsTTL,
+
+			OTelCumulativeCacheSize: DefaultOTelCumulativeCacheSize,
 		},
 	}
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -17,12 +17,21 @@
 package dbwriter
 
 import (
+	"fmt"
+	"reflect"
+	"time"
+	"unsafe"
+
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
 	"github.com/deepflowio/deepflow/server/ingester/flow_tag"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
 	"github.com/deepflowio/deepflow/server/libs/pool"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 )
 
 const (
@@ -30,25 +39,27 @@ const (
 )
 
 type ExtMetrics struct {
-	Timestamp uint32 // s
+	pool.ReferenceCount
+
+	Timestamp uint32 `json:"time" category:"$tag" sub:"flow_info"` // s
 	MsgType   datatype.MessageType
 
 	UniversalTag flow_metrics.UniversalTag
 
-	VTableName string
+	VTableName string `json:"virtual_table_name" category:"$tag" sub:"flow_info"`
 
 	AgentID uint16
 
 	// Not stored, only determines which database to store in.
 	// When Orgid is 0 or 1, it is stored in database '<DatabaseName()>', otherwise stored in '<OrgId>_<DatabaseName()>'.
 	OrgId, RawOrgId uint16 // RawOrgId is read from server-stats message, only used to distinguish which database data is written to
-	TeamID          uint16
+	TeamID          uint16 `json:"team_id" category:"$tag"`
 
-	TagNames  []string
-	TagValues []string
+	TagNames  []string `json:"tag_names" category:"$tag" sub:"native_tag" data_type:"[]string"`
+	TagValues []string `json:"tag_values" category:"$tag" sub:"native_tag" data_type:"[]string"`
 
-	MetricsFloatNames  []string
-	MetricsFloatValues []float64
+	MetricsFloatNames  []string  `json:"metrics_names" category:"$metrics" sub:"metrics_value" data_type:"[]string"`
+	MetricsFloatValues []float64 `json:"metrics_values" category:"$metrics" sub:"metrics_value" data_type:"[]float64"`
 }
 
 func (m *ExtMetrics) IsValid() bool {
@@ -133,6 +144,48 @@ func (m *ExtMetrics) Release() {
 	ReleaseExtMetrics(m)
 }
 
+func (m *ExtMetrics) DataSource() uint32 {
+	return uint32(exporterconfig.EXT_METRICS)
+}
+
+func (m *ExtMetrics) EncodeTo(protocol exporterconfig.ExportProtocol, utags *utag.UniversalTagsManager, cfg *exporterconfig.ExporterCfg) (interface{}, error) {
+	switch protocol {
+	case exporterconfig.PROTOCOL_KAFKA:
+		tags := m.QueryUniversalTags(utags)
+		k8sLabels := utags.QueryCustomK8sLabels(m.OrgId, m.UniversalTag.PodID)
+		return exportercommon.EncodeToJson(m, int(m.DataSource()), cfg, tags, tags, k8sLabels, k8sLabels), nil
+	case exporterconfig.PROTOCOL_PROMETHEUS:
+		tags := m.QueryUniversalTags(utags)
+		labels := exportercommon.GetPrometheusLabels(m, int(m.DataSource()), cfg, tags, tags)
+		labels = exportercommon.AppendPrometheusLabels(labels, m.TagNames, m.TagValues, cfg)
+		// metric name is '<virtual_table_name>_<metrics_name>', e.g.: 'influxdb_cpu_usage_idle'
+		return exportercommon.EncodeMetricsToPrometheus(labels, m.VTableName+"_", m.MetricsFloatNames, m.MetricsFloatValues, int64(m.Timestamp)*1000, cfg), nil
+	case exporterconfig.PROTOCOL_OTLP:
+		// metric name is '<virtual_table_name>.<metrics_name>', e.g.: 'influxdb.cpu.usage_idle'
+		return exportercommon.EncodeMetricsToOtlp(m, int(m.DataSource()), cfg, m.QueryUniversalTags(utags), m.TagNames, m.TagValues, m.VTableName+".", m.MetricsFloatNames, m.MetricsFloatValues), nil
+	default:
+		return nil, fmt.Errorf("ext metrics unsupport export to %s", protocol)
+	}
+}
+
+func (m *ExtMetrics) QueryUniversalTags(utags *utag.UniversalTagsManager) *utag.UniversalTags {
+	t := &m.UniversalTag
+	return utags.QueryUniversalTags(m.OrgId,
+		t.RegionID, t.AZID, t.HostID, t.PodNSID, t.PodClusterID, t.SubnetID, t.VTAPID,
+		uint8(t.L3DeviceType), t.AutoServiceType, t.AutoInstanceType,
+		t.L3DeviceID, t.AutoServiceID, t.AutoInstanceID, t.PodNodeID, t.PodGroupID, t.PodID, uint32(t.L3EpcID), t.GPID, t.ServiceID,
+		t.IsIPv6 == 0, t.IP, t.IP6,
+	)
+}
+
+func (m *ExtMetrics) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(m)), offset, kind, dataType)
+}
+
+func (m *ExtMetrics) TimestampUs() int64 {
+	return int64(time.Duration(m.Timestamp) * time.Second / time.Microsecond)
+}
+
 func (m *ExtMetrics) GenCKTable(cluster, storagePolicy, ckdbType string, ttl int, coldStorage *ckdb.ColdStorage) *ckdb.Table {
 	timeKey := "time"
 	engine := ckdb.MergeTree
@@ -249,12 +302,17 @@ var extMetricsPool = pool.NewLockFreePool(func() interface{} {
 })
 
 func AcquireExtMetrics() *ExtMetrics {
-	return extMetricsPool.Get().(*ExtMetrics)
+	m := extMetricsPool.Get().(*ExtMetrics)
+	m.Reset()
+	return m
 }
 
 var emptyUniversalTag = flow_metrics.UniversalTag{}
 
 func ReleaseExtMetrics(m *ExtMetrics) {
+	if m == nil || m.SubReferenceCount() {
+		return
+	}
 	m.UniversalTag = emptyUniversalTag
 	m.TagNames = m.TagNames[:0]
 	m.TagValues = m.TagValues[:0]
@@ -23,8 +23,12 @@ import (
 
 	"github.com/influxdata/influxdb/models"
 	logging "github.com/op/go-logging"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
 
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/config"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/dbwriter"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
@@ -61,6 +65,8 @@ type Decoder struct {
 	platformData      *grpc.PlatformInfoTable
 	inQueue           queue.QueueReader
 	extMetricsWriters [dbwriter.MAX_DB_ID]*dbwriter.ExtMetricsWriter
+	exporters         *exporters.Exporters
+	exportIndex       int
 	debugEnabled      bool
 	config            *config.Config
 
@@ -70,6 +76,10 @@ type Decoder struct {
 	vtapIDToUniversalTag     [grpc.MAX_ORG_COUNT]map[uint16]*flow_metrics.UniversalTag
 	platformDataVersion      [grpc.MAX_ORG_COUNT]uint64
 
+	// OpenTelemetry delta metrics are converted to cumulative metrics
+	otelCumulativeCache *OTelCumulativeCache
+	otelSamples         []otelSample
+
 	orgId, teamId uint16
 
 	counter *Counter
@@ -81,6 +91,9 @@ func NewDecoder(
 	platformData *grpc.PlatformInfoTable,
 	inQueue queue.QueueReader,
 	extMetricsWriters [dbwriter.MAX_DB_ID]*dbwriter.ExtMetricsWriter,
+	exporters *exporters.Exporters,
+	exportIndex int,
+	otelCumulativeCache *OTelCumulativeCache,
 	config *config.Config,
 ) *Decoder {
 	d := &Decoder{
@@ -90,8 +103,12 @@ func NewDecoder(
 		inQueue:           inQueue,
 		debugEnabled:      log.IsEnabledFor(logging.DEBUG),
 		extMetricsWriters: extMetricsWriters,
+		exporters:         exporters,
+		exportIndex:       exportIndex,
 		config:            config,
 		counter:           &Counter{},
+
+		otelCumulativeCache: otelCumulativeCache,
 	}
 	for i := 0; i < grpc.MAX_ORG_COUNT; i++ {
 		d.podNameToUniversalTag[i] = make(map[string]*flow_metrics.UniversalTag)
@@ -115,10 +132,15 @@ func (d *Decoder) Run() {
 
 	buffer := make([]interface{}, BUFFER_SIZE)
 	decoder := &codec.SimpleDecoder{}
+	pbMetricsData := &metricsv1.MetricsData{}
 	for {
 		n := d.inQueue.Gets(buffer)
 		for i := 0; i < n; i++ {
 			if buffer[i] == nil {
+				d.export(nil)
+				if d.otelCumulativeCache != nil {
+					d.otelCumulativeCache.expire()
+				}
 				continue
 			}
 			d.counter.InCount++
@@ -133,6 +155,8 @@ func (d *Decoder) Run() {
 				d.handleTelegraf(recvBytes.VtapID, decoder)
 			} else if d.msgType == datatype.MESSAGE_TYPE_DFSTATS || d.msgType == datatype.MESSAGE_TYPE_SERVER_DFSTATS {
 				d.handleDeepflowStats(recvBytes.VtapID, decoder)
+			} else if d.msgType == datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS {
+				d.handleOTelMetrics(recvBytes.VtapID, decoder, pbMetricsData)
 			}
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
@@ -175,10 +199,18 @@ func (d *Decoder) sendTelegraf(vtapID uint16, point models.Point) {
 		d.counter.ErrMetrics++
 		return
 	}
+	d.export(extMetrics)
 	d.extMetricsWriters[int(dbwriter.EXT_METRICS_DB_ID)].Write(extMetrics)
 	d.counter.OutCount++
 }
 
+func (d *Decoder) export(item exportercommon.ExportItem) {
+	if !d.exporters.IsExportDataSource(uint32(exporterconfig.EXT_METRICS)) {
+		return
+	}
+	d.exporters.Put(uint32(exporterconfig.EXT_METRICS), d.exportIndex, item)
+}
+
 func (d *Decoder) handleDeepflowStats(vtapID uint16, decoder *codec.SimpleDecoder) {
 	for !decoder.IsEnd() {
 		pbStats := &pb.Stats{}
@@ -0,0 +1,431 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/dbwriter"
+	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const (
+	VTABLE_PREFIX_OTEL = "otel."
+	OTEL_POD           = "k8s.pod.name"
+	OTEL_METRIC_VALUE  = "value"
+
+	OTEL_SUFFIX_BUCKET = "_bucket"
+	OTEL_SUFFIX_COUNT  = "_count"
+	OTEL_SUFFIX_SUM    = "_sum"
+	OTEL_TAG_LE        = "le"
+	OTEL_TAG_QUANTILE  = "quantile"
+
+	OTEL_CUMULATIVE_EXPIRE_INTERVAL = 60  // s
+	OTEL_CUMULATIVE_EXPIRE_TIME     = 600 // s
+
+	ZLIB_HEADER = 0x78
+)
+
+type otelCumulativeValue struct {
+	value    float64
+	lastSeen uint32
+}
+
+type OTelCumulativeCacheCounter struct {
+	Size uint64 `statsd:"size,gauge"`
+	Drop uint64 `statsd:"drop"`
+}
+
+// OTelCumulativeCache accumulates the values of delta metrics, so that the delta metrics are stored as
+// cumulative metrics, which is consistent with Prometheus and Telegraf.
+// The cache is shared by all the decoders, because the receiver does not dispatch the messages of an agent
+// to a fixed decoder. The totals only live in memory, so they restart from 0 after the ingester restarts,
+// which is the same as a counter reset of Prometheus.
+type OTelCumulativeCache struct {
+	sync.Mutex
+	values     map[string]*otelCumulativeValue
+	maxSize    int
+	lastExpire uint32
+
+	counter *OTelCumulativeCacheCounter
+	utils.Closable
+}
+
+func NewOTelCumulativeCache(maxSize int) *OTelCumulativeCache {
+	return &OTelCumulativeCache{
+		values:     make(map[string]*otelCumulativeValue),
+		maxSize:    maxSize,
+		lastExpire: uint32(time.Now().Unix()),
+		counter:    &OTelCumulativeCacheCounter{},
+	}
+}
+
+func (c *OTelCumulativeCache) GetCounter() interface{} {
+	c.Lock()
+	counter := c.counter
+	c.counter = &OTelCumulativeCacheCounter{}
+	counter.Size = uint64(len(c.values))
+	c.Unlock()
+	return counter
+}
+
+// add returns the cumulative value of the series, and returns false if the series is new and the cache is full
+func (c *OTelCumulativeCache) add(key string, delta float64) (float64, bool) {
+	now := uint32(time.Now().Unix())
+	c.Lock()
+	defer c.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		if len(c.values) >= c.maxSize {
+			c.counter.Drop++
+			return 0, false
+		}
+		v = &otelCumulativeValue{}
+		c.values[key] = v
+	}
+	v.value += delta
+	v.lastSeen = now
+	return v.value, true
+}
+
+// expire removes the series which have not been updated for OTEL_CUMULATIVE_EXPIRE_TIME
+func (c *OTelCumulativeCache) expire() {
+	now := uint32(time.Now().Unix())
+	c.Lock()
+	defer c.Unlock()
+	if now-c.lastExpire < OTEL_CUMULATIVE_EXPIRE_INTERVAL {
+		return
+	}
+	c.lastExpire = now
+	for key, v := range c.values {
+		if now-v.lastSeen > OTEL_CUMULATIVE_EXPIRE_TIME {
+			delete(c.values, key)
+		}
+	}
+}
+
+// otelDataPoint is the common part of all the samples generated by one OpenTelemetry data point
+type otelDataPoint struct {
+	podName   string
+	tagNames  []string
+	tagValues []string
+	timestamp uint32 // s
+	delta     bool
+	seriesKey string // only used by delta data points
+}
+
+// otelSample is one sample of the virtual table 'otel.<name>', the extra tag is 'le' or 'quantile'
+type otelSample struct {
+	point         *otelDataPoint
+	name          string
+	extraTagName  string
+	extraTagValue string
+	value         float64
+}
+
+// handleOTelMetrics decodes the OpenTelemetry metrics sent by the agent. Each payload of the message is a 4-byte length
+// followed by an OTLP MetricsData encoded in Protobuf, which is zlib-compressed if the agent enables compression.
+// A MetricsData starts with the tag of field 'resource_metrics' (0x0a), while a zlib stream starts with 0x78.
+func (d *Decoder) handleOTelMetrics(vtapID uint16, decoder *codec.SimpleDecoder, pbMetricsData *metricsv1.MetricsData) {
+	var err error
+	for !decoder.IsEnd() {
+		pbMetricsData.Reset()
+		bytes := decoder.ReadBytes()
+		if len(bytes) > 0 {
+			if bytes[0] == ZLIB_HEADER {
+				bytes, err = decompressZlib(bytes)
+			}
+			if err == nil {
+				err = proto.Unmarshal(bytes, pbMetricsData)
+			}
+		}
+		if decoder.Failed() || err != nil {
+			if d.counter.ErrorCount == 0 {
+				log.Errorf("OpenTelemetry metrics decode failed, offset=%d len=%d err: %s", decoder.Offset(), len(decoder.Bytes()), err)
+			}
+			d.counter.ErrorCount++
+			return
+		}
+		if d.debugEnabled {
+			log.Debugf("decoder %d vtap %d recv otel metrics: %s", d.index, vtapID, pbMetricsData)
+		}
+
+		for _, resourceMetrics := range pbMetricsData.GetResourceMetrics() {
+			resAttributes := resourceMetrics.GetResource().GetAttributes()
+			for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+				for _, metric := range scopeMetrics.GetMetrics() {
+					d.sendOTelMetric(vtapID, resAttributes, metric)
+				}
+			}
+		}
+	}
+}
+
+func (d *Decoder) sendOTelMetric(vtapID uint16, resAttributes []*v11.KeyValue, metric *metricsv1.Metric) {
+	name := exportercommon.PrometheusName(metric.GetName())
+	if name == "" {
+		if d.counter.ErrMetrics == 0 {
+			log.Warningf("OpenTelemetry metric name is empty: %s", metric)
+		}
+		d.counter.ErrMetrics++
+		return
+	}
+
+	var ok bool
+	d.otelSamples, ok = appendOTelSamples(d.otelSamples[:0], vtapID, d.orgId, name, resAttributes, metric)
+	if !ok {
+		if d.counter.DropUnsupportedMetrics&0xff == 0 {
+			log.Warningf("drop unsupported OpenTelemetry metric: %s. total drop %d", name, d.counter.DropUnsupportedMetrics)
+		}
+		d.counter.DropUnsupportedMetrics++
+		return
+	}
+	for i := range d.otelSamples {
+		sample := &d.otelSamples[i]
+		if sample.point.delta {
+			// the new series exceeding the cache size are dropped, which are counted by the cache
+			if sample.value, ok = d.otelCumulativeCache.add(sample.seriesKey(), sample.value); !ok {
+				continue
+			}
+		}
+		d.writeOTelSample(vtapID, sample)
+	}
+}
+
+// appendOTelSamples converts the data points of the metric to samples, and returns false if the metric type is unsupported
+func appendOTelSamples(samples []otelSample, vtapID, orgId uint16, name string, resAttributes []*v11.KeyValue, metric *metricsv1.Metric) ([]otelSample, bool) {
+	switch data := metric.GetData().(type) {
+	case *metricsv1.Metric_Gauge:
+		for _, p := range data.Gauge.GetDataPoints() {
+			if isNoRecordedValue(p.GetFlags()) {
+				continue
+			}
+			point := newOTelDataPoint(vtapID, orgId, resAttributes, p.GetAttributes(), p.GetTimeUnixNano(), false)
+			samples = append(samples, otelSample{point, name, "", "", numberDataPointValue(p)})
+		}
+	case *metricsv1.Metric_Sum:
+		delta := data.Sum.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, p := range data.Sum.GetDataPoints() {
+			if isNoRecordedValue(p.GetFlags()) {
+				continue
+			}
+			point := newOTelDataPoint(vtapID, orgId, resAttributes, p.GetAttributes(), p.GetTimeUnixNano(), delta)
+			samples = append(samples, otelSample{point, name, "", "", numberDataPointValue(p)})
+		}
+	case *metricsv1.Metric_Histogram:
+		delta := data.Histogram.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, p := range data.Histogram.GetDataPoints() {
+			if isNoRecordedValue(p.GetFlags()) {
+				continue
+			}
+			point := newOTelDataPoint(vtapID, orgId, resAttributes, p.GetAttributes(), p.GetTimeUnixNano(), delta)
+			var cumulativeCount uint64
+			bucketCounts := p.GetBucketCounts()
+			for i, bound := range p.GetExplicitBounds() {
+				if i >= len(bucketCounts) {
+					break
+				}
+				cumulativeCount += bucketCounts[i]
+				samples = append(samples, otelSample{point, name + OTEL_SUFFIX_BUCKET, OTEL_TAG_LE, formatBound(bound), float64(cumulativeCount)})
+			}
+			samples = appendOTelHistogramTotal(samples, point, name, p.GetCount(), p.Sum)
+		}
+	case *metricsv1.Metric_ExponentialHistogram:
+		delta := data.ExponentialHistogram.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, p := range data.ExponentialHistogram.GetDataPoints() {
+			if isNoRecordedValue(p.GetFlags()) {
+				continue
+			}
+			point := newOTelDataPoint(vtapID, orgId, resAttributes, p.GetAttributes(), p.GetTimeUnixNano(), delta)
+			samples = appendOTelExponentialBuckets(samples, point, name, p)
+			samples = appendOTelHistogramTotal(samples, point, name, p.GetCount(), p.Sum)
+		}
+	case *metricsv1.Metric_Summary:
+		// summary data points are always cumulative
+		for _, p := range data.Summary.GetDataPoints() {
+			if isNoRecordedValue(p.GetFlags()) {
+				continue
+			}
+			point := newOTelDataPoint(vtapID, orgId, resAttributes, p.GetAttributes(), p.GetTimeUnixNano(), false)
+			for _, q := range p.GetQuantileValues() {
+				samples = append(samples, otelSample{point, name, OTEL_TAG_QUANTILE, formatBound(q.GetQuantile()), q.GetValue()})
+			}
+			samples = append(samples, otelSample{point, name + OTEL_SUFFIX_COUNT, "", "", float64(p.GetCount())})
+			samples = append(samples, otelSample{point, name + OTEL_SUFFIX_SUM, "", "", p.GetSum()})
+		}
+	default:
+		return samples, false
+	}
+	return samples, true
+}
+
+// appendOTelExponentialBuckets converts the exponential buckets to explicit buckets,
+// refer to: https://opentelemetry.io/docs/specs/otel/metrics/data-model/#exponentialhistogram
+func appendOTelExponentialBuckets(samples []otelSample, point *otelDataPoint, name string, p *metricsv1.ExponentialHistogramDataPoint) []otelSample {
+	// the upper bound of the bucket with index i is base^(i+1), base = 2^(2^-scale)
+	factor := math.Exp2(-float64(p.GetScale()))
+	var cumulativeCount uint64
+
+	negative := p.GetNegative()
+	negativeCounts := negative.GetBucketCounts()
+	for i := len(negativeCounts) - 1; i >= 0; i-- {
+		cumulativeCount += negativeCounts[i]
+		bound := -math.Exp2(float64(int(negative.GetOffset())+i) * factor)
+		samples = append(samples, otelSample{point, name + OTEL_SUFFIX_BUCKET, OTEL_TAG_LE, formatBound(bound), float64(cumulativeCount)})
+	}
+
+	cumulativeCount += p.GetZeroCount()
+	samples = append(samples, otelSample{point, name + OTEL_SUFFIX_BUCKET, OTEL_TAG_LE, formatBound(p.GetZeroThreshold()), float64(cumulativeCount)})
+
+	positive := p.GetPositive()
+	for i, count := range positive.GetBucketCounts() {
+		cumulativeCount += count
+		bound := math.Exp2(float64(int(positive.GetOffset())+i+1) * factor)
+		samples = append(samples, otelSample{point, name + OTEL_SUFFIX_BUCKET, OTEL_TAG_LE, formatBound(bound), float64(cumulativeCount)})
+	}
+	return samples
+}
+
+// appendOTelHistogramTotal appends the '+Inf' bucket, the count and the sum of the histogram
+func appendOTelHistogramTotal(samples []otelSample, point *otelDataPoint, name string, count uint64, sum *float64) []otelSample {
+	samples = append(samples, otelSample{point, name + OTEL_SUFFIX_BUCKET, OTEL_TAG_LE, "+Inf", float64(count)})
+	samples = append(samples, otelSample{point, name + OTEL_SUFFIX_COUNT, "", "", float64(count)})
+	if sum != nil {
+		samples = append(samples, otelSample{point, name + OTEL_SUFFIX_SUM, "", "", *sum})
+	}
+	return samples
+}
+
+func newOTelDataPoint(vtapID, orgId uint16, resAttributes, attributes []*v11.KeyValue, timeUnixNano uint64, delta bool) *otelDataPoint {
+	point := &otelDataPoint{
+		timestamp: uint32(timeUnixNano / uint64(time.Second)),
+		delta:     delta,
+	}
+	if point.timestamp == 0 {
+		point.timestamp = uint32(time.Now().Unix())
+	}
+	point.tagNames = make([]string, 0, len(resAttributes)+len(attributes))
+	point.tagValues = make([]string, 0, len(resAttributes)+len(attributes))
+	for _, attr := range resAttributes {
+		value := common.OTelAnyValueString(attr.GetValue())
+		if attr.GetKey() == OTEL_POD {
+			point.podName = value
+		}
+		point.tagNames = append(point.tagNames, exportercommon.PrometheusName(attr.GetKey()))
+		point.tagValues = append(point.tagValues, value)
+	}
+	for _, attr := range attributes {
+		point.tagNames = append(point.tagNames, exportercommon.PrometheusName(attr.GetKey()))
+		point.tagValues = append(point.tagValues, common.OTelAnyValueString(attr.GetValue()))
+	}
+
+	if delta {
+		// the series key consists of the agent, the organization and the sorted tags
+		indexes := make([]int, len(point.tagNames))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		sort.Slice(indexes, func(i, j int) bool {
+			return point.tagNames[indexes[i]] < point.tagNames[indexes[j]]
+		})
+		var sb strings.Builder
+		sb.WriteString(strconv.Itoa(int(vtapID)))
+		sb.WriteByte('|')
+		sb.WriteString(strconv.Itoa(int(orgId)))
+		for _, i := range indexes {
+			sb.WriteByte('|')
+			sb.WriteString(point.tagNames[i])
+			sb.WriteByte('=')
+			sb.WriteString(point.tagValues[i])
+		}
+		point.seriesKey = sb.String()
+	}
+	return point
+}
+
+func (s *otelSample) seriesKey() string {
+	return s.name + "|" + s.extraTagName + "=" + s.extraTagValue + "|" + s.point.seriesKey
+}
+
+// writeOTelSample writes one sample to the virtual table 'otel.<name>' whose metric name is 'value',
+// so that it can be queried in the same way as the Telegraf metrics in ext_metrics.
+func (d *Decoder) writeOTelSample(vtapID uint16, s *otelSample) {
+	m := dbwriter.AcquireExtMetrics()
+	m.Timestamp = s.point.timestamp
+	m.MsgType = datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS
+	m.VTableName = VTABLE_PREFIX_OTEL + s.name
+	m.OrgId, m.TeamID = d.orgId, d.teamId
+	m.TagNames = append(m.TagNames, s.point.tagNames...)
+	m.TagValues = append(m.TagValues, s.point.tagValues...)
+	if s.extraTagName != "" {
+		m.TagNames = append(m.TagNames, s.extraTagName)
+		m.TagValues = append(m.TagValues, s.extraTagValue)
+	}
+	d.fillExtMetricsBase(m, vtapID, s.point.podName, true)
+	m.MetricsFloatNames = append(m.MetricsFloatNames, OTEL_METRIC_VALUE)
+	m.MetricsFloatValues = append(m.MetricsFloatValues, s.value)
+
+	d.export(m)
+	d.extMetricsWriters[int(dbwriter.EXT_METRICS_DB_ID)].Write(m)
+	d.counter.OutCount++
+}
+
+func numberDataPointValue(p *metricsv1.NumberDataPoint) float64 {
+	switch v := p.GetValue().(type) {
+	case *metricsv1.NumberDataPoint_AsDouble:
+		return v.AsDouble
+	case *metricsv1.NumberDataPoint_AsInt:
+		return float64(v.AsInt)
+	}
+	return 0
+}
+
+func isNoRecordedValue(flags uint32) bool {
+	return flags&uint32(metricsv1.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0
+}
+
+func formatBound(bound float64) string {
+	if math.IsInf(bound, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
+
+func decompressZlib(in []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// formatOTelSamples formats the samples as 'name{extra tag}=value' to compare easily
+func formatOTelSamples(samples []otelSample) []string {
+	ret := make([]string, 0, len(samples))
+	for _, s := range samples {
+		if s.extraTagName != "" {
+			ret = append(ret, fmt.Sprintf("%s{%s=%s}=%v", s.name, s.extraTagName, s.extraTagValue, s.value))
+		} else {
+			ret = append(ret, fmt.Sprintf("%s=%v", s.name, s.value))
+		}
+	}
+	return ret
+}
+
+func otelStringKeyValue(key, value string) *v11.KeyValue {
+	return &v11.KeyValue{Key: key, Value: &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: value}}}
+}
+
+func TestAppendOTelSamples(t *testing.T) {
+	sum := 10.5
+	noRecordedValue := uint32(metricsv1.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK)
+	testCases := []struct {
+		name     string
+		data     interface{}
+		delta    bool
+		expected []string
+	}{
+		{
+			name: "gauge",
+			data: &metricsv1.Metric_Gauge{Gauge: &metricsv1.Gauge{DataPoints: []*metricsv1.NumberDataPoint{
+				{Value: &metricsv1.NumberDataPoint_AsDouble{AsDouble: 1.5}},
+				{Value: &metricsv1.NumberDataPoint_AsInt{AsInt: 2}, Flags: noRecordedValue},
+			}}},
+			expected: []string{"m=1.5"},
+		},
+		{
+			name: "cumulative sum",
+			data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints:             []*metricsv1.NumberDataPoint{{Value: &metricsv1.NumberDataPoint_AsInt{AsInt: 3}}},
+			}},
+			expected: []string{"m=3"},
+		},
+		{
+			name: "delta sum",
+			data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints:             []*metricsv1.NumberDataPoint{{Value: &metricsv1.NumberDataPoint_AsInt{AsInt: 3}}},
+			}},
+			delta:    true,
+			expected: []string{"m=3"},
+		},
+		{
+			name: "histogram",
+			data: &metricsv1.Metric_Histogram{Histogram: &metricsv1.Histogram{
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricsv1.HistogramDataPoint{
+					{Count: 6, Sum: &sum, ExplicitBounds: []float64{0.5, 1}, BucketCounts: []uint64{1, 2, 3}},
+					{Count: 1, Flags: noRecordedValue},
+				},
+			}},
+			expected: []string{"m_bucket{le=0.5}=1", "m_bucket{le=1}=3", "m_bucket{le=+Inf}=6", "m_count=6", "m_sum=10.5"},
+		},
+		{
+			name: "exponential histogram scale 0",
+			data: &metricsv1.Metric_ExponentialHistogram{ExponentialHistogram: &metricsv1.ExponentialHistogram{
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints: []*metricsv1.ExponentialHistogramDataPoint{{
+					Count: 10, Scale: 0, ZeroCount: 1,
+					// the absolute values are in (1, 2], (2, 4]
+					Negative: &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: 0, BucketCounts: []uint64{1, 2}},
+					// (2, 4], (4, 8], (8, 16]
+					Positive: &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: 1, BucketCounts: []uint64{3, 0, 3}},
+				}},
+			}},
+			delta: true,
+			expected: []string{
+				"m_bucket{le=-2}=2", "m_bucket{le=-1}=3", "m_bucket{le=0}=4",
+				"m_bucket{le=4}=7", "m_bucket{le=8}=7", "m_bucket{le=16}=10",
+				"m_bucket{le=+Inf}=10", "m_count=10",
+			},
+		},
+		{
+			name: "exponential histogram scale -1",
+			data: &metricsv1.Metric_ExponentialHistogram{ExponentialHistogram: &metricsv1.ExponentialHistogram{
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricsv1.ExponentialHistogramDataPoint{{
+					Count: 6, Sum: &sum, Scale: -1, ZeroCount: 2, ZeroThreshold: 0.001,
+					// base 4, the absolute values are in (0.25, 1]
+					Negative: &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: -1, BucketCounts: []uint64{1}},
+					// base 4: (1, 4], (4, 16]
+					Positive: &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: 0, BucketCounts: []uint64{1, 2}},
+				}},
+			}},
+			expected: []string{
+				"m_bucket{le=-0.25}=1", "m_bucket{le=0.001}=3", "m_bucket{le=4}=4", "m_bucket{le=16}=6",
+				"m_bucket{le=+Inf}=6", "m_count=6", "m_sum=10.5",
+			},
+		},
+		{
+			name: "summary",
+			data: &metricsv1.Metric_Summary{Summary: &metricsv1.Summary{DataPoints: []*metricsv1.SummaryDataPoint{
+				{Count: 4, Sum: 8, QuantileValues: []*metricsv1.SummaryDataPoint_ValueAtQuantile{{Quantile: 0.5, Value: 1}, {Quantile: 0.99, Value: 3}}},
+				{Count: 1, Flags: noRecordedValue},
+			}}},
+			expected: []string{"m{quantile=0.5}=1", "m{quantile=0.99}=3", "m_count=4", "m_sum=8"},
+		},
+	}
+
+	for _, c := range testCases {
+		metric := &metricsv1.Metric{Name: "m"}
+		switch data := c.data.(type) {
+		case *metricsv1.Metric_Gauge:
+			metric.Data = data
+		case *metricsv1.Metric_Sum:
+			metric.Data = data
+		case *metricsv1.Metric_Histogram:
+			metric.Data = data
+		case *metricsv1.Metric_ExponentialHistogram:
+			metric.Data = data
+		case *metricsv1.Metric_Summary:
+			metric.Data = data
+		}
+		samples, ok := appendOTelSamples(nil, 1, 1, "m", nil, metric)
+		if !ok {
+			t.Errorf("%s: unsupported", c.name)
+			continue
+		}
+		if got := formatOTelSamples(samples); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: got %v, expected %v", c.name, got, c.expected)
+		}
+		for _, s := range samples {
+			if s.point.delta != c.delta || (s.point.seriesKey != "") != c.delta {
+				t.Errorf("%s: got delta %v series key %s, expected delta %v", c.name, s.point.delta, s.point.seriesKey, c.delta)
+			}
+		}
+	}
+
+	if _, ok := appendOTelSamples(nil, 1, 1, "m", nil, &metricsv1.Metric{Name: "m"}); ok {
+		t.Errorf("metric without data should be unsupported")
+	}
+}
+
+func TestNewOTelDataPoint(t *testing.T) {
+	resAttributes := []*v11.KeyValue{otelStringKeyValue(OTEL_POD, "pod-0"), otelStringKeyValue("service.name", "svc")}
+	attributes := []*v11.KeyValue{otelStringKeyValue("http.method", "GET"), otelStringKeyValue(OTEL_POD, "not-resource")}
+	point := newOTelDataPoint(1, 2, resAttributes, attributes, 1700000000123456789, true)
+	if point.podName != "pod-0" || point.timestamp != 1700000000 {
+		t.Errorf("got pod %s timestamp %d", point.podName, point.timestamp)
+	}
+	expectedNames := []string{"k8s_pod_name", "service_name", "http_method", "k8s_pod_name"}
+	if !reflect.DeepEqual(point.tagNames, expectedNames) {
+		t.Errorf("got tag names %v, expected %v", point.tagNames, expectedNames)
+	}
+	// the tags of the series key are sorted, so that the order of the attributes does not matter
+	reordered := newOTelDataPoint(1, 2, resAttributes[1:], append([]*v11.KeyValue{attributes[0]}, resAttributes[0]), 0, true)
+	if reordered.seriesKey != newOTelDataPoint(1, 2, resAttributes, attributes[:1], 0, true).seriesKey {
+		t.Errorf("series key depends on the order of attributes: %s", reordered.seriesKey)
+	}
+	if other := newOTelDataPoint(1, 3, resAttributes, attributes, 0, true); other.seriesKey == point.seriesKey {
+		t.Errorf("series keys of different organizations are the same: %s", other.seriesKey)
+	}
+}
+
+func TestOTelCumulativeCache(t *testing.T) {
+	c := NewOTelCumulativeCache(2)
+	for i, expected := range []float64{1, 3, 6} {
+		if v, ok := c.add("a", float64(i+1)); !ok || v != expected {
+			t.Errorf("add a: got %v %v, expected %v", v, ok, expected)
+		}
+	}
+	if v, ok := c.add("b", 5); !ok || v != 5 {
+		t.Errorf("add b: got %v %v", v, ok)
+	}
+	// the cache is full, the new series is dropped and the existing series are still accumulated
+	if _, ok := c.add("c", 1); ok {
+		t.Errorf("add c: expected to be dropped")
+	}
+	if v, ok := c.add("b", 1); !ok || v != 6 {
+		t.Errorf("add b: got %v %v", v, ok)
+	}
+	counter := c.GetCounter().(*OTelCumulativeCacheCounter)
+	if counter.Size != 2 || counter.Drop != 1 {
+		t.Errorf("got counter %+v", counter)
+	}
+	if counter := c.GetCounter().(*OTelCumulativeCacheCounter); counter.Size != 2 || counter.Drop != 0 {
+		t.Errorf("got counter %+v after reset", counter)
+	}
+
+	// expired series restart from 0, which is the same as a counter reset
+	c.values["a"].lastSeen -= OTEL_CUMULATIVE_EXPIRE_TIME + 1
+	c.lastExpire -= OTEL_CUMULATIVE_EXPIRE_INTERVAL
+	c.expire()
+	if _, ok := c.values["a"]; ok {
+		t.Errorf("series a is not expired")
+	}
+	if v, ok := c.add("a", 1); !ok || v != 1 {
+		t.Errorf("add a after expired: got %v %v", v, ok)
+	}
+}
@@ -23,7 +23,12 @@ import (
 	_ "golang.org/x/net/context"
 	_ "google.golang.org/grpc"
 
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
 	dropletqueue "github.com/deepflowio/deepflow/server/ingester/droplet/queue"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/config"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/dbwriter"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/decoder"
@@ -36,15 +41,24 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/receiver"
 )
 
+var log = logging.MustGetLogger("ext_metrics")
+
 const (
 	CMD_PLATFORMDATA_EXT_METRICS = 35
 )
 
+// the export producer groups of the metricsors which export ext_metrics
+const (
+	EXPORT_GROUP_TELEGRAF = iota
+	EXPORT_GROUP_OPENTELEMETRY_METRICS
+)
+
 type ExtMetrics struct {
 	Config             *config.Config
 	Telegraf           *Metricsor
 	DeepflowAgentStats *Metricsor
 	DeepflowStats      *Metricsor
+	OTelMetrics        *Metricsor
 }
 
 type Metricsor struct {
@@ -55,18 +69,23 @@ type Metricsor struct {
 	Writers             [dbwriter.MAX_DB_ID]*dbwriter.ExtMetricsWriter
 }
 
-func NewExtMetrics(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager) (*ExtMetrics, error) {
+func NewExtMetrics(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager, exporters *exporters.Exporters) (*ExtMetrics, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_EXTMETRICS_QUEUE)
 
-	telegraf, err := NewMetricsor(datatype.MESSAGE_TYPE_TELEGRAF, []dbwriter.WriterDBID{dbwriter.EXT_METRICS_DB_ID}, config, platformDataManager, manager, recv, true)
+	// the telegraf and OpenTelemetry metrics are both exported as ext_metrics, so they use different export producer groups
+	telegraf, err := NewMetricsor(datatype.MESSAGE_TYPE_TELEGRAF, []dbwriter.WriterDBID{dbwriter.EXT_METRICS_DB_ID}, config, platformDataManager, manager, recv, true, exporters, EXPORT_GROUP_TELEGRAF)
 	if err != nil {
 		return nil, err
 	}
-	deepflowAgentStats, err := NewMetricsor(datatype.MESSAGE_TYPE_DFSTATS, []dbwriter.WriterDBID{dbwriter.DEEPFLOW_ADMIN_DB_ID, dbwriter.DEEPFLOW_TENANT_DB_ID}, config, platformDataManager, manager, recv, false)
+	deepflowAgentStats, err := NewMetricsor(datatype.MESSAGE_TYPE_DFSTATS, []dbwriter.WriterDBID{dbwriter.DEEPFLOW_ADMIN_DB_ID, dbwriter.DEEPFLOW_TENANT_DB_ID}, config, platformDataManager, manager, recv, false, nil, 0)
 	if err != nil {
 		return nil, err
 	}
-	deepflowStats, err := NewMetricsor(datatype.MESSAGE_TYPE_SERVER_DFSTATS, []dbwriter.WriterDBID{dbwriter.DEEPFLOW_ADMIN_DB_ID, dbwriter.DEEPFLOW_TENANT_DB_ID}, config, platformDataManager, manager, recv, false)
+	deepflowStats, err := NewMetricsor(datatype.MESSAGE_TYPE_SERVER_DFSTATS, []dbwriter.WriterDBID{dbwriter.DEEPFLOW_ADMIN_DB_ID, dbwriter.DEEPFLOW_TENANT_DB_ID}, config, platformDataManager, manager, recv, false, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	otelMetrics, err := NewMetricsor(datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS, []dbwriter.WriterDBID{dbwriter.EXT_METRICS_DB_ID}, config, platformDataManager, manager, recv, true, exporters, EXPORT_GROUP_OPENTELEMETRY_METRICS)
 	if err != nil {
 		return nil, err
 	}
@@ -75,10 +94,11 @@ func NewExtMetrics(config *config.Config, recv *receiver.Receiver, platformDataM
 		Telegraf:           telegraf,
 		DeepflowAgentStats: deepflowAgentStats,
 		DeepflowStats:      deepflowStats,
+		OTelMetrics:        otelMetrics,
 	}, nil
 }
 
-func NewMetricsor(msgType datatype.MessageType, flowTagTablePrefixs []dbwriter.WriterDBID, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, platformDataEnabled bool) (*Metricsor, error) {
+func NewMetricsor(msgType datatype.MessageType, flowTagTablePrefixs []dbwriter.WriterDBID, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, platformDataEnabled bool, exporters *exporters.Exporters, exportGroup int) (*Metricsor, error) {
 	queueCount := config.DecoderQueueCount
 	decodeQueues := manager.NewQueues(
 		"1-receive-to-decode-"+msgType.String(),
@@ -89,13 +109,20 @@ func NewMetricsor(msgType datatype.MessageType, flowTagTablePrefixs []dbwriter.W
 		libqueue.OptionRelease(func(p interface{}) { receiver.ReleaseRecvBuffer(p.(*receiver.RecvBuffer)) }))
 	recv.RegistHandler(msgType, decodeQueues, queueCount)
 
+	// the decoders share one cache, because the messages of an agent may be dispatched to any decoder
+	var otelCumulativeCache *decoder.OTelCumulativeCache
+	if msgType == datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS {
+		otelCumulativeCache = decoder.NewOTelCumulativeCache(config.OTelCumulativeCacheSize)
+		common.RegisterCountableForIngester("otel_cumulative_cache", otelCumulativeCache)
+	}
+
 	decoders := make([]*decoder.Decoder, queueCount)
 	platformDatas := make([]*grpc.PlatformInfoTable, queueCount)
 	for i := 0; i < queueCount; i++ {
 		if platformDataEnabled {
 			var err error
 			platformDatas[i], err = platformDataManager.NewPlatformInfoTable("ext-metrics-" + msgType.String() + "-" + strconv.Itoa(i))
-			if i == 0 {
+			if i == 0 && msgType == datatype.MESSAGE_TYPE_TELEGRAF {
 				debug.ServerRegisterSimple(CMD_PLATFORMDATA_EXT_METRICS, platformDatas[i])
 			}
 			if err != nil {
@@ -116,6 +143,9 @@ func NewMetricsor(msgType datatype.MessageType, flowTagTablePrefixs []dbwriter.W
 			platformDatas[i],
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			metricsWriters,
+			exporters,
+			exporterconfig.ProducerIndex(exportGroup, i),
+			otelCumulativeCache,
 			config,
 		)
 	}
@@ -151,11 +181,13 @@ func (s *ExtMetrics) Start() {
 	s.Telegraf.Start()
 	s.DeepflowAgentStats.Start()
 	s.DeepflowStats.Start()
+	s.OTelMetrics.Start()
 }
 
 func (s *ExtMetrics) Close() error {
 	s.Telegraf.Close()
 	s.DeepflowAgentStats.Close()
 	s.DeepflowStats.Close()
+	s.OTelMetrics.Close()
 	return nil
 }
@@ -17,6 +17,7 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 
@@ -36,8 +37,119 @@ const (
 	DefaultDecoderQueueSize  = 4096
 	DefaultBrokerQueueSize   = 1 << 14
 	DefaultFlowLogTTL        = 72 // hour
+	DefaultReservedRatio     = 50 // %
+	DefaultMaxStrata         = 64
+	DefaultDecisionWait      = 10 // s
+	DefaultMaxBufferedSpans  = 100000
+	DefaultSamplingRatio     = 10 // %
+	DefaultGeoLanguage       = "en"
+	DefaultGeoReloadInterval = 60 // s
 )
 
+const (
+	STRATUM_KEY_APP_SERVICE     = "app_service"
+	STRATUM_KEY_L7_PROTOCOL     = "l7_protocol"
+	STRATUM_KEY_RESPONSE_STATUS = "response_status"
+)
+
+// StratumKeys is the bitmap of the fields used to generate the stratum key of a l7 flow log
+type StratumKeys uint8
+
+const (
+	StratumKeyAppService StratumKeys = 1 << iota
+	StratumKeyL7Protocol
+	StratumKeyResponseStatus
+)
+
+var stratumKeys = map[string]StratumKeys{
+	STRATUM_KEY_APP_SERVICE:     StratumKeyAppService,
+	STRATUM_KEY_L7_PROTOCOL:     StratumKeyL7Protocol,
+	STRATUM_KEY_RESPONSE_STATUS: StratumKeyResponseStatus,
+}
+
+// StratifiedThrottle keeps a guaranteed share of the throttle for each stratum of l7 flow logs, so that a chatty
+// service does not crowd out the flow logs of other services
+type StratifiedThrottle struct {
+	Enabled       bool     `yaml:"enabled"`
+	Keys          []string `yaml:"keys,flow"`
+	ReservedRatio int      `yaml:"reserved-ratio"` // %, the ratio of the throttle shared equally by the strata
+	MaxStrata     int      `yaml:"max-strata"`     // the flow logs of the strata beyond are sampled by the rest of the throttle
+	KeepError     bool     `yaml:"keep-error"`     // always keep the flow logs of client or server error responses
+	SlowThreshold int      `yaml:"slow-threshold"` // ms, always keep the flow logs with response duration exceeding it, 0 means disabled
+
+	StratumKeys StratumKeys `yaml:"-"`
+}
+
+func (s *StratifiedThrottle) Validate() error {
+	s.StratumKeys = 0
+	for _, key := range s.Keys {
+		k, ok := stratumKeys[key]
+		if !ok {
+			return fmt.Errorf("invalid stratified-throttle key '%s', supported keys are %s, %s, %s", key, STRATUM_KEY_APP_SERVICE, STRATUM_KEY_L7_PROTOCOL, STRATUM_KEY_RESPONSE_STATUS)
+		}
+		s.StratumKeys |= k
+	}
+	if s.StratumKeys == 0 {
+		s.StratumKeys = StratumKeyAppService | StratumKeyL7Protocol | StratumKeyResponseStatus
+	}
+	if s.ReservedRatio <= 0 || s.ReservedRatio > 100 {
+		s.ReservedRatio = DefaultReservedRatio
+	}
+	if s.MaxStrata <= 0 {
+		s.MaxStrata = DefaultMaxStrata
+	}
+	if s.SlowThreshold < 0 {
+		s.SlowThreshold = 0
+	}
+	return nil
+}
+
+// TailSampling buffers the l7 flow logs by trace id and keeps or drops the whole trace, so that the sampled
+// traces are complete in the distributed tracing view
+type TailSampling struct {
+	Enabled          bool     `yaml:"enabled"`
+	DecisionWait     int      `yaml:"decision-wait"`      // s, the time to buffer the spans of a trace since its first span before deciding
+	MaxBufferedSpans int      `yaml:"max-buffered-spans"` // the maximum spans buffered by each decoder queue, the oldest traces are decided early beyond it
+	KeepError        bool     `yaml:"keep-error"`         // keep the traces with client or server error responses
+	LatencyThreshold int      `yaml:"latency-threshold"`  // ms, keep the traces with response duration exceeding it, 0 means disabled
+	Services         []string `yaml:"services,flow"`      // keep the traces passing the app services
+	Endpoints        []string `yaml:"endpoints,flow"`     // keep the traces passing the endpoints
+	SamplingRatio    float64  `yaml:"sampling-ratio"`     // %, the ratio of the other traces kept, decided by the hash of the trace id
+}
+
+func (t *TailSampling) Validate() error {
+	if t.DecisionWait <= 0 {
+		t.DecisionWait = DefaultDecisionWait
+	}
+	if t.MaxBufferedSpans <= 0 {
+		t.MaxBufferedSpans = DefaultMaxBufferedSpans
+	}
+	if t.LatencyThreshold < 0 {
+		t.LatencyThreshold = 0
+	}
+	if t.SamplingRatio < 0 || t.SamplingRatio > 100 {
+		return fmt.Errorf("invalid tail-sampling sampling-ratio %v, it should be in [0, 100]", t.SamplingRatio)
+	}
+	return nil
+}
+
+// Geo enriches the public IPs of flow logs with MaxMind DB files, the builtin IPv4 province data is used
+// if no file is set
+type Geo struct {
+	MMDBFiles      []string `yaml:"mmdb-files"`
+	Language       string   `yaml:"language"`
+	ReloadInterval int      `yaml:"reload-interval"` // s, 0 means disabled
+}
+
+func (g *Geo) Validate() {
+	if g.Language == "" {
+		g.Language = DefaultGeoLanguage
+	}
+	if g.ReloadInterval < 0 {
+		g.ReloadInterval = 0
+	}
+}
+
 type FlowLogTTL struct {
 	L4FlowLog int `yaml:"l4-flow-log"`
 	L7FlowLog int `yaml:"l7-flow-log"`
@@ -55,6 +167,10 @@ type Config struct {
 	DecoderQueueCount int                   `yaml:"flow-log-decoder-queue-count"`
 	DecoderQueueSize  int                   `yaml:"flow-log-decoder-queue-size"`
 	TraceTreeEnabled  *bool                 `yaml:"flow-log-trace-tree-enabled"`
+
+	StratifiedThrottle StratifiedThrottle `yaml:"flow-log-stratified-throttle"`
+	TailSampling       TailSampling       `yaml:"flow-log-tail-sampling"`
+	Geo                Geo                `yaml:"flow-log-geo"`
 }
 
 type FlowLogConfig struct {
@@ -84,7 +200,12 @@ func (c *Config) Validate() error {
 		c.TraceTreeEnabled = &value
 	}
 
-	return nil
+	c.Geo.Validate()
+
+	if err := c.StratifiedThrottle.Validate(); err != nil {
+		return err
+	}
+	return c.TailSampling.Validate()
 }
 
 func Load(base *config.Config, path string) *Config {
@@ -97,10 +218,26 @@ func Load(base *config.Config, path string) *Config {
 			DecoderQueueSize:  DefaultDecoderQueueSize,
 			CKWriterConfig:    config.CKWriterConfig{QueueCount: 1, QueueSize: 256000, BatchSize: 128000, FlushTimeout: 10},
 			FlowLogTTL:        FlowLogTTL{DefaultFlowLogTTL, DefaultFlowLogTTL, DefaultFlowLogTTL},
+			StratifiedThrottle: StratifiedThrottle{
+				ReservedRatio: DefaultReservedRatio,
+				MaxStrata:     DefaultMaxStrata,
+				KeepError:     true,
+			},
+			TailSampling: TailSampling{
+				DecisionWait:     DefaultDecisionWait,
+				MaxBufferedSpans: DefaultMaxBufferedSpans,
+				KeepError:        true,
+				SamplingRatio:    DefaultSamplingRatio,
+			},
+			Geo: Geo{
+				Language:       DefaultGeoLanguage,
+				ReloadInterval: DefaultGeoReloadInterval,
+			},
 		},
 	}
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		log.Info("no config file, use defaults")
+		config.FlowLog.Validate()
 		return &config.FlowLog
 	}
 	configBytes, err := ioutil.ReadFile(path)
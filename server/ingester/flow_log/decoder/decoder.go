@@ -84,6 +84,7 @@ type Decoder struct {
 	platformData        *grpc.PlatformInfoTable
 	inQueue             queue.QueueReader
 	throttler           *throttler.ThrottlingQueue
+	tailSampling        *throttler.TailSamplingQueue // nil if the tail sampling is disabled
 	flowTagWriter       *flow_tag.FlowTagWriter
 	appServiceTagWriter *flow_tag.AppServiceTagWriter
 	spanWriter          *dbwriter.SpanWriter
@@ -105,20 +106,21 @@ func NewDecoder(
 	index int, msgType datatype.MessageType,
 	platformData *grpc.PlatformInfoTable,
 	inQueue queue.QueueReader,
-	throttler *throttler.ThrottlingQueue,
+	throttlingQueue *throttler.ThrottlingQueue,
+	tailSampler *throttler.TailSampler,
 	flowTagWriter *flow_tag.FlowTagWriter,
 	appServiceTagWriter *flow_tag.AppServiceTagWriter,
 	spanWriter *dbwriter.SpanWriter,
 	exporters *exporters.Exporters,
 	cfg *config.Config,
 ) *Decoder {
-	return &Decoder{
+	d := &Decoder{
 		index:               index,
 		msgType:             msgType,
 		dataSourceID:        exportconfig.FlowLogMessageToDataSourceID(msgType),
 		platformData:        platformData,
 		inQueue:             inQueue,
-		throttler:           throttler,
+		throttler:           throttlingQueue,
 		flowTagWriter:       flowTagWriter,
 		appServiceTagWriter: appServiceTagWriter,
 		spanWriter:          spanWriter,
@@ -130,6 +132,8 @@ func NewDecoder(
 		fieldValuesBuf:      make([]interface{}, 0, 64),
 		counter:             &Counter{},
 	}
+	d.tailSampling = throttler.NewTailSamplingQueue(tailSampler, throttlingQueue, d.onTailSampled, msgType.String(), index)
+	return d
 }
 
 func (d *Decoder) GetCounter() interface{} {
@@ -185,6 +189,8 @@ func (d *Decoder) Run() {
 				d.handleL4Packet(decoder)
 			case datatype.MESSAGE_TYPE_SKYWALKING:
 				d.handleSkyWalking(decoder, pbSkywalkingData, false)
+			case datatype.MESSAGE_TYPE_ZIPKIN, datatype.MESSAGE_TYPE_JAEGER:
+				d.handleSpans(decoder)
 			default:
 				log.Warningf("unknown msg type: %d", d.msgType)
 
@@ -267,19 +273,47 @@ func (d *Decoder) sendOpenMetetry(tracesData *v1.TracesData) {
 		log.Debugf("decoder %d vtap %d recv otel: %s", d.index, d.agentId, tracesData)
 	}
 	d.counter.Count++
-	ls := log_data.OTelTracesDataToL7FlowLogs(d.agentId, d.orgId, d.teamId, tracesData, d.platformData, d.cfg)
+	d.sendL7FlowLogs(log_data.OTelTracesDataToL7FlowLogs(d.agentId, d.orgId, d.teamId, tracesData, d.platformData, d.cfg))
+}
+
+func (d *Decoder) sendL7FlowLogs(ls []*log_data.L7FlowLog) {
 	for _, l := range ls {
 		l.AddReferenceCount()
-		if !d.throttler.SendWithThrottling(l) {
-			d.counter.DropCount++
-		} else {
+		d.sendL7FlowLog(l)
+		l.Release()
+	}
+}
+
+// sendL7FlowLog sends the l7 flow log with a trace id to the tail sampling if it is enabled, otherwise to the throttler,
+// it takes over a reference of the l7 flow log
+func (d *Decoder) sendL7FlowLog(l *log_data.L7FlowLog) {
+	if d.tailSampling != nil && l.TraceId != "" {
+		d.tailSampling.Put(l)
+		return
+	}
+	d.onL7FlowLogSent(l, d.throttler.SendWithThrottling(l))
+}
+
+// onTailSampled is called when the trace of the buffered l7 flow log is decided
+func (d *Decoder) onTailSampled(item throttler.TraceItem, kept bool) {
+	d.onL7FlowLogSent(item.(*log_data.L7FlowLog), kept)
+}
+
+func (d *Decoder) onL7FlowLogSent(l *log_data.L7FlowLog, sent bool) {
+	if sent {
+		if d.flowTagWriter != nil {
 			d.fieldsBuf, d.fieldValuesBuf = d.fieldsBuf[:0], d.fieldValuesBuf[:0]
 			l.GenerateNewFlowTags(d.flowTagWriter.Cache)
 			d.flowTagWriter.WriteFieldsAndFieldValuesInCache()
-			d.appServiceTagWrite(l)
-			d.spanWrite(l)
 		}
-		l.Release()
+		d.appServiceTagWrite(l)
+		d.export(l)
+		d.spanWrite(l)
+	}
+	if d.msgType == datatype.MESSAGE_TYPE_PROTOCOLLOG {
+		d.updateCounter(datatype.L7Protocol(l.L7Protocol), !sent)
+	} else if !sent {
+		d.counter.DropCount++
 	}
 }
 
@@ -313,19 +347,40 @@ func (d *Decoder) sendSkyWalking(segmentData, peerIP []byte, uri string) {
 		log.Debugf("decoder %d vtap %d recv skywalking data length: %d", d.index, d.agentId, len(segmentData))
 	}
 	d.counter.Count++
-	ls := sw_import.SkyWalkingDataToL7FlowLogs(d.agentId, d.orgId, d.teamId, segmentData, peerIP, uri, d.platformData, d.cfg)
-	for _, l := range ls {
-		l.AddReferenceCount()
-		if !d.throttler.SendWithThrottling(l) {
-			d.counter.DropCount++
+	d.sendL7FlowLogs(sw_import.SkyWalkingDataToL7FlowLogs(d.agentId, d.orgId, d.teamId, segmentData, peerIP, uri, d.platformData, d.cfg))
+}
+
+// handleSpans handles the spans of Zipkin and Jaeger, the format (JSON, Thrift or Protobuf) is detected by the importers
+func (d *Decoder) handleSpans(decoder *codec.SimpleDecoder) {
+	for !decoder.IsEnd() {
+		bytes := decoder.ReadBytes()
+		if decoder.Failed() {
+			if d.counter.ErrorCount == 0 {
+				log.Errorf("%s data decode failed, offset=%d len=%d", d.msgType, decoder.Offset(), len(decoder.Bytes()))
+			}
+			d.counter.ErrorCount++
+			return
+		}
+		if d.debugEnabled {
+			log.Debugf("decoder %d vtap %d recv %s data length: %d", d.index, d.agentId, d.msgType, len(bytes))
+		}
+
+		var ls []*log_data.L7FlowLog
+		var err error
+		if d.msgType == datatype.MESSAGE_TYPE_ZIPKIN {
+			ls, err = log_data.ZipkinDataToL7FlowLogs(d.agentId, d.orgId, d.teamId, bytes, d.platformData, d.cfg)
 		} else {
-			d.fieldsBuf, d.fieldValuesBuf = d.fieldsBuf[:0], d.fieldValuesBuf[:0]
-			l.GenerateNewFlowTags(d.flowTagWriter.Cache)
-			d.flowTagWriter.WriteFieldsAndFieldValuesInCache()
-			d.appServiceTagWrite(l)
-			d.spanWrite(l)
+			ls, err = log_data.JaegerDataToL7FlowLogs(d.agentId, d.orgId, d.teamId, bytes, d.platformData, d.cfg)
 		}
-		l.Release()
+		if err != nil {
+			if d.counter.ErrorCount == 0 {
+				log.Warningf("%s data decode failed: %s", d.msgType, err)
+			}
+			d.counter.ErrorCount++
+			continue
+		}
+		d.counter.Count++
+		d.sendL7FlowLogs(ls)
 	}
 }
 
@@ -418,18 +473,7 @@ func (d *Decoder) sendProto(proto *pb.AppProtoLogsData) {
 
 	l := log_data.ProtoLogToL7FlowLog(d.orgId, d.teamId, proto, d.platformData, d.cfg)
 	l.AddReferenceCount()
-	sent := d.throttler.SendWithThrottling(l)
-	if sent {
-		if d.flowTagWriter != nil {
-			d.fieldsBuf, d.fieldValuesBuf = d.fieldsBuf[:0], d.fieldValuesBuf[:0]
-			l.GenerateNewFlowTags(d.flowTagWriter.Cache)
-			d.flowTagWriter.WriteFieldsAndFieldValuesInCache()
-		}
-		d.appServiceTagWrite(l)
-		d.export(l)
-		d.spanWrite(l)
-	}
-	d.updateCounter(datatype.L7Protocol(proto.Base.Head.Proto), !sent)
+	d.sendL7FlowLog(l)
 	l.Release()
 	proto.Release()
 
@@ -465,6 +509,9 @@ func (d *Decoder) updateCounter(l7Protocol datatype.L7Protocol, dropped bool) {
 }
 
 func (d *Decoder) flush() {
+	if d.tailSampling != nil {
+		d.tailSampling.Flush()
+	}
 	if d.throttler != nil {
 		d.throttler.SendWithThrottling(nil)
 		d.throttler.SendWithoutThrottling(nil)
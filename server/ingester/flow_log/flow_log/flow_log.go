@@ -55,6 +55,8 @@ type FlowLog struct {
 	OtelCompressedLogger *Logger
 	L4PacketLogger       *Logger
 	SkyWalkingLogger     *Logger
+	ZipkinLogger         *Logger
+	JaegerLogger         *Logger
 	Exporters            *exporters.Exporters
 	SpanWriter           *dbwriter.SpanWriter
 	TraceTreeWriter      *dbwriter.TraceTreeWriter
@@ -70,8 +72,11 @@ type Logger struct {
 func NewFlowLog(config *config.Config, traceTreeQueue *queue.OverwriteQueue, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager, exporters *exporters.Exporters) (*FlowLog, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_FLOW_LOG_QUEUE)
 
+	// the tail sampling decisions are shared by all l7 flow logs, since the spans of a trace may come from different sources
+	tailSampler := throttler.NewTailSampler(&config.TailSampling)
+
 	if config.Base.StorageDisabled {
-		l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, nil, exporters, nil)
+		l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, nil, exporters, nil, tailSampler)
 		if err != nil {
 			return nil, err
 		}
@@ -81,7 +86,11 @@ func NewFlowLog(config *config.Config, traceTreeQueue *queue.OverwriteQueue, rec
 		}, nil
 	}
 
-	geo.NewGeoTree()
+	if len(config.Geo.MMDBFiles) > 0 {
+		geo.NewMMDBGeoTree(config.Geo.MMDBFiles, config.Geo.Language, time.Duration(config.Geo.ReloadInterval)*time.Second)
+	} else {
+		geo.NewGeoTree()
+	}
 
 	flowLogWriter, err := dbwriter.NewFlowLogWriter(
 		*config.Base.CKDB.ActualAddrs, config.Base.CKDBAuth.Username, config.Base.CKDBAuth.Password,
@@ -103,23 +112,31 @@ func NewFlowLog(config *config.Config, traceTreeQueue *queue.OverwriteQueue, rec
 
 	l4FlowLogger := NewL4FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters)
 
-	l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters, spanWriter)
+	l7FlowLogger, err := NewL7FlowLogger(config, platformDataManager, manager, recv, flowLogWriter, exporters, spanWriter, tailSampler)
+	if err != nil {
+		return nil, err
+	}
+	otelLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, tailSampler)
 	if err != nil {
 		return nil, err
 	}
-	otelLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter)
+	otelCompressedLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY_COMPRESSED, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, tailSampler)
 	if err != nil {
 		return nil, err
 	}
-	otelCompressedLogger, err := NewLogger(datatype.MESSAGE_TYPE_OPENTELEMETRY_COMPRESSED, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter)
+	l4PacketLogger, err := NewLogger(datatype.MESSAGE_TYPE_PACKETSEQUENCE, config, nil, manager, recv, flowLogWriter, common.L4_PACKET_ID, nil, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	l4PacketLogger, err := NewLogger(datatype.MESSAGE_TYPE_PACKETSEQUENCE, config, nil, manager, recv, flowLogWriter, common.L4_PACKET_ID, nil, nil)
+	skywalkingLogger, err := NewLogger(datatype.MESSAGE_TYPE_SKYWALKING, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, tailSampler)
 	if err != nil {
 		return nil, err
 	}
-	skywalkingLogger, err := NewLogger(datatype.MESSAGE_TYPE_SKYWALKING, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter)
+	zipkinLogger, err := NewLogger(datatype.MESSAGE_TYPE_ZIPKIN, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, tailSampler)
+	if err != nil {
+		return nil, err
+	}
+	jaegerLogger, err := NewLogger(datatype.MESSAGE_TYPE_JAEGER, config, platformDataManager, manager, recv, flowLogWriter, common.L7_FLOW_ID, nil, spanWriter, tailSampler)
 	if err != nil {
 		return nil, err
 	}
@@ -131,13 +148,15 @@ func NewFlowLog(config *config.Config, traceTreeQueue *queue.OverwriteQueue, rec
 		OtelCompressedLogger: otelCompressedLogger,
 		L4PacketLogger:       l4PacketLogger,
 		SkyWalkingLogger:     skywalkingLogger,
+		ZipkinLogger:         zipkinLogger,
+		JaegerLogger:         jaegerLogger,
 		Exporters:            exporters,
 		SpanWriter:           spanWriter,
 		TraceTreeWriter:      traceTreeWriter,
 	}, nil
 }
 
-func NewLogger(msgType datatype.MessageType, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, flowLogId common.FlowLogID, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter) (*Logger, error) {
+func NewLogger(msgType datatype.MessageType, config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, flowLogId common.FlowLogID, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter, tailSampler *throttler.TailSampler) (*Logger, error) {
 	queueCount := config.DecoderQueueCount
 	decodeQueues := manager.NewQueues(
 		"1-receive-to-decode-"+datatype.MessageTypeString[msgType],
@@ -148,6 +167,12 @@ func NewLogger(msgType datatype.MessageType, config *config.Config, platformData
 		libqueue.OptionRelease(func(p interface{}) { receiver.ReleaseRecvBuffer(p.(*receiver.RecvBuffer)) }))
 	recv.RegistHandler(msgType, decodeQueues, queueCount)
 	throttle := config.Throttle / queueCount
+	// only l7 flow logs are throttled by stratum and sampled by trace
+	stratifiedThrottle := &config.StratifiedThrottle
+	if flowLogId != common.L7_FLOW_ID {
+		stratifiedThrottle = nil
+		tailSampler = nil
+	}
 
 	throttlers := make([]*throttler.ThrottlingQueue, queueCount)
 	decoders := make([]*decoder.Decoder, queueCount)
@@ -161,11 +186,14 @@ func NewLogger(msgType datatype.MessageType, config *config.Config, platformData
 		if err != nil {
 			return nil, err
 		}
-		throttlers[i] = throttler.NewThrottlingQueue(
+		throttlers[i] = throttler.NewStratifiedThrottlingQueue(
 			throttle,
 			config.ThrottleBucket,
 			flowLogWriter,
 			int(flowLogId),
+			stratifiedThrottle,
+			msgType.String(),
+			i,
 		)
 		if platformDataManager != nil {
 			platformDatas[i], _ = platformDataManager.NewPlatformInfoTable("flow-log-" + datatype.MessageTypeString[msgType] + "-" + strconv.Itoa(i))
@@ -179,6 +207,7 @@ func NewLogger(msgType datatype.MessageType, config *config.Config, platformData
 			platformDatas[i],
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			throttlers[i],
+			tailSampler,
 			flowTagWriter,
 			appServiceTagWriter,
 			spanWriter,
@@ -234,6 +263,7 @@ func NewL4FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 			platformDatas[i],
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			throttlers[i],
+			nil,
 			nil, nil, nil,
 			exporters,
 			config,
@@ -247,7 +277,7 @@ func NewL4FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 	}
 }
 
-func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter) (*Logger, error) {
+func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDataManager, manager *dropletqueue.Manager, recv *receiver.Receiver, flowLogWriter *dbwriter.FlowLogWriter, exporters *exporters.Exporters, spanWriter *dbwriter.SpanWriter, tailSampler *throttler.TailSampler) (*Logger, error) {
 	queueSuffix := "-l7"
 	queueCount := config.DecoderQueueCount
 	msgType := datatype.MESSAGE_TYPE_PROTOCOLLOG
@@ -285,11 +315,14 @@ func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 				return nil, err
 			}
 		}
-		throttlers[i] = throttler.NewThrottlingQueue(
+		throttlers[i] = throttler.NewStratifiedThrottlingQueue(
 			throttle,
 			config.ThrottleBucket,
 			flowLogWriter,
 			int(common.L7_FLOW_ID),
+			&config.StratifiedThrottle,
+			msgType.String(),
+			i,
 		)
 		platformDatas[i], _ = platformDataManager.NewPlatformInfoTable("l7-flow-log-" + strconv.Itoa(i))
 		if i == 0 {
@@ -301,6 +334,7 @@ func NewL7FlowLogger(config *config.Config, platformDataManager *grpc.PlatformDa
 			platformDatas[i],
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			throttlers[i],
+			tailSampler,
 			flowTagWriter,
 			appServiceTagWriter,
 			spanWriter,
@@ -365,6 +399,12 @@ func (s *FlowLog) Start() {
 	if s.SkyWalkingLogger != nil {
 		s.SkyWalkingLogger.Start()
 	}
+	if s.ZipkinLogger != nil {
+		s.ZipkinLogger.Start()
+	}
+	if s.JaegerLogger != nil {
+		s.JaegerLogger.Start()
+	}
 	if s.SpanWriter != nil {
 		s.SpanWriter.Start()
 	}
@@ -392,6 +432,12 @@ func (s *FlowLog) Close() error {
 	if s.SkyWalkingLogger != nil {
 		s.SkyWalkingLogger.Close()
 	}
+	if s.ZipkinLogger != nil {
+		s.ZipkinLogger.Close()
+	}
+	if s.JaegerLogger != nil {
+		s.JaegerLogger.Close()
+	}
 	if s.SpanWriter != nil {
 		s.SpanWriter.Close()
 	}
@@ -17,16 +17,54 @@
 package geo
 
 import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	logging "github.com/op/go-logging"
+
 	"github.com/deepflowio/deepflow/server/libs/geo"
 )
 
+var log = logging.MustGetLogger("flow_log.geo")
+
 var geoTree geo.GeoTree
+var mmdbEnabled bool
 
+// NewGeoTree uses the builtin IPv4 province and ISP data
 func NewGeoTree() {
 	geoTree = geo.NewNetmaskGeoTree()
+	mmdbEnabled = false
+}
+
+// NewMMDBGeoTree uses the MaxMind DB files, and falls back to the builtin data if they can not be loaded
+func NewMMDBGeoTree(paths []string, language string, reloadInterval time.Duration) {
+	tree, err := geo.NewMMDBGeoTree(paths, language, reloadInterval)
+	if err != nil {
+		log.Errorf("load mmdb files %v failed, use the builtin geo data: %s", paths, err)
+		NewGeoTree()
+		return
+	}
+	geoTree = tree
+	mmdbEnabled = true
 }
 
 func QueryProvince(ip uint32) string {
 	region, _ := geoTree.Query(ip)
 	return geo.DecodeRegion(region)
 }
+
+// QueryIP fills the geo information of the public IPv4 or IPv6 address, the
+// private and special addresses are skipped
+func QueryIP(ip net.IP, info *geo.IPGeoInfo) {
+	*info = geo.IPGeoInfo{}
+	if ip.IsGlobalUnicast() && !ip.IsPrivate() {
+		geoTree.QueryIP(ip, info)
+	}
+	// keep the province of the builtin data as before, which is '未知' if not found
+	if !mmdbEnabled && info.Province == "" {
+		if ip4 := ip.To4(); ip4 != nil {
+			info.Province = QueryProvince(binary.BigEndian.Uint32(ip4))
+		}
+	}
+}
@@ -0,0 +1,544 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_data
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	flowlogCfg "github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+	"github.com/deepflowio/deepflow/server/libs/grpc"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+// Jaeger tag value types, refer to: https://github.com/jaegertracing/jaeger-idl/blob/main/proto/api_v2/model.proto
+const (
+	JAEGER_TAG_STRING  = 0
+	JAEGER_TAG_BOOL    = 1
+	JAEGER_TAG_INT64   = 2
+	JAEGER_TAG_FLOAT64 = 3
+	JAEGER_TAG_BINARY  = 4
+)
+
+// Jaeger span reference types
+const (
+	JAEGER_REF_CHILD_OF     = 0
+	JAEGER_REF_FOLLOWS_FROM = 1
+)
+
+type jaegerProcess struct {
+	serviceName string
+	tags        []*v11.KeyValue
+}
+
+type jaegerSpan struct {
+	span    *v1.Span
+	process *jaegerProcess // only the spans of Jaeger protobuf may have their own process
+}
+
+type jaegerBatch struct {
+	process *jaegerProcess
+	spans   []jaegerSpan
+}
+
+// JaegerDataToL7FlowLogs converts a Jaeger batch encoded in Thrift (binary or compact protocol) or Protobuf to L7FlowLogs.
+// The spans are converted to OpenTelemetry spans first, so that they are handled in the same way as OpenTelemetry.
+func JaegerDataToL7FlowLogs(vtapID, orgId, teamId uint16, data []byte, platformData *grpc.PlatformInfoTable, cfg *flowlogCfg.Config) ([]*L7FlowLog, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("jaeger data is empty")
+	}
+
+	var batch *jaegerBatch
+	var err error
+	// the first byte is the header of the first field of Batch
+	switch data[0] {
+	case 0x0c, 0x0f: // thrift binary protocol: struct field 'process' or list field 'spans'
+		batch, err = decodeJaegerThriftBatch(data, false)
+	case 0x1c, 0x29: // thrift compact protocol: struct field 'process' or list field 'spans'
+		batch, err = decodeJaegerThriftBatch(data, true)
+	case 0x0a, 0x12: // protobuf: field 'spans' or 'process'
+		batch, err = decodeJaegerProtoBatch(data)
+	default:
+		return nil, fmt.Errorf("unknown jaeger data format, first byte 0x%x", data[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*L7FlowLog, 0, len(batch.spans))
+	for _, s := range batch.spans {
+		process := s.process
+		if process == nil {
+			process = batch.process
+		}
+		finishJaegerSpan(s.span)
+		ret = append(ret, spanToL7FlowLog(vtapID, orgId, teamId, s.span, jaegerProcessToResource(process), platformData, cfg))
+	}
+	return ret, nil
+}
+
+func jaegerTagValue(vType int64, vStr string, vBool bool, vInt64 int64, vFloat64 float64, vBinary []byte) *v11.AnyValue {
+	switch vType {
+	case JAEGER_TAG_BOOL:
+		return &v11.AnyValue{Value: &v11.AnyValue_BoolValue{BoolValue: vBool}}
+	case JAEGER_TAG_INT64:
+		return &v11.AnyValue{Value: &v11.AnyValue_IntValue{IntValue: vInt64}}
+	case JAEGER_TAG_FLOAT64:
+		return &v11.AnyValue{Value: &v11.AnyValue_DoubleValue{DoubleValue: vFloat64}}
+	case JAEGER_TAG_BINARY:
+		return &v11.AnyValue{Value: &v11.AnyValue_BytesValue{BytesValue: vBinary}}
+	default:
+		return &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: vStr}}
+	}
+}
+
+func jaegerProcessToResource(process *jaegerProcess) []*v11.KeyValue {
+	if process == nil {
+		return nil
+	}
+	attributes := make([]*v11.KeyValue, 0, len(process.tags)+2)
+	attributes = append(attributes, stringKeyValue("service.name", process.serviceName))
+	for _, tag := range process.tags {
+		attributes = append(attributes, tag)
+		if tag.Key != "ip" {
+			continue
+		}
+		// the 'ip' tag of the jaeger clients is the IP of the host, which may be a string or an int
+		var ip net.IP
+		if v, ok := tag.Value.GetValue().(*v11.AnyValue_IntValue); ok {
+			ip = utils.IpFromUint32(uint32(v.IntValue))
+		} else {
+			ip = net.ParseIP(tag.Value.GetStringValue())
+		}
+		if ip != nil {
+			attributes = append(attributes, stringKeyValue("app.host.ip", ip.String()))
+		}
+	}
+	return attributes
+}
+
+func stringKeyValue(key, value string) *v11.KeyValue {
+	return &v11.KeyValue{Key: key, Value: &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: value}}}
+}
+
+// finishJaegerSpan converts the Jaeger span tags 'span.kind', 'error' and 'otel.status_*' to the kind and the status of the span
+func finishJaegerSpan(span *v1.Span) {
+	var isError bool
+	var statusCode, statusMessage string
+	attributes := span.Attributes[:0]
+	for _, attr := range span.Attributes {
+		switch attr.Key {
+		case "span.kind":
+			switch strings.ToLower(attr.Value.GetStringValue()) {
+			case "client":
+				span.Kind = v1.Span_SPAN_KIND_CLIENT
+			case "server":
+				span.Kind = v1.Span_SPAN_KIND_SERVER
+			case "producer":
+				span.Kind = v1.Span_SPAN_KIND_PRODUCER
+			case "consumer":
+				span.Kind = v1.Span_SPAN_KIND_CONSUMER
+			case "internal":
+				span.Kind = v1.Span_SPAN_KIND_INTERNAL
+			}
+		case "error":
+			if v, ok := attr.Value.GetValue().(*v11.AnyValue_BoolValue); ok {
+				isError = v.BoolValue
+			} else {
+				isError = strings.ToLower(attr.Value.GetStringValue()) == "true"
+			}
+		case "otel.status_code":
+			statusCode = strings.ToUpper(attr.Value.GetStringValue())
+		case "otel.status_description":
+			statusMessage = attr.Value.GetStringValue()
+		default:
+			attributes = append(attributes, attr)
+		}
+	}
+	span.Attributes = attributes
+
+	if isError || statusCode == "ERROR" {
+		span.Status = &v1.Status{Code: v1.Status_STATUS_CODE_ERROR, Message: statusMessage}
+	} else if statusCode == "OK" {
+		span.Status = &v1.Status{Code: v1.Status_STATUS_CODE_OK}
+	}
+}
+
+// addJaegerReference sets the first 'CHILD_OF' reference as the parent span, other references are set as links
+func addJaegerReference(span *v1.Span, refType int64, traceId, spanId []byte) {
+	if refType == JAEGER_REF_CHILD_OF && len(span.ParentSpanId) == 0 {
+		span.ParentSpanId = spanId
+		return
+	}
+	span.Links = append(span.Links, &v1.Span_Link{TraceId: traceId, SpanId: spanId})
+}
+
+func jaegerThriftTraceId(low, high int64) []byte {
+	traceId := make([]byte, 16)
+	binary.BigEndian.PutUint64(traceId, uint64(high))
+	binary.BigEndian.PutUint64(traceId[8:], uint64(low))
+	return traceId
+}
+
+func jaegerThriftSpanId(spanId int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(spanId))
+	return b
+}
+
+// decodeJaegerThriftBatch decodes the Batch of jaeger.thrift, refer to: https://github.com/jaegertracing/jaeger-idl/blob/main/thrift/jaeger.thrift
+func decodeJaegerThriftBatch(data []byte, compact bool) (*jaegerBatch, error) {
+	r := newThriftReader(data, compact)
+	batch := &jaegerBatch{}
+	r.readStruct(func(fieldType uint8, fieldId int16) bool {
+		switch {
+		case fieldId == 1 && fieldType == THRIFT_STRUCT:
+			batch.process = readJaegerThriftProcess(r)
+		case fieldId == 2 && fieldType == THRIFT_LIST:
+			r.readList(func(elemType uint8) {
+				if elemType != THRIFT_STRUCT {
+					r.skip(elemType, 1)
+					return
+				}
+				batch.spans = append(batch.spans, jaegerSpan{span: readJaegerThriftSpan(r)})
+			})
+		default:
+			return false
+		}
+		return true
+	})
+	if r.Err() != nil {
+		return nil, fmt.Errorf("jaeger thrift decode failed: %s", r.Err())
+	}
+	return batch, nil
+}
+
+func readJaegerThriftProcess(r *thriftReader) *jaegerProcess {
+	process := &jaegerProcess{}
+	r.readStruct(func(fieldType uint8, fieldId int16) bool {
+		switch {
+		case fieldId == 1 && fieldType == THRIFT_STRING:
+			process.serviceName = r.readString()
+		case fieldId == 2 && fieldType == THRIFT_LIST:
+			process.tags = readJaegerThriftTags(r, process.tags)
+		default:
+			return false
+		}
+		return true
+	})
+	return process
+}
+
+func readJaegerThriftTags(r *thriftReader, tags []*v11.KeyValue) []*v11.KeyValue {
+	r.readList(func(elemType uint8) {
+		if elemType != THRIFT_STRUCT {
+			r.skip(elemType, 1)
+			return
+		}
+		var key, vStr string
+		var vType, vLong int64
+		var vBool bool
+		var vDouble float64
+		var vBinary []byte
+		r.readStruct(func(fieldType uint8, fieldId int16) bool {
+			switch {
+			case fieldId == 1 && fieldType == THRIFT_STRING:
+				key = r.readString()
+			case fieldId == 2 && fieldType == THRIFT_I32:
+				vType = int64(r.readI32())
+			case fieldId == 3 && fieldType == THRIFT_STRING:
+				vStr = r.readString()
+			case fieldId == 4 && fieldType == THRIFT_DOUBLE:
+				vDouble = r.readDouble()
+			case fieldId == 5 && fieldType == THRIFT_BOOL:
+				vBool = r.readBool()
+			case fieldId == 6 && fieldType == THRIFT_I64:
+				vLong = r.readI64()
+			case fieldId == 7 && fieldType == THRIFT_STRING:
+				vBinary = r.readBinary()
+			default:
+				return false
+			}
+			return true
+		})
+		tags = append(tags, &v11.KeyValue{Key: key, Value: jaegerTagValue(vType, vStr, vBool, vLong, vDouble, vBinary)})
+	})
+	return tags
+}
+
+func readJaegerThriftSpan(r *thriftReader) *v1.Span {
+	span := &v1.Span{}
+	var traceIdLow, traceIdHigh, parentSpanId, startTime, duration int64
+	r.readStruct(func(fieldType uint8, fieldId int16) bool {
+		switch {
+		case fieldId == 1 && fieldType == THRIFT_I64:
+			traceIdLow = r.readI64()
+		case fieldId == 2 && fieldType == THRIFT_I64:
+			traceIdHigh = r.readI64()
+		case fieldId == 3 && fieldType == THRIFT_I64:
+			span.SpanId = jaegerThriftSpanId(r.readI64())
+		case fieldId == 4 && fieldType == THRIFT_I64:
+			parentSpanId = r.readI64()
+		case fieldId == 5 && fieldType == THRIFT_STRING:
+			span.Name = r.readString()
+		case fieldId == 6 && fieldType == THRIFT_LIST:
+			r.readList(func(elemType uint8) {
+				if elemType != THRIFT_STRUCT {
+					r.skip(elemType, 1)
+					return
+				}
+				var refType, refTraceIdLow, refTraceIdHigh, refSpanId int64
+				r.readStruct(func(fieldType uint8, fieldId int16) bool {
+					switch {
+					case fieldId == 1 && fieldType == THRIFT_I32:
+						refType = int64(r.readI32())
+					case fieldId == 2 && fieldType == THRIFT_I64:
+						refTraceIdLow = r.readI64()
+					case fieldId == 3 && fieldType == THRIFT_I64:
+						refTraceIdHigh = r.readI64()
+					case fieldId == 4 && fieldType == THRIFT_I64:
+						refSpanId = r.readI64()
+					default:
+						return false
+					}
+					return true
+				})
+				addJaegerReference(span, refType, jaegerThriftTraceId(refTraceIdLow, refTraceIdHigh), jaegerThriftSpanId(refSpanId))
+			})
+		case fieldId == 7 && fieldType == THRIFT_I32:
+			span.Flags = uint32(r.readI32())
+		case fieldId == 8 && fieldType == THRIFT_I64:
+			startTime = r.readI64()
+		case fieldId == 9 && fieldType == THRIFT_I64:
+			duration = r.readI64()
+		case fieldId == 10 && fieldType == THRIFT_LIST:
+			span.Attributes = readJaegerThriftTags(r, span.Attributes)
+		case fieldId == 11 && fieldType == THRIFT_LIST:
+			r.readList(func(elemType uint8) {
+				if elemType != THRIFT_STRUCT {
+					r.skip(elemType, 1)
+					return
+				}
+				var timestamp int64
+				var fields []*v11.KeyValue
+				r.readStruct(func(fieldType uint8, fieldId int16) bool {
+					switch {
+					case fieldId == 1 && fieldType == THRIFT_I64:
+						timestamp = r.readI64()
+					case fieldId == 2 && fieldType == THRIFT_LIST:
+						fields = readJaegerThriftTags(r, fields)
+					default:
+						return false
+					}
+					return true
+				})
+				span.Events = append(span.Events, jaegerLogToEvent(uint64(timestamp)*uint64(time.Microsecond), fields))
+			})
+		default:
+			return false
+		}
+		return true
+	})
+
+	span.TraceId = jaegerThriftTraceId(traceIdLow, traceIdHigh)
+	// the parent span set by 'parentSpanId' takes precedence over the references
+	if parentSpanId != 0 {
+		span.ParentSpanId = jaegerThriftSpanId(parentSpanId)
+	}
+	span.StartTimeUnixNano = uint64(startTime) * uint64(time.Microsecond)
+	span.EndTimeUnixNano = uint64(startTime+duration) * uint64(time.Microsecond)
+	return span
+}
+
+// jaegerLogToEvent uses the value of the 'event' field as the event name
+func jaegerLogToEvent(timeUnixNano uint64, fields []*v11.KeyValue) *v1.Span_Event {
+	event := &v1.Span_Event{TimeUnixNano: timeUnixNano, Name: "log"}
+	for i, field := range fields {
+		if field.Key == "event" {
+			event.Name = field.Value.GetStringValue()
+			fields = append(fields[:i:i], fields[i+1:]...)
+			break
+		}
+	}
+	event.Attributes = fields
+	return event
+}
+
+// decodeJaegerProtoBatch decodes the Batch of model.proto, refer to: https://github.com/jaegertracing/jaeger-idl/blob/main/proto/api_v2/model.proto
+func decodeJaegerProtoBatch(data []byte) (*jaegerBatch, error) {
+	batch := &jaegerBatch{}
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			s, err := decodeJaegerProtoSpan(value)
+			if err != nil {
+				return err
+			}
+			batch.spans = append(batch.spans, s)
+		case 2:
+			process, err := decodeJaegerProtoProcess(value)
+			if err != nil {
+				return err
+			}
+			batch.process = process
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jaeger protobuf decode failed: %s", err)
+	}
+	return batch, nil
+}
+
+func decodeJaegerProtoProcess(data []byte) (*jaegerProcess, error) {
+	process := &jaegerProcess{}
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			process.serviceName = string(value)
+		case 2:
+			tag, err := decodeJaegerProtoKeyValue(value)
+			if err != nil {
+				return err
+			}
+			process.tags = append(process.tags, tag)
+		}
+		return nil
+	})
+	return process, err
+}
+
+func decodeJaegerProtoKeyValue(data []byte) (*v11.KeyValue, error) {
+	var key, vStr string
+	var vType, vInt64 int64
+	var vBool bool
+	var vFloat64 float64
+	var vBinary []byte
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error {
+		switch num {
+		case 1:
+			key = string(value)
+		case 2:
+			vType = int64(number)
+		case 3:
+			vStr = string(value)
+		case 4:
+			vBool = number != 0
+		case 5:
+			vInt64 = int64(number)
+		case 6:
+			vFloat64 = math.Float64frombits(number)
+		case 7:
+			vBinary = value
+		}
+		return nil
+	})
+	return &v11.KeyValue{Key: key, Value: jaegerTagValue(vType, vStr, vBool, vInt64, vFloat64, vBinary)}, err
+}
+
+// decodeJaegerProtoTime decodes google.protobuf.Timestamp or google.protobuf.Duration to nanoseconds
+func decodeJaegerProtoTime(data []byte) (uint64, error) {
+	var seconds, nanos int64
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, _ []byte, number uint64) error {
+		switch num {
+		case 1:
+			seconds = int64(number)
+		case 2:
+			nanos = int64(int32(number))
+		}
+		return nil
+	})
+	return uint64(seconds*int64(time.Second) + nanos), err
+}
+
+func decodeJaegerProtoSpan(data []byte) (jaegerSpan, error) {
+	s := jaegerSpan{span: &v1.Span{}}
+	span := s.span
+	var startTime, duration uint64
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error {
+		var err error
+		switch num {
+		case 1:
+			span.TraceId = value
+		case 2:
+			span.SpanId = value
+		case 3:
+			span.Name = string(value)
+		case 4:
+			var refTraceId, refSpanId []byte
+			var refType int64
+			err = rangeProtoFields(value, func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error {
+				switch num {
+				case 1:
+					refTraceId = value
+				case 2:
+					refSpanId = value
+				case 3:
+					refType = int64(number)
+				}
+				return nil
+			})
+			addJaegerReference(span, refType, refTraceId, refSpanId)
+		case 5:
+			span.Flags = uint32(number)
+		case 6:
+			startTime, err = decodeJaegerProtoTime(value)
+		case 7:
+			duration, err = decodeJaegerProtoTime(value)
+		case 8:
+			var tag *v11.KeyValue
+			tag, err = decodeJaegerProtoKeyValue(value)
+			span.Attributes = append(span.Attributes, tag)
+		case 9:
+			var timestamp uint64
+			var fields []*v11.KeyValue
+			err = rangeProtoFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				var err error
+				switch num {
+				case 1:
+					timestamp, err = decodeJaegerProtoTime(value)
+				case 2:
+					var field *v11.KeyValue
+					field, err = decodeJaegerProtoKeyValue(value)
+					fields = append(fields, field)
+				}
+				return err
+			})
+			span.Events = append(span.Events, jaegerLogToEvent(timestamp, fields))
+		case 10:
+			s.process, err = decodeJaegerProtoProcess(value)
+		}
+		return err
+	})
+	span.StartTimeUnixNano = startTime
+	span.EndTimeUnixNano = startTime + duration
+	return s, err
+}
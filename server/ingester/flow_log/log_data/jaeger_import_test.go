@@ -0,0 +1,283 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_data
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	jaegerTestTraceId  = []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2}
+	jaegerTestSpanId   = []byte{0, 0, 0, 0, 0, 0, 0, 3}
+	jaegerTestParentId = []byte{0, 0, 0, 0, 0, 0, 0, 4}
+	jaegerTestLinkId   = []byte{0, 0, 0, 0, 0, 0, 0, 5}
+)
+
+func writeJaegerThriftTag(w *thriftWriter, key string, vType int32, write func()) {
+	w.structBegin()
+	w.stringField(1, key)
+	w.i32Field(2, vType)
+	write()
+	w.structEnd()
+}
+
+// jaegerThriftTestBatch encodes a Batch with one span which has a CHILD_OF and a FOLLOWS_FROM reference
+func jaegerThriftTestBatch(compact bool) []byte {
+	w := &thriftWriter{compact: compact}
+	w.structBegin()
+	w.fieldBegin(THRIFT_STRUCT, 1, false)
+	w.structBegin()
+	w.stringField(1, "svc")
+	w.fieldBegin(THRIFT_LIST, 2, false)
+	w.listBegin(THRIFT_STRUCT, 2)
+	writeJaegerThriftTag(w, "ip", JAEGER_TAG_INT64, func() { w.i64Field(6, 0x0a000001) })
+	writeJaegerThriftTag(w, "hostname", JAEGER_TAG_STRING, func() { w.stringField(3, "host") })
+	w.structEnd()
+
+	w.fieldBegin(THRIFT_LIST, 2, false)
+	w.listBegin(THRIFT_STRUCT, 1)
+	w.structBegin()
+	w.i64Field(1, 2)
+	w.i64Field(2, 1)
+	w.i64Field(3, 3)
+	w.i64Field(4, 0)
+	w.stringField(5, "GET /")
+	w.fieldBegin(THRIFT_LIST, 6, false)
+	w.listBegin(THRIFT_STRUCT, 2)
+	for _, ref := range []struct {
+		refType int32
+		spanId  int64
+	}{{JAEGER_REF_FOLLOWS_FROM, 5}, {JAEGER_REF_CHILD_OF, 4}} {
+		w.structBegin()
+		w.i32Field(1, ref.refType)
+		w.i64Field(2, 2)
+		w.i64Field(3, 1)
+		w.i64Field(4, ref.spanId)
+		w.structEnd()
+	}
+	w.i32Field(7, 1)
+	w.i64Field(8, 1000000)
+	w.i64Field(9, 500)
+	w.fieldBegin(THRIFT_LIST, 10, false)
+	w.listBegin(THRIFT_STRUCT, 4)
+	writeJaegerThriftTag(w, "span.kind", JAEGER_TAG_STRING, func() { w.stringField(3, "server") })
+	writeJaegerThriftTag(w, "error", JAEGER_TAG_BOOL, func() { w.boolField(5, true) })
+	writeJaegerThriftTag(w, "http.status_code", JAEGER_TAG_INT64, func() { w.i64Field(6, 500) })
+	writeJaegerThriftTag(w, "ratio", JAEGER_TAG_FLOAT64, func() { w.doubleField(4, 0.5) })
+	w.fieldBegin(THRIFT_LIST, 11, false)
+	w.listBegin(THRIFT_STRUCT, 1)
+	w.structBegin()
+	w.i64Field(1, 1000100)
+	w.fieldBegin(THRIFT_LIST, 2, false)
+	w.listBegin(THRIFT_STRUCT, 2)
+	writeJaegerThriftTag(w, "event", JAEGER_TAG_STRING, func() { w.stringField(3, "retry") })
+	writeJaegerThriftTag(w, "attempt", JAEGER_TAG_INT64, func() { w.i64Field(6, 2) })
+	w.structEnd()
+	// an unknown field which should be skipped
+	w.fieldBegin(THRIFT_MAP, 20, false)
+	w.mapBegin(THRIFT_STRING, THRIFT_I32, 1)
+	w.str("unknown")
+	w.i32(1)
+	w.structEnd()
+	w.structEnd()
+	return w.buf
+}
+
+func appendProtoBytes(b []byte, num protowire.Number, value []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func appendProtoVarint(b []byte, num protowire.Number, value uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+func jaegerProtoTestKeyValue(key string, vType uint64, appendValue func(b []byte) []byte) []byte {
+	b := appendProtoBytes(nil, 1, []byte(key))
+	b = appendProtoVarint(b, 2, vType)
+	return appendValue(b)
+}
+
+func jaegerProtoTestTime(seconds, nanos uint64) []byte {
+	return appendProtoVarint(appendProtoVarint(nil, 1, seconds), 2, nanos)
+}
+
+// jaegerProtoTestBatch encodes the same Batch as jaegerThriftTestBatch in model.proto
+func jaegerProtoTestBatch() []byte {
+	var span []byte
+	span = appendProtoBytes(span, 1, jaegerTestTraceId)
+	span = appendProtoBytes(span, 2, jaegerTestSpanId)
+	span = appendProtoBytes(span, 3, []byte("GET /"))
+	for _, ref := range []struct {
+		refType uint64
+		spanId  []byte
+	}{{JAEGER_REF_FOLLOWS_FROM, jaegerTestLinkId}, {JAEGER_REF_CHILD_OF, jaegerTestParentId}} {
+		r := appendProtoBytes(nil, 1, jaegerTestTraceId)
+		r = appendProtoBytes(r, 2, ref.spanId)
+		r = appendProtoVarint(r, 3, ref.refType)
+		span = appendProtoBytes(span, 4, r)
+	}
+	span = appendProtoVarint(span, 5, 1)
+	span = appendProtoBytes(span, 6, jaegerProtoTestTime(1, 0))
+	span = appendProtoBytes(span, 7, jaegerProtoTestTime(0, 500000))
+	span = appendProtoBytes(span, 8, jaegerProtoTestKeyValue("span.kind", JAEGER_TAG_STRING, func(b []byte) []byte {
+		return appendProtoBytes(b, 3, []byte("server"))
+	}))
+	span = appendProtoBytes(span, 8, jaegerProtoTestKeyValue("error", JAEGER_TAG_BOOL, func(b []byte) []byte {
+		return appendProtoVarint(b, 4, 1)
+	}))
+	span = appendProtoBytes(span, 8, jaegerProtoTestKeyValue("http.status_code", JAEGER_TAG_INT64, func(b []byte) []byte {
+		return appendProtoVarint(b, 5, 500)
+	}))
+	span = appendProtoBytes(span, 8, jaegerProtoTestKeyValue("ratio", JAEGER_TAG_FLOAT64, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 6, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(0.5))
+	}))
+	spanLog := appendProtoBytes(nil, 1, jaegerProtoTestTime(1, 100000))
+	spanLog = appendProtoBytes(spanLog, 2, jaegerProtoTestKeyValue("event", JAEGER_TAG_STRING, func(b []byte) []byte {
+		return appendProtoBytes(b, 3, []byte("retry"))
+	}))
+	spanLog = appendProtoBytes(spanLog, 2, jaegerProtoTestKeyValue("attempt", JAEGER_TAG_INT64, func(b []byte) []byte {
+		return appendProtoVarint(b, 5, 2)
+	}))
+	span = appendProtoBytes(span, 9, spanLog)
+	// an unknown field which should be skipped
+	span = appendProtoVarint(span, 20, 1)
+
+	process := appendProtoBytes(nil, 1, []byte("svc"))
+	process = appendProtoBytes(process, 2, jaegerProtoTestKeyValue("ip", JAEGER_TAG_STRING, func(b []byte) []byte {
+		return appendProtoBytes(b, 3, []byte("10.0.0.1"))
+	}))
+	process = appendProtoBytes(process, 2, jaegerProtoTestKeyValue("hostname", JAEGER_TAG_STRING, func(b []byte) []byte {
+		return appendProtoBytes(b, 3, []byte("host"))
+	}))
+
+	batch := appendProtoBytes(nil, 1, span)
+	return appendProtoBytes(batch, 2, process)
+}
+
+func checkJaegerTestBatch(t *testing.T, name string, batch *jaegerBatch) {
+	if batch.process == nil || batch.process.serviceName != "svc" {
+		t.Fatalf("%s: unexpected process %+v", name, batch.process)
+	}
+	resAttributes := jaegerProcessToResource(batch.process)
+	resExpected := map[string]string{"service.name": "svc", "app.host.ip": "10.0.0.1", "hostname": "host"}
+	for _, attr := range resAttributes {
+		if v, ok := resExpected[attr.Key]; ok {
+			if got := attr.Value.GetStringValue(); got != v {
+				t.Errorf("%s: resource attribute %s is %s, expected %s", name, attr.Key, got, v)
+			}
+			delete(resExpected, attr.Key)
+		}
+	}
+	if len(resExpected) > 0 {
+		t.Errorf("%s: resource attributes %v are missing", name, resExpected)
+	}
+
+	if len(batch.spans) != 1 {
+		t.Fatalf("%s: got %d spans, expected 1", name, len(batch.spans))
+	}
+	span := batch.spans[0].span
+	finishJaegerSpan(span)
+	if !bytes.Equal(span.TraceId, jaegerTestTraceId) || !bytes.Equal(span.SpanId, jaegerTestSpanId) || !bytes.Equal(span.ParentSpanId, jaegerTestParentId) {
+		t.Errorf("%s: unexpected ids %x %x %x", name, span.TraceId, span.SpanId, span.ParentSpanId)
+	}
+	if len(span.Links) != 1 || !bytes.Equal(span.Links[0].SpanId, jaegerTestLinkId) {
+		t.Errorf("%s: unexpected links %v", name, span.Links)
+	}
+	if span.Name != "GET /" || span.Flags != 1 || span.Kind != v1.Span_SPAN_KIND_SERVER {
+		t.Errorf("%s: unexpected name %s, flags %d or kind %s", name, span.Name, span.Flags, span.Kind)
+	}
+	if span.StartTimeUnixNano != 1000000000 || span.EndTimeUnixNano != 1000500000 {
+		t.Errorf("%s: unexpected time %d - %d", name, span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+	if span.Status.GetCode() != v1.Status_STATUS_CODE_ERROR {
+		t.Errorf("%s: unexpected status %v", name, span.Status)
+	}
+	if len(span.Attributes) != 2 || span.Attributes[0].Key != "http.status_code" || span.Attributes[0].Value.GetIntValue() != 500 ||
+		span.Attributes[1].Key != "ratio" || span.Attributes[1].Value.GetDoubleValue() != 0.5 {
+		t.Errorf("%s: unexpected attributes %v", name, span.Attributes)
+	}
+	if len(span.Events) != 1 || span.Events[0].Name != "retry" || span.Events[0].TimeUnixNano != 1000100000 ||
+		len(span.Events[0].Attributes) != 1 || span.Events[0].Attributes[0].Value.GetIntValue() != 2 {
+		t.Errorf("%s: unexpected events %v", name, span.Events)
+	}
+}
+
+func TestDecodeJaegerBatch(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"thrift binary", jaegerThriftTestBatch(false)},
+		{"thrift compact", jaegerThriftTestBatch(true)},
+		{"protobuf", jaegerProtoTestBatch()},
+	}
+	for _, c := range testCases {
+		var batch *jaegerBatch
+		var err error
+		switch c.data[0] {
+		case 0x0c:
+			batch, err = decodeJaegerThriftBatch(c.data, false)
+		case 0x1c:
+			batch, err = decodeJaegerThriftBatch(c.data, true)
+		case 0x0a:
+			batch, err = decodeJaegerProtoBatch(c.data)
+		default:
+			t.Fatalf("%s: unexpected first byte 0x%x", c.name, c.data[0])
+		}
+		if err != nil {
+			t.Fatalf("%s: decode failed: %s", c.name, err)
+		}
+		checkJaegerTestBatch(t, c.name, batch)
+
+		// truncated data must fail instead of panic
+		if _, err := JaegerDataToL7FlowLogs(0, 0, 0, c.data[:len(c.data)/2], nil, nil); err == nil {
+			t.Errorf("%s: expected error of the truncated data", c.name)
+		}
+	}
+}
+
+func TestJaegerDataFormat(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"json", []byte(`{"spans":[]}`)},
+		{"thrift binary i32 field", []byte{THRIFT_I32, 0, 1, 0, 0, 0, 0, 0}},
+		{"thrift compact bool field", []byte{0x11, 0}},
+	}
+	for _, c := range testCases {
+		if _, err := JaegerDataToL7FlowLogs(0, 0, 0, c.data, nil, nil); err == nil {
+			t.Errorf("%s: expected error", c.name)
+		}
+	}
+
+	// an empty batch of every format is valid
+	for _, data := range [][]byte{{0x0f, 0, 2, THRIFT_STRUCT, 0, 0, 0, 0, 0}, {0x29, 0x0c, 0}, {0x12, 0}} {
+		if ls, err := JaegerDataToL7FlowLogs(0, 0, 0, data, nil, nil); err != nil || len(ls) != 0 {
+			t.Errorf("data %x: got %d logs, err %v", data, len(ls), err)
+		}
+	}
+}
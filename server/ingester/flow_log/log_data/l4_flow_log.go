@@ -31,6 +31,7 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	"github.com/deepflowio/deepflow/server/libs/datatype/pb"
 	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
+	libgeo "github.com/deepflowio/deepflow/server/libs/geo"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
 	"github.com/deepflowio/deepflow/server/libs/pool"
 	"github.com/deepflowio/deepflow/server/libs/utils"
@@ -218,18 +219,45 @@ func (a *ApplicationLayer) WriteBlock(block *ckdb.Block) {
 }
 
 type Internet struct {
-	Province0 string `json:"province_0" category:"$tag" sub:"network_layer"`
-	Province1 string `json:"province_1" category:"$tag" sub:"network_layer"`
+	Province0     string `json:"province_0" category:"$tag" sub:"network_layer"`
+	Province1     string `json:"province_1" category:"$tag" sub:"network_layer"`
+	Country0      string `json:"country_0" category:"$tag" sub:"network_layer"`
+	Country1      string `json:"country_1" category:"$tag" sub:"network_layer"`
+	City0         string `json:"city_0" category:"$tag" sub:"network_layer"`
+	City1         string `json:"city_1" category:"$tag" sub:"network_layer"`
+	ASN0          uint32 `json:"asn_0" category:"$tag" sub:"network_layer"`
+	ASN1          uint32 `json:"asn_1" category:"$tag" sub:"network_layer"`
+	Organization0 string `json:"organization_0" category:"$tag" sub:"network_layer"`
+	Organization1 string `json:"organization_1" category:"$tag" sub:"network_layer"`
 }
 
 var InternetColumns = []*ckdb.Column{
 	// 广域网
 	ckdb.NewColumn("province_0", ckdb.LowCardinalityString),
 	ckdb.NewColumn("province_1", ckdb.LowCardinalityString),
+	ckdb.NewColumn("country_0", ckdb.LowCardinalityString),
+	ckdb.NewColumn("country_1", ckdb.LowCardinalityString),
+	ckdb.NewColumn("city_0", ckdb.LowCardinalityString),
+	ckdb.NewColumn("city_1", ckdb.LowCardinalityString),
+	ckdb.NewColumn("asn_0", ckdb.UInt32).SetComment("Autonomous System Number"),
+	ckdb.NewColumn("asn_1", ckdb.UInt32).SetComment("Autonomous System Number"),
+	ckdb.NewColumn("organization_0", ckdb.LowCardinalityString).SetComment("ASN organization or ISP"),
+	ckdb.NewColumn("organization_1", ckdb.LowCardinalityString).SetComment("ASN organization or ISP"),
 }
 
 func (i *Internet) WriteBlock(block *ckdb.Block) {
-	block.Write(i.Province0, i.Province1)
+	block.Write(
+		i.Province0,
+		i.Province1,
+		i.Country0,
+		i.Country1,
+		i.City0,
+		i.City1,
+		i.ASN0,
+		i.ASN1,
+		i.Organization0,
+		i.Organization1,
+	)
 }
 
 type KnowledgeGraph struct {
@@ -702,9 +730,21 @@ func (a *ApplicationLayer) Fill(f *pb.Flow) {
 	}
 }
 
-func (i *Internet) Fill(f *pb.Flow) {
-	i.Province0 = geo.QueryProvince(f.FlowKey.IpSrc)
-	i.Province1 = geo.QueryProvince(f.FlowKey.IpDst)
+func (i *Internet) Fill(f *pb.Flow, isIPV6 bool) {
+	i.fill(isIPV6, f.FlowKey.IpSrc, f.FlowKey.IpDst, f.FlowKey.Ip6Src, f.FlowKey.Ip6Dst)
+}
+
+// fill queries the geo information of the public client and server IPs
+func (i *Internet) fill(isIPv6 bool, ip40, ip41 uint32, ip60, ip61 net.IP) {
+	ip0, ip1 := ip60, ip61
+	if !isIPv6 {
+		ip0, ip1 = utils.IpFromUint32(ip40), utils.IpFromUint32(ip41)
+	}
+	info := libgeo.IPGeoInfo{}
+	geo.QueryIP(ip0, &info)
+	i.Province0, i.Country0, i.City0, i.ASN0, i.Organization0 = info.Province, info.Country, info.City, info.ASN, info.Organization
+	geo.QueryIP(ip1, &info)
+	i.Province1, i.Country1, i.City1, i.ASN1, i.Organization1 = info.Province, info.Country, info.City, info.ASN, info.Organization
 }
 
 func isLocalIP(isIPv6 bool, ip4 uint32, ip6 net.IP) bool {
@@ -1105,7 +1145,7 @@ func TaggedFlowToL4FlowLog(orgId, teamId uint16, f *pb.TaggedFlow, platformData
 	s.NetworkLayer.Fill(f.Flow, isIPV6)
 	s.TransportLayer.Fill(f.Flow)
 	s.ApplicationLayer.Fill(f.Flow)
-	s.Internet.Fill(f.Flow)
+	s.Internet.Fill(f.Flow, isIPV6)
 	s.KnowledgeGraph.FillL4(f.Flow, isIPV6, platformData)
 	s.FlowInfo.Fill(f.Flow)
 	s.Metrics.Fill(f.Flow)
@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/deepflowio/deepflow/server/ingester/config"
@@ -250,6 +251,9 @@ type L7FlowLog struct {
 	MetricsValues []float64 `json:"metrics_values" category:"$metrics" data_type:"[]float64"`
 
 	Events string `json:"events" category:"$tag" sub:"application_layer"`
+
+	// 广域网
+	Internet
 }
 
 func L7FlowLogColumns() []*ckdb.Column {
@@ -299,6 +303,7 @@ func L7FlowLogColumns() []*ckdb.Column {
 		ckdb.NewColumn("metrics_values", ckdb.ArrayFloat64).SetComment("额外的指标对应的值"),
 		ckdb.NewColumn("events", ckdb.String).SetComment("OTel events"),
 	)
+	l7Columns = append(l7Columns, InternetColumns...)
 	return l7Columns
 }
 
@@ -348,6 +353,7 @@ func (h *L7FlowLog) WriteBlock(block *ckdb.Block) {
 		h.MetricsValues,
 		h.Events,
 	)
+	h.Internet.WriteBlock(block)
 }
 
 func (h *L7FlowLog) OrgID() uint16 {
@@ -387,6 +393,7 @@ func ParseTraceIdIndex(traceId string, traceIdIndexCfg *config.TraceIdWithIndex)
 
 func (h *L7FlowLog) Fill(l *pb.AppProtoLogsData, platformData *grpc.PlatformInfoTable, cfg *flowlogCfg.Config) {
 	h.L7Base.Fill(l, platformData)
+	h.Internet.fill(!h.IsIPv4, h.IP40, h.IP41, h.IP60, h.IP61)
 
 	h.Type = uint8(l.Base.Head.MsgType)
 	h.IsTLS = uint8(l.Flags & 0x1)
@@ -552,6 +559,42 @@ func (h *L7FlowLog) SetID(id uint64) {
 	h._id = id
 }
 
+// StratumKey joins the values of the key fields, it is used by the stratified throttling
+func (h *L7FlowLog) StratumKey(keys flowlogCfg.StratumKeys) string {
+	values := make([]string, 0, 3)
+	if keys&flowlogCfg.StratumKeyAppService != 0 {
+		values = append(values, h.AppService)
+	}
+	if keys&flowlogCfg.StratumKeyL7Protocol != 0 {
+		values = append(values, h.L7ProtocolStr)
+	}
+	if keys&flowlogCfg.StratumKeyResponseStatus != 0 {
+		values = append(values, datatype.LogMessageStatus(h.ResponseStatus).String())
+	}
+	return strings.Join(values, "/")
+}
+
+func (h *L7FlowLog) IsErrorResponse() bool {
+	return h.ResponseStatus == uint8(datatype.STATUS_SERVER_ERROR) || h.ResponseStatus == uint8(datatype.STATUS_CLIENT_ERROR)
+}
+
+// GetResponseDuration returns the response duration in microseconds
+func (h *L7FlowLog) GetResponseDuration() uint64 {
+	return h.ResponseDuration
+}
+
+func (h *L7FlowLog) GetTraceID() string {
+	return h.TraceId
+}
+
+func (h *L7FlowLog) GetAppService() string {
+	return h.AppService
+}
+
+func (h *L7FlowLog) GetEndpoint() string {
+	return h.Endpoint
+}
+
 func (b *L7Base) Fill(log *pb.AppProtoLogsData, platformData *grpc.PlatformInfoTable) {
 	l := log.Base
 	// 网络层
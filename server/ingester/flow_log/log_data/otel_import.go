@@ -97,22 +97,6 @@ func HttpCodeToResponseStatus(code int32) datatype.LogMessageStatus {
 	}
 }
 
-func getValueString(value *v11.AnyValue) string {
-	valueString := value.GetStringValue()
-	if valueString != "" {
-		return valueString
-	} else {
-		valueString = value.String()
-		// 获取:后边的内容(:前边的是数据类型)
-		index := strings.Index(valueString, ":")
-		if index > -1 && len(valueString) > index+1 {
-			return valueString[index+1:]
-		} else {
-			return valueString
-		}
-	}
-}
-
 func skywalkingGetParentSpanIdFromLinks(links []*v1.Span_Link) string {
 	for _, link := range links {
 		refTypeValid := false
@@ -127,14 +111,14 @@ func skywalkingGetParentSpanIdFromLinks(links []*v1.Span_Link) string {
 
 			switch key {
 			case "refType":
-				valueStr := getValueString(value)
+				valueStr := common.OTelAnyValueString(value)
 				if valueStr == "CrossProcess" || valueStr == "CrossThread" {
 					refTypeValid = true
 				}
 			case "sw8.parent_span_id":
-				parentSpanId = getValueString(value)
+				parentSpanId = common.OTelAnyValueString(value)
 			case "sw8.parent_segment_id":
-				parentSegmentId = getValueString(value)
+				parentSegmentId = common.OTelAnyValueString(value)
 			}
 		}
 		if refTypeValid && parentSpanId != "" && parentSegmentId != "" {
@@ -166,9 +150,9 @@ func (h *L7FlowLog) fillAttributes(spanAttributes, resAttributes []*v11.KeyValue
 		if i >= len(spanAttributes) {
 			switch key {
 			case "service.name":
-				h.AppService = getValueString(value)
+				h.AppService = common.OTelAnyValueString(value)
 			case "service.instance.id":
-				h.AppInstance = getValueString(value)
+				h.AppInstance = common.OTelAnyValueString(value)
 			// 通过一个[k8sattributesprocessor插件](https://pkg.go.dev/github.com/open-telemetry/opentelemetry-collector-contrib/processor/k8sattributesprocessor#section-readme)
 			// 获取当前应用(otel-agent)对应上一级（即Span的来源）的IP地址，例如：Span为POD产生，则获取POD的IP；Span为部署在虚拟机上的进程产生，则获取虚拟机的IP
 			//   - 限制：因为获取的为当前应用的上一级IP，因此如果Span所在的应用发送数据给otel-agent是通过LB过来，则获取的为LB的IP
@@ -196,7 +180,7 @@ func (h *L7FlowLog) fillAttributes(spanAttributes, resAttributes []*v11.KeyValue
 					}
 				}
 			case "sw8.trace_id":
-				h.TraceId = getValueString(value)
+				h.TraceId = common.OTelAnyValueString(value)
 				h.TraceIdIndex = ParseTraceIdIndex(h.TraceId, &cfg.Base.TraceIdWithIndex)
 			}
 
@@ -234,7 +218,7 @@ func (h *L7FlowLog) fillAttributes(spanAttributes, resAttributes []*v11.KeyValue
 			case "http.flavor":
 				h.Version = value.GetStringValue()
 			case "http.status_code":
-				v, _ := strconv.Atoi(getValueString(value))
+				v, _ := strconv.Atoi(common.OTelAnyValueString(value))
 				h.responseCode = int32(v)
 				h.ResponseCode = &h.responseCode
 			case "http.host", "db.connection_string":
@@ -246,11 +230,11 @@ func (h *L7FlowLog) fillAttributes(spanAttributes, resAttributes []*v11.KeyValue
 			case "http.url":
 				httpURL = value.GetStringValue()
 			case "sw8.span_id":
-				h.SpanId = getValueString(value)
+				h.SpanId = common.OTelAnyValueString(value)
 			case "sw8.parent_span_id":
-				h.ParentSpanId = getValueString(value)
+				h.ParentSpanId = common.OTelAnyValueString(value)
 			case "sw8.segment_id":
-				sw8SegmentId = getValueString(value)
+				sw8SegmentId = common.OTelAnyValueString(value)
 			case "http.request_content_length":
 				h.requestLength = value.GetIntValue()
 				h.RequestLength = &h.requestLength
@@ -272,12 +256,12 @@ func (h *L7FlowLog) fillAttributes(spanAttributes, resAttributes []*v11.KeyValue
 
 		if isMetrics {
 			metricsNames = append(metricsNames, key)
-			v, _ := strconv.ParseFloat(getValueString(value), 64)
+			v, _ := strconv.ParseFloat(common.OTelAnyValueString(value), 64)
 			metricsValues = append(metricsValues, v)
 		} else {
 			// FIXME 不同类型都按string存储，后续不同类型存储应分开, 参考: https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/common/v1/common.proto#L31
 			attributeNames = append(attributeNames, key)
-			attributeValues = append(attributeValues, getValueString(value))
+			attributeValues = append(attributeValues, common.OTelAnyValueString(value))
 		}
 
 	}
@@ -380,6 +364,7 @@ func (h *L7FlowLog) FillOTel(l *v1.Span, resAttributes []*v11.KeyValue, platform
 			}
 		}
 	}
+	h.Internet.fill(!h.IsIPv4, h.IP40, h.IP41, h.IP60, h.IP61)
 	h.L7Base.KnowledgeGraph.FillOTel(h, platformData)
 	// only show data for services as 'server side'
 	if h.TapSide == flow_metrics.ServerApp.String() && h.ServerPort == 0 {
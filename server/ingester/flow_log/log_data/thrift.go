@@ -0,0 +1,339 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// thrift types of the binary protocol, the types of the compact protocol are converted to them.
+const (
+	THRIFT_STOP   uint8 = 0
+	THRIFT_BOOL   uint8 = 2
+	THRIFT_BYTE   uint8 = 3
+	THRIFT_DOUBLE uint8 = 4
+	THRIFT_I16    uint8 = 6
+	THRIFT_I32    uint8 = 8
+	THRIFT_I64    uint8 = 10
+	THRIFT_STRING uint8 = 11
+	THRIFT_STRUCT uint8 = 12
+	THRIFT_MAP    uint8 = 13
+	THRIFT_SET    uint8 = 14
+	THRIFT_LIST   uint8 = 15
+)
+
+// types of the compact protocol, refer to: https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+var compactTypeToThriftType = [...]uint8{
+	0:  THRIFT_STOP,
+	1:  THRIFT_BOOL, // true
+	2:  THRIFT_BOOL, // false
+	3:  THRIFT_BYTE,
+	4:  THRIFT_I16,
+	5:  THRIFT_I32,
+	6:  THRIFT_I64,
+	7:  THRIFT_DOUBLE,
+	8:  THRIFT_STRING,
+	9:  THRIFT_LIST,
+	10: THRIFT_SET,
+	11: THRIFT_MAP,
+	12: THRIFT_STRUCT,
+}
+
+const THRIFT_MAX_DEPTH = 64
+
+var errThriftShortBuffer = errors.New("thrift buffer too short")
+
+// thriftReader is a minimal reader of the thrift binary and compact protocol, it only supports reading
+// structs, which is enough for decoding Jaeger batches.
+type thriftReader struct {
+	buf     []byte
+	offset  int
+	compact bool
+	err     error
+
+	// only used by the compact protocol
+	lastFieldIds []int16
+	boolValue    bool
+}
+
+func newThriftReader(buf []byte, compact bool) *thriftReader {
+	return &thriftReader{buf: buf, compact: compact}
+}
+
+func (r *thriftReader) Err() error {
+	return r.err
+}
+
+func (r *thriftReader) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *thriftReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.offset+n > len(r.buf) {
+		r.setErr(errThriftShortBuffer)
+		return nil
+	}
+	b := r.buf[r.offset : r.offset+n]
+	r.offset += n
+	return b
+}
+
+func (r *thriftReader) readByte() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *thriftReader) readVarint() uint64 {
+	var x uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b := r.readByte()
+		if r.err != nil {
+			return 0
+		}
+		x |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return x
+		}
+	}
+	r.setErr(errors.New("thrift varint overflow"))
+	return 0
+}
+
+func (r *thriftReader) readZigzag() int64 {
+	x := r.readVarint()
+	return int64(x>>1) ^ -int64(x&1)
+}
+
+func (r *thriftReader) readI16() int16 {
+	if r.compact {
+		return int16(r.readZigzag())
+	}
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (r *thriftReader) readI32() int32 {
+	if r.compact {
+		return int32(r.readZigzag())
+	}
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *thriftReader) readI64() int64 {
+	if r.compact {
+		return r.readZigzag()
+	}
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (r *thriftReader) readDouble() float64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	if r.compact {
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+// readBool reads a bool value of a field, the value of bool fields is encoded in the field header in the compact protocol
+func (r *thriftReader) readBool() bool {
+	if r.compact {
+		return r.boolValue
+	}
+	return r.readByte() != 0
+}
+
+func (r *thriftReader) readSize() int {
+	var size int
+	if r.compact {
+		size = int(r.readVarint())
+	} else {
+		size = int(r.readI32())
+	}
+	if size < 0 || size > len(r.buf)-r.offset {
+		r.setErr(fmt.Errorf("invalid thrift size %d", size))
+		return 0
+	}
+	return size
+}
+
+func (r *thriftReader) readBinary() []byte {
+	return r.next(r.readSize())
+}
+
+func (r *thriftReader) readString() string {
+	return string(r.readBinary())
+}
+
+func (r *thriftReader) readType(compactType uint8) uint8 {
+	if int(compactType) >= len(compactTypeToThriftType) {
+		r.setErr(fmt.Errorf("invalid thrift compact type %d", compactType))
+		return THRIFT_STOP
+	}
+	return compactTypeToThriftType[compactType]
+}
+
+// readListBegin returns the element type and the size of a list or set
+func (r *thriftReader) readListBegin() (uint8, int) {
+	if !r.compact {
+		elemType := r.readByte()
+		return elemType, r.readSize()
+	}
+	b := r.readByte()
+	size := int(b >> 4)
+	if size == 15 {
+		size = r.readSize()
+	}
+	if size > len(r.buf)-r.offset {
+		r.setErr(fmt.Errorf("invalid thrift list size %d", size))
+		return THRIFT_STOP, 0
+	}
+	return r.readType(b & 0x0f), size
+}
+
+func (r *thriftReader) readFieldBegin() (uint8, int16) {
+	b := r.readByte()
+	if r.err != nil {
+		return THRIFT_STOP, 0
+	}
+	if !r.compact {
+		if b == THRIFT_STOP {
+			return THRIFT_STOP, 0
+		}
+		return b, r.readI16()
+	}
+
+	compactType := b & 0x0f
+	if compactType == 0 {
+		return THRIFT_STOP, 0
+	}
+	lastFieldId := &r.lastFieldIds[len(r.lastFieldIds)-1]
+	var fieldId int16
+	if delta := int16(b >> 4); delta != 0 {
+		fieldId = *lastFieldId + delta
+	} else {
+		fieldId = r.readI16()
+	}
+	*lastFieldId = fieldId
+	r.boolValue = compactType == 1
+	return r.readType(compactType), fieldId
+}
+
+// readStruct reads the fields of a struct, the fields which are not read by readField are skipped
+func (r *thriftReader) readStruct(readField func(fieldType uint8, fieldId int16) bool) {
+	if len(r.lastFieldIds) >= THRIFT_MAX_DEPTH {
+		r.setErr(errors.New("thrift struct is too deep"))
+		return
+	}
+	r.lastFieldIds = append(r.lastFieldIds, 0)
+	for r.err == nil {
+		fieldType, fieldId := r.readFieldBegin()
+		if fieldType == THRIFT_STOP {
+			break
+		}
+		if !readField(fieldType, fieldId) {
+			r.skip(fieldType, 0)
+		}
+	}
+	r.lastFieldIds = r.lastFieldIds[:len(r.lastFieldIds)-1]
+}
+
+// readList calls readElem for every element of a list
+func (r *thriftReader) readList(readElem func(elemType uint8)) {
+	elemType, size := r.readListBegin()
+	for i := 0; i < size && r.err == nil; i++ {
+		readElem(elemType)
+	}
+}
+
+// skip skips a value, depth is 0 for the fields of structs and greater than 0 for the elements of containers
+func (r *thriftReader) skip(fieldType uint8, depth int) {
+	if depth > THRIFT_MAX_DEPTH {
+		r.setErr(errors.New("thrift value is too deep"))
+		return
+	}
+	switch fieldType {
+	case THRIFT_BOOL:
+		if r.compact && depth > 0 {
+			// bool elements of containers take one byte in the compact protocol
+			r.readByte()
+		} else {
+			r.readBool()
+		}
+	case THRIFT_BYTE:
+		r.readByte()
+	case THRIFT_I16:
+		r.readI16()
+	case THRIFT_I32:
+		r.readI32()
+	case THRIFT_I64:
+		r.readI64()
+	case THRIFT_DOUBLE:
+		r.readDouble()
+	case THRIFT_STRING:
+		r.readBinary()
+	case THRIFT_STRUCT:
+		r.readStruct(func(uint8, int16) bool { return false })
+	case THRIFT_LIST, THRIFT_SET:
+		r.readList(func(elemType uint8) {
+			r.skip(elemType, depth+1)
+		})
+	case THRIFT_MAP:
+		var keyType, valueType uint8
+		var size int
+		if r.compact {
+			size = r.readSize()
+			if size > 0 {
+				b := r.readByte()
+				keyType, valueType = r.readType(b>>4), r.readType(b&0x0f)
+			}
+		} else {
+			keyType, valueType = r.readByte(), r.readByte()
+			size = r.readSize()
+		}
+		for i := 0; i < size && r.err == nil; i++ {
+			r.skip(keyType, depth+1)
+			r.skip(valueType, depth+1)
+		}
+	default:
+		r.setErr(fmt.Errorf("invalid thrift type %d", fieldType))
+	}
+}
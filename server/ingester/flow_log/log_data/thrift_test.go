@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_data
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+var thriftTypeToCompactType = map[uint8]uint8{
+	THRIFT_BOOL:   1,
+	THRIFT_BYTE:   3,
+	THRIFT_I16:    4,
+	THRIFT_I32:    5,
+	THRIFT_I64:    6,
+	THRIFT_DOUBLE: 7,
+	THRIFT_STRING: 8,
+	THRIFT_LIST:   9,
+	THRIFT_SET:    10,
+	THRIFT_MAP:    11,
+	THRIFT_STRUCT: 12,
+}
+
+// thriftWriter writes the thrift binary or compact protocol for the tests of thriftReader
+type thriftWriter struct {
+	buf          []byte
+	compact      bool
+	lastFieldIds []int16
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) i16(v int16) {
+	if w.compact {
+		w.zigzag(int64(v))
+		return
+	}
+	w.buf = append(w.buf, 0, 0)
+	binary.BigEndian.PutUint16(w.buf[len(w.buf)-2:], uint16(v))
+}
+
+func (w *thriftWriter) i32(v int32) {
+	if w.compact {
+		w.zigzag(int64(v))
+		return
+	}
+	w.buf = append(w.buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(w.buf[len(w.buf)-4:], uint32(v))
+}
+
+func (w *thriftWriter) i64(v int64) {
+	if w.compact {
+		w.zigzag(v)
+		return
+	}
+	w.buf = append(w.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(w.buf[len(w.buf)-8:], uint64(v))
+}
+
+func (w *thriftWriter) double(v float64) {
+	w.buf = append(w.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	if w.compact {
+		binary.LittleEndian.PutUint64(w.buf[len(w.buf)-8:], math.Float64bits(v))
+	} else {
+		binary.BigEndian.PutUint64(w.buf[len(w.buf)-8:], math.Float64bits(v))
+	}
+}
+
+func (w *thriftWriter) size(n int) {
+	if w.compact {
+		w.varint(uint64(n))
+	} else {
+		w.i32(int32(n))
+	}
+}
+
+func (w *thriftWriter) str(s string) {
+	w.size(len(s))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *thriftWriter) structBegin() {
+	w.lastFieldIds = append(w.lastFieldIds, 0)
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf = append(w.buf, THRIFT_STOP)
+	w.lastFieldIds = w.lastFieldIds[:len(w.lastFieldIds)-1]
+}
+
+// fieldBegin writes the field header, boolValue is only used by the bool fields of the compact protocol
+func (w *thriftWriter) fieldBegin(fieldType uint8, fieldId int16, boolValue bool) {
+	if !w.compact {
+		w.buf = append(w.buf, fieldType)
+		w.i16(fieldId)
+		return
+	}
+	compactType := thriftTypeToCompactType[fieldType]
+	if fieldType == THRIFT_BOOL {
+		compactType = 2
+		if boolValue {
+			compactType = 1
+		}
+	}
+	lastFieldId := &w.lastFieldIds[len(w.lastFieldIds)-1]
+	if delta := fieldId - *lastFieldId; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|compactType)
+	} else {
+		w.buf = append(w.buf, compactType)
+		w.i16(fieldId)
+	}
+	*lastFieldId = fieldId
+}
+
+func (w *thriftWriter) listBegin(elemType uint8, size int) {
+	if !w.compact {
+		w.buf = append(w.buf, elemType)
+		w.i32(int32(size))
+		return
+	}
+	compactType := thriftTypeToCompactType[elemType]
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|compactType)
+	} else {
+		w.buf = append(w.buf, 0xf0|compactType)
+		w.varint(uint64(size))
+	}
+}
+
+func (w *thriftWriter) mapBegin(keyType, valueType uint8, size int) {
+	if !w.compact {
+		w.buf = append(w.buf, keyType, valueType)
+		w.i32(int32(size))
+		return
+	}
+	w.varint(uint64(size))
+	if size > 0 {
+		w.buf = append(w.buf, thriftTypeToCompactType[keyType]<<4|thriftTypeToCompactType[valueType])
+	}
+}
+
+func (w *thriftWriter) i16Field(fieldId int16, v int16) {
+	w.fieldBegin(THRIFT_I16, fieldId, false)
+	w.i16(v)
+}
+
+func (w *thriftWriter) i32Field(fieldId int16, v int32) {
+	w.fieldBegin(THRIFT_I32, fieldId, false)
+	w.i32(v)
+}
+
+func (w *thriftWriter) i64Field(fieldId int16, v int64) {
+	w.fieldBegin(THRIFT_I64, fieldId, false)
+	w.i64(v)
+}
+
+func (w *thriftWriter) stringField(fieldId int16, s string) {
+	w.fieldBegin(THRIFT_STRING, fieldId, false)
+	w.str(s)
+}
+
+func (w *thriftWriter) boolField(fieldId int16, v bool) {
+	w.fieldBegin(THRIFT_BOOL, fieldId, v)
+	if !w.compact {
+		if v {
+			w.buf = append(w.buf, 1)
+		} else {
+			w.buf = append(w.buf, 0)
+		}
+	}
+}
+
+func (w *thriftWriter) doubleField(fieldId int16, v float64) {
+	w.fieldBegin(THRIFT_DOUBLE, fieldId, false)
+	w.double(v)
+}
+
+func TestThriftReader(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		w := &thriftWriter{compact: compact}
+		w.structBegin()
+		w.boolField(1, true)
+		w.boolField(2, false)
+		w.i32Field(3, -12345)
+		w.i64Field(4, math.MinInt64)
+		w.doubleField(5, 3.25)
+		// a large delta of the field id, which is not encoded in the header of the compact protocol
+		w.stringField(100, "hello")
+		// fields to be skipped: list<i32>, map<string, list<i64>>, struct { set<bool> }
+		w.fieldBegin(THRIFT_LIST, 101, false)
+		w.listBegin(THRIFT_I32, 20)
+		for i := 0; i < 20; i++ {
+			w.i32(int32(i))
+		}
+		w.fieldBegin(THRIFT_MAP, 102, false)
+		w.mapBegin(THRIFT_STRING, THRIFT_LIST, 1)
+		w.str("key")
+		w.listBegin(THRIFT_I64, 2)
+		w.i64(1)
+		w.i64(-1)
+		w.fieldBegin(THRIFT_STRUCT, 103, false)
+		w.structBegin()
+		w.fieldBegin(THRIFT_SET, 1, false)
+		// the bool elements of containers take one byte in both protocols
+		w.listBegin(THRIFT_BOOL, 1)
+		w.buf = append(w.buf, 1)
+		w.structEnd()
+		// the field after the skipped fields, whose id is the delta of the skipped struct in the compact protocol
+		w.i16Field(104, 7)
+		w.structEnd()
+
+		var v1, v2 bool
+		var v3 int32
+		var v4 int64
+		var v5 float64
+		var v100 string
+		var v104 int16
+		r := newThriftReader(w.buf, compact)
+		r.readStruct(func(fieldType uint8, fieldId int16) bool {
+			switch fieldId {
+			case 1:
+				v1 = r.readBool()
+			case 2:
+				v2 = r.readBool()
+			case 3:
+				v3 = r.readI32()
+			case 4:
+				v4 = r.readI64()
+			case 5:
+				v5 = r.readDouble()
+			case 100:
+				v100 = r.readString()
+			case 104:
+				v104 = r.readI16()
+			default:
+				return false
+			}
+			return true
+		})
+		if r.Err() != nil {
+			t.Fatalf("compact=%v, read failed: %s", compact, r.Err())
+		}
+		if r.offset != len(w.buf) {
+			t.Errorf("compact=%v, offset %d, expected %d", compact, r.offset, len(w.buf))
+		}
+		if !v1 || v2 || v3 != -12345 || v4 != math.MinInt64 || v5 != 3.25 || v100 != "hello" || v104 != 7 {
+			t.Errorf("compact=%v, got %v %v %d %d %f %s %d", compact, v1, v2, v3, v4, v5, v100, v104)
+		}
+	}
+}
+
+func TestThriftReaderInvalid(t *testing.T) {
+	deepStruct := func(compact bool) []byte {
+		w := &thriftWriter{compact: compact}
+		for i := 0; i < THRIFT_MAX_DEPTH+1; i++ {
+			w.structBegin()
+			w.fieldBegin(THRIFT_STRUCT, 1, false)
+		}
+		return w.buf
+	}
+	testCases := []struct {
+		name    string
+		compact bool
+		data    []byte
+	}{
+		{"binary empty", false, []byte{}},
+		{"binary truncated string", false, []byte{THRIFT_STRING, 0, 1, 0, 0, 0, 10, 'a'}},
+		{"binary negative size", false, []byte{THRIFT_STRING, 0, 1, 0xff, 0xff, 0xff, 0xff}},
+		{"binary huge list", false, []byte{THRIFT_LIST, 0, 1, THRIFT_I64, 0x7f, 0xff, 0xff, 0xff}},
+		{"binary invalid type", false, []byte{1, 0, 1, 0}},
+		{"binary too deep", false, deepStruct(false)},
+		{"compact truncated varint", true, []byte{0x15, 0x80}},
+		{"compact varint overflow", true, []byte{0x16, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}},
+		{"compact huge list", true, []byte{0x19, 0xf6, 0xff, 0xff, 0xff, 0x0f}},
+		{"compact invalid type", true, []byte{0x1d, 0}},
+		{"compact too deep", true, deepStruct(true)},
+	}
+	for _, c := range testCases {
+		r := newThriftReader(c.data, c.compact)
+		r.readStruct(func(uint8, int16) bool { return false })
+		if r.Err() == nil {
+			t.Errorf("%s: expected error", c.name)
+		}
+	}
+}
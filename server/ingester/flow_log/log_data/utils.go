@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"net"
 	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 func IPIntToString(ipInt uint32) string {
@@ -39,3 +41,41 @@ func ParseUrlPath(rawURL string) (string, error) {
 
 	return parts[1][pathStart:], nil
 }
+
+// rangeProtoFields calls f for every field of the protobuf message, the value of length-delimited fields is
+// passed by 'value', and the value of other fields is passed by 'number'.
+func rangeProtoFields(b []byte, f func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var value []byte
+		var number uint64
+		switch typ {
+		case protowire.VarintType:
+			number, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			number = uint64(v)
+		case protowire.Fixed64Type:
+			number, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := f(num, typ, value, number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -17,7 +17,10 @@
 package log_data
 
 import (
+	"bytes"
 	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 func TestParseUrlPath(t *testing.T) {
@@ -46,3 +49,70 @@ func TestParseUrlPath(t *testing.T) {
 		}
 	}
 }
+
+func TestRangeProtoFields(t *testing.T) {
+	type field struct {
+		num    protowire.Number
+		typ    protowire.Type
+		value  []byte
+		number uint64
+	}
+	valid := protowire.AppendTag(nil, 1, protowire.VarintType)
+	valid = protowire.AppendVarint(valid, 300)
+	valid = protowire.AppendTag(valid, 2, protowire.Fixed32Type)
+	valid = protowire.AppendFixed32(valid, 7)
+	valid = protowire.AppendTag(valid, 3, protowire.Fixed64Type)
+	valid = protowire.AppendFixed64(valid, 1<<40)
+	valid = protowire.AppendTag(valid, 4, protowire.BytesType)
+	valid = protowire.AppendBytes(valid, []byte("abc"))
+	valid = protowire.AppendTag(valid, 5, protowire.StartGroupType)
+	valid = protowire.AppendTag(valid, 1, protowire.VarintType)
+	valid = protowire.AppendVarint(valid, 1)
+	valid = protowire.AppendTag(valid, 5, protowire.EndGroupType)
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected []field
+		err      bool
+	}{
+		{"empty", nil, nil, false},
+		{"all types", valid, []field{
+			{1, protowire.VarintType, nil, 300},
+			{2, protowire.Fixed32Type, nil, 7},
+			{3, protowire.Fixed64Type, nil, 1 << 40},
+			{4, protowire.BytesType, []byte("abc"), 0},
+			{5, protowire.StartGroupType, nil, 0},
+		}, false},
+		{"truncated tag", []byte{0x80}, nil, true},
+		{"invalid field number", []byte{0x00, 0x01}, nil, true},
+		{"truncated varint", []byte{0x08, 0x80}, nil, true},
+		{"truncated fixed64", []byte{0x19, 0x01, 0x02}, nil, true},
+		{"bytes too long", []byte{0x22, 0x05, 'a'}, nil, true},
+		{"unterminated group", []byte{0x2b, 0x08, 0x01}, nil, true},
+	}
+	for _, c := range testCases {
+		var got []field
+		err := rangeProtoFields(c.data, func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error {
+			got = append(got, field{num, typ, value, number})
+			return nil
+		})
+		if (err != nil) != c.err {
+			t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+			continue
+		}
+		if c.err {
+			continue
+		}
+		if len(got) != len(c.expected) {
+			t.Errorf("%s: got %d fields, expected %d", c.name, len(got), len(c.expected))
+			continue
+		}
+		for i := range got {
+			if got[i].num != c.expected[i].num || got[i].typ != c.expected[i].typ ||
+				!bytes.Equal(got[i].value, c.expected[i].value) || got[i].number != c.expected[i].number {
+				t.Errorf("%s: field %d is %+v, expected %+v", c.name, i, got[i], c.expected[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_data
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	flowlogCfg "github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+	"github.com/deepflowio/deepflow/server/libs/grpc"
+)
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int32  `json:"port"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// zipkinSpan is the span of Zipkin v2, refer to: https://github.com/openzipkin/zipkin-api/blob/master/zipkin2-api.yaml
+type zipkinSpan struct {
+	TraceId        string             `json:"traceId"`
+	ParentId       string             `json:"parentId"`
+	Id             string             `json:"id"`
+	Kind           string             `json:"kind"`
+	Name           string             `json:"name"`
+	Timestamp      uint64             `json:"timestamp"`
+	Duration       uint64             `json:"duration"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+
+	// the IDs of Zipkin protobuf are bytes, they are converted from the hex string of JSON
+	traceId, parentId, id []byte
+}
+
+// ZipkinDataToL7FlowLogs converts a list of Zipkin v2 spans encoded in JSON or Protobuf to L7FlowLogs.
+// The spans are converted to OpenTelemetry spans first, so that they are handled in the same way as OpenTelemetry.
+func ZipkinDataToL7FlowLogs(vtapID, orgId, teamId uint16, data []byte, platformData *grpc.PlatformInfoTable, cfg *flowlogCfg.Config) ([]*L7FlowLog, error) {
+	spans, err := decodeZipkinSpans(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*L7FlowLog, 0, len(spans))
+	for i := range spans {
+		span, resAttributes := zipkinSpanToOTelSpan(&spans[i])
+		ret = append(ret, spanToL7FlowLog(vtapID, orgId, teamId, span, resAttributes, platformData, cfg))
+	}
+	return ret, nil
+}
+
+func decodeZipkinSpans(data []byte) ([]zipkinSpan, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("zipkin data is empty")
+	}
+
+	// the JSON encoding is a list of spans, and the Protobuf encoding is ListOfSpans whose first byte is the header of field 'spans'
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		spans, err := decodeZipkinJSONSpans(trimmed)
+		// a Protobuf whose first span is 91 bytes long also starts with "\n["
+		if err == nil || data[0] != 0x0a {
+			return spans, err
+		}
+	}
+	if data[0] == 0x0a {
+		return decodeZipkinProtoSpans(data)
+	}
+	return nil, fmt.Errorf("unknown zipkin data format, first byte 0x%x", data[0])
+}
+
+func decodeZipkinJSONSpans(data []byte) ([]zipkinSpan, error) {
+	var spans []zipkinSpan
+	if err := json.Unmarshal(data, &spans); err != nil {
+		return nil, fmt.Errorf("zipkin json decode failed: %s", err)
+	}
+	for i := range spans {
+		s := &spans[i]
+		var err error
+		if s.traceId, err = hex.DecodeString(s.TraceId); err != nil {
+			return nil, fmt.Errorf("invalid zipkin trace id %s", s.TraceId)
+		}
+		if s.id, err = hex.DecodeString(s.Id); err != nil {
+			return nil, fmt.Errorf("invalid zipkin span id %s", s.Id)
+		}
+		if s.parentId, err = hex.DecodeString(s.ParentId); err != nil {
+			return nil, fmt.Errorf("invalid zipkin parent span id %s", s.ParentId)
+		}
+	}
+	return spans, nil
+}
+
+// Zipkin span kinds of Protobuf
+var zipkinProtoKinds = [...]string{"", "CLIENT", "SERVER", "PRODUCER", "CONSUMER"}
+
+// decodeZipkinProtoSpans decodes the ListOfSpans of zipkin.proto, refer to: https://github.com/openzipkin/zipkin-api/blob/master/zipkin.proto
+func decodeZipkinProtoSpans(data []byte) ([]zipkinSpan, error) {
+	var spans []zipkinSpan
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		s, err := decodeZipkinProtoSpan(value)
+		if err != nil {
+			return err
+		}
+		spans = append(spans, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("zipkin protobuf decode failed: %s", err)
+	}
+	return spans, nil
+}
+
+func decodeZipkinProtoSpan(data []byte) (zipkinSpan, error) {
+	s := zipkinSpan{}
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error {
+		var err error
+		switch num {
+		case 1:
+			s.traceId = value
+		case 2:
+			s.parentId = value
+		case 3:
+			s.id = value
+		case 4:
+			if number < uint64(len(zipkinProtoKinds)) {
+				s.Kind = zipkinProtoKinds[number]
+			}
+		case 5:
+			s.Name = string(value)
+		case 6:
+			s.Timestamp = number
+		case 7:
+			s.Duration = number
+		case 8:
+			s.LocalEndpoint, err = decodeZipkinProtoEndpoint(value)
+		case 9:
+			s.RemoteEndpoint, err = decodeZipkinProtoEndpoint(value)
+		case 10:
+			var annotation zipkinAnnotation
+			err = rangeProtoFields(value, func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error {
+				switch num {
+				case 1:
+					annotation.Timestamp = number
+				case 2:
+					annotation.Value = string(value)
+				}
+				return nil
+			})
+			s.Annotations = append(s.Annotations, annotation)
+		case 11:
+			var key, val string
+			err = rangeProtoFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				switch num {
+				case 1:
+					key = string(value)
+				case 2:
+					val = string(value)
+				}
+				return nil
+			})
+			if s.Tags == nil {
+				s.Tags = make(map[string]string)
+			}
+			s.Tags[key] = val
+		}
+		return err
+	})
+	return s, err
+}
+
+func decodeZipkinProtoEndpoint(data []byte) (*zipkinEndpoint, error) {
+	endpoint := &zipkinEndpoint{}
+	err := rangeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, number uint64) error {
+		switch num {
+		case 1:
+			endpoint.ServiceName = string(value)
+		case 2:
+			if len(value) == net.IPv4len {
+				endpoint.IPv4 = net.IP(value).String()
+			}
+		case 3:
+			if len(value) == net.IPv6len {
+				endpoint.IPv6 = net.IP(value).String()
+			}
+		case 4:
+			endpoint.Port = int32(number)
+		}
+		return nil
+	})
+	return endpoint, err
+}
+
+func (e *zipkinEndpoint) ip() string {
+	if e.IPv4 != "" {
+		return e.IPv4
+	}
+	return e.IPv6
+}
+
+// zipkinSpanToOTelSpan converts the Zipkin span to the OpenTelemetry span and its resource attributes,
+// the local endpoint is used as the resource and the remote endpoint is used as the peer.
+func zipkinSpanToOTelSpan(s *zipkinSpan) (*v1.Span, []*v11.KeyValue) {
+	span := &v1.Span{
+		TraceId:           s.traceId,
+		SpanId:            s.id,
+		ParentSpanId:      s.parentId,
+		Name:              s.Name,
+		StartTimeUnixNano: s.Timestamp * uint64(time.Microsecond),
+		EndTimeUnixNano:   (s.Timestamp + s.Duration) * uint64(time.Microsecond),
+	}
+	switch s.Kind {
+	case "CLIENT":
+		span.Kind = v1.Span_SPAN_KIND_CLIENT
+	case "SERVER":
+		span.Kind = v1.Span_SPAN_KIND_SERVER
+	case "PRODUCER":
+		span.Kind = v1.Span_SPAN_KIND_PRODUCER
+	case "CONSUMER":
+		span.Kind = v1.Span_SPAN_KIND_CONSUMER
+	default:
+		span.Kind = v1.Span_SPAN_KIND_INTERNAL
+	}
+
+	keys := make([]string, 0, len(s.Tags))
+	for key := range s.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	span.Attributes = make([]*v11.KeyValue, 0, len(keys)+3)
+	for _, key := range keys {
+		value := s.Tags[key]
+		switch key {
+		case "error":
+			// the value of the 'error' tag is the error message, refer to: https://zipkin.io/zipkin-api/#/default/post_spans
+			span.Status = &v1.Status{Code: v1.Status_STATUS_CODE_ERROR, Message: value}
+			continue
+		case "http.path":
+			key = "http.target"
+		}
+		span.Attributes = append(span.Attributes, stringKeyValue(key, value))
+	}
+	if remote := s.RemoteEndpoint; remote != nil {
+		if ip := remote.ip(); ip != "" {
+			span.Attributes = append(span.Attributes, stringKeyValue("net.peer.ip", ip))
+		}
+		if remote.Port != 0 {
+			span.Attributes = append(span.Attributes, stringKeyValue("net.peer.port", strconv.Itoa(int(remote.Port))))
+		}
+		if remote.ServiceName != "" {
+			span.Attributes = append(span.Attributes, stringKeyValue("peer.service", remote.ServiceName))
+		}
+	}
+
+	for _, annotation := range s.Annotations {
+		span.Events = append(span.Events, &v1.Span_Event{TimeUnixNano: annotation.Timestamp * uint64(time.Microsecond), Name: annotation.Value})
+	}
+
+	var resAttributes []*v11.KeyValue
+	if local := s.LocalEndpoint; local != nil {
+		resAttributes = append(resAttributes, stringKeyValue("service.name", local.ServiceName))
+		if ip := local.ip(); ip != "" {
+			resAttributes = append(resAttributes, stringKeyValue("app.host.ip", ip))
+		}
+	}
+	return span, resAttributes
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log_data
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const zipkinTestJSON = `
+[{
+	"traceId": "00000000000000010000000000000002",
+	"parentId": "0000000000000004",
+	"id": "0000000000000003",
+	"kind": "CLIENT",
+	"name": "get /api",
+	"timestamp": 1000000,
+	"duration": 500,
+	"localEndpoint": {"serviceName": "frontend", "ipv4": "10.0.0.1"},
+	"remoteEndpoint": {"serviceName": "backend", "ipv4": "10.0.0.2", "port": 8080},
+	"annotations": [{"timestamp": 1000100, "value": "ws"}],
+	"tags": {"http.method": "GET", "http.path": "/api", "error": "timeout"}
+}]`
+
+func zipkinTestProto() []byte {
+	endpoint := func(serviceName string, ip net.IP, port uint64) []byte {
+		b := appendProtoBytes(nil, 1, []byte(serviceName))
+		b = appendProtoBytes(b, 2, ip.To4())
+		if port != 0 {
+			b = appendProtoVarint(b, 4, port)
+		}
+		return b
+	}
+	tag := func(key, value string) []byte {
+		return appendProtoBytes(appendProtoBytes(nil, 1, []byte(key)), 2, []byte(value))
+	}
+
+	var span []byte
+	span = appendProtoBytes(span, 1, jaegerTestTraceId)
+	span = appendProtoBytes(span, 2, jaegerTestParentId)
+	span = appendProtoBytes(span, 3, jaegerTestSpanId)
+	span = appendProtoVarint(span, 4, 1) // CLIENT
+	span = appendProtoBytes(span, 5, []byte("get /api"))
+	span = protowire.AppendTag(span, 6, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, 1000000)
+	span = appendProtoVarint(span, 7, 500)
+	span = appendProtoBytes(span, 8, endpoint("frontend", net.ParseIP("10.0.0.1"), 0))
+	span = appendProtoBytes(span, 9, endpoint("backend", net.ParseIP("10.0.0.2"), 8080))
+	annotation := protowire.AppendTag(nil, 1, protowire.Fixed64Type)
+	annotation = protowire.AppendFixed64(annotation, 1000100)
+	annotation = appendProtoBytes(annotation, 2, []byte("ws"))
+	span = appendProtoBytes(span, 10, annotation)
+	span = appendProtoBytes(span, 11, tag("http.method", "GET"))
+	span = appendProtoBytes(span, 11, tag("http.path", "/api"))
+	span = appendProtoBytes(span, 11, tag("error", "timeout"))
+	return appendProtoBytes(nil, 1, span)
+}
+
+func TestDecodeZipkinSpans(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"json", []byte(zipkinTestJSON)},
+		{"protobuf", zipkinTestProto()},
+	}
+	for _, c := range testCases {
+		spans, err := decodeZipkinSpans(c.data)
+		if err != nil {
+			t.Fatalf("%s: decode failed: %s", c.name, err)
+		}
+		if len(spans) != 1 {
+			t.Fatalf("%s: got %d spans, expected 1", c.name, len(spans))
+		}
+
+		span, resAttributes := zipkinSpanToOTelSpan(&spans[0])
+		if !bytes.Equal(span.TraceId, jaegerTestTraceId) || !bytes.Equal(span.SpanId, jaegerTestSpanId) || !bytes.Equal(span.ParentSpanId, jaegerTestParentId) {
+			t.Errorf("%s: unexpected ids %x %x %x", c.name, span.TraceId, span.SpanId, span.ParentSpanId)
+		}
+		if span.Name != "get /api" || span.Kind != v1.Span_SPAN_KIND_CLIENT {
+			t.Errorf("%s: unexpected name %s or kind %s", c.name, span.Name, span.Kind)
+		}
+		if span.StartTimeUnixNano != 1000000000 || span.EndTimeUnixNano != 1000500000 {
+			t.Errorf("%s: unexpected time %d - %d", c.name, span.StartTimeUnixNano, span.EndTimeUnixNano)
+		}
+		if span.Status.GetCode() != v1.Status_STATUS_CODE_ERROR || span.Status.GetMessage() != "timeout" {
+			t.Errorf("%s: unexpected status %v", c.name, span.Status)
+		}
+		if len(span.Events) != 1 || span.Events[0].Name != "ws" || span.Events[0].TimeUnixNano != 1000100000 {
+			t.Errorf("%s: unexpected events %v", c.name, span.Events)
+		}
+
+		expected := []string{
+			"http.method", "GET",
+			"http.target", "/api",
+			"net.peer.ip", "10.0.0.2",
+			"net.peer.port", "8080",
+			"peer.service", "backend",
+		}
+		if len(span.Attributes)*2 != len(expected) {
+			t.Fatalf("%s: unexpected attributes %v", c.name, span.Attributes)
+		}
+		for i, attr := range span.Attributes {
+			if attr.Key != expected[2*i] || attr.Value.GetStringValue() != expected[2*i+1] {
+				t.Errorf("%s: attribute %d is %s=%s, expected %s=%s", c.name, i, attr.Key, attr.Value.GetStringValue(), expected[2*i], expected[2*i+1])
+			}
+		}
+
+		if len(resAttributes) != 2 || resAttributes[0].Value.GetStringValue() != "frontend" || resAttributes[1].Value.GetStringValue() != "10.0.0.1" {
+			t.Errorf("%s: unexpected resource attributes %v", c.name, resAttributes)
+		}
+	}
+}
+
+func TestZipkinDataFormat(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		err  bool
+	}{
+		{"empty", []byte{}, true},
+		{"whitespace", []byte(" \n"), true},
+		{"json empty list", []byte(" \r\n[]"), false},
+		{"json object", []byte(`{"traceId":"01"}`), true},
+		{"json invalid id", []byte(`[{"traceId":"xyz","id":"01"}]`), true},
+		{"thrift", []byte{0x0c, 0, 1, 0}, true},
+		{"protobuf truncated", zipkinTestProto()[:10], true},
+		// a Protobuf whose first span is 91 bytes long starts with "\n[", it must not be decoded as JSON
+		{"protobuf like json", appendProtoBytes(nil, 1, appendProtoBytes(nil, 5, make([]byte, 89))), false},
+	}
+	for _, c := range testCases {
+		spans, err := decodeZipkinSpans(c.data)
+		if (err != nil) != c.err {
+			t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+		}
+		if err == nil && c.name == "protobuf like json" && len(spans) != 1 {
+			t.Errorf("%s: got %d spans, expected 1", c.name, len(spans))
+		}
+	}
+}
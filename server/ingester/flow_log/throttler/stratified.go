@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package throttler
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/dbwriter"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const (
+	// the stratum without items for more than STRATUM_IDLE_PERIODS throttle buckets is removed
+	STRATUM_IDLE_PERIODS = 8
+)
+
+// StratifiedItem is implemented by the flow logs which can be throttled by stratum
+type StratifiedItem interface {
+	StratumKey(keys config.StratumKeys) string
+	IsErrorResponse() bool
+	GetResponseDuration() uint64 // us
+}
+
+type StratumCounter struct {
+	In      int64 `statsd:"in"`
+	Sampled int64 `statsd:"sampled"`
+	utils.Closable
+}
+
+func (c *StratumCounter) GetCounter() interface{} {
+	counter := *c
+	*c = StratumCounter{Closable: c.Closable}
+	return &counter
+}
+
+type StratifiedCounter struct {
+	KeepErrorCount int64 `statsd:"keep-error-count"`
+	KeepSlowCount  int64 `statsd:"keep-slow-count"`
+	OverflowCount  int64 `statsd:"stratum-overflow-count"`
+	Strata         int64 `statsd:"strata"`
+	utils.Closable
+}
+
+func (c *StratifiedCounter) GetCounter() interface{} {
+	counter := *c
+	*c = StratifiedCounter{Strata: c.Strata, Closable: c.Closable}
+	return &counter
+}
+
+type stratum struct {
+	items       []interface{} // the guaranteed samples of the stratum
+	count       int           // the count of items in the current period
+	idlePeriods int
+	counter     StratumCounter
+}
+
+type stratifiedSampler struct {
+	keys          config.StratumKeys
+	keepError     bool
+	slowThreshold uint64 // us
+	quota         int    // the guaranteed sample count of each stratum in a period
+	maxStrata     int
+	strata        map[string]*stratum
+	statTags      stats.OptionStatTags
+	counter       StratifiedCounter
+}
+
+// NewStratifiedThrottlingQueue creates a ThrottlingQueue which reserves a guaranteed share of the throttle for
+// each stratum. The rest of the throttle is shared by all flow logs in the same way as NewThrottlingQueue.
+// It is the same as NewThrottlingQueue if cfg is nil or disabled.
+func NewStratifiedThrottlingQueue(throttle, throttleBucket int, flowLogWriter *dbwriter.FlowLogWriter, index int, cfg *config.StratifiedThrottle, name string, queueID int) *ThrottlingQueue {
+	thq := NewThrottlingQueue(throttle, throttleBucket, flowLogWriter, index)
+	if cfg == nil || !cfg.Enabled || thq.SampleDisabled() {
+		return thq
+	}
+
+	quota := thq.Throttle * cfg.ReservedRatio / 100 / cfg.MaxStrata
+	if quota == 0 {
+		quota = 1
+	}
+	sharedSize := thq.Throttle - quota*cfg.MaxStrata
+	if sharedSize < 0 {
+		sharedSize = 0
+	}
+	thq.sampleItems = make([]interface{}, sharedSize)
+	thq.sampleStrata = make([]*stratum, sharedSize)
+	thq.stratified = &stratifiedSampler{
+		keys:          cfg.StratumKeys,
+		keepError:     cfg.KeepError,
+		slowThreshold: uint64(cfg.SlowThreshold) * 1000,
+		quota:         quota,
+		maxStrata:     cfg.MaxStrata,
+		strata:        make(map[string]*stratum),
+		statTags:      stats.OptionStatTags{"type": name, "thread": strconv.Itoa(queueID)},
+	}
+	common.RegisterCountableForIngester("flow_log_throttler", &thq.stratified.counter, thq.stratified.statTags)
+	return thq
+}
+
+func (s *stratifiedSampler) getStratum(key string) *stratum {
+	if st, ok := s.strata[key]; ok {
+		return st
+	}
+	if len(s.strata) >= s.maxStrata {
+		return nil
+	}
+	st := &stratum{items: make([]interface{}, 0, s.quota)}
+	s.strata[key] = st
+	s.counter.Strata = int64(len(s.strata))
+	tags := stats.OptionStatTags{"stratum": key}
+	for k, v := range s.statTags {
+		tags[k] = v
+	}
+	common.RegisterCountableForIngester("flow_log_throttler_stratum", &st.counter, tags)
+	return st
+}
+
+func (thq *ThrottlingQueue) sendStratified(flow interface{}, item StratifiedItem) bool {
+	s := thq.stratified
+	if s.keepError && item.IsErrorResponse() {
+		s.counter.KeepErrorCount++
+		thq.SendWithoutThrottling(flow)
+		return true
+	}
+	if s.slowThreshold > 0 && item.GetResponseDuration() >= s.slowThreshold {
+		s.counter.KeepSlowCount++
+		thq.SendWithoutThrottling(flow)
+		return true
+	}
+
+	st := s.getStratum(item.StratumKey(s.keys))
+	if st == nil {
+		s.counter.OverflowCount++
+		return thq.sample(flow, nil)
+	}
+	st.count++
+	st.counter.In++
+	if len(st.items) < s.quota {
+		st.items = append(st.items, flow)
+		return true
+	}
+	// Reservoir Sampling in the stratum, the item replaced competes for the shared samples
+	r := rand.Intn(st.count)
+	if r < s.quota {
+		replaced := st.items[r]
+		st.items[r] = flow
+		thq.sample(replaced, st)
+		return true
+	}
+	return thq.sample(flow, st)
+}
+
+func (thq *ThrottlingQueue) flushStrata() {
+	s := thq.stratified
+	for i, st := range thq.sampleStrata[:thq.periodEmitCount] {
+		if st != nil {
+			st.counter.Sampled++
+			thq.sampleStrata[i] = nil
+		}
+	}
+	for key, st := range s.strata {
+		if st.count == 0 {
+			st.idlePeriods++
+			if st.idlePeriods > STRATUM_IDLE_PERIODS {
+				st.counter.Close()
+				delete(s.strata, key)
+			}
+			continue
+		}
+		st.idlePeriods = 0
+		st.count = 0
+		st.counter.Sampled += int64(len(st.items))
+		thq.put(st.items)
+		for i := range st.items {
+			st.items[i] = nil
+		}
+		st.items = st.items[:0]
+	}
+	s.counter.Strata = int64(len(s.strata))
+}
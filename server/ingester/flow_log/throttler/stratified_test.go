@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package throttler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+)
+
+type testItem struct {
+	service  string
+	error    bool
+	duration uint64
+	released bool
+}
+
+func (i *testItem) StratumKey(keys config.StratumKeys) string { return i.service }
+func (i *testItem) IsErrorResponse() bool                     { return i.error }
+func (i *testItem) GetResponseDuration() uint64               { return i.duration }
+func (i *testItem) Release()                                  { i.released = true }
+
+func newTestStratifiedQueue(t *testing.T, throttle, maxStrata int) *ThrottlingQueue {
+	cfg := &config.StratifiedThrottle{Enabled: true, ReservedRatio: 50, MaxStrata: maxStrata, KeepError: true, SlowThreshold: 1}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	thq := NewStratifiedThrottlingQueue(throttle, 1, nil, 0, cfg, "test", 0)
+	if thq.stratified == nil {
+		t.Fatal("stratified throttling is not enabled")
+	}
+	return thq
+}
+
+func TestStratifiedThrottling(t *testing.T) {
+	// 100 per period, 25 reserved for each of the 2 strata and 50 shared
+	thq := newTestStratifiedQueue(t, 100, 2)
+	// keep all items in the same period
+	thq.throttleBucket = 1 << 30
+	thq.lastFlush = time.Now().Unix()
+
+	var chatty, rare []*testItem
+	for i := 0; i < 10000; i++ {
+		item := &testItem{service: "chatty"}
+		chatty = append(chatty, item)
+		thq.SendWithThrottling(item)
+	}
+	for i := 0; i < 10; i++ {
+		item := &testItem{service: "rare"}
+		rare = append(rare, item)
+		if !thq.SendWithThrottling(item) {
+			t.Errorf("rare item %d is not sent", i)
+		}
+	}
+	for _, item := range rare {
+		if item.released {
+			t.Errorf("rare item is released")
+		}
+	}
+
+	// error and slow items are always kept
+	thq.SendWithThrottling(&testItem{service: "chatty", error: true})
+	thq.SendWithThrottling(&testItem{service: "chatty", duration: 1000})
+	if len(thq.nonSampleItems) != 2 {
+		t.Errorf("got %d non sample items, expected 2", len(thq.nonSampleItems))
+	}
+
+	// the strata beyond max-strata are sampled by the shared samples
+	thq.SendWithThrottling(&testItem{service: "other"})
+	counter := thq.stratified.counter
+	if counter.KeepErrorCount != 1 || counter.KeepSlowCount != 1 || counter.OverflowCount != 1 || counter.Strata != 2 {
+		t.Errorf("got counter %+v", counter)
+	}
+
+	kept := 0
+	for _, item := range chatty {
+		if !item.released {
+			kept++
+		}
+	}
+	if kept != 75 {
+		t.Errorf("got %d chatty items kept, expected 75", kept)
+	}
+
+	thq.flush()
+	chattyCounter := thq.stratified.strata["chatty"].counter
+	rareCounter := thq.stratified.strata["rare"].counter
+	if chattyCounter.In != 10000 || rareCounter.In != 10 || rareCounter.Sampled != 10 || chattyCounter.Sampled+rareCounter.Sampled > 100 {
+		t.Errorf("got chatty counter %+v, rare counter %+v", chattyCounter, rareCounter)
+	}
+}
+
+func TestStratumExpire(t *testing.T) {
+	thq := newTestStratifiedQueue(t, 100, 2)
+	thq.SendWithThrottling(&testItem{service: "a"})
+	for i := 0; i <= STRATUM_IDLE_PERIODS+1; i++ {
+		thq.flush()
+	}
+	if len(thq.stratified.strata) != 0 {
+		t.Errorf("idle stratum is not removed")
+	}
+}
@@ -0,0 +1,327 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package throttler
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const (
+	TAIL_SAMPLING_SHARDS = 64
+	// the decision of a trace is kept for TAIL_SAMPLING_DECISION_WAITS decision waits, the spans arriving late follow it
+	TAIL_SAMPLING_DECISION_WAITS = 6
+	// the interval to remove the expired decisions, in seconds
+	TAIL_SAMPLING_GC_INTERVAL = 1
+)
+
+// TraceItem is implemented by the flow logs which can be sampled by trace
+type TraceItem interface {
+	GetTraceID() string
+	GetAppService() string
+	GetEndpoint() string
+	IsErrorResponse() bool
+	GetResponseDuration() uint64 // us
+	Release()
+}
+
+type traceState struct {
+	firstSeen   int64 // the time of the first span seen by all decoder queues, in seconds
+	interesting bool  // any span of the trace matches the keep policies
+	decided     bool
+	keep        bool
+	expire      int64 // the time to remove the decision, in seconds
+}
+
+type traceShard struct {
+	sync.Mutex
+	traces map[string]*traceState
+}
+
+type TailSamplerCounter struct {
+	Traces int64 `statsd:"traces"`
+}
+
+// TailSampler holds the trace states shared by all decoder queues, so that the spans of a trace received by
+// different queues get the same decision
+type TailSampler struct {
+	decisionWait      int64 // s
+	maxBufferedSpans  int
+	keepError         bool
+	latencyThreshold  uint64 // us
+	services          map[string]bool
+	endpoints         map[string]bool
+	samplingThreshold uint32 // the traces whose hash%10000 is less than it are kept
+
+	shards [TAIL_SAMPLING_SHARDS]traceShard
+	lastGC int64
+	utils.Closable
+}
+
+// NewTailSampler returns nil if the tail sampling is disabled
+func NewTailSampler(cfg *config.TailSampling) *TailSampler {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	s := &TailSampler{
+		decisionWait:      int64(cfg.DecisionWait),
+		maxBufferedSpans:  cfg.MaxBufferedSpans,
+		keepError:         cfg.KeepError,
+		latencyThreshold:  uint64(cfg.LatencyThreshold) * 1000,
+		services:          make(map[string]bool),
+		endpoints:         make(map[string]bool),
+		samplingThreshold: uint32(cfg.SamplingRatio * 100),
+	}
+	for _, service := range cfg.Services {
+		s.services[service] = true
+	}
+	for _, endpoint := range cfg.Endpoints {
+		s.endpoints[endpoint] = true
+	}
+	for i := range s.shards {
+		s.shards[i].traces = make(map[string]*traceState)
+	}
+	common.RegisterCountableForIngester("flow_log_tail_sampler", s)
+	return s
+}
+
+func (s *TailSampler) GetCounter() interface{} {
+	counter := &TailSamplerCounter{}
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.Lock()
+		counter.Traces += int64(len(shard.traces))
+		shard.Unlock()
+	}
+	return counter
+}
+
+func traceHash(traceID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(traceID))
+	return h.Sum32()
+}
+
+func (s *TailSampler) shard(hash uint32) *traceShard {
+	return &s.shards[hash%TAIL_SAMPLING_SHARDS]
+}
+
+// interesting returns true if the span matches any keep policy
+func (s *TailSampler) interesting(item TraceItem) bool {
+	return (s.keepError && item.IsErrorResponse()) ||
+		(s.latencyThreshold > 0 && item.GetResponseDuration() >= s.latencyThreshold) ||
+		s.services[item.GetAppService()] ||
+		s.endpoints[item.GetEndpoint()]
+}
+
+// observe records a span of the trace, returns the first seen time of the trace, and the decision if it is decided
+func (s *TailSampler) observe(traceID string, interesting bool, now int64) (firstSeen int64, decided, keep bool) {
+	shard := s.shard(traceHash(traceID))
+	shard.Lock()
+	defer shard.Unlock()
+	state, ok := shard.traces[traceID]
+	if !ok {
+		shard.traces[traceID] = &traceState{firstSeen: now, interesting: interesting}
+		return now, false, false
+	}
+	if state.decided {
+		return state.firstSeen, true, state.keep
+	}
+	state.interesting = state.interesting || interesting
+	return state.firstSeen, false, false
+}
+
+// decide makes the decision of the trace if it is not decided by other queues, the traces which do not match
+// any keep policy are sampled by the hash of the trace id, so the decision is the same even if the state is lost
+func (s *TailSampler) decide(traceID string, now int64) bool {
+	hash := traceHash(traceID)
+	shard := s.shard(hash)
+	shard.Lock()
+	defer shard.Unlock()
+	state, ok := shard.traces[traceID]
+	if !ok {
+		state = &traceState{firstSeen: now}
+		shard.traces[traceID] = state
+	}
+	if !state.decided {
+		state.decided = true
+		state.keep = state.interesting || hash%10000 < s.samplingThreshold
+		state.expire = now + s.decisionWait*TAIL_SAMPLING_DECISION_WAITS
+	}
+	return state.keep
+}
+
+// gc removes the expired decisions, and the undecided states whose spans are never decided
+func (s *TailSampler) gc(now int64) {
+	lastGC := atomic.LoadInt64(&s.lastGC)
+	if now-lastGC < TAIL_SAMPLING_GC_INTERVAL || !atomic.CompareAndSwapInt64(&s.lastGC, lastGC, now) {
+		return
+	}
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.Lock()
+		for traceID, state := range shard.traces {
+			if (state.decided && state.expire <= now) ||
+				(!state.decided && state.firstSeen+s.decisionWait*TAIL_SAMPLING_DECISION_WAITS <= now) {
+				delete(shard.traces, traceID)
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+type TailSamplingCounter struct {
+	InCount          int64 `statsd:"in-count"`
+	KeepCount        int64 `statsd:"keep-count"`
+	DropCount        int64 `statsd:"drop-count"`
+	KeepTraceCount   int64 `statsd:"keep-trace-count"`
+	DropTraceCount   int64 `statsd:"drop-trace-count"`
+	EarlyDecideCount int64 `statsd:"early-decide-count"`
+	BufferedSpans    int64 `statsd:"buffered-spans"`
+}
+
+type bufferedTrace struct {
+	traceID  string
+	deadline int64 // the time to decide the trace, in seconds
+	items    []TraceItem
+}
+
+// TailSamplingQueue buffers the spans received by a decoder queue by trace id, and sends the spans of the kept
+// traces to the throttler without throttling when the traces are decided.
+type TailSamplingQueue struct {
+	sampler   *TailSampler
+	throttler *ThrottlingQueue
+	output    func(item TraceItem, kept bool)
+
+	traces  map[string]*bufferedTrace
+	pending []*bufferedTrace // in the order of buffering
+	spans   int
+
+	counter *TailSamplingCounter
+	utils.Closable
+}
+
+// NewTailSamplingQueue returns nil if sampler is nil. output is called for each span when its trace is decided,
+// before the span is sent to the throttler or released.
+func NewTailSamplingQueue(sampler *TailSampler, throttler *ThrottlingQueue, output func(item TraceItem, kept bool), name string, queueID int) *TailSamplingQueue {
+	if sampler == nil {
+		return nil
+	}
+	q := &TailSamplingQueue{
+		sampler:   sampler,
+		throttler: throttler,
+		output:    output,
+		traces:    make(map[string]*bufferedTrace),
+		counter:   &TailSamplingCounter{},
+	}
+	common.RegisterCountableForIngester("flow_log_tail_sampling", q, stats.OptionStatTags{"type": name, "thread": strconv.Itoa(queueID)})
+	return q
+}
+
+func (q *TailSamplingQueue) GetCounter() interface{} {
+	var counter *TailSamplingCounter
+	counter, q.counter = q.counter, &TailSamplingCounter{}
+	counter.BufferedSpans = int64(q.spans)
+	return counter
+}
+
+// Put takes over the reference of the item, the item must have a trace id
+func (q *TailSamplingQueue) Put(item TraceItem) {
+	q.put(item, time.Now().Unix())
+}
+
+func (q *TailSamplingQueue) put(item TraceItem, now int64) {
+	q.counter.InCount++
+	traceID := item.GetTraceID()
+	interesting := q.sampler.interesting(item)
+	if trace, ok := q.traces[traceID]; ok {
+		if interesting {
+			q.sampler.observe(traceID, interesting, now)
+		}
+		trace.items = append(trace.items, item)
+		q.spans++
+	} else {
+		firstSeen, decided, keep := q.sampler.observe(traceID, interesting, now)
+		if decided {
+			q.emit(item, keep)
+			return
+		}
+		trace := &bufferedTrace{traceID: traceID, deadline: firstSeen + q.sampler.decisionWait, items: []TraceItem{item}}
+		q.traces[traceID] = trace
+		q.pending = append(q.pending, trace)
+		q.spans++
+	}
+
+	// decide the oldest traces early to limit the memory
+	for q.spans > q.sampler.maxBufferedSpans && len(q.pending) > 0 {
+		q.counter.EarlyDecideCount++
+		q.decide(now)
+	}
+}
+
+// Flush decides the traces whose decision wait is over
+func (q *TailSamplingQueue) Flush() {
+	q.flush(time.Now().Unix())
+}
+
+func (q *TailSamplingQueue) flush(now int64) {
+	// the pending traces are nearly in the order of deadline, a trace whose first span is seen earlier by other
+	// queues may wait for the traces ahead of it, which is at most a decision wait
+	for len(q.pending) > 0 && q.pending[0].deadline <= now {
+		q.decide(now)
+	}
+	q.sampler.gc(now)
+}
+
+// decide decides the oldest pending trace
+func (q *TailSamplingQueue) decide(now int64) {
+	trace := q.pending[0]
+	q.pending[0] = nil
+	q.pending = q.pending[1:]
+	delete(q.traces, trace.traceID)
+
+	keep := q.sampler.decide(trace.traceID, now)
+	if keep {
+		q.counter.KeepTraceCount++
+	} else {
+		q.counter.DropTraceCount++
+	}
+	for i, item := range trace.items {
+		q.emit(item, keep)
+		trace.items[i] = nil
+	}
+	q.spans -= len(trace.items)
+}
+
+func (q *TailSamplingQueue) emit(item TraceItem, keep bool) {
+	q.output(item, keep)
+	if keep {
+		q.counter.KeepCount++
+		q.throttler.SendWithoutThrottling(item)
+	} else {
+		q.counter.DropCount++
+		item.Release()
+	}
+}
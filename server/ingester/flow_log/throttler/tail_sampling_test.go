@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package throttler
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/config"
+)
+
+type testSpan struct {
+	traceID  string
+	service  string
+	endpoint string
+	error    bool
+	duration uint64
+	released bool
+}
+
+func (s *testSpan) GetTraceID() string          { return s.traceID }
+func (s *testSpan) GetAppService() string       { return s.service }
+func (s *testSpan) GetEndpoint() string         { return s.endpoint }
+func (s *testSpan) IsErrorResponse() bool       { return s.error }
+func (s *testSpan) GetResponseDuration() uint64 { return s.duration }
+func (s *testSpan) Release()                    { s.released = true }
+
+type testTailSampling struct {
+	queue *TailSamplingQueue
+	kept  map[string]int
+	drop  map[string]int
+}
+
+func newTestTailSampling(sampler *TailSampler) *testTailSampling {
+	t := &testTailSampling{kept: make(map[string]int), drop: make(map[string]int)}
+	t.queue = NewTailSamplingQueue(sampler, NewThrottlingQueue(0, 1, nil, 0), func(item TraceItem, kept bool) {
+		if kept {
+			t.kept[item.GetTraceID()]++
+		} else {
+			t.drop[item.GetTraceID()]++
+		}
+	}, "test", 0)
+	return t
+}
+
+func newTestTailSampler(t *testing.T, cfg *config.TailSampling) *TailSampler {
+	cfg.Enabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return NewTailSampler(cfg)
+}
+
+func TestTailSamplingSharedDecision(t *testing.T) {
+	sampler := newTestTailSampler(t, &config.TailSampling{DecisionWait: 10, KeepError: true, LatencyThreshold: 100, Services: []string{"payment"}})
+	q0, q1 := newTestTailSampling(sampler), newTestTailSampling(sampler)
+
+	// the error span received by another queue keeps the whole trace
+	q0.queue.put(&testSpan{traceID: "error"}, 100)
+	q1.queue.put(&testSpan{traceID: "error"}, 105)
+	q1.queue.put(&testSpan{traceID: "error", error: true}, 106)
+	q0.queue.put(&testSpan{traceID: "slow", duration: 200000}, 100)
+	q0.queue.put(&testSpan{traceID: "service", service: "payment"}, 100)
+	q0.queue.put(&testSpan{traceID: "normal"}, 100)
+	q1.queue.put(&testSpan{traceID: "normal"}, 101)
+
+	q0.queue.flush(109)
+	q1.queue.flush(109)
+	if len(q0.kept)+len(q0.drop)+len(q1.kept)+len(q1.drop) != 0 {
+		t.Fatalf("traces are decided before the decision wait")
+	}
+	q0.queue.flush(110)
+	q1.queue.flush(110)
+	if q0.kept["error"] != 1 || q1.kept["error"] != 2 || q0.kept["slow"] != 1 || q0.kept["service"] != 1 {
+		t.Errorf("got kept %v %v", q0.kept, q1.kept)
+	}
+	if q0.drop["normal"] != 1 || q1.drop["normal"] != 1 {
+		t.Errorf("got drop %v %v", q0.drop, q1.drop)
+	}
+	if q0.queue.spans != 0 || q1.queue.spans != 0 || len(q0.queue.traces) != 0 {
+		t.Errorf("got buffered spans %d %d", q0.queue.spans, q1.queue.spans)
+	}
+
+	// the spans arriving late follow the decision
+	late := &testSpan{traceID: "normal", error: true}
+	q1.queue.put(late, 120)
+	if q1.drop["normal"] != 2 || !late.released {
+		t.Errorf("late span of the dropped trace is not dropped")
+	}
+	q0.queue.put(&testSpan{traceID: "error"}, 120)
+	if q0.kept["error"] != 2 {
+		t.Errorf("late span of the kept trace is not kept")
+	}
+
+	// the decisions expire
+	q0.queue.flush(110 + 10*TAIL_SAMPLING_DECISION_WAITS)
+	if counter := sampler.GetCounter().(*TailSamplerCounter); counter.Traces != 0 {
+		t.Errorf("got %d traces after expiring", counter.Traces)
+	}
+}
+
+func TestTailSamplingRatio(t *testing.T) {
+	sampler := newTestTailSampler(t, &config.TailSampling{SamplingRatio: 100})
+	q := newTestTailSampling(sampler)
+	q.queue.put(&testSpan{traceID: "a"}, 100)
+	q.queue.flush(200)
+	if q.kept["a"] != 1 {
+		t.Errorf("trace is not kept with sampling ratio 100")
+	}
+
+	sampler = newTestTailSampler(t, &config.TailSampling{SamplingRatio: 0})
+	q = newTestTailSampling(sampler)
+	q.queue.put(&testSpan{traceID: "a"}, 100)
+	q.queue.flush(200)
+	if q.drop["a"] != 1 {
+		t.Errorf("trace is not dropped with sampling ratio 0")
+	}
+
+	if err := (&config.TailSampling{SamplingRatio: 101}).Validate(); err == nil {
+		t.Errorf("invalid sampling ratio is accepted")
+	}
+}
+
+func TestTailSamplingMaxBufferedSpans(t *testing.T) {
+	sampler := newTestTailSampler(t, &config.TailSampling{MaxBufferedSpans: 2})
+	q := newTestTailSampling(sampler)
+	q.queue.put(&testSpan{traceID: "a"}, 100)
+	q.queue.put(&testSpan{traceID: "a"}, 100)
+	q.queue.put(&testSpan{traceID: "b"}, 100)
+	if q.drop["a"] != 2 || q.queue.spans != 1 || q.queue.counter.EarlyDecideCount != 1 {
+		t.Errorf("got drop %v, %d spans buffered", q.drop, q.queue.spans)
+	}
+}
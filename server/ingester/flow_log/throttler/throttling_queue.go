@@ -34,6 +34,7 @@ type throttleItem interface {
 type ThrottlingQueue struct {
 	flowLogWriter *dbwriter.FlowLogWriter
 	index         int
+	stratified    *stratifiedSampler // nil if the stratified throttling is disabled
 
 	Throttle        int
 	throttleBucket  int64 // since the sender has a burst, it needs to accumulate a certain amount of time for sampling
@@ -42,6 +43,7 @@ type ThrottlingQueue struct {
 	periodEmitCount int
 
 	sampleItems    []interface{}
+	sampleStrata   []*stratum // the stratum of each sample item, only used by the stratified throttling
 	nonSampleItems []interface{}
 }
 
@@ -64,20 +66,27 @@ func (thq *ThrottlingQueue) SampleDisabled() bool {
 	return thq.Throttle <= 0
 }
 
-func (thq *ThrottlingQueue) flush() {
-	if thq.periodEmitCount > 0 {
-		if thq.flowLogWriter != nil {
-			thq.flowLogWriter.Put(thq.index, thq.sampleItems[:thq.periodEmitCount]...)
-		} else {
-			for i := range thq.sampleItems[:thq.periodEmitCount] {
-				if tItem, ok := thq.sampleItems[i].(throttleItem); ok {
-					tItem.Release()
-				}
+func (thq *ThrottlingQueue) put(items []interface{}) {
+	if thq.flowLogWriter != nil {
+		thq.flowLogWriter.Put(thq.index, items...)
+	} else {
+		for i := range items {
+			if tItem, ok := items[i].(throttleItem); ok {
+				tItem.Release()
 			}
 		}
 	}
 }
 
+func (thq *ThrottlingQueue) flush() {
+	if thq.stratified != nil {
+		thq.flushStrata()
+	}
+	if thq.periodEmitCount > 0 {
+		thq.put(thq.sampleItems[:thq.periodEmitCount])
+	}
+}
+
 func (thq *ThrottlingQueue) SendWithThrottling(flow interface{}) bool {
 	if thq.SampleDisabled() {
 		thq.SendWithoutThrottling(flow)
@@ -95,19 +104,36 @@ func (thq *ThrottlingQueue) SendWithThrottling(flow interface{}) bool {
 		return false
 	}
 
+	if thq.stratified != nil {
+		if item, ok := flow.(StratifiedItem); ok {
+			return thq.sendStratified(flow, item)
+		}
+	}
+	return thq.sample(flow, nil)
+}
+
+// sample puts the flow to the reservoir shared by all strata, s is the stratum of the flow, nil if unknown
+func (thq *ThrottlingQueue) sample(flow interface{}, s *stratum) bool {
 	// Reservoir Sampling
+	sampleSize := len(thq.sampleItems)
 	thq.periodCount++
-	if thq.periodEmitCount < thq.Throttle {
+	if thq.periodEmitCount < sampleSize {
 		thq.sampleItems[thq.periodEmitCount] = flow
+		if thq.sampleStrata != nil {
+			thq.sampleStrata[thq.periodEmitCount] = s
+		}
 		thq.periodEmitCount++
 		return true
 	} else {
 		r := rand.Intn(thq.periodCount)
-		if r < thq.Throttle {
+		if r < sampleSize {
 			if tItem, ok := thq.sampleItems[r].(throttleItem); ok {
 				tItem.Release()
 			}
 			thq.sampleItems[r] = flow
+			if thq.sampleStrata != nil {
+				thq.sampleStrata[r] = s
+			}
 		} else {
 			if tItem, ok := flow.(throttleItem); ok {
 				tItem.Release()
@@ -120,15 +146,7 @@ func (thq *ThrottlingQueue) SendWithThrottling(flow interface{}) bool {
 func (thq *ThrottlingQueue) SendWithoutThrottling(flow interface{}) {
 	if flow == nil || len(thq.nonSampleItems) >= QUEUE_BATCH {
 		if len(thq.nonSampleItems) > 0 {
-			if thq.flowLogWriter != nil {
-				thq.flowLogWriter.Put(thq.index, thq.nonSampleItems...)
-			} else {
-				for i := range thq.nonSampleItems {
-					if tItem, ok := thq.nonSampleItems[i].(throttleItem); ok {
-						tItem.Release()
-					}
-				}
-			}
+			thq.put(thq.nonSampleItems)
 			thq.nonSampleItems = thq.nonSampleItems[:0]
 		}
 	}
@@ -19,8 +19,6 @@ package unmarshaller
 import (
 	"fmt"
 	"reflect"
-	"sort"
-	"strings"
 	"time"
 	"unsafe"
 
@@ -30,7 +28,6 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/app"
 	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
 	"github.com/deepflowio/deepflow/server/libs/utils"
-	"github.com/prometheus/prometheus/prompb"
 )
 
 type ExportDocumentFlow app.DocumentFlow
@@ -163,79 +160,17 @@ func QueryUniversalTags1(e app.Document, utags *utag.UniversalTagsManager) *utag
 	)
 }
 
-func getPrometheusLabels(e app.Document, uTags0, uTags1 *utag.UniversalTags, cfg *config.ExporterCfg) []prompb.Label {
-	dataSourceId := config.DataSourceID(e.DataSource())
-	labels := make([]prompb.Label, 0, 16)
-	labels = append(labels, prompb.Label{
-		Name:  "datasource",
-		Value: dataSourceId.String(),
-	})
-	isMapItem := dataSourceId.IsMap()
-	var name, valueStr string
-	for _, structTags := range cfg.ExportFieldStructTags[dataSourceId] {
-		if structTags.CategoryBit&config.TAG == 0 {
-			continue
-		}
-
-		value := e.GetFieldValueByOffsetAndKind(structTags.Offset, structTags.DataKind, structTags.DataType)
-		if utils.IsNil(value) {
-			log.Debug("is nil ", structTags.FieldName)
-			continue
-		}
-
-		//  TimeSeries.Samples have exported `time`, no need to export anymore
-		if structTags.Name == "time" {
-			continue
-		}
-
-		if v, ok := value.(string); ok {
-			valueStr = v
-		} else {
-			valueStr = fmt.Sprintf("%v", value)
-		}
-
-		if structTags.ToStringFuncName != "" {
-			ret := structTags.ToStringFunc.Call([]reflect.Value{reflect.ValueOf(value)})
-			valueStr = ret[0].String()
-		} else if structTags.UniversalTagMapID > 0 && !cfg.UniversalTagTranslateToNameDisabled {
-			if strings.HasSuffix(structTags.Name, "_1") {
-				valueStr = uTags1.GetTagValue(structTags.UniversalTagMapID)
-			} else {
-				valueStr = uTags0.GetTagValue(structTags.UniversalTagMapID)
-			}
-		} else if structTags.EnumFile != "" && !cfg.EnumTranslateToNameDisabled {
-			valueStr = structTags.EnumStringMap[valueStr]
-		}
-
-		if !cfg.ExportEmptyTag && valueStr == "" {
-			continue
-		}
-
-		if isMapItem && structTags.MapName != "" {
-			name = structTags.MapName
-		} else {
-			name = structTags.Name
-		}
-
-		labels = append(labels, prompb.Label{
-			Name:  name,
-			Value: valueStr,
-		})
-	}
-	return labels
-}
-
 func EncodeToPrometheus(e app.Document, utags *utag.UniversalTagsManager, cfg *config.ExporterCfg) (interface{}, error) {
 	dataSourceId := e.DataSource()
 	uTags0, uTags1 := QueryUniversalTags0(e, utags), QueryUniversalTags1(e, utags)
-	timeSeries := []prompb.TimeSeries{}
+	labels := exportercommon.GetPrometheusLabels(e, int(dataSourceId), cfg, uTags0, uTags1)
 
-	labels := getPrometheusLabels(e, uTags0, uTags1, cfg)
+	names := make([]string, 0, len(cfg.ExportFieldStructTags[dataSourceId]))
+	values := make([]float64, 0, len(cfg.ExportFieldStructTags[dataSourceId]))
 	for _, structTags := range cfg.ExportFieldStructTags[dataSourceId] {
 		if structTags.CategoryBit&config.METRICS == 0 {
 			continue
 		}
-		isFloat64 := false
 		value := e.GetFieldValueByOffsetAndKind(structTags.Offset, structTags.DataKind, structTags.DataType)
 		if utils.IsNil(value) {
 			log.Debugf("is nil ", structTags.FieldName)
@@ -246,27 +181,9 @@ func EncodeToPrometheus(e app.Document, utags *utag.UniversalTagsManager, cfg *c
 		if !isFloat64 {
 			continue
 		}
-
-		if cfg.ExportEmptyMetricsDisabled && valueFloat64 == 0 {
-			continue
-		}
-
-		ts := prompb.TimeSeries{}
-		ts.Labels = make([]prompb.Label, 0, len(labels)+1)
-		ts.Labels = append(ts.Labels, prompb.Label{
-			Name:  "__name__",
-			Value: structTags.Name,
-		})
-		ts.Labels = append(ts.Labels, labels...)
-		sort.Slice(ts.Labels, func(i, j int) bool {
-			return ts.Labels[i].Name < ts.Labels[j].Name
-		})
-
-		ts.Samples = make([]prompb.Sample, 1)
-		ts.Samples[0].Value = valueFloat64
-		ts.Samples[0].Timestamp = int64(e.Time()) * 1000 // convert to  ms
-		timeSeries = append(timeSeries, ts)
+		names = append(names, structTags.Name)
+		values = append(values, valueFloat64)
 	}
 
-	return timeSeries, nil
+	return exportercommon.EncodeMetricsToPrometheus(labels, "", names, values, int64(e.Time())*1000, cfg), nil
 }
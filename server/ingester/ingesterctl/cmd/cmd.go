@@ -103,6 +103,7 @@ func RegisterIngesterCommand(root *cobra.Command) {
 		debug.CmdHelper{Cmd: "switch-to-debug-org [org-id]", Helper: "the debugging command switches to the specified organization"},
 		nil,
 	))
+	ingesterCmd.AddCommand(debug.ClientRegisterSimple(ingesterctl.CMD_CKWRITER_SPOOL, debug.CmdHelper{Cmd: "ckwriter-spool [filter]", Helper: "show the spool depth and replay progress of ckwriters"}, nil))
 	ingesterCmd.AddCommand(RegisterDecodeTraceCommand(ip, uint16(orgId)))
 
 	dropletCmd.AddCommand(queue.RegisterCommand(ingesterctl.INGESTERCTL_QUEUE, []string{
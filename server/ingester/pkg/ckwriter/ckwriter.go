@@ -19,8 +19,10 @@ package ckwriter
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,7 +30,9 @@ import (
 	server_common "github.com/deepflowio/deepflow/server/common"
 	"github.com/deepflowio/deepflow/server/ingester/common"
 	"github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/debug"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
 	"github.com/deepflowio/deepflow/server/libs/queue"
 	"github.com/deepflowio/deepflow/server/libs/stats"
@@ -60,6 +64,7 @@ func RegisterToCkwriterManager(w *CKWriter) {
 	if len(ckwriterManager.ckwriters) == 0 {
 		server_common.SetOrgHandler(ckwriterManager)
 		config.AddClickHouseEndpointsOnChange(ckwriterManager)
+		debug.ServerRegisterSimple(ingesterctl.CMD_CKWRITER_SPOOL, ckwriterManager)
 	}
 	ckwriterManager.ckwriters = append(ckwriterManager.ckwriters, w)
 	ckwriterManager.Unlock()
@@ -104,6 +109,7 @@ type CKWriter struct {
 	putCounter    int
 	ckdbwatcher   *config.Watcher
 	queueContexts []*QueueContext
+	spools        *Spools // nil if the spool is disabled
 
 	wg   sync.WaitGroup
 	exit bool
@@ -342,6 +348,12 @@ func NewCKWriter(addrs []string, user, password, counterName, timeZone string, t
 		dataQueues:  dataQueues,
 		ckdbwatcher: ckdbwatcher,
 	}
+	if spoolConfig.Enabled {
+		if w.spools, err = newSpools(filepath.Join(spoolConfig.Dir, name), &spoolConfig); err != nil {
+			return nil, err
+		}
+		common.RegisterCountableForIngester("ckwriter_spool", w.spools, stats.OptionStatTags{"table": w.name, "name": w.counterName})
+	}
 	RegisterToCkwriterManager(w)
 	return w, nil
 }
@@ -373,6 +385,11 @@ type Counter struct {
 	RetryCount        int64 `statsd:"retry-count"`
 	RetryFailedCount  int64 `statsd:"retry-failed-count"`
 	OrgInvalidCount   int64 `statsd:"org-invalid-count"`
+	SpoolCount        int64 `statsd:"spool-count"`
+	SpoolFailedCount  int64 `statsd:"spool-failed-count"`
+	SpoolDropCount    int64 `statsd:"spool-drop-count"`
+	ReplayCount       int64 `statsd:"replay-count"`
+	ReplayFailedCount int64 `statsd:"replay-failed-count"`
 	utils.Closable
 }
 
@@ -462,6 +479,7 @@ func (w *CKWriter) queueProcess(queueID int) {
 						cache.lastWriteTime = now
 					}
 				}
+				w.replaySpools(queueID)
 			} else {
 				log.Warningf("get writer queue data type wrong %T", item)
 			}
@@ -509,6 +527,7 @@ func (w *CKWriter) Write(queueID int, cache *Cache) {
 				log.Warningf("create table (%s.%s) failed, drop (%d) items: %s", w.table.OrgDatabase(cache.orgID), w.table.LocalName, itemsLen, err)
 			}
 			qc.counter.WriteFailedCount += int64(itemsLen)
+			w.spool(queueID, cache)
 			cache.Release()
 			return
 		}
@@ -540,6 +559,7 @@ func (w *CKWriter) Write(queueID int, cache *Cache) {
 		}
 		if err != nil {
 			qc.counter.WriteFailedCount += int64(itemsLen)
+			w.spool(queueID, cache)
 		} else {
 			qc.counter.WriteSuccessCount += int64(itemsLen)
 		}
@@ -618,6 +638,9 @@ func (w *CKWriter) Close() {
 		}
 		qc.counter.Close()
 	}
+	if w.spools != nil {
+		w.spools.Close()
+	}
 
 	for _, q := range w.dataQueues {
 		q.Close()
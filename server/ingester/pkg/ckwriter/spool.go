@@ -0,0 +1,587 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckwriter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/binary"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const (
+	SPOOL_FILE_SUFFIX     = ".block"
+	SPOOL_TMP_FILE_SUFFIX = ".tmp"
+	// the maximum number of segments replayed by a queue at each flush tick, so that the writing of new items is not blocked too long
+	SPOOL_REPLAY_SEGMENTS = 8
+	// the interval to retry replaying after replaying failed
+	SPOOL_REPLAY_RETRY_INTERVAL = 10 * time.Second
+)
+
+var spoolConfig config.CKWriterSpool
+
+// SetSpoolConfig should be called before the ckwriters are created, the spool is disabled by default
+func SetSpoolConfig(cfg *config.CKWriterSpool) {
+	spoolConfig = *cfg
+}
+
+// spoolBatch collects the rows written by CKItem.WriteBlock into a native block instead of sending them to ClickHouse
+type spoolBatch struct {
+	driver.Batch
+	block *proto.Block
+}
+
+func (b *spoolBatch) Append(v ...interface{}) error {
+	return b.block.Append(v...)
+}
+
+func (b *spoolBatch) Send() error {
+	return nil
+}
+
+type spoolSegment struct {
+	seq        uint64
+	rows       int64
+	size       int64
+	createTime time.Time
+}
+
+func (s *spoolSegment) fileName() string {
+	return fmt.Sprintf("%020d-%d%s", s.seq, s.rows, SPOOL_FILE_SUFFIX)
+}
+
+func parseSpoolFileName(name string) (seq uint64, rows int64, ok bool) {
+	if !strings.HasSuffix(name, SPOOL_FILE_SUFFIX) {
+		return 0, 0, false
+	}
+	fields := strings.Split(strings.TrimSuffix(name, SPOOL_FILE_SUFFIX), "-")
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	seq, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	rows, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return seq, rows, true
+}
+
+// Spool saves the failed batches of a table of an organization, each batch is saved as a segment file
+// in the native block format, and the segments are replayed in the order of saving.
+type Spool struct {
+	sync.Mutex
+	orgID    uint16
+	dir      string
+	maxSize  int64
+	maxAge   time.Duration
+	segments []spoolSegment
+	size     int64
+	rows     int64
+	nextSeq  uint64
+
+	replaying        bool
+	lastReplayFailed time.Time
+	lastReplayError  string
+	replayedRows     int64
+	replayedSegments int64
+}
+
+func newSpool(dir string, orgID uint16, maxSize int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Spool{
+		orgID:   orgID,
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load the segments saved before restarting
+func (s *Spool) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasSuffix(name, SPOOL_TMP_FILE_SUFFIX) {
+			// the spooling is interrupted
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		seq, rows, ok := parseSpoolFileName(name)
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, spoolSegment{seq: seq, rows: rows, size: info.Size(), createTime: info.ModTime()})
+		s.size += info.Size()
+		s.rows += rows
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+	if len(s.segments) > 0 {
+		s.nextSeq = s.segments[len(s.segments)-1].seq + 1
+	}
+	return nil
+}
+
+func (s *Spool) path(seg *spoolSegment) string {
+	return filepath.Join(s.dir, seg.fileName())
+}
+
+// append saves the block as a new segment, returns the rows dropped for exceeding the size or age limit
+func (s *Spool) append(block *proto.Block) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	seg := spoolSegment{seq: s.nextSeq, rows: int64(block.Rows()), createTime: time.Now()}
+	path := s.path(&seg)
+	tmpPath := path + SPOOL_TMP_FILE_SUFFIX
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	writer := bufio.NewWriter(file)
+	err = block.Encode(binary.NewEncoder(writer), 0)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if info, err := os.Stat(path); err == nil {
+		seg.size = info.Size()
+	}
+
+	s.nextSeq++
+	s.segments = append(s.segments, seg)
+	s.size += seg.size
+	s.rows += seg.rows
+	return s.expireLocked(seg.createTime), nil
+}
+
+// expireLocked drops the oldest segments until the spool is within the size and age limit
+func (s *Spool) expireLocked(now time.Time) int64 {
+	var dropRows int64
+	for len(s.segments) > 0 && (s.size > s.maxSize || now.Sub(s.segments[0].createTime) > s.maxAge) {
+		dropRows += s.segments[0].rows
+		s.removeLocked(&s.segments[0])
+	}
+	if dropRows > 0 {
+		log.Warningf("spool %s exceeds the limit, drop (%d) items", s.dir, dropRows)
+	}
+	return dropRows
+}
+
+func (s *Spool) expire() int64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.expireLocked(time.Now())
+}
+
+// removeLocked removes the segment which must be the oldest one
+func (s *Spool) removeLocked(seg *spoolSegment) {
+	if err := os.Remove(s.path(seg)); err != nil && !os.IsNotExist(err) {
+		log.Warningf("remove spool segment %s failed: %s", s.path(seg), err)
+	}
+	s.size -= seg.size
+	s.rows -= seg.rows
+	s.segments = s.segments[1:]
+}
+
+func (s *Spool) oldest() (spoolSegment, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.segments) == 0 {
+		return spoolSegment{}, false
+	}
+	return s.segments[0], true
+}
+
+func (s *Spool) read(seg *spoolSegment) (*proto.Block, error) {
+	file, err := os.Open(s.path(seg))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	block := &proto.Block{}
+	if err := block.Decode(binary.NewDecoder(bufio.NewReader(file)), 0); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// done removes the oldest segment after it is replayed or found broken
+func (s *Spool) done(seg *spoolSegment, replayed bool) {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.segments) == 0 || s.segments[0].seq != seg.seq {
+		return
+	}
+	s.removeLocked(&s.segments[0])
+	if replayed {
+		s.replayedRows += seg.rows
+		s.replayedSegments++
+	}
+}
+
+// clear drops all segments, returns the rows dropped
+func (s *Spool) clear() int64 {
+	s.Lock()
+	defer s.Unlock()
+	dropRows := s.rows
+	for len(s.segments) > 0 {
+		s.removeLocked(&s.segments[0])
+	}
+	return dropRows
+}
+
+func (s *Spool) idle() bool {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.segments) == 0 && s.replayedSegments == 0
+}
+
+func (s *Spool) String() string {
+	s.Lock()
+	defer s.Unlock()
+	oldest := ""
+	if len(s.segments) > 0 {
+		oldest = s.segments[0].createTime.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("org: %d segments: %d bytes: %d items: %d oldest: %s replayed segments: %d replayed items: %d last replay error: %s",
+		s.orgID, len(s.segments), s.size, s.rows, oldest, s.replayedSegments, s.replayedRows, s.lastReplayError)
+}
+
+type SpoolCounter struct {
+	Segments int64 `statsd:"spool-segments"`
+	Bytes    int64 `statsd:"spool-bytes"`
+	Items    int64 `statsd:"spool-items"`
+}
+
+// Spools holds the spools of all organizations of a ckwriter, it reports the depth of the spools as a gauge
+type Spools struct {
+	sync.Mutex
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	spools  []*Spool // indexed by orgID, created when used
+	utils.Closable
+}
+
+func newSpools(dir string, cfg *config.CKWriterSpool) (*Spools, error) {
+	s := &Spools{
+		dir:     dir,
+		maxSize: int64(cfg.MaxSize) << 20,
+		maxAge:  time.Duration(cfg.MaxAge) * time.Hour,
+		spools:  make([]*Spool, ckdb.MAX_ORG_ID+1),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		orgID, err := strconv.Atoi(entry.Name())
+		if !entry.IsDir() || err != nil || orgID < 0 || orgID > ckdb.MAX_ORG_ID {
+			continue
+		}
+		if _, err := s.get(uint16(orgID)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Spools) get(orgID uint16) (*Spool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.spools[orgID] == nil {
+		spool, err := newSpool(filepath.Join(s.dir, strconv.Itoa(int(orgID))), orgID, s.maxSize, s.maxAge)
+		if err != nil {
+			return nil, err
+		}
+		if len(spool.segments) > 0 {
+			log.Infof("spool %s loaded (%d) segments (%d) items", spool.dir, len(spool.segments), spool.rows)
+		}
+		s.spools[orgID] = spool
+	}
+	return s.spools[orgID], nil
+}
+
+// list returns the spools created
+func (s *Spools) list() []*Spool {
+	s.Lock()
+	defer s.Unlock()
+	spools := make([]*Spool, 0, 1)
+	for _, spool := range s.spools {
+		if spool != nil {
+			spools = append(spools, spool)
+		}
+	}
+	return spools
+}
+
+func (s *Spools) GetCounter() interface{} {
+	counter := &SpoolCounter{}
+	for _, spool := range s.list() {
+		spool.Lock()
+		counter.Segments += int64(len(spool.segments))
+		counter.Bytes += spool.size
+		counter.Items += spool.rows
+		spool.Unlock()
+	}
+	return counter
+}
+
+func spoolColumnType(c *ckdb.Column, timeZone string) string {
+	columnType := c.Type.String()
+	if c.TypeArgs != "" {
+		columnType = fmt.Sprintf(columnType, c.TypeArgs)
+	}
+	if timeZone != "" {
+		columnType = strings.ReplaceAll(columnType, ckdb.DF_TIMEZONE, timeZone)
+	}
+	return columnType
+}
+
+func (w *CKWriter) newSpoolBlock() (*proto.Block, error) {
+	block := &proto.Block{}
+	for _, c := range w.table.Columns {
+		if err := block.AddColumn(c.Name, column.Type(spoolColumnType(c, w.timeZone))); err != nil {
+			return nil, err
+		}
+	}
+	return block, nil
+}
+
+// spool saves the items of the cache to the spool of the organization, returns false if the spool is disabled or failed
+func (w *CKWriter) spool(queueID int, cache *Cache) bool {
+	if w.spools == nil || len(cache.items) == 0 {
+		return false
+	}
+	qc := w.queueContexts[queueID]
+	itemsLen := int64(len(cache.items))
+	err := func() error {
+		spool, err := w.spools.get(cache.orgID)
+		if err != nil {
+			return err
+		}
+		block, err := w.newSpoolBlock()
+		if err != nil {
+			return err
+		}
+		ckdbBlock := ckdb.NewBlock(&spoolBatch{block: block})
+		for _, item := range cache.items {
+			item.WriteBlock(ckdbBlock)
+			if err := ckdbBlock.WriteAll(); err != nil {
+				return err
+			}
+		}
+		dropRows, err := spool.append(block)
+		qc.counter.SpoolDropCount += dropRows
+		return err
+	}()
+	if err != nil {
+		if qc.counter.SpoolFailedCount == 0 {
+			log.Warningf("spool table (%s.%s) failed, drop (%d) items: %s", w.table.OrgDatabase(cache.orgID), w.table.LocalName, itemsLen, err)
+		}
+		qc.counter.SpoolFailedCount += itemsLen
+		return false
+	}
+	qc.counter.SpoolCount += itemsLen
+	return true
+}
+
+// replaySpools replays the spooled segments of all organizations in order, it is called at each flush tick of the queue
+func (w *CKWriter) replaySpools(queueID int) {
+	if w.spools == nil {
+		return
+	}
+	for _, spool := range w.spools.list() {
+		w.replaySpool(queueID, spool)
+	}
+}
+
+func (w *CKWriter) replaySpool(queueID int, spool *Spool) {
+	qc := w.queueContexts[queueID]
+	qc.counter.SpoolDropCount += spool.expire()
+
+	// only one queue replays the spool at the same time to keep the order
+	spool.Lock()
+	if spool.replaying || len(spool.segments) == 0 || time.Since(spool.lastReplayFailed) < SPOOL_REPLAY_RETRY_INTERVAL {
+		spool.Unlock()
+		return
+	}
+	spool.replaying = true
+	spool.Unlock()
+
+	replayErr := w.replaySegments(queueID, spool)
+
+	spool.Lock()
+	spool.replaying = false
+	if replayErr != nil {
+		spool.lastReplayFailed = time.Now()
+		spool.lastReplayError = replayErr.Error()
+	} else {
+		spool.lastReplayError = ""
+	}
+	spool.Unlock()
+}
+
+func (w *CKWriter) replaySegments(queueID int, spool *Spool) error {
+	qc := w.queueContexts[queueID]
+	qc.EndpointsChange(w.addrs)
+	cache := qc.orgCaches[spool.orgID]
+	if !cache.OrgIdExists() {
+		dropRows := spool.clear()
+		log.Warningf("table (%s.%s) orgId is not exist, drop (%d) spooled items", w.table.OrgDatabase(spool.orgID), w.table.LocalName, dropRows)
+		qc.counter.SpoolDropCount += dropRows
+		return nil
+	}
+	if !cache.tableCreated {
+		if err := w.InitTable(queueID, spool.orgID); err != nil {
+			return err
+		}
+		cache.tableCreated = true
+	}
+
+	for i := 0; i < SPOOL_REPLAY_SEGMENTS; i++ {
+		seg, ok := spool.oldest()
+		if !ok {
+			return nil
+		}
+		block, err := spool.read(&seg)
+		if err != nil {
+			log.Warningf("read spool segment %s failed, drop (%d) items: %s", spool.path(&seg), seg.rows, err)
+			spool.done(&seg, false)
+			qc.counter.SpoolDropCount += seg.rows
+			continue
+		}
+		connID := int(atomic.AddUint64(&qc.writeCounter, 1)) % qc.connCount
+		if err := w.writeSpoolBlock(queueID, connID, cache.prepare, block); err != nil {
+			if _, ok := err.(*spoolAppendError); ok {
+				// the rows can never be written, e.g. the table schema is changed
+				log.Warningf("replay spool segment %s failed, drop (%d) items: %s", spool.path(&seg), seg.rows, err)
+				spool.done(&seg, false)
+				qc.counter.SpoolDropCount += seg.rows
+				continue
+			}
+			qc.counter.ReplayFailedCount += seg.rows
+			return err
+		}
+		spool.done(&seg, true)
+		qc.counter.ReplayCount += seg.rows
+		log.Debugf("replay spool segment %s success, write (%d) items", spool.path(&seg), seg.rows)
+	}
+	return nil
+}
+
+type spoolAppendError struct {
+	err error
+}
+
+func (e *spoolAppendError) Error() string {
+	return fmt.Sprintf("append spooled row failed: %s", e.err)
+}
+
+func (w *CKWriter) writeSpoolBlock(queueID, connID int, prepare string, block *proto.Block) error {
+	qc := w.queueContexts[queueID]
+	ck := qc.conns[connID]
+	if IsNil(ck) {
+		if err := w.ResetConnection(queueID, connID); err != nil {
+			return fmt.Errorf("can not connect to clickhouse: %s", err)
+		}
+		ck = qc.conns[connID]
+	}
+	batch, err := ck.PrepareBatch(context.Background(), prepare)
+	if err != nil {
+		return fmt.Errorf("prepare batch failed: %s", err)
+	}
+	if len(block.Columns) != len(w.table.Columns) {
+		batch.Abort()
+		return &spoolAppendError{fmt.Errorf("got %d columns, expected %d", len(block.Columns), len(w.table.Columns))}
+	}
+	row := make([]interface{}, len(block.Columns))
+	for i := 0; i < block.Rows(); i++ {
+		for j, c := range block.Columns {
+			row[j] = c.Row(i, false)
+		}
+		if err := batch.Append(row...); err != nil {
+			batch.Abort()
+			return &spoolAppendError{err}
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("send batch failed: %s", err)
+	}
+	return nil
+}
+
+func (w *CKWriter) spoolStatus() string {
+	if w.spools == nil {
+		return ""
+	}
+	sb := &strings.Builder{}
+	for _, spool := range w.spools.list() {
+		if spool.idle() {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %s\n", spool))
+	}
+	return sb.String()
+}
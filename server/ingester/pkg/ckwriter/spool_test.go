@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckwriter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+)
+
+type testSpoolItem struct {
+	id    uint32
+	name  string
+	value float64
+}
+
+func (i *testSpoolItem) WriteBlock(block *ckdb.Block) {
+	block.Write(i.id, i.name, i.value)
+}
+
+func (i *testSpoolItem) OrgID() uint16 { return 1 }
+
+func (i *testSpoolItem) Release() {}
+
+func newTestSpoolWriter(t *testing.T, maxSize int) *CKWriter {
+	table := &ckdb.Table{
+		Database:  "test",
+		LocalName: "spool_local",
+		Columns: []*ckdb.Column{
+			ckdb.NewColumn("id", ckdb.UInt32),
+			ckdb.NewColumn("name", ckdb.String),
+			ckdb.NewColumn("value", ckdb.Float64),
+		},
+	}
+	spools, err := newSpools(t.TempDir(), &config.CKWriterSpool{Enabled: true, MaxSize: maxSize, MaxAge: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CKWriter{
+		table:         table,
+		queueContexts: []*QueueContext{{}},
+		spools:        spools,
+	}
+}
+
+func TestSpoolRoundTrip(t *testing.T) {
+	w := newTestSpoolWriter(t, 1)
+	for i := 0; i < 3; i++ {
+		cache := &Cache{orgID: 1, items: []CKItem{
+			&testSpoolItem{uint32(i), "a", 1.5},
+			&testSpoolItem{uint32(i), "b", 2.5},
+		}}
+		if !w.spool(0, cache) {
+			t.Fatalf("spool batch %d failed", i)
+		}
+	}
+	if counter := w.queueContexts[0].counter; counter.SpoolCount != 6 || counter.SpoolFailedCount != 0 {
+		t.Errorf("got counter %+v", counter)
+	}
+
+	// the spooled segments are loaded in order after restarting
+	spools, err := newSpools(w.spools.dir, &config.CKWriterSpool{MaxSize: 1, MaxAge: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool, _ := spools.get(1)
+	if spool.nextSeq != 3 || spool.rows != 6 {
+		t.Fatalf("got next seq %d rows %d", spool.nextSeq, spool.rows)
+	}
+	for i := 0; i < 3; i++ {
+		seg, ok := spool.oldest()
+		if !ok || seg.seq != uint64(i) || seg.rows != 2 {
+			t.Fatalf("got segment %+v %v, expected seq %d", seg, ok, i)
+		}
+		block, err := spool.read(&seg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block.Rows() != 2 || len(block.Columns) != 3 {
+			t.Fatalf("got %d rows %d columns", block.Rows(), len(block.Columns))
+		}
+		if id := block.Columns[0].Row(1, false); id != uint32(i) {
+			t.Errorf("got id %v, expected %d", id, i)
+		}
+		if name := block.Columns[1].Row(1, false); name != "b" {
+			t.Errorf("got name %v", name)
+		}
+		if value := block.Columns[2].Row(1, false); value != 2.5 {
+			t.Errorf("got value %v", value)
+		}
+		spool.done(&seg, true)
+	}
+	if spool.rows != 0 || spool.size != 0 || spool.replayedRows != 6 || spool.replayedSegments != 3 {
+		t.Errorf("got spool %s", spool)
+	}
+	if entries, _ := os.ReadDir(spool.dir); len(entries) != 0 {
+		t.Errorf("got %d files after replaying", len(entries))
+	}
+}
+
+func TestSpoolLimit(t *testing.T) {
+	w := newTestSpoolWriter(t, 1)
+	spool, _ := w.spools.get(1)
+	spool.maxSize = 0
+	w.spool(0, &Cache{orgID: 1, items: []CKItem{&testSpoolItem{1, "a", 1}}})
+	if counter := w.queueContexts[0].counter; counter.SpoolCount != 1 || counter.SpoolDropCount != 1 {
+		t.Errorf("got counter %+v after exceeding max size", counter)
+	}
+
+	spool.maxSize = 1 << 20
+	w.spool(0, &Cache{orgID: 1, items: []CKItem{&testSpoolItem{1, "a", 1}}})
+	spool.segments[0].createTime = time.Now().Add(-2 * time.Hour)
+	if drop := spool.expire(); drop != 1 || len(spool.segments) != 0 {
+		t.Errorf("got %d dropped, %d segments after exceeding max age", drop, len(spool.segments))
+	}
+
+	// the interrupted segments are removed when loading
+	tmpFile := filepath.Join(spool.dir, "00000000000000000009-1"+SPOOL_FILE_SUFFIX+SPOOL_TMP_FILE_SUFFIX)
+	os.WriteFile(tmpFile, []byte{1}, 0644)
+	if _, err := newSpool(spool.dir, 1, 1<<20, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpFile); !os.IsNotExist(err) {
+		t.Errorf("tmp file is not removed: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInProcessProfileClone(t *testing.T) {
+	p := AcquireInProcess()
+	p.AppService = "svc"
+	p.TagNames = []string{"a", "b"}
+	p.TagValues = []string{"1", "2"}
+	p.AddReferenceCount()
+
+	c := p.Clone()
+	if c.AppService != "svc" {
+		t.Errorf("got app service %s, expected svc", c.AppService)
+	}
+	if !reflect.DeepEqual(c.TagNames, []string{"a", "b"}) || !reflect.DeepEqual(c.TagValues, []string{"1", "2"}) {
+		t.Errorf("got tags %v=%v", c.TagNames, c.TagValues)
+	}
+	// the clone has its own reference count and tag slices
+	if c.GetReferenceCount() != 1 {
+		t.Errorf("got reference count %d of the clone, expected 1", c.GetReferenceCount())
+	}
+	p.TagNames[0], p.TagValues[0] = "x", "9"
+	if c.TagNames[0] != "a" || c.TagValues[0] != "1" {
+		t.Errorf("the tags of the clone are modified with the origin: %v=%v", c.TagNames, c.TagValues)
+	}
+
+	p.Release()
+	p.Release()
+	if c.AppService != "svc" || len(c.TagNames) != 2 {
+		t.Errorf("the clone is modified after the origin is released: %+v", c)
+	}
+	c.Release()
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unsafe"
+
+	exportercommon "github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
+	"github.com/deepflowio/deepflow/server/libs/pool"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+	"github.com/prometheus/common/model"
+)
+
+// PrometheusExportSample is only used by exporters. The samples stored in the database only have the label IDs,
+// so the metric name and label names/values are kept here.
+type PrometheusExportSample struct {
+	pool.ReferenceCount
+
+	Time        uint32   `json:"time" category:"$tag" sub:"flow_info"` // s
+	MetricName  string   `json:"metric_name" category:"$tag" sub:"flow_info"`
+	LabelNames  []string `json:"label_names" category:"$tag" sub:"native_tag" data_type:"[]string"`
+	LabelValues []string `json:"label_values" category:"$tag" sub:"native_tag" data_type:"[]string"`
+
+	UniversalTag flow_metrics.UniversalTag
+
+	OrgId  uint16 `json:"org_id" category:"$tag"`
+	TeamID uint16 `json:"team_id" category:"$tag"`
+
+	Value float64 `json:"value" category:"$metrics" sub:"metrics_value"`
+}
+
+// FillLabels copies the labels of the TimeSeries and extra labels except the metric name, the labels are from
+// temporary memory, so they need to be cloned.
+func (s *PrometheusExportSample) FillLabels(ts *prompb.TimeSeries, extraLabels []prompb.Label) {
+	var l *prompb.Label
+	tsLen, extraLen := len(ts.Labels), len(extraLabels)
+	for i := 0; i < tsLen+extraLen; i++ {
+		if i < tsLen {
+			l = &ts.Labels[i]
+		} else {
+			l = &extraLabels[i-tsLen]
+		}
+		if l.Name == model.MetricNameLabel {
+			if s.MetricName == "" {
+				s.MetricName = strings.Clone(l.Value)
+			}
+			continue
+		}
+		s.LabelNames = append(s.LabelNames, strings.Clone(l.Name))
+		s.LabelValues = append(s.LabelValues, strings.Clone(l.Value))
+	}
+}
+
+func (s *PrometheusExportSample) DataSource() uint32 {
+	return uint32(exporterconfig.PROMETHEUS)
+}
+
+func (s *PrometheusExportSample) EncodeTo(protocol exporterconfig.ExportProtocol, utags *utag.UniversalTagsManager, cfg *exporterconfig.ExporterCfg) (interface{}, error) {
+	switch protocol {
+	case exporterconfig.PROTOCOL_KAFKA:
+		tags := s.QueryUniversalTags(utags)
+		k8sLabels := utags.QueryCustomK8sLabels(s.OrgId, s.UniversalTag.PodID)
+		return exportercommon.EncodeToJson(s, int(s.DataSource()), cfg, tags, tags, k8sLabels, k8sLabels), nil
+	case exporterconfig.PROTOCOL_PROMETHEUS:
+		tags := s.QueryUniversalTags(utags)
+		labels := exportercommon.GetPrometheusLabels(s, int(s.DataSource()), cfg, tags, tags)
+		labels = exportercommon.AppendPrometheusLabels(labels, s.LabelNames, s.LabelValues, cfg)
+		return exportercommon.EncodeMetricsToPrometheus(labels, "", []string{s.MetricName}, []float64{s.Value}, int64(s.Time)*1000, cfg), nil
+	case exporterconfig.PROTOCOL_OTLP:
+		return exportercommon.EncodeMetricsToOtlp(s, int(s.DataSource()), cfg, s.QueryUniversalTags(utags), s.LabelNames, s.LabelValues, "", []string{s.MetricName}, []float64{s.Value}), nil
+	default:
+		return nil, fmt.Errorf("prometheus sample unsupport export to %s", protocol)
+	}
+}
+
+func (s *PrometheusExportSample) QueryUniversalTags(utags *utag.UniversalTagsManager) *utag.UniversalTags {
+	t := &s.UniversalTag
+	return utags.QueryUniversalTags(s.OrgId,
+		t.RegionID, t.AZID, t.HostID, t.PodNSID, t.PodClusterID, t.SubnetID, t.VTAPID,
+		uint8(t.L3DeviceType), t.AutoServiceType, t.AutoInstanceType,
+		t.L3DeviceID, t.AutoServiceID, t.AutoInstanceID, t.PodNodeID, t.PodGroupID, t.PodID, uint32(t.L3EpcID), t.GPID, t.ServiceID,
+		t.IsIPv6 == 0, t.IP, t.IP6,
+	)
+}
+
+func (s *PrometheusExportSample) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(s)), offset, kind, dataType)
+}
+
+func (s *PrometheusExportSample) TimestampUs() int64 {
+	return int64(time.Duration(s.Time) * time.Second / time.Microsecond)
+}
+
+func (s *PrometheusExportSample) Release() {
+	ReleasePrometheusExportSample(s)
+}
+
+var prometheusExportSamplePool = pool.NewLockFreePool(func() interface{} {
+	return &PrometheusExportSample{}
+})
+
+func AcquirePrometheusExportSample() *PrometheusExportSample {
+	s := prometheusExportSamplePool.Get().(*PrometheusExportSample)
+	s.Reset()
+	return s
+}
+
+func ReleasePrometheusExportSample(s *PrometheusExportSample) {
+	if s == nil || s.SubReferenceCount() {
+		return
+	}
+	labelNames, labelValues := s.LabelNames[:0], s.LabelValues[:0]
+	*s = PrometheusExportSample{}
+	s.LabelNames, s.LabelValues = labelNames, labelValues
+	prometheusExportSamplePool.Put(s)
+}
@@ -0,0 +1,328 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dbwriter
+
+import (
+	"github.com/deepflowio/deepflow/server/ingester/common"
+	baseconfig "github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/ckwriter"
+	"github.com/deepflowio/deepflow/server/ingester/prometheus/config"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/pool"
+)
+
+const (
+	PROMETHEUS_NATIVE_HISTOGRAM_TABLE = "native_histograms"
+	PROMETHEUS_EXEMPLAR_TABLE         = "exemplars"
+	PROMETHEUS_METADATA_TABLE         = "metadata"
+)
+
+// PrometheusNativeHistogram stores a native histogram sample. The labels are stored as strings instead of
+// app_label_value_id columns, the bucket counts are decoded from the spans and deltas to absolute counts.
+type PrometheusNativeHistogram struct {
+	Time            uint32 // s
+	MetricName      string
+	LabelNames      []string
+	LabelValues     []string
+	Schema          int32
+	ZeroThreshold   float64
+	ZeroCount       float64
+	Count           float64
+	Sum             float64
+	PositiveIndexes []int64
+	PositiveCounts  []float64
+	NegativeIndexes []int64
+	NegativeCounts  []float64
+	ResetHint       uint8
+
+	VtapId uint16
+	OrgId  uint16
+	TeamID uint16
+}
+
+func PrometheusNativeHistogramColumns() []*ckdb.Column {
+	return []*ckdb.Column{
+		ckdb.NewColumn("time", ckdb.DateTime),
+		ckdb.NewColumn("metric_name", ckdb.LowCardinalityString),
+		ckdb.NewColumn("label_names", ckdb.ArrayLowCardinalityString),
+		ckdb.NewColumn("label_values", ckdb.ArrayString),
+		ckdb.NewColumn("schema", ckdb.Int32).SetComment("the resolution of the buckets, the bucket boundaries are powers of 2^(2^-schema)"),
+		ckdb.NewColumn("zero_threshold", ckdb.Float64),
+		ckdb.NewColumn("zero_count", ckdb.Float64),
+		ckdb.NewColumn("count", ckdb.Float64),
+		ckdb.NewColumn("sum", ckdb.Float64),
+		ckdb.NewColumn("positive_bucket_indexes", ckdb.ArrayInt64),
+		ckdb.NewColumn("positive_bucket_counts", ckdb.ArrayFloat64),
+		ckdb.NewColumn("negative_bucket_indexes", ckdb.ArrayInt64),
+		ckdb.NewColumn("negative_bucket_counts", ckdb.ArrayFloat64),
+		ckdb.NewColumn("reset_hint", ckdb.UInt8),
+		ckdb.NewColumn("agent_id", ckdb.UInt16),
+		ckdb.NewColumn("team_id", ckdb.UInt16),
+	}
+}
+
+// Note: The order of Write() must be consistent with the order of append() in Columns.
+func (h *PrometheusNativeHistogram) WriteBlock(block *ckdb.Block) {
+	block.WriteDateTime(h.Time)
+	block.Write(
+		h.MetricName,
+		h.LabelNames,
+		h.LabelValues,
+		h.Schema,
+		h.ZeroThreshold,
+		h.ZeroCount,
+		h.Count,
+		h.Sum,
+		h.PositiveIndexes,
+		h.PositiveCounts,
+		h.NegativeIndexes,
+		h.NegativeCounts,
+		h.ResetHint,
+		h.VtapId,
+		h.TeamID,
+	)
+}
+
+func (h *PrometheusNativeHistogram) OrgID() uint16 {
+	return h.OrgId
+}
+
+func (h *PrometheusNativeHistogram) Release() {
+	ReleasePrometheusNativeHistogram(h)
+}
+
+var prometheusNativeHistogramPool = pool.NewLockFreePool(func() interface{} {
+	return &PrometheusNativeHistogram{}
+})
+
+func AcquirePrometheusNativeHistogram() *PrometheusNativeHistogram {
+	return prometheusNativeHistogramPool.Get().(*PrometheusNativeHistogram)
+}
+
+func ReleasePrometheusNativeHistogram(h *PrometheusNativeHistogram) {
+	// the labels may be shared by the histograms of the same time series, so they are not reused
+	*h = PrometheusNativeHistogram{
+		PositiveIndexes: h.PositiveIndexes[:0],
+		PositiveCounts:  h.PositiveCounts[:0],
+		NegativeIndexes: h.NegativeIndexes[:0],
+		NegativeCounts:  h.NegativeCounts[:0],
+	}
+	prometheusNativeHistogramPool.Put(h)
+}
+
+// PrometheusExemplar stores an exemplar with the labels of its time series, the trace_id/span_id labels
+// of the exemplar are extracted so that the exemplar can be linked to the traces in flow_log.l7_flow_log.
+type PrometheusExemplar struct {
+	Time                uint32 // s
+	Timestamp           int64  // ms
+	MetricName          string
+	LabelNames          []string
+	LabelValues         []string
+	ExemplarLabelNames  []string
+	ExemplarLabelValues []string
+	Value               float64
+	TraceID             string
+	SpanID              string
+
+	VtapId uint16
+	OrgId  uint16
+	TeamID uint16
+}
+
+func PrometheusExemplarColumns() []*ckdb.Column {
+	return []*ckdb.Column{
+		ckdb.NewColumn("time", ckdb.DateTime),
+		ckdb.NewColumn("timestamp", ckdb.DateTime64ms),
+		ckdb.NewColumn("metric_name", ckdb.LowCardinalityString),
+		ckdb.NewColumn("label_names", ckdb.ArrayLowCardinalityString),
+		ckdb.NewColumn("label_values", ckdb.ArrayString),
+		ckdb.NewColumn("exemplar_label_names", ckdb.ArrayLowCardinalityString),
+		ckdb.NewColumn("exemplar_label_values", ckdb.ArrayString),
+		ckdb.NewColumn("value", ckdb.Float64),
+		ckdb.NewColumn("trace_id", ckdb.String).SetIndex(ckdb.IndexBloomfilter),
+		ckdb.NewColumn("span_id", ckdb.String),
+		ckdb.NewColumn("agent_id", ckdb.UInt16),
+		ckdb.NewColumn("team_id", ckdb.UInt16),
+	}
+}
+
+// Note: The order of Write() must be consistent with the order of append() in Columns.
+func (e *PrometheusExemplar) WriteBlock(block *ckdb.Block) {
+	block.WriteDateTime(e.Time)
+	block.Write(
+		e.Timestamp,
+		e.MetricName,
+		e.LabelNames,
+		e.LabelValues,
+		e.ExemplarLabelNames,
+		e.ExemplarLabelValues,
+		e.Value,
+		e.TraceID,
+		e.SpanID,
+		e.VtapId,
+		e.TeamID,
+	)
+}
+
+func (e *PrometheusExemplar) OrgID() uint16 {
+	return e.OrgId
+}
+
+func (e *PrometheusExemplar) Release() {
+	ReleasePrometheusExemplar(e)
+}
+
+var prometheusExemplarPool = pool.NewLockFreePool(func() interface{} {
+	return &PrometheusExemplar{}
+})
+
+func AcquirePrometheusExemplar() *PrometheusExemplar {
+	return prometheusExemplarPool.Get().(*PrometheusExemplar)
+}
+
+func ReleasePrometheusExemplar(e *PrometheusExemplar) {
+	// the labels may be shared by the exemplars of the same time series, so they are not reused
+	*e = PrometheusExemplar{
+		ExemplarLabelNames:  e.ExemplarLabelNames[:0],
+		ExemplarLabelValues: e.ExemplarLabelValues[:0],
+	}
+	prometheusExemplarPool.Put(e)
+}
+
+// PrometheusMetadata stores the type, help and unit of a metric family, the latest one is kept by ReplacingMergeTree(time).
+type PrometheusMetadata struct {
+	Time             uint32 // s
+	MetricFamilyName string
+	Type             string
+	Help             string
+	Unit             string
+
+	OrgId  uint16
+	TeamID uint16
+}
+
+func PrometheusMetadataColumns() []*ckdb.Column {
+	return []*ckdb.Column{
+		ckdb.NewColumn("time", ckdb.DateTime),
+		ckdb.NewColumn("metric_family_name", ckdb.String),
+		ckdb.NewColumn("type", ckdb.LowCardinalityString),
+		ckdb.NewColumn("help", ckdb.String),
+		ckdb.NewColumn("unit", ckdb.LowCardinalityString),
+		ckdb.NewColumn("team_id", ckdb.UInt16),
+	}
+}
+
+// Note: The order of Write() must be consistent with the order of append() in Columns.
+func (m *PrometheusMetadata) WriteBlock(block *ckdb.Block) {
+	block.WriteDateTime(m.Time)
+	block.Write(
+		m.MetricFamilyName,
+		m.Type,
+		m.Help,
+		m.Unit,
+		m.TeamID,
+	)
+}
+
+func (m *PrometheusMetadata) OrgID() uint16 {
+	return m.OrgId
+}
+
+func (m *PrometheusMetadata) Release() {}
+
+func genPrometheusExtCKTable(cluster, storagePolicy, ckdbType string, ttl int, coldStorage *ckdb.ColdStorage, tableName string, columns []*ckdb.Column, engine ckdb.EngineType, orderKeys []string) *ckdb.Table {
+	return &ckdb.Table{
+		Version:         common.CK_VERSION,
+		Database:        PROMETHEUS_DB,
+		DBType:          ckdbType,
+		LocalName:       tableName + ckdb.LOCAL_SUBFFIX,
+		GlobalName:      tableName,
+		Columns:         columns,
+		TimeKey:         "time",
+		TTL:             ttl,
+		PartitionFunc:   DefaultPartition,
+		Engine:          engine,
+		Cluster:         cluster,
+		StoragePolicy:   storagePolicy,
+		ColdStorage:     *coldStorage,
+		OrderKeys:       orderKeys,
+		PrimaryKeyCount: len(orderKeys),
+	}
+}
+
+// PrometheusExtWriter writes the native histograms, exemplars and metadata of remote write requests, it is shared by all decoders
+type PrometheusExtWriter struct {
+	histogramWriter *ckwriter.CKWriter
+	exemplarWriter  *ckwriter.CKWriter
+	metadataWriter  *ckwriter.CKWriter
+}
+
+func (w *PrometheusExtWriter) WriteNativeHistograms(histograms []interface{}) {
+	if len(histograms) > 0 {
+		w.histogramWriter.Put(histograms...)
+	}
+}
+
+func (w *PrometheusExtWriter) WriteExemplars(exemplars []interface{}) {
+	if len(exemplars) > 0 {
+		w.exemplarWriter.Put(exemplars...)
+	}
+}
+
+func (w *PrometheusExtWriter) WriteMetadata(metadata []interface{}) {
+	if len(metadata) > 0 {
+		w.metadataWriter.Put(metadata...)
+	}
+}
+
+func NewPrometheusExtWriter(config *config.Config) (*PrometheusExtWriter, error) {
+	base := config.Base
+	coldStorages := base.GetCKDBColdStorages()
+	newWriter := func(tableName string, columns []*ckdb.Column, engine ckdb.EngineType, orderKeys []string, writerConfig *baseconfig.CKWriterConfig) (*ckwriter.CKWriter, error) {
+		table := genPrometheusExtCKTable(base.CKDB.ClusterName, base.CKDB.StoragePolicy, base.CKDB.Type, config.TTL,
+			ckdb.GetColdStorage(coldStorages, PROMETHEUS_DB, tableName), tableName, columns, engine, orderKeys)
+		w, err := ckwriter.NewCKWriter(*base.CKDB.ActualAddrs, base.CKDBAuth.Username, base.CKDBAuth.Password,
+			"prometheus-"+tableName, base.CKDB.TimeZone, table,
+			writerConfig.QueueCount, writerConfig.QueueSize, writerConfig.BatchSize, writerConfig.FlushTimeout, base.CKDB.Watcher)
+		if err != nil {
+			return nil, err
+		}
+		w.Run()
+		return w, nil
+	}
+
+	histogramWriter, err := newWriter(PROMETHEUS_NATIVE_HISTOGRAM_TABLE, PrometheusNativeHistogramColumns(), ckdb.MergeTree, []string{"metric_name", "time"}, &config.CKWriterConfig)
+	if err != nil {
+		return nil, err
+	}
+	exemplarWriter, err := newWriter(PROMETHEUS_EXEMPLAR_TABLE, PrometheusExemplarColumns(), ckdb.MergeTree, []string{"metric_name", "time"}, &config.CKWriterConfig)
+	if err != nil {
+		return nil, err
+	}
+	// metadata is rarely changed, one queue is enough
+	metadataWriterConfig := config.CKWriterConfig
+	metadataWriterConfig.QueueCount = 1
+	metadataWriter, err := newWriter(PROMETHEUS_METADATA_TABLE, PrometheusMetadataColumns(), ckdb.ReplacingMergeTree, []string{"metric_family_name"}, &metadataWriterConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &PrometheusExtWriter{
+		histogramWriter: histogramWriter,
+		exemplarWriter:  exemplarWriter,
+		metadataWriter:  metadataWriter,
+	}, nil
+}
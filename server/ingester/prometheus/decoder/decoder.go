@@ -28,6 +28,8 @@ import (
 	"github.com/prometheus/common/model"
 
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/config"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
 	"github.com/deepflowio/deepflow/server/libs/codec"
@@ -59,6 +61,9 @@ type Counter struct {
 	TimeSeriesErr  int64 `statsd:"time-series-err"`
 	TimeSeriesSlow int64 `statsd:"time-series-slow"`
 	TimeSeriesOut  int64 `statsd:"time-series-out"` // count the number of TimeSeries (not Samples)
+	HistogramIn    int64 `statsd:"native-histogram-in"`
+	ExemplarIn     int64 `statsd:"exemplar-in"`
+	MetadataOut    int64 `statsd:"metadata-out"`
 }
 
 type BuilderCounter struct {
@@ -93,6 +98,9 @@ type PrometheusSamplesBuilder struct {
 	labelColumnIndexsBuffer []uint32
 	appLabelValueIDsBuffer  []uint32
 
+	// label IDs of the symbols of the current Remote-Write 2.0 request
+	symbolIDs symbolIDTable
+
 	// universal tag cache
 	podNameIDToUniversalTag  [grpc.MAX_ORG_COUNT]map[uint32]flow_metrics.UniversalTag
 	instanceIPToUniversalTag [grpc.MAX_ORG_COUNT]map[uint32]flow_metrics.UniversalTag
@@ -135,6 +143,8 @@ type Decoder struct {
 	inQueue          queue.QueueReader
 	slowDecodeQueue  queue.QueueWriter
 	prometheusWriter *dbwriter.PrometheusWriter
+	extWriter        *dbwriter.PrometheusExtWriter
+	exporters        *exporters.Exporters
 	debugEnabled     bool
 	config           *config.Config
 
@@ -142,6 +152,19 @@ type Decoder struct {
 
 	samplesBuilder *PrometheusSamplesBuilder
 
+	histograms     []*dbwriter.PrometheusNativeHistogram
+	exemplars      []*dbwriter.PrometheusExemplar
+	itemBuffer     []interface{}
+	classicSeries  []prompb.TimeSeries
+	classicBuckets []classicBucket
+	metadataBuffer []interface{}
+	metadataCache  map[metadataCacheKey]metadataCacheItem
+
+	// buffers to convert the time series of Remote-Write 2.0
+	timeSeriesV1     prompb.TimeSeries
+	exemplarLabelsV1 []prompb.Label
+	metadataV1       []prompb.MetricMetadata
+
 	counter *Counter
 	utils.Closable
 }
@@ -153,6 +176,8 @@ func NewDecoder(
 	inQueue queue.QueueReader,
 	slowDecodeQueue queue.QueueWriter,
 	prometheusWriter *dbwriter.PrometheusWriter,
+	extWriter *dbwriter.PrometheusExtWriter,
+	exporters *exporters.Exporters,
 	config *config.Config,
 ) *Decoder {
 	return &Decoder{
@@ -162,7 +187,10 @@ func NewDecoder(
 		slowDecodeQueue:  slowDecodeQueue,
 		debugEnabled:     log.IsEnabledFor(logging.DEBUG),
 		prometheusWriter: prometheusWriter,
+		extWriter:        extWriter,
+		exporters:        exporters,
 		config:           config,
+		metadataCache:    make(map[metadataCacheKey]metadataCacheItem),
 		counter:          &Counter{},
 	}
 }
@@ -179,6 +207,7 @@ func (d *Decoder) Run() {
 		"msg_type": datatype.MESSAGE_TYPE_PROMETHEUS.String()})
 	buffer := make([]interface{}, BUFFER_SIZE)
 	promWriteRequest := &prompb.WriteRequest{}
+	promWriteRequestV2 := &prompb.WriteRequestV2{}
 	decodeBuffer := []byte{}
 	decoder := &codec.SimpleDecoder{}
 	prometheusMetric := &pb.PrometheusMetric{}
@@ -187,6 +216,9 @@ func (d *Decoder) Run() {
 		n := d.inQueue.Gets(buffer)
 		for i := 0; i < n; i++ {
 			if buffer[i] == nil {
+				if d.exporters.IsExportDataSource(uint32(exporterconfig.PROMETHEUS)) {
+					d.exporters.Put(uint32(exporterconfig.PROMETHEUS), d.index, nil)
+				}
 				continue
 			}
 			d.counter.InCount++
@@ -197,7 +229,7 @@ func (d *Decoder) Run() {
 			}
 			decoder.Init(recvBytes.Buffer[recvBytes.Begin:recvBytes.End])
 			d.orgId, d.teamId = uint16(recvBytes.OrgID), uint16(recvBytes.TeamID)
-			d.handlePrometheusData(recvBytes.VtapID, decoder, &decodeBuffer, promWriteRequest, prometheusMetric, extraLabels)
+			d.handlePrometheusData(recvBytes.VtapID, decoder, &decodeBuffer, promWriteRequest, promWriteRequestV2, prometheusMetric, extraLabels)
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
 	}
@@ -220,13 +252,31 @@ func DecodeWriteRequest(compressed []byte, decodeBuffer *[]byte, req *prompb.Wri
 	return nil
 }
 
+func DecodeWriteRequestV2(compressed []byte, decodeBuffer *[]byte, req *prompb.WriteRequestV2) error {
+	decodeData, err := snappy.Decode(*decodeBuffer, compressed)
+	if err != nil {
+		return err
+	}
+
+	if err := req.Unmarshal(decodeData); err != nil {
+		return err
+	}
+
+	if len(decodeData) > len(*decodeBuffer) {
+		*decodeBuffer = decodeData
+	}
+
+	return nil
+}
+
 func prometheusMetricReset(m *pb.PrometheusMetric) {
 	m.Metrics = m.Metrics[:0]
 	m.ExtraLabelNames = m.ExtraLabelNames[:0]
 	m.ExtraLabelValues = m.ExtraLabelValues[:0]
+	m.RemoteWriteVersion = 0
 }
 
-func (d *Decoder) handlePrometheusData(vtapID uint16, decoder *codec.SimpleDecoder, decodeBuffer *[]byte, req *prompb.WriteRequest, prometheusMetric *pb.PrometheusMetric, extraLabels *[]prompb.Label) {
+func (d *Decoder) handlePrometheusData(vtapID uint16, decoder *codec.SimpleDecoder, decodeBuffer *[]byte, req *prompb.WriteRequest, reqV2 *prompb.WriteRequestV2, prometheusMetric *pb.PrometheusMetric, extraLabels *[]prompb.Label) {
 	for !decoder.IsEnd() {
 		prometheusMetricReset(prometheusMetric)
 		bytes := decoder.ReadBytes()
@@ -246,7 +296,13 @@ func (d *Decoder) handlePrometheusData(vtapID uint16, decoder *codec.SimpleDecod
 			continue
 		}
 
-		err := DecodeWriteRequest(prometheusMetric.Metrics, decodeBuffer, req)
+		var err error
+		isV2 := prometheusMetric.RemoteWriteVersion == prompb.REMOTE_WRITE_V2
+		if isV2 {
+			err = DecodeWriteRequestV2(prometheusMetric.Metrics, decodeBuffer, reqV2)
+		} else {
+			err = DecodeWriteRequest(prometheusMetric.Metrics, decodeBuffer, req)
+		}
 		if err != nil {
 			if d.counter.ErrCount == 0 {
 				log.Warningf("prometheus parse failed, err msg:%s", err)
@@ -263,15 +319,48 @@ func (d *Decoder) handlePrometheusData(vtapID uint16, decoder *codec.SimpleDecod
 			})
 		}
 
+		if isV2 {
+			d.handleWriteRequestV2(vtapID, reqV2, *extraLabels)
+			reqV2.ResetWithBufferReserved() // release memory as soon as possible
+			continue
+		}
+
+		d.writeMetadata(req.Metadata)
 		for i := range req.Timeseries {
 			d.counter.TimeSeriesIn++
-			d.sendPrometheus(vtapID, &req.Timeseries[i], *extraLabels)
+			d.sendTimeSeries(vtapID, &req.Timeseries[i], nil, *extraLabels)
 		}
 		req.ResetWithBufferReserved() // release memory as soon as possible
 	}
 }
 
+func (d *Decoder) writeMetadata(metadata []prompb.MetricMetadata) {
+	if len(metadata) == 0 {
+		return
+	}
+	items := d.metadataToStore(metadata)
+	d.counter.MetadataOut += int64(len(items))
+	d.extWriter.WriteMetadata(items)
+}
+
+// sendTimeSeries writes the native histograms and exemplars of the time series, and sends its samples.
+// labelsRefs is the symbol refs of the labels if the time series is decoded from a Remote-Write 2.0 request.
+func (d *Decoder) sendTimeSeries(vtapID uint16, ts *prompb.TimeSeries, labelsRefs []uint32, extraLabels []prompb.Label) {
+	if len(ts.Histograms) > 0 || len(ts.Exemplars) > 0 {
+		d.sendPrometheusExt(vtapID, ts, extraLabels)
+		// the time series only contains native histograms or exemplars
+		if len(ts.Samples) == 0 {
+			return
+		}
+	}
+	d.sendPrometheusWithRefs(vtapID, ts, labelsRefs, extraLabels)
+}
+
 func (d *Decoder) sendPrometheus(vtapID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label) {
+	d.sendPrometheusWithRefs(vtapID, ts, nil, extraLabels)
+}
+
+func (d *Decoder) sendPrometheusWithRefs(vtapID uint16, ts *prompb.TimeSeries, labelsRefs []uint32, extraLabels []prompb.Label) {
 	if d.debugEnabled {
 		log.Debugf("decoder %d vtap %d recv promtheus timeseries: %v", d.index, vtapID, ts)
 	}
@@ -285,7 +374,7 @@ func (d *Decoder) sendPrometheus(vtapID uint16, ts *prompb.TimeSeries, extraLabe
 		return
 	}
 
-	isSlowItem, err := d.samplesBuilder.TimeSeriesToStore(vtapID, epcId, podClusterId, d.orgId, d.teamId, ts, extraLabels)
+	isSlowItem, err := d.samplesBuilder.timeSeriesToStore(vtapID, epcId, podClusterId, d.orgId, d.teamId, ts, labelsRefs, extraLabels)
 	if !isSlowItem && err != nil {
 		if d.counter.TimeSeriesErr == 0 {
 			log.Warning(err)
@@ -300,6 +389,8 @@ func (d *Decoder) sendPrometheus(vtapID uint16, ts *prompb.TimeSeries, extraLabe
 		d.slowDecodeQueue.Put(AcquireSlowItem(vtapID, epcId, podClusterId, orgId, teamId, ts, extraLabels))
 		return
 	}
+	// export before writing, the samples may be released after written
+	builder.ExportSamples(d.exporters, d.index, extraLabels)
 	d.prometheusWriter.WriteBatch(builder.samplesBuffer, builder.metricName, builder.timeSeriesBuffer, extraLabels, builder.tsLabelNameIDsBuffer, builder.tsLabelValueIDsBuffer)
 	d.counter.OutCount += int64(len(builder.samplesBuffer))
 	d.counter.TimeSeriesOut++
@@ -321,6 +412,12 @@ func (b *PrometheusSamplesBuilder) GetEpcPodClusterId(orgId, vtapID uint16) (uin
 // if failed, return false,err
 // if isSlow, return true,slowReason
 func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId, orgId, teamID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label) (bool, error) {
+	return b.timeSeriesToStore(vtapID, epcId, podClusterId, orgId, teamID, ts, nil, extraLabels)
+}
+
+// timeSeriesToStore builds the samples of the time series. If labelsRefs is not nil, the time series is decoded
+// from a Remote-Write 2.0 request, and the label IDs of its labels are queried by the symbol refs in b.symbolIDs.
+func (b *PrometheusSamplesBuilder) timeSeriesToStore(vtapID, epcId, podClusterId, orgId, teamID uint16, ts *prompb.TimeSeries, labelsRefs []uint32, extraLabels []prompb.Label) (bool, error) {
 	if len(ts.Samples) == 0 {
 		b.counter.TimeSeriesInvaild++
 		return false, fmt.Errorf("prometheum samples of time serries(%s) is empty.", ts)
@@ -339,11 +436,15 @@ func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId
 	var ok bool
 
 	// get metricID first
-	for _, l := range ts.Labels {
+	for i, l := range ts.Labels {
 		if metricName == "" && l.Name == model.MetricNameLabel {
 			metricName = l.Value
 			b.metricName = metricName
-			metricID, ok = b.labelTable.QueryMetricID(orgId, metricName)
+			if labelsRefs != nil {
+				metricID, ok = b.symbolIDs.metricID(b.labelTable, labelsRefs[2*i+1], metricName)
+			} else {
+				metricID, ok = b.labelTable.QueryMetricID(orgId, metricName)
+			}
 			if !ok {
 				b.counter.MetricMiss++
 				return true, fmt.Errorf("metric name %s miss", metricName)
@@ -366,12 +467,21 @@ func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId
 			continue
 		}
 		b.counter.LabelCount++
-		nameID, ok := b.labelTable.QueryLabelNameID(orgId, l.Name)
+		var nameID, valueID uint32
+		if labelsRefs != nil && i < tsLen {
+			nameID, ok = b.symbolIDs.labelNameID(b.labelTable, labelsRefs[2*i], l.Name)
+		} else {
+			nameID, ok = b.labelTable.QueryLabelNameID(orgId, l.Name)
+		}
 		if !ok {
 			b.counter.NameMiss++
 			return true, fmt.Errorf("label name %s miss", l.Name)
 		}
-		valueID, ok := b.labelTable.QueryLabelValueID(orgId, l.Value)
+		if labelsRefs != nil && i < tsLen {
+			valueID, ok = b.symbolIDs.labelValueID(b.labelTable, labelsRefs[2*i+1], l.Value)
+		} else {
+			valueID, ok = b.labelTable.QueryLabelValueID(orgId, l.Value)
+		}
 		if !ok {
 			b.counter.ValueMiss++
 			return true, fmt.Errorf("label value %s miss", l.Value)
@@ -466,6 +576,31 @@ func (b *PrometheusSamplesBuilder) TimeSeriesToStore(vtapID, epcId, podClusterId
 	return false, nil
 }
 
+// ExportSamples converts the samples built by TimeSeriesToStore to PrometheusExportSample and puts them to exporters
+func (b *PrometheusSamplesBuilder) ExportSamples(exporters *exporters.Exporters, decoderIndex int, extraLabels []prompb.Label) {
+	if !exporters.IsExportDataSource(uint32(exporterconfig.PROMETHEUS)) {
+		return
+	}
+	for _, sample := range b.samplesBuffer {
+		s := dbwriter.AcquirePrometheusExportSample()
+		switch m := sample.(type) {
+		case *dbwriter.PrometheusSample:
+			s.Time, s.Value, s.OrgId, s.TeamID = m.Timestamp, m.Value, m.OrgId, m.TeamID
+			s.UniversalTag = m.UniversalTag
+		case *dbwriter.PrometheusSampleMini:
+			s.Time, s.Value, s.OrgId, s.TeamID = m.Timestamp, m.Value, m.OrgId, m.TeamID
+			s.UniversalTag.VTAPID = m.VtapId
+		default:
+			s.Release()
+			continue
+		}
+		s.FillLabels(b.timeSeriesBuffer, extraLabels)
+		exporters.Put(uint32(exporterconfig.PROMETHEUS), decoderIndex, s)
+		// exporters hold their own reference
+		s.Release()
+	}
+}
+
 func (b *PrometheusSamplesBuilder) fillUniversalTag(m *dbwriter.PrometheusSample, vtapID uint16, podName, instance string, podNameID, instanceID uint32, fillWithVtapId bool) {
 	// fast path
 	platformDataVersion := b.platformData.Version(m.OrgId)
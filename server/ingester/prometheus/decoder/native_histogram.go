@@ -0,0 +1,364 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+)
+
+const (
+	CLASSIC_BUCKET_SUFFIX = "_bucket"
+	CLASSIC_COUNT_SUFFIX  = "_count"
+	CLASSIC_SUM_SUFFIX    = "_sum"
+
+	// metadata is rewritten periodically even if it is not changed, so that it will not be dropped by the TTL
+	METADATA_REWRITE_INTERVAL = 3600 // s
+)
+
+var exemplarTraceIDLabels = []string{"trace_id", "traceID", "traceId", "trace-id"}
+var exemplarSpanIDLabels = []string{"span_id", "spanID", "spanId", "span-id"}
+
+type metadataCacheKey struct {
+	orgId            uint16
+	metricFamilyName string
+}
+
+type metadataCacheItem struct {
+	metricType string
+	help       string
+	unit       string
+	time       uint32
+}
+
+// decodeBuckets converts the spans and deltas (integer histogram) or counts (float histogram) to
+// the absolute bucket indexes and counts, the results are appended to indexes and counts.
+func decodeBuckets(spans []prompb.BucketSpan, deltas []int64, floatCounts []float64, indexes []int64, counts []float64) ([]int64, []float64) {
+	var index, current int64
+	pos := 0
+	for _, span := range spans {
+		// the offset of the first span is the start index, the others are the gap to the previous span
+		index += int64(span.Offset)
+		for i := uint32(0); i < span.Length; i++ {
+			var count float64
+			if len(deltas) > 0 {
+				if pos < len(deltas) {
+					current += deltas[pos]
+				}
+				count = float64(current)
+			} else if pos < len(floatCounts) {
+				count = floatCounts[pos]
+			}
+			indexes = append(indexes, index)
+			counts = append(counts, count)
+			index++
+			pos++
+		}
+	}
+	return indexes, counts
+}
+
+func histogramCount(h *prompb.Histogram) float64 {
+	switch c := h.Count.(type) {
+	case *prompb.Histogram_CountInt:
+		return float64(c.CountInt)
+	case *prompb.Histogram_CountFloat:
+		return c.CountFloat
+	}
+	return 0
+}
+
+func histogramZeroCount(h *prompb.Histogram) float64 {
+	switch c := h.ZeroCount.(type) {
+	case *prompb.Histogram_ZeroCountInt:
+		return float64(c.ZeroCountInt)
+	case *prompb.Histogram_ZeroCountFloat:
+		return c.ZeroCountFloat
+	}
+	return 0
+}
+
+// bucketUpperBound returns the upper bound of the positive bucket with the index, the bucket is (base^(index-1), base^index]
+// and base = 2^(2^-schema)
+func bucketUpperBound(schema int32, index int64) float64 {
+	if schema <= 0 {
+		return math.Ldexp(1, int(index)<<uint(-schema))
+	}
+	// split the exponent to the integer and fraction parts to keep the bounds of the power of 2 exact
+	mask := int64(1)<<uint(schema) - 1
+	return math.Ldexp(math.Pow(2, float64(index&mask)/float64(mask+1)), int(index>>uint(schema)))
+}
+
+func formatLe(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type classicBucket struct {
+	le         float64
+	cumulative float64
+}
+
+// toClassicBuckets converts a native histogram to the cumulative classic buckets ordered by le, the +Inf bucket is not included.
+func toClassicBuckets(h *dbwriter.PrometheusNativeHistogram, buckets []classicBucket) []classicBucket {
+	var cumulative float64
+	// the negative bucket with a larger index has a smaller upper bound: -base^(index-1)
+	for i := len(h.NegativeIndexes) - 1; i >= 0; i-- {
+		cumulative += h.NegativeCounts[i]
+		buckets = append(buckets, classicBucket{le: -bucketUpperBound(h.Schema, h.NegativeIndexes[i]-1), cumulative: cumulative})
+	}
+	cumulative += h.ZeroCount
+	buckets = append(buckets, classicBucket{le: h.ZeroThreshold, cumulative: cumulative})
+	for i := range h.PositiveIndexes {
+		cumulative += h.PositiveCounts[i]
+		buckets = append(buckets, classicBucket{le: bucketUpperBound(h.Schema, h.PositiveIndexes[i]), cumulative: cumulative})
+	}
+	return buckets
+}
+
+func getMetricName(labels []prompb.Label) string {
+	for i := range labels {
+		if labels[i].Name == model.MetricNameLabel {
+			return labels[i].Value
+		}
+	}
+	return ""
+}
+
+func cloneLabels(labels, extraLabels []prompb.Label, names, values []string) ([]string, []string) {
+	for i := range labels {
+		if labels[i].Name == model.MetricNameLabel {
+			continue
+		}
+		names = append(names, strings.Clone(labels[i].Name))
+		values = append(values, strings.Clone(labels[i].Value))
+	}
+	for i := range extraLabels {
+		names = append(names, strings.Clone(extraLabels[i].Name))
+		values = append(values, strings.Clone(extraLabels[i].Value))
+	}
+	return names, values
+}
+
+func timestampToSecond(ms int64) uint32 {
+	if ms <= 0 {
+		return uint32(time.Now().Unix())
+	}
+	return uint32(ms / 1000)
+}
+
+// nativeHistogramToStore decodes the native histograms of the time series, and appends them to histograms
+func (d *Decoder) nativeHistogramToStore(vtapID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label, histograms []*dbwriter.PrometheusNativeHistogram) []*dbwriter.PrometheusNativeHistogram {
+	metricName := getMetricName(ts.Labels)
+	if metricName == "" {
+		return histograms
+	}
+	metricName = strings.Clone(metricName)
+	var names, values []string
+	for i := range ts.Histograms {
+		h := &ts.Histograms[i]
+		nh := dbwriter.AcquirePrometheusNativeHistogram()
+		nh.Time = timestampToSecond(h.Timestamp)
+		nh.MetricName = metricName
+		// all histograms of the time series share the same labels
+		if names == nil {
+			names, values = cloneLabels(ts.Labels, extraLabels, nil, nil)
+		}
+		nh.LabelNames, nh.LabelValues = names, values
+		nh.Schema = h.Schema
+		nh.ZeroThreshold = h.ZeroThreshold
+		nh.ZeroCount = histogramZeroCount(h)
+		nh.Count = histogramCount(h)
+		nh.Sum = h.Sum
+		nh.PositiveIndexes, nh.PositiveCounts = decodeBuckets(h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts, nh.PositiveIndexes[:0], nh.PositiveCounts[:0])
+		nh.NegativeIndexes, nh.NegativeCounts = decodeBuckets(h.NegativeSpans, h.NegativeDeltas, h.NegativeCounts, nh.NegativeIndexes[:0], nh.NegativeCounts[:0])
+		nh.ResetHint = uint8(h.ResetHint)
+		nh.VtapId, nh.OrgId, nh.TeamID = vtapID, d.orgId, d.teamId
+		histograms = append(histograms, nh)
+	}
+	return histograms
+}
+
+// nativeHistogramsToClassic expands the native histograms of a metric to the classic series
+// <name>_bucket{le=...}, <name>_count and <name>_sum, so that they can be queried by PromQL
+// functions such as histogram_quantile. The returned series reuse the buffer of the decoder.
+func (d *Decoder) nativeHistogramsToClassic(ts *prompb.TimeSeries, histograms []*dbwriter.PrometheusNativeHistogram) []prompb.TimeSeries {
+	metricName := getMetricName(ts.Labels)
+	series := d.classicSeries[:0]
+	newSeries := func(name, le string) int {
+		if len(series) < cap(series) {
+			series = series[:len(series)+1]
+		} else {
+			series = append(series, prompb.TimeSeries{})
+		}
+		s := &series[len(series)-1]
+		s.Labels, s.Samples = s.Labels[:0], s.Samples[:0]
+		for i := range ts.Labels {
+			if ts.Labels[i].Name == model.MetricNameLabel {
+				s.Labels = append(s.Labels, prompb.Label{Name: model.MetricNameLabel, Value: name})
+			} else {
+				s.Labels = append(s.Labels, ts.Labels[i])
+			}
+		}
+		if le != "" {
+			s.Labels = append(s.Labels, prompb.Label{Name: model.BucketLabel, Value: le})
+		}
+		return len(series) - 1
+	}
+
+	countIndex := newSeries(metricName+CLASSIC_COUNT_SUFFIX, "")
+	sumIndex := newSeries(metricName+CLASSIC_SUM_SUFFIX, "")
+	bucketIndexes := make(map[string]int)
+	for i, h := range histograms {
+		timestamp := ts.Histograms[i].Timestamp
+		series[countIndex].Samples = append(series[countIndex].Samples, prompb.Sample{Value: h.Count, Timestamp: timestamp})
+		series[sumIndex].Samples = append(series[sumIndex].Samples, prompb.Sample{Value: h.Sum, Timestamp: timestamp})
+
+		d.classicBuckets = append(toClassicBuckets(h, d.classicBuckets[:0]), classicBucket{le: math.Inf(1), cumulative: h.Count})
+		for _, b := range d.classicBuckets {
+			le := formatLe(b.le)
+			index, ok := bucketIndexes[le]
+			if !ok {
+				index = newSeries(metricName+CLASSIC_BUCKET_SUFFIX, le)
+				bucketIndexes[le] = index
+			}
+			series[index].Samples = append(series[index].Samples, prompb.Sample{Value: b.cumulative, Timestamp: timestamp})
+		}
+	}
+	d.classicSeries = series
+	return series
+}
+
+func findLabel(names, values []string, candidates []string) string {
+	for _, c := range candidates {
+		for i := range names {
+			if names[i] == c {
+				return values[i]
+			}
+		}
+	}
+	return ""
+}
+
+// exemplarsToStore converts the exemplars of the time series, and appends them to exemplars
+func (d *Decoder) exemplarsToStore(vtapID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label, exemplars []*dbwriter.PrometheusExemplar) []*dbwriter.PrometheusExemplar {
+	metricName := getMetricName(ts.Labels)
+	if metricName == "" {
+		return exemplars
+	}
+	metricName = strings.Clone(metricName)
+	var names, values []string
+	for i := range ts.Exemplars {
+		ex := &ts.Exemplars[i]
+		e := dbwriter.AcquirePrometheusExemplar()
+		e.Time = timestampToSecond(ex.Timestamp)
+		e.Timestamp = ex.Timestamp
+		if e.Timestamp <= 0 {
+			e.Timestamp = int64(e.Time) * 1000
+		}
+		e.MetricName = metricName
+		if names == nil {
+			names, values = cloneLabels(ts.Labels, extraLabels, nil, nil)
+		}
+		e.LabelNames, e.LabelValues = names, values
+		e.ExemplarLabelNames, e.ExemplarLabelValues = cloneLabels(ex.Labels, nil, e.ExemplarLabelNames[:0], e.ExemplarLabelValues[:0])
+		e.TraceID = findLabel(e.ExemplarLabelNames, e.ExemplarLabelValues, exemplarTraceIDLabels)
+		e.SpanID = findLabel(e.ExemplarLabelNames, e.ExemplarLabelValues, exemplarSpanIDLabels)
+		e.Value = ex.Value
+		e.VtapId, e.OrgId, e.TeamID = vtapID, d.orgId, d.teamId
+		exemplars = append(exemplars, e)
+	}
+	return exemplars
+}
+
+func metricTypeString(t prompb.MetricMetadata_MetricType) string {
+	return strings.ToLower(prompb.MetricMetadata_MetricType_name[int32(t)])
+}
+
+// metadataToStore returns the metadata which is new or changed or not written for METADATA_REWRITE_INTERVAL
+func (d *Decoder) metadataToStore(metadata []prompb.MetricMetadata) []interface{} {
+	now := uint32(time.Now().Unix())
+	items := d.metadataBuffer[:0]
+	for i := range metadata {
+		m := &metadata[i]
+		if m.MetricFamilyName == "" {
+			continue
+		}
+		metricType := metricTypeString(m.Type)
+		key := metadataCacheKey{orgId: d.orgId, metricFamilyName: m.MetricFamilyName}
+		if cached, ok := d.metadataCache[key]; ok && cached.metricType == metricType && cached.help == m.Help &&
+			cached.unit == m.Unit && now < cached.time+METADATA_REWRITE_INTERVAL {
+			continue
+		}
+		item := &dbwriter.PrometheusMetadata{
+			Time:             now,
+			MetricFamilyName: strings.Clone(m.MetricFamilyName),
+			Type:             metricType,
+			Help:             strings.Clone(m.Help),
+			Unit:             strings.Clone(m.Unit),
+			OrgId:            d.orgId,
+			TeamID:           d.teamId,
+		}
+		key.metricFamilyName = item.MetricFamilyName
+		d.metadataCache[key] = metadataCacheItem{metricType: item.Type, help: item.Help, unit: item.Unit, time: now}
+		items = append(items, item)
+	}
+	d.metadataBuffer = items
+	return items
+}
+
+// sendPrometheusExt writes the native histograms and exemplars of the time series, and sends the classic
+// series expanded from the native histograms
+func (d *Decoder) sendPrometheusExt(vtapID uint16, ts *prompb.TimeSeries, extraLabels []prompb.Label) {
+	if len(ts.Exemplars) > 0 {
+		d.exemplars = d.exemplarsToStore(vtapID, ts, extraLabels, d.exemplars[:0])
+		items := d.itemBuffer[:0]
+		for _, e := range d.exemplars {
+			items = append(items, e)
+		}
+		d.counter.ExemplarIn += int64(len(items))
+		d.extWriter.WriteExemplars(items)
+		d.itemBuffer = items
+	}
+
+	if len(ts.Histograms) == 0 {
+		return
+	}
+	d.histograms = d.nativeHistogramToStore(vtapID, ts, extraLabels, d.histograms[:0])
+	if len(d.histograms) == 0 {
+		return
+	}
+	// expand before writing, the histograms are released by the writer after written
+	series := d.nativeHistogramsToClassic(ts, d.histograms)
+	items := d.itemBuffer[:0]
+	for _, h := range d.histograms {
+		items = append(items, h)
+	}
+	d.counter.HistogramIn += int64(len(items))
+	d.extWriter.WriteNativeHistograms(items)
+	d.itemBuffer = items
+
+	for i := range series {
+		d.sendPrometheus(vtapID, &series[i], extraLabels)
+	}
+}
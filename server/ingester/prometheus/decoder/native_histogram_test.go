@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+)
+
+// formatClassicSeries formats the series as 'name{le}=value@timestamp' to compare easily
+func formatClassicSeries(series []prompb.TimeSeries) []string {
+	ret := []string{}
+	for _, s := range series {
+		name, le := "", ""
+		for _, l := range s.Labels {
+			switch l.Name {
+			case "__name__":
+				name = l.Value
+			case "le":
+				le = "{" + l.Value + "}"
+			}
+		}
+		for _, sample := range s.Samples {
+			ret = append(ret, fmt.Sprintf("%s%s=%v@%d", name, le, sample.Value, sample.Timestamp))
+		}
+	}
+	return ret
+}
+
+func TestDecodeBuckets(t *testing.T) {
+	spans := []prompb.BucketSpan{{Offset: -1, Length: 2}, {Offset: 2, Length: 1}}
+	indexes, counts := decodeBuckets(spans, []int64{1, 2, -2}, nil, nil, nil)
+	if !reflect.DeepEqual(indexes, []int64{-1, 0, 3}) || !reflect.DeepEqual(counts, []float64{1, 3, 1}) {
+		t.Errorf("integer buckets: got indexes %v counts %v", indexes, counts)
+	}
+	indexes, counts = decodeBuckets(spans, nil, []float64{0.5, 1.5, 2}, nil, nil)
+	if !reflect.DeepEqual(indexes, []int64{-1, 0, 3}) || !reflect.DeepEqual(counts, []float64{0.5, 1.5, 2}) {
+		t.Errorf("float buckets: got indexes %v counts %v", indexes, counts)
+	}
+}
+
+func TestBucketUpperBound(t *testing.T) {
+	testCases := []struct {
+		schema   int32
+		index    int64
+		expected float64
+	}{
+		{0, 0, 1},
+		{0, 3, 8},
+		{0, -2, 0.25},
+		{-1, 1, 4},
+		{1, 2, 2},
+		{1, -3, 0.3535533905932738},
+		{1, 1, 1.4142135623730951},
+	}
+	for _, tc := range testCases {
+		if got := bucketUpperBound(tc.schema, tc.index); got != tc.expected {
+			t.Errorf("schema %d index %d: expected %v, got %v", tc.schema, tc.index, tc.expected, got)
+		}
+	}
+}
+
+func TestNativeHistogramsToClassic(t *testing.T) {
+	ts := &prompb.TimeSeries{
+		Labels: []prompb.Label{{Name: "__name__", Value: "latency"}, {Name: "job", Value: "api"}},
+		Histograms: []prompb.Histogram{{
+			Count:          &prompb.Histogram_CountInt{CountInt: 7},
+			Sum:            10,
+			Schema:         0,
+			ZeroThreshold:  0.001,
+			ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+			NegativeSpans:  []prompb.BucketSpan{{Offset: 1, Length: 1}},
+			NegativeDeltas: []int64{2},
+			PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+			PositiveDeltas: []int64{1, 1, -1},
+			Timestamp:      1000,
+		}},
+	}
+	d := &Decoder{}
+	histograms := d.nativeHistogramToStore(1, ts, []prompb.Label{{Name: "cluster", Value: "c1"}}, nil)
+	if len(histograms) != 1 {
+		t.Fatalf("expected 1 histogram, got %d", len(histograms))
+	}
+	h := histograms[0]
+	if h.MetricName != "latency" || !reflect.DeepEqual(h.LabelNames, []string{"job", "cluster"}) || h.Count != 7 || h.ZeroCount != 1 {
+		t.Errorf("unexpected histogram: %+v", h)
+	}
+
+	expected := []string{
+		"latency_count=7@1000",
+		"latency_sum=10@1000",
+		"latency_bucket{-1}=2@1000",
+		"latency_bucket{0.001}=3@1000",
+		"latency_bucket{1}=4@1000",
+		"latency_bucket{2}=6@1000",
+		"latency_bucket{8}=7@1000",
+		"latency_bucket{+Inf}=7@1000",
+	}
+	if got := formatClassicSeries(d.nativeHistogramsToClassic(ts, histograms)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	dbwriter.ReleasePrometheusNativeHistogram(h)
+}
+
+func TestMetadataToStore(t *testing.T) {
+	d := &Decoder{metadataCache: make(map[metadataCacheKey]metadataCacheItem)}
+	metadata := []prompb.MetricMetadata{{Type: prompb.MetricMetadata_HISTOGRAM, MetricFamilyName: "latency", Help: "request latency", Unit: "seconds"}}
+	items := d.metadataToStore(metadata)
+	if len(items) != 1 || items[0].(*dbwriter.PrometheusMetadata).Type != "histogram" {
+		t.Fatalf("unexpected metadata: %v", items)
+	}
+	if items = d.metadataToStore(metadata); len(items) != 0 {
+		t.Errorf("unchanged metadata should be skipped, got %v", items)
+	}
+	metadata[0].Help = "latency of requests"
+	if items = d.metadataToStore(metadata); len(items) != 1 {
+		t.Errorf("changed metadata should be written, got %v", items)
+	}
+}
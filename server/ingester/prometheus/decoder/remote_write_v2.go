@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+)
+
+const (
+	symbolMetricIDResolved uint8 = 1 << iota
+	symbolNameIDResolved
+	symbolValueIDResolved
+)
+
+// symbolIDTable caches the IDs in PrometheusLabelTable of the symbols of a Remote-Write 2.0 request,
+// so that a symbol shared by many time series is looked up only once. The misses are not cached, the
+// time series with missed IDs are sent to the slow decoder which requests the IDs from the controller.
+type symbolIDTable struct {
+	orgId    uint16
+	ids      [][3]uint32 // metric id, label name id, label value id
+	resolved []uint8
+}
+
+func (t *symbolIDTable) reset(orgId uint16, symbolCount int) {
+	t.orgId = orgId
+	if cap(t.ids) < symbolCount {
+		t.ids = make([][3]uint32, symbolCount)
+		t.resolved = make([]uint8, symbolCount)
+		return
+	}
+	t.ids = t.ids[:symbolCount]
+	t.resolved = t.resolved[:symbolCount]
+	for i := range t.resolved {
+		t.resolved[i] = 0
+	}
+}
+
+func (t *symbolIDTable) metricID(labelTable *PrometheusLabelTable, ref uint32, symbol string) (uint32, bool) {
+	if t.resolved[ref]&symbolMetricIDResolved != 0 {
+		return t.ids[ref][0], true
+	}
+	id, ok := labelTable.QueryMetricID(t.orgId, symbol)
+	if ok {
+		t.ids[ref][0] = id
+		t.resolved[ref] |= symbolMetricIDResolved
+	}
+	return id, ok
+}
+
+func (t *symbolIDTable) labelNameID(labelTable *PrometheusLabelTable, ref uint32, symbol string) (uint32, bool) {
+	if t.resolved[ref]&symbolNameIDResolved != 0 {
+		return t.ids[ref][1], true
+	}
+	id, ok := labelTable.QueryLabelNameID(t.orgId, symbol)
+	if ok {
+		t.ids[ref][1] = id
+		t.resolved[ref] |= symbolNameIDResolved
+	}
+	return id, ok
+}
+
+func (t *symbolIDTable) labelValueID(labelTable *PrometheusLabelTable, ref uint32, symbol string) (uint32, bool) {
+	if t.resolved[ref]&symbolValueIDResolved != 0 {
+		return t.ids[ref][2], true
+	}
+	id, ok := labelTable.QueryLabelValueID(t.orgId, symbol)
+	if ok {
+		t.ids[ref][2] = id
+		t.resolved[ref] |= symbolValueIDResolved
+	}
+	return id, ok
+}
+
+// metricFamilyName returns the metric family name of a series of classic histograms or summaries
+func metricFamilyName(metricName string, metricType prompb.MetricMetadata_MetricType) string {
+	switch metricType {
+	case prompb.MetricMetadata_HISTOGRAM, prompb.MetricMetadata_GAUGEHISTOGRAM:
+		for _, suffix := range []string{CLASSIC_BUCKET_SUFFIX, CLASSIC_COUNT_SUFFIX, CLASSIC_SUM_SUFFIX} {
+			if strings.HasSuffix(metricName, suffix) {
+				return strings.TrimSuffix(metricName, suffix)
+			}
+		}
+	case prompb.MetricMetadata_SUMMARY:
+		for _, suffix := range []string{CLASSIC_COUNT_SUFFIX, CLASSIC_SUM_SUFFIX} {
+			if strings.HasSuffix(metricName, suffix) {
+				return strings.TrimSuffix(metricName, suffix)
+			}
+		}
+	}
+	return metricName
+}
+
+// timeSeriesV2ToV1 converts the time series of Remote-Write 2.0 to d.timeSeriesV1. The labels refer to the
+// symbols and the samples and histograms are shared, so that no label string is materialized.
+func (d *Decoder) timeSeriesV2ToV1(symbols []string, tsV2 *prompb.TimeSeriesV2) *prompb.TimeSeries {
+	ts := &d.timeSeriesV1
+	ts.Labels = ts.Labels[:0]
+	for i := 0; i < len(tsV2.LabelsRefs); i += 2 {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: symbols[tsV2.LabelsRefs[i]], Value: symbols[tsV2.LabelsRefs[i+1]]})
+	}
+	ts.Samples = tsV2.Samples
+	ts.Histograms = tsV2.Histograms
+
+	ts.Exemplars = ts.Exemplars[:0]
+	exemplarLabels := d.exemplarLabelsV1[:0]
+	for i := range tsV2.Exemplars {
+		e := &tsV2.Exemplars[i]
+		start := len(exemplarLabels)
+		for j := 0; j < len(e.LabelsRefs); j += 2 {
+			exemplarLabels = append(exemplarLabels, prompb.Label{Name: symbols[e.LabelsRefs[j]], Value: symbols[e.LabelsRefs[j+1]]})
+		}
+		ts.Exemplars = append(ts.Exemplars, prompb.Exemplar{Labels: exemplarLabels[start:len(exemplarLabels):len(exemplarLabels)], Value: e.Value, Timestamp: e.Timestamp})
+	}
+	d.exemplarLabelsV1 = exemplarLabels
+	return ts
+}
+
+// handleWriteRequestV2 handles the time series of Remote-Write 2.0. Their labels are mapped to the label IDs
+// by the symbol refs, and their inline metadata are written as the metadata of Remote-Write 1.0.
+// The created timestamps are ignored since the samples table has no column for them.
+func (d *Decoder) handleWriteRequestV2(vtapID uint16, req *prompb.WriteRequestV2, extraLabels []prompb.Label) {
+	d.samplesBuilder.symbolIDs.reset(d.orgId, len(req.Symbols))
+	metadata := d.metadataV1[:0]
+	for i := range req.Timeseries {
+		d.counter.TimeSeriesIn++
+		tsV2 := &req.Timeseries[i]
+		ts := d.timeSeriesV2ToV1(req.Symbols, tsV2)
+		if m := &tsV2.Metadata; m.Type != prompb.MetricMetadata_UNKNOWN || m.HelpRef != 0 || m.UnitRef != 0 {
+			if metricName := getMetricName(ts.Labels); metricName != "" {
+				metadata = append(metadata, prompb.MetricMetadata{
+					Type:             m.Type,
+					MetricFamilyName: metricFamilyName(metricName, m.Type),
+					Help:             req.Symbols[m.HelpRef],
+					Unit:             req.Symbols[m.UnitRef],
+				})
+			}
+		}
+		d.sendTimeSeries(vtapID, ts, tsV2.LabelsRefs, extraLabels)
+	}
+	d.writeMetadata(metadata)
+	d.metadataV1 = metadata[:0]
+}
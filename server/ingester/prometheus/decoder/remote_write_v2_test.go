@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+)
+
+func TestMetricFamilyName(t *testing.T) {
+	testCases := []struct {
+		name       string
+		metricType prompb.MetricMetadata_MetricType
+		expected   string
+	}{
+		{"latency_bucket", prompb.MetricMetadata_HISTOGRAM, "latency"},
+		{"latency_count", prompb.MetricMetadata_HISTOGRAM, "latency"},
+		{"latency", prompb.MetricMetadata_HISTOGRAM, "latency"},
+		{"rpc_sum", prompb.MetricMetadata_SUMMARY, "rpc"},
+		{"rpc_bucket", prompb.MetricMetadata_SUMMARY, "rpc_bucket"},
+		{"requests_count", prompb.MetricMetadata_COUNTER, "requests_count"},
+	}
+	for _, tc := range testCases {
+		if got := metricFamilyName(tc.name, tc.metricType); got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestTimeSeriesV2ToV1(t *testing.T) {
+	symbols := []string{"", "__name__", "requests_total", "job", "api", "trace_id", "abc"}
+	tsV2 := &prompb.TimeSeriesV2{
+		LabelsRefs: []uint32{1, 2, 3, 4},
+		Samples:    []prompb.Sample{{Value: 1, Timestamp: 1000}},
+		Exemplars: []prompb.ExemplarV2{
+			{LabelsRefs: []uint32{5, 6}, Value: 0.5, Timestamp: 900},
+			{LabelsRefs: []uint32{3, 4}, Value: 0.7, Timestamp: 950},
+		},
+	}
+	d := &Decoder{}
+	ts := d.timeSeriesV2ToV1(symbols, tsV2)
+	expected := &prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "requests_total"}, {Name: "job", Value: "api"}},
+		Samples: tsV2.Samples,
+		Exemplars: []prompb.Exemplar{
+			{Labels: []prompb.Label{{Name: "trace_id", Value: "abc"}}, Value: 0.5, Timestamp: 900},
+			{Labels: []prompb.Label{{Name: "job", Value: "api"}}, Value: 0.7, Timestamp: 950},
+		},
+	}
+	if !reflect.DeepEqual(ts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, ts)
+	}
+
+	// the buffers are reused by the next time series
+	ts = d.timeSeriesV2ToV1(symbols, &prompb.TimeSeriesV2{LabelsRefs: []uint32{1, 2}})
+	if len(ts.Labels) != 1 || len(ts.Exemplars) != 0 || ts.Samples != nil {
+		t.Errorf("unexpected time series: %+v", ts)
+	}
+}
@@ -26,6 +26,8 @@ import (
 
 	"github.com/deepflowio/deepflow/message/trident"
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/config"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
 	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
@@ -96,6 +98,8 @@ type SlowDecoder struct {
 	debugEnabled     bool
 	config           *config.Config
 	prometheusWriter *dbwriter.PrometheusWriter
+	exporters        *exporters.Exporters
+	exportIndex      int
 
 	samplesBuilder *PrometheusSamplesBuilder
 	labelTable     *PrometheusLabelTable
@@ -110,6 +114,8 @@ func NewSlowDecoder(
 	prometheusLabelTable *PrometheusLabelTable,
 	inQueue queue.QueueReader,
 	prometheusWriter *dbwriter.PrometheusWriter,
+	exporters *exporters.Exporters,
+	exportIndex int,
 	config *config.Config,
 ) *SlowDecoder {
 	return &SlowDecoder{
@@ -119,6 +125,8 @@ func NewSlowDecoder(
 		inQueue:          inQueue,
 		debugEnabled:     log.IsEnabledFor(logging.DEBUG),
 		prometheusWriter: prometheusWriter,
+		exporters:        exporters,
+		exportIndex:      exportIndex,
 		config:           config,
 		counter:          &SlowCounter{},
 	}
@@ -144,6 +152,9 @@ func (d *SlowDecoder) Run() {
 		for i := 0; i < n; i++ {
 			if buffer[i] == nil {
 				queueTicker++
+				if d.exporters.IsExportDataSource(uint32(exporterconfig.PROMETHEUS)) {
+					d.exporters.Put(uint32(exporterconfig.PROMETHEUS), d.exportIndex, nil)
+				}
 				continue
 			}
 			d.counter.TimeSeriesIn++
@@ -288,6 +299,7 @@ func (d *SlowDecoder) sendPrometheusSamples(vtapID, epcId, podClusterId, orgId,
 		d.counter.TimeSeriesDrop++
 		return
 	}
+	d.samplesBuilder.ExportSamples(d.exporters, d.exportIndex, nil)
 	d.prometheusWriter.WriteBatch(d.samplesBuilder.samplesBuffer,
 		d.samplesBuilder.metricName,
 		d.samplesBuilder.timeSeriesBuffer,
@@ -24,6 +24,8 @@ import (
 	_ "google.golang.org/grpc"
 
 	dropletqueue "github.com/deepflowio/deepflow/server/ingester/droplet/queue"
+	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	exporterconfig "github.com/deepflowio/deepflow/server/ingester/exporters/config"
 	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/config"
 	"github.com/deepflowio/deepflow/server/ingester/prometheus/dbwriter"
@@ -46,7 +48,7 @@ type PrometheusHandler struct {
 	prometheusLabelTable *decoder.PrometheusLabelTable
 }
 
-func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager) (*PrometheusHandler, error) {
+func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager, exporters *exporters.Exporters) (*PrometheusHandler, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_PROMETHEUS_QUEUE)
 	queueCount := config.DecoderQueueCount
 	msgType := datatype.MESSAGE_TYPE_PROMETHEUS
@@ -76,6 +78,11 @@ func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platfo
 		initAppLabelColumnCount = currentColumnIndexMax
 	}
 
+	extWriter, err := dbwriter.NewPrometheusExtWriter(config)
+	if err != nil {
+		return nil, err
+	}
+
 	decoders := make([]*decoder.Decoder, queueCount)
 	platformDatas := make([]*grpc.PlatformInfoTable, queueCount)
 	slowDecoders := make([]*decoder.SlowDecoder, queueCount)
@@ -100,6 +107,8 @@ func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platfo
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			queue.QueueWriter(slowDecodeQueues.FixedMultiQueue[i]),
 			metricsWriter,
+			extWriter,
+			exporters,
 			config,
 		)
 		slowMetricsWriter, err := dbwriter.NewPrometheusWriter(i, initAppLabelColumnCount, "slow-prometheus", dbwriter.PROMETHEUS_DB, config)
@@ -113,6 +122,9 @@ func NewPrometheusHandler(config *config.Config, recv *receiver.Receiver, platfo
 			prometheusLabelTable,
 			queue.QueueReader(slowDecodeQueues.FixedMultiQueue[i]),
 			slowMetricsWriter,
+			exporters,
+			// the decoders export with the producer indexes of group 0
+			exporterconfig.ProducerIndex(1, i),
 			config,
 		)
 	}
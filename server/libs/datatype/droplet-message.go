@@ -56,6 +56,11 @@ const (
 	MESSAGE_TYPE_APPLICATION_LOG
 	MESSAGE_TYPE_AGENT_LOG
 	MESSAGE_TYPE_SKYWALKING // 19
+
+	MESSAGE_TYPE_OPENTELEMETRY_LOG // 20
+	MESSAGE_TYPE_OPENTELEMETRY_METRICS
+	MESSAGE_TYPE_ZIPKIN
+	MESSAGE_TYPE_JAEGER
 	MESSAGE_TYPE_MAX
 )
 
@@ -82,6 +87,11 @@ var MessageTypeString = [MESSAGE_TYPE_MAX]string{
 	MESSAGE_TYPE_APPLICATION_LOG:          "application_log",
 	MESSAGE_TYPE_AGENT_LOG:                "agent_log",
 	MESSAGE_TYPE_SKYWALKING:               "skywalking",
+
+	MESSAGE_TYPE_OPENTELEMETRY_LOG:     "open_telemetry_log",
+	MESSAGE_TYPE_OPENTELEMETRY_METRICS: "open_telemetry_metrics",
+	MESSAGE_TYPE_ZIPKIN:                "zipkin",
+	MESSAGE_TYPE_JAEGER:                "jaeger",
 }
 
 func (m MessageType) String() string {
@@ -126,6 +136,11 @@ var MessageHeaderTypes = [MESSAGE_TYPE_MAX]MessageHeaderType{
 	MESSAGE_TYPE_APPLICATION_LOG:          HEADER_TYPE_LT_VTAP,
 	MESSAGE_TYPE_AGENT_LOG:                HEADER_TYPE_LT_VTAP,
 	MESSAGE_TYPE_SKYWALKING:               HEADER_TYPE_LT_VTAP,
+
+	MESSAGE_TYPE_OPENTELEMETRY_LOG:     HEADER_TYPE_LT_VTAP,
+	MESSAGE_TYPE_OPENTELEMETRY_METRICS: HEADER_TYPE_LT_VTAP,
+	MESSAGE_TYPE_ZIPKIN:                HEADER_TYPE_LT_VTAP,
+	MESSAGE_TYPE_JAEGER:                HEADER_TYPE_LT_VTAP,
 }
 
 func (m MessageType) HeaderType() MessageHeaderType {
@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prompb
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Content types of Remote-Write, see https://prometheus.io/docs/specs/remote_write_spec_2_0/
+const (
+	REMOTE_WRITE_V1 = 1 // prometheus.WriteRequest
+	REMOTE_WRITE_V2 = 2 // io.prometheus.write.v2.Request
+)
+
+// WriteRequestV2 is io.prometheus.write.v2.Request. The labels, help and unit of the time series
+// are references to Symbols, which are decoded by unsafeBytesToString and do not hold actual memory.
+type WriteRequestV2 struct {
+	Symbols    []string
+	Timeseries []TimeSeriesV2
+}
+
+type TimeSeriesV2 struct {
+	// pairs of the references of label name and value in Symbols
+	LabelsRefs       []uint32
+	Samples          []Sample
+	Histograms       []Histogram
+	Exemplars        []ExemplarV2
+	Metadata         MetadataV2
+	CreatedTimestamp int64 // ms
+}
+
+type ExemplarV2 struct {
+	LabelsRefs []uint32
+	Value      float64
+	Timestamp  int64 // ms
+}
+
+// MetadataV2 is the metadata of a time series, the values of Type are the same as MetricMetadata_MetricType
+type MetadataV2 struct {
+	Type    MetricMetadata_MetricType
+	HelpRef uint32
+	UnitRef uint32
+}
+
+// ResetWithBufferReserved resets the request and reserves the memory of the time series for reuse
+func (m *WriteRequestV2) ResetWithBufferReserved() {
+	m.Symbols = m.Symbols[:0]
+	for i := range m.Timeseries {
+		m.Timeseries[i].reset()
+	}
+	m.Timeseries = m.Timeseries[:0]
+}
+
+func (m *TimeSeriesV2) reset() {
+	for i := range m.Exemplars {
+		m.Exemplars[i].LabelsRefs = m.Exemplars[i].LabelsRefs[:0]
+	}
+	*m = TimeSeriesV2{
+		LabelsRefs: m.LabelsRefs[:0],
+		Samples:    m.Samples[:0],
+		Histograms: m.Histograms[:0],
+		Exemplars:  m.Exemplars[:0],
+	}
+}
+
+func (m *WriteRequestV2) Unmarshal(data []byte) error {
+	m.ResetWithBufferReserved()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case num == 4 && typ == protowire.BytesType:
+			b, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			m.Symbols = append(m.Symbols, unsafeBytesToString(b))
+			data = data[n:]
+		case num == 5 && typ == protowire.BytesType:
+			b, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if len(m.Timeseries) < cap(m.Timeseries) {
+				m.Timeseries = m.Timeseries[:len(m.Timeseries)+1]
+			} else {
+				m.Timeseries = append(m.Timeseries, TimeSeriesV2{})
+			}
+			if err := m.Timeseries[len(m.Timeseries)-1].unmarshal(b); err != nil {
+				return err
+			}
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return m.validate()
+}
+
+// validate checks the references to Symbols, so that they can be used without bounds checking
+func (m *WriteRequestV2) validate() error {
+	if len(m.Symbols) > 0 && m.Symbols[0] != "" {
+		return fmt.Errorf("the first symbol must be an empty string, got %q", m.Symbols[0])
+	}
+	symbolCount := uint32(len(m.Symbols))
+	checkRefs := func(refs []uint32) error {
+		if len(refs)%2 != 0 {
+			return fmt.Errorf("the length of labels refs %d is odd", len(refs))
+		}
+		for _, ref := range refs {
+			if ref >= symbolCount {
+				return fmt.Errorf("symbol ref %d out of range %d", ref, symbolCount)
+			}
+		}
+		return nil
+	}
+	for i := range m.Timeseries {
+		ts := &m.Timeseries[i]
+		if err := checkRefs(ts.LabelsRefs); err != nil {
+			return err
+		}
+		for j := range ts.Exemplars {
+			if err := checkRefs(ts.Exemplars[j].LabelsRefs); err != nil {
+				return err
+			}
+		}
+		if ts.Metadata.HelpRef >= symbolCount || ts.Metadata.UnitRef >= symbolCount {
+			return fmt.Errorf("metadata symbol ref out of range %d", symbolCount)
+		}
+	}
+	return nil
+}
+
+// consumeRefs decodes both packed and unpacked repeated uint32
+func consumeRefs(refs []uint32, typ protowire.Type, data []byte) ([]uint32, int) {
+	if typ == protowire.VarintType {
+		v, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return refs, n
+		}
+		return append(refs, uint32(v)), n
+	}
+	b, n := protowire.ConsumeBytes(data)
+	if n < 0 {
+		return refs, n
+	}
+	for len(b) > 0 {
+		v, m := protowire.ConsumeVarint(b)
+		if m < 0 {
+			return refs, m
+		}
+		refs = append(refs, uint32(v))
+		b = b[m:]
+	}
+	return refs, n
+}
+
+func (m *TimeSeriesV2) unmarshal(data []byte) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case num == 1 && (typ == protowire.BytesType || typ == protowire.VarintType):
+			m.LabelsRefs, n = consumeRefs(m.LabelsRefs, typ, data)
+		case num == 2 && typ == protowire.BytesType:
+			var b []byte
+			if b, n = protowire.ConsumeBytes(data); n >= 0 {
+				var s Sample
+				if err := unmarshalSample(b, &s); err != nil {
+					return err
+				}
+				m.Samples = append(m.Samples, s)
+			}
+		case num == 3 && typ == protowire.BytesType:
+			var b []byte
+			if b, n = protowire.ConsumeBytes(data); n >= 0 {
+				// the field numbers of io.prometheus.write.v2.Histogram are the same as prometheus.Histogram
+				m.Histograms = append(m.Histograms, Histogram{})
+				if err := m.Histograms[len(m.Histograms)-1].Unmarshal(b); err != nil {
+					return err
+				}
+			}
+		case num == 4 && typ == protowire.BytesType:
+			var b []byte
+			if b, n = protowire.ConsumeBytes(data); n >= 0 {
+				if len(m.Exemplars) < cap(m.Exemplars) {
+					m.Exemplars = m.Exemplars[:len(m.Exemplars)+1]
+				} else {
+					m.Exemplars = append(m.Exemplars, ExemplarV2{})
+				}
+				if err := m.Exemplars[len(m.Exemplars)-1].unmarshal(b); err != nil {
+					return err
+				}
+			}
+		case num == 5 && typ == protowire.BytesType:
+			var b []byte
+			if b, n = protowire.ConsumeBytes(data); n >= 0 {
+				if err := m.Metadata.unmarshal(b); err != nil {
+					return err
+				}
+			}
+		case num == 6 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			m.CreatedTimestamp = int64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func unmarshalSample(data []byte, s *Sample) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(data)
+			s.Value = math.Float64frombits(v)
+		case num == 2 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			s.Timestamp = int64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (m *ExemplarV2) unmarshal(data []byte) error {
+	m.LabelsRefs = m.LabelsRefs[:0]
+	m.Value, m.Timestamp = 0, 0
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case num == 1 && (typ == protowire.BytesType || typ == protowire.VarintType):
+			m.LabelsRefs, n = consumeRefs(m.LabelsRefs, typ, data)
+		case num == 2 && typ == protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(data)
+			m.Value = math.Float64frombits(v)
+		case num == 3 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			m.Timestamp = int64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (m *MetadataV2) unmarshal(data []byte) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		var v uint64
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			m.Type = MetricMetadata_MetricType(v)
+		case num == 3 && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			m.HelpRef = uint32(v)
+		case num == 4 && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			m.UnitRef = uint32(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prompb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func appendPackedRefs(b []byte, num protowire.Number, refs ...uint64) []byte {
+	var packed []byte
+	for _, r := range refs {
+		packed = protowire.AppendVarint(packed, r)
+	}
+	return appendMessage(b, num, packed)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func encodeWriteRequestV2(symbols []string, refs ...uint64) []byte {
+	var req []byte
+	for _, s := range symbols {
+		req = appendMessage(req, 4, []byte(s))
+	}
+
+	var sample []byte
+	sample = appendDouble(sample, 1, 1.5)
+	sample = appendVarint(sample, 2, 1000)
+
+	var exemplar []byte
+	exemplar = appendPackedRefs(exemplar, 1, 5, 6)
+	exemplar = appendDouble(exemplar, 2, 0.3)
+	exemplar = appendVarint(exemplar, 3, 999)
+
+	var metadata []byte
+	metadata = appendVarint(metadata, 1, uint64(MetricMetadata_COUNTER))
+	metadata = appendVarint(metadata, 3, 7)
+
+	var ts []byte
+	ts = appendPackedRefs(ts, 1, refs...)
+	ts = appendMessage(ts, 2, sample)
+	ts = appendMessage(ts, 4, exemplar)
+	ts = appendMessage(ts, 5, metadata)
+	ts = appendVarint(ts, 6, 500)
+	// unknown fields are skipped
+	ts = appendVarint(ts, 100, 1)
+	return appendMessage(req, 5, ts)
+}
+
+func TestWriteRequestV2Unmarshal(t *testing.T) {
+	symbols := []string{"", "__name__", "requests_total", "job", "api", "trace_id", "abc", "total requests"}
+	req := &WriteRequestV2{}
+	if err := req.Unmarshal(encodeWriteRequestV2(symbols, 1, 2, 3, 4)); err != nil {
+		t.Fatalf("unmarshal failed: %s", err)
+	}
+	if !reflect.DeepEqual(req.Symbols, symbols) || len(req.Timeseries) != 1 {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	expected := TimeSeriesV2{
+		LabelsRefs:       []uint32{1, 2, 3, 4},
+		Samples:          []Sample{{Value: 1.5, Timestamp: 1000}},
+		Exemplars:        []ExemplarV2{{LabelsRefs: []uint32{5, 6}, Value: 0.3, Timestamp: 999}},
+		Metadata:         MetadataV2{Type: MetricMetadata_COUNTER, HelpRef: 7},
+		CreatedTimestamp: 500,
+	}
+	if !reflect.DeepEqual(req.Timeseries[0], expected) {
+		t.Errorf("expected %+v, got %+v", expected, req.Timeseries[0])
+	}
+
+	// the buffers are reused
+	if err := req.Unmarshal(encodeWriteRequestV2(symbols, 3, 4, 1, 2)); err != nil {
+		t.Fatalf("unmarshal failed: %s", err)
+	}
+	if len(req.Timeseries) != 1 || !reflect.DeepEqual(req.Timeseries[0].LabelsRefs, []uint32{3, 4, 1, 2}) || len(req.Timeseries[0].Samples) != 1 {
+		t.Errorf("unexpected time series after reused: %+v", req.Timeseries)
+	}
+}
+
+func TestWriteRequestV2Validate(t *testing.T) {
+	symbols := []string{"", "__name__", "requests_total", "job", "api", "trace_id", "abc", "total requests"}
+	req := &WriteRequestV2{}
+	if err := req.Unmarshal(encodeWriteRequestV2(symbols, 1, 2, 3, 8)); err == nil {
+		t.Error("expected error of ref out of range")
+	}
+	if err := req.Unmarshal(encodeWriteRequestV2(symbols, 1, 2, 3)); err == nil {
+		t.Error("expected error of odd refs")
+	}
+	if err := req.Unmarshal(encodeWriteRequestV2(append([]string{"x"}, symbols[1:]...), 1, 2)); err == nil {
+		t.Error("expected error of non-empty first symbol")
+	}
+}
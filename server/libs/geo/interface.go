@@ -16,6 +16,8 @@
 
 package geo
 
+import "net"
+
 type GeoInfo struct {
 	IPStart uint32
 	IPEnd   uint32
@@ -24,6 +26,18 @@ type GeoInfo struct {
 	ISP     uint8
 }
 
+// IPGeoInfo is the detailed geo information of an IPv4 or IPv6 address, empty
+// fields mean unknown
+type IPGeoInfo struct {
+	Country      string
+	Province     string
+	City         string
+	ASN          uint32
+	Organization string
+}
+
 type GeoTree interface {
 	Query(ip uint32) (uint8, uint8)
+	// QueryIP fills the geo information of ip into info, returns false if not found
+	QueryIP(ip net.IP, info *IPGeoInfo) bool
 }
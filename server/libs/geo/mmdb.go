@@ -0,0 +1,383 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// A minimal reader of the MaxMind DB file format, see
+// https://maxmind.github.io/MaxMind-DB/ for the specification.
+
+const (
+	MMDB_DATA_SECTION_SEPARATOR_SIZE = 16
+	MMDB_METADATA_MAX_SIZE           = 128 * 1024
+)
+
+var mmdbMetadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+var errMMDBInvalid = errors.New("invalid mmdb data")
+
+type MMDBMetadata struct {
+	NodeCount    uint32
+	RecordSize   uint32
+	IPVersion    uint32
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+type MMDBReader struct {
+	Metadata MMDBMetadata
+
+	buffer    []byte
+	data      []byte
+	nodeSize  uint32
+	ipv4Start uint32
+}
+
+func OpenMMDB(path string) (*MMDBReader, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewMMDBReader(buffer)
+	if err != nil {
+		return nil, fmt.Errorf("mmdb file %s: %s", path, err)
+	}
+	return r, nil
+}
+
+func NewMMDBReader(buffer []byte) (*MMDBReader, error) {
+	searchStart := 0
+	if len(buffer) > MMDB_METADATA_MAX_SIZE {
+		searchStart = len(buffer) - MMDB_METADATA_MAX_SIZE
+	}
+	index := bytes.LastIndex(buffer[searchStart:], mmdbMetadataStartMarker)
+	if index < 0 {
+		return nil, errors.New("metadata not found")
+	}
+	metadataStart := searchStart + index + len(mmdbMetadataStartMarker)
+
+	d := mmdbDecoder{buffer: buffer[metadataStart:]}
+	value, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("decode metadata failed: %s", err)
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("metadata is not a map")
+	}
+
+	r := &MMDBReader{buffer: buffer}
+	r.Metadata.NodeCount = uint32(mmdbUint(m["node_count"]))
+	r.Metadata.RecordSize = uint32(mmdbUint(m["record_size"]))
+	r.Metadata.IPVersion = uint32(mmdbUint(m["ip_version"]))
+	r.Metadata.DatabaseType, _ = m["database_type"].(string)
+	r.Metadata.BuildEpoch = mmdbUint(m["build_epoch"])
+
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported ip version %d", r.Metadata.IPVersion)
+	}
+	r.nodeSize = r.Metadata.RecordSize / 4
+	treeSize := int(r.Metadata.NodeCount) * int(r.nodeSize)
+	dataStart := treeSize + MMDB_DATA_SECTION_SEPARATOR_SIZE
+	if dataStart > metadataStart-len(mmdbMetadataStartMarker) {
+		return nil, errors.New("search tree exceeds the file size")
+	}
+	r.data = buffer[dataStart : metadataStart-len(mmdbMetadataStartMarker)]
+
+	// the IPv4 addresses are stored in ::/96 of IPv6 databases
+	if r.Metadata.IPVersion == 6 {
+		node := uint32(0)
+		for i := 0; i < 96 && node < r.Metadata.NodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+func (r *MMDBReader) readNode(node uint32, bit uint32) uint32 {
+	b := r.buffer[node*r.nodeSize:]
+	switch r.Metadata.RecordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	case 28:
+		if bit == 0 {
+			return uint32(b[3]&0xF0)<<20 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		}
+		return uint32(b[3]&0x0F)<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+	default:
+		return binary.BigEndian.Uint32(b[bit*4:])
+	}
+}
+
+// LookupOffset returns the offset of the record of ip in the data section,
+// returns false if ip is not found
+func (r *MMDBReader) LookupOffset(ip net.IP) (uint32, bool) {
+	bitCount := 128
+	node := uint32(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bitCount = 32
+		if r.Metadata.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.Metadata.IPVersion == 4 {
+		return 0, false
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := 0; i < bitCount && node < nodeCount; i++ {
+		bit := uint32(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = r.readNode(node, bit)
+	}
+	if node <= nodeCount {
+		return 0, false
+	}
+	offset := node - nodeCount - MMDB_DATA_SECTION_SEPARATOR_SIZE
+	if int(offset) >= len(r.data) {
+		return 0, false
+	}
+	return offset, true
+}
+
+// Decode decodes the record at offset of the data section
+func (r *MMDBReader) Decode(offset uint32) (interface{}, error) {
+	d := mmdbDecoder{buffer: r.data}
+	value, _, err := d.decode(int(offset))
+	return value, err
+}
+
+func (r *MMDBReader) Lookup(ip net.IP) (interface{}, bool, error) {
+	offset, ok := r.LookupOffset(ip)
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := r.Decode(offset)
+	return value, err == nil, err
+}
+
+type mmdbDecoder struct {
+	buffer []byte
+}
+
+func (d *mmdbDecoder) decodeCtrl(offset int) (int, int, int, error) {
+	if offset >= len(d.buffer) {
+		return 0, 0, 0, errMMDBInvalid
+	}
+	ctrl := d.buffer[offset]
+	offset++
+	dataType := int(ctrl >> 5)
+	if dataType == mmdbExtended {
+		if offset >= len(d.buffer) {
+			return 0, 0, 0, errMMDBInvalid
+		}
+		dataType = int(d.buffer[offset]) + 7
+		offset++
+	}
+	if dataType == mmdbPointer {
+		return dataType, int(ctrl), offset, nil
+	}
+
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > len(d.buffer) {
+			return 0, 0, 0, errMMDBInvalid
+		}
+		v := 0
+		for _, b := range d.buffer[offset : offset+n] {
+			v = v<<8 | int(b)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + v
+		case 30:
+			size = 285 + v
+		default:
+			size = 65821 + v
+		}
+	}
+	return dataType, size, offset, nil
+}
+
+func (d *mmdbDecoder) decodeUint(offset, size int) (uint64, int, error) {
+	if size > 8 || offset+size > len(d.buffer) {
+		return 0, 0, errMMDBInvalid
+	}
+	v := uint64(0)
+	for _, b := range d.buffer[offset : offset+size] {
+		v = v<<8 | uint64(b)
+	}
+	return v, offset + size, nil
+}
+
+func (d *mmdbDecoder) decodePointer(ctrl, offset int) (int, int, error) {
+	n := (ctrl>>3)&0x3 + 1
+	if offset+n > len(d.buffer) {
+		return 0, 0, errMMDBInvalid
+	}
+	v := 0
+	if n != 4 {
+		v = ctrl & 0x7
+	}
+	for _, b := range d.buffer[offset : offset+n] {
+		v = v<<8 | int(b)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+// decode returns the value at offset and the offset of the next value
+func (d *mmdbDecoder) decode(offset int) (interface{}, int, error) {
+	dataType, size, offset, err := d.decodeCtrl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if dataType == mmdbPointer {
+		pointer, next, err := d.decodePointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// a pointer may not point to another pointer, so the recursion is bounded
+		if pointer < len(d.buffer) && d.buffer[pointer]>>5 == mmdbPointer {
+			return nil, 0, errMMDBInvalid
+		}
+		value, _, err := d.decode(pointer)
+		return value, next, err
+	}
+
+	switch dataType {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			var key, value interface{}
+			if key, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errMMDBInvalid
+			}
+			if value, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			var value interface{}
+			if value, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > len(d.buffer) {
+		return nil, 0, errMMDBInvalid
+	}
+	switch dataType {
+	case mmdbString:
+		return string(d.buffer[offset : offset+size]), offset + size, nil
+	case mmdbBytes, mmdbUint128:
+		return d.buffer[offset : offset+size], offset + size, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errMMDBInvalid
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(d.buffer[offset:])), offset + size, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errMMDBInvalid
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(d.buffer[offset:]))), offset + size, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		return d.decodeUint(offset, size)
+	case mmdbInt32:
+		v, next, err := d.decodeUint(offset, size)
+		return int64(int32(v)), next, err
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", dataType)
+}
+
+func mmdbUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	case float64:
+		return uint64(n)
+	}
+	return 0
+}
+
+// mmdbGet returns the value of the path in the record, e.g. mmdbGet(record, "country", "names")
+func mmdbGet(v interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
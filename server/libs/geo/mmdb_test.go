@@ -0,0 +1,368 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type mmdbPointerTo int
+
+type mmdbTestNode struct {
+	children [2]int // >0: index of node, <0: -1-index of record, 0: empty
+}
+
+// mmdbTestWriter writes tiny mmdb files for testing
+type mmdbTestWriter struct {
+	ipVersion  int
+	recordSize int
+	nodes      []mmdbTestNode
+	records    []int // offsets in the data section
+	data       []byte
+}
+
+func newMMDBTestWriter(ipVersion, recordSize int) *mmdbTestWriter {
+	return &mmdbTestWriter{ipVersion: ipVersion, recordSize: recordSize, nodes: make([]mmdbTestNode, 1)}
+}
+
+func mmdbEncodeCtrl(dataType, size int) []byte {
+	var b []byte
+	var extra []byte
+	switch {
+	case size < 29:
+	case size < 285:
+		extra = []byte{byte(size - 29)}
+		size = 29
+	case size < 65821:
+		extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+		size = 30
+	default:
+		v := size - 65821
+		extra = []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+		size = 31
+	}
+	if dataType > 7 {
+		b = []byte{byte(size), byte(dataType - 7)}
+	} else {
+		b = []byte{byte(dataType<<5 | size)}
+	}
+	return append(b, extra...)
+}
+
+func mmdbEncodeUint(dataType int, v uint64) []byte {
+	var buf []byte
+	for ; v > 0; v >>= 8 {
+		buf = append([]byte{byte(v)}, buf...)
+	}
+	return append(mmdbEncodeCtrl(dataType, len(buf)), buf...)
+}
+
+func mmdbEncode(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(mmdbEncodeCtrl(mmdbString, len(v)), v...)
+	case uint16:
+		return mmdbEncodeUint(mmdbUint16, uint64(v))
+	case uint32:
+		return mmdbEncodeUint(mmdbUint32, uint64(v))
+	case uint64:
+		return mmdbEncodeUint(mmdbUint64, v)
+	case float64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(v))
+		return append(mmdbEncodeCtrl(mmdbDouble, 8), b...)
+	case bool:
+		if v {
+			return mmdbEncodeCtrl(mmdbBool, 1)
+		}
+		return mmdbEncodeCtrl(mmdbBool, 0)
+	case mmdbPointerTo:
+		// always use the 32 bits pointer
+		b := []byte{mmdbPointer<<5 | 3<<3, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(v))
+		return b
+	case []interface{}:
+		b := mmdbEncodeCtrl(mmdbArray, len(v))
+		for _, e := range v {
+			b = append(b, mmdbEncode(e)...)
+		}
+		return b
+	case map[string]interface{}:
+		b := mmdbEncodeCtrl(mmdbMap, len(v))
+		for k, e := range v {
+			b = append(b, mmdbEncode(k)...)
+			b = append(b, mmdbEncode(e)...)
+		}
+		return b
+	}
+	panic("unsupported type")
+}
+
+// addRecord adds the encoded record to the data section and returns its offset
+func (w *mmdbTestWriter) addRecord(encoded []byte) int {
+	offset := len(w.data)
+	w.data = append(w.data, encoded...)
+	w.records = append(w.records, offset)
+	return offset
+}
+
+func (w *mmdbTestWriter) insert(cidr string, record int) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	ip := ipNet.IP
+	prefixLen, _ := ipNet.Mask.Size()
+	if ip4 := ip.To4(); ip4 != nil {
+		if w.ipVersion == 6 {
+			// IPv4 addresses are in ::/96 rather than ::ffff:0:0/96
+			ip = append(make(net.IP, 12), ip4...)
+			prefixLen += 96
+		} else {
+			ip = ip4
+		}
+	}
+	node := 0
+	for i := 0; i < prefixLen; i++ {
+		bit := ip[i>>3] >> (7 - uint(i&7)) & 1
+		if i == prefixLen-1 {
+			w.nodes[node].children[bit] = -1 - record
+			return
+		}
+		next := w.nodes[node].children[bit]
+		if next <= 0 {
+			w.nodes = append(w.nodes, mmdbTestNode{})
+			next = len(w.nodes) - 1
+			w.nodes[node].children[bit] = next
+		}
+		node = next
+	}
+}
+
+func (w *mmdbTestWriter) bytes() []byte {
+	nodeCount := len(w.nodes)
+	value := func(child int) uint32 {
+		if child == 0 {
+			return uint32(nodeCount)
+		} else if child < 0 {
+			return uint32(nodeCount + MMDB_DATA_SECTION_SEPARATOR_SIZE + w.records[-1-child])
+		}
+		return uint32(child)
+	}
+	var buf []byte
+	for _, n := range w.nodes {
+		left, right := value(n.children[0]), value(n.children[1])
+		switch w.recordSize {
+		case 24:
+			buf = append(buf, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			buf = append(buf, byte(left>>16), byte(left>>8), byte(left), byte(left>>24<<4)|byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			buf = append(buf, byte(left>>24), byte(left>>16), byte(left>>8), byte(left))
+			buf = append(buf, byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		}
+	}
+	buf = append(buf, make([]byte, MMDB_DATA_SECTION_SEPARATOR_SIZE)...)
+	buf = append(buf, w.data...)
+	buf = append(buf, mmdbMetadataStartMarker...)
+	return append(buf, mmdbEncode(map[string]interface{}{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(w.recordSize),
+		"ip_version":                  uint16(w.ipVersion),
+		"database_type":               "Test",
+		"build_epoch":                 uint64(1700000000),
+		"languages":                   []interface{}{"en", "zh-CN"},
+		"binary_format_major_version": uint16(2),
+	})...)
+}
+
+func mmdbTestNames(en, zh string) map[string]interface{} {
+	return map[string]interface{}{"names": map[string]interface{}{"en": en, "zh-CN": zh}}
+}
+
+func newMMDBTestCity(recordSize int) []byte {
+	w := newMMDBTestWriter(6, recordSize)
+	country := mmdbEncode(mmdbTestNames("China", "中国"))
+	// the record begins with a map of 4 entries and the key "country"
+	head := append(mmdbEncodeCtrl(mmdbMap, 4), mmdbEncode("country")...)
+	tianjin := w.addRecord(append(append(append([]byte{}, head...), country...), mmdbEncode(map[string]interface{}{
+		"city":         mmdbTestNames("Tianjin", "天津"),
+		"subdivisions": []interface{}{mmdbTestNames("Tianjin", "天津")},
+		"location":     map[string]interface{}{"latitude": 39.14, "longitude": 117.18, "accuracy_radius": uint16(50)},
+	})[1:]...))
+	w.insert("1.2.3.0/24", 0)
+	w.addRecord(mmdbEncode(map[string]interface{}{
+		"registered_country": mmdbPointerTo(tianjin + len(head)),
+		"is_anycast":         true,
+	}))
+	w.insert("2001:db8::/32", 1)
+	return w.bytes()
+}
+
+func newMMDBTestASN(org string) []byte {
+	w := newMMDBTestWriter(4, 24)
+	w.addRecord(mmdbEncode(map[string]interface{}{
+		"autonomous_system_number":       uint32(4134),
+		"autonomous_system_organization": org,
+	}))
+	w.insert("1.2.0.0/16", 0)
+	return w.bytes()
+}
+
+func TestMMDBReader(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		r, err := NewMMDBReader(newMMDBTestCity(recordSize))
+		if err != nil {
+			t.Fatalf("record size %d: %s", recordSize, err)
+		}
+		if r.Metadata.IPVersion != 6 || r.Metadata.DatabaseType != "Test" || r.Metadata.BuildEpoch != 1700000000 {
+			t.Errorf("record size %d: got metadata %+v", recordSize, r.Metadata)
+		}
+		record, ok, err := r.Lookup(net.ParseIP("1.2.3.4"))
+		if !ok || err != nil {
+			t.Fatalf("record size %d: lookup 1.2.3.4 failed: %v", recordSize, err)
+		}
+		if name := mmdbGet(record, "city", "names", "en"); name != "Tianjin" {
+			t.Errorf("record size %d: got city %v", recordSize, name)
+		}
+		if v := mmdbGet(record, "location", "latitude"); v != 39.14 {
+			t.Errorf("record size %d: got latitude %v", recordSize, v)
+		}
+		record, ok, err = r.Lookup(net.ParseIP("2001:db8::1"))
+		if !ok || err != nil {
+			t.Fatalf("record size %d: lookup 2001:db8::1 failed: %v", recordSize, err)
+		}
+		if name := mmdbGet(record, "registered_country", "names", "en"); name != "China" {
+			t.Errorf("record size %d: got country %v through the pointer", recordSize, name)
+		}
+		if v := mmdbGet(record, "is_anycast"); v != true {
+			t.Errorf("record size %d: got is_anycast %v", recordSize, v)
+		}
+		for _, ip := range []string{"1.2.4.1", "8.8.8.8", "2001:db9::1", "::1"} {
+			if _, ok, _ := r.Lookup(net.ParseIP(ip)); ok {
+				t.Errorf("record size %d: %s should not be found", recordSize, ip)
+			}
+		}
+	}
+
+	r, err := NewMMDBReader(newMMDBTestASN("Chinanet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := r.Lookup(net.ParseIP("1.2.255.255")); !ok {
+		t.Errorf("1.2.255.255 should be found")
+	}
+	if _, ok, _ := r.Lookup(net.ParseIP("2001:db8::1")); ok {
+		t.Errorf("IPv6 address should not be found in IPv4 database")
+	}
+
+	for _, buffer := range [][]byte{nil, []byte("not a mmdb file"), newMMDBTestASN("Chinanet")[:20]} {
+		if _, err := NewMMDBReader(buffer); err == nil {
+			t.Errorf("invalid mmdb file %q should fail", buffer)
+		}
+	}
+}
+
+func TestMMDBDecodeSize(t *testing.T) {
+	for _, size := range []int{0, 28, 29, 284, 285, 65820, 65821, 70000} {
+		s := string(make([]byte, size))
+		d := mmdbDecoder{buffer: mmdbEncode(s)}
+		v, next, err := d.decode(0)
+		if err != nil || v != s || next != len(d.buffer) {
+			t.Errorf("size %d: got error %v next %d", size, err, next)
+		}
+	}
+	d := mmdbDecoder{buffer: mmdbEncode("truncated")[:5]}
+	if _, _, err := d.decode(0); err == nil {
+		t.Errorf("truncated data should fail")
+	}
+}
+
+func TestMMDBGeoTree(t *testing.T) {
+	dir := t.TempDir()
+	cityPath, asnPath := filepath.Join(dir, "city.mmdb"), filepath.Join(dir, "asn.mmdb")
+	if err := os.WriteFile(cityPath, newMMDBTestCity(28), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(asnPath, newMMDBTestASN("电信"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMMDBGeoTree([]string{cityPath, filepath.Join(dir, "missing.mmdb")}, "", 0); err == nil {
+		t.Errorf("missing file should fail")
+	}
+
+	tree, err := NewMMDBGeoTree([]string{cityPath, asnPath}, "zh-CN", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	expected := IPGeoInfo{Country: "中国", Province: "天津", City: "天津", ASN: 4134, Organization: "电信"}
+	for i := 0; i < 2; i++ {
+		info := IPGeoInfo{}
+		if !tree.QueryIP(net.ParseIP("1.2.3.4"), &info) || info != expected {
+			t.Errorf("got %+v, expected %+v", info, expected)
+		}
+	}
+	region, isp := tree.Query(binary.BigEndian.Uint32([]byte{1, 2, 3, 4}))
+	if DecodeRegion(region) != "天津" || DecodeISP(isp) != "电信" {
+		t.Errorf("got region %s isp %s", DecodeRegion(region), DecodeISP(isp))
+	}
+	info := IPGeoInfo{}
+	if !tree.QueryIP(net.ParseIP("1.2.4.4"), &info) || info != (IPGeoInfo{ASN: 4134, Organization: "电信"}) {
+		t.Errorf("got %+v for 1.2.4.4", info)
+	}
+	info = IPGeoInfo{}
+	if !tree.QueryIP(net.ParseIP("2001:db8::1"), &info) || info != (IPGeoInfo{Country: "中国"}) {
+		t.Errorf("got %+v for 2001:db8::1", info)
+	}
+	if tree.QueryIP(net.ParseIP("8.8.8.8"), &IPGeoInfo{}) {
+		t.Errorf("8.8.8.8 should not be found")
+	}
+
+	if tree.reloadIfModified() {
+		t.Errorf("files are not modified")
+	}
+	if err := os.WriteFile(asnPath, newMMDBTestASN("Chinanet"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(asnPath, future, future)
+	if !tree.reloadIfModified() {
+		t.Errorf("files are modified")
+	}
+	info = IPGeoInfo{}
+	if tree.QueryIP(net.ParseIP("1.2.3.4"), &info); info.Organization != "Chinanet" {
+		t.Errorf("got organization %s after reload", info.Organization)
+	}
+
+	// the broken file is ignored and the old ones are kept
+	os.WriteFile(asnPath, []byte("broken"), 0644)
+	if tree.reloadIfModified() {
+		t.Errorf("broken file should not be loaded")
+	}
+	info = IPGeoInfo{}
+	if tree.QueryIP(net.ParseIP("1.2.3.4"), &info); info.Organization != "Chinanet" {
+		t.Errorf("got organization %s after reload failed", info.Organization)
+	}
+}
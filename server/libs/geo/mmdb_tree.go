@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const MMDB_DEFAULT_LANGUAGE = "en"
+
+type mmdbFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+	reader  *MMDBReader
+	// the decoded records indexed by the offset in the data section, the
+	// number of records is bounded by the size of the mmdb file
+	cache sync.Map
+}
+
+// MMDBGeoTree queries the geo information from MaxMind DB files, such as
+// GeoLite2-City.mmdb and GeoLite2-ASN.mmdb, the results of all files are
+// merged. The files are reloaded when they are modified.
+type MMDBGeoTree struct {
+	paths    []string
+	language string
+	files    atomic.Value // []*mmdbFile
+	exit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewMMDBGeoTree(paths []string, language string, reloadInterval time.Duration) (*MMDBGeoTree, error) {
+	if language == "" {
+		language = MMDB_DEFAULT_LANGUAGE
+	}
+	t := &MMDBGeoTree{
+		paths:    paths,
+		language: language,
+		exit:     make(chan struct{}),
+	}
+	files, err := loadMMDBFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	t.files.Store(files)
+	for _, f := range files {
+		log.Infof("load mmdb file %s, database type %s, build epoch %d", f.path, f.reader.Metadata.DatabaseType, f.reader.Metadata.BuildEpoch)
+	}
+
+	if reloadInterval > 0 {
+		t.wg.Add(1)
+		go t.run(reloadInterval)
+	}
+	return t, nil
+}
+
+func loadMMDBFiles(paths []string) ([]*mmdbFile, error) {
+	files := make([]*mmdbFile, 0, len(paths))
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := OpenMMDB(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &mmdbFile{path: path, size: stat.Size(), modTime: stat.ModTime(), reader: reader})
+	}
+	return files, nil
+}
+
+func (t *MMDBGeoTree) run(reloadInterval time.Duration) {
+	defer t.wg.Done()
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.exit:
+			return
+		case <-ticker.C:
+			t.reloadIfModified()
+		}
+	}
+}
+
+func (t *MMDBGeoTree) reloadIfModified() bool {
+	files := t.files.Load().([]*mmdbFile)
+	modified := false
+	for _, f := range files {
+		stat, err := os.Stat(f.path)
+		if err != nil {
+			// the file may be being replaced, check it next time
+			log.Warningf("stat mmdb file %s failed: %s", f.path, err)
+			return false
+		}
+		if stat.Size() != f.size || !stat.ModTime().Equal(f.modTime) {
+			modified = true
+		}
+	}
+	if !modified {
+		return false
+	}
+	newFiles, err := loadMMDBFiles(t.paths)
+	if err != nil {
+		log.Warningf("reload mmdb files failed, keep using the old ones: %s", err)
+		return false
+	}
+	t.files.Store(newFiles)
+	log.Infof("reload mmdb files %v", t.paths)
+	return true
+}
+
+func (t *MMDBGeoTree) Close() {
+	close(t.exit)
+	t.wg.Wait()
+}
+
+func (t *MMDBGeoTree) Query(ip uint32) (uint8, uint8) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], ip)
+	info := IPGeoInfo{}
+	if !t.QueryIP(net.IP(buf[:]), &info) {
+		return 0, 0
+	}
+	return EncodeRegion(info.Province), EncodeISP(info.Organization)
+}
+
+func (t *MMDBGeoTree) QueryIP(ip net.IP, info *IPGeoInfo) bool {
+	found := false
+	for _, f := range t.files.Load().([]*mmdbFile) {
+		offset, ok := f.reader.LookupOffset(ip)
+		if !ok {
+			continue
+		}
+		var record *IPGeoInfo
+		if v, ok := f.cache.Load(offset); ok {
+			record = v.(*IPGeoInfo)
+		} else {
+			value, err := f.reader.Decode(offset)
+			if err != nil {
+				log.Debugf("decode record of %s in %s failed: %s", ip, f.path, err)
+				continue
+			}
+			record = t.toGeoInfo(value)
+			f.cache.Store(offset, record)
+		}
+		mergeGeoInfo(info, record)
+		found = true
+	}
+	return found
+}
+
+func (t *MMDBGeoTree) name(record interface{}, path ...string) string {
+	names, ok := mmdbGet(record, append(path, "names")...).(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if name, ok := names[t.language].(string); ok {
+		return name
+	}
+	name, _ := names[MMDB_DEFAULT_LANGUAGE].(string)
+	return name
+}
+
+// toGeoInfo extracts the fields of the City, Country, ASN and ISP databases
+func (t *MMDBGeoTree) toGeoInfo(record interface{}) *IPGeoInfo {
+	info := &IPGeoInfo{
+		Country: t.name(record, "country"),
+		City:    t.name(record, "city"),
+		ASN:     uint32(mmdbUint(mmdbGet(record, "autonomous_system_number"))),
+	}
+	if info.Country == "" {
+		info.Country = t.name(record, "registered_country")
+	}
+	if subdivisions, ok := mmdbGet(record, "subdivisions").([]interface{}); ok && len(subdivisions) > 0 {
+		info.Province = t.name(subdivisions[0])
+	}
+	for _, key := range []string{"autonomous_system_organization", "organization", "isp"} {
+		if org, ok := mmdbGet(record, key).(string); ok && org != "" {
+			info.Organization = org
+			break
+		}
+	}
+	return info
+}
+
+func mergeGeoInfo(dst, src *IPGeoInfo) {
+	if dst.Country == "" {
+		dst.Country = src.Country
+	}
+	if dst.Province == "" {
+		dst.Province = src.Province
+	}
+	if dst.City == "" {
+		dst.City = src.City
+	}
+	if dst.ASN == 0 {
+		dst.ASN = src.ASN
+	}
+	if dst.Organization == "" {
+		dst.Organization = src.Organization
+	}
+}
@@ -16,6 +16,11 @@
 
 package geo
 
+import (
+	"encoding/binary"
+	"net"
+)
+
 const MIN_MASKLEN = 16
 const MAX_MASKLEN = 32
 
@@ -99,3 +104,21 @@ func (t *netmaskTree) Query(ip uint32) (uint8, uint8) {
 	}
 	return 0, 0
 }
+
+func (t *netmaskTree) QueryIP(ip net.IP, info *IPGeoInfo) bool {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return false
+	}
+	region, isp := t.Query(binary.BigEndian.Uint32(ip4))
+	if region == 0 && isp == 0 {
+		return false
+	}
+	if region != 0 {
+		info.Province = DecodeRegion(region)
+	}
+	if isp != 0 {
+		info.Organization = DecodeISP(isp)
+	}
+	return true
+}
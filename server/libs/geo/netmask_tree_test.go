@@ -18,6 +18,7 @@ package geo
 
 import (
 	"encoding/binary"
+	"net"
 	"testing"
 )
 
@@ -59,4 +60,11 @@ func TestNetmaskGeoTree(t *testing.T) {
 	if DecodeRegion(region) != "天津" || DecodeISP(isp) != "移动" {
 		t.Error("查询结果不正确")
 	}
+	info := IPGeoInfo{}
+	if !tree.QueryIP(net.IPv4(223, 103, 7, 0), &info) || info.Province != "天津" || info.Organization != "移动" {
+		t.Error("查询结果不正确")
+	}
+	if tree.QueryIP(net.ParseIP("2001:db8::1"), &IPGeoInfo{}) {
+		t.Error("IPv6地址不应查询到结果")
+	}
 }
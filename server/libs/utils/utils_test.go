@@ -18,7 +18,9 @@ package utils
 
 import (
 	"net"
+	"reflect"
 	"testing"
+	"unsafe"
 )
 
 func TestIPv4ToBinary(t *testing.T) {
@@ -34,3 +36,32 @@ func TestIPv6ToBinary(t *testing.T) {
 		t.Errorf("IPv6ToBinary处理不正确，expect %v, return %v", expect, ret)
 	}
 }
+
+func TestGetValueByOffsetAndKindSlice(t *testing.T) {
+	item := struct {
+		Names  []string
+		Values []float64
+		Ints   []int64
+	}{[]string{"a"}, []float64{1.5}, []int64{-1, 2}}
+	base := uintptr(unsafe.Pointer(&item))
+
+	testCases := []struct {
+		dataTypeStr string
+		offset      uintptr
+		expected    interface{}
+	}{
+		{"[]string", unsafe.Offsetof(item.Names), item.Names},
+		{"[]float64", unsafe.Offsetof(item.Values), item.Values},
+		{"[]int64", unsafe.Offsetof(item.Ints), item.Ints},
+	}
+	for _, c := range testCases {
+		dataType := ToDataType(c.dataTypeStr)
+		if dataType == DATATYPE_INVALID {
+			t.Errorf("ToDataType(%s) is invalid", c.dataTypeStr)
+			continue
+		}
+		if ret := GetValueByOffsetAndKind(base, c.offset, reflect.Slice, dataType); !reflect.DeepEqual(ret, c.expected) {
+			t.Errorf("GetValueByOffsetAndKind of %s, expect %v, return %v", c.dataTypeStr, c.expected, ret)
+		}
+	}
+}
@@ -25,9 +25,21 @@ const (
 )
 
 const (
-	DATABASE_FLOW_LOG = "flow_log"
-	TABLE_L7_FLOW_LOG = "l7_flow_log"
-	TAG_TRACE_ID      = "trace_id"
+	DATABASE_FLOW_LOG        = "flow_log"
+	DATABASE_FLOW_TAG        = "flow_tag"
+	TABLE_L7_FLOW_LOG        = "l7_flow_log"
+	TABLE_TRACE_TREE         = "trace_tree"
+	TABLE_SPAN_WITH_TRACE_ID = "span_with_trace_id"
+	TAG_TRACE_ID             = "trace_id"
+	TAG_SEARCH_INDEX         = "search_index"
+	TAG_ENCODED_SPAN         = "encoded_span"
+	TAG_ENCODED_SPAN_LIST    = "encoded_span_list"
+)
+
+// the auto services without resources, the ip is used as the name
+const (
+	AUTO_SERVICE_TYPE_INTERNET_IP = 0
+	AUTO_SERVICE_TYPE_IP          = 255
 )
 
 const (
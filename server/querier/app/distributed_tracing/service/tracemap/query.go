@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracemap
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+)
+
+var log = logging.MustGetLogger("tracemap")
+
+// query executes the sql in ClickHouse directly, the debug of the query is appended to debugs if it is not nil
+func query(ctx context.Context, db, sql, orgID string, debugs *[]client.Debug) (*querier_common.Result, error) {
+	chClient := client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       db,
+		Context:  ctx,
+		Debug:    client.NewDebug(sql),
+	}
+	result, err := chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: orgID})
+	if debugs != nil {
+		*debugs = append(*debugs, *chClient.Debug)
+	}
+	return result, err
+}
+
+// orgDatabase returns the database name of the organization, e.g.: 0002_flow_log
+func orgDatabase(orgID, db string) string {
+	id, err := strconv.Atoi(orgID)
+	if err != nil {
+		return db
+	}
+	return ckdb.OrgDatabasePrefix(uint16(id)) + db
+}
+
+// quoteStrings formats the strings as the elements of an IN expression, e.g.: 'a','b'
+func quoteStrings(values []string) string {
+	var sb strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('\'')
+		for _, c := range []byte(v) {
+			if c == '\'' || c == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		}
+		sb.WriteByte('\'')
+	}
+	return sb.String()
+}
@@ -13,16 +13,448 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
+
 package tracemap
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 
+	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/common"
 	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/model"
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
 	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	"github.com/deepflowio/deepflow/server/querier/router"
+)
+
+// the dictionary keys of the icons of the auto services without resources
+const (
+	internetIconDictKey = 63999
+	ipIconDictKey       = 64000
 )
 
+// a side of an edge of the trace map
+type traceMapNode struct {
+	autoServiceType uint8
+	autoServiceID   uint32
+	ip              string
+	appService      string
+	layer           int32
+}
+
+type traceMapEdgeKey struct {
+	client traceMapNode
+	server traceMapNode
+}
+
+type traceMapEdge struct {
+	responseTotal                  uint32
+	responseStatusServerErrorCount uint32
+	responseDurationSum            uint64
+}
+
+type autoServiceKey struct {
+	autoServiceType uint8
+	autoServiceID   uint32
+}
+
+type autoServiceInfo struct {
+	name     string
+	iconID   int
+	nodeType string
+}
+
+// TraceMap queries the trace trees of the traces in the time range, and aggregates them to the edges between the
+// services. The traces are queried in several iterations, the edges of every batch of traces are written to the
+// response as a line of json once they are aggregated, the client should sum up the edges with the same uids.
 func TraceMap(args model.TraceMap, cfg *config.QuerierConfig, c *gin.Context, done chan bool, generator *TraceMapGenerator) {
-	done <- true
-	return
+	defer func() { done <- true }()
+
+	if args.OrgID == "" {
+		args.OrgID = querier_common.DEFAULT_ORG_ID
+	}
+	if args.Context == nil {
+		args.Context = context.Background()
+	}
+	m := &traceMapper{args: &args, cfg: cfg, c: c, traceIDs: make(map[string]struct{})}
+	if err := m.run(); err != nil {
+		log.Errorf("trace map failed: %s", err)
+		m.write(nil, err)
+	}
+}
+
+type traceMapper struct {
+	args     *model.TraceMap
+	cfg      *config.QuerierConfig
+	c        *gin.Context
+	debugs   []client.Debug
+	traceIDs map[string]struct{} // the traces which have been aggregated
+}
+
+func (m *traceMapper) run() error {
+	iterations := int(m.cfg.Tracemap.TraceIdQueryIterations)
+	if iterations <= 0 {
+		iterations = 1
+	}
+	timeStart, timeEnd := m.args.TimeStart, m.args.TimeEnd
+	step := (timeEnd - timeStart + iterations) / iterations
+	if step <= 0 {
+		step = 1
+	}
+	// the latest traces are returned first
+	for end := timeEnd; end >= timeStart; end -= step {
+		start := end - step + 1
+		if start < timeStart {
+			start = timeStart
+		}
+		if err := m.args.Context.Err(); err != nil {
+			return err
+		}
+		traceIDs, err := m.queryTraceIDs(start, end)
+		if err != nil {
+			return err
+		}
+		batchSize := int(m.cfg.Tracemap.BatchTracesCountMax)
+		if batchSize <= 0 {
+			batchSize = len(traceIDs)
+		}
+		for i := 0; i < len(traceIDs); i += batchSize {
+			j := i + batchSize
+			if j > len(traceIDs) {
+				j = len(traceIDs)
+			}
+			result, err := m.queryTraceMap(traceIDs[i:j], start, end)
+			if err != nil {
+				return err
+			}
+			m.write(result, nil)
+		}
+	}
+	return nil
+}
+
+// queryTraceIDs returns the traces in [start, end] which have not been aggregated
+func (m *traceMapper) queryTraceIDs(start, end int) ([]string, error) {
+	var result *querier_common.Result
+	if m.args.QueryCondition == "" {
+		db := orgDatabase(m.args.OrgID, common.DATABASE_FLOW_LOG)
+		sql := fmt.Sprintf(
+			"SELECT %s FROM %s.%s WHERE time>=%d AND time<=%d GROUP BY %s LIMIT %d",
+			common.TAG_TRACE_ID, db, common.TABLE_TRACE_TREE, start, end, common.TAG_TRACE_ID, m.cfg.Tracemap.MaxTracePerIteration,
+		)
+		var err error
+		result, err = query(m.args.Context, db, sql, m.args.OrgID, &m.debugs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// the query condition is filtered by the querier engine, so that the tags could be used in it
+		sql := fmt.Sprintf(
+			"SELECT %s FROM %s WHERE time>=%d AND time<=%d AND %s!='' AND (%s) GROUP BY %s LIMIT %d",
+			common.TAG_TRACE_ID, common.TABLE_L7_FLOW_LOG, start, end, common.TAG_TRACE_ID, m.args.QueryCondition, common.TAG_TRACE_ID, m.cfg.Tracemap.MaxTracePerIteration,
+		)
+		ckEngine := &clickhouse.CHEngine{DB: common.DATABASE_FLOW_LOG}
+		ckEngine.Init()
+		var debug map[string]interface{}
+		var err error
+		result, debug, err = ckEngine.ExecuteQuery(&querier_common.QuerierParams{
+			DB:      common.DATABASE_FLOW_LOG,
+			Sql:     sql,
+			Debug:   strconv.FormatBool(m.args.Debug),
+			Context: m.args.Context,
+			ORGID:   m.args.OrgID,
+		})
+		if sqls, ok := debug["query_sqls"].([]client.Debug); ok {
+			m.debugs = append(m.debugs, sqls...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	traceIDs := make([]string, 0, len(result.Values))
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) == 0 {
+			continue
+		}
+		traceID, _ := row[0].(string)
+		if _, ok := m.traceIDs[traceID]; ok || traceID == "" {
+			continue
+		}
+		m.traceIDs[traceID] = struct{}{}
+		traceIDs = append(traceIDs, traceID)
+	}
+	return traceIDs, nil
+}
+
+// queryTraceMap aggregates the trace trees of the traces to the edges
+func (m *traceMapper) queryTraceMap(traceIDs []string, start, end int) ([]*model.RawTraceMap, error) {
+	if len(traceIDs) == 0 {
+		return nil, nil
+	}
+	searchIndexes := make([]string, len(traceIDs))
+	for i, traceID := range traceIDs {
+		searchIndexes[i] = strconv.FormatUint(tracetree.HashSearchIndex(traceID), 10)
+	}
+	// a trace tree is written at the start time of the trace, which may be earlier than the time of the trace id
+	delta := int(m.cfg.Tracemap.TraceQueryDelta)
+	db := orgDatabase(m.args.OrgID, common.DATABASE_FLOW_LOG)
+	sql := fmt.Sprintf(
+		"SELECT %s FROM %s.%s WHERE time>=%d AND time<=%d AND %s IN (%s) AND %s IN (%s) LIMIT 1 BY %s",
+		common.TAG_ENCODED_SPAN_LIST, db, common.TABLE_TRACE_TREE, start-delta, end+delta,
+		common.TAG_SEARCH_INDEX, strings.Join(searchIndexes, ","), common.TAG_TRACE_ID, quoteStrings(traceIDs), common.TAG_TRACE_ID,
+	)
+	result, err := query(m.args.Context, db, sql, m.args.OrgID, &m.debugs)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make(map[traceMapEdgeKey]*traceMapEdge)
+	decoder := &codec.SimpleDecoder{}
+	tree := tracetree.AcquireTraceTree()
+	defer tracetree.ReleaseTraceTree(tree)
+	for _, value := range result.Values {
+		encoded, _ := value.([]interface{})[0].(string)
+		decoder.Init([]byte(encoded))
+		if err := tree.Decode(decoder); err != nil {
+			log.Debugf("decode trace tree failed: %s", err)
+			continue
+		}
+		aggregateTraceTree(tree, edges)
+	}
+	return m.toRawTraceMap(edges)
+}
+
+// aggregateTraceTree adds the requests of the nodes to the edges from their parent nodes, the client side of the
+// edges of the root nodes are empty
+func aggregateTraceTree(tree *tracetree.TraceTree, edges map[traceMapEdgeKey]*traceMapEdge) {
+	// the levels of the nodes are not stored in the trace tree
+	layers := make([]int32, len(tree.TreeNodes))
+	for i := range layers {
+		layers[i] = -1
+	}
+	var layer func(i int32, depth int) int32
+	layer = func(i int32, depth int) int32 {
+		if layers[i] >= 0 {
+			return layers[i]
+		}
+		parent := tree.TreeNodes[i].ParentNodeIndex
+		if parent < 0 || int(parent) >= len(tree.TreeNodes) || depth >= len(tree.TreeNodes) {
+			layers[i] = 0
+		} else {
+			layers[i] = layer(parent, depth+1) + 1
+		}
+		return layers[i]
+	}
+
+	for i := range tree.TreeNodes {
+		node := &tree.TreeNodes[i]
+		var key traceMapEdgeKey
+		if parent := node.ParentNodeIndex; parent >= 0 && int(parent) < len(tree.TreeNodes) {
+			key.client = newTraceMapNode(&tree.TreeNodes[parent], layer(parent, 0))
+		} else if node.ResponseTotal == 0 {
+			continue
+		}
+		key.server = newTraceMapNode(node, layer(int32(i), 0))
+		edge, ok := edges[key]
+		if !ok {
+			edge = &traceMapEdge{}
+			edges[key] = edge
+		}
+		edge.responseTotal += node.ResponseTotal
+		edge.responseStatusServerErrorCount += node.ResponseStatusServerErrorCount
+		edge.responseDurationSum += node.ResponseDurationSum
+	}
+}
+
+func newTraceMapNode(node *tracetree.TreeNode, layer int32) traceMapNode {
+	n := traceMapNode{
+		autoServiceType: node.NodeInfo.AutoServiceType,
+		autoServiceID:   node.NodeInfo.AutoServiceID,
+		appService:      node.NodeInfo.AppService,
+		layer:           layer,
+	}
+	// the auto services without resources are distinguished by the ip
+	if isIPAutoService(n.autoServiceType) {
+		n.ip = nodeIP(&node.NodeInfo).String()
+	}
+	return n
+}
+
+func isIPAutoService(autoServiceType uint8) bool {
+	return autoServiceType == common.AUTO_SERVICE_TYPE_INTERNET_IP || autoServiceType == common.AUTO_SERVICE_TYPE_IP
+}
+
+func (m *traceMapper) toRawTraceMap(edges map[traceMapEdgeKey]*traceMapEdge) ([]*model.RawTraceMap, error) {
+	if len(edges) == 0 {
+		return nil, nil
+	}
+	services := make(map[autoServiceKey]*autoServiceInfo)
+	for key := range edges {
+		if key.client != (traceMapNode{}) {
+			services[autoServiceDictKey(key.client.autoServiceType, key.client.autoServiceID)] = nil
+		}
+		services[autoServiceDictKey(key.server.autoServiceType, key.server.autoServiceID)] = nil
+	}
+	if err := m.queryAutoServices(services); err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.RawTraceMap, 0, len(edges))
+	for key, edge := range edges {
+		serverInfo := services[autoServiceDictKey(key.server.autoServiceType, key.server.autoServiceID)]
+		r := &model.RawTraceMap{
+			AutoServiceId0:                 uint(key.client.autoServiceID),
+			AutoServiceId1:                 uint(key.server.autoServiceID),
+			AutoServiceType0:               uint(key.client.autoServiceType),
+			AutoServiceType1:               uint(key.server.autoServiceType),
+			ResponseTotal:                  uint(edge.responseTotal),
+			ResponseStatusServerErrorCount: uint(edge.responseStatusServerErrorCount),
+			ResponseDurationSum:            edge.responseDurationSum,
+			IP0:                            key.client.ip,
+			IP1:                            key.server.ip,
+			AppService0:                    key.client.appService,
+			AppService1:                    key.server.appService,
+		}
+		if key.client != (traceMapNode{}) {
+			clientInfo := services[autoServiceDictKey(key.client.autoServiceType, key.client.autoServiceID)]
+			r.AutoService0, r.ClientIconId, r.ClientNodeType = autoServiceName(&key.client, clientInfo), clientInfo.iconID, clientInfo.nodeType
+			r.Uid0 = traceMapNodeUid(&key.client, r.AutoService0)
+		}
+		r.AutoService1, r.ServerIconId, r.ServerNodeType = autoServiceName(&key.server, serverInfo), serverInfo.iconID, serverInfo.nodeType
+		r.Uid1 = traceMapNodeUid(&key.server, r.AutoService1)
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// the auto services without resources use the same icons
+func autoServiceDictKey(autoServiceType uint8, autoServiceID uint32) autoServiceKey {
+	switch autoServiceType {
+	case common.AUTO_SERVICE_TYPE_INTERNET_IP:
+		return autoServiceKey{autoServiceType, internetIconDictKey}
+	case common.AUTO_SERVICE_TYPE_IP:
+		return autoServiceKey{autoServiceType, ipIconDictKey}
+	}
+	return autoServiceKey{autoServiceType, autoServiceID}
+}
+
+func autoServiceName(n *traceMapNode, info *autoServiceInfo) string {
+	if isIPAutoService(n.autoServiceType) {
+		return n.ip
+	}
+	return info.name
+}
+
+// encoding: auto_service_type+auto_service_id+auto_service+app_service+layer
+func traceMapNodeUid(n *traceMapNode, autoService string) string {
+	return fmt.Sprintf("%d-%d-%s-%s-%d", n.autoServiceType, n.autoServiceID, autoService, n.appService, n.layer)
+}
+
+// queryAutoServices fills in the names, icons and node types of the auto services from the dictionaries
+func (m *traceMapper) queryAutoServices(services map[autoServiceKey]*autoServiceInfo) error {
+	keys := make([]string, 0, len(services))
+	for key := range services {
+		services[key] = &autoServiceInfo{}
+		keys = append(keys, fmt.Sprintf("(%d,%d)", key.autoServiceType, key.autoServiceID))
+	}
+	sql := fmt.Sprintf(
+		"SELECT tupleElement(k,1) AS auto_service_type, tupleElement(k,2) AS auto_service_id, "+
+			"dictGet('%s.device_map', 'name', (toUInt64(if(auto_service_type IN (%d,%d),auto_service_id,auto_service_type)),toUInt64(auto_service_id))) AS name, "+
+			"dictGet('%s.device_map', 'icon_id', (toUInt64(if(auto_service_type IN (%d,%d),auto_service_id,auto_service_type)),toUInt64(auto_service_id))) AS icon_id, "+
+			"dictGet('%s.node_type_map', 'node_type', toUInt64(auto_service_type)) AS node_type "+
+			"FROM (SELECT arrayJoin([%s]) AS k)",
+		common.DATABASE_FLOW_TAG, common.AUTO_SERVICE_TYPE_INTERNET_IP, common.AUTO_SERVICE_TYPE_IP,
+		common.DATABASE_FLOW_TAG, common.AUTO_SERVICE_TYPE_INTERNET_IP, common.AUTO_SERVICE_TYPE_IP,
+		common.DATABASE_FLOW_TAG, strings.Join(keys, ","),
+	)
+	result, err := query(m.args.Context, common.DATABASE_FLOW_TAG, sql, m.args.OrgID, &m.debugs)
+	if err != nil {
+		return err
+	}
+	for _, value := range result.Values {
+		row := value.([]interface{})
+		if len(row) < 5 {
+			continue
+		}
+		key := autoServiceKey{uint8(toUint64(row[0])), uint32(toUint64(row[1]))}
+		info, ok := services[key]
+		if !ok {
+			continue
+		}
+		info.name, _ = row[2].(string)
+		info.iconID = int(toUint64(row[3]))
+		info.nodeType, _ = row[4].(string)
+	}
+	return nil
+}
+
+func toUint64(value interface{}) uint64 {
+	switch v := value.(type) {
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case int8:
+		return uint64(v)
+	case int16:
+		return uint64(v)
+	case int32:
+		return uint64(v)
+	case int64:
+		return uint64(v)
+	}
+	return 0
+}
+
+// write writes a line of json response and flushes it to the client
+func (m *traceMapper) write(result []*model.RawTraceMap, err error) {
+	resp := router.Response{OptStatus: common.SUCCESS, Result: result}
+	if err != nil {
+		resp.OptStatus, resp.Description = common.FAIL, err.Error()
+	}
+	if result == nil {
+		resp.Result = []*model.RawTraceMap{}
+	}
+	if m.args.Debug {
+		resp.Debug = m.trimDebugs()
+	}
+	m.debugs = m.debugs[:0]
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Errorf("marshal trace map response failed: %s", err)
+		return
+	}
+	m.c.Writer.Write(append(data, '\n'))
+	m.c.Writer.Flush()
+}
+
+func (m *traceMapper) trimDebugs() map[string]interface{} {
+	debugs := make([]client.Debug, len(m.debugs))
+	copy(debugs, m.debugs)
+	if sqlLenMax := m.cfg.Tracemap.DebugSqlLenMax; sqlLenMax > 0 {
+		for i := range debugs {
+			if len(debugs[i].Sql) > sqlLenMax {
+				debugs[i].Sql = debugs[i].Sql[:sqlLenMax] + "..."
+			}
+		}
+	}
+	return map[string]interface{}{"query_sqls": debugs}
 }
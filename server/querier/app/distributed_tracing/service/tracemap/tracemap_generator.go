@@ -13,22 +13,171 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
+
 package tracemap
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	controller_common "github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/codec"
 	"github.com/deepflowio/deepflow/server/libs/queue"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/common"
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
 	"github.com/deepflowio/deepflow/server/querier/config"
 )
 
+// TraceMapGenerator builds the trace trees from `flow_log.span_with_trace_id` periodically, and puts them to
+// the shared queue which is written to `flow_log.trace_tree` by the ingester.
 type TraceMapGenerator struct {
 	sharedQueue *queue.OverwriteQueue
 	cfg         *config.QuerierConfig
+
+	builder *traceTreeBuilder
+	lastEnd map[string]uint32 // org id -> the end time of the last generation
 }
 
 func NewTraceMapGenerator(sharedQueue *queue.OverwriteQueue, cfg *config.QuerierConfig) *TraceMapGenerator {
-	return &TraceMapGenerator{sharedQueue: sharedQueue, cfg: cfg}
+	return &TraceMapGenerator{
+		sharedQueue: sharedQueue,
+		cfg:         cfg,
+		builder:     newTraceTreeBuilder(),
+		lastEnd:     make(map[string]uint32),
+	}
 }
 
 func (g *TraceMapGenerator) Start() {
+	if g.sharedQueue == nil || g.cfg.Tracemap.WriteInterval <= 0 {
+		return
+	}
+	go g.run()
+}
+
+func (g *TraceMapGenerator) run() {
+	log.Infof("trace map generator starting, write interval %ds", g.cfg.Tracemap.WriteInterval)
+	ticker := time.NewTicker(time.Duration(g.cfg.Tracemap.WriteInterval) * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		// the spans of a trace may arrive later, only generate the traces which are older than trace_query_delta
+		end := uint32(time.Now().Unix()) - uint32(g.cfg.Tracemap.TraceQueryDelta)
+		for _, orgID := range g.getOrgIDs() {
+			start, ok := g.lastEnd[orgID]
+			if !ok || start >= end {
+				start = end - uint32(g.cfg.Tracemap.WriteInterval)
+			}
+			if err := g.generate(orgID, start, end); err != nil {
+				log.Warningf("generate trace tree of org %s in [%d, %d) failed: %s", orgID, start, end, err)
+				continue
+			}
+			g.lastEnd[orgID] = end
+		}
+	}
+}
+
+func (g *TraceMapGenerator) getOrgIDs() []string {
+	getOrgUrl := fmt.Sprintf("http://localhost:%d/v1/orgs/", config.ControllerCfg.ListenPort)
+	resp, err := controller_common.CURLPerform("GET", getOrgUrl, nil)
+	if err != nil {
+		log.Warningf("request controller failed: %s, URL: %s", resp, getOrgUrl)
+		return []string{querier_common.DEFAULT_ORG_ID}
+	}
+	orgs := resp.Get("DATA").MustArray()
+	orgIDs := make([]string, 0, len(orgs))
+	for i := range orgs {
+		orgIDs = append(orgIDs, strconv.Itoa(resp.Get("DATA").GetIndex(i).Get("ORG_ID").MustInt()))
+	}
+	return orgIDs
+}
+
+// generate builds the trace trees of the traces starting in [start, end)
+func (g *TraceMapGenerator) generate(orgID string, start, end uint32) error {
+	ctx := context.Background()
+	db := orgDatabase(orgID, common.DATABASE_FLOW_LOG)
+	// the span table exists only when the trace tree is enabled in the ingester
+	exists, err := query(ctx, "system", fmt.Sprintf("EXISTS TABLE %s.%s", db, common.TABLE_SPAN_WITH_TRACE_ID), orgID, nil)
+	if err != nil {
+		return err
+	}
+	if len(exists.Values) == 0 || exists.Values[0].([]interface{})[0] != uint8(1) {
+		return nil
+	}
+
+	delta := uint32(g.cfg.Tracemap.TraceQueryDelta)
+	sql := fmt.Sprintf(
+		"SELECT %s FROM %s.%s WHERE time>=%d AND time<%d GROUP BY %s HAVING min(time)>=%d LIMIT %d",
+		common.TAG_TRACE_ID, db, common.TABLE_SPAN_WITH_TRACE_ID, start-delta, end, common.TAG_TRACE_ID, start, g.cfg.Tracemap.MaxTracePerIteration,
+	)
+	result, err := query(ctx, db, sql, orgID, nil)
+	if err != nil {
+		return err
+	}
+	traceIDs := make([]string, 0, len(result.Values))
+	for _, value := range result.Values {
+		if traceID, ok := value.([]interface{})[0].(string); ok && traceID != "" {
+			traceIDs = append(traceIDs, traceID)
+		}
+	}
+
+	orgIDInt, _ := strconv.Atoi(orgID)
+	batchSize := int(g.cfg.Tracemap.BatchTracesCountMax)
+	if batchSize <= 0 {
+		batchSize = len(traceIDs)
+	}
+	for i := 0; i < len(traceIDs); i += batchSize {
+		j := i + batchSize
+		if j > len(traceIDs) {
+			j = len(traceIDs)
+		}
+		batch := traceIDs[i:j]
+		sql := fmt.Sprintf(
+			"SELECT %s, toUInt32(time), %s FROM %s.%s WHERE time>=%d AND time<%d AND %s IN (%s)",
+			common.TAG_TRACE_ID, common.TAG_ENCODED_SPAN, db, common.TABLE_SPAN_WITH_TRACE_ID, start, end+delta, common.TAG_TRACE_ID, quoteStrings(batch),
+		)
+		result, err := query(ctx, db, sql, orgID, nil)
+		if err != nil {
+			return err
+		}
+		g.putTraceTrees(uint16(orgIDInt), result.Values)
+	}
+	return nil
+}
+
+func (g *TraceMapGenerator) putTraceTrees(orgID uint16, values []interface{}) {
+	traceSpans := make(map[string][]*tracetree.SpanTrace)
+	decoder := &codec.SimpleDecoder{}
+	for _, value := range values {
+		row := value.([]interface{})
+		traceID, _ := row[0].(string)
+		encodedSpan, _ := row[2].(string)
+		span := tracetree.AcquireSpanTrace()
+		decoder.Init([]byte(encodedSpan))
+		if err := span.Decode(decoder); err != nil {
+			log.Debugf("decode span of trace %s failed: %s", traceID, err)
+			tracetree.ReleaseSpanTrace(span)
+			continue
+		}
+		span.Time, _ = row[1].(uint32)
+		traceSpans[traceID] = append(traceSpans[traceID], span)
+	}
 
+	batch := make([]interface{}, 0, g.cfg.Tracemap.WriteBatchSize)
+	for traceID, spans := range traceSpans {
+		if tree := g.builder.Build(orgID, traceID, spans); tree != nil {
+			batch = append(batch, tree)
+		}
+		for _, span := range spans {
+			tracetree.ReleaseSpanTrace(span)
+		}
+		if len(batch) >= g.cfg.Tracemap.WriteBatchSize {
+			g.sharedQueue.Put(batch...)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		g.sharedQueue.Put(batch...)
+	}
 }
@@ -0,0 +1,306 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracemap
+
+import (
+	"net"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+)
+
+const (
+	spanUnresolved = iota
+	spanResolving
+	spanResolved
+)
+
+// a node is identified by the auto service and the ip, the app service is filled in when it is known
+type treeNodeKey struct {
+	parentNodeIndex int32
+	autoServiceType uint8
+	autoServiceID   uint32
+	ip              string
+}
+
+type treeNodeStats struct {
+	responseDurationSum            uint64
+	responseTotal                  uint32
+	responseStatusServerErrorCount uint32
+}
+
+// traceTreeBuilder builds the service tree of a trace from its spans:
+//   - the node of a span is the server side of the span
+//   - the parent of a node is the node of the parent span, or the client side of the span if it has no parent span
+//   - the spans of the same request observed at different points are merged into one node, the metrics of a node
+//     are from the observation point which sees the most requests
+type traceTreeBuilder struct {
+	tree      *tracetree.TraceTree
+	spans     []*tracetree.SpanTrace
+	spanIndex map[string]int
+	spanState []uint8
+	spanNodes []int32
+	nodeIndex map[treeNodeKey]int32
+	nodeStats []map[string]*treeNodeStats
+}
+
+func newTraceTreeBuilder() *traceTreeBuilder {
+	return &traceTreeBuilder{
+		spanIndex: make(map[string]int),
+		nodeIndex: make(map[treeNodeKey]int32),
+	}
+}
+
+func (b *traceTreeBuilder) reset(spans []*tracetree.SpanTrace) {
+	b.spans = spans
+	for k := range b.spanIndex {
+		delete(b.spanIndex, k)
+	}
+	for k := range b.nodeIndex {
+		delete(b.nodeIndex, k)
+	}
+	b.spanState = b.spanState[:0]
+	b.spanNodes = b.spanNodes[:0]
+	b.nodeStats = b.nodeStats[:0]
+	for i, span := range spans {
+		if span.SpanId != "" {
+			b.spanIndex[span.SpanId] = i
+		}
+		b.spanState = append(b.spanState, spanUnresolved)
+		b.spanNodes = append(b.spanNodes, -1)
+	}
+}
+
+// Build returns the trace tree of the spans, the tree is acquired from the pool and should be released by the user
+func (b *traceTreeBuilder) Build(orgID uint16, traceID string, spans []*tracetree.SpanTrace) *tracetree.TraceTree {
+	if len(spans) == 0 {
+		return nil
+	}
+	b.reset(spans)
+	b.tree = tracetree.AcquireTraceTree()
+	b.tree.OrgId = orgID
+	b.tree.TraceId = traceID
+	b.tree.SearchIndex = tracetree.HashSearchIndex(traceID)
+	b.tree.Time = spans[0].Time
+	for i, span := range spans {
+		if span.Time < b.tree.Time {
+			b.tree.Time = span.Time
+		}
+		b.resolve(i)
+	}
+
+	for i := range b.tree.TreeNodes {
+		var best *treeNodeStats
+		bestPoint := ""
+		for point, stats := range b.nodeStats[i] {
+			if best == nil || stats.responseTotal > best.responseTotal ||
+				stats.responseTotal == best.responseTotal && betterObservationPoint(point, bestPoint) {
+				best, bestPoint = stats, point
+			}
+		}
+		if best == nil {
+			continue
+		}
+		node := &b.tree.TreeNodes[i]
+		node.ResponseDurationSum = best.responseDurationSum
+		node.ResponseTotal = best.responseTotal
+		node.ResponseStatusServerErrorCount = best.responseStatusServerErrorCount
+	}
+	tree := b.tree
+	b.tree, b.spans = nil, nil
+	return tree
+}
+
+func (b *traceTreeBuilder) resolve(i int) int32 {
+	switch b.spanState[i] {
+	case spanResolved:
+		return b.spanNodes[i]
+	case spanResolving:
+		// the parent spans are looped
+		return -1
+	}
+	b.spanState[i] = spanResolving
+	span := b.spans[i]
+
+	parentNodeIndex := int32(-1)
+	if p, ok := b.spanIndex[span.ParentSpanId]; ok && p != i {
+		parentNodeIndex = b.resolve(p)
+	}
+	server := serverNodeInfo(span)
+	var nodeIndex int32
+	if parentNodeIndex >= 0 && isSameNode(&b.tree.TreeNodes[parentNodeIndex].NodeInfo, &server) {
+		nodeIndex = parentNodeIndex
+		fillAppService(&b.tree.TreeNodes[nodeIndex].NodeInfo, server.AppService)
+	} else {
+		if parentNodeIndex < 0 {
+			if client := clientNodeInfo(span); !isEmptyNode(&client) && !isSameNode(&client, &server) {
+				parentNodeIndex = b.addNode(-1, &client)
+			}
+		}
+		nodeIndex = b.addNode(parentNodeIndex, &server)
+		if parentNodeIndex >= 0 {
+			addUniqParentSpanInfo(&b.tree.TreeNodes[nodeIndex], span)
+		}
+	}
+	if span.Topic != "" && b.tree.TreeNodes[nodeIndex].Topic == "" {
+		b.tree.TreeNodes[nodeIndex].Topic = span.Topic
+	}
+
+	stats, ok := b.nodeStats[nodeIndex][span.ObservationPoint]
+	if !ok {
+		stats = &treeNodeStats{}
+		b.nodeStats[nodeIndex][span.ObservationPoint] = stats
+	}
+	stats.responseTotal++
+	stats.responseDurationSum += span.ResponseDuration
+	if span.ResponseStatus == uint8(datatype.STATUS_SERVER_ERROR) {
+		stats.responseStatusServerErrorCount++
+	}
+
+	b.spanState[i] = spanResolved
+	b.spanNodes[i] = nodeIndex
+	return nodeIndex
+}
+
+func (b *traceTreeBuilder) addNode(parentNodeIndex int32, info *tracetree.NodeInfo) int32 {
+	key := treeNodeKey{
+		parentNodeIndex: parentNodeIndex,
+		autoServiceType: info.AutoServiceType,
+		autoServiceID:   info.AutoServiceID,
+		ip:              nodeIP(info).String(),
+	}
+	if index, ok := b.nodeIndex[key]; ok {
+		fillAppService(&b.tree.TreeNodes[index].NodeInfo, info.AppService)
+		return index
+	}
+	index := int32(len(b.tree.TreeNodes))
+	b.nodeIndex[key] = index
+	b.tree.TreeNodes = append(b.tree.TreeNodes, tracetree.TreeNode{
+		ParentNodeIndex: parentNodeIndex,
+		NodeInfo:        *info,
+	})
+	if parentNodeIndex >= 0 {
+		parent := &b.tree.TreeNodes[parentNodeIndex]
+		parent.ChildIndices = append(parent.ChildIndices, index)
+		b.tree.TreeNodes[index].Level = parent.Level + 1
+	}
+	b.nodeStats = append(b.nodeStats, make(map[string]*treeNodeStats))
+	return index
+}
+
+func addUniqParentSpanInfo(node *tracetree.TreeNode, span *tracetree.SpanTrace) {
+	info := tracetree.SpanInfo{
+		AutoServiceType0: span.AutoServiceType0,
+		AutoServiceType1: span.AutoServiceType1,
+		AutoServiceID0:   span.AutoServiceID0,
+		AutoServiceID1:   span.AutoServiceID1,
+		IsIPv4:           span.IsIPv4,
+		IP40:             span.IP40,
+		IP60:             span.IP60,
+		IP41:             span.IP41,
+		IP61:             span.IP61,
+	}
+	if isClientSide(span.ObservationPoint) {
+		info.AppService0 = span.AppService
+	} else {
+		info.AppService1 = span.AppService
+	}
+	for i := range node.UniqParentSpanInfos {
+		s := &node.UniqParentSpanInfos[i]
+		if s.AutoServiceType0 == info.AutoServiceType0 && s.AutoServiceType1 == info.AutoServiceType1 &&
+			s.AutoServiceID0 == info.AutoServiceID0 && s.AutoServiceID1 == info.AutoServiceID1 &&
+			s.AppService0 == info.AppService0 && s.AppService1 == info.AppService1 &&
+			s.IsIPv4 == info.IsIPv4 && s.IP40 == info.IP40 && s.IP41 == info.IP41 &&
+			s.IP60.Equal(info.IP60) && s.IP61.Equal(info.IP61) {
+			return
+		}
+	}
+	node.UniqParentSpanInfos = append(node.UniqParentSpanInfos, info)
+}
+
+// betterObservationPoint prefers the server side, which is closer to the node, and the result is stable
+func betterObservationPoint(a, b string) bool {
+	if isClientSide(a) != isClientSide(b) {
+		return !isClientSide(a)
+	}
+	return a < b
+}
+
+// the observation points at the client side, e.g.: c, c-p, c-app, c-nd
+func isClientSide(observationPoint string) bool {
+	return len(observationPoint) > 0 && observationPoint[0] == 'c'
+}
+
+func serverNodeInfo(span *tracetree.SpanTrace) tracetree.NodeInfo {
+	info := tracetree.NodeInfo{
+		AutoServiceType: span.AutoServiceType1,
+		AutoServiceID:   span.AutoServiceID1,
+		IsIPv4:          span.IsIPv4,
+		IP4:             span.IP41,
+		IP6:             span.IP61,
+	}
+	// the app service of the spans at the client side belongs to the caller
+	if !isClientSide(span.ObservationPoint) {
+		info.AppService = span.AppService
+	}
+	return info
+}
+
+func clientNodeInfo(span *tracetree.SpanTrace) tracetree.NodeInfo {
+	info := tracetree.NodeInfo{
+		AutoServiceType: span.AutoServiceType0,
+		AutoServiceID:   span.AutoServiceID0,
+		IsIPv4:          span.IsIPv4,
+		IP4:             span.IP40,
+		IP6:             span.IP60,
+	}
+	if isClientSide(span.ObservationPoint) {
+		info.AppService = span.AppService
+	}
+	return info
+}
+
+func nodeIP(info *tracetree.NodeInfo) net.IP {
+	if info.IsIPv4 {
+		return net.IPv4(byte(info.IP4>>24), byte(info.IP4>>16), byte(info.IP4>>8), byte(info.IP4))
+	}
+	return info.IP6
+}
+
+func isEmptyNode(info *tracetree.NodeInfo) bool {
+	if info.AutoServiceID != 0 || info.AppService != "" {
+		return false
+	}
+	ip := nodeIP(info)
+	return ip == nil || ip.IsUnspecified()
+}
+
+func isSameNode(a, b *tracetree.NodeInfo) bool {
+	if a.AutoServiceType != b.AutoServiceType || a.AutoServiceID != b.AutoServiceID {
+		return false
+	}
+	if a.AppService != "" && b.AppService != "" && a.AppService != b.AppService {
+		return false
+	}
+	return nodeIP(a).Equal(nodeIP(b))
+}
+
+func fillAppService(info *tracetree.NodeInfo, appService string) {
+	if info.AppService == "" {
+		info.AppService = appService
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracemap
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+)
+
+const (
+	testIP1 = 0x0a000001 // 10.0.0.1
+	testIP2 = 0x0a000002 // 10.0.0.2
+	testIP3 = 0x0a000003 // 10.0.0.3
+)
+
+func newTestSpans() []*tracetree.SpanTrace {
+	return []*tracetree.SpanTrace{
+		// the request from an ip to service 100, observed at both sides
+		{
+			Time: 101, SpanId: "1", ObservationPoint: "c",
+			AutoServiceType0: 255, AutoServiceType1: 11, AutoServiceID1: 100,
+			IsIPv4: true, IP40: testIP1, IP41: testIP2, ResponseDuration: 10,
+		},
+		{
+			Time: 100, SpanId: "2", ParentSpanId: "1", ObservationPoint: "s", AppService: "svc-a",
+			AutoServiceType0: 255, AutoServiceType1: 11, AutoServiceID1: 100,
+			IsIPv4: true, IP40: testIP1, IP41: testIP2, ResponseDuration: 8,
+		},
+		// the request from service 100 to service 200
+		{
+			Time: 102, SpanId: "3", ParentSpanId: "2", ObservationPoint: "s-app", AppService: "svc-b",
+			AutoServiceType0: 11, AutoServiceID0: 100, AutoServiceType1: 11, AutoServiceID1: 200,
+			IsIPv4: true, IP40: testIP2, IP41: testIP3, ResponseDuration: 5,
+			ResponseStatus: uint8(datatype.STATUS_SERVER_ERROR),
+		},
+	}
+}
+
+func TestTraceTreeBuilder(t *testing.T) {
+	tree := newTraceTreeBuilder().Build(1, "trace-1", newTestSpans())
+	defer tracetree.ReleaseTraceTree(tree)
+
+	if tree.Time != 100 || tree.TraceId != "trace-1" || tree.SearchIndex != tracetree.HashSearchIndex("trace-1") {
+		t.Fatalf("got trace tree %+v", tree)
+	}
+	if len(tree.TreeNodes) != 3 {
+		t.Fatalf("got %d tree nodes, expect 3", len(tree.TreeNodes))
+	}
+	client, server, callee := &tree.TreeNodes[0], &tree.TreeNodes[1], &tree.TreeNodes[2]
+	if client.ParentNodeIndex != -1 || client.NodeInfo.AutoServiceType != 255 || client.ResponseTotal != 0 {
+		t.Errorf("got client node %+v", client)
+	}
+	// the spans of the same request are merged, the metrics are from the server side
+	if server.ParentNodeIndex != 0 || server.NodeInfo.AutoServiceID != 100 || server.NodeInfo.AppService != "svc-a" ||
+		server.ResponseTotal != 1 || server.ResponseDurationSum != 8 || len(server.UniqParentSpanInfos) != 1 {
+		t.Errorf("got server node %+v", server)
+	}
+	if callee.ParentNodeIndex != 1 || callee.NodeInfo.AutoServiceID != 200 || callee.NodeInfo.AppService != "svc-b" ||
+		callee.ResponseStatusServerErrorCount != 1 || callee.ResponseDurationSum != 5 {
+		t.Errorf("got callee node %+v", callee)
+	}
+
+	// the looped parent spans should not hang
+	spans := newTestSpans()
+	spans[0].ParentSpanId = "3"
+	looped := newTraceTreeBuilder().Build(1, "trace-2", spans)
+	defer tracetree.ReleaseTraceTree(looped)
+	if len(looped.TreeNodes) == 0 {
+		t.Errorf("got empty looped trace tree")
+	}
+}
+
+func TestAggregateTraceTree(t *testing.T) {
+	builder := newTraceTreeBuilder()
+	edges := make(map[traceMapEdgeKey]*traceMapEdge)
+	for _, traceID := range []string{"trace-1", "trace-2"} {
+		tree := builder.Build(1, traceID, newTestSpans())
+		aggregateTraceTree(tree, edges)
+		tracetree.ReleaseTraceTree(tree)
+	}
+	if len(edges) != 2 {
+		t.Fatalf("got %d edges, expect 2", len(edges))
+	}
+
+	ipToService := traceMapEdgeKey{
+		client: traceMapNode{autoServiceType: 255, ip: "10.0.0.1"},
+		server: traceMapNode{autoServiceType: 11, autoServiceID: 100, appService: "svc-a", layer: 1},
+	}
+	if edge := edges[ipToService]; edge == nil || edge.responseTotal != 2 || edge.responseDurationSum != 16 {
+		t.Errorf("got edge %+v of %+v", edge, ipToService)
+	}
+	serviceToService := traceMapEdgeKey{
+		client: ipToService.server,
+		server: traceMapNode{autoServiceType: 11, autoServiceID: 200, appService: "svc-b", layer: 2},
+	}
+	if edge := edges[serviceToService]; edge == nil || edge.responseTotal != 2 || edge.responseStatusServerErrorCount != 2 {
+		t.Errorf("got edge %+v of %+v", edge, serviceToService)
+	}
+}
@@ -55,11 +55,42 @@ type PromMetaParams struct {
 	StartTime   string
 	EndTime     string
 	LabelName   string
+	Metric      string // only for metadata
+	Limit       int    // only for metadata, the maximum number of metrics to return, 0 means no limit
 	OrgID       string
 	BlockTeamID []string
 	Context     context.Context
 }
 
+// PromMetricMetadata is the metadata of a metric in the response of `/api/v1/metadata`
+type PromMetricMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// PromExemplarData is an element of the response data of `/api/v1/query_exemplars`
+type PromExemplarData struct {
+	SeriesLabels map[string]string `json:"seriesLabels"`
+	Exemplars    []PromExemplar    `json:"exemplars"`
+}
+
+type PromExemplar struct {
+	Labels    map[string]string `json:"labels"`
+	Value     string            `json:"value"`
+	Timestamp float64           `json:"timestamp"` // s
+}
+
+// PromBuildInfo is the response data of `/api/v1/status/buildinfo`
+type PromBuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	BuildUser string `json:"buildUser"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
 type PromQueryStats struct {
 	Duration   float64 `json:"duration,omitempty"`
 	SQL        string  `json:"sql,omitempty"`
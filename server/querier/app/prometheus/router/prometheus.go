@@ -37,6 +37,7 @@ import (
 
 const _STATUS_FAIL = "fail"
 const _STATUS_SUCCESS = "success"
+const _ERROR_TYPE_BAD_DATA = "bad_data"
 
 // PromQL Query API
 func promQuery(svc *service.PrometheusService) gin.HandlerFunc {
@@ -208,6 +209,99 @@ func promSeriesReader(svc *service.PrometheusService) gin.HandlerFunc {
 	})
 }
 
+func promLabelsReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		args := model.PromQueryParams{
+			StartTime: c.Request.FormValue("start"),
+			EndTime:   c.Request.FormValue("end"),
+			Matchers:  c.Request.Form["match[]"],
+			Context:   c.Request.Context(),
+			OrgID:     c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
+		}
+		debug := c.Request.FormValue("debug")
+		block_team_id := c.Request.FormValue("block-team-id")
+		args.ExtraFilters = c.Request.FormValue("extra-filters")
+		setRouterArgs(debug, &args.Debug, config.Cfg.Prometheus.RequestQueryWithDebug, strconv.ParseBool)
+		err := setRouterArgs(block_team_id, &args.BlockTeamID, nil, splitStrings)
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+			return
+		}
+		// label names are got from the tags of series
+		ctx := context.WithValue(c.Request.Context(), service.CtxKeyShowTag{}, true)
+		result, err := svc.PromLabelsService(&args, ctx)
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+		} else {
+			c.JSON(200, result)
+		}
+	})
+}
+
+func promMetadataReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		args := model.PromMetaParams{
+			Metric:  c.Request.FormValue("metric"),
+			Context: c.Request.Context(),
+			OrgID:   c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
+		}
+		limit := c.Request.FormValue("limit")
+		block_team_id := c.Request.FormValue("block-team-id")
+		err := setRouterArgs(limit, &args.Limit, 0, strconv.Atoi)
+		if err == nil {
+			err = setRouterArgs(block_team_id, &args.BlockTeamID, nil, splitStrings)
+		}
+		if err != nil {
+			c.JSON(400, &model.PromQueryResponse{Error: err.Error(), ErrorType: _ERROR_TYPE_BAD_DATA, Status: _STATUS_FAIL})
+			return
+		}
+		result, err := svc.PromMetadataService(&args, c.Request.Context())
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+		} else {
+			c.JSON(200, result)
+		}
+	})
+}
+
+func promExemplarsReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		args := model.PromQueryParams{
+			Promql:    c.Request.FormValue("query"),
+			StartTime: c.Request.FormValue("start"),
+			EndTime:   c.Request.FormValue("end"),
+			Context:   c.Request.Context(),
+			OrgID:     c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
+		}
+		result, err := svc.PromExemplarsQueryService(&args, c.Request.Context())
+		if err != nil {
+			c.JSON(400, &model.PromQueryResponse{Error: err.Error(), ErrorType: _ERROR_TYPE_BAD_DATA, Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+func promFormatQuery(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		result, err := svc.PromFormatQueryService(c.Request.FormValue("query"))
+		if err != nil {
+			c.JSON(400, &model.PromQueryResponse{Error: err.Error(), ErrorType: _ERROR_TYPE_BAD_DATA, Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+func promBuildInfo(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		c.JSON(200, svc.PromBuildInfoService())
+	})
+}
+
 func promQLAnalysis(svc *service.PrometheusService) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		metric := c.Query("metric")
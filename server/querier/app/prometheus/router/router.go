@@ -44,6 +44,14 @@ func PrometheusRouter(e *gin.Engine) {
 		promGroup.GET("/api/v1/series", promSeriesReader(prometheusService))
 		promGroup.POST("/api/v1/series", promSeriesReader(prometheusService))
 		promGroup.GET("/api/v1/label/:labelName/values", promTagValuesReader(prometheusService))
+		promGroup.GET("/api/v1/labels", promLabelsReader(prometheusService))
+		promGroup.POST("/api/v1/labels", promLabelsReader(prometheusService))
+		promGroup.GET("/api/v1/metadata", promMetadataReader(prometheusService))
+		promGroup.GET("/api/v1/query_exemplars", promExemplarsReader(prometheusService))
+		promGroup.POST("/api/v1/query_exemplars", promExemplarsReader(prometheusService))
+		promGroup.GET("/api/v1/format_query", promFormatQuery(prometheusService))
+		promGroup.POST("/api/v1/format_query", promFormatQuery(prometheusService))
+		promGroup.GET("/api/v1/status/buildinfo", promBuildInfo(prometheusService))
 
 		// not use "/prom/api/v1/adapter/:name", suitable for map[rouer key]counter in statsd
 		for _, v := range []string{"label", "query_range", "query", "series"} {
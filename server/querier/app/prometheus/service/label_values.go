@@ -45,6 +45,15 @@ func (p *prometheusExecutor) getTagValues(ctx context.Context, args *model.PromM
 }
 
 func getMetrics(ctx context.Context, args *model.PromMetaParams) (resp []string) {
+	resp = []string{}
+	walkMetrics(ctx, args, func(metricName string, _ *metrics.Metrics) {
+		resp = append(resp, metricName)
+	})
+	return resp
+}
+
+// walkMetrics calls fn for each metric, m is nil for the prometheus native metrics
+func walkMetrics(ctx context.Context, args *model.PromMetaParams, fn func(metricName string, m *metrics.Metrics)) {
 	// We speed up the return of the metrics list by querying the aggregation information in
 	// `flow_tag.ext_metrics_custom_field_value`. Since we do not query the original time series
 	// data, filtering metrics by time is currently not supported.
@@ -60,14 +69,13 @@ func getMetrics(ctx context.Context, args *model.PromMetaParams) (resp []string)
 	//	where = fmt.Sprintf("time<=%s", args.EndTime)
 	//}
 
-	resp = []string{}
 	for db, tables := range chCommon.DB_TABLE_MAP {
 		if db == chCommon.DB_NAME_EXT_METRICS {
 			extMetrics, _ := metrics.GetExtMetrics(chCommon.DB_NAME_EXT_METRICS, "", where, "", args.OrgID, false, args.Context)
 			for _, v := range extMetrics {
 				// append telegraf metrics, e.g.: influxdb_internal_statsd__tcp_current_connections[influxdb_target__metric]
 				metricName := fmt.Sprintf("%s__%s__%s__%s", db, "metrics", strings.Replace(v.Table, ".", "_", 1), strings.TrimPrefix(v.DisplayName, "metrics."))
-				resp = append(resp, metricName)
+				fn(metricName, v)
 			}
 		} else if db == chCommon.DB_NAME_PROMETHEUS {
 			// prometheus samples should get all metrcis from `table`
@@ -75,16 +83,16 @@ func getMetrics(ctx context.Context, args *model.PromMetaParams) (resp []string)
 			for _, v := range samples.Values {
 				tableName := v.([]interface{})[0].(string)
 				// append ${metrics_name}
-				resp = append(resp, tableName)
+				fn(tableName, nil)
 				// append prometheus__samples__${metrics_name}
 				metricsName := fmt.Sprintf("%s__%s__%s", db, TABLE_NAME_SAMPLES, tableName)
-				resp = append(resp, metricsName)
+				fn(metricsName, nil)
 			}
 		} else if db == chCommon.DB_NAME_DEEPFLOW_ADMIN || db == chCommon.DB_NAME_DEEPFLOW_TENANT {
 			deepflowSystem, _ := metrics.GetExtMetrics(db, "", where, "", args.OrgID, false, args.Context)
 			for _, v := range deepflowSystem {
 				metricName := fmt.Sprintf("%s__%s__%s", db, strings.ReplaceAll(v.Table, ".", "_"), strings.TrimPrefix(v.DisplayName, "metrics."))
-				resp = append(resp, metricName)
+				fn(metricName, v)
 			}
 		} else {
 			for _, table := range tables {
@@ -99,16 +107,15 @@ func getMetrics(ctx context.Context, args *model.PromMetaParams) (resp []string)
 					metricsName := ""
 					if db == chCommon.DB_NAME_FLOW_METRICS {
 						metricsName = fmt.Sprintf("%s__%s__%s__%s", db, table, field, "1m")
-						resp = append(resp, metricsName)
+						fn(metricsName, v)
 						metricsName = fmt.Sprintf("%s__%s__%s__%s", db, table, field, "1s")
-						resp = append(resp, metricsName)
+						fn(metricsName, v)
 					} else {
 						metricsName = fmt.Sprintf("%s__%s__%s", db, table, field)
-						resp = append(resp, metricsName)
+						fn(metricsName, v)
 					}
 				}
 			}
 		}
 	}
-	return resp
 }
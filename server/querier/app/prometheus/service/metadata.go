@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/metrics"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+)
+
+const (
+	// the time range of `/api/v1/labels` when start or end is not given
+	defaultLabelsQueryRange = time.Hour
+
+	PROMETHEUS_METRIC_TYPE_COUNTER = "counter"
+	PROMETHEUS_METRIC_TYPE_GAUGE   = "gauge"
+	PROMETHEUS_METRIC_TYPE_UNKNOWN = "unknown"
+
+	prometheusModulePath = "github.com/prometheus/prometheus"
+)
+
+var (
+	buildInfo     *model.PromBuildInfo
+	buildInfoOnce sync.Once
+)
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#getting-label-names
+func (p *prometheusExecutor) labels(ctx context.Context, args *model.PromQueryParams) (result *model.PromQueryResponse, err error) {
+	names := map[string]struct{}{LABEL_NAME_METRICS: {}}
+	if len(args.Matchers) > 0 {
+		end := time.Now()
+		if args.EndTime == "" {
+			args.EndTime = fmt.Sprintf("%d", end.Unix())
+		}
+		if args.StartTime == "" {
+			args.StartTime = fmt.Sprintf("%d", end.Add(-defaultLabelsQueryRange).Unix())
+		}
+		seriesResult, err := p.series(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		for _, lset := range seriesResult.Data.([]labels.Labels) {
+			for _, l := range lset {
+				names[l.Name] = struct{}{}
+			}
+		}
+	} else {
+		orgID := args.OrgID
+		if orgID == "" {
+			orgID = common.DEFAULT_ORG_ID
+		}
+		for name := range trans_prometheus.ORGPrometheus[orgID].LabelNameToID {
+			names[name] = struct{}{}
+		}
+	}
+
+	data := make([]string, 0, len(names))
+	for name := range names {
+		data = append(data, name)
+	}
+	sort.Strings(data)
+	return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+func (p *prometheusExecutor) metadata(ctx context.Context, args *model.PromMetaParams) (result *model.PromQueryResponse, err error) {
+	data := make(map[string][]model.PromMetricMetadata)
+	walkMetrics(ctx, args, func(metricName string, m *metrics.Metrics) {
+		if args.Metric != "" && args.Metric != metricName {
+			return
+		}
+		if _, ok := data[metricName]; ok {
+			return
+		}
+		if args.Limit > 0 && len(data) >= args.Limit {
+			return
+		}
+		data[metricName] = []model.PromMetricMetadata{metricsToPromMetadata(m)}
+	})
+	// the metadata sent by remote write overrides the unknown type of prometheus native metrics, and adds the
+	// metric families of histograms and summaries whose series names have suffixes
+	stored, err := storedMetadata(ctx, args.OrgID, args.BlockTeamID)
+	if err != nil {
+		log.Debugf("query prometheus metadata failed: %s", err)
+	}
+	for name, m := range stored {
+		if args.Metric != "" && args.Metric != name {
+			continue
+		}
+		if _, ok := data[name]; !ok && args.Limit > 0 && len(data) >= args.Limit {
+			continue
+		}
+		data[name] = []model.PromMetricMetadata{m}
+	}
+	return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+}
+
+func metricsToPromMetadata(m *metrics.Metrics) model.PromMetricMetadata {
+	// the type of prometheus native metrics are stored in prometheus.metadata only when it is sent by remote write
+	if m == nil {
+		return model.PromMetricMetadata{Type: PROMETHEUS_METRIC_TYPE_UNKNOWN}
+	}
+	metadata := model.PromMetricMetadata{Help: m.Description, Unit: m.Unit}
+	if metadata.Help == "" {
+		metadata.Help = m.DisplayName
+	}
+	switch m.Type {
+	case metrics.METRICS_TYPE_COUNTER:
+		metadata.Type = PROMETHEUS_METRIC_TYPE_COUNTER
+	case metrics.METRICS_TYPE_GAUGE, metrics.METRICS_TYPE_BOUNDED_GAUGE, metrics.METRICS_TYPE_DELAY,
+		metrics.METRICS_TYPE_PERCENTAGE, metrics.METRICS_TYPE_QUOTIENT:
+		metadata.Type = PROMETHEUS_METRIC_TYPE_GAUGE
+	default:
+		metadata.Type = PROMETHEUS_METRIC_TYPE_UNKNOWN
+	}
+	return metadata
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
+func (p *prometheusExecutor) queryExemplars(ctx context.Context, args *model.PromQueryParams) (result *model.PromQueryResponse, err error) {
+	expr, err := parser.ParseExpr(args.Promql)
+	if err != nil {
+		return nil, err
+	}
+	start, err := parseTime(args.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTime(args.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end timestamp must not be before start timestamp")
+	}
+	data, err := queryStoredExemplars(ctx, args, parser.ExtractSelectors(expr), start, end)
+	if err != nil {
+		// the exemplars table is created when the first exemplar is received, return an empty result
+		// so that the clients fall back gracefully
+		log.Debugf("query prometheus exemplars failed: %s", err)
+		return &model.PromQueryResponse{Data: []model.PromExemplarData{}, Status: _SUCCESS}, nil
+	}
+	return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#formatting-query-expressions
+func (p *prometheusExecutor) formatQuery(query string) (result *model.PromQueryResponse, err error) {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PromQueryResponse{Data: expr.String(), Status: _SUCCESS}, nil
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#build-information
+func (p *prometheusExecutor) buildInfo() *model.PromQueryResponse {
+	buildInfoOnce.Do(func() {
+		buildInfo = &model.PromBuildInfo{GoVersion: runtime.Version()}
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		// report the version of the compatible prometheus, e.g.: v0.36.2 -> 2.36.2
+		for _, dep := range info.Deps {
+			if dep.Path == prometheusModulePath {
+				buildInfo.Version = "2." + strings.TrimPrefix(dep.Version, "v0.")
+				break
+			}
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				buildInfo.Revision = s.Value
+			case "vcs.time":
+				buildInfo.BuildDate = s.Value
+			}
+		}
+	})
+	return &model.PromQueryResponse{Data: buildInfo, Status: _SUCCESS}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/metrics"
+)
+
+func TestMetricsToPromMetadata(t *testing.T) {
+	Convey("TestCase_MetricsToPromMetadata", t, func() {
+		So(metricsToPromMetadata(nil).Type, ShouldEqual, PROMETHEUS_METRIC_TYPE_UNKNOWN)
+
+		m := metricsToPromMetadata(&metrics.Metrics{Type: metrics.METRICS_TYPE_COUNTER, Unit: "byte", DisplayName: "byte"})
+		So(m, ShouldResemble, model.PromMetricMetadata{Type: PROMETHEUS_METRIC_TYPE_COUNTER, Help: "byte", Unit: "byte"})
+
+		m = metricsToPromMetadata(&metrics.Metrics{Type: metrics.METRICS_TYPE_DELAY, Unit: "us", Description: "round trip time"})
+		So(m, ShouldResemble, model.PromMetricMetadata{Type: PROMETHEUS_METRIC_TYPE_GAUGE, Help: "round trip time", Unit: "us"})
+
+		So(metricsToPromMetadata(&metrics.Metrics{Type: metrics.METRICS_TYPE_TAG}).Type, ShouldEqual, PROMETHEUS_METRIC_TYPE_UNKNOWN)
+	})
+}
+
+func TestFormatQuery(t *testing.T) {
+	p := &prometheusExecutor{}
+	Convey("TestCase_FormatQuery_Success", t, func() {
+		result, err := p.formatQuery(`rate( http_requests_total{job = "api"} [5m] )`)
+		So(err, ShouldBeNil)
+		So(result.Status, ShouldEqual, _SUCCESS)
+		So(result.Data, ShouldEqual, `rate(http_requests_total{job="api"}[5m])`)
+	})
+
+	Convey("TestCase_FormatQuery_Failed", t, func() {
+		_, err := p.formatQuery(`sum(`)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("TestCase_QueryExemplars", t, func() {
+		result, err := p.queryExemplars(context.Background(), &model.PromQueryParams{Promql: "up", StartTime: "1700000000", EndTime: "1700000060"})
+		So(err, ShouldBeNil)
+		So(result.Data, ShouldResemble, []model.PromExemplarData{})
+
+		_, err = p.queryExemplars(context.Background(), &model.PromQueryParams{Promql: "up", StartTime: "1700000060", EndTime: "1700000000"})
+		So(err, ShouldNotBeNil)
+	})
+}
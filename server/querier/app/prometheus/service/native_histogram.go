@@ -0,0 +1,308 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+)
+
+const (
+	PROMETHEUS_DATABASE       = "prometheus"
+	PROMETHEUS_EXEMPLAR_TABLE = "exemplars"
+	PROMETHEUS_METADATA_TABLE = "metadata"
+
+	// the native histograms are stored as the classic series <name>_bucket{le=...}, <name>_count and <name>_sum
+	CLASSIC_BUCKET_SUFFIX = "_bucket"
+
+	EXEMPLARS_QUERY_LIMIT = 10000
+)
+
+// queryPrometheusExt executes the sql on the tables written by the ingester for the native histograms,
+// exemplars and metadata, which are not supported by the querier engine
+func queryPrometheusExt(ctx context.Context, orgID, sql string) (*common.Result, error) {
+	if config.Cfg == nil {
+		return nil, fmt.Errorf("querier config is not initialized")
+	}
+	chClient := client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       prometheusExtDatabase(orgID),
+		Context:  ctx,
+		Debug:    client.NewDebug(sql),
+	}
+	return chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: orgID})
+}
+
+// prometheusExtDatabase returns the prometheus database of the organization, e.g.: 0002_prometheus
+func prometheusExtDatabase(orgID string) string {
+	id, err := strconv.Atoi(orgID)
+	if err != nil {
+		return PROMETHEUS_DATABASE
+	}
+	return ckdb.OrgDatabasePrefix(uint16(id)) + PROMETHEUS_DATABASE
+}
+
+// quoteStrings formats the strings as the elements of an IN expression, e.g.: 'a','b'
+func quoteStrings(values []string) string {
+	var sb strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('\'')
+		for _, c := range []byte(v) {
+			if c == '\'' || c == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		}
+		sb.WriteByte('\'')
+	}
+	return sb.String()
+}
+
+func blockTeamFilter(blockTeamID []string) string {
+	if len(blockTeamID) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("team_id NOT IN (%s)", strings.Join(blockTeamID, ","))
+}
+
+// storedMetadata returns the latest metadata of the metric families sent by remote write
+func storedMetadata(ctx context.Context, orgID string, blockTeamID []string) (map[string]model.PromMetricMetadata, error) {
+	sql := fmt.Sprintf("SELECT metric_family_name, argMax(type, time), argMax(help, time), argMax(unit, time) FROM %s.%s",
+		prometheusExtDatabase(orgID), PROMETHEUS_METADATA_TABLE)
+	if filter := blockTeamFilter(blockTeamID); filter != "" {
+		sql += " WHERE " + filter
+	}
+	sql += " GROUP BY metric_family_name"
+	result, err := queryPrometheusExt(ctx, orgID, sql)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]model.PromMetricMetadata, len(result.Values))
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) < 4 {
+			continue
+		}
+		name, _ := row[0].(string)
+		m := model.PromMetricMetadata{}
+		m.Type, _ = row[1].(string)
+		m.Help, _ = row[2].(string)
+		m.Unit, _ = row[3].(string)
+		if m.Type == "" {
+			m.Type = PROMETHEUS_METRIC_TYPE_UNKNOWN
+		}
+		metadata[name] = m
+	}
+	return metadata, nil
+}
+
+// queryStoredExemplars returns the exemplars of the series matched by the selectors in [start, end]
+func queryStoredExemplars(ctx context.Context, args *model.PromQueryParams, selectors [][]*labels.Matcher, start, end time.Time) ([]model.PromExemplarData, error) {
+	conditions := []string{fmt.Sprintf("time>=%d AND time<=%d", start.Unix(), end.Unix())}
+	// filter by metric names only if all selectors have an equal matcher of the metric name
+	metricNames := make([]string, 0, len(selectors))
+	for _, matchers := range selectors {
+		name := ""
+		for _, m := range matchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				name = m.Value
+			}
+		}
+		if name == "" {
+			metricNames = nil
+			break
+		}
+		metricNames = append(metricNames, name)
+	}
+	if len(metricNames) > 0 {
+		conditions = append(conditions, fmt.Sprintf("metric_name IN (%s)", quoteStrings(metricNames)))
+	}
+	if filter := blockTeamFilter(args.BlockTeamID); filter != "" {
+		conditions = append(conditions, filter)
+	}
+	sql := fmt.Sprintf("SELECT toUnixTimestamp64Milli(timestamp), metric_name, label_names, label_values, exemplar_label_names, exemplar_label_values, value "+
+		"FROM %s.%s WHERE %s ORDER BY timestamp LIMIT %d",
+		prometheusExtDatabase(args.OrgID), PROMETHEUS_EXEMPLAR_TABLE, strings.Join(conditions, " AND "), EXEMPLARS_QUERY_LIMIT)
+	result, err := queryPrometheusExt(ctx, args.OrgID, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []model.PromExemplarData{}
+	seriesIndexes := make(map[string]int)
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) < 7 {
+			continue
+		}
+		timestamp, _ := row[0].(int64)
+		metricName, _ := row[1].(string)
+		names, _ := row[2].([]string)
+		values, _ := row[3].([]string)
+		exemplarNames, _ := row[4].([]string)
+		exemplarValues, _ := row[5].([]string)
+		v, _ := row[6].(float64)
+
+		series := make(labels.Labels, 0, len(names)+1)
+		series = append(series, labels.Label{Name: labels.MetricName, Value: metricName})
+		for i := 0; i < len(names) && i < len(values); i++ {
+			series = append(series, labels.Label{Name: names[i], Value: values[i]})
+		}
+		sort.Sort(series)
+		if !matchSelectors(series, selectors) {
+			continue
+		}
+		key := series.String()
+		index, ok := seriesIndexes[key]
+		if !ok {
+			index = len(data)
+			seriesIndexes[key] = index
+			data = append(data, model.PromExemplarData{SeriesLabels: series.Map()})
+		}
+		exemplarLabels := make(map[string]string, len(exemplarNames))
+		for i := 0; i < len(exemplarNames) && i < len(exemplarValues); i++ {
+			exemplarLabels[exemplarNames[i]] = exemplarValues[i]
+		}
+		data[index].Exemplars = append(data[index].Exemplars, model.PromExemplar{
+			Labels:    exemplarLabels,
+			Value:     strconv.FormatFloat(v, 'f', -1, 64),
+			Timestamp: float64(timestamp) / 1000,
+		})
+	}
+	return data, nil
+}
+
+// matchSelectors returns true if the series is matched by any of the selectors
+func matchSelectors(series labels.Labels, selectors [][]*labels.Matcher) bool {
+	for _, matchers := range selectors {
+		matched := true
+		for _, m := range matchers {
+			if !m.Matches(series.Get(m.Name)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteNativeHistogramQuery rewrites the selectors of a native histogram `x` in histogram_quantile to the
+// classic buckets `x_bucket`, and keeps the `le` label in the aggregations, e.g.:
+// histogram_quantile(0.9, sum(rate(x[5m]))) => histogram_quantile(0.9, sum by (le) (rate(x_bucket[5m])))
+func rewriteNativeHistogramQuery(orgID, promql string) string {
+	if !strings.Contains(promql, "histogram_quantile") {
+		return promql
+	}
+	if orgID == "" {
+		orgID = common.DEFAULT_ORG_ID
+	}
+	metricNameToID := trans_prometheus.ORGPrometheus[orgID].MetricNameToID
+	isNativeHistogram := func(name string) bool {
+		if _, ok := metricNameToID[name]; ok {
+			return false
+		}
+		_, ok := metricNameToID[name+CLASSIC_BUCKET_SUFFIX]
+		return ok
+	}
+	return rewriteNativeHistogramExpr(promql, isNativeHistogram)
+}
+
+func rewriteNativeHistogramExpr(promql string, isNativeHistogram func(string) bool) string {
+	expr, err := parser.ParseExpr(promql)
+	if err != nil {
+		return promql
+	}
+	rewritten := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		call, ok := node.(*parser.Call)
+		if !ok || call.Func.Name != "histogram_quantile" || len(call.Args) < 2 {
+			return nil
+		}
+		if rewriteNativeHistogramSelectors(call.Args[1], isNativeHistogram) {
+			rewritten = true
+		}
+		return nil
+	})
+	if !rewritten {
+		return promql
+	}
+	return expr.String()
+}
+
+func rewriteNativeHistogramSelectors(expr parser.Expr, isNativeHistogram func(string) bool) bool {
+	rewritten := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok || vs.Name == "" || !isNativeHistogram(vs.Name) {
+			return nil
+		}
+		vs.Name += CLASSIC_BUCKET_SUFFIX
+		for _, m := range vs.LabelMatchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				m.Value = vs.Name
+			}
+		}
+		rewritten = true
+		return nil
+	})
+	if !rewritten {
+		return false
+	}
+	// the buckets of the native histogram are aggregated implicitly, `le` should be kept for the classic buckets
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		agg, ok := node.(*parser.AggregateExpr)
+		if !ok {
+			return nil
+		}
+		index := -1
+		for i, g := range agg.Grouping {
+			if g == labels.BucketLabel {
+				index = i
+			}
+		}
+		if agg.Without && index >= 0 {
+			agg.Grouping = append(agg.Grouping[:index], agg.Grouping[index+1:]...)
+		} else if !agg.Without && index < 0 {
+			agg.Grouping = append(agg.Grouping, labels.BucketLabel)
+		}
+		return nil
+	})
+	return true
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRewriteNativeHistogramExpr(t *testing.T) {
+	isNativeHistogram := func(name string) bool { return name == "latency" }
+	format := func(promql string) string { return mustParseExpr(promql).String() }
+	Convey("TestCase_RewriteNativeHistogramExpr", t, func() {
+		So(rewriteNativeHistogramExpr(`histogram_quantile(0.9, sum(rate(latency[5m])))`, isNativeHistogram),
+			ShouldEqual, format(`histogram_quantile(0.9, sum by (le) (rate(latency_bucket[5m])))`))
+		So(rewriteNativeHistogramExpr(`histogram_quantile(0.9, sum by (job) (rate(latency{job="api"}[5m])))`, isNativeHistogram),
+			ShouldEqual, format(`histogram_quantile(0.9, sum by (job, le) (rate(latency_bucket{job="api"}[5m])))`))
+		So(rewriteNativeHistogramExpr(`histogram_quantile(0.9, sum without (le, pod) (rate(latency[5m])))`, isNativeHistogram),
+			ShouldEqual, format(`histogram_quantile(0.9, sum without (pod) (rate(latency_bucket[5m])))`))
+	})
+
+	Convey("TestCase_RewriteNativeHistogramExpr_Unchanged", t, func() {
+		for _, promql := range []string{
+			`histogram_quantile(0.9, sum by (le) (rate(rtt_bucket[5m])))`,
+			`sum(rate(latency[5m]))`,
+			`histogram_quantile(`,
+		} {
+			So(rewriteNativeHistogramExpr(promql, isNativeHistogram), ShouldEqual, promql)
+		}
+	})
+}
+
+func TestMatchSelectors(t *testing.T) {
+	Convey("TestCase_MatchSelectors", t, func() {
+		selectors := parser.ExtractSelectors(mustParseExpr(`latency{job="api"} + rtt`))
+		So(matchSelectors(labels.FromStrings("__name__", "latency", "job", "api"), selectors), ShouldBeTrue)
+		So(matchSelectors(labels.FromStrings("__name__", "latency", "job", "web"), selectors), ShouldBeFalse)
+		So(matchSelectors(labels.FromStrings("__name__", "rtt", "job", "web"), selectors), ShouldBeTrue)
+	})
+}
+
+func mustParseExpr(promql string) parser.Expr {
+	expr, err := parser.ParseExpr(promql)
+	So(err, ShouldBeNil)
+	return expr
+}
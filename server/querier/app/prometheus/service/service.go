@@ -77,6 +77,7 @@ func (s *PrometheusService) PromRemoteReadService(req *prompb.ReadRequest, ctx c
 }
 
 func (s *PrometheusService) PromInstantQueryService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	args.Promql = rewriteNativeHistogramQuery(args.OrgID, args.Promql)
 	if args.Offloading {
 		return s.executor.offloadInstantQueryExecute(ctx, args, s.engine)
 	} else {
@@ -85,6 +86,7 @@ func (s *PrometheusService) PromInstantQueryService(args *model.PromQueryParams,
 }
 
 func (s *PrometheusService) PromRangeQueryService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	args.Promql = rewriteNativeHistogramQuery(args.OrgID, args.Promql)
 	if args.Offloading {
 		return s.executor.offloadRangeQueryExecute(ctx, args, s.engine)
 	} else {
@@ -100,6 +102,26 @@ func (s *PrometheusService) PromSeriesQueryService(args *model.PromQueryParams,
 	return s.executor.series(ctx, args)
 }
 
+func (s *PrometheusService) PromLabelsService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	return s.executor.labels(ctx, args)
+}
+
+func (s *PrometheusService) PromMetadataService(args *model.PromMetaParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	return s.executor.metadata(ctx, args)
+}
+
+func (s *PrometheusService) PromExemplarsQueryService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	return s.executor.queryExemplars(ctx, args)
+}
+
+func (s *PrometheusService) PromFormatQueryService(query string) (*model.PromQueryResponse, error) {
+	return s.executor.formatQuery(query)
+}
+
+func (s *PrometheusService) PromBuildInfoService() *model.PromQueryResponse {
+	return s.executor.buildInfo()
+}
+
 func (s *PrometheusService) PromQLAnalysis(ctx context.Context, metric string, targetLabels []string, appLabels []string, startTime string, endTime string, orgID string) (*common.Result, error) {
 	return s.executor.promQLAnalysis(ctx, metric, targetLabels, appLabels, startTime, endTime, orgID)
 }
@@ -52,8 +52,7 @@ func Deserialize[T any](data []byte) (*T, error) {
 }
 
 func prepareRequest(timeout time.Duration, tlsConfig *config.TLSConfig) (*http.Client, error) {
-	client := &http.Client{}
-	http.DefaultClient.Timeout = timeout
+	client := &http.Client{Timeout: timeout}
 	if tlsConfig != nil {
 		tlsClientConfig := &tls.Config{}
 		if tlsConfig.Insecure {
@@ -83,7 +82,7 @@ func prepareRequest(timeout time.Duration, tlsConfig *config.TLSConfig) (*http.C
 			tlsClientConfig.Certificates = []tls.Certificate{clientTLSCert}
 		}
 
-		http.DefaultClient.Transport = &http.Transport{TLSClientConfig: tlsClientConfig}
+		client.Transport = &http.Transport{TLSClientConfig: tlsClientConfig}
 	}
 	return client, nil
 }
@@ -17,9 +17,17 @@
 package service
 
 import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
 	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
 	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/service/packet_service"
 	"github.com/op/go-logging"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 var (
@@ -27,11 +35,26 @@ var (
 	log_base = logging.MustGetLogger("tracing-adapter.base")
 )
 
+const (
+	L7ProtocolHTTP    = 20
+	L7ProtocolStrHTTP = "HTTP"
+
+	// OpenTracing and OpenTelemetry semantic attributes used by Jaeger and Zipkin
+	AttributeSpanKind   = "span.kind"
+	AttributeHTTPURL    = "http.url"
+	AttributeHTTPTarget = "http.target"
+	AttributeHTTPPath   = "http.path"
+	AttributeSQLQuery   = "sql.query"
+	AttributeRPCMethod  = "rpc.method"
+)
+
 func Register() error {
 	if Adapters == nil {
 		Adapters = make(map[string]model.TraceAdapter, 0)
 	}
 	Adapters["skywalking"] = &SkyWalkingAdapter{}
+	Adapters["jaeger"] = &JaegerAdapter{}
+	Adapters["zipkin"] = &ZipkinAdapter{}
 	subServices := packet_service.GetPacketServices()
 	if subServices != nil {
 		for k, v := range subServices {
@@ -41,3 +64,96 @@ func Register() error {
 	}
 	return nil
 }
+
+// apmAuthConfig is decoded from ExternalAPM.ExtraConfig
+type apmAuthConfig struct {
+	Auth  string `mapstructure:"auth"`  // basic auth, base64 encoded `username:password`
+	Token string `mapstructure:"token"` // bearer token
+}
+
+func (a *apmAuthConfig) headers() map[string]string {
+	header := common.DefaultContentTypeHeader()
+	if a.Auth != "" {
+		header["Authorization"] = fmt.Sprintf("Basic %s", a.Auth)
+	} else if a.Token != "" {
+		header["Authorization"] = fmt.Sprintf("Bearer %s", a.Token)
+	}
+	return header
+}
+
+// apmURL joins the addr and the path, the scheme is added if the addr has no scheme
+func apmURL(c *config.ExternalAPM, path string) string {
+	addr := strings.TrimSuffix(c.Addr, "/")
+	if !strings.Contains(addr, "://") {
+		scheme := "http"
+		if c.TLS != nil {
+			scheme = "https"
+		}
+		addr = fmt.Sprintf("%s://%s", scheme, addr)
+	}
+	return fmt.Sprintf("%s/%s", addr, path)
+}
+
+// exSpanUniqueID generates the unique id of a span in the trace
+func exSpanUniqueID(spanID string, index int) uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(spanID))
+	// high 32 bits: hash of spanID, low 32 bits: index
+	return uint64(h.Sum32())<<32 | uint64(index)&0xffffffff
+}
+
+// parseSpanKind parses the span kind of Jaeger (lower case) and Zipkin (upper case)
+func parseSpanKind(kind string) v1.Span_SpanKind {
+	switch strings.ToLower(kind) {
+	case "server":
+		return v1.Span_SPAN_KIND_SERVER
+	case "client":
+		return v1.Span_SPAN_KIND_CLIENT
+	case "producer":
+		return v1.Span_SPAN_KIND_PRODUCER
+	case "consumer":
+		return v1.Span_SPAN_KIND_CONSUMER
+	case "internal":
+		return v1.Span_SPAN_KIND_INTERNAL
+	default:
+		return v1.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+func spanKindToTapSide(kind v1.Span_SpanKind) string {
+	switch kind {
+	case v1.Span_SPAN_KIND_SERVER, v1.Span_SPAN_KIND_CONSUMER:
+		return "s-app"
+	case v1.Span_SPAN_KIND_CLIENT, v1.Span_SPAN_KIND_PRODUCER:
+		return "c-app"
+	default:
+		return "app"
+	}
+}
+
+// tagsToSpanRequestInfo fills the request info of the span by the semantic tags
+func tagsToSpanRequestInfo(tags map[string]string, span *model.ExSpan) {
+	if method, ok := tags[AttributeHTTPMethod]; ok {
+		span.L7Protocol, span.L7ProtocolStr = L7ProtocolHTTP, L7ProtocolStrHTTP
+		span.RequestType = method
+		for _, key := range []string{AttributeHTTPTarget, AttributeHTTPPath, AttributeHTTPURL} {
+			if resource, ok := tags[key]; ok {
+				span.RequestResource = resource
+				break
+			}
+		}
+		if code, err := strconv.Atoi(tags[AttributeHTTPStatus_Code]); err == nil {
+			span.ResponseStatus = code
+		}
+		return
+	}
+	for _, key := range []string{AttributeDbStatement, AttributeSQLQuery} {
+		if statement, ok := tags[key]; ok {
+			span.RequestResource = statement
+			return
+		}
+	}
+	if method, ok := tags[AttributeRPCMethod]; ok {
+		span.RequestType = method
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
+	"github.com/goccy/go-json"
+	"github.com/mitchellh/mapstructure"
+	"github.com/op/go-logging"
+)
+
+const (
+	// https://www.jaegertracing.io/docs/latest/apis/#http-json-internal
+	jaeger_query_url = "api/traces/%s"
+
+	JaegerRefTypeChildOf = "CHILD_OF"
+
+	JaegerProcessTagHostname = "hostname"
+	JaegerProcessTagIP       = "ip"
+)
+
+type jaegerKeyValue struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"` // string, bool, int64, float64 or binary
+}
+
+func (kv *jaegerKeyValue) String() string {
+	var value string
+	if err := json.Unmarshal(kv.Value, &value); err == nil {
+		return value
+	}
+	return string(kv.Value)
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     int64             `json:"startTime"` // us
+	Duration      int64             `json:"duration"`  // us
+	Tags          []jaegerKeyValue  `json:"tags"`
+	ProcessID     string            `json:"processID"`
+}
+
+type jaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []jaegerKeyValue `json:"tags"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+}
+
+type jaegerError struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	TraceID string `json:"traceID"`
+}
+
+type jaegerResponse struct {
+	Data   []jaegerTrace `json:"data"`
+	Errors []jaegerError `json:"errors"`
+}
+
+// JaegerAdapter gets the trace from the HTTP JSON API of jaeger-query
+type JaegerAdapter struct {
+}
+
+var log_jaeger = logging.MustGetLogger("tracing-adapter.jaeger")
+
+func (j *JaegerAdapter) GetTrace(traceID string, c *config.ExternalAPM) (*model.ExTrace, error) {
+	authConfig := &apmAuthConfig{}
+	err := mapstructure.Decode(c.ExtraConfig, authConfig)
+	if err != nil {
+		log_jaeger.Errorf("cannot decode jaeger extra config %v, err: %s", c.ExtraConfig, err)
+		return nil, err
+	}
+	resp, err := j.getTrace(traceID, c, authConfig)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	return j.jaegerTracesToExTrace(resp.Data), nil
+}
+
+func (j *JaegerAdapter) getTrace(traceID string, c *config.ExternalAPM, authConfig *apmAuthConfig) (*jaegerResponse, error) {
+	addr := apmURL(c, fmt.Sprintf(jaeger_query_url, url.PathEscape(traceID)))
+	result, err := common.DoRequest(http.MethodGet, addr, nil, authConfig.headers(), c.Timeout, c.TLS)
+	if err != nil || result == nil {
+		log_jaeger.Errorf("query jaeger trace %s at %s failed! err: %s", traceID, c.Addr, err)
+		return nil, err
+	}
+	resp, err := common.Deserialize[jaegerResponse](result)
+	if err != nil || resp == nil {
+		log_jaeger.Errorf("deserialize failed! err: %s", err)
+		return nil, err
+	}
+	if len(resp.Data) == 0 && len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("query jaeger trace %s failed, code: %d, msg: %s", traceID, resp.Errors[0].Code, resp.Errors[0].Msg)
+	}
+	return resp, nil
+}
+
+func (j *JaegerAdapter) jaegerTracesToExTrace(traces []jaegerTrace) *model.ExTrace {
+	exTrace := &model.ExTrace{}
+	for _, trace := range traces {
+		if exTrace.Spans == nil {
+			exTrace.Spans = make([]model.ExSpan, 0, len(trace.Spans))
+		}
+		for i := range trace.Spans {
+			jaegerSpan := &trace.Spans[i]
+			process := trace.Processes[jaegerSpan.ProcessID]
+			tags := j.jaegerTagsToAttributes(jaegerSpan.Tags)
+			spanKind := parseSpanKind(tags[AttributeSpanKind])
+			span := model.ExSpan{
+				Name:            jaegerSpan.OperationName,
+				ID:              exSpanUniqueID(jaegerSpan.SpanID, len(exTrace.Spans)),
+				StartTimeUs:     jaegerSpan.StartTime,
+				EndTimeUs:       jaegerSpan.StartTime + jaegerSpan.Duration,
+				TapSide:         spanKindToTapSide(spanKind),
+				TraceID:         jaegerSpan.TraceID,
+				SpanID:          jaegerSpan.SpanID,
+				ParentSpanID:    j.jaegerParentSpanID(jaegerSpan.References),
+				SpanKind:        int(spanKind),
+				Endpoint:        jaegerSpan.OperationName,
+				AppService:      process.ServiceName,
+				AppInstance:     j.jaegerProcessInstance(&process),
+				ServiceUname:    process.ServiceName,
+				RequestResource: jaegerSpan.OperationName, // maybe overwrite by tags
+				SignalSource:    model.L7_FLOW_SIGNAL_SOURCE_OTEL,
+				Attribute:       tags,
+			}
+			tagsToSpanRequestInfo(tags, &span)
+			exTrace.Spans = append(exTrace.Spans, span)
+		}
+	}
+	return exTrace
+}
+
+func (j *JaegerAdapter) jaegerParentSpanID(refs []jaegerReference) string {
+	// a span has ONE parent in DeepFlow, prefer the CHILD_OF reference to the FOLLOWS_FROM reference
+	for _, ref := range refs {
+		if ref.RefType == JaegerRefTypeChildOf {
+			return ref.SpanID
+		}
+	}
+	if len(refs) > 0 {
+		return refs[0].SpanID
+	}
+	return ""
+}
+
+func (j *JaegerAdapter) jaegerProcessInstance(process *jaegerProcess) string {
+	var ip string
+	for i := range process.Tags {
+		switch process.Tags[i].Key {
+		case JaegerProcessTagHostname:
+			return process.Tags[i].String()
+		case JaegerProcessTagIP:
+			ip = process.Tags[i].String()
+		}
+	}
+	return ip
+}
+
+func (j *JaegerAdapter) jaegerTagsToAttributes(tags []jaegerKeyValue) map[string]string {
+	attr := make(map[string]string, len(tags))
+	for i := range tags {
+		attr[tags[i].Key] = tags[i].String()
+	}
+	return attr
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	. "github.com/smartystreets/goconvey/convey"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// recorded from jaeger-query 1.50 of the HotROD demo
+var jaeger_mock_data = `{
+"data": [
+    {
+        "traceID": "6d1b6a3c5f5e0f1a",
+        "spans": [
+            {
+                "traceID": "6d1b6a3c5f5e0f1a",
+                "spanID": "6d1b6a3c5f5e0f1a",
+                "operationName": "HTTP GET /dispatch",
+                "references": [],
+                "startTime": 1700000000000000,
+                "duration": 705000,
+                "tags": [
+                    {"key": "span.kind", "type": "string", "value": "server"},
+                    {"key": "http.method", "type": "string", "value": "GET"},
+                    {"key": "http.url", "type": "string", "value": "/dispatch?customer=123"},
+                    {"key": "http.status_code", "type": "int64", "value": 200}
+                ],
+                "logs": [],
+                "processID": "p1",
+                "warnings": null
+            },
+            {
+                "traceID": "6d1b6a3c5f5e0f1a",
+                "spanID": "2a4c8f1e9b7d3c05",
+                "operationName": "SQL SELECT",
+                "references": [
+                    {"refType": "FOLLOWS_FROM", "traceID": "6d1b6a3c5f5e0f1a", "spanID": "0000000000000001"},
+                    {"refType": "CHILD_OF", "traceID": "6d1b6a3c5f5e0f1a", "spanID": "6d1b6a3c5f5e0f1a"}
+                ],
+                "startTime": 1700000000001000,
+                "duration": 300000,
+                "tags": [
+                    {"key": "span.kind", "type": "string", "value": "client"},
+                    {"key": "db.statement", "type": "string", "value": "SELECT * FROM customer WHERE customer_id=123"},
+                    {"key": "error", "type": "bool", "value": false}
+                ],
+                "logs": [],
+                "processID": "p2",
+                "warnings": null
+            }
+        ],
+        "processes": {
+            "p1": {
+                "serviceName": "frontend",
+                "tags": [
+                    {"key": "ip", "type": "string", "value": "10.1.2.3"},
+                    {"key": "hostname", "type": "string", "value": "frontend-7b9c"}
+                ]
+            },
+            "p2": {
+                "serviceName": "mysql",
+                "tags": [
+                    {"key": "ip", "type": "string", "value": "10.1.2.4"}
+                ]
+            }
+        },
+        "warnings": null
+    }
+],
+"total": 0,
+"limit": 0,
+"offset": 0,
+"errors": null
+}`
+
+var jaeger_mock_not_found = `{"data": null, "total": 0, "limit": 0, "offset": 0, "errors": [{"code": 404, "msg": "trace not found"}]}`
+
+func TestGetJaegerTrace(t *testing.T) {
+	jaegerAdapter := &JaegerAdapter{}
+	Convey("TestGetJaegerTrace_Success", t, func() {
+		resp, err := common.Deserialize[jaegerResponse]([]byte(jaeger_mock_data))
+		So(err, ShouldBeNil)
+		result := jaegerAdapter.jaegerTracesToExTrace(resp.Data)
+		So(len(result.Spans), ShouldEqual, 2)
+
+		server := result.Spans[0]
+		So(server.ID, ShouldBeGreaterThan, 0)
+		So(server.TraceID, ShouldEqual, "6d1b6a3c5f5e0f1a")
+		So(server.SpanID, ShouldEqual, "6d1b6a3c5f5e0f1a")
+		So(server.ParentSpanID, ShouldEqual, "")
+		So(server.StartTimeUs, ShouldEqual, 1700000000000000)
+		So(server.EndTimeUs, ShouldEqual, 1700000000705000)
+		So(server.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_SERVER))
+		So(server.TapSide, ShouldEqual, "s-app")
+		So(server.AppService, ShouldEqual, "frontend")
+		So(server.AppInstance, ShouldEqual, "frontend-7b9c")
+		So(server.L7ProtocolStr, ShouldEqual, L7ProtocolStrHTTP)
+		So(server.RequestType, ShouldEqual, "GET")
+		So(server.RequestResource, ShouldEqual, "/dispatch?customer=123")
+		So(server.ResponseStatus, ShouldEqual, 200)
+		So(server.Attribute["http.status_code"], ShouldEqual, "200")
+
+		client := result.Spans[1]
+		So(client.ID, ShouldNotEqual, server.ID)
+		So(client.ParentSpanID, ShouldEqual, "6d1b6a3c5f5e0f1a")
+		So(client.TapSide, ShouldEqual, "c-app")
+		So(client.AppInstance, ShouldEqual, "10.1.2.4")
+		So(client.RequestResource, ShouldEqual, "SELECT * FROM customer WHERE customer_id=123")
+		So(client.Attribute["error"], ShouldEqual, "false")
+	})
+
+	Convey("TestGetJaegerTrace_Request", t, func() {
+		var path, auth string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, auth = r.URL.Path, r.Header.Get("Authorization")
+			w.Write([]byte(jaeger_mock_data))
+		}))
+		defer server.Close()
+
+		c := &config.ExternalAPM{Name: "jaeger", Addr: server.URL, Timeout: time.Second, ExtraConfig: map[string]string{"token": "secret"}}
+		result, err := jaegerAdapter.GetTrace("6d1b6a3c5f5e0f1a", c)
+		So(err, ShouldBeNil)
+		So(len(result.Spans), ShouldEqual, 2)
+		So(path, ShouldEqual, "/api/traces/6d1b6a3c5f5e0f1a")
+		So(auth, ShouldEqual, "Bearer secret")
+	})
+
+	Convey("TestGetJaegerTrace_Error", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(jaeger_mock_not_found))
+		}))
+		defer server.Close()
+		_, err := jaegerAdapter.GetTrace("1", &config.ExternalAPM{Addr: server.URL, Timeout: time.Second})
+		So(err, ShouldNotBeNil)
+
+		slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(500 * time.Millisecond)
+			w.Write([]byte(jaeger_mock_data))
+		}))
+		defer slowServer.Close()
+		_, err = jaegerAdapter.GetTrace("1", &config.ExternalAPM{Addr: slowServer.URL, Timeout: 50 * time.Millisecond})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
+	"github.com/mitchellh/mapstructure"
+	"github.com/op/go-logging"
+)
+
+const (
+	// https://zipkin.io/zipkin-api/#/default/get_trace__traceId_
+	zipkin_query_url = "api/v2/trace/%s"
+
+	// the server span shares the span id with the client span in Zipkin, it is suffixed to be unique in DeepFlow
+	ZipkinSharedSpanIDSuffix = "-shared"
+)
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type zipkinSpan struct {
+	TraceID        string            `json:"traceId"`
+	ParentID       string            `json:"parentId"`
+	ID             string            `json:"id"`
+	Kind           string            `json:"kind"`
+	Name           string            `json:"name"`
+	Timestamp      int64             `json:"timestamp"` // us
+	Duration       int64             `json:"duration"`  // us
+	LocalEndpoint  *zipkinEndpoint   `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint   `json:"remoteEndpoint"`
+	Tags           map[string]string `json:"tags"`
+	Shared         bool              `json:"shared"`
+}
+
+// ZipkinAdapter gets the trace from the Zipkin v2 API
+type ZipkinAdapter struct {
+}
+
+var log_zipkin = logging.MustGetLogger("tracing-adapter.zipkin")
+
+func (z *ZipkinAdapter) GetTrace(traceID string, c *config.ExternalAPM) (*model.ExTrace, error) {
+	authConfig := &apmAuthConfig{}
+	err := mapstructure.Decode(c.ExtraConfig, authConfig)
+	if err != nil {
+		log_zipkin.Errorf("cannot decode zipkin extra config %v, err: %s", c.ExtraConfig, err)
+		return nil, err
+	}
+	spans, err := z.getTrace(traceID, c, authConfig)
+	if err != nil || spans == nil {
+		return nil, err
+	}
+	return z.zipkinSpansToExTrace(*spans), nil
+}
+
+func (z *ZipkinAdapter) getTrace(traceID string, c *config.ExternalAPM, authConfig *apmAuthConfig) (*[]zipkinSpan, error) {
+	addr := apmURL(c, fmt.Sprintf(zipkin_query_url, url.PathEscape(traceID)))
+	result, err := common.DoRequest(http.MethodGet, addr, nil, authConfig.headers(), c.Timeout, c.TLS)
+	if err != nil || result == nil {
+		log_zipkin.Errorf("query zipkin trace %s at %s failed! err: %s", traceID, c.Addr, err)
+		return nil, err
+	}
+	spans, err := common.Deserialize[[]zipkinSpan](result)
+	if err != nil || spans == nil {
+		log_zipkin.Errorf("deserialize failed! err: %s", err)
+		return nil, err
+	}
+	return spans, nil
+}
+
+func (z *ZipkinAdapter) zipkinSpansToExTrace(spans []zipkinSpan) *model.ExTrace {
+	// the service of the shared server spans, the children of a shared server span have the same service
+	sharedServices := make(map[string]string)
+	for i := range spans {
+		if spans[i].Shared {
+			sharedServices[spans[i].ID] = z.zipkinServiceName(spans[i].LocalEndpoint)
+		}
+	}
+
+	exTrace := &model.ExTrace{}
+	exTrace.Spans = make([]model.ExSpan, 0, len(spans))
+	for i := range spans {
+		zipkinSpan := &spans[i]
+		service := z.zipkinServiceName(zipkinSpan.LocalEndpoint)
+		spanID, parentSpanID := zipkinSpan.ID, zipkinSpan.ParentID
+		if zipkinSpan.Shared {
+			spanID, parentSpanID = zipkinSpan.ID+ZipkinSharedSpanIDSuffix, zipkinSpan.ID
+		} else if sharedService, ok := sharedServices[parentSpanID]; ok && sharedService == service {
+			parentSpanID += ZipkinSharedSpanIDSuffix
+		}
+		spanKind := parseSpanKind(zipkinSpan.Kind)
+		tags := zipkinSpan.Tags
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		span := model.ExSpan{
+			Name:            zipkinSpan.Name,
+			ID:              exSpanUniqueID(spanID, i),
+			StartTimeUs:     zipkinSpan.Timestamp,
+			EndTimeUs:       zipkinSpan.Timestamp + zipkinSpan.Duration,
+			TapSide:         spanKindToTapSide(spanKind),
+			TraceID:         zipkinSpan.TraceID,
+			SpanID:          spanID,
+			ParentSpanID:    parentSpanID,
+			SpanKind:        int(spanKind),
+			Endpoint:        zipkinSpan.Name,
+			AppService:      service,
+			AppInstance:     z.zipkinInstance(zipkinSpan.LocalEndpoint),
+			ServiceUname:    service,
+			RequestResource: zipkinSpan.Name, // maybe overwrite by tags
+			SignalSource:    model.L7_FLOW_SIGNAL_SOURCE_OTEL,
+			Attribute:       tags,
+		}
+		tagsToSpanRequestInfo(tags, &span)
+		exTrace.Spans = append(exTrace.Spans, span)
+	}
+	return exTrace
+}
+
+func (z *ZipkinAdapter) zipkinServiceName(endpoint *zipkinEndpoint) string {
+	if endpoint == nil {
+		return ""
+	}
+	return endpoint.ServiceName
+}
+
+func (z *ZipkinAdapter) zipkinInstance(endpoint *zipkinEndpoint) string {
+	if endpoint == nil {
+		return ""
+	}
+	if endpoint.IPv4 != "" {
+		return endpoint.IPv4
+	}
+	return endpoint.IPv6
+}
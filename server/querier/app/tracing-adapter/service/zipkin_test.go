@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	. "github.com/smartystreets/goconvey/convey"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// recorded from zipkin 2.24 of the brave webmvc example, the server span shares the id with the client span
+var zipkin_mock_data = `[
+    {
+        "traceId": "5af7183fb1d4cf5f",
+        "id": "5af7183fb1d4cf5f",
+        "kind": "SERVER",
+        "name": "get /",
+        "timestamp": 1700000000000000,
+        "duration": 25000,
+        "localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.1.2"},
+        "remoteEndpoint": {"ipv6": "::1", "port": 63260},
+        "tags": {"http.method": "GET", "http.path": "/", "http.status_code": "200"}
+    },
+    {
+        "traceId": "5af7183fb1d4cf5f",
+        "parentId": "5af7183fb1d4cf5f",
+        "id": "352bff9a74ca9ad2",
+        "kind": "CLIENT",
+        "name": "get /api",
+        "timestamp": 1700000000002000,
+        "duration": 20000,
+        "localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.1.2"},
+        "remoteEndpoint": {"serviceName": "backend", "ipv4": "192.168.1.3", "port": 9000},
+        "tags": {"http.method": "GET", "http.path": "/api"}
+    },
+    {
+        "traceId": "5af7183fb1d4cf5f",
+        "parentId": "5af7183fb1d4cf5f",
+        "id": "352bff9a74ca9ad2",
+        "kind": "SERVER",
+        "name": "get /api",
+        "timestamp": 1700000000003000,
+        "duration": 18000,
+        "localEndpoint": {"serviceName": "backend", "ipv4": "192.168.1.3"},
+        "remoteEndpoint": {"ipv4": "192.168.1.2", "port": 54444},
+        "tags": {"http.method": "GET", "http.path": "/api", "http.status_code": "500", "error": "500"},
+        "shared": true
+    },
+    {
+        "traceId": "5af7183fb1d4cf5f",
+        "parentId": "352bff9a74ca9ad2",
+        "id": "a1b2c3d4e5f60718",
+        "name": "query",
+        "timestamp": 1700000000004000,
+        "duration": 1000,
+        "localEndpoint": {"serviceName": "backend", "ipv4": "192.168.1.3"},
+        "tags": {"sql.query": "SELECT 1"}
+    }
+]`
+
+func TestGetZipkinTrace(t *testing.T) {
+	zipkinAdapter := &ZipkinAdapter{}
+	Convey("TestGetZipkinTrace_Success", t, func() {
+		spans, err := common.Deserialize[[]zipkinSpan]([]byte(zipkin_mock_data))
+		So(err, ShouldBeNil)
+		result := zipkinAdapter.zipkinSpansToExTrace(*spans)
+		So(len(result.Spans), ShouldEqual, 4)
+
+		root := result.Spans[0]
+		So(root.SpanID, ShouldEqual, "5af7183fb1d4cf5f")
+		So(root.ParentSpanID, ShouldEqual, "")
+		So(root.EndTimeUs-root.StartTimeUs, ShouldEqual, 25000)
+		So(root.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_SERVER))
+		So(root.TapSide, ShouldEqual, "s-app")
+		So(root.AppService, ShouldEqual, "frontend")
+		So(root.AppInstance, ShouldEqual, "192.168.1.2")
+		So(root.RequestType, ShouldEqual, "GET")
+		So(root.RequestResource, ShouldEqual, "/")
+		So(root.ResponseStatus, ShouldEqual, 200)
+
+		client, server, local := result.Spans[1], result.Spans[2], result.Spans[3]
+		So(client.SpanID, ShouldEqual, "352bff9a74ca9ad2")
+		So(client.ParentSpanID, ShouldEqual, root.SpanID)
+		So(client.TapSide, ShouldEqual, "c-app")
+		// the shared server span is the child of the client span
+		So(server.SpanID, ShouldEqual, "352bff9a74ca9ad2"+ZipkinSharedSpanIDSuffix)
+		So(server.ParentSpanID, ShouldEqual, client.SpanID)
+		So(server.ID, ShouldNotEqual, client.ID)
+		So(server.ResponseStatus, ShouldEqual, 500)
+		So(server.Attribute["error"], ShouldEqual, "500")
+		So(local.ParentSpanID, ShouldEqual, server.SpanID)
+		So(local.TapSide, ShouldEqual, "app")
+		So(local.RequestResource, ShouldEqual, "SELECT 1")
+	})
+
+	Convey("TestGetZipkinTrace_Request", t, func() {
+		var path, auth string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, auth = r.URL.Path, r.Header.Get("Authorization")
+			w.Write([]byte(zipkin_mock_data))
+		}))
+		defer server.Close()
+
+		c := &config.ExternalAPM{Name: "zipkin", Addr: server.URL, Timeout: time.Second, ExtraConfig: map[string]string{"auth": "dXNlcjpwYXNz"}}
+		result, err := zipkinAdapter.GetTrace("5af7183fb1d4cf5f", c)
+		So(err, ShouldBeNil)
+		So(len(result.Spans), ShouldEqual, 4)
+		So(path, ShouldEqual, "/api/v2/trace/5af7183fb1d4cf5f")
+		So(auth, ShouldEqual, "Basic dXNlcjpwYXNz")
+	})
+
+	Convey("TestGetZipkinTrace_NotFound", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+		_, err := zipkinAdapter.GetTrace("1", &config.ExternalAPM{Addr: server.URL, Timeout: time.Second})
+		So(err, ShouldNotBeNil)
+	})
+}
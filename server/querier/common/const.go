@@ -46,8 +46,9 @@ const (
 )
 
 const (
-	HEADER_KEY_X_ORG_ID = "X-Org-Id"
-	DEFAULT_ORG_ID      = "1"
+	HEADER_KEY_X_ORG_ID  = "X-Org-Id"
+	HEADER_KEY_X_USER_ID = "X-User-Id"
+	DEFAULT_ORG_ID       = "1"
 )
 
 const NO_LIMIT = "-1"
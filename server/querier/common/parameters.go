@@ -23,17 +23,19 @@ import (
 )
 
 type QuerierParams struct {
-	Debug         string
-	UseQueryCache bool
-	QueryCacheTTL string
-	QueryUUID     string
-	DB            string
-	Sql           string
-	DataSource    string
-	Context       context.Context
-	NoPreWhere    bool
-	ORGID         string
-	SimpleSql     bool
+	Debug          string
+	UseQueryCache  bool
+	QueryCacheTTL  string
+	UseResultCache bool
+	QueryUUID      string
+	DB             string
+	Sql            string
+	DataSource     string
+	Context        context.Context
+	NoPreWhere     bool
+	ORGID          string
+	UserID         string
+	SimpleSql      bool
 }
 
 type TempoParams struct {
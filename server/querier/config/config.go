@@ -62,6 +62,8 @@ type QuerierConfig struct {
 	MaxPrometheusIdSubqueryLruEntry int                           `default:"8000" yaml:"max-prometheus-id-subquery-lru-entry"`
 	PrometheusIdSubqueryLruTimeout  int                           `default:"60" yaml:"prometheus-id-subquery-lru-timeout"`
 	AutoCustomTags                  []AutoCustomTags              `yaml:"auto-custom-tags" binding:"omitempty,dive"`
+	QueryCache                      QueryCache                    `yaml:"query-cache"`
+	MaxConcurrentQueryPerOrg        int                           `default:"0" yaml:"max-concurrent-query-per-org"`
 }
 
 type DeepflowApp struct {
@@ -90,6 +92,15 @@ type Clickhouse struct {
 	ConnectTimeout int    `default:"2" yaml:"connect-timeout"`
 	MaxConnection  int    `default:"20" yaml:"max-connection"`
 }
+
+type QueryCache struct {
+	Enabled    bool `default:"true" yaml:"enabled"`
+	MaxCount   int  `default:"1024" yaml:"max-count"`  // max count of the cached query results
+	MaxRows    int  `default:"100000" yaml:"max-rows"` // the results with more rows are not cached
+	TTL        int  `default:"300" yaml:"ttl"`         // unit: s
+	FreshDelay int  `default:"60" yaml:"fresh-delay"`  // the data in the latest fresh-delay seconds may be incomplete and is always queried, unit: s
+}
+
 type AutoCustomTags struct {
 	TagName     string   `default:"" yaml:"tag-name"`
 	TagFields   []string `yaml:"tag-fields" binding:"omitempty,dive"`
@@ -113,6 +113,33 @@ func init() {
 }
 
 func (e *CHEngine) ExecuteQuery(args *common.QuerierParams) (*common.Result, map[string]interface{}, error) {
+	if sql, ok := parse.ParseExplain(args.Sql); ok {
+		return e.Explain(sql, args)
+	}
+	if sql, offset, ok := ParseCompareSql(args.Sql); ok {
+		e.Context = args.Context
+		return e.QueryCompare(sql, offset, args)
+	}
+	if args.UseResultCache {
+		if cache := GetQueryCache(); cache != nil {
+			return cache.Execute(args, e.cachedQuery)
+		}
+	}
+	return e.executeQuery(args)
+}
+
+// cachedQuery executes the query with a new engine, so that the query could be split by the query cache
+func (e *CHEngine) cachedQuery(args *common.QuerierParams) (*common.Result, map[string]interface{}, *querySeries, error) {
+	engine := &CHEngine{DB: e.DB, DataSource: e.DataSource, Context: e.Context}
+	engine.Init()
+	result, debug, err := engine.executeQuery(args)
+	if err != nil {
+		return result, debug, nil, err
+	}
+	return result, debug, engine.querySeries(args.Sql, result), nil
+}
+
+func (e *CHEngine) executeQuery(args *common.QuerierParams) (*common.Result, map[string]interface{}, error) {
 	// 解析show开头的sql
 	// show metrics/tags from <table_name> 例：show metrics/tags from l4_flow_log
 	var err error
@@ -229,6 +256,7 @@ func (e *CHEngine) ExecuteQuery(args *common.QuerierParams) (*common.Result, map
 			QueryUUID:       query_uuid,
 			ColumnSchemaMap: ColumnSchemaMap,
 			ORGID:           args.ORGID,
+			UserID:          args.UserID,
 		}
 		if !isShow {
 			params.Callbacks = callbacks
@@ -502,6 +530,7 @@ func (e *CHEngine) QuerySlimitSql(sql string, args *common.QuerierParams) (*comm
 		QueryUUID:       query_uuid,
 		ColumnSchemaMap: columnSchemaMap,
 		ORGID:           args.ORGID,
+		UserID:          args.UserID,
 	}
 	rst, err := chClient.DoQuery(params)
 	if err != nil {
@@ -863,6 +892,7 @@ func (e *CHEngine) QueryWithSql(sql string, args *common.QuerierParams) (*common
 		QueryUUID:       query_uuid,
 		ColumnSchemaMap: columnSchemaMap,
 		ORGID:           args.ORGID,
+		UserID:          args.UserID,
 	}
 	rst, err := chClient.DoQuery(params)
 	if err != nil {
@@ -669,6 +669,34 @@ func TestGetSql(t *testing.T) {
 	}
 }
 
+func TestExplain(t *testing.T) {
+	Load()
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	mockDatasources()
+
+	sql, ok := parse.ParseExplain("EXPLAIN\n select pod_ns, topK(pod, 10) from `vtap_app_port.1h` WHERE time>=1687315761 AND time<=1687316661 group by pod_ns limit 10")
+	if !ok {
+		t.Fatalf("explain sql is not parsed")
+	}
+	if _, ok := parse.ParseExplain("select explain from `vtap_app_port.1h`"); ok {
+		t.Errorf("the select sql should not be parsed as explain")
+	}
+	e := CHEngine{DB: "flow_metrics", Context: context.Background()}
+	e.Init()
+	chSql, err := e.explainSql(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(chSql, "FROM flow_metrics.`application.1h`") || e.Model.From.ToString() != "flow_metrics.`application.1h`" {
+		t.Errorf("got sql %s, tables %s", chSql, e.Model.From.ToString())
+	}
+	translations := e.explainTagTranslations()
+	if translations["pod_ns"] != "dictGet('flow_tag.pod_ns_map', 'name', (toUInt64(pod_ns_id)))" {
+		t.Errorf("got tag translations %v", translations)
+	}
+}
+
 /* func TestGetSqltest(t *testing.T) {
 	 for _, pcase := range parsetest {
 		 e := CHEngine{DB: "flow_log"}
@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -45,6 +46,7 @@ type QueryParams struct {
 	QueryUUID       string
 	ColumnSchemaMap map[string]*common.ColumnSchema
 	ORGID           string
+	UserID          string
 	SimpleSql       bool
 }
 
@@ -135,8 +137,48 @@ func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error)
 	if c.Context == nil {
 		ctx = context.Background()
 	}
-	rows, err := c.connection.Query(ctx, sqlstr)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	orgID := params.ORGID
+	if orgID == "" {
+		orgID = common.DEFAULT_ORG_ID
+	}
+	runningQuery := &runningQuery{
+		queryUUID: c.Debug.QueryUUID,
+		sql:       sqlstr,
+		orgID:     orgID,
+		userID:    params.UserID,
+		startTime: start,
+		cancel:    cancel,
+	}
 	c.Debug.Sql = sqlstr
+	if err := RunningQueries.add(runningQuery, config.Cfg.MaxConcurrentQueryPerOrg); err != nil {
+		c.Debug.Error = fmt.Sprintf("%s", err)
+		return nil, err
+	}
+	defer RunningQueries.remove(runningQuery)
+	// closing the connection does not always stop the query in ClickHouse, kill it when the request is
+	// aborted or the query is canceled
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-finished:
+			default:
+				killQuery(runningQuery.queryID)
+			}
+		case <-finished:
+		}
+	}()
+	ctx = clickhouse.Context(ctx,
+		clickhouse.WithQueryID(runningQuery.queryID),
+		clickhouse.WithProgress(func(p *clickhouse.Progress) {
+			atomic.AddUint64(&runningQuery.readRows, p.Rows)
+		}),
+	)
+	rows, err := c.connection.Query(ctx, sqlstr)
 	if err != nil {
 		log.Errorf("query clickhouse Error: %s, sql: %s, query_uuid: %s", err, sqlstr, c.Debug.QueryUUID)
 		c.Debug.Error = fmt.Sprintf("%s", err)
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+const KILL_QUERY_TIMEOUT = 10 * time.Second
+
+// RunningQuery is the snapshot of a query being executed in ClickHouse
+type RunningQuery struct {
+	QueryUUID string    `json:"query_uuid"`
+	Sql       string    `json:"sql"`
+	ORGID     string    `json:"org_id"`
+	UserID    string    `json:"user_id"`
+	StartTime time.Time `json:"start_time"`
+	Elapsed   float64   `json:"elapsed"` // unit: s
+	ReadRows  uint64    `json:"read_rows"`
+}
+
+type runningQuery struct {
+	queryUUID string
+	queryID   string // the query_id in ClickHouse, a query uuid may be used by several ClickHouse queries
+	sql       string
+	orgID     string
+	userID    string
+	startTime time.Time
+	readRows  uint64
+	cancel    context.CancelFunc
+}
+
+func (q *runningQuery) snapshot(now time.Time) *RunningQuery {
+	return &RunningQuery{
+		QueryUUID: q.queryUUID,
+		Sql:       q.sql,
+		ORGID:     q.orgID,
+		UserID:    q.userID,
+		StartTime: q.startTime,
+		Elapsed:   now.Sub(q.startTime).Seconds(),
+		ReadRows:  atomic.LoadUint64(&q.readRows),
+	}
+}
+
+type runningQueries struct {
+	sync.Mutex
+	queries  map[string]*runningQuery // query_id -> query
+	orgCount map[string]int
+	sequence uint64
+}
+
+var RunningQueries = &runningQueries{
+	queries:  make(map[string]*runningQuery),
+	orgCount: make(map[string]int),
+}
+
+// add registers the query, an error is returned if the org has reached maxPerOrg running queries, 0 means no limit
+func (r *runningQueries) add(q *runningQuery, maxPerOrg int) error {
+	r.Lock()
+	defer r.Unlock()
+	if maxPerOrg > 0 && r.orgCount[q.orgID] >= maxPerOrg {
+		return common.NewError(
+			common.RESOURCE_NUM_EXCEEDED,
+			fmt.Sprintf("org %s has %d running queries, exceeding the limit %d", q.orgID, r.orgCount[q.orgID], maxPerOrg),
+		)
+	}
+	r.sequence++
+	q.queryID = fmt.Sprintf("%s-%d", q.queryUUID, r.sequence)
+	r.queries[q.queryID] = q
+	r.orgCount[q.orgID]++
+	return nil
+}
+
+func (r *runningQueries) remove(q *runningQuery) {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.queries[q.queryID]; !ok {
+		return
+	}
+	delete(r.queries, q.queryID)
+	r.orgCount[q.orgID]--
+	if r.orgCount[q.orgID] <= 0 {
+		delete(r.orgCount, q.orgID)
+	}
+}
+
+// List returns the running queries of the org ordered by the start time, all orgs are returned if orgID is empty
+func (r *runningQueries) List(orgID string) []*RunningQuery {
+	now := time.Now()
+	r.Lock()
+	queries := make([]*RunningQuery, 0, len(r.queries))
+	for _, q := range r.queries {
+		if orgID == "" || q.orgID == orgID {
+			queries = append(queries, q.snapshot(now))
+		}
+	}
+	r.Unlock()
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].StartTime.Before(queries[j].StartTime)
+	})
+	return queries
+}
+
+// Kill cancels the running queries of the query uuid in the org, and returns the count of the canceled queries.
+// The canceled queries are killed in ClickHouse by DoQuery.
+func (r *runningQueries) Kill(orgID, queryUUID string) int {
+	r.Lock()
+	defer r.Unlock()
+	count := 0
+	for _, q := range r.queries {
+		if q.queryUUID == queryUUID && (orgID == "" || q.orgID == orgID) {
+			q.cancel()
+			count++
+		}
+	}
+	return count
+}
+
+func killQuery(queryID string) {
+	if connection == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), KILL_QUERY_TIMEOUT)
+	defer cancel()
+	sql := fmt.Sprintf("KILL QUERY WHERE query_id=%s ASYNC", quoteString(queryID))
+	if err := connection.Exec(ctx, sql); err != nil {
+		log.Warningf("kill query %s failed: %s", queryID, err)
+		return
+	}
+	log.Infof("kill query %s", queryID)
+}
+
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunningQueries(t *testing.T) {
+	r := &runningQueries{
+		queries:  make(map[string]*runningQuery),
+		orgCount: make(map[string]int),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Now()
+	a := &runningQuery{queryUUID: "a", orgID: "1", startTime: now, cancel: cancel}
+	b := &runningQuery{queryUUID: "a", orgID: "1", startTime: now.Add(time.Second), cancel: func() {}}
+	c := &runningQuery{queryUUID: "c", orgID: "2", startTime: now, cancel: func() {}}
+	for _, q := range []*runningQuery{a, b, c} {
+		if err := r.add(q, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.queryID == b.queryID {
+		t.Errorf("the queries of the same uuid have the same query id %s", a.queryID)
+	}
+	if err := r.add(&runningQuery{queryUUID: "d", orgID: "1", cancel: func() {}}, 2); err == nil {
+		t.Errorf("the running queries of org 1 should exceed the limit")
+	}
+
+	if queries := r.List("1"); len(queries) != 2 || queries[0].StartTime != now || queries[0].QueryUUID != "a" {
+		t.Errorf("got running queries %+v", queries)
+	}
+	if queries := r.List(""); len(queries) != 3 {
+		t.Errorf("got %d running queries, expect 3", len(queries))
+	}
+
+	// the queries of the other orgs can not be killed
+	if count := r.Kill("2", "a"); count != 0 {
+		t.Errorf("killed %d queries of org 1 in org 2", count)
+	}
+	if count := r.Kill("1", "a"); count != 2 || ctx.Err() == nil {
+		t.Errorf("killed %d queries, context error %v", count, ctx.Err())
+	}
+
+	r.remove(a)
+	r.remove(a)
+	if r.orgCount["1"] != 1 {
+		t.Errorf("got %d running queries of org 1, expect 1", r.orgCount["1"])
+	}
+	if err := r.add(&runningQuery{queryUUID: "d", orgID: "1", cancel: func() {}}, 2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	if s := quoteString(`a'b\`); s != `'a\'b\\'` {
+		t.Errorf("got %s", s)
+	}
+}
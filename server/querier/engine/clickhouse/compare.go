@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/view"
+)
+
+const (
+	COMPARE_BASELINE_SUFFIX = "_baseline"
+	COMPARE_DELTA_SUFFIX    = "_delta"
+	COMPARE_RATIO_SUFFIX    = "_ratio"
+)
+
+// select Sum(byte) AS sum_byte from network.1m where time>=x and time<=y group by time(time, 60) COMPARE TO 1d
+var compareRegexp = regexp.MustCompile(`(?is)^(.*\S)\s+compare\s+to\s+(\d+)\s*([smhdw]?)\s*$`)
+
+var compareUnits = map[string]int64{
+	"":  1,
+	"s": 1,
+	"m": 60,
+	"h": 3600,
+	"d": 86400,
+	"w": 86400 * 7,
+}
+
+// ParseCompareSql splits `<select statement> COMPARE TO <offset>` to the select statement and the offset in seconds
+func ParseCompareSql(sql string) (string, int64, bool) {
+	match := compareRegexp.FindStringSubmatch(sql)
+	if match == nil {
+		return "", 0, false
+	}
+	offset, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return match[1], offset * compareUnits[strings.ToLower(match[3])], true
+}
+
+// QueryCompare executes the sql over the time range and the time range shifted by the offset, the rows of the shifted
+// query are aligned to the rows of the sql by the tags and the shifted time, and each metric column is followed by
+// the baseline, delta (value - baseline) and ratio (value / baseline) columns
+func (e *CHEngine) QueryCompare(sql string, offset int64, args *common.QuerierParams) (*common.Result, map[string]interface{}, error) {
+	if offset <= 0 {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, "the offset of compare should be greater than 0")
+	}
+	starts := queryTimeStartRegexp.FindAllStringSubmatch(sql, -1)
+	ends := queryTimeEndRegexp.FindAllStringSubmatch(sql, -1)
+	if len(starts) != 1 || len(ends) != 1 {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, "compare requires a single time>= filter and a single time<= filter")
+	}
+	start, _ := strconv.ParseInt(starts[0][1], 10, 64)
+	end, _ := strconv.ParseInt(ends[0][1], 10, 64)
+	if start < offset {
+		return nil, nil, common.NewError(common.INVALID_POST_DATA, fmt.Sprintf("the offset %d is greater than the start time %d", offset, start))
+	}
+
+	debugInfo := &client.DebugInfo{}
+	result, model, debug, err := e.compareQuery(sql, args)
+	appendDebugInfo(debugInfo, debug)
+	if err != nil {
+		return nil, debugInfo.Get(), err
+	}
+	baseline, _, debug, err := e.compareQuery(rewriteQueryTime(sql, start-offset, end-offset), args)
+	appendDebugInfo(debugInfo, debug)
+	if err != nil {
+		return nil, debugInfo.Get(), err
+	}
+	return alignCompareResult(result, baseline, model, offset), debugInfo.Get(), nil
+}
+
+// compareQuery executes the sql with a new engine, and returns the model of the engine for the alignment
+func (e *CHEngine) compareQuery(sql string, args *common.QuerierParams) (*common.Result, *view.Model, map[string]interface{}, error) {
+	engine := &CHEngine{DB: e.DB, DataSource: e.DataSource, Context: e.Context}
+	engine.Init()
+	queryArgs := *args
+	queryArgs.Sql = sql
+	result, debug, err := engine.executeQuery(&queryArgs)
+	return result, engine.Model, debug, err
+}
+
+func appendDebugInfo(debugInfo *client.DebugInfo, debug map[string]interface{}) {
+	if debugs, ok := debug["query_sqls"].([]client.Debug); ok {
+		debugInfo.Debug = append(debugInfo.Debug, debugs...)
+	}
+}
+
+func alignCompareResult(result, baseline *common.Result, model *view.Model, offset int64) *common.Result {
+	if result == nil {
+		return result
+	}
+	timeIndex := -1
+	if model != nil && model.Time != nil && model.Time.Alias != "" {
+		alias := strings.Trim(model.Time.Alias, "`")
+		for i, column := range result.Columns {
+			if name, _ := column.(string); name == alias {
+				timeIndex = i
+				break
+			}
+		}
+	}
+	var tagIndexes, metricIndexes []int
+	for i := range result.Columns {
+		if i == timeIndex {
+			continue
+		}
+		if i < len(result.Schemas) && result.Schemas[i] != nil && result.Schemas[i].Type == common.COLUMN_SCHEMA_TYPE_METRICS {
+			metricIndexes = append(metricIndexes, i)
+		} else {
+			tagIndexes = append(tagIndexes, i)
+		}
+	}
+
+	rowKey := func(row []interface{}, shift int64) string {
+		key := seriesGroupKey(row, tagIndexes)
+		if timeIndex >= 0 {
+			t, _ := seriesRowTime(row, timeIndex)
+			key += strconv.FormatInt(t+shift, 10)
+		}
+		return key
+	}
+	baselineRows := make(map[string][]interface{})
+	if baseline != nil {
+		for _, value := range baseline.Values {
+			if row, ok := value.([]interface{}); ok {
+				baselineRows[rowKey(row, offset)] = row
+			}
+		}
+	}
+
+	aligned := &common.Result{}
+	for i, column := range result.Columns {
+		aligned.Columns = append(aligned.Columns, column)
+		if i < len(result.Schemas) {
+			aligned.Schemas = append(aligned.Schemas, result.Schemas[i])
+		}
+		if !slices.Contains(metricIndexes, i) {
+			continue
+		}
+		name := fmt.Sprint(column)
+		aligned.Columns = append(aligned.Columns, name+COMPARE_BASELINE_SUFFIX, name+COMPARE_DELTA_SUFFIX, name+COMPARE_RATIO_SUFFIX)
+		for _, suffix := range []string{COMPARE_BASELINE_SUFFIX, COMPARE_DELTA_SUFFIX, COMPARE_RATIO_SUFFIX} {
+			schema := *result.Schemas[i]
+			schema.Name = name + suffix
+			if suffix == COMPARE_RATIO_SUFFIX {
+				schema.Unit = ""
+			}
+			aligned.Schemas = append(aligned.Schemas, &schema)
+		}
+	}
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		baselineRow := baselineRows[rowKey(row, 0)]
+		alignedRow := make([]interface{}, 0, len(aligned.Columns))
+		for i, v := range row {
+			alignedRow = append(alignedRow, v)
+			if !slices.Contains(metricIndexes, i) {
+				continue
+			}
+			var base, delta, ratio interface{}
+			if i < len(baselineRow) {
+				base = baselineRow[i]
+			}
+			current, currentOk := toFloat64(v)
+			previous, previousOk := toFloat64(base)
+			if currentOk && previousOk {
+				delta = current - previous
+				if previous != 0 {
+					ratio = current / previous
+				}
+			}
+			alignedRow = append(alignedRow, base, delta, ratio)
+		}
+		aligned.Values = append(aligned.Values, alignedRow)
+	}
+	return aligned
+}
+
+// toFloat64 converts the numeric values and the pointers of the nullable columns to float64
+func toFloat64(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
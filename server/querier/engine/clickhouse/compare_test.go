@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/view"
+)
+
+func TestParseCompareSql(t *testing.T) {
+	for _, c := range []struct {
+		input  string
+		sql    string
+		offset int64
+		ok     bool
+	}{
+		{"SELECT Sum(byte) FROM network.1m WHERE time>=100 AND time<=200 COMPARE TO 1d", "SELECT Sum(byte) FROM network.1m WHERE time>=100 AND time<=200", 86400, true},
+		{"SELECT Sum(byte) FROM network.1m LIMIT 10\n compare to 2 H ", "SELECT Sum(byte) FROM network.1m LIMIT 10", 7200, true},
+		{"SELECT Sum(byte) FROM network.1m compare to 600", "SELECT Sum(byte) FROM network.1m", 600, true},
+		{"SELECT Sum(byte) FROM network.1m WHERE pod='compare to 1d'", "", 0, false},
+		{"SELECT Sum(byte) FROM network.1m compare to 1y", "", 0, false},
+	} {
+		sql, offset, ok := ParseCompareSql(c.input)
+		if sql != c.sql || offset != c.offset || ok != c.ok {
+			t.Errorf("parse %q, got %q %d %v", c.input, sql, offset, ok)
+		}
+	}
+}
+
+func TestAlignCompareResult(t *testing.T) {
+	schemas := common.ColumnSchemas{
+		{Name: "time_60", Type: common.COLUMN_SCHEMA_TYPE_TAG},
+		{Name: "pod", Type: common.COLUMN_SCHEMA_TYPE_TAG},
+		{Name: "Sum(byte)", Unit: "byte", Type: common.COLUMN_SCHEMA_TYPE_METRICS},
+	}
+	result := &common.Result{
+		Columns: []interface{}{"time_60", "pod", "Sum(byte)"},
+		Schemas: schemas,
+		Values: []interface{}{
+			[]interface{}{uint32(86400), "a", uint64(30)},
+			[]interface{}{uint32(86460), "a", uint64(10)},
+			[]interface{}{uint32(86400), "b", float64(5)},
+		},
+	}
+	baseline := &common.Result{
+		Columns: result.Columns,
+		Schemas: schemas,
+		Values: []interface{}{
+			[]interface{}{uint32(0), "a", uint64(20)},
+			[]interface{}{uint32(60), "a", uint64(0)},
+			[]interface{}{uint32(60), "b", float64(5)},
+		},
+	}
+	model := view.NewModel()
+	model.Time.Alias = "time_60"
+	aligned := alignCompareResult(result, baseline, model, 86400)
+
+	columns := []interface{}{"time_60", "pod", "Sum(byte)", "Sum(byte)_baseline", "Sum(byte)_delta", "Sum(byte)_ratio"}
+	if !reflect.DeepEqual(aligned.Columns, columns) || len(aligned.Schemas) != len(columns) {
+		t.Fatalf("got columns %v, schemas %v", aligned.Columns, aligned.Schemas)
+	}
+	if aligned.Schemas[3].Unit != "byte" || aligned.Schemas[5].Unit != "" || aligned.Schemas[5].Type != common.COLUMN_SCHEMA_TYPE_METRICS {
+		t.Errorf("got schemas %+v %+v", aligned.Schemas[3], aligned.Schemas[5])
+	}
+	values := []interface{}{
+		[]interface{}{uint32(86400), "a", uint64(30), uint64(20), float64(10), float64(1.5)},
+		[]interface{}{uint32(86460), "a", uint64(10), uint64(0), float64(10), nil},
+		[]interface{}{uint32(86400), "b", float64(5), nil, nil, nil},
+	}
+	if !reflect.DeepEqual(aligned.Values, values) {
+		t.Errorf("got values %v", aligned.Values)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/view"
+	"github.com/deepflowio/deepflow/server/querier/parse"
+)
+
+var explainColumns = []interface{}{
+	"sql", "db", "table", "data_source", "datasource_interval", "tables", "tag_translations", "estimate",
+}
+
+// Explain translates the sql without executing it, and returns the ClickHouse sql, the chosen tables, the
+// translated tags and the rows/parts/marks to be read estimated by `EXPLAIN ESTIMATE` of ClickHouse
+func (e *CHEngine) Explain(sql string, args *common.QuerierParams) (*common.Result, map[string]interface{}, error) {
+	e.Context = args.Context
+	e.NoPreWhere = args.NoPreWhere
+	e.ORGID = common.DEFAULT_ORG_ID
+	if args.ORGID != "" {
+		e.ORGID = args.ORGID
+	}
+	debugInfo := &client.DebugInfo{}
+	chSql, err := e.explainSql(sql)
+	if err != nil {
+		log.Errorf("sql: %s; parse error: %s", sql, err)
+		return nil, nil, err
+	}
+
+	debug := &client.Debug{
+		IP:        config.Cfg.Clickhouse.Host,
+		QueryUUID: args.QueryUUID,
+	}
+	chClient := client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       e.DB,
+		Debug:    debug,
+		Context:  e.Context,
+	}
+	estimateResult, err := chClient.DoQuery(&client.QueryParams{
+		Sql:       "EXPLAIN ESTIMATE " + chSql,
+		QueryUUID: args.QueryUUID,
+		ORGID:     args.ORGID,
+		UserID:    args.UserID,
+	})
+	debugInfo.Debug = append(debugInfo.Debug, *debug)
+	if err != nil {
+		return nil, debugInfo.Get(), err
+	}
+
+	result := &common.Result{
+		Columns: explainColumns,
+		Values: []interface{}{
+			[]interface{}{
+				chSql, e.DB, e.Table, e.DataSource, e.Model.Time.DatasourceInterval,
+				e.Model.From.ToString(), e.explainTagTranslations(), explainEstimate(estimateResult),
+			},
+		},
+	}
+	return result, debugInfo.Get(), nil
+}
+
+// explainSql translates the sql in the same way as ExecuteQuery
+func (e *CHEngine) explainSql(sql string) (string, error) {
+	if checkWithSqlRegexp.MatchString(sql) || strings.Contains(strings.ToLower(sql), "slimit") {
+		return "", common.NewError(common.INVALID_POST_DATA, fmt.Sprintf("explain is not supported for sql: %s", sql))
+	}
+	parser := parse.Parser{Engine: e}
+	if err := parser.ParseSQL(sql); err != nil {
+		return "", err
+	}
+	for _, stmt := range e.Statements {
+		stmt.Format(e.Model)
+	}
+	FormatModel(e.Model)
+	e.View = view.NewView(e.Model)
+	e.View.NoPreWhere = e.NoPreWhere
+	return e.ToSQLString(), nil
+}
+
+// explainTagTranslations returns the tags which are translated to ClickHouse expressions
+func (e *CHEngine) explainTagTranslations() map[string]string {
+	translations := make(map[string]string)
+	for _, stmt := range e.Statements {
+		selectTag, ok := stmt.(*SelectTag)
+		if !ok || selectTag.Alias == "" {
+			continue
+		}
+		name := strings.Trim(selectTag.Alias, "`")
+		if strings.Trim(selectTag.Value, "`") != name {
+			translations[name] = selectTag.Value
+		}
+	}
+	return translations
+}
+
+// explainEstimate converts the result of `EXPLAIN ESTIMATE` (database, table, parts, rows, marks) to maps
+func explainEstimate(result *common.Result) []map[string]interface{} {
+	estimate := []map[string]interface{}{}
+	if result == nil {
+		return estimate
+	}
+	for _, value := range result.Values {
+		row := value.([]interface{})
+		item := make(map[string]interface{}, len(row))
+		for i, column := range result.Columns {
+			if i < len(row) {
+				item[fmt.Sprint(column)] = row[i]
+			}
+		}
+		estimate = append(estimate, item)
+	}
+	return estimate
+}
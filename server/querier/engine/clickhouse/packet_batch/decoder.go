@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package packet_batch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+// The packet_batch of l4_packet is a sequence of TCP packet headers, all
+// integers are little endian:
+//
+//	packet := timestamp(u64) field_flag(u8) fields
+//	timestamp: bit 0-55 is the timestamp in microseconds, bit 63 is the
+//	           direction, 0 means client to server, 1 means server to client
+//	field_flag: the fields present in the packet, the same as the agent
+//	           configuration processors.packet.tcp_header.header_fields_flag
+//	fields: in the order of the field_flag bits from high to low
+//	  | bit | field        | type                                      |
+//	  | 7   | FLAG         | u8, TCP flags                             |
+//	  | 6   | SEQ          | u32                                       |
+//	  | 5   | ACK          | u32                                       |
+//	  | 4   | PAYLOAD_SIZE | u16                                       |
+//	  | 3   | WINDOW_SIZE  | u16                                       |
+//	  | 2   | OPT_MSS      | u16                                       |
+//	  | 1   | OPT_WS       | u8                                        |
+//	  | 0   | OPT_SACK     | u8 length in bytes, then (left, right u32) |
+const (
+	FIELD_FLAG = 1 << (7 - iota)
+	FIELD_SEQ
+	FIELD_ACK
+	FIELD_PAYLOAD_SIZE
+	FIELD_WINDOW_SIZE
+	FIELD_OPT_MSS
+	FIELD_OPT_WS
+	FIELD_OPT_SACK
+)
+
+const (
+	PACKET_HEAD_SIZE    = 9
+	TIMESTAMP_MASK      = 1<<56 - 1
+	DIRECTION_SHIFT     = 63
+	MAX_SACK_BLOCKS     = 4
+	SACK_BLOCK_SIZE     = 8
+	PACKET_BATCH_COLUMN = "packet_batch"
+)
+
+const (
+	DIRECTION_CLIENT_TO_SERVER = 0
+	DIRECTION_SERVER_TO_CLIENT = 1
+)
+
+var errTruncated = errors.New("packet batch is truncated")
+
+type Packet struct {
+	Timestamp   int64 // us
+	Direction   uint8
+	FieldFlag   uint8
+	TCPFlags    uint8
+	Seq         uint32
+	Ack         uint32
+	PayloadSize uint16
+	WindowSize  uint16
+	MSS         uint16
+	WindowScale uint8
+	SACK        []uint32 // left and right edges of the SACK blocks
+}
+
+// DecodePacketBatch decodes all packets of the batch, the packets decoded
+// before an error are returned with the error
+func DecodePacketBatch(batch []byte) ([]Packet, error) {
+	packets := []Packet{}
+	for offset := 0; offset < len(batch); {
+		if offset+PACKET_HEAD_SIZE > len(batch) {
+			return packets, errTruncated
+		}
+		head := binary.LittleEndian.Uint64(batch[offset:])
+		p := Packet{
+			Timestamp: int64(head & TIMESTAMP_MASK),
+			Direction: uint8(head >> DIRECTION_SHIFT),
+			FieldFlag: batch[offset+8],
+		}
+		offset += PACKET_HEAD_SIZE
+
+		b := batch[offset:]
+		size := fieldsSize(p.FieldFlag, b)
+		if size < 0 || size > len(b) {
+			return packets, errTruncated
+		}
+		if p.FieldFlag&FIELD_FLAG != 0 {
+			p.TCPFlags = b[0]
+			b = b[1:]
+		}
+		if p.FieldFlag&FIELD_SEQ != 0 {
+			p.Seq = binary.LittleEndian.Uint32(b)
+			b = b[4:]
+		}
+		if p.FieldFlag&FIELD_ACK != 0 {
+			p.Ack = binary.LittleEndian.Uint32(b)
+			b = b[4:]
+		}
+		if p.FieldFlag&FIELD_PAYLOAD_SIZE != 0 {
+			p.PayloadSize = binary.LittleEndian.Uint16(b)
+			b = b[2:]
+		}
+		if p.FieldFlag&FIELD_WINDOW_SIZE != 0 {
+			p.WindowSize = binary.LittleEndian.Uint16(b)
+			b = b[2:]
+		}
+		if p.FieldFlag&FIELD_OPT_MSS != 0 {
+			p.MSS = binary.LittleEndian.Uint16(b)
+			b = b[2:]
+		}
+		if p.FieldFlag&FIELD_OPT_WS != 0 {
+			p.WindowScale = b[0]
+			b = b[1:]
+		}
+		if p.FieldFlag&FIELD_OPT_SACK != 0 {
+			length := int(b[0])
+			b = b[1:]
+			for i := 0; i+4 <= length; i += 4 {
+				p.SACK = append(p.SACK, binary.LittleEndian.Uint32(b[i:]))
+			}
+		}
+		offset += size
+		packets = append(packets, p)
+	}
+	return packets, nil
+}
+
+// fieldsSize returns the size of the fields, or -1 if the SACK length is invalid
+func fieldsSize(fieldFlag uint8, b []byte) int {
+	size := 0
+	for _, f := range []struct {
+		flag uint8
+		size int
+	}{
+		{FIELD_FLAG, 1}, {FIELD_SEQ, 4}, {FIELD_ACK, 4}, {FIELD_PAYLOAD_SIZE, 2},
+		{FIELD_WINDOW_SIZE, 2}, {FIELD_OPT_MSS, 2}, {FIELD_OPT_WS, 1},
+	} {
+		if fieldFlag&f.flag != 0 {
+			size += f.size
+		}
+	}
+	if fieldFlag&FIELD_OPT_SACK != 0 {
+		if size >= len(b) {
+			return -1
+		}
+		length := int(b[size])
+		if length%SACK_BLOCK_SIZE != 0 || length > MAX_SACK_BLOCKS*SACK_BLOCK_SIZE {
+			return -1
+		}
+		size += 1 + length
+	}
+	return size
+}
+
+func (p *Packet) DirectionString() string {
+	if p.Direction == DIRECTION_SERVER_TO_CLIENT {
+		return "s2c"
+	}
+	return "c2s"
+}
+
+// TCPFlagsString formats the TCP flags as tcpdump, e.g.: SYN|ACK
+func (p *Packet) TCPFlagsString() string {
+	names := []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG", "ECE", "CWR"}
+	flags := make([]string, 0, 2)
+	for i, name := range names {
+		if p.TCPFlags&(1<<i) != 0 {
+			flags = append(flags, name)
+		}
+	}
+	return strings.Join(flags, "|")
+}
+
+// ToMap returns the packet as a row, only the fields present in the packet are included
+func (p *Packet) ToMap() map[string]interface{} {
+	row := map[string]interface{}{
+		"timestamp": p.Timestamp,
+		"direction": p.DirectionString(),
+	}
+	if p.FieldFlag&FIELD_FLAG != 0 {
+		row["tcp_flags"] = p.TCPFlags
+		row["tcp_flags_str"] = p.TCPFlagsString()
+	}
+	if p.FieldFlag&FIELD_SEQ != 0 {
+		row["seq"] = p.Seq
+	}
+	if p.FieldFlag&FIELD_ACK != 0 {
+		row["ack"] = p.Ack
+	}
+	if p.FieldFlag&FIELD_PAYLOAD_SIZE != 0 {
+		row["payload_size"] = p.PayloadSize
+	}
+	if p.FieldFlag&FIELD_WINDOW_SIZE != 0 {
+		row["window_size"] = p.WindowSize
+	}
+	if p.FieldFlag&FIELD_OPT_MSS != 0 {
+		row["mss"] = p.MSS
+	}
+	if p.FieldFlag&FIELD_OPT_WS != 0 {
+		row["window_scale"] = p.WindowScale
+	}
+	if p.FieldFlag&FIELD_OPT_SACK != 0 {
+		row["sack"] = p.SACK
+	}
+	return row
+}
+
+// PacketBatchFormat replaces the packet_batch column of l4_packet with the
+// decoded packets, args[0] is the name of the column if it is aliased
+func PacketBatchFormat(args []interface{}) func(*common.Result) error {
+	column := PACKET_BATCH_COLUMN
+	if len(args) > 0 {
+		if name, ok := args[0].(string); ok && name != "" {
+			column = strings.Trim(name, "`")
+		}
+	}
+	return func(result *common.Result) error {
+		index := -1
+		for i, c := range result.Columns {
+			if name, ok := c.(string); ok && name == column {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil
+		}
+		var lastErr error
+		for _, value := range result.Values {
+			record, ok := value.([]interface{})
+			if !ok || index >= len(record) {
+				continue
+			}
+			batch, ok := record[index].(string)
+			if !ok {
+				continue
+			}
+			packets, err := DecodePacketBatch([]byte(batch))
+			if err != nil {
+				lastErr = fmt.Errorf("decode %s failed: %s", column, err)
+			}
+			rows := make([]map[string]interface{}, 0, len(packets))
+			for i := range packets {
+				rows = append(rows, packets[i].ToMap())
+			}
+			record[index] = rows
+		}
+		return lastErr
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package packet_batch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return appendUint16(appendUint16(b, uint16(v)), uint16(v>>16))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+func encodePacket(b []byte, p *Packet) []byte {
+	head := uint64(p.Timestamp) | uint64(p.Direction)<<DIRECTION_SHIFT
+	b = appendUint64(b, head)
+	b = append(b, p.FieldFlag)
+	if p.FieldFlag&FIELD_FLAG != 0 {
+		b = append(b, p.TCPFlags)
+	}
+	if p.FieldFlag&FIELD_SEQ != 0 {
+		b = appendUint32(b, p.Seq)
+	}
+	if p.FieldFlag&FIELD_ACK != 0 {
+		b = appendUint32(b, p.Ack)
+	}
+	if p.FieldFlag&FIELD_PAYLOAD_SIZE != 0 {
+		b = appendUint16(b, p.PayloadSize)
+	}
+	if p.FieldFlag&FIELD_WINDOW_SIZE != 0 {
+		b = appendUint16(b, p.WindowSize)
+	}
+	if p.FieldFlag&FIELD_OPT_MSS != 0 {
+		b = appendUint16(b, p.MSS)
+	}
+	if p.FieldFlag&FIELD_OPT_WS != 0 {
+		b = append(b, p.WindowScale)
+	}
+	if p.FieldFlag&FIELD_OPT_SACK != 0 {
+		b = append(b, byte(len(p.SACK)*4))
+		for _, edge := range p.SACK {
+			b = appendUint32(b, edge)
+		}
+	}
+	return b
+}
+
+var testPackets = []Packet{
+	{Timestamp: 1700000000000000, FieldFlag: 0xff, TCPFlags: 0x2, Seq: 100, WindowSize: 64240, MSS: 1460, WindowScale: 7},
+	{Timestamp: 1700000000000100, Direction: DIRECTION_SERVER_TO_CLIENT, FieldFlag: 0xff, TCPFlags: 0x12, Seq: 200, Ack: 101, WindowSize: 65160, MSS: 1400, WindowScale: 7},
+	{Timestamp: 1700000000000200, FieldFlag: FIELD_FLAG | FIELD_SEQ | FIELD_ACK | FIELD_PAYLOAD_SIZE | FIELD_OPT_SACK, TCPFlags: 0x18, Seq: 101, Ack: 201, PayloadSize: 10, SACK: []uint32{300, 400, 500, 600}},
+	{Timestamp: 1700000000000300, Direction: DIRECTION_SERVER_TO_CLIENT, FieldFlag: FIELD_FLAG, TCPFlags: 0x11},
+}
+
+func TestDecodePacketBatch(t *testing.T) {
+	var batch []byte
+	for i := range testPackets {
+		batch = encodePacket(batch, &testPackets[i])
+	}
+	packets, err := DecodePacketBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packets, testPackets) {
+		t.Errorf("got %+v, expected %+v", packets, testPackets)
+	}
+
+	packets, err = DecodePacketBatch(batch[:len(batch)-1])
+	if err == nil || len(packets) != len(testPackets)-1 {
+		t.Errorf("got %d packets and error %v from the truncated batch", len(packets), err)
+	}
+	invalidSack := encodePacket(nil, &Packet{FieldFlag: FIELD_OPT_SACK, SACK: []uint32{1}})
+	if _, err := DecodePacketBatch(invalidSack); err == nil {
+		t.Errorf("invalid SACK length should fail")
+	}
+}
+
+func TestPacketBatchFormat(t *testing.T) {
+	batch := string(encodePacket(encodePacket(nil, &testPackets[1]), &testPackets[3]))
+	result := &common.Result{
+		Columns: []interface{}{"flow_id", "pb"},
+		Values:  []interface{}{[]interface{}{uint64(1), batch}},
+	}
+	if err := PacketBatchFormat([]interface{}{"`pb`"})(result); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{
+			"timestamp": int64(1700000000000100), "direction": "s2c", "tcp_flags": uint8(0x12), "tcp_flags_str": "SYN|ACK",
+			"seq": uint32(200), "ack": uint32(101), "payload_size": uint16(0), "window_size": uint16(65160),
+			"mss": uint16(1400), "window_scale": uint8(7), "sack": []uint32(nil),
+		},
+		{"timestamp": int64(1700000000000300), "direction": "s2c", "tcp_flags": uint8(0x11), "tcp_flags_str": "FIN|ACK"},
+	}
+	if got := result.Values[0].([]interface{})[1]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package packet_batch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+)
+
+// https://www.ietf.org/archive/id/draft-gharris-opsawg-pcap-01.html
+const (
+	PCAP_MAGIC_MICROSECONDS         = 0xa1b2c3d4
+	PCAP_MAGIC_NANOSECONDS          = 0xa1b23c4d
+	PCAP_FILE_HEADER_SIZE           = 24
+	PCAP_RECORD_HEADER_SIZE         = 16
+	PCAP_FILE_HEADER_LINKTYPE_INDEX = 20
+)
+
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html
+const (
+	PCAPNG_SECTION_HEADER_BLOCK  = 0x0a0d0d0a
+	PCAPNG_INTERFACE_BLOCK       = 0x00000001
+	PCAPNG_ENHANCED_PACKET_BLOCK = 0x00000006
+	PCAPNG_BYTE_ORDER_MAGIC      = 0x1a2b3c4d
+	PCAPNG_OPTION_END            = 0
+	PCAPNG_OPTION_IF_TSRESOL     = 9
+	PCAPNG_TSRESOL_NANOSECONDS   = 9
+	PCAPNG_SECTION_HEADER_SIZE   = 28
+	PCAPNG_INTERFACE_BLOCK_SIZE  = 32
+	PCAPNG_PACKET_HEAD_SIZE      = 28
+	PCAPNG_PACKET_TAIL_SIZE      = 4
+	PCAPNG_ALIGNMENT             = 4
+
+	// https://www.tcpdump.org/linktypes.html
+	LINKTYPE_RAW = 101
+)
+
+const (
+	IPV4_HEADER_SIZE     = 20
+	IPV6_HEADER_SIZE     = 40
+	TCP_HEADER_SIZE      = 20
+	TCP_MAX_OPTIONS_SIZE = 40
+	IP_PROTOCOL_TCP      = 6
+	DEFAULT_TTL          = 64
+	MAX_IP_TOTAL_LENGTH  = 65535
+
+	TCP_FLAG_SYN = 0x2
+
+	TCP_OPTION_NOP               = 1
+	TCP_OPTION_MSS               = 2
+	TCP_OPTION_WINDOW_SCALE      = 3
+	TCP_OPTION_SACK              = 5
+	TCP_OPTION_MSS_SIZE          = 4
+	TCP_OPTION_WINDOW_SCALE_SIZE = 3
+	TCP_OPTION_SACK_HEAD_SIZE    = 2
+)
+
+// PcapRecord is a packet of a pcapng file
+type PcapRecord struct {
+	Timestamp int64 // ns
+	LinkType  uint16
+	Data      []byte
+	OrigLen   uint32
+}
+
+// FlowInfo is the addresses of the flow used to rebuild the IP and TCP headers of l4_packet
+type FlowInfo struct {
+	IsIPv4     bool
+	IP0        net.IP // client
+	IP1        net.IP // server
+	ClientPort uint16
+	ServerPort uint16
+}
+
+// ParsePcap parses the packet_batch of l7_packet, which is a pcap file
+func ParsePcap(data []byte) ([]PcapRecord, error) {
+	if len(data) < PCAP_FILE_HEADER_SIZE {
+		return nil, errors.New("pcap file header is truncated")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	magic := order.Uint32(data)
+	if magic != PCAP_MAGIC_MICROSECONDS && magic != PCAP_MAGIC_NANOSECONDS {
+		order = binary.BigEndian
+		magic = order.Uint32(data)
+		if magic != PCAP_MAGIC_MICROSECONDS && magic != PCAP_MAGIC_NANOSECONDS {
+			return nil, fmt.Errorf("invalid pcap magic 0x%x", magic)
+		}
+	}
+	// the high bits of the link type are FCS information
+	linkType := uint16(order.Uint32(data[PCAP_FILE_HEADER_LINKTYPE_INDEX:]))
+
+	records := []PcapRecord{}
+	for offset := PCAP_FILE_HEADER_SIZE; offset < len(data); {
+		if offset+PCAP_RECORD_HEADER_SIZE > len(data) {
+			return records, errors.New("pcap record header is truncated")
+		}
+		header := data[offset : offset+PCAP_RECORD_HEADER_SIZE]
+		seconds, fraction := int64(order.Uint32(header)), int64(order.Uint32(header[4:]))
+		capLen, origLen := int(order.Uint32(header[8:])), order.Uint32(header[12:])
+		offset += PCAP_RECORD_HEADER_SIZE
+		if offset+capLen > len(data) {
+			return records, errors.New("pcap record is truncated")
+		}
+		if magic == PCAP_MAGIC_MICROSECONDS {
+			fraction *= 1000
+		}
+		records = append(records, PcapRecord{
+			Timestamp: seconds*1e9 + fraction,
+			LinkType:  linkType,
+			Data:      data[offset : offset+capLen],
+			OrigLen:   origLen,
+		})
+		offset += capLen
+	}
+	return records, nil
+}
+
+func tcpOptions(p *Packet) []byte {
+	options := make([]byte, 0, TCP_MAX_OPTIONS_SIZE)
+	if p.FieldFlag&FIELD_OPT_MSS != 0 && p.MSS != 0 {
+		options = append(options, TCP_OPTION_MSS, TCP_OPTION_MSS_SIZE, byte(p.MSS>>8), byte(p.MSS))
+	}
+	if p.FieldFlag&FIELD_OPT_WS != 0 && p.TCPFlags&TCP_FLAG_SYN != 0 {
+		options = append(options, TCP_OPTION_NOP, TCP_OPTION_WINDOW_SCALE, TCP_OPTION_WINDOW_SCALE_SIZE, p.WindowScale)
+	}
+	if len(p.SACK) >= 2 {
+		blocks := len(p.SACK) / 2
+		for len(options)+TCP_OPTION_SACK_HEAD_SIZE+2+blocks*SACK_BLOCK_SIZE > TCP_MAX_OPTIONS_SIZE {
+			blocks--
+		}
+		if blocks > 0 {
+			options = append(options, TCP_OPTION_NOP, TCP_OPTION_NOP, TCP_OPTION_SACK, byte(TCP_OPTION_SACK_HEAD_SIZE+blocks*SACK_BLOCK_SIZE))
+			for _, edge := range p.SACK[:blocks*2] {
+				options = append(options, byte(edge>>24), byte(edge>>16), byte(edge>>8), byte(edge))
+			}
+		}
+	}
+	for len(options)%4 != 0 {
+		options = append(options, TCP_OPTION_NOP)
+	}
+	return options
+}
+
+func ipChecksum(header []byte) uint16 {
+	sum := uint32(0)
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(header[i])<<8 | uint32(header[i+1])
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// BuildPacketRecords rebuilds the IP and TCP headers of the packets as raw IP
+// packets, the payloads are not captured and only counted in the original length
+func BuildPacketRecords(flow *FlowInfo, packets []Packet) []PcapRecord {
+	records := make([]PcapRecord, 0, len(packets))
+	src, dst := flow.IP0, flow.IP1
+	if flow.IsIPv4 {
+		src, dst = src.To4(), dst.To4()
+		if src == nil {
+			src = net.IPv4zero.To4()
+		}
+		if dst == nil {
+			dst = net.IPv4zero.To4()
+		}
+	} else {
+		src, dst = src.To16(), dst.To16()
+		if src == nil {
+			src = net.IPv6zero
+		}
+		if dst == nil {
+			dst = net.IPv6zero
+		}
+	}
+
+	for i := range packets {
+		p := &packets[i]
+		srcIP, dstIP, srcPort, dstPort := src, dst, flow.ClientPort, flow.ServerPort
+		if p.Direction == DIRECTION_SERVER_TO_CLIENT {
+			srcIP, dstIP, srcPort, dstPort = dst, src, flow.ServerPort, flow.ClientPort
+		}
+		options := tcpOptions(p)
+		tcpSize := TCP_HEADER_SIZE + len(options)
+
+		var data []byte
+		if flow.IsIPv4 {
+			data = make([]byte, IPV4_HEADER_SIZE, IPV4_HEADER_SIZE+tcpSize)
+			totalLength := IPV4_HEADER_SIZE + tcpSize + int(p.PayloadSize)
+			if totalLength > MAX_IP_TOTAL_LENGTH {
+				totalLength = MAX_IP_TOTAL_LENGTH
+			}
+			data[0] = 0x45
+			binary.BigEndian.PutUint16(data[2:], uint16(totalLength))
+			binary.BigEndian.PutUint16(data[6:], 0x4000) // don't fragment
+			data[8] = DEFAULT_TTL
+			data[9] = IP_PROTOCOL_TCP
+			copy(data[12:], srcIP)
+			copy(data[16:], dstIP)
+			binary.BigEndian.PutUint16(data[10:], ipChecksum(data))
+		} else {
+			data = make([]byte, IPV6_HEADER_SIZE, IPV6_HEADER_SIZE+tcpSize)
+			data[0] = 0x60
+			binary.BigEndian.PutUint16(data[4:], uint16(tcpSize+int(p.PayloadSize)))
+			data[6] = IP_PROTOCOL_TCP
+			data[7] = DEFAULT_TTL
+			copy(data[8:], srcIP)
+			copy(data[24:], dstIP)
+		}
+
+		// the checksum is left as 0 since the payload is unknown
+		tcp := make([]byte, TCP_HEADER_SIZE)
+		binary.BigEndian.PutUint16(tcp, srcPort)
+		binary.BigEndian.PutUint16(tcp[2:], dstPort)
+		binary.BigEndian.PutUint32(tcp[4:], p.Seq)
+		binary.BigEndian.PutUint32(tcp[8:], p.Ack)
+		tcp[12] = byte(tcpSize/4) << 4
+		tcp[13] = p.TCPFlags
+		binary.BigEndian.PutUint16(tcp[14:], p.WindowSize)
+		data = append(append(data, tcp...), options...)
+
+		records = append(records, PcapRecord{
+			Timestamp: p.Timestamp * 1000,
+			LinkType:  LINKTYPE_RAW,
+			Data:      data,
+			OrigLen:   uint32(len(data)) + uint32(p.PayloadSize),
+		})
+	}
+	return records
+}
+
+type pcapngWriter struct {
+	w          io.Writer
+	interfaces map[uint16]uint32
+}
+
+func (w *pcapngWriter) writeSectionHeader() error {
+	b := make([]byte, PCAPNG_SECTION_HEADER_SIZE)
+	binary.LittleEndian.PutUint32(b, PCAPNG_SECTION_HEADER_BLOCK)
+	binary.LittleEndian.PutUint32(b[4:], PCAPNG_SECTION_HEADER_SIZE)
+	binary.LittleEndian.PutUint32(b[8:], PCAPNG_BYTE_ORDER_MAGIC)
+	binary.LittleEndian.PutUint16(b[12:], 1) // major version
+	binary.LittleEndian.PutUint16(b[14:], 0) // minor version
+	binary.LittleEndian.PutUint64(b[16:], ^uint64(0))
+	binary.LittleEndian.PutUint32(b[24:], PCAPNG_SECTION_HEADER_SIZE)
+	_, err := w.w.Write(b)
+	return err
+}
+
+// interfaceID returns the interface of the link type, all interfaces use nanosecond timestamps
+func (w *pcapngWriter) interfaceID(linkType uint16) (uint32, error) {
+	if id, ok := w.interfaces[linkType]; ok {
+		return id, nil
+	}
+	id := uint32(len(w.interfaces))
+	w.interfaces[linkType] = id
+	b := make([]byte, PCAPNG_INTERFACE_BLOCK_SIZE)
+	binary.LittleEndian.PutUint32(b, PCAPNG_INTERFACE_BLOCK)
+	binary.LittleEndian.PutUint32(b[4:], PCAPNG_INTERFACE_BLOCK_SIZE)
+	binary.LittleEndian.PutUint16(b[8:], linkType)
+	// reserved and snap length are 0
+	binary.LittleEndian.PutUint16(b[16:], PCAPNG_OPTION_IF_TSRESOL)
+	binary.LittleEndian.PutUint16(b[18:], 1)
+	b[20] = PCAPNG_TSRESOL_NANOSECONDS
+	binary.LittleEndian.PutUint16(b[24:], PCAPNG_OPTION_END)
+	binary.LittleEndian.PutUint32(b[28:], PCAPNG_INTERFACE_BLOCK_SIZE)
+	_, err := w.w.Write(b)
+	return id, err
+}
+
+func (w *pcapngWriter) writePacket(r *PcapRecord) error {
+	id, err := w.interfaceID(r.LinkType)
+	if err != nil {
+		return err
+	}
+	padding := (PCAPNG_ALIGNMENT - len(r.Data)%PCAPNG_ALIGNMENT) % PCAPNG_ALIGNMENT
+	size := PCAPNG_PACKET_HEAD_SIZE + len(r.Data) + padding + PCAPNG_PACKET_TAIL_SIZE
+	origLen := r.OrigLen
+	if origLen < uint32(len(r.Data)) {
+		origLen = uint32(len(r.Data))
+	}
+	b := make([]byte, size)
+	binary.LittleEndian.PutUint32(b, PCAPNG_ENHANCED_PACKET_BLOCK)
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	binary.LittleEndian.PutUint32(b[8:], id)
+	binary.LittleEndian.PutUint32(b[12:], uint32(uint64(r.Timestamp)>>32))
+	binary.LittleEndian.PutUint32(b[16:], uint32(r.Timestamp))
+	binary.LittleEndian.PutUint32(b[20:], uint32(len(r.Data)))
+	binary.LittleEndian.PutUint32(b[24:], origLen)
+	copy(b[PCAPNG_PACKET_HEAD_SIZE:], r.Data)
+	binary.LittleEndian.PutUint32(b[size-PCAPNG_PACKET_TAIL_SIZE:], uint32(size))
+	_, err = w.w.Write(b)
+	return err
+}
+
+// WritePcapng writes the records sorted by timestamp as a pcapng file, an
+// interface is created for each link type
+func WritePcapng(w io.Writer, records []PcapRecord) error {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp < records[j].Timestamp
+	})
+	writer := &pcapngWriter{w: w, interfaces: make(map[uint16]uint32)}
+	if err := writer.writeSectionHeader(); err != nil {
+		return err
+	}
+	for i := range records {
+		if err := writer.writePacket(&records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
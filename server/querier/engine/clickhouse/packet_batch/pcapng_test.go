@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package packet_batch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+)
+
+type pcapngBlock struct {
+	blockType uint32
+	body      []byte
+}
+
+func readPcapngBlocks(t *testing.T, data []byte) []pcapngBlock {
+	blocks := []pcapngBlock{}
+	for len(data) > 0 {
+		size := int(binary.LittleEndian.Uint32(data[4:]))
+		if size%PCAPNG_ALIGNMENT != 0 || size > len(data) || binary.LittleEndian.Uint32(data[size-4:]) != uint32(size) {
+			t.Fatalf("invalid block size %d", size)
+		}
+		blocks = append(blocks, pcapngBlock{binary.LittleEndian.Uint32(data), data[8 : size-4]})
+		data = data[size:]
+	}
+	return blocks
+}
+
+func newTestPcap(magic uint32, order binary.ByteOrder, linkType uint32, packets ...[]byte) []byte {
+	header := make([]byte, PCAP_FILE_HEADER_SIZE)
+	order.PutUint32(header, magic)
+	order.PutUint16(header[4:], 2)
+	order.PutUint16(header[6:], 4)
+	order.PutUint32(header[16:], 65535)
+	order.PutUint32(header[20:], linkType)
+	for i, p := range packets {
+		record := make([]byte, PCAP_RECORD_HEADER_SIZE)
+		order.PutUint32(record, 1700000000)
+		order.PutUint32(record[4:], uint32(i+1)*50)
+		order.PutUint32(record[8:], uint32(len(p)))
+		order.PutUint32(record[12:], uint32(len(p))+100)
+		header = append(append(header, record...), p...)
+	}
+	return header
+}
+
+func TestParsePcap(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		records, err := ParsePcap(newTestPcap(PCAP_MAGIC_MICROSECONDS, order, 1, []byte{1, 2, 3}, []byte{4}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []PcapRecord{
+			{Timestamp: 1700000000000050000, LinkType: 1, Data: []byte{1, 2, 3}, OrigLen: 103},
+			{Timestamp: 1700000000000100000, LinkType: 1, Data: []byte{4}, OrigLen: 101},
+		}
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("got %+v, expected %+v", records, expected)
+		}
+	}
+	records, err := ParsePcap(newTestPcap(PCAP_MAGIC_NANOSECONDS, binary.LittleEndian, 1, []byte{1}))
+	if err != nil || records[0].Timestamp != 1700000000000000050 {
+		t.Errorf("got %+v, error %v", records, err)
+	}
+
+	if _, err := ParsePcap([]byte("not a pcap file, not a pcap file")); err == nil {
+		t.Errorf("invalid magic should fail")
+	}
+	truncated := newTestPcap(PCAP_MAGIC_MICROSECONDS, binary.LittleEndian, 1, []byte{1, 2, 3})
+	if records, err := ParsePcap(truncated[:len(truncated)-1]); err == nil || len(records) != 0 {
+		t.Errorf("truncated record should fail")
+	}
+}
+
+func TestBuildPacketRecords(t *testing.T) {
+	flow := &FlowInfo{IsIPv4: true, IP0: net.ParseIP("10.0.0.1"), IP1: net.ParseIP("10.0.0.2"), ClientPort: 12345, ServerPort: 80}
+	records := BuildPacketRecords(flow, testPackets)
+	if len(records) != len(testPackets) {
+		t.Fatalf("got %d records", len(records))
+	}
+
+	syn := records[0].Data
+	// MSS 4 bytes, NOP and window scale 4 bytes
+	if len(syn) != IPV4_HEADER_SIZE+TCP_HEADER_SIZE+8 || records[0].Timestamp != 1700000000000000000 || records[0].LinkType != LINKTYPE_RAW {
+		t.Errorf("got SYN record %+v", records[0])
+	}
+	if ipChecksum(syn[:IPV4_HEADER_SIZE]) != 0 {
+		t.Errorf("invalid IPv4 header checksum")
+	}
+	tcp := syn[IPV4_HEADER_SIZE:]
+	if !net.IP(syn[12:16]).Equal(flow.IP0) || binary.BigEndian.Uint16(tcp) != 12345 || binary.BigEndian.Uint32(tcp[4:]) != 100 ||
+		tcp[12] != 7<<4 || tcp[13] != 0x2 || binary.BigEndian.Uint16(tcp[22:]) != 1460 || tcp[27] != 7 {
+		t.Errorf("got SYN packet %v", syn)
+	}
+
+	synAck := records[1].Data
+	if !net.IP(synAck[12:16]).Equal(flow.IP1) || binary.BigEndian.Uint16(synAck[IPV4_HEADER_SIZE:]) != 80 {
+		t.Errorf("got SYN-ACK packet %v", synAck)
+	}
+
+	// NOP, NOP and SACK with 2 blocks
+	data := records[2].Data
+	if len(data) != IPV4_HEADER_SIZE+TCP_HEADER_SIZE+20 || records[2].OrigLen != uint32(len(data))+10 ||
+		binary.BigEndian.Uint16(data[2:]) != uint16(len(data))+10 || data[IPV4_HEADER_SIZE+TCP_HEADER_SIZE+2] != TCP_OPTION_SACK {
+		t.Errorf("got data packet %v", data)
+	}
+
+	flow6 := &FlowInfo{IP0: net.ParseIP("2001:db8::1"), IP1: net.ParseIP("2001:db8::2"), ClientPort: 12345, ServerPort: 80}
+	fin := BuildPacketRecords(flow6, testPackets[3:])[0].Data
+	if len(fin) != IPV6_HEADER_SIZE+TCP_HEADER_SIZE || fin[0] != 0x60 || fin[6] != IP_PROTOCOL_TCP ||
+		!net.IP(fin[8:24]).Equal(flow6.IP1) || binary.BigEndian.Uint16(fin[4:]) != TCP_HEADER_SIZE {
+		t.Errorf("got IPv6 FIN packet %v", fin)
+	}
+}
+
+func TestWritePcapng(t *testing.T) {
+	records := []PcapRecord{
+		{Timestamp: 2000, LinkType: 1, Data: []byte{1, 2, 3, 4, 5}, OrigLen: 100},
+		{Timestamp: 1000, LinkType: LINKTYPE_RAW, Data: []byte{6, 7, 8, 9}},
+		{Timestamp: 3000, LinkType: 1, Data: []byte{10}},
+	}
+	buffer := &bytes.Buffer{}
+	if err := WritePcapng(buffer, records); err != nil {
+		t.Fatal(err)
+	}
+	blocks := readPcapngBlocks(t, buffer.Bytes())
+	types := []uint32{}
+	for _, b := range blocks {
+		types = append(types, b.blockType)
+	}
+	expectedTypes := []uint32{
+		PCAPNG_SECTION_HEADER_BLOCK,
+		PCAPNG_INTERFACE_BLOCK, PCAPNG_ENHANCED_PACKET_BLOCK,
+		PCAPNG_INTERFACE_BLOCK, PCAPNG_ENHANCED_PACKET_BLOCK,
+		PCAPNG_ENHANCED_PACKET_BLOCK,
+	}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Fatalf("got block types %v, expected %v", types, expectedTypes)
+	}
+	if binary.LittleEndian.Uint32(blocks[0].body) != PCAPNG_BYTE_ORDER_MAGIC {
+		t.Errorf("invalid byte order magic")
+	}
+	if binary.LittleEndian.Uint16(blocks[1].body) != LINKTYPE_RAW || blocks[1].body[12] != PCAPNG_TSRESOL_NANOSECONDS ||
+		binary.LittleEndian.Uint16(blocks[3].body) != 1 {
+		t.Errorf("got interfaces %v %v", blocks[1].body, blocks[3].body)
+	}
+
+	for i, expected := range []struct {
+		id        uint32
+		timestamp uint64
+		data      []byte
+		origLen   uint32
+	}{
+		{0, 1000, []byte{6, 7, 8, 9}, 4},
+		{1, 2000, []byte{1, 2, 3, 4, 5}, 100},
+		{1, 3000, []byte{10}, 1},
+	} {
+		body := blocks[[]int{2, 4, 5}[i]].body
+		id := binary.LittleEndian.Uint32(body)
+		timestamp := uint64(binary.LittleEndian.Uint32(body[4:]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:]))
+		capLen, origLen := binary.LittleEndian.Uint32(body[12:]), binary.LittleEndian.Uint32(body[16:])
+		if id != expected.id || timestamp != expected.timestamp || origLen != expected.origLen ||
+			!bytes.Equal(body[20:20+capLen], expected.data) {
+			t.Errorf("packet %d: got id %d timestamp %d origLen %d data %v", i, id, timestamp, origLen, body[20:20+capLen])
+		}
+	}
+}
@@ -0,0 +1,491 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/libs/lru"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/view"
+	"github.com/deepflowio/deepflow/server/querier/statsd"
+)
+
+const (
+	// the time buckets are aligned in the same way as TimeFill
+	queryCacheTimeZoneOffset = 3600 * 8
+
+	QUERY_CACHE_HIT         = "hit"
+	QUERY_CACHE_PARTIAL_HIT = "partial_hit"
+	QUERY_CACHE_MISS        = "miss"
+)
+
+var (
+	queryCacheOnce sync.Once
+	queryCacheIns  *QueryCache
+
+	// only the sqls with a single `time>=` filter and a single `time<=` filter are cached
+	queryTimeStartRegexp = regexp.MustCompile(`\btime\s*>=\s*(\d+)`)
+	queryTimeEndRegexp   = regexp.MustCompile(`\btime\s*<=\s*(\d+)`)
+	querySlimitRegexp    = regexp.MustCompile(`(?i)\bslimit\b`)
+
+	errQueryAborted = errors.New("query is aborted")
+)
+
+type queryCacheKey struct {
+	orgID      string
+	db         string
+	dataSource string
+	noPreWhere bool
+	sql        string // the normalized sql without the time range
+}
+
+// querySeries describes how to split the result of a time series query by the time buckets
+type querySeries struct {
+	interval   int64
+	timeIndex  int
+	tagIndexes []int
+	grouped    bool // the rows are grouped by the tags before sorted by the time, see TimeFill
+	reverse    bool
+	limit      int
+}
+
+type queryCacheEntry struct {
+	start     int64
+	end       int64
+	createdAt int64
+	result    *common.Result
+	series    *querySeries // nil if the result could only be reused as a whole
+}
+
+type queryFunc func(args *common.QuerierParams) (*common.Result, map[string]interface{}, *querySeries, error)
+
+type queryCall struct {
+	done   chan struct{}
+	result *common.Result
+	debug  map[string]interface{}
+	err    error
+}
+
+// QueryCache caches the results of the queries by the normalized sql, org and db. The results of the time series
+// queries are reused by the time buckets, only the buckets out of the cached ones are queried. The identical queries
+// in flight are executed only once.
+type QueryCache struct {
+	cfg     config.QueryCache
+	entries *lru.Cache[queryCacheKey, *queryCacheEntry]
+	lock    sync.Mutex
+
+	calls     map[string]*queryCall
+	callsLock sync.Mutex
+
+	counter *statsd.QueryCacheCounter
+	now     func() int64
+}
+
+// GetQueryCache returns nil if the query cache is disabled
+func GetQueryCache() *QueryCache {
+	queryCacheOnce.Do(func() {
+		if config.Cfg == nil || !config.Cfg.QueryCache.Enabled {
+			return
+		}
+		queryCacheIns = NewQueryCache(config.Cfg.QueryCache)
+		statsd.RegisterCountableForIngester("query_cache", queryCacheIns.counter)
+	})
+	return queryCacheIns
+}
+
+func NewQueryCache(cfg config.QueryCache) *QueryCache {
+	return &QueryCache{
+		cfg:     cfg,
+		entries: lru.NewCache[queryCacheKey, *queryCacheEntry](cfg.MaxCount),
+		calls:   make(map[string]*queryCall),
+		counter: statsd.NewQueryCacheCounter(),
+		now:     func() int64 { return time.Now().Unix() },
+	}
+}
+
+// Execute returns the result of the query, from the cache if possible
+func (c *QueryCache) Execute(args *common.QuerierParams, query queryFunc) (*common.Result, map[string]interface{}, error) {
+	key, start, end, ok := newQueryCacheKey(args)
+	if !ok {
+		result, debug, _, err := query(args)
+		return result, debug, err
+	}
+
+	callKey := fmt.Sprintf("%+v|%d|%d", key, start, end)
+	c.callsLock.Lock()
+	if call, ok := c.calls[callKey]; ok {
+		c.callsLock.Unlock()
+		c.counter.Write(&statsd.QueryCacheStats{CacheCoalesced: 1})
+		var canceled <-chan struct{}
+		if args.Context != nil {
+			canceled = args.Context.Done()
+		}
+		select {
+		case <-call.done:
+		case <-canceled:
+			return nil, nil, args.Context.Err()
+		}
+		if !errors.Is(call.err, context.Canceled) && !errors.Is(call.err, context.DeadlineExceeded) {
+			return call.result, call.debug, call.err
+		}
+		// the query is canceled by the client which executes it, execute it again
+		return c.execute(args, query, key, start, end)
+	}
+	call := &queryCall{done: make(chan struct{}), err: errQueryAborted}
+	c.calls[callKey] = call
+	c.callsLock.Unlock()
+	defer func() {
+		c.callsLock.Lock()
+		delete(c.calls, callKey)
+		c.callsLock.Unlock()
+		close(call.done)
+	}()
+
+	call.result, call.debug, call.err = c.execute(args, query, key, start, end)
+	return call.result, call.debug, call.err
+}
+
+func (c *QueryCache) execute(args *common.QuerierParams, query queryFunc, key queryCacheKey, start, end int64) (*common.Result, map[string]interface{}, error) {
+	now := c.now()
+	c.lock.Lock()
+	entry, ok := c.entries.Get(key)
+	c.lock.Unlock()
+	if ok && now-entry.createdAt <= int64(c.cfg.TTL) {
+		if entry.series == nil {
+			if entry.start == start && entry.end == end {
+				c.counter.Write(&statsd.QueryCacheStats{CacheHit: 1})
+				result := *entry.result
+				return &result, newQueryCacheDebug(nil, QUERY_CACHE_HIT), nil
+			}
+		} else if result, debug, ok, err := c.executeSeries(args, query, key, entry, start, end, now); ok || err != nil {
+			return result, debug, err
+		}
+	}
+
+	c.counter.Write(&statsd.QueryCacheStats{CacheMiss: 1})
+	result, debug, series, err := query(args)
+	if err != nil {
+		return result, debug, err
+	}
+	c.add(key, start, end, now, result, series)
+	return result, newQueryCacheDebug(debug, QUERY_CACHE_MISS), nil
+}
+
+// executeSeries reuses the complete time buckets of the cached entry, and queries the buckets before or after them,
+// ok is false if the entry could not be reused.
+func (c *QueryCache) executeSeries(args *common.QuerierParams, query queryFunc, key queryCacheKey, entry *queryCacheEntry, start, end, now int64) (
+	result *common.Result, debug map[string]interface{}, ok bool, err error) {
+	s := entry.series
+	// the buckets before trustedEnd are complete when the entry is created
+	trustedEnd := alignQueryTime(minInt64(entry.end+1, entry.createdAt-int64(c.cfg.FreshDelay)), s.interval)
+	// the first bucket contains the data after the start time only
+	cachedFrom := alignQueryTime(start, s.interval)
+	if start != entry.start {
+		cachedFrom = alignQueryTime(maxInt64(start, entry.start)+s.interval-1, s.interval)
+	}
+	cachedTo := minInt64(trustedEnd, alignQueryTime(end+1, s.interval))
+	if cachedFrom >= cachedTo {
+		return nil, nil, false, nil
+	}
+
+	var ranges [][2]int64
+	if start < cachedFrom {
+		ranges = append(ranges, [2]int64{start, cachedFrom - 1})
+	}
+	if cachedTo <= end {
+		ranges = append(ranges, [2]int64{cachedTo, end})
+	}
+	parts := [][]interface{}{filterSeriesRows(entry.result.Values, s, cachedFrom, cachedTo)}
+	debugInfo := &client.DebugInfo{}
+	for _, r := range ranges {
+		rangeArgs := *args
+		rangeArgs.Sql = rewriteQueryTime(args.Sql, r[0], r[1])
+		rangeResult, rangeDebug, rangeSeries, err := query(&rangeArgs)
+		if sqls, ok := rangeDebug["query_sqls"].([]client.Debug); ok {
+			debugInfo.Debug = append(debugInfo.Debug, sqls...)
+		}
+		if err != nil {
+			return nil, debugInfo.Get(), true, err
+		}
+		if rangeSeries == nil || rangeSeries.interval != s.interval || rangeSeries.timeIndex != s.timeIndex ||
+			len(rangeResult.Columns) != len(entry.result.Columns) {
+			return nil, nil, false, nil
+		}
+		parts = append(parts, rangeResult.Values)
+	}
+	values := mergeSeriesRows(s, parts...)
+	if s.limit > 0 && len(values) >= s.limit {
+		// the merged result may be different from the truncated result of the query
+		return nil, nil, false, nil
+	}
+
+	status := QUERY_CACHE_HIT
+	if len(ranges) > 0 {
+		status = QUERY_CACHE_PARTIAL_HIT
+		c.counter.Write(&statsd.QueryCacheStats{CachePartialHit: 1})
+	} else {
+		c.counter.Write(&statsd.QueryCacheStats{CacheHit: 1})
+	}
+	result = &common.Result{Columns: entry.result.Columns, Schemas: entry.result.Schemas, Values: values}
+	if len(ranges) > 0 {
+		c.add(key, start, end, now, result, s)
+	}
+	return result, newQueryCacheDebug(debugInfo.Get(), status), true, nil
+}
+
+func (c *QueryCache) add(key queryCacheKey, start, end, now int64, result *common.Result, series *querySeries) {
+	if result == nil || len(result.Values) > c.cfg.MaxRows {
+		return
+	}
+	if series != nil && series.limit > 0 && len(result.Values) >= series.limit {
+		// the result is truncated by the limit, it could only be reused as a whole
+		series = nil
+	}
+	if series == nil && end > now-int64(c.cfg.FreshDelay) {
+		// the result will be changed by the data arriving later
+		return
+	}
+	c.lock.Lock()
+	c.entries.Add(key, &queryCacheEntry{start: start, end: end, createdAt: now, result: result, series: series})
+	c.lock.Unlock()
+}
+
+func newQueryCacheDebug(debug map[string]interface{}, status string) map[string]interface{} {
+	if debug == nil {
+		debug = (&client.DebugInfo{}).Get()
+	}
+	debug["query_cache"] = status
+	return debug
+}
+
+// newQueryCacheKey returns the key and the time range of the query, ok is false if the query is not cacheable
+func newQueryCacheKey(args *common.QuerierParams) (key queryCacheKey, start, end int64, ok bool) {
+	sql := normalizeQuerySql(args.Sql)
+	starts := queryTimeStartRegexp.FindAllStringSubmatch(sql, -1)
+	ends := queryTimeEndRegexp.FindAllStringSubmatch(sql, -1)
+	if len(starts) != 1 || len(ends) != 1 {
+		return
+	}
+	var err error
+	if start, err = strconv.ParseInt(starts[0][1], 10, 64); err != nil {
+		return
+	}
+	if end, err = strconv.ParseInt(ends[0][1], 10, 64); err != nil || start > end {
+		return
+	}
+	sql = queryTimeStartRegexp.ReplaceAllString(sql, "time>=?")
+	sql = queryTimeEndRegexp.ReplaceAllString(sql, "time<=?")
+	key = queryCacheKey{
+		orgID:      args.ORGID,
+		db:         args.DB,
+		dataSource: args.DataSource,
+		noPreWhere: args.NoPreWhere,
+		sql:        sql,
+	}
+	return key, start, end, true
+}
+
+// normalizeQuerySql collapses the whitespaces out of the quoted strings
+func normalizeQuerySql(sql string) string {
+	var sb strings.Builder
+	var quote byte
+	space := false
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		if quote != 0 {
+			sb.WriteByte(ch)
+			if ch == '\\' && i+1 < len(sql) {
+				i++
+				sb.WriteByte(sql[i])
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch ch {
+		case ' ', '\t', '\n', '\r':
+			space = true
+			continue
+		case '\'', '"', '`':
+			quote = ch
+		}
+		if space && sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		space = false
+		sb.WriteByte(ch)
+	}
+	return sb.String()
+}
+
+func rewriteQueryTime(sql string, start, end int64) string {
+	sql = queryTimeStartRegexp.ReplaceAllString(sql, fmt.Sprintf("time>=%d", start))
+	return queryTimeEndRegexp.ReplaceAllString(sql, fmt.Sprintf("time<=%d", end))
+}
+
+func alignQueryTime(t, interval int64) int64 {
+	return (t+queryCacheTimeZoneOffset)/interval*interval - queryCacheTimeZoneOffset
+}
+
+// querySeries returns nil if the result of the query could not be split by the time buckets
+func (e *CHEngine) querySeries(sql string, result *common.Result) *querySeries {
+	m := e.Model
+	if m == nil || result == nil || m.Time.Interval <= 0 || m.Time.Alias == "" || m.Time.Offset != 0 ||
+		m.Time.WindowSize > 1 || m.IsDerivative || e.IsDerivative || m.Limit.Offset != "" || querySlimitRegexp.MatchString(sql) {
+		return nil
+	}
+	alias := strings.Trim(m.Time.Alias, "`")
+	s := &querySeries{
+		interval:  int64(m.Time.Interval),
+		timeIndex: -1,
+		grouped:   m.Time.Fill == "0" || m.Time.Fill == "none" || m.Time.Fill == "null",
+	}
+	s.limit, _ = strconv.Atoi(m.Limit.Limit)
+	// the rows could be merged only if they are sorted by the time
+	for _, node := range m.Orders.Orders {
+		order, ok := node.(*view.Order)
+		if !ok || strings.Trim(order.SortBy, "`") != alias {
+			return nil
+		}
+		s.reverse = order.OrderBy == "desc"
+	}
+	for i, column := range result.Columns {
+		if name, _ := column.(string); name == alias {
+			s.timeIndex = i
+			break
+		}
+	}
+	if s.timeIndex < 0 {
+		return nil
+	}
+	for i, schema := range result.Schemas {
+		if i != s.timeIndex && schema != nil && schema.Type == common.COLUMN_SCHEMA_TYPE_TAG {
+			s.tagIndexes = append(s.tagIndexes, i)
+		}
+	}
+	for _, value := range result.Values {
+		if _, ok := seriesRowTime(value, s.timeIndex); !ok {
+			return nil
+		}
+	}
+	return s
+}
+
+func seriesRowTime(value interface{}, timeIndex int) (int64, bool) {
+	row, ok := value.([]interface{})
+	if !ok || timeIndex >= len(row) {
+		return 0, false
+	}
+	switch t := row[timeIndex].(type) {
+	case uint32:
+		return int64(t), true
+	case int:
+		return int64(t), true
+	case int64:
+		return t, true
+	case uint64:
+		return int64(t), true
+	}
+	return 0, false
+}
+
+// filterSeriesRows returns the rows in the buckets in [from, to)
+func filterSeriesRows(values []interface{}, s *querySeries, from, to int64) []interface{} {
+	rows := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		if t, ok := seriesRowTime(value, s.timeIndex); ok && t >= from && t < to {
+			rows = append(rows, value)
+		}
+	}
+	return rows
+}
+
+// mergeSeriesRows sorts the rows of the parts in the same order as the result of the query
+func mergeSeriesRows(s *querySeries, parts ...[]interface{}) []interface{} {
+	type seriesRow struct {
+		group int
+		time  int64
+		value interface{}
+	}
+	groups := make(map[string]int)
+	rows := make([]seriesRow, 0)
+	for _, part := range parts {
+		for _, value := range part {
+			t, _ := seriesRowTime(value, s.timeIndex)
+			row := seriesRow{time: t, value: value}
+			if s.grouped {
+				groupKey := seriesGroupKey(value.([]interface{}), s.tagIndexes)
+				group, ok := groups[groupKey]
+				if !ok {
+					group = len(groups)
+					groups[groupKey] = group
+				}
+				row.group = group
+			}
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].group != rows[j].group {
+			return rows[i].group < rows[j].group
+		}
+		if s.reverse {
+			return rows[i].time > rows[j].time
+		}
+		return rows[i].time < rows[j].time
+	})
+	values := make([]interface{}, len(rows))
+	for i := range rows {
+		values[i] = rows[i].value
+	}
+	return values
+}
+
+func seriesGroupKey(row []interface{}, tagIndexes []int) string {
+	var sb strings.Builder
+	for _, i := range tagIndexes {
+		if i < len(row) {
+			fmt.Fprintf(&sb, "%v|", row[i])
+		}
+	}
+	return sb.String()
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+)
+
+const testQueryCacheSql = "SELECT time(time, 60) AS time_60, pod, Sum(byte) FROM network.1m WHERE time>=%d AND time<=%d GROUP BY time_60, pod ORDER BY time_60"
+
+func newTestQueryCache(now int64) *QueryCache {
+	c := NewQueryCache(config.QueryCache{Enabled: true, MaxCount: 16, MaxRows: 10000, TTL: 300, FreshDelay: 60})
+	c.now = func() int64 { return now }
+	return c
+}
+
+type testSeriesQuerier struct {
+	sqls []string
+}
+
+// query returns a row of every minute for the pods, the value is the time of the row
+func (q *testSeriesQuerier) query(args *common.QuerierParams) (*common.Result, map[string]interface{}, *querySeries, error) {
+	q.sqls = append(q.sqls, args.Sql)
+	key, start, end, _ := newQueryCacheKey(args)
+	if key.sql == "" {
+		return nil, nil, nil, fmt.Errorf("invalid sql %s", args.Sql)
+	}
+	result := &common.Result{
+		Columns: []interface{}{"time_60", "pod", "Sum(byte)"},
+		Schemas: common.ColumnSchemas{
+			{Name: "time_60", Type: common.COLUMN_SCHEMA_TYPE_TAG},
+			{Name: "pod", Type: common.COLUMN_SCHEMA_TYPE_TAG},
+			{Name: "Sum(byte)", Type: common.COLUMN_SCHEMA_TYPE_METRICS},
+		},
+	}
+	for _, pod := range []string{"a", "b"} {
+		for t := alignQueryTime(start, 60); t <= end; t += 60 {
+			result.Values = append(result.Values, []interface{}{uint32(t), pod, int(t)})
+		}
+	}
+	series := &querySeries{interval: 60, timeIndex: 0, tagIndexes: []int{1}, grouped: true, limit: 10000}
+	return result, nil, series, nil
+}
+
+func TestQueryCacheSeries(t *testing.T) {
+	now := int64(1700000000)
+	c := newTestQueryCache(now)
+	q := &testSeriesQuerier{}
+	execute := func(start, end int64) (*common.Result, map[string]interface{}) {
+		result, debug, err := c.Execute(&common.QuerierParams{Sql: fmt.Sprintf(testQueryCacheSql, start, end), ORGID: "1"}, q.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, debug
+	}
+	expected := func(start, end int64) []interface{} {
+		result, _, _, _ := (&testSeriesQuerier{}).query(&common.QuerierParams{Sql: fmt.Sprintf(testQueryCacheSql, start, end)})
+		return result.Values
+	}
+
+	// miss
+	result, debug := execute(now-3600, now)
+	if debug["query_cache"] != QUERY_CACHE_MISS || len(q.sqls) != 1 {
+		t.Fatalf("got debug %v, sqls %v", debug, q.sqls)
+	}
+	// the latest minute is queried again
+	result, debug = execute(now-3600, now)
+	if debug["query_cache"] != QUERY_CACHE_PARTIAL_HIT || len(q.sqls) != 2 {
+		t.Fatalf("got debug %v, sqls %v", debug, q.sqls)
+	}
+	if q.sqls[1] != fmt.Sprintf(testQueryCacheSql, alignQueryTime(now-60, 60), now) {
+		t.Errorf("got sql %s", q.sqls[1])
+	}
+	if !reflect.DeepEqual(result.Values, expected(now-3600, now)) {
+		t.Errorf("got values %v", result.Values)
+	}
+
+	// the sliding window, only the head and the tail are queried
+	now += 150
+	c.now = func() int64 { return now }
+	result, debug = execute(now-3600, now)
+	if debug["query_cache"] != QUERY_CACHE_PARTIAL_HIT || len(q.sqls) != 4 {
+		t.Fatalf("got debug %v, sqls %v", debug, q.sqls)
+	}
+	if !reflect.DeepEqual(result.Values, expected(now-3600, now)) {
+		t.Errorf("got values %v", result.Values)
+	}
+
+	// the complete buckets are returned from the cache
+	result, debug = execute(now-3600, now-600)
+	if debug["query_cache"] != QUERY_CACHE_PARTIAL_HIT || len(q.sqls) != 5 {
+		t.Fatalf("got debug %v, sqls %v", debug, q.sqls)
+	}
+	if !reflect.DeepEqual(result.Values, expected(now-3600, now-600)) {
+		t.Errorf("got values %v", result.Values)
+	}
+	start := alignQueryTime(now-3000, 60)
+	result, debug = execute(start, start+599)
+	if debug["query_cache"] != QUERY_CACHE_HIT || len(q.sqls) != 5 {
+		t.Fatalf("got debug %v, sqls %v", debug, q.sqls)
+	}
+	if !reflect.DeepEqual(result.Values, expected(start, start+599)) {
+		t.Errorf("got values %v", result.Values)
+	}
+
+	// the other orgs are not affected
+	c.Execute(&common.QuerierParams{Sql: fmt.Sprintf(testQueryCacheSql, start, start+599), ORGID: "2"}, q.query)
+	if len(q.sqls) != 6 {
+		t.Errorf("got sqls %v", q.sqls)
+	}
+}
+
+func TestQueryCacheWhole(t *testing.T) {
+	now := int64(1700000000)
+	c := newTestQueryCache(now)
+	count := 0
+	query := func(args *common.QuerierParams) (*common.Result, map[string]interface{}, *querySeries, error) {
+		count++
+		return &common.Result{Values: []interface{}{[]interface{}{count}}}, nil, nil, nil
+	}
+	sql := "SELECT Sum(byte) FROM network.1m WHERE time>=%d AND time<=%d"
+	for i := 0; i < 2; i++ {
+		c.Execute(&common.QuerierParams{Sql: fmt.Sprintf(sql, now-3600, now-600)}, query)
+	}
+	if count != 1 {
+		t.Errorf("the complete result is queried %d times", count)
+	}
+	// the result containing the latest data is not cached
+	for i := 0; i < 2; i++ {
+		c.Execute(&common.QuerierParams{Sql: fmt.Sprintf(sql, now-3600, now)}, query)
+	}
+	if count != 3 {
+		t.Errorf("the incomplete result is queried %d times", count-1)
+	}
+	// expired
+	c.now = func() int64 { return now + 301 }
+	c.Execute(&common.QuerierParams{Sql: fmt.Sprintf(sql, now-3600, now-600)}, query)
+	if count != 4 {
+		t.Errorf("the expired result is queried %d times", count-3)
+	}
+}
+
+func TestQueryCacheCoalesce(t *testing.T) {
+	c := newTestQueryCache(1700000000)
+	started, release := make(chan struct{}), make(chan struct{})
+	count := 0
+	query := func(args *common.QuerierParams) (*common.Result, map[string]interface{}, *querySeries, error) {
+		count++
+		close(started)
+		<-release
+		return &common.Result{Values: []interface{}{[]interface{}{1}}}, nil, nil, nil
+	}
+	args := &common.QuerierParams{Sql: "SELECT Sum(byte) FROM network.1m WHERE time>=1699990000 AND time<=1700000000", Context: context.Background()}
+
+	results := make([]*common.Result, 3)
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = c.Execute(args, query)
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if count != 1 {
+		t.Errorf("the query is executed %d times", count)
+	}
+	for _, result := range results {
+		if result == nil || len(result.Values) != 1 {
+			t.Errorf("got result %v", result)
+		}
+	}
+}
+
+func TestNewQueryCacheKey(t *testing.T) {
+	a, start, end, ok := newQueryCacheKey(&common.QuerierParams{Sql: "SELECT pod FROM network.1m  WHERE time >= 100 AND\n time<= 200 AND pod='a  b'"})
+	if !ok || start != 100 || end != 200 {
+		t.Fatalf("got %v %d %d %v", a, start, end, ok)
+	}
+	b, _, _, _ := newQueryCacheKey(&common.QuerierParams{Sql: "SELECT pod FROM network.1m WHERE time>=300 AND time<=400 AND pod='a  b'"})
+	if a != b || b.sql != "SELECT pod FROM network.1m WHERE time>=? AND time<=? AND pod='a  b'" {
+		t.Errorf("got keys %v and %v", a, b)
+	}
+	if _, _, _, ok := newQueryCacheKey(&common.QuerierParams{Sql: "SELECT pod FROM network.1m WHERE time>=300 AND time<=400 AND pod='a b'"}); !ok {
+		t.Errorf("the sql should be cacheable")
+	}
+	for _, sql := range []string{
+		"SELECT pod FROM network.1m",
+		"SELECT pod FROM network.1m WHERE time>=300",
+		"SELECT pod FROM network.1m WHERE (time>=300 AND time<=400) OR (time>=500 AND time<=600)",
+	} {
+		if _, _, _, ok := newQueryCacheKey(&common.QuerierParams{Sql: sql}); ok {
+			t.Errorf("sql %s should not be cacheable", sql)
+		}
+	}
+}
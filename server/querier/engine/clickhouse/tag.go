@@ -246,7 +246,7 @@ func (t *SelectTag) Format(m *view.Model) {
 			m.AddCallback(t.Value, MacTranslate([]interface{}{t.Value, alias}))
 		}
 		if t.Value == "packet_batch" {
-			m.AddCallback(t.Value, packet_batch.PacketBatchFormat([]interface{}{}))
+			m.AddCallback(t.Value, packet_batch.PacketBatchFormat([]interface{}{t.Alias}))
 		}
 	}
 
@@ -17,11 +17,15 @@
 package parse
 
 import (
+	"regexp"
+
 	"github.com/xwb1989/sqlparser"
 
 	"github.com/deepflowio/deepflow/server/querier/engine"
 )
 
+var explainRegexp = regexp.MustCompile(`(?is)^\s*explain\s+(.+)$`)
+
 type Parser struct {
 	Engine engine.Engine
 }
@@ -104,3 +108,12 @@ func (p *Parser) ParseSQL(sql string) error {
 	}
 	return nil
 }
+
+// ParseExplain returns the explained statement if the sql is `EXPLAIN <select statement>`
+func ParseExplain(sql string) (string, bool) {
+	match := explainRegexp.FindStringSubmatch(sql)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
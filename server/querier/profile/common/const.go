@@ -42,7 +42,27 @@ const (
 	TAG_PROCESS_ID = "process_id"
 )
 
-const DATA_FORMAT_GRAFANA = "grafana"
+const (
+	DATA_FORMAT_GRAFANA = "grafana"
+	DATA_FORMAT_PPROF   = "pprof"
+)
+
+// the unit of profile_value in pprof, the event types not listed are counted
+var PROFILE_VALUE_UNIT_MAP = map[string]string{
+	"inuse_space":              "bytes",
+	"alloc_space":              "bytes",
+	"mutex_duration":           "nanoseconds",
+	"block_duration":           "nanoseconds",
+	"alloc_in_new_tlab_bytes":  "bytes",
+	"alloc_outside_tlab_bytes": "bytes",
+	"lock_duration":            "nanoseconds",
+	"on-cpu":                   "microseconds",
+	"off-cpu":                  "microseconds",
+	"mem-alloc":                "bytes",
+	"mem-inuse":                "bytes",
+}
+
+const PROFILE_VALUE_UNIT_DEFAULT = "count"
 
 var LOCATION_TYPE_MAP = map[string]string{
 	"[c] ": "C", // cuda functions
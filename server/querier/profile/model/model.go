@@ -29,7 +29,16 @@ type Profile struct {
 	Debug               bool   `json:"debug"`
 	Context             context.Context
 	OrgID               string
-	MaxKernelStackDepth *int `json:"max_kernel_stack_depth"` // default: -1
+	MaxKernelStackDepth *int   `json:"max_kernel_stack_depth"` // default: -1
+	Format              string `json:"format"`                 // pprof: download the gzipped pprof protobuf
+}
+
+// ProfileDiff compares the profile with the comparison, which replaces the time range or the tag filter of the profile
+type ProfileDiff struct {
+	Profile
+	CompareTimeStart int    `json:"compare_time_start"`
+	CompareTimeEnd   int    `json:"compare_time_end"`
+	CompareTagFilter string `json:"compare_tag_filter"`
 }
 
 type ProfileGrafana struct {
@@ -17,6 +17,8 @@
 package router
 
 import (
+	"fmt"
+	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +34,7 @@ import (
 func ProfileRouter(e *gin.Engine, cfg *config.QuerierConfig) {
 	e.POST("/v1/profile/ProfileTracing", profile(cfg))
 	e.POST("/v1/profile/ProfileGrafana", profileGrafana(cfg))
+	e.POST("/v1/profile/ProfileDiff", profileDiff(cfg))
 }
 
 func profile(cfg *config.QuerierConfig) gin.HandlerFunc {
@@ -51,6 +54,43 @@ func profile(cfg *config.QuerierConfig) gin.HandlerFunc {
 			args.MaxKernelStackDepth = &maxKernelStackDepth
 		}
 		result, debug, err := service.Profile(args, cfg)
+		if err == nil && args.Format == common.DATA_FORMAT_PPROF {
+			var data []byte
+			data, err = service.ProfileToPprof(&result, &args)
+			if err == nil {
+				c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pb.gz", args.ProfileEventType))
+				c.Data(http.StatusOK, "application/octet-stream", data)
+				return
+			}
+		}
+		if err == nil && !args.Debug {
+			debug = nil
+		}
+		router.JsonResponse(c, result, debug, err)
+	})
+}
+
+func profileDiff(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.ProfileDiff
+
+		// 参数校验
+		err := c.ShouldBindBodyWith(&args, binding.JSON)
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_POST_DATA, err.Error())
+			return
+		}
+		if args.CompareTimeStart == 0 && args.CompareTimeEnd == 0 && args.CompareTagFilter == "" {
+			router.BadRequestResponse(c, common.INVALID_PARAMETERS, "one of compare_time_start, compare_time_end and compare_tag_filter is required")
+			return
+		}
+		args.Context = c.Request.Context()
+		args.OrgID = c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
+		if args.MaxKernelStackDepth == nil {
+			var maxKernelStackDepth = common.MAX_KERNEL_STACK_DEPTH_DEFAULT
+			args.MaxKernelStackDepth = &maxKernelStackDepth
+		}
+		result, debug, err := service.ProfileDiff(args, cfg)
 		if err == nil && !args.Debug {
 			debug = nil
 		}
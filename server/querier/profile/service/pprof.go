@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/common"
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+// field numbers of pprof, ref: https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	pprofProfileSampleType    protowire.Number = 1
+	pprofProfileSample        protowire.Number = 2
+	pprofProfileLocation      protowire.Number = 4
+	pprofProfileFunction      protowire.Number = 5
+	pprofProfileStringTable   protowire.Number = 6
+	pprofProfileTimeNanos     protowire.Number = 9
+	pprofProfileDurationNanos protowire.Number = 10
+	pprofProfilePeriodType    protowire.Number = 11
+
+	pprofValueTypeType protowire.Number = 1
+	pprofValueTypeUnit protowire.Number = 2
+
+	pprofSampleLocationID protowire.Number = 1
+	pprofSampleValue      protowire.Number = 2
+
+	pprofLocationID   protowire.Number = 1
+	pprofLocationLine protowire.Number = 4
+
+	pprofLineFunctionID protowire.Number = 1
+
+	pprofFunctionID         protowire.Number = 1
+	pprofFunctionName       protowire.Number = 2
+	pprofFunctionSystemName protowire.Number = 3
+)
+
+type pprofStringTable struct {
+	strings []string
+	index   map[string]uint64
+}
+
+func newPprofStringTable() *pprofStringTable {
+	// the first string must be ""
+	return &pprofStringTable{strings: []string{""}, index: map[string]uint64{"": 0}}
+}
+
+func (t *pprofStringTable) add(s string) uint64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	i := uint64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.index[s] = i
+	return i
+}
+
+// ProfileToPprof encodes the profile tree as the gzipped pprof protobuf which can be opened by `go tool pprof`.
+// Each node with self value is a sample, the root node (app service) is not a part of the stacks.
+func ProfileToPprof(tree *model.ProfileTree, args *model.Profile) ([]byte, error) {
+	stringTable := newPprofStringTable()
+	unit, ok := common.PROFILE_VALUE_UNIT_MAP[args.ProfileEventType]
+	if !ok {
+		unit = common.PROFILE_VALUE_UNIT_DEFAULT
+	}
+	var valueType []byte
+	valueType = appendPprofVarint(valueType, pprofValueTypeType, stringTable.add(args.ProfileEventType))
+	valueType = appendPprofVarint(valueType, pprofValueTypeUnit, stringTable.add(unit))
+
+	var buf []byte
+	buf = appendPprofMessage(buf, pprofProfileSampleType, valueType)
+
+	nodes := tree.NodeValues.Values
+	usedFunctions := make([]bool, len(tree.Functions))
+	var sample, locationIDs []byte
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i][2] == 0 {
+			continue
+		}
+		// location ids from the leaf to the root, the ids of pprof start from 1
+		locationIDs = locationIDs[:0]
+		for n := i; n > 0; n = nodes[n][1] {
+			functionID := nodes[n][0]
+			usedFunctions[functionID] = true
+			locationIDs = protowire.AppendVarint(locationIDs, uint64(functionID)+1)
+		}
+		sample = appendPprofMessage(sample[:0], pprofSampleLocationID, locationIDs)
+		sample = appendPprofMessage(sample, pprofSampleValue, protowire.AppendVarint(nil, uint64(nodes[i][2])))
+		buf = appendPprofMessage(buf, pprofProfileSample, sample)
+	}
+
+	// every function has one location without address and line number
+	var location, line, function []byte
+	for functionID, used := range usedFunctions {
+		if !used {
+			continue
+		}
+		id := uint64(functionID) + 1
+		line = appendPprofVarint(line[:0], pprofLineFunctionID, id)
+		location = appendPprofVarint(location[:0], pprofLocationID, id)
+		location = appendPprofMessage(location, pprofLocationLine, line)
+		buf = appendPprofMessage(buf, pprofProfileLocation, location)
+
+		name := stringTable.add(tree.Functions[functionID])
+		function = appendPprofVarint(function[:0], pprofFunctionID, id)
+		function = appendPprofVarint(function, pprofFunctionName, name)
+		function = appendPprofVarint(function, pprofFunctionSystemName, name)
+		buf = appendPprofMessage(buf, pprofProfileFunction, function)
+	}
+
+	for _, s := range stringTable.strings {
+		buf = protowire.AppendTag(buf, pprofProfileStringTable, protowire.BytesType)
+		buf = protowire.AppendString(buf, s)
+	}
+	buf = appendPprofVarint(buf, pprofProfileTimeNanos, uint64(args.TimeStart)*1e9)
+	if args.TimeEnd > args.TimeStart {
+		buf = appendPprofVarint(buf, pprofProfileDurationNanos, uint64(args.TimeEnd-args.TimeStart)*1e9)
+	}
+	buf = appendPprofMessage(buf, pprofProfilePeriodType, valueType)
+
+	var out bytes.Buffer
+	w := gzip.NewWriter(&out)
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func appendPprofMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendPprofVarint(b []byte, num protowire.Number, v uint64) []byte {
+	// zero values are omitted in proto3
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+func TestProfileToPprof(t *testing.T) {
+	tree := newTestProfileTree([]string{"app", "foo", "main", "bar"}, [][]int{
+		{0, -1, 0, 5},
+		{1, 2, 3, 3},
+		{2, 0, 0, 5},
+		{3, 2, 2, 2},
+	})
+	data, err := ProfileToPprof(tree, &model.Profile{ProfileEventType: "on-cpu", TimeStart: 100, TimeEnd: 160})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _ := io.ReadAll(r)
+
+	var strings []string
+	var samples [][]uint64
+	var values []uint64
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		buf = buf[n:]
+		switch {
+		case num == pprofProfileStringTable:
+			s, n := protowire.ConsumeString(buf)
+			strings = append(strings, s)
+			buf = buf[n:]
+		case num == pprofProfileSample:
+			sample, n := protowire.ConsumeBytes(buf)
+			buf = buf[n:]
+			for len(sample) > 0 {
+				num, _, n := protowire.ConsumeTag(sample)
+				sample = sample[n:]
+				packed, n := protowire.ConsumeBytes(sample)
+				sample = sample[n:]
+				var ids []uint64
+				for len(packed) > 0 {
+					v, n := protowire.ConsumeVarint(packed)
+					packed = packed[n:]
+					ids = append(ids, v)
+				}
+				if num == pprofSampleLocationID {
+					samples = append(samples, ids)
+				} else {
+					values = append(values, ids...)
+				}
+			}
+		default:
+			buf = buf[protowire.ConsumeFieldValue(num, typ, buf):]
+		}
+	}
+
+	// the stacks are from the leaf to the root, the location id is the function id + 1
+	if !reflect.DeepEqual(samples, [][]uint64{{2, 3}, {4, 3}}) || !reflect.DeepEqual(values, []uint64{3, 2}) {
+		t.Errorf("got samples %v, values %v", samples, values)
+	}
+	if len(strings) == 0 || strings[0] != "" || strings[1] != "on-cpu" || strings[2] != "microseconds" {
+		t.Errorf("got string table %v", strings)
+	}
+}
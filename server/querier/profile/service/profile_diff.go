@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+// column indexes of the node values of the diff profile tree
+const (
+	diffNodeFunctionID = iota
+	diffNodeParentNodeID
+	diffNodeBaselineSelf
+	diffNodeBaselineTotal
+	diffNodeComparisonSelf
+	diffNodeComparisonTotal
+	diffNodeColumns
+)
+
+// ProfileDiff generates the profile trees of the baseline and the comparison, and merges them by the function stacks.
+// The values are not normalized, the clients could normalize them by the total values of the root node.
+func ProfileDiff(args model.ProfileDiff, cfg *config.QuerierConfig) (result model.ProfileTree, debug interface{}, err error) {
+	comparisonArgs := args.Profile
+	if args.CompareTimeStart != 0 {
+		comparisonArgs.TimeStart = args.CompareTimeStart
+	}
+	if args.CompareTimeEnd != 0 {
+		comparisonArgs.TimeEnd = args.CompareTimeEnd
+	}
+	if args.CompareTagFilter != "" {
+		comparisonArgs.TagFilter = args.CompareTagFilter
+	}
+
+	debugs := model.ProfileDebug{}
+	baseline, baselineDebug, err := Profile(args.Profile, cfg)
+	appendProfileDebug(&debugs, baselineDebug)
+	if err != nil {
+		debug = debugs
+		return
+	}
+	comparison, comparisonDebug, err := Profile(comparisonArgs, cfg)
+	appendProfileDebug(&debugs, comparisonDebug)
+	debug = debugs
+	if err != nil {
+		return
+	}
+	result = DiffProfileTree(&baseline, &comparison, args.ProfileEventType)
+	return
+}
+
+func appendProfileDebug(debugs *model.ProfileDebug, debug interface{}) {
+	if d, ok := debug.(model.ProfileDebug); ok {
+		debugs.QuerierDebug = append(debugs.QuerierDebug, d.QuerierDebug...)
+		debugs.FormatTime = d.FormatTime
+	}
+}
+
+type diffNodeKey struct {
+	parentNodeID int
+	functionID   int
+}
+
+type profileTreeDiffer struct {
+	functions    []string
+	functionToID map[string]int
+	nodes        [][]int
+	nodeKeyToID  map[diffNodeKey]int
+}
+
+// DiffProfileTree merges the nodes with the same function stack of two profile trees,
+// the node values are [function_id, parent_node_id, baseline_self_value, baseline_total_value, comparison_self_value, comparison_total_value]
+func DiffProfileTree(baseline, comparison *model.ProfileTree, profileEventType string) (result model.ProfileTree) {
+	if len(baseline.NodeValues.Values) == 0 && len(comparison.NodeValues.Values) == 0 {
+		return
+	}
+	d := &profileTreeDiffer{
+		functionToID: make(map[string]int),
+		nodes:        make([][]int, 0, len(baseline.NodeValues.Values)),
+		nodeKeyToID:  make(map[diffNodeKey]int),
+	}
+	// the root nodes of both trees are merged
+	root := baseline
+	if len(root.Functions) == 0 {
+		root = comparison
+	}
+	d.addNode(-1, d.addFunction(root.Functions[0]))
+	d.merge(baseline, diffNodeBaselineSelf)
+	d.merge(comparison, diffNodeComparisonSelf)
+
+	result.Functions = d.functions
+	result.FunctionValues.Values = make([][]int, len(d.functions))
+	typeValues := make([][]int, len(d.functions))
+	for i := range result.FunctionValues.Values {
+		result.FunctionValues.Values[i] = []int{0, 0, 0, 0}
+		typeValues[i] = []int{0, 0}
+	}
+	for _, node := range d.nodes {
+		functionValues := result.FunctionValues.Values[node[diffNodeFunctionID]]
+		for i := range functionValues {
+			functionValues[i] += node[diffNodeBaselineSelf+i]
+		}
+	}
+	for i, v := range result.FunctionValues.Values {
+		typeValues[i][0], typeValues[i][1] = v[0]+v[2], v[1]+v[3]
+	}
+	result.FunctionTypes = GetLocationType(d.functions, typeValues, profileEventType)
+	result.NodeValues.Values = d.nodes
+	result.FunctionValues.Columns = []string{"baseline_self_value", "baseline_total_value", "comparison_self_value", "comparison_total_value"}
+	result.NodeValues.Columns = []string{"function_id", "parent_node_id", "baseline_self_value", "baseline_total_value", "comparison_self_value", "comparison_total_value"}
+	return
+}
+
+func (d *profileTreeDiffer) addFunction(function string) int {
+	functionID, ok := d.functionToID[function]
+	if !ok {
+		functionID = len(d.functions)
+		d.functionToID[function] = functionID
+		d.functions = append(d.functions, function)
+	}
+	return functionID
+}
+
+func (d *profileTreeDiffer) addNode(parentNodeID, functionID int) int {
+	key := diffNodeKey{parentNodeID: parentNodeID, functionID: functionID}
+	nodeID, ok := d.nodeKeyToID[key]
+	if !ok {
+		nodeID = len(d.nodes)
+		d.nodeKeyToID[key] = nodeID
+		node := make([]int, diffNodeColumns)
+		node[diffNodeFunctionID] = functionID
+		node[diffNodeParentNodeID] = parentNodeID
+		d.nodes = append(d.nodes, node)
+	}
+	return nodeID
+}
+
+// merge adds the values of the tree to the columns starting at selfColumn
+func (d *profileTreeDiffer) merge(tree *model.ProfileTree, selfColumn int) {
+	nodes := tree.NodeValues.Values
+	if len(nodes) == 0 {
+		return
+	}
+	// the parent node may be appended after its children in the profile tree
+	nodeIDs := make([]int, len(nodes))
+	for i := range nodeIDs {
+		nodeIDs[i] = -1
+	}
+	nodeIDs[0] = 0
+	var mergedNodeID func(int) int
+	mergedNodeID = func(i int) int {
+		if nodeIDs[i] < 0 {
+			parentNodeID := mergedNodeID(nodes[i][1])
+			nodeIDs[i] = d.addNode(parentNodeID, d.addFunction(tree.Functions[nodes[i][0]]))
+		}
+		return nodeIDs[i]
+	}
+	for i, node := range nodes {
+		nodeID := mergedNodeID(i)
+		mergedNode := d.nodes[nodeID]
+		mergedNode[selfColumn] += node[2]
+		mergedNode[selfColumn+1] += node[3]
+	}
+}
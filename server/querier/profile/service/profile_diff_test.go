@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+func newTestProfileTree(functions []string, nodes [][]int) *model.ProfileTree {
+	tree := &model.ProfileTree{Functions: functions}
+	tree.NodeValues.Values = nodes
+	return tree
+}
+
+func TestDiffProfileTree(t *testing.T) {
+	// the parent node is appended after its child like GenerateProfile does
+	baseline := newTestProfileTree([]string{"app", "foo", "main", "bar"}, [][]int{
+		{0, -1, 0, 5},
+		{1, 2, 3, 3},
+		{2, 0, 0, 5},
+		{3, 2, 2, 2},
+	})
+	comparison := newTestProfileTree([]string{"app", "baz", "main", "foo"}, [][]int{
+		{0, -1, 0, 8},
+		{1, 2, 4, 4},
+		{2, 0, 0, 8},
+		{3, 2, 4, 4},
+	})
+	result := DiffProfileTree(baseline, comparison, "on-cpu")
+
+	if !reflect.DeepEqual(result.Functions, []string{"app", "main", "foo", "bar", "baz"}) {
+		t.Fatalf("got functions %v", result.Functions)
+	}
+	expected := [][]int{
+		{0, -1, 0, 5, 0, 8},
+		{1, 0, 0, 5, 0, 8},
+		{2, 1, 3, 3, 4, 4},
+		{3, 1, 2, 2, 0, 0},
+		{4, 1, 0, 0, 4, 4},
+	}
+	if !reflect.DeepEqual(result.NodeValues.Values, expected) {
+		t.Errorf("got nodes %v", result.NodeValues.Values)
+	}
+	if !reflect.DeepEqual(result.FunctionValues.Values[2], []int{3, 3, 4, 4}) {
+		t.Errorf("got function values %v", result.FunctionValues.Values)
+	}
+	if len(result.FunctionTypes) != len(result.Functions) {
+		t.Errorf("got function types %v", result.FunctionTypes)
+	}
+
+	// the comparison is empty
+	result = DiffProfileTree(baseline, &model.ProfileTree{}, "on-cpu")
+	if len(result.NodeValues.Values) != 4 || result.NodeValues.Values[0][3] != 5 || result.NodeValues.Values[0][5] != 0 {
+		t.Errorf("got nodes %v", result.NodeValues.Values)
+	}
+}
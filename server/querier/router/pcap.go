@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/service"
+)
+
+// pcapDownload exports the packets of a flow as a pcapng file, e.g.:
+// GET /v1/pcap/download?flow_id=1&time_start=1700000000&time_end=1700000060
+func pcapDownload() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		args := service.PcapDownloadParams{
+			Context: c.Request.Context(),
+			OrgID:   getOrgID(c),
+		}
+		var err error
+		if args.FlowID, err = strconv.ParseUint(c.Query("flow_id"), 10, 64); err != nil {
+			BadRequestResponse(c, common.INVALID_PARAMETERS, fmt.Sprintf("invalid flow_id: %s", err))
+			return
+		}
+		if args.TimeStart, err = strconv.ParseInt(c.Query("time_start"), 10, 64); err != nil {
+			BadRequestResponse(c, common.INVALID_PARAMETERS, fmt.Sprintf("invalid time_start: %s", err))
+			return
+		}
+		if args.TimeEnd, err = strconv.ParseInt(c.Query("time_end"), 10, 64); err != nil {
+			BadRequestResponse(c, common.INVALID_PARAMETERS, fmt.Sprintf("invalid time_end: %s", err))
+			return
+		}
+
+		data, err := service.PcapDownload(&args)
+		if err != nil {
+			if e, ok := err.(*common.ServiceError); ok && e.Status == common.INVALID_PARAMETERS {
+				BadRequestResponse(c, e.Status, e.Message)
+				return
+			}
+			JsonResponse(c, nil, nil, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=flow_%d.pcapng", args.FlowID))
+		c.Data(http.StatusOK, "application/octet-stream", data)
+	})
+}
@@ -17,17 +17,22 @@
 package router
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
 	"github.com/deepflowio/deepflow/server/querier/service"
 )
 
 func QueryRouter(e *gin.Engine) {
 	e.POST("/v1/query/", executeQuery())
+	e.GET("/v1/query/running/", listRunningQueries())
+	e.DELETE("/v1/query/running/:query_uuid", killRunningQuery())
+	e.GET("/v1/pcap/download", pcapDownload())
 
 	// api router for tempo
 	e.GET("/api/traces/:traceId", tempoTraceReader())
@@ -45,6 +50,7 @@ func executeQuery() gin.HandlerFunc {
 		args.UseQueryCache, _ = strconv.ParseBool(c.DefaultQuery("use_query_cache", "false"))
 		args.SimpleSql, _ = strconv.ParseBool(c.DefaultQuery("simple_sql", "false"))
 		args.QueryCacheTTL = c.Query("query_cache_ttl")
+		args.UseResultCache, _ = strconv.ParseBool(c.DefaultQuery("use_result_cache", "true"))
 		args.QueryUUID = c.Query("query_uuid")
 		args.NoPreWhere, _ = strconv.ParseBool(c.DefaultQuery("no_prewhere", "false"))
 		args.ORGID = c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
@@ -52,6 +58,7 @@ func executeQuery() gin.HandlerFunc {
 		if args.ORGID == "" {
 			args.ORGID = common.DEFAULT_ORG_ID
 		}
+		args.UserID = c.Request.Header.Get(common.HEADER_KEY_X_USER_ID)
 		if args.QueryUUID == "" {
 			query_uuid := uuid.New()
 			args.QueryUUID = query_uuid.String()
@@ -81,3 +88,31 @@ func executeQuery() gin.HandlerFunc {
 		JsonResponse(c, result, debug, err)
 	})
 }
+
+func getOrgID(c *gin.Context) string {
+	orgID := c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
+	if orgID == "" {
+		orgID = common.DEFAULT_ORG_ID
+	}
+	return orgID
+}
+
+// listRunningQueries returns the ClickHouse queries of the org being executed by this querier
+func listRunningQueries() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		JsonResponse(c, client.RunningQueries.List(getOrgID(c)), nil, nil)
+	})
+}
+
+// killRunningQuery cancels the ClickHouse queries of the query uuid and kills them in ClickHouse
+func killRunningQuery() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		queryUUID := c.Param("query_uuid")
+		count := client.RunningQueries.Kill(getOrgID(c), queryUUID)
+		if count == 0 {
+			JsonResponse(c, nil, nil, common.NewError(common.RESOURCE_NOT_FOUND, fmt.Sprintf("query %s is not running", queryUUID)))
+			return
+		}
+		JsonResponse(c, map[string]interface{}{"query_uuid": queryUUID, "killed_count": count}, nil, nil)
+	})
+}
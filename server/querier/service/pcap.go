@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	chCommon "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/packet_batch"
+)
+
+var log = logging.MustGetLogger("querier.service")
+
+const (
+	PCAP_MAX_BATCHES = 10000
+)
+
+// PcapDownloadParams selects the packets of a flow, the time range is in seconds
+type PcapDownloadParams struct {
+	Context   context.Context
+	OrgID     string
+	FlowID    uint64
+	TimeStart int64
+	TimeEnd   int64
+}
+
+// PcapDownload exports the TCP headers in l4_packet and the packets in
+// l7_packet of the flow as a pcapng file
+func PcapDownload(args *PcapDownloadParams) ([]byte, error) {
+	if config.Cfg == nil {
+		return nil, fmt.Errorf("querier config is not initialized")
+	}
+	if args.TimeEnd < args.TimeStart {
+		return nil, common.NewError(common.INVALID_PARAMETERS, "time_end should not be less than time_start")
+	}
+	db := chCommon.DB_NAME_FLOW_LOG
+	if id, err := strconv.Atoi(args.OrgID); err == nil {
+		db = ckdb.OrgDatabasePrefix(uint16(id)) + db
+	}
+	filter := fmt.Sprintf("flow_id=%d AND time>=%d AND time<=%d", args.FlowID, args.TimeStart, args.TimeEnd)
+
+	records := []packet_batch.PcapRecord{}
+	result, err := queryPcap(args, db, fmt.Sprintf("SELECT packet_batch FROM l4_packet WHERE %s ORDER BY start_time LIMIT %d", filter, PCAP_MAX_BATCHES))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Values) > 0 {
+		flow, err := queryPcapFlowInfo(args, db, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range result.Values {
+			batch, _ := value.([]interface{})[0].(string)
+			packets, err := packet_batch.DecodePacketBatch([]byte(batch))
+			if err != nil {
+				log.Warningf("decode packet_batch of flow %d failed: %s", args.FlowID, err)
+			}
+			records = append(records, packet_batch.BuildPacketRecords(flow, packets)...)
+		}
+	}
+
+	result, err = queryPcap(args, db, fmt.Sprintf("SELECT packet_batch FROM l7_packet WHERE %s ORDER BY start_time LIMIT %d", filter, PCAP_MAX_BATCHES))
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range result.Values {
+		batch, _ := value.([]interface{})[0].(string)
+		pcapRecords, err := packet_batch.ParsePcap([]byte(batch))
+		if err != nil {
+			log.Warningf("parse pcap of flow %d failed: %s", args.FlowID, err)
+		}
+		records = append(records, pcapRecords...)
+	}
+
+	if len(records) == 0 {
+		return nil, common.NewError(common.RESOURCE_NOT_FOUND, fmt.Sprintf("no packet of flow %d is found", args.FlowID))
+	}
+	buffer := &bytes.Buffer{}
+	if err := packet_batch.WritePcapng(buffer, records); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// queryPcapFlowInfo returns the addresses of the flow, which are unknown if the
+// flow log has been dropped or throttled
+func queryPcapFlowInfo(args *PcapDownloadParams, db, filter string) (*packet_batch.FlowInfo, error) {
+	flow := &packet_batch.FlowInfo{IsIPv4: true}
+	result, err := queryPcap(args, db, fmt.Sprintf(
+		"SELECT is_ipv4, ip4_0, ip4_1, ip6_0, ip6_1, client_port, server_port FROM l4_flow_log WHERE %s LIMIT 1", filter))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Values) == 0 {
+		log.Infof("l4_flow_log of flow %d is not found, the addresses of the packets are unknown", args.FlowID)
+		return flow, nil
+	}
+	row := result.Values[0].([]interface{})
+	isIPv4, _ := row[0].(uint8)
+	flow.IsIPv4 = isIPv4 == 1
+	if flow.IsIPv4 {
+		flow.IP0, _ = row[1].(net.IP)
+		flow.IP1, _ = row[2].(net.IP)
+	} else {
+		flow.IP0, _ = row[3].(net.IP)
+		flow.IP1, _ = row[4].(net.IP)
+	}
+	flow.ClientPort, _ = row[5].(uint16)
+	flow.ServerPort, _ = row[6].(uint16)
+	return flow, nil
+}
+
+func queryPcap(args *PcapDownloadParams, db, sql string) (*common.Result, error) {
+	chClient := client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       db,
+		Context:  args.Context,
+		Debug:    client.NewDebug(sql),
+	}
+	return chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: args.OrgID})
+}
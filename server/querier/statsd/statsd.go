@@ -160,3 +160,49 @@ func NewApiCounter() *ApiCounter {
 }
 
 var ApiCounters map[string]*ApiCounter
+
+type QueryCacheStats struct {
+	CacheHit        uint64 `statsd:"cache_hit"`
+	CachePartialHit uint64 `statsd:"cache_partial_hit"`
+	CacheMiss       uint64 `statsd:"cache_miss"`
+	CacheCoalesced  uint64 `statsd:"cache_coalesced"`
+}
+
+type QueryCacheCounter struct {
+	stats      *QueryCacheStats
+	writeMutex *sync.Mutex
+	exited     bool
+}
+
+func (c *QueryCacheCounter) Write(qs *QueryCacheStats) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.stats.CacheHit += qs.CacheHit
+	c.stats.CachePartialHit += qs.CachePartialHit
+	c.stats.CacheMiss += qs.CacheMiss
+	c.stats.CacheCoalesced += qs.CacheCoalesced
+}
+
+func (c *QueryCacheCounter) GetCounter() interface{} {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	counter := &QueryCacheStats{}
+	counter, c.stats = c.stats, counter
+	return counter
+}
+
+func (c *QueryCacheCounter) Close() {
+	c.exited = true
+}
+
+func (c *QueryCacheCounter) Closed() bool {
+	return c.exited
+}
+
+func NewQueryCacheCounter() *QueryCacheCounter {
+	return &QueryCacheCounter{
+		exited:     false,
+		stats:      &QueryCacheStats{},
+		writeMutex: &sync.Mutex{},
+	}
+}